@@ -0,0 +1,83 @@
+// Package app 管理 sniper 的子命令和子命令依赖的对象
+//
+// 每个子系统（http 服务、定时任务、消息消费等）都是一个子命令，
+// 在各自包的 init 中调用 Register 注册，并在 cmd/commands.go 中引入。
+// 子命令依赖的 DB、redis 等对象通过 Provide 注册构造函数，使用 Invoke 注入。
+package app
+
+import (
+	"sort"
+	"sync"
+
+	"sniper/util/conf"
+	"sniper/util/log"
+	"sniper/util/twirp"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	commandsMu sync.Mutex
+	commands   = map[string]*cobra.Command{}
+)
+
+// Register 注册子命令，子命令名称不能重复
+func Register(cmd *cobra.Command) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+
+	name := cmd.Name()
+	if _, ok := commands[name]; ok {
+		panic("app: command " + name + " is already registered")
+	}
+	commands[name] = cmd
+}
+
+// Commands 返回所有注册的子命令，按名称排序
+func Commands() []*cobra.Command {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+
+	cmds := make([]*cobra.Command, 0, len(commands))
+	for _, c := range commands {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name() < cmds[j].Name() })
+	return cmds
+}
+
+// Execute 执行子命令，子命令返回后关闭容器中的对象
+func Execute() error {
+	var env string
+	root := &cobra.Command{
+		Use: "sniper",
+		// 命令行指定的环境优先于环境变量 SNIPER_ENV 和 DEPLOY_ENV
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			conf.SetEnv(env)
+			logConf()
+		},
+	}
+	root.PersistentFlags().StringVar(&env, "env", "", "conf env, load conf/{env}.toml over conf/base.toml")
+	root.AddCommand(Commands()...)
+
+	// 调用下游 twirp 服务时带上当前服务标识，方便下游统计废弃接口的调用方
+	twirp.CallerName = conf.AppID
+	// 业务在 init 中设置的回调保留在后面
+	twirp.DefaultClientHooks = twirp.ChainClientHooks(clientMetrics, clientBaggage, twirp.DefaultClientHooks)
+
+	defer func() {
+		if err := Close(); err != nil {
+			logger().Error(err)
+		}
+	}()
+	return root.Execute()
+}
+
+// logConf 启动时输出生效的配置，排查配置覆盖问题
+func logConf() {
+	fields := log.Fields{"env": conf.Env}
+	for _, s := range conf.Dump() {
+		fields[s.Key] = s.Value + " (" + s.Source + ")"
+	}
+	logger().WithFields(fields).Info("effective conf")
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"sniper/util/errors"
+	"sniper/util/metrics"
+	"sniper/util/trace"
+	"sniper/util/twirp"
+	"sniper/util/xhttp"
+)
+
+type clientStartKey struct{}
+
+// clientMetrics 按下游服务和方法记录生成的客户端的调用耗时和结果
+//
+// code 标签为 ok、twirp 错误码、transport（连接失败、超时等没有收到响应的错误）
+// 或者 circuit_open（xhttp 熔断），后两种错误下游服务端的监控统计不到。
+var clientMetrics = &twirp.ClientHooks{
+	RequestPrepared: func(ctx context.Context, req *http.Request) (context.Context, error) {
+		return context.WithValue(ctx, clientStartKey{}, time.Now()), nil
+	},
+	ResponseReceived: func(ctx context.Context) {
+		observeClient(ctx, "ok")
+	},
+	Error: func(ctx context.Context, err twirp.Error) {
+		code := string(err.Code())
+		if twirp.IsTransportError(err) {
+			code = "transport"
+			if errors.Cause(err) == xhttp.ErrCircuitOpen {
+				code = "circuit_open"
+			}
+		}
+		observeClient(ctx, code)
+	},
+}
+
+func observeClient(ctx context.Context, code string) {
+	start, ok := ctx.Value(clientStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	service, method := twirp.ClientMethod(ctx)
+	metrics.RPCClientDurationsSeconds.WithLabelValues(service, method, code).Observe(time.Since(start).Seconds())
+}
+
+// clientBaggage 调用下游服务时传递 ctx 中允许传递的 baggage
+var clientBaggage = &twirp.ClientHooks{
+	RequestPrepared: func(ctx context.Context, req *http.Request) (context.Context, error) {
+		trace.InjectBaggageHeader(ctx, req.Header)
+		return ctx, nil
+	},
+}
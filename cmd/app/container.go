@@ -0,0 +1,149 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+var (
+	mu        sync.Mutex
+	providers = map[reflect.Type]*provider{}
+	// instances 已经创建的对象，按照创建顺序保存，Close 时倒序关闭
+	instances []reflect.Value
+)
+
+type provider struct {
+	fn        reflect.Value
+	value     reflect.Value
+	done      bool
+	resolving bool
+}
+
+// Provide 注册构造函数，构造函数的参数由容器注入，返回值为提供的类型
+//
+// 构造函数的形式为 func(deps...) T 或者 func(deps...) (T, error)，
+// 每个类型只能注册一次，对象在第一次被依赖时才会创建，之后一直复用。
+//
+//	app.Provide(func(c *conf.Conf) (*sql.DB, error) {
+//		return sql.Open("mysql", c.Get("DB_DSN"))
+//	})
+func Provide(constructor interface{}) {
+	fn := reflect.ValueOf(constructor)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.NumOut() == 0 || t.NumOut() > 2 ||
+		(t.NumOut() == 2 && t.Out(1) != errorType) {
+		panic(fmt.Sprintf("app: invalid constructor %s", t))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	out := t.Out(0)
+	if _, ok := providers[out]; ok {
+		panic(fmt.Sprintf("app: %s is already provided", out))
+	}
+	providers[out] = &provider{fn: fn}
+}
+
+// Invoke 注入 fn 的参数并调用 fn，fn 最后一个返回值为 error 时返回该错误
+//
+// 构造函数执行时会持有容器的锁，不能在构造函数中调用 Invoke。
+func Invoke(fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		panic(fmt.Sprintf("app: invalid function %s", f.Type()))
+	}
+
+	args, err := lockedResolveArgs(f.Type())
+	if err != nil {
+		return err
+	}
+
+	out := f.Call(args)
+	if n := len(out); n > 0 && f.Type().Out(n-1) == errorType && !out[n-1].IsNil() {
+		return out[n-1].Interface().(error)
+	}
+	return nil
+}
+
+// MustInvoke 同 Invoke，出错时 panic，一般用于子命令的入口
+func MustInvoke(fn interface{}) {
+	if err := Invoke(fn); err != nil {
+		panic(err)
+	}
+}
+
+// lockedResolveArgs 持有容器的锁解析参数，构造函数 panic 时也会释放锁
+func lockedResolveArgs(t reflect.Type) ([]reflect.Value, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return resolveArgs(t)
+}
+
+func resolveArgs(t reflect.Type) ([]reflect.Value, error) {
+	args := make([]reflect.Value, t.NumIn())
+	for i := range args {
+		v, err := resolve(t.In(i))
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+func resolve(t reflect.Type) (reflect.Value, error) {
+	p, ok := providers[t]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("app: no provider for %s", t)
+	}
+	if p.done {
+		return p.value, nil
+	}
+	if p.resolving {
+		return reflect.Value{}, fmt.Errorf("app: circular dependency on %s", t)
+	}
+
+	p.resolving = true
+	defer func() { p.resolving = false }()
+
+	args, err := resolveArgs(p.fn.Type())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	// 构造失败时不缓存，下次依赖时重试
+	out := p.fn.Call(args)
+	if len(out) == 2 && !out[1].IsNil() {
+		return reflect.Value{}, fmt.Errorf("app: provide %s: %w", t, out[1].Interface().(error))
+	}
+
+	p.value, p.done = out[0], true
+	instances = append(instances, out[0])
+	return p.value, nil
+}
+
+// Close 按照创建顺序倒序关闭所有实现了 io.Closer 的对象，返回第一个错误
+//
+// 关闭之后再次依赖时会重新创建对象。
+func Close() (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i := len(instances) - 1; i >= 0; i-- {
+		if c, ok := instances[i].Interface().(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	instances = nil
+	for _, p := range providers {
+		p.value, p.done = reflect.Value{}, false
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testConfig struct{ dsn string }
+
+type testDB struct {
+	cfg    *testConfig
+	closed bool
+}
+
+func (db *testDB) Close() error {
+	db.closed = true
+	return nil
+}
+
+type testA struct{}
+type testB struct{}
+type testPanic struct{}
+
+func TestContainer(t *testing.T) {
+	var created int
+	Provide(func() *testConfig { return &testConfig{dsn: "test"} })
+	Provide(func(c *testConfig) (*testDB, error) {
+		created++
+		return &testDB{cfg: c}, nil
+	})
+
+	var db *testDB
+	err := Invoke(func(d1 *testDB, d2 *testDB) {
+		if d1 != d2 || d1.cfg.dsn != "test" {
+			t.Errorf("dependency should be singleton")
+		}
+		db = d1
+	})
+	if err != nil || created != 1 {
+		t.Fatalf("Invoke = %v, created %d", err, created)
+	}
+
+	if err := Close(); err != nil || !db.closed {
+		t.Errorf("Close = %v, closed %v", err, db.closed)
+	}
+	MustInvoke(func(*testDB) {})
+	if created != 2 {
+		t.Errorf("dependency should be recreated after Close")
+	}
+
+	want := errors.New("boom")
+	if err := Invoke(func(*testConfig) error { return want }); err != want {
+		t.Errorf("Invoke should return fn error: %v", err)
+	}
+	if err := Invoke(func(int) {}); err == nil {
+		t.Errorf("missing provider should fail")
+	}
+
+	Provide(func(*testB) *testA { return nil })
+	Provide(func(*testA) *testB { return nil })
+	if err := Invoke(func(*testA) {}); err == nil {
+		t.Errorf("circular dependency should fail")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("duplicated provider should panic")
+		}
+	}()
+	Provide(func() *testConfig { return nil })
+}
+
+func TestContainerPanic(t *testing.T) {
+	Provide(func() *testPanic { panic("boom") })
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic of constructor should be propagated")
+			}
+		}()
+		_ = Invoke(func(*testPanic) {})
+	}()
+
+	// 构造函数 panic 之后容器仍然可用
+	done := make(chan error, 1)
+	go func() { done <- Invoke(func() {}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Invoke = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Invoke should not deadlock after a constructor panics")
+	}
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/db"
+	"sniper/util/log"
+)
+
+// 默认提供配置、日志和默认 DB，DB 需要在 main 中引入对应的驱动，
+// redis 等其他依赖由业务在 init 中调用 Provide 注册
+func init() {
+	Provide(func() *conf.Conf { return conf.File("sniper") })
+	Provide(func() log.Logger { return logger() })
+	Provide(newDB)
+}
+
+func logger() log.Logger {
+	return log.Get(context.Background())
+}
+
+// newDB 使用配置 DB_DEFAULT_DSN 连接默认数据库，驱动默认为 mysql，连接池指标的 name 为 default
+func newDB(c *conf.Conf) (*sql.DB, error) {
+	dsn := c.Get("DB_DEFAULT_DSN")
+	if dsn == "" {
+		return nil, errors.New("DB_DEFAULT_DSN is not configured")
+	}
+	driver := c.Get("DB_DEFAULT_DRIVER")
+	if driver == "" {
+		driver = "mysql"
+	}
+
+	conn, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if n := c.GetInt("DB_DEFAULT_MAX_OPEN_CONNS"); n > 0 {
+		conn.SetMaxOpenConns(n)
+	}
+	if n := c.GetInt("DB_DEFAULT_MAX_IDLE_CONNS"); n > 0 {
+		conn.SetMaxIdleConns(n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	db.Register("default", conn)
+	return conn, nil
+}
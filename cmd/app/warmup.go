@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/metrics"
+)
+
+type warmupTask struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+var (
+	warmupMu    sync.Mutex
+	warmupTasks []warmupTask
+)
+
+// Warmup 注册预热任务，服务在所有预热任务执行完之后才开始接收请求
+//
+// 预热任务用于填充缓存、建立连接池、解析模版等，避免发布后的第一批请求耗时过高。
+// timeout 为 0 时使用配置 WARMUP_TIMEOUT，默认 10 秒。
+// 预热失败或者超时只记录日志，不影响服务启动。
+//
+//	func init() {
+//		app.Warmup("db", time.Second, func(ctx context.Context) error {
+//			return app.Invoke(func(db *sql.DB) error { return db.PingContext(ctx) })
+//		})
+//	}
+func Warmup(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+
+	warmupTasks = append(warmupTasks, warmupTask{name: name, timeout: timeout, fn: fn})
+}
+
+// RunWarmup 并发执行所有预热任务，等待全部完成后返回，由子命令在开始接收请求之前调用
+func RunWarmup() {
+	warmupMu.Lock()
+	tasks := warmupTasks
+	warmupMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		wg.Add(1)
+		go func(t warmupTask) {
+			defer wg.Done()
+			t.run()
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (t warmupTask) run() {
+	timeout := t.timeout
+	if timeout <= 0 {
+		timeout = conf.GetDuration("WARMUP_TIMEOUT")
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v stack: %s", r, debug.Stack())
+			}
+		}()
+		done <- t.fn(ctx)
+	}()
+
+	// 预热任务不响应 ctx 时也不能阻塞启动
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+		logger().WithField("task", t.name).Errorf("warmup failed: %v", err)
+	}
+
+	d := time.Since(start)
+	metrics.WarmupDurationSeconds.WithLabelValues(t.name, result).Set(d.Seconds())
+	logger().WithField("task", t.name).WithField("cost", d.Seconds()).Info("warmup done")
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunWarmup(t *testing.T) {
+	warmupTasks = nil
+	defer func() { warmupTasks = nil }()
+
+	var done int32
+	Warmup("ok", time.Second, func(ctx context.Context) error {
+		atomic.AddInt32(&done, 1)
+		return nil
+	})
+	Warmup("error", time.Second, func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	Warmup("panic", time.Second, func(ctx context.Context) error {
+		panic("boom")
+	})
+	Warmup("stuck", 10*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	RunWarmup()
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("stuck task should time out, took %s", d)
+	}
+	if atomic.LoadInt32(&done) != 1 {
+		t.Errorf("task should run once")
+	}
+}
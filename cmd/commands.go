@@ -0,0 +1,11 @@
+// Package cmd 引入所有子命令，子命令在各自包的 init 中调用 app.Register 注册
+//
+// 使用 sniper cmd --name=foo 生成的子命令会自动追加到这里。
+package cmd
+
+import (
+	_ "sniper/cmd/consumer"
+	_ "sniper/cmd/errcode"
+	_ "sniper/cmd/job"
+	_ "sniper/cmd/server"
+)
@@ -0,0 +1,125 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	httpd "net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"sniper/cmd/app"
+	"sniper/util"
+	"sniper/util/conf"
+	"sniper/util/log"
+	"sniper/util/mq"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+type subscription struct {
+	topic   string
+	handler mq.Handler
+}
+
+var subscriptions []subscription
+
+var port int
+
+func init() {
+	Cmd.Flags().IntVar(&port, "port", 8080, "metrics listen port")
+
+	app.Provide(newSubscriber)
+	app.Register(Cmd)
+}
+
+// Cmd 消费消息
+var Cmd = &cobra.Command{
+	Use:   "consumer",
+	Short: "Run mq consumer",
+	Long:  `Subscribe all topics registered by subscribe and consume messages until SIGTERM.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		server := &httpd.Server{Addr: fmt.Sprintf(":%d", port), Handler: metricsMux()}
+		go func() {
+			if err := server.ListenAndServe(); err != httpd.ErrServerClosed {
+				panic(err)
+			}
+		}()
+
+		conf.OnConfigChange(func() { util.Reset() })
+		conf.WatchConfig()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+			<-stop
+			cancel()
+		}()
+
+		app.RunWarmup()
+
+		// Subscriber 实现了 io.Closer 时，子命令退出后由容器关闭，等待处理中的消息
+		app.MustInvoke(func(sub mq.Subscriber, logger log.Logger) error {
+			return run(ctx, sub, logger)
+		})
+
+		server.Shutdown(context.Background())
+		util.Stop()
+	},
+}
+
+// subscribe 注册 topic 的消息处理函数，统一解析 baggage 和请求优先级
+//
+// 去重等其他中间件通过 mws 传入，Handler 返回错误时消息会被重新投递。
+func subscribe(topic string, h mq.Handler, mws ...mq.Middleware) {
+	for _, s := range subscriptions {
+		if s.topic == topic {
+			panic("topic " + topic + " is subscribed")
+		}
+	}
+
+	mws = append([]mq.Middleware{mq.Baggage(), mq.Priority()}, mws...)
+	subscriptions = append(subscriptions, subscription{topic: topic, handler: mq.Chain(h, mws...)})
+}
+
+// run 订阅所有 topic，ctx 取消后返回
+func run(ctx context.Context, sub mq.Subscriber, logger log.Logger) error {
+	for _, s := range subscriptions {
+		if err := sub.Subscribe(s.topic, s.handler); err != nil {
+			return fmt.Errorf("subscribe %s: %w", s.topic, err)
+		}
+		logger.Infof("subscribe %s", s.topic)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// newSubscriber 根据 MQ_DRIVER 创建 Subscriber，默认为进程内的 mq.LocalBus
+//
+// 接入 kafka 等消息队列时在这里增加对应的实现。
+func newSubscriber(c *conf.Conf) (mq.Subscriber, error) {
+	switch driver := c.Get("MQ_DRIVER"); driver {
+	case "", "local":
+		return mq.NewLocalBus(), nil
+	default:
+		return nil, fmt.Errorf("unsupported MQ_DRIVER %s", driver)
+	}
+}
+
+func metricsMux() *httpd.ServeMux {
+	mux := httpd.NewServeMux()
+
+	metricsHandler := promhttp.Handler()
+	mux.HandleFunc("/metrics", func(w httpd.ResponseWriter, r *httpd.Request) {
+		util.GatherMetrics()
+
+		metricsHandler.ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/monitor/ping", func(w httpd.ResponseWriter, r *httpd.Request) {
+		w.Write([]byte("pong"))
+	})
+	return mux
+}
@@ -0,0 +1,56 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"sniper/cmd/app"
+	"sniper/util/log"
+	"sniper/util/mq"
+	"sniper/util/twirp"
+)
+
+func TestRun(t *testing.T) {
+	rec := mq.NewRecorder()
+	var priority twirp.Priority
+	subscribe("test", func(ctx context.Context, msg *mq.Message) error {
+		priority = twirp.GetPriority(ctx)
+		return nil
+	}, rec.Middleware())
+
+	var bus *mq.LocalBus
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	app.MustInvoke(func(sub mq.Subscriber, logger log.Logger) error {
+		bus = sub.(*mq.LocalBus)
+		return run(ctx, sub, logger)
+	})
+
+	msg := &mq.Message{Topic: "test", Header: map[string]string{twirp.PriorityHeader: "batch"}}
+	if err := bus.Publish(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	bus.Wait()
+
+	if n := len(rec.Consumed("test")); n != 1 {
+		t.Errorf("consumed = %d, want 1", n)
+	}
+	if priority != twirp.PriorityBatch {
+		t.Errorf("priority = %s, want batch", priority)
+	}
+
+	// 退出时由容器关闭 Subscriber
+	if err := app.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Publish(context.Background(), msg); err != mq.ErrBusClosed {
+		t.Errorf("bus should be closed, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicated topic should panic")
+		}
+	}()
+	subscribe("test", func(ctx context.Context, msg *mq.Message) error { return nil })
+}
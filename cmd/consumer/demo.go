@@ -0,0 +1,17 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"sniper/util/mq"
+)
+
+// 消息消费示例，开源专用
+
+func init() {
+	subscribe("demo", func(ctx context.Context, msg *mq.Message) error {
+		fmt.Printf("consume %s: %s\n", msg.ID, msg.Body)
+		return nil
+	})
+}
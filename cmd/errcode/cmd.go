@@ -0,0 +1,50 @@
+package errcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"sniper/cmd/app"
+	"sniper/util/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var format string
+
+func init() {
+	Cmd.Flags().StringVar(&format, "format", "markdown", "output format, markdown or json")
+
+	app.Register(Cmd)
+}
+
+// Cmd 导出所有注册过的业务错误码
+var Cmd = &cobra.Command{
+	Use:   "errcode",
+	Short: "List business error codes",
+	Long: `List all business error codes registered by util/errors,
+the output can be published to the docs portal.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		codes := errors.Codes()
+
+		switch format {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(codes); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		case "markdown":
+			fmt.Println("| 服务 | 错误码 | twirp 错误码 | HTTP 状态码 | 说明 |")
+			fmt.Println("| --- | --- | --- | --- | --- |")
+			for _, c := range codes {
+				fmt.Printf("| %s | %d | %s | %d | %s |\n", c.Service, c.Code, c.TwirpCode, c.Status, c.Msg)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "unknown format %q\n", format)
+			os.Exit(1)
+		}
+	},
+}
@@ -14,6 +14,7 @@ import (
 	"syscall"
 	"time"
 
+	"sniper/cmd/app"
 	"sniper/util"
 
 	"sniper/util/conf"
@@ -48,6 +49,8 @@ var port int
 
 func init() {
 	Cmd.Flags().IntVar(&port, "port", 8080, "metrics listen port")
+
+	app.Register(Cmd)
 }
 
 // Cmd run job once or periodically
@@ -86,7 +89,8 @@ If you run job cmd WITHOUT any sub cmd, job will be sheduled like cron.`,
 			})
 
 			httpd.HandleFunc("/RunTask", func(w httpd.ResponseWriter, r *httpd.Request) {
-				ctx := context.Background()
+				// 触发任务的服务可以通过 baggage 头传递用户、租户等信息
+				ctx := trace.ExtractBaggage(context.Background(), r.Header.Get(trace.BaggageHeader))
 				span, ctx := opentracing.StartSpanFromContext(ctx, "RunTask")
 				defer span.Finish()
 
@@ -253,6 +257,8 @@ func regjob(name string, spec string, job func(ctx context.Context) error, tasks
 
 		span.SetTag("name", name)
 		ctx = ctxkit.WithTraceID(ctx, trace.GetTraceID(ctx))
+		// 任务中发送的消息和调用的服务通过 baggage 关联到任务
+		ctx = trace.WithBaggage(ctx, "job", name)
 
 		logger := log.Get(ctx)
 
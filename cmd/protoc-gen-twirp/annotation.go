@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// annotations 返回注释中所有名为 name 的注解值
+//
+// 注解需要独占一行，格式为 @name 或者 @name:value，如：
+//
+//	// @auth
+//	// @flag:new_checkout
+//
+// 没有值的注解返回空字符串
+func annotations(comments protogen.Comments, name string) (values []string) {
+	for _, line := range strings.Split(string(comments), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "@"+name) {
+			continue
+		}
+
+		rest := line[len(name)+1:]
+		if rest == "" {
+			values = append(values, "")
+		} else if rest[0] == ':' {
+			values = append(values, strings.TrimSpace(rest[1:]))
+		}
+	}
+	return
+}
+
+// annotation 返回第一个名为 name 的注解值，ok 表示注解是否存在
+func annotation(comments protogen.Comments, name string) (value string, ok bool) {
+	values := annotations(comments, name)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// methodAnnotation 先查询方法注解，找不到再查询服务注解
+func methodAnnotation(method *protogen.Method, service *protogen.Service, name string) (string, bool) {
+	if v, ok := annotation(method.Comments.Leading, name); ok {
+		return v, true
+	}
+	return annotation(service.Comments.Leading, name)
+}
+
+// errorCodes twirp.ErrorCode 常量名到错误码的映射
+var errorCodes = map[string]string{
+	"Canceled":           "canceled",
+	"Unknown":            "unknown",
+	"InvalidArgument":    "invalid_argument",
+	"DeadlineExceeded":   "deadline_exceeded",
+	"NotFound":           "not_found",
+	"BadRoute":           "bad_route",
+	"AlreadyExists":      "already_exists",
+	"PermissionDenied":   "permission_denied",
+	"Unauthenticated":    "unauthenticated",
+	"ResourceExhausted":  "resource_exhausted",
+	"FailedPrecondition": "failed_precondition",
+	"Aborted":            "aborted",
+	"OutOfRange":         "out_of_range",
+	"Unimplemented":      "unimplemented",
+	"Internal":           "internal",
+	"Unavailable":        "unavailable",
+	"DataLoss":           "data_loss",
+}
+
+// errorDoc 接口注释中声明的错误码
+type errorDoc struct {
+	Name    string // twirp.ErrorCode 常量名，如 NotFound
+	Code    string // 错误码，如 not_found
+	Comment string
+}
+
+// errorDocs 返回方法及所属服务通过 @error 声明的错误码，格式为：
+//
+//	// @error:NotFound user not exists
+//
+// 错误码必须是 twirp.ErrorCode 的常量名，否则直接报错
+func errorDocs(method *protogen.Method, service *protogen.Service) (docs []errorDoc) {
+	values := annotations(method.Comments.Leading, "error")
+	values = append(values, annotations(service.Comments.Leading, "error")...)
+	for _, v := range values {
+		name, comment := v, ""
+		if i := strings.IndexAny(v, " \t"); i > 0 {
+			name, comment = v[:i], strings.TrimSpace(v[i+1:])
+		}
+		code, ok := errorCodes[name]
+		if !ok {
+			fail("invalid @error of %s: %q", method.Desc.FullName(), v)
+		}
+		docs = append(docs, errorDoc{Name: name, Code: code, Comment: comment})
+	}
+	return
+}
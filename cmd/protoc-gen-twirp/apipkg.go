@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// apiPackage 服务代码（.twirp.go 以及 graphql、upstream 等 Go 代码）所在的包
+//
+// 默认与 protoc-gen-go 生成的 message 在同一个包。设置 api_package 参数后
+// 生成到 message 包下名为 {包名}{api_package} 的子包，只使用 message 的程序
+// 不再依赖 net/http 和 twirp 运行库。
+type apiPackage struct {
+	name       protogen.GoPackageName
+	importPath protogen.GoImportPath
+	// 生成文件名前缀，同 protogen.File.GeneratedFilenamePrefix
+	filenamePrefix string
+}
+
+func (t *twirp) apiPackage(file *protogen.File) apiPackage {
+	if t.APIPackage == "" {
+		return apiPackage{
+			name:           file.GoPackageName,
+			importPath:     file.GoImportPath,
+			filenamePrefix: file.GeneratedFilenamePrefix,
+		}
+	}
+
+	name := string(file.GoPackageName) + t.APIPackage
+	return apiPackage{
+		name:       protogen.GoPackageName(name),
+		importPath: protogen.GoImportPath(path.Join(string(file.GoImportPath), name)),
+		filenamePrefix: path.Join(
+			path.Dir(file.GeneratedFilenamePrefix),
+			name,
+			path.Base(file.GeneratedFilenamePrefix),
+		),
+	}
+}
+
+// generateValidateExports 为 message 生成导出的 Validate 方法
+//
+// validate 方法定义在 message 上，只能与 message 生成在同一个包中，
+// 子包中的服务代码通过 Validate 调用。
+func (t *twirp) generateValidateExports(file *protogen.File) []byte {
+	t.P()
+	for _, message := range file.Messages {
+		name := message.GoIdent.GoName
+		t.P(`// Validate checks the field rules of `, name, `.`)
+		t.P(`func (m *`, name, `) Validate() error { return m.validate() }`)
+		t.P()
+		t.P(`// ValidateAll checks the field rules of `, name, ` and returns all violations.`)
+		t.P(`func (m *`, name, `) ValidateAll() error { return m.validateAll() }`)
+		t.P()
+	}
+	b := append([]byte(nil), t.output.Bytes()...)
+	t.output.Reset()
+	return b
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// defaultBatchConcurrency @batchable 没有指定并发数时同时处理的请求数
+const defaultBatchConcurrency = 8
+
+// batchConcurrency 返回 @batchable 注解声明的批量接口并发数，没有注解时返回 0
+func (t *twirp) batchConcurrency(service *protogen.Service, method *protogen.Method) int {
+	v, ok := annotation(method.Comments.Leading, "batchable")
+	if !ok {
+		return 0
+	}
+	if v == "" {
+		return defaultBatchConcurrency
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		fail("invalid @batchable of %s: %q", t.pathFor(service, method), v)
+	}
+	return n
+}
+
+// hasBatchable 判断服务是否有批量接口
+func (t *twirp) hasBatchable(service *protogen.Service) bool {
+	for _, method := range service.Methods {
+		if t.batchConcurrency(service, method) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func batchPathConst(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "BatchPath"
+}
+
+func batchResult(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "BatchResult"
+}
+
+// checkBatch 检查批量接口
+//
+// 批量接口逐个调用普通接口的实现，只支持普通接口；加密和长轮询依赖单个请求的 HTTP 上下文，也不支持。
+func (t *twirp) checkBatch(file *protogen.File) {
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if t.batchConcurrency(service, method) == 0 {
+				continue
+			}
+			if isStreaming(method) {
+				fail("@batchable is not supported by streaming method %s", method.Desc.FullName())
+			}
+			for _, name := range []string{"encrypted", "longpoll"} {
+				if _, ok := methodAnnotation(method, service, name); ok {
+					fail("@%s is not supported by batchable method %s", name, method.Desc.FullName())
+				}
+			}
+		}
+	}
+}
+
+// generateBatchTypes 生成批量接口的结果类型和客户端使用的 {Service}BatchClient 接口
+func (t *twirp) generateBatchTypes(service *protogen.Service) {
+	if !t.hasBatchable(service) {
+		return
+	}
+	ctx := t.pkgs["context"] + ".Context"
+
+	for _, method := range service.Methods {
+		if t.batchConcurrency(service, method) == 0 {
+			continue
+		}
+		result := batchResult(service, method)
+		t.P()
+		t.P(`// `, result, ` is the result of a single request in a `, method.GoName, `Batch call.`)
+		t.P(`// Exactly one of Response and Err is set.`)
+		t.P(`type `, result, ` struct {`)
+		t.P(`  Response *`, t.getType(method.Output))
+		t.P(`  Err      error`)
+		t.P(`}`)
+	}
+
+	t.P()
+	t.P(`// `, service.GoName, `BatchClient is implemented by all `, service.GoName, ` clients. Each batch method`)
+	t.P(`// sends many requests in one HTTP call, results are in the same order as the requests.`)
+	t.P(`type `, service.GoName, `BatchClient interface {`)
+	for _, method := range service.Methods {
+		if t.batchConcurrency(service, method) == 0 {
+			continue
+		}
+		t.P(`  `, method.GoName, `Batch(`, ctx, `, []*`, t.getType(method.Input), `) ([]`, batchResult(service, method), `, error)`)
+	}
+	t.P(`}`)
+}
+
+func (t *twirp) generateBatchClientMethod(name, structName string, file *protogen.File, service *protogen.Service, method *protogen.Method, index int) {
+	methName := method.GoName
+	inputType := t.getType(method.Input)
+	outputType := t.getType(method.Output)
+	result := batchResult(service, method)
+
+	codec := t.pkgs["twirp"] + "." + name + "Codec"
+	if name == "Codec" {
+		codec = "c.codec"
+	}
+
+	t.P(`func (c *`, structName, `) `, methName, `Batch(ctx `, t.pkgs["context"], `.Context, in []*`, inputType, `) ([]`, result, `, error) {`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithPackageName(ctx, "`, *file.Proto.Package, `")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithServiceName(ctx, "`, service.GoName, `")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `Batch")`)
+	if _, ok := methodAnnotation(method, service, "priority"); ok {
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithDefaultPriority(ctx, `, t.pkgs["twirp"], `.`, t.priority(service, method), `)`)
+	}
+	t.P(`  ctx, cancel := `, t.pkgs["twirp"], `.ClientBudget(ctx)`)
+	t.P(`  defer cancel()`)
+	t.P(`  items := make(`, t.pkgs["twirp"], `.BatchItems, len(in))`)
+	t.P(`  for i := range in {`)
+	t.P(`    items[i] = in[i]`)
+	t.P(`  }`)
+	t.P(`  results, err := `, t.pkgs["twirp"], `.DoBatchRequest(ctx, c.client, `, codec, `, c.urls[`, strconv.Itoa(index), `]+"Batch", items, new(`, outputType, `))`)
+	t.P(`  if err != nil {`)
+	t.P(`    return nil, err`)
+	t.P(`  }`)
+	t.P(`  out := make([]`, result, `, len(results))`)
+	t.P(`  for i, r := range results {`)
+	t.P(`    if r.Err != nil {`)
+	t.P(`      out[i].Err = r.Err`)
+	t.P(`    } else {`)
+	t.P(`      out[i].Response = r.Response.(*`, outputType, `)`)
+	t.P(`    }`)
+	t.P(`  }`)
+	t.P(`  return out, nil`)
+	t.P(`}`)
+	t.P()
+}
+
+// generateBatchServerMethod 生成批量接口的 serve{Method}Batch 方法
+//
+// 鉴权、功能开关等请求级别的逻辑只执行一次，校验、配额和业务方法调用对每个请求单独执行，
+// 单个请求的错误写入对应的结果，不影响其他请求。
+func (t *twirp) generateBatchServerMethod(service *protogen.Service, method *protogen.Method) {
+	concurrency := t.batchConcurrency(service, method)
+	if concurrency == 0 {
+		return
+	}
+	methName := method.GoName
+	inputType := t.getType(method.Input)
+	outputType := t.getType(method.Output)
+
+	t.P(`func (s *`, serviceStruct(service), `) serve`, methName, `Batch(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
+	t.P(`  var err error`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `Batch")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithRequestPriority(ctx, req, `, t.pkgs["twirp"], `.`, t.priority(service, method), `)`)
+	t.P(`  ctx, err = s.hooks.CallRequestRouted(ctx)`)
+	t.P(`  if err != nil {`)
+	t.P(`    s.writeError(ctx, resp, err)`)
+	t.P(`    return`)
+	t.P(`  }`)
+	t.P()
+	t.generateMethodPrelude(service, method)
+	// 登录检查在读取请求体之前执行，配额按照每个子请求扣减，在解析之后检查
+	if t.ValidateEnable && t.needLogin(method, service) {
+		t.P(`  if ctxkit.GetUserID(ctx) == 0 {`)
+		t.P(`    s.writeError(ctx, resp, twirp.NewError(twirp.Unauthenticated, "need login"))`)
+		t.P(`    return`)
+		t.P(`  }`)
+	}
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "auth")`)
+	t.P(`  codec, items, err := `, t.pkgs["twirp"], `.ReadBatchRequest(req, new(`, inputType, `))`)
+	t.P(`  if err != nil {`)
+	t.P(`    s.writeError(ctx, resp, err)`)
+	t.P(`    return`)
+	t.P(`  }`)
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "decode")`)
+	t.P()
+	t.generateCanary(service, method)
+	t.P(`  results := `, t.pkgs["twirp"], `.RunBatch(ctx, len(items), `, strconv.Itoa(concurrency), `, func(ctx `, t.pkgs["context"], `.Context, i int) `, t.pkgs["twirp"], `.BatchResult {`)
+	t.P(`    reqContent := items[i].(*`, inputType, `)`)
+	t.P(`    ctx = `, t.pkgs["twirp"], `.WithRequest(ctx, reqContent)`)
+	if t.ValidateEnable {
+		validate := "validate"
+		if t.APIPackage != "" {
+			validate = "Validate"
+		}
+		t.P(`    if validerr := reqContent.`, validate, `(); validerr != nil {`)
+		t.P(`      return `, t.pkgs["twirp"], `.BatchResult{Err: `, t.pkgs["twirp"], `.InvalidArgumentError("argument", validerr.Error())}`)
+		t.P(`    }`)
+	}
+	for _, q := range t.quotas(service, method) {
+		t.P(`    if err := `, t.pkgs["twirp"], `.CheckQuota(ctx, `, strconv.Quote(q.name), `, `, strconv.FormatInt(q.limit, 10), `); err != nil {`)
+		t.P(`      return `, t.pkgs["twirp"], `.BatchResult{Err: err}`)
+		t.P(`    }`)
+	}
+	t.P(`    respContent, err := impl.`, methName, `(ctx, reqContent)`)
+	if len(errorDocs(method, service)) > 0 {
+		t.P(`    err = `, t.pkgs["twirp"], `.CheckErrorCode(`, methodPathConst(service, method), `, err)`)
+	}
+	t.P(`    if err != nil {`)
+	t.P(`      return `, t.pkgs["twirp"], `.BatchResult{Err: err}`)
+	t.P(`    }`)
+	t.P(`    if respContent == nil {`)
+	if isEmpty(method.Output) {
+		t.P(`      respContent = new(`, outputType, `)`)
+	} else {
+		t.P(`      return `, t.pkgs["twirp"], `.BatchResult{Err: `, t.pkgs["twirp"], `.InternalError("received a nil *`, outputType, ` and nil error while calling `, methName, `. nil responses are not supported")}`)
+	}
+	t.P(`    }`)
+	t.P(`    return `, t.pkgs["twirp"], `.BatchResult{Response: respContent}`)
+	t.P(`  })`)
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "handler")`)
+	t.P()
+	t.P(`  `, t.pkgs["twirp"], `.WriteBatchResponse(ctx, resp, s.hooks, codec, results)`)
+	t.P(`}`)
+	t.P()
+}
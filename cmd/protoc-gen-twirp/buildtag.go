@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 可以单独设置构建约束的生成文件
+const (
+	artifactValidate = "validate"
+	artifactExplorer = "explorer"
+	artifactGraphQL  = "graphql"
+	artifactUpstream = "upstream"
+	artifactGenerics = "generics"
+	artifactContract = "contract_test"
+	artifactGRPC     = "grpc"
+)
+
+var artifacts = map[string]bool{
+	artifactValidate: true,
+	artifactExplorer: true,
+	artifactGraphQL:  true,
+	artifactUpstream: true,
+	artifactGenerics: true,
+	artifactContract: true,
+	artifactGRPC:     true,
+}
+
+// buildTags 解析 build_tag 参数，格式为 {artifact}={expr}，可以设置多次
+//
+//	--twirp_opt=build_tag=explorer=tools --twirp_opt='build_tag=graphql=!prod'
+type buildTags map[string]string
+
+func (b buildTags) String() string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ss []string
+	for _, name := range names {
+		ss = append(ss, name+"="+b[name])
+	}
+	return strings.Join(ss, ",")
+}
+
+func (b buildTags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return fmt.Errorf("invalid build_tag %q, should be like explorer=tools", s)
+	}
+
+	name, expr := s[:i], strings.TrimSpace(s[i+1:])
+	if !artifacts[name] {
+		return fmt.Errorf("unknown artifact %q of build_tag", name)
+	}
+	if expr == "" || strings.Contains(expr, "\n") {
+		return fmt.Errorf("invalid build constraint %q of %s", expr, name)
+	}
+	b[name] = expr
+	return nil
+}
+
+// buildConstraint 返回 artifact 对应文件的 //go:build 行，base 为文件本身需要的约束
+//
+// 没有设置约束时只使用 base，两者都为空时返回空字符串。
+func (t *twirp) buildConstraint(artifact, base string) string {
+	expr := t.BuildTags[artifact]
+	switch {
+	case expr == "" && base == "":
+		return ""
+	case expr == "":
+		return "//go:build " + base
+	case base == "":
+		return "//go:build " + expr
+	default:
+		return "//go:build " + base + " && (" + expr + ")"
+	}
+}
+
+// generateBuildConstraint 在文件开头输出构建约束
+func (t *twirp) generateBuildConstraint(artifact, base string) {
+	if c := t.buildConstraint(artifact, base); c != "" {
+		t.P(c)
+		t.P()
+	}
+}
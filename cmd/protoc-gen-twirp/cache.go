@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// generatedFile 待输出的生成文件
+type generatedFile struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content"`
+
+	// 是否为需要格式化的 Go 代码，缓存中的内容都已经格式化过
+	goSource bool
+}
+
+// writeFile 记录生成的文件
+//
+// Go 代码在当前文件生成结束后统一格式化，content 会被复制，
+// 调用方可以继续复用缓冲区。
+func (t *twirp) writeFile(name string, content []byte, goSource bool) {
+	t.files = append(t.files, &generatedFile{
+		Name:     name,
+		Content:  append([]byte(nil), content...),
+		goSource: goSource,
+	})
+}
+
+// cacheKey 计算 proto 文件生成结果的缓存键
+//
+// 生成结果只由插件版本、插件参数、proto 文件及其依赖的描述以及该文件在本次
+// 生成中的序号决定，任何一项变化都会重新生成。
+func (t *twirp) cacheKey(file *protogen.File) string {
+	h := sha256.New()
+	io.WriteString(h, Version)
+	io.WriteString(h, "\x00")
+	io.WriteString(h, t.plugin.Request.GetParameter())
+	io.WriteString(h, "\x00")
+	io.WriteString(h, strconv.Itoa(t.filesHandled))
+
+	visited := map[string]bool{}
+	var walk func(fd protoreflect.FileDescriptor)
+	walk = func(fd protoreflect.FileDescriptor) {
+		if visited[fd.Path()] {
+			return
+		}
+		visited[fd.Path()] = true
+
+		f, ok := t.plugin.FilesByPath[fd.Path()]
+		if !ok {
+			return
+		}
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(f.Proto)
+		if err != nil {
+			fail("marshal descriptor of %s: %v", fd.Path(), err)
+		}
+		io.WriteString(h, "\x00")
+		h.Write(b)
+
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			walk(imports.Get(i).FileDescriptor)
+		}
+	}
+	walk(file.Desc)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadCache 读取缓存的生成结果，不存在或者损坏时返回 false
+func (t *twirp) loadCache(key string) ([]*generatedFile, bool) {
+	b, err := ioutil.ReadFile(filepath.Join(t.CacheDir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+
+	var files []*generatedFile
+	if err := json.Unmarshal(b, &files); err != nil {
+		return nil, false
+	}
+	return files, true
+}
+
+// saveCache 保存生成结果
+//
+// 先写临时文件再重命名，多个 protoc 进程共用缓存目录时不会读到写了一半的文件。
+// 缓存只用于加速，写入失败不影响生成。
+func (t *twirp) saveCache(key string, files []*generatedFile) {
+	b, err := json.Marshal(files)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(t.CacheDir, 0o755); err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(t.CacheDir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	_ = os.Rename(tmp.Name(), filepath.Join(t.CacheDir, key+".json"))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateContractTest 生成 {file}_contract_test.go
+//
+// 测试使用脚手架生成的 {Service}Server 作为服务实现，
+// 回放 testdata/contract/{Service} 目录下录制的用例。
+func (t *twirp) generateContractTest(file *protogen.File) {
+	api := t.apiPackage(file)
+	t.generateBuildConstraint(artifactContract, "")
+	t.P("// Package ", string(api.name), " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.Desc.Path())
+	t.P(`package `, string(api.name))
+	t.P()
+	t.P(`import "testing"`)
+	t.P()
+	t.P(`import "`, t.TwirpPackage, `/contract"`)
+	// 服务实现与 message 在同一个包中
+	impl := ""
+	if t.APIPackage != "" {
+		impl = path.Base(string(file.GoImportPath)) + "."
+		t.P(`import `, strconv.Quote(string(file.GoImportPath)))
+	}
+	t.P()
+
+	for _, service := range file.Services {
+		servName := service.GoName
+		dir := "testdata/contract/" + servName
+		t.P(`// Test`, servName, `Contract replays the cases in `, dir, `.`)
+		t.P(`// Run with `, "CONTRACT_UPDATE=1", ` to record responses.`)
+		t.P(`func Test`, servName, `Contract(t *testing.T) {`)
+		t.P(`  server := New`, servName, `Server(&`, impl, servName, `Server{}, nil)`)
+		t.P(`  contract.Run(t, server, `, servName, `PathPrefix, `, strconv.Quote(dir), `)`)
+		t.P(`}`)
+		t.P()
+	}
+
+	fname := fmt.Sprintf("%s_contract_test.go", api.filenamePrefix)
+	t.writeFile(fname, t.output.Bytes(), true)
+	t.output.Reset()
+}
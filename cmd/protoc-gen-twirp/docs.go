@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// generateDocs 为每个服务生成 docs/{Service}.md 接口文档，供开发者门户导入
+//
+// 文档包括服务和接口的注释、鉴权、配额、错误码等注解，以及请求、响应字段和
+// JSON、表单两种格式的请求示例，注释中的注解行不会出现在正文中。
+func (t *twirp) generateDocs(file *protogen.File) {
+	for _, service := range file.Services {
+		buf := &bytes.Buffer{}
+
+		fmt.Fprintf(buf, "# %s\n\n", service.Desc.FullName())
+		if text := docText(service.Comments.Leading); text != "" {
+			fmt.Fprintf(buf, "%s\n\n", text)
+		}
+		fmt.Fprintf(buf, "- 源文件：`%s`\n", file.Desc.Path())
+		fmt.Fprintf(buf, "- 路径前缀：`%s`\n", t.pathPrefix(service))
+		for _, a := range [][2]string{{"owner", "负责人"}, {"team", "团队"}, {"oncall", "值班"}} {
+			if v, ok := annotation(service.Comments.Leading, a[0]); ok && v != "" {
+				fmt.Fprintf(buf, "- %s：%s\n", a[1], v)
+			}
+		}
+		if isWebhook(service) {
+			buf.WriteString("- 回调服务：由接收方实现，请求头 `Webhook-Signature` 为签名，失败时以相同的 `Webhook-Id` 重试\n")
+		}
+
+		for _, method := range service.Methods {
+			t.generateMethodDoc(buf, service, method)
+		}
+
+		fname := path.Join(path.Dir(file.GeneratedFilenamePrefix), "docs", service.GoName+".md")
+		t.writeFile(fname, buf.Bytes(), false)
+	}
+}
+
+func (t *twirp) generateMethodDoc(buf *bytes.Buffer, service *protogen.Service, method *protogen.Method) {
+	fmt.Fprintf(buf, "\n## %s\n\n", method.GoName)
+	if replacement, ok := t.deprecated(service, method); ok {
+		if replacement != "" {
+			fmt.Fprintf(buf, "> 已废弃，请使用 %s\n\n", replacement)
+		} else {
+			fmt.Fprintf(buf, "> 已废弃\n\n")
+		}
+	}
+	if text := docText(method.Comments.Leading); text != "" {
+		fmt.Fprintf(buf, "%s\n\n", text)
+	}
+
+	if isWebSocket(method) {
+		fmt.Fprintf(buf, "- 路径：`GET %s`（WebSocket）\n", t.pathFor(service, method))
+	} else {
+		fmt.Fprintf(buf, "- 路径：`POST %s`\n", t.pathFor(service, method))
+	}
+	if t.needLogin(method, service) {
+		fmt.Fprintf(buf, "- 鉴权：需要登录\n")
+	} else {
+		fmt.Fprintf(buf, "- 鉴权：不需要登录\n")
+	}
+	if _, ok := methodAnnotation(method, service, "internal"); ok {
+		fmt.Fprintf(buf, "- 内部接口：只允许内部调用\n")
+	}
+	if _, ok := methodAnnotation(method, service, "tenant"); ok {
+		fmt.Fprintf(buf, "- 租户：需要租户 ID\n")
+	}
+	switch {
+	case isServerStreaming(method) && t.sse():
+		fmt.Fprintf(buf, "- 流式响应：返回 Server-Sent Events，支持 GET 请求，可以直接使用 EventSource\n")
+	case isServerStreaming(method):
+		fmt.Fprintf(buf, "- 流式响应：JSON 请求返回 NDJSON，每行一条响应\n")
+	case isWebSocket(method) && method.Desc.IsStreamingServer():
+		fmt.Fprintf(buf, "- 双向流式：通过 WebSocket 逐条发送请求和接收响应\n")
+	case isWebSocket(method):
+		fmt.Fprintf(buf, "- 流式请求：通过 WebSocket 逐条发送请求，发送完毕后返回一条响应\n")
+	}
+	if longpoll := t.longPollTimeout(service, method); longpoll > 0 {
+		fmt.Fprintf(buf, "- 长轮询：没有新数据时最多等待 `%s`，超时返回只带请求游标的空响应\n", longpoll)
+	}
+	if _, ok := methodAnnotation(method, service, "get"); ok {
+		fmt.Fprintf(buf, "- GET 请求：参数按表单解析，同时支持 HEAD 和 OPTIONS 请求\n")
+	}
+	for _, rule := range httpRules(method) {
+		fmt.Fprintf(buf, "- RESTful：`%s %s`，路径变量赋值到同名字段\n", rule.method, rule.pattern)
+	}
+	if n := t.batchConcurrency(service, method); n > 0 {
+		fmt.Fprintf(buf, "- 批量接口：`POST %sBatch`，最多同时处理 %d 个请求，结果与请求一一对应\n", t.pathFor(service, method), n)
+	}
+	items := []struct{ name, label string }{
+		{"quota", "配额"},
+		{"timeout", "超时"},
+		{"retry", "重试"},
+		{"priority", "优先级"},
+		{"dedup", "请求去重"},
+		{"cache", "响应缓存"},
+		{"flag", "功能开关"},
+	}
+	for _, item := range items {
+		if v, ok := methodAnnotation(method, service, item.name); ok {
+			if v == "" {
+				v = "是"
+			}
+			fmt.Fprintf(buf, "- %s：`%s`\n", item.label, v)
+		}
+	}
+
+	fmt.Fprintf(buf, "\n### 请求参数\n\n")
+	docFields(buf, method.Input)
+	fmt.Fprintf(buf, "\n### 响应参数\n\n")
+	docFields(buf, method.Output)
+
+	if docs := errorDocs(method, service); len(docs) > 0 {
+		fmt.Fprintf(buf, "\n### 错误码\n\n")
+		fmt.Fprintf(buf, "| 错误码 | 说明 |\n| --- | --- |\n")
+		for _, doc := range docs {
+			fmt.Fprintf(buf, "| `%s` | %s |\n", doc.Code, docEscape(doc.Comment))
+		}
+	}
+
+	url := "http://localhost:8080/api" + t.pathFor(service, method)
+	fmt.Fprintf(buf, "\n### 请求示例\n\n")
+	fmt.Fprintf(buf, "```bash\n# JSON\ncurl -X POST -H 'Content-Type: application/json' \\\n  -d '%s' \\\n  %s\n",
+		explorerExample(method.Input, 0), url)
+	if t.Compat != "twitch" {
+		fmt.Fprintf(buf, "# 表单\ncurl -X POST -d '%s' \\\n  %s\n", formExample(method.Input), url)
+	}
+	fmt.Fprintf(buf, "```\n")
+}
+
+// docFields 输出 message 的字段表格
+func docFields(buf *bytes.Buffer, m *protogen.Message) {
+	if len(m.Fields) == 0 {
+		fmt.Fprintf(buf, "无\n")
+		return
+	}
+
+	fmt.Fprintf(buf, "| 字段 | 类型 | 说明 | 规则 |\n| --- | --- | --- | --- |\n")
+	for _, field := range m.Fields {
+		var rules []string
+		for _, line := range strings.Split(string(field.Comments.Leading), "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "@") {
+				rules = append(rules, "`"+docEscape(line)+"`")
+			}
+		}
+		text := strings.Replace(docText(field.Comments.Leading), "\n", " ", -1)
+		if field.Desc.Options().(*descriptorpb.FieldOptions).GetDeprecated() {
+			text = strings.TrimSpace("已废弃 " + text)
+		}
+		fmt.Fprintf(buf, "| `%s` | %s | %s | %s |\n", field.Desc.Name(), docType(field), docEscape(text), strings.Join(rules, " "))
+	}
+}
+
+// docType 返回字段的 proto 类型，如 repeated int64、map<string, echo.v1.User>
+func docType(field *protogen.Field) string {
+	kind := func(f *protogen.Field) string {
+		switch {
+		case f.Message != nil:
+			return string(f.Message.Desc.FullName())
+		case f.Enum != nil:
+			return string(f.Enum.Desc.FullName())
+		default:
+			return f.Desc.Kind().String()
+		}
+	}
+
+	switch {
+	case field.Desc.IsMap():
+		return fmt.Sprintf("map<%s, %s>", kind(field.Message.Fields[0]), kind(field.Message.Fields[1]))
+	case field.Desc.IsList():
+		return "repeated " + kind(field)
+	default:
+		return kind(field)
+	}
+}
+
+// formExample 生成表单请求示例，只包含表单支持的基础类型字段
+func formExample(m *protogen.Message) string {
+	var params []string
+	for _, field := range m.Fields {
+		ft, _ := getFieldType(field.Desc.Kind())
+		if ft == "" || field.Desc.IsMap() {
+			continue
+		}
+
+		v := "0"
+		switch ft {
+		case "string":
+			v = ""
+		case "bool":
+			v = "false"
+		}
+		params = append(params, string(field.Desc.Name())+"="+v)
+	}
+	return strings.Join(params, "&")
+}
+
+// docText 返回去掉注解行的注释
+func docText(comments protogen.Comments) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(comments)), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "@") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// docEscape 转义表格中的 |
+func docEscape(s string) string {
+	return strings.Replace(s, "|", `\|`, -1)
+}
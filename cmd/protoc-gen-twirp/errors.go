@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+)
+
+// generateError 生成过程中遇到的错误，比如注解格式不对
+//
+// 生成代码的调用层次很深，逐层返回 error 会让每个 t.P 都需要检查错误。
+// 出错时调用 fail 中止当前文件的生成，generateFile 恢复之后附加 proto 文件名，
+// 最终通过 protogen 返回给 protoc，不会造成插件崩溃。
+type generateError struct {
+	err error
+}
+
+// fail 中止当前文件的生成，错误信息应包含出错的服务或方法
+func fail(format string, args ...interface{}) {
+	panic(generateError{fmt.Errorf(format, args...)})
+}
+
+// recoverError 把 fail 的 panic 转换为 err，其他 panic 继续抛出
+func recoverError(file string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	e, ok := r.(generateError)
+	if !ok {
+		panic(r)
+	}
+	*err = fmt.Errorf("%s: %w", file, e.err)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// explorerPath 返回接口调试页面的路径
+func (t *twirp) explorerPath(service *protogen.Service) string {
+	return t.pathPrefix(service) + "__explorer"
+}
+
+// generateExplorerMethods 生成接口调试页面使用的方法列表
+//
+// 通过 build_tag 参数为调试页面设置构建约束时，twirp.go 中只声明变量，
+// 方法列表在 {file}.explorer.go 的 init 中赋值，不满足约束时变量为空，不挂载调试页面。
+func (t *twirp) generateExplorerMethods(service *protogen.Service) {
+	if _, ok := t.BuildTags[artifactExplorer]; ok {
+		t.P(`var `, explorerMethodsVar(service), ` []`, t.pkgs["twirp"], `.ExplorerMethod`)
+		t.P()
+		return
+	}
+	t.P(`var `, explorerMethodsVar(service), ` = `, t.explorerMethodsLiteral(service))
+	t.P()
+}
+
+// generateExplorer 生成 {file}.explorer.go，只在设置了调试页面的构建约束时使用
+func (t *twirp) generateExplorer(file *protogen.File) {
+	api := t.apiPackage(file)
+	t.generateBuildConstraint(artifactExplorer, "")
+	t.P("// Package ", string(api.name), " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.Desc.Path())
+	t.P(`package `, string(api.name))
+	t.P()
+	t.P(`import `, t.pkgs["twirp"], ` "`, t.TwirpPackage, `"`)
+	t.P()
+	t.P(`func init() {`)
+	for _, service := range file.Services {
+		t.P(`  `, explorerMethodsVar(service), ` = `, t.explorerMethodsLiteral(service))
+	}
+	t.P(`}`)
+
+	t.writeFile(api.filenamePrefix+".explorer.go", t.output.Bytes(), true)
+	t.output.Reset()
+}
+
+func explorerMethodsVar(service *protogen.Service) string {
+	return unexported(service.GoName) + "ExplorerMethods"
+}
+
+// explorerMethodsLiteral 返回方法列表的字面量
+func (t *twirp) explorerMethodsLiteral(service *protogen.Service) string {
+	var b strings.Builder
+	b.WriteString(`[]` + t.pkgs["twirp"] + ".ExplorerMethod{\n")
+	for _, method := range service.Methods {
+		// 调试页面只能发送单个请求
+		if isWebSocket(method) {
+			continue
+		}
+		b.WriteString("{\n")
+		b.WriteString(`Name: ` + strconv.Quote(method.GoName) + ",\n")
+		b.WriteString(`Comment: ` + strconv.Quote(strings.TrimSpace(string(method.Comments.Leading))) + ",\n")
+		b.WriteString(`Example: ` + strconv.Quote(explorerExample(method.Input, 0)) + ",\n")
+		b.WriteString("},\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// explorerExample 生成 message 的 JSON 请求示例，字段都取默认值
+func explorerExample(m *protogen.Message, depth int) string {
+	// 避免递归 message 无限展开
+	if depth > 3 {
+		return "{}"
+	}
+
+	fields := make([]string, 0, len(m.Fields))
+	for _, field := range m.Fields {
+		var v string
+		switch {
+		case field.Desc.IsMap():
+			v = "{}"
+		case field.Desc.IsList():
+			v = "[]"
+		default:
+			v = explorerValue(field, depth)
+		}
+		fields = append(fields, strconv.Quote(field.Desc.JSONName())+":"+v)
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+func explorerValue(field *protogen.Field, depth int) string {
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return "false"
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return `""`
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return `"0"`
+	case protoreflect.EnumKind:
+		return strconv.Quote(string(field.Enum.Values[0].Desc.Name()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if field.Message.Desc.FullName().Parent() == "google.protobuf" {
+			return "null"
+		}
+		return explorerExample(field.Message, depth+1)
+	default:
+		return "0"
+	}
+}
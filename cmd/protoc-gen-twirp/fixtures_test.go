@@ -0,0 +1,265 @@
+package main
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// fixtureFiles 返回 testdata/golden 中 fixture 的描述，与 protoc --include_imports 的输出一致
+//
+// golden 文件使用这里的描述生成，不需要安装 protoc；TestGoldenFixtures 在安装了 protoc 时
+// 检查两者没有差异，修改 fixture 时需要同时修改这里。
+func fixtureFiles() []*descriptorpb.FileDescriptorProto {
+	return []*descriptorpb.FileDescriptorProto{
+		scalarsFixture(),
+		collectionsFixture(),
+		userFixture(),
+		multiFixture(),
+	}
+}
+
+// fixtureField 创建字段，json_name 与 protoc 的规则相同
+func fixtureField(name, jsonName string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+		JsonName: proto.String(jsonName),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func repeatedField(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return f
+}
+
+func oneofField(f *descriptorpb.FieldDescriptorProto, index int32) *descriptorpb.FieldDescriptorProto {
+	f.OneofIndex = proto.Int32(index)
+	return f
+}
+
+// mapEntry 创建 map 字段对应的 XxxEntry message
+func mapEntry(name string, key, value *descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{
+		Name:    proto.String(name),
+		Field:   []*descriptorpb.FieldDescriptorProto{key, value},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+}
+
+func fixtureMethod(name, input, output string) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String(name),
+		InputType:  proto.String(input),
+		OutputType: proto.String(output),
+	}
+}
+
+// fixtureComments 返回注释的位置信息，只记录生成代码会用到的 leading comments
+func fixtureComments(comments map[string][]int32) *descriptorpb.SourceCodeInfo {
+	info := &descriptorpb.SourceCodeInfo{}
+	for comment, path := range comments {
+		info.Location = append(info.Location, &descriptorpb.SourceCodeInfo_Location{
+			Path:            path,
+			Span:            []int32{0, 0, 0},
+			LeadingComments: proto.String(comment),
+		})
+	}
+	return info
+}
+
+func scalarsFixture() *descriptorpb.FileDescriptorProto {
+	types := []descriptorpb.FieldDescriptorProto_Type{
+		descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+		descriptorpb.FieldDescriptorProto_TYPE_INT32,
+		descriptorpb.FieldDescriptorProto_TYPE_INT64,
+		descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+		descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+		descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+		descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+		descriptorpb.FieldDescriptorProto_TYPE_STRING,
+		descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	}
+	names := []string{
+		"double", "float", "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+		"fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "string", "bytes",
+	}
+	fields := make([]*descriptorpb.FieldDescriptorProto, len(types))
+	for i, typ := range types {
+		fields[i] = fixtureField(names[i]+"_value", names[i]+"Value", int32(i+1), typ, "")
+	}
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("scalars.proto"),
+		Package: proto.String("fixture.scalars"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("sniper/fixture/scalars;scalars"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:  proto.String("Scalars"),
+			Field: fields,
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				fixtureMethod("Echo", ".fixture.scalars.Scalars", ".fixture.scalars.Scalars"),
+			},
+		}},
+		SourceCodeInfo: fixtureComments(map[string][]int32{
+			" 所有标量类型\n": {4, 0},
+			" 原样返回请求\n": {6, 0, 2, 0},
+		}),
+		Syntax: proto.String("proto3"),
+	}
+}
+
+func collectionsFixture() *descriptorpb.FileDescriptorProto {
+	const (
+		int32Type   = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		int64Type   = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		stringType  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		enumType    = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+		messageType = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+
+		color      = ".fixture.collections.Color"
+		item       = ".fixture.collections.Item"
+		collection = ".fixture.collections.Collection"
+	)
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("collections.proto"),
+		Package: proto.String("fixture.collections"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("sniper/fixture/collections;collections"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				fixtureField("id", "id", 1, int64Type, ""),
+				fixtureField("name", "name", 2, stringType, ""),
+			},
+		}, {
+			Name: proto.String("Collection"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				repeatedField(fixtureField("ids", "ids", 1, int64Type, "")),
+				repeatedField(fixtureField("names", "names", 2, stringType, "")),
+				repeatedField(fixtureField("items", "items", 3, messageType, item)),
+				repeatedField(fixtureField("counts", "counts", 4, messageType, collection+".CountsEntry")),
+				repeatedField(fixtureField("index", "index", 5, messageType, collection+".IndexEntry")),
+				fixtureField("color", "color", 6, enumType, color),
+				repeatedField(fixtureField("colors", "colors", 7, enumType, color)),
+				oneofField(fixtureField("key_id", "keyId", 8, int64Type, ""), 0),
+				oneofField(fixtureField("key_name", "keyName", 9, stringType, ""), 0),
+				oneofField(fixtureField("key_item", "keyItem", 10, messageType, item), 0),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				mapEntry("CountsEntry",
+					fixtureField("key", "key", 1, stringType, ""),
+					fixtureField("value", "value", 2, int64Type, "")),
+				mapEntry("IndexEntry",
+					fixtureField("key", "key", 1, int64Type, ""),
+					fixtureField("value", "value", 2, messageType, item)),
+			},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{
+				Name: proto.String("key"),
+			}},
+		}, {
+			Name: proto.String("ListRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				fixtureField("offset", "offset", 1, int64Type, ""),
+				fixtureField("limit", "limit", 2, int32Type, ""),
+				fixtureField("color", "color", 3, enumType, color),
+			},
+		}},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Color"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("COLOR_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("COLOR_RED"), Number: proto.Int32(1)},
+				{Name: proto.String("COLOR_GREEN"), Number: proto.Int32(2)},
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Collections"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				fixtureMethod("List", ".fixture.collections.ListRequest", collection),
+				fixtureMethod("Save", collection, collection),
+			},
+		}},
+		SourceCodeInfo: fixtureComments(map[string][]int32{
+			" 重复字段、map、枚举以及 oneof\n": {4, 1},
+			" 查询列表\n": {6, 0, 2, 0},
+		}),
+		Syntax: proto.String("proto3"),
+	}
+}
+
+func userFixture() *descriptorpb.FileDescriptorProto {
+	int64Type := descriptorpb.FieldDescriptorProto_TYPE_INT64
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("common/user.proto"),
+		Package: proto.String("fixture.common"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("sniper/fixture/common;common"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				fixtureField("id", "id", 1, int64Type, ""),
+				fixtureField("name", "name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			},
+		}, {
+			Name: proto.String("UserID"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				fixtureField("id", "id", 1, int64Type, ""),
+			},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{},
+		Syntax:         proto.String("proto3"),
+	}
+}
+
+func multiFixture() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("multi.proto"),
+		Package:    proto.String("fixture.multi"),
+		Dependency: []string{"common/user.proto"},
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("sniper/fixture/multi;multi"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Empty"),
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Users"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				fixtureMethod("Get", ".fixture.common.UserID", ".fixture.common.User"),
+				fixtureMethod("Update", ".fixture.common.User", ".fixture.multi.Empty"),
+			},
+		}, {
+			Name: proto.String("Admin"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				fixtureMethod("Delete", ".fixture.common.UserID", ".fixture.multi.Empty"),
+			},
+		}},
+		SourceCodeInfo: fixtureComments(map[string][]int32{
+			" 请求和响应都是其他包中的 message\n":            {6, 0},
+			" 同一个文件中的第二个服务\n\n @internal\n":      {6, 1},
+			" @error:NotFound user not exists\n": {6, 1, 2, 0},
+			" @cache:1m\n":                       {6, 0, 2, 0},
+		}),
+		Syntax: proto.String("proto3"),
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// methodGateway 接口在网关上的超时和重试配置
+//
+// 分别对应 @timeout:3s 和 @retry:2 注解，方法注解优先于服务注解；
+// 长轮询接口没有 @timeout 注解时超时为等待时间加上 longPollGatewayMargin；
+// 客户端流式和双向流式接口需要网关转发 WebSocket 握手
+type methodGateway struct {
+	path      string
+	timeout   time.Duration
+	retries   int
+	websocket bool
+}
+
+// longPollGatewayMargin 长轮询接口的网关超时比最长等待时间多出的部分，留给服务输出响应
+const longPollGatewayMargin = 5 * time.Second
+
+func (t *twirp) methodGateways(service *protogen.Service) (gws []methodGateway) {
+	for _, method := range service.Methods {
+		gw := methodGateway{path: t.pathFor(service, method), websocket: isWebSocket(method)}
+
+		longpoll := t.longPollTimeout(service, method)
+		if v, ok := methodAnnotation(method, service, "timeout"); ok {
+			timeout, err := time.ParseDuration(v)
+			if err != nil {
+				fail("invalid @timeout of %s: %v", gw.path, err)
+			}
+			if timeout <= 0 {
+				fail("invalid @timeout of %s: %q", gw.path, v)
+			}
+			if longpoll > 0 && timeout <= longpoll {
+				fail("@timeout of %s must be longer than @longpoll %s", gw.path, longpoll)
+			}
+			gw.timeout = timeout
+		} else if longpoll > 0 {
+			gw.timeout = longpoll + longPollGatewayMargin
+		}
+
+		if v, ok := methodAnnotation(method, service, "retry"); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				fail("invalid @retry of %s: %q", gw.path, v)
+			}
+			gw.retries = n
+		}
+
+		if gw.timeout == 0 && gw.retries == 0 && !gw.websocket {
+			continue
+		}
+		gws = append(gws, gw)
+	}
+	return
+}
+
+// envoyDuration 返回 envoy 配置使用的时长，单位为秒，可以带小数，如 65s、0.5s
+func envoyDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// nginxDuration 返回 nginx 配置使用的时长，整秒使用 s，否则使用 ms，不足一毫秒按一毫秒计算
+//
+// nginx 不支持小数，也不支持 1m5s 这种不带空格的组合格式
+func nginxDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+	return strconv.FormatInt(int64((d+time.Millisecond-1)/time.Millisecond), 10) + "ms"
+}
+
+// generateGateway 根据 gateway 参数生成 envoy 路由片段或者 nginx location 配置
+func (t *twirp) generateGateway(file *protogen.File) {
+	var buf bytes.Buffer
+	var fname string
+
+	switch t.Gateway {
+	case "envoy":
+		fname = file.GeneratedFilenamePrefix + ".envoy.yaml"
+		t.generateEnvoyRoutes(&buf, file)
+	case "nginx":
+		fname = file.GeneratedFilenamePrefix + ".nginx.conf"
+		t.generateNginxLocations(&buf, file)
+	default:
+		fail("unknown gateway %q, only envoy and nginx are supported", t.Gateway)
+	}
+
+	t.writeFile(fname, buf.Bytes(), false)
+}
+
+// generateEnvoyRoutes 生成 envoy RouteConfiguration 中 virtual_hosts.routes 的片段
+//
+// 带有超时或重试注解的接口和 WebSocket 接口单独生成路由，需要排在服务前缀路由之前
+func (t *twirp) generateEnvoyRoutes(buf *bytes.Buffer, file *protogen.File) {
+	fmt.Fprintf(buf, "# Generated by protoc-gen-twirp %s, DO NOT EDIT.\n", Version)
+	fmt.Fprintf(buf, "# source: %s\n", file.Desc.Path())
+
+	for _, service := range file.Services {
+		for _, gw := range t.methodGateways(service) {
+			fmt.Fprintf(buf, "- match:\n")
+			fmt.Fprintf(buf, "    path: %s\n", gw.path)
+			fmt.Fprintf(buf, "  route:\n")
+			fmt.Fprintf(buf, "    cluster: %s\n", t.GatewayCluster)
+			if gw.timeout > 0 {
+				fmt.Fprintf(buf, "    timeout: %s\n", envoyDuration(gw.timeout))
+			}
+			if gw.retries > 0 {
+				fmt.Fprintf(buf, "    retry_policy:\n")
+				fmt.Fprintf(buf, "      retry_on: connect-failure,refused-stream,unavailable\n")
+				fmt.Fprintf(buf, "      num_retries: %d\n", gw.retries)
+			}
+			if gw.websocket {
+				fmt.Fprintf(buf, "    upgrade_configs:\n")
+				fmt.Fprintf(buf, "    - upgrade_type: websocket\n")
+			}
+		}
+
+		fmt.Fprintf(buf, "- match:\n")
+		fmt.Fprintf(buf, "    prefix: %s\n", t.pathPrefix(service))
+		fmt.Fprintf(buf, "  route:\n")
+		fmt.Fprintf(buf, "    cluster: %s\n", t.GatewayCluster)
+	}
+}
+
+// generateNginxLocations 生成 nginx server 块中的 location 配置
+func (t *twirp) generateNginxLocations(buf *bytes.Buffer, file *protogen.File) {
+	fmt.Fprintf(buf, "# Generated by protoc-gen-twirp %s, DO NOT EDIT.\n", Version)
+	fmt.Fprintf(buf, "# source: %s\n", file.Desc.Path())
+
+	for _, service := range file.Services {
+		for _, gw := range t.methodGateways(service) {
+			fmt.Fprintf(buf, "\nlocation = %s {\n", gw.path)
+			fmt.Fprintf(buf, "    proxy_pass http://%s;\n", t.GatewayCluster)
+			if gw.timeout > 0 {
+				fmt.Fprintf(buf, "    proxy_read_timeout %s;\n", nginxDuration(gw.timeout))
+			}
+			if gw.retries > 0 {
+				fmt.Fprintf(buf, "    proxy_next_upstream error timeout http_502 http_503 non_idempotent;\n")
+				fmt.Fprintf(buf, "    proxy_next_upstream_tries %d;\n", gw.retries+1)
+			}
+			if gw.websocket {
+				fmt.Fprintf(buf, "    proxy_http_version 1.1;\n")
+				fmt.Fprintf(buf, "    proxy_set_header Upgrade $http_upgrade;\n")
+				fmt.Fprintf(buf, "    proxy_set_header Connection \"upgrade\";\n")
+			}
+			fmt.Fprintf(buf, "}\n")
+		}
+
+		fmt.Fprintf(buf, "\nlocation %s {\n", t.pathPrefix(service))
+		fmt.Fprintf(buf, "    proxy_pass http://%s;\n", t.GatewayCluster)
+		fmt.Fprintf(buf, "}\n")
+	}
+}
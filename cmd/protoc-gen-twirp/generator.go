@@ -19,20 +19,25 @@ import (
 	"compress/gzip"
 	"crypto/sha1"
 	"fmt"
+	"go/format"
 	"go/parser"
-	"go/printer"
 	"go/token"
 	"io"
-	"log"
+	"mime"
 	"path"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"sniper/cmd/protoc-gen-twirp/templates"
 	"sniper/cmd/protoc-gen-twirp/templates/rule"
 
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -41,6 +46,12 @@ import (
 
 const Version = "v0.1.0"
 
+// GengoVersion messages 参数生成 *.pb.go 使用的 protoc-gen-go 版本，与 go.mod 中的 google.golang.org/protobuf 一致
+//
+// internal_gengo 是 protoc-gen-go 的内部包，没有兼容性保证。升级 google.golang.org/protobuf 时
+// 需要同时修改这里，并确认 GenerateFile 和 Supported* 的用法没有变化。
+const GengoVersion = "v1.34.1"
+
 type twirp struct {
 	// OptionPrefix method_option flag
 	OptionPrefix string
@@ -50,13 +61,77 @@ type twirp struct {
 	TwirpPackage string
 	// 是否开启 validate
 	ValidateEnable bool
-
+	// 是否同时为没有服务的 proto 文件生成 validate 代码，开启时 ValidateEnable 也会开启
+	// 公共的请求、响应 message 单独定义时，嵌套字段的递归校验依赖这些代码
+	ValidateAll bool
+	// 是否把响应输出逻辑内联到每个接口方法
+	// 默认调用 twirp 运行库的公共函数，可以大幅减少生成的代码量
+	InlineServe bool
+	// 是否优先使用 protoc-gen-go-vtproto 生成的 MarshalVT/UnmarshalVT 方法
+	VTProto bool
+	// 是否使用 sync.Pool 复用请求和响应消息，适用于调用量非常大的服务
+	MessagePool bool
+	// 是否额外生成 {file}.routes.json 路由清单
+	Routes bool
+	// 是否额外为每个服务生成 docs/{Service}.md 接口文档
+	Docs bool
+	// 额外生成的网关配置类型，支持 envoy 和 nginx，默认不生成
+	Gateway string
+	// 网关配置中的 envoy cluster 或者 nginx upstream 名称
+	GatewayCluster string
+	// 是否额外生成 GraphQL schema 和 resolver
+	GraphQL bool
+	// 是否生成接口调试页面
+	Explorer bool
+	// 兼容模式，twitch 表示与官方 twirp 客户端、服务端协议完全兼容
+	Compat string
+	// 是否生成同时挂载到官方 twirp 服务的适配代码
+	Upstream bool
+	// 是否生成回放录制请求的契约测试
+	ContractTest bool
+	// 是否统计 JSON 请求中的未知字段
+	UnknownFields bool
+	// 请求体的最大字节数，为零表示不限制，可以通过 @maxbody 注解单独设置
+	MaxBodySize int64
+	// protobuf 请求是否必须带有 Content-Length 头
+	RequireContentLength bool
+	// 使用 gzip 压缩的最小响应字节数，为零表示不压缩响应，gzip 请求体总是会自动解压
+	GzipMinSize int64
+	// 分块输出的最小 protobuf 响应字节数，为零表示不分块，分块输出的响应通过 trailer 返回处理结果
+	ChunkMinSize int64
+	// 表单请求所有响应（包括错误）的 Content-Type，为空表示不修改，可以通过 @form_content_type 注解单独设置
+	FormContentType string
+	// 服务代码所在子包的名称后缀，为空表示与 message 生成在同一个包
+	APIPackage string
+	// 是否生成基于泛型的强类型构造函数，需要 Go 1.18
+	Generics bool
+	// 是否生成把服务挂载到 *grpc.Server 的适配代码（.grpc.go），需要依赖 google.golang.org/grpc
+	GRPC bool
+	// 是否同时生成 message 代码（.pb.go），不再需要单独调用 protoc-gen-go
+	Messages bool
+	// 生成结果缓存目录，为空表示不使用缓存
+	// proto 文件及其依赖、插件版本和参数都没有变化时直接输出上次的结果
+	CacheDir string
+	// 并发生成文件的数量，为零表示使用 CPU 核数
+	Workers int
+	// 返回 google.protobuf.Empty 的接口是否输出没有响应体的 204 响应
+	EmptyNoContent bool
+	// 服务端流式接口的响应格式，sse 表示输出 Server-Sent Events，默认为 NDJSON 或者长度前缀分帧
+	StreamTransport string
+	// 按生成文件设置的构建约束，如 explorer=tools，生产环境构建时不包含调试页面
+	BuildTags buildTags
+
+	// 当前文件在本次生成中的序号
 	filesHandled int
 
+	// 当前文件生成的所有文件，Generate 结束时统一输出
+	files []*generatedFile
+
 	// Map to record whether we've built each package
 	pkgs          map[string]string
 	pkgNamesInUse map[string]bool
-	deps          map[string]string
+	// 当前文件依赖的其他包，导入路径到包别名
+	deps map[protogen.GoImportPath]string
 
 	methodOptionRegexp *regexp.Regexp
 
@@ -94,8 +169,9 @@ func newGenerator() *twirp {
 	t := &twirp{
 		pkgs:          make(map[string]string),
 		pkgNamesInUse: make(map[string]bool),
-		deps:          make(map[string]string),
+		deps:          make(map[protogen.GoImportPath]string),
 		output:        bytes.NewBuffer(nil),
+		BuildTags:     buildTags{},
 	}
 
 	return t
@@ -106,38 +182,222 @@ func (t *twirp) Generate(plugin *protogen.Plugin) error {
 
 	t.methodOptionRegexp = regexp.MustCompile(t.OptionPrefix + `:([^:\s]+)`)
 
-	// Register names of packages that we import.
-	t.registerPackageName("bytes")
-	t.registerPackageName("strings")
-	t.registerPackageName("context")
-	t.registerPackageName("http")
-	t.registerPackageName("io")
-	t.registerPackageName("ioutil")
-	t.registerPackageName("json")
-	t.registerPackageName("jsonpb")
-	t.registerPackageName("proto")
-	t.registerPackageName("twirp")
-	t.registerPackageName("url")
-	t.registerPackageName("fmt")
-	t.registerPackageName("errors")
-	t.registerPackageName("strconv")
-	t.registerPackageName("ctxkit")
+	if t.Compat != "" && t.Compat != "twitch" {
+		return fmt.Errorf("unknown compat %q, only twitch is supported", t.Compat)
+	}
+	// 官方 twirp 客户端把 204 和 trailer 中的错误都当作异常响应
+	if t.Compat == "twitch" && t.EmptyNoContent {
+		return fmt.Errorf("empty_no_content is not supported by compat=twitch")
+	}
+	if t.Compat == "twitch" && t.ChunkMinSize > 0 {
+		return fmt.Errorf("chunk_min_size is not supported by compat=twitch")
+	}
+	if t.StreamTransport != "" && t.StreamTransport != "sse" {
+		return fmt.Errorf("unknown stream_transport %q, only sse is supported", t.StreamTransport)
+	}
+
+	// 生成的代码依赖 protoc-gen-go 生成的消息，支持的特性（proto3 optional、editions）
+	// 与 protoc-gen-go 保持一致，否则 protoc 会拒绝使用 editions 的 proto 文件
+	plugin.SupportedFeatures = gengo.SupportedFeatures
+	plugin.SupportedEditionsMinimum = gengo.SupportedEditionsMinimum
+	plugin.SupportedEditionsMaximum = gengo.SupportedEditionsMaximum
+
+	if t.ValidateAll {
+		t.ValidateEnable = true
+	}
 
+	// 需要生成代码的文件，下标用作 file descriptor 变量名的序号
+	// 开启 ValidateAll 时包含没有服务的文件，这些文件只生成 validate 代码
+	var files []*protogen.File
+	// 生成文件名前缀到 proto 文件的映射，go_package 配置错误时多个文件可能使用相同的前缀
+	prefixes := map[string]string{}
 	for _, f := range t.plugin.Files {
-		if len(f.Services) == 0 {
+		if f.Generate || len(f.Services) > 0 {
+			if src, ok := prefixes[f.GeneratedFilenamePrefix]; ok {
+				return fmt.Errorf("%s and %s generate files with the same prefix %s, check go_package", src, f.Desc.Path(), f.GeneratedFilenamePrefix)
+			}
+			prefixes[f.GeneratedFilenamePrefix] = f.Desc.Path()
+		}
+
+		if t.Messages && f.Generate {
+			gengo.GenerateFile(plugin, f)
+		}
+
+		if len(f.Services) == 0 && !(t.ValidateAll && f.Generate) {
 			continue
 		}
+		files = append(files, f)
+	}
+
+	workers := t.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	// 每个文件使用独立的生成器，互不影响，可以并发生成
+	results := make([][]*generatedFile, len(files))
+	errs := make([]error, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = t.fileGenerator(i).generateFile(files[i])
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// 按文件顺序返回第一个错误，保证错误信息稳定
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// 不同 proto 文件生成的文件重名时后者会覆盖前者，比如同一目录下的服务文档
+	owners := map[string]string{}
+	for i, generated := range results {
+		src := files[i].Desc.Path()
+		for _, f := range generated {
+			if owner, ok := owners[f.Name]; ok && owner != src {
+				return fmt.Errorf("%s and %s both generate %s", owner, src, f.Name)
+			}
+			owners[f.Name] = src
+		}
+	}
 
-		t.generate(f)
-		if t.ValidateEnable {
-			t.generateValidate(f)
+	// protogen.Plugin 不是并发安全的，按文件顺序统一输出
+	for _, files := range results {
+		for _, f := range files {
+			// 生成的代码不使用 protogen 的 import 管理，导入路径可以为空
+			gf := t.plugin.NewGeneratedFile(f.Name, "")
+			gf.Write(f.Content)
 		}
-		t.filesHandled++
 	}
 
 	return nil
 }
 
+// fileGenerator 返回生成第 index 个文件的生成器
+//
+// 生成参数与 t 相同，包名、依赖和输出缓冲区等状态每个文件单独一份。
+func (t *twirp) fileGenerator(index int) *twirp {
+	g := *t
+	g.pkgs = make(map[string]string)
+	g.pkgNamesInUse = make(map[string]bool)
+	g.deps = make(map[protogen.GoImportPath]string)
+	g.output = bytes.NewBuffer(nil)
+	g.files = nil
+	g.filesHandled = index
+
+	// Register names of packages that we import.
+	g.registerPackageName("bytes")
+	g.registerPackageName("strings")
+	g.registerPackageName("context")
+	g.registerPackageName("http")
+	g.registerPackageName("io")
+	g.registerPackageName("ioutil")
+	g.registerPackageName("json")
+	g.registerPackageName("protojson")
+	g.registerPackageName("proto")
+	g.registerPackageName("twirp")
+	g.registerPackageName("url")
+	g.registerPackageName("fmt")
+	g.registerPackageName("errors")
+	g.registerPackageName("strconv")
+	g.registerPackageName("ctxkit")
+	g.registerPackageName("sync")
+	g.registerPackageName("upstream")
+	g.registerPackageName("grpc")
+	g.registerPackageName("codes")
+	g.registerPackageName("status")
+
+	return &g
+}
+
+// generateFile 生成一个 proto 文件对应的所有文件，返回格式化之后的结果
+func (t *twirp) generateFile(f *protogen.File) (files []*generatedFile, err error) {
+	defer recoverError(f.Desc.Path(), &err)
+
+	var key string
+	if t.CacheDir != "" {
+		key = t.cacheKey(f)
+		if files, ok := t.loadCache(key); ok {
+			return files, nil
+		}
+	}
+
+	if len(f.Services) == 0 {
+		// 只有 message 的文件不生成服务代码，为了递归校验生成 validate 代码
+		t.generateValidate(f)
+		return t.finishFile(f, key)
+	}
+
+	t.checkStreaming(f)
+	t.checkLongPoll(f)
+	t.checkBatch(f)
+	t.checkWebhook(f)
+	t.checkHTTPRules(f)
+	t.collectDeps(f)
+	t.generate(f)
+	if t.Explorer && t.BuildTags[artifactExplorer] != "" {
+		t.generateExplorer(f)
+	}
+	if t.ValidateEnable {
+		t.generateValidate(f)
+	}
+	if t.Routes {
+		t.generateRoutes(f)
+	}
+	if t.Docs {
+		t.generateDocs(f)
+	}
+	if t.Gateway != "" {
+		t.generateGateway(f)
+	}
+	if t.GraphQL {
+		t.generateGraphQL(f)
+	}
+	if t.Upstream {
+		t.generateUpstream(f)
+	}
+	if t.ContractTest {
+		t.generateContractTest(f)
+	}
+	if t.Generics {
+		t.generateGenerics(f)
+	}
+	if t.GRPC {
+		t.generateGRPC(f)
+	}
+
+	return t.finishFile(f, key)
+}
+
+// finishFile 格式化生成的 Go 代码，key 不为空时写入缓存
+func (t *twirp) finishFile(f *protogen.File, key string) (files []*generatedFile, err error) {
+	for _, gf := range t.files {
+		if gf.goSource {
+			if gf.Content, err = t.formattedOutput(gf.Content); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", f.Desc.Path(), gf.Name, err)
+			}
+			gf.goSource = false
+		}
+	}
+
+	if key != "" {
+		t.saveCache(key, t.files)
+	}
+	return t.files, nil
+}
+
 func (t *twirp) registerPackageName(name string) (alias string) {
 	alias = name
 	i := 1
@@ -161,9 +421,9 @@ func (t *twirp) generate(file *protogen.File) {
 
 	t.generateFileDescriptor(file)
 
-	fname := file.GeneratedFilenamePrefix + ".twirp.go"
-	gf := t.plugin.NewGeneratedFile(fname, file.GoImportPath)
-	gf.Write(t.formattedOutput(t.output.Bytes()))
+	api := t.apiPackage(file)
+	fname := api.filenamePrefix + ".twirp.go"
+	t.writeFile(fname, t.output.Bytes(), true)
 	t.output.Reset()
 }
 
@@ -175,66 +435,125 @@ func (t *twirp) generateValidate(file *protogen.File) {
 	templates.Register(tpl)
 
 	buf := &bytes.Buffer{}
+	if c := t.buildConstraint(artifactValidate, ""); c != "" {
+		buf.WriteString(c + "\n")
+	}
 	if err := tpl.Execute(buf, file); err != nil {
-		panic(err)
+		fail("execute validate template: %v", err)
+	}
+	if t.APIPackage != "" {
+		buf.Write(t.generateValidateExports(file))
 	}
 
-	gf := t.plugin.NewGeneratedFile(fname, file.GoImportPath)
-	gf.Write(t.formattedOutput(buf.Bytes()))
+	t.writeFile(fname, buf.Bytes(), true)
 }
 
 func (t *twirp) generateFileHeader(file *protogen.File) {
-	t.P("// Package ", string(file.GoPackageName), " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
+	name := string(t.apiPackage(file).name)
+	t.P("// Package ", name, " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
 	t.P("// source: ", file.Desc.Path())
-	t.P(`package `, string(file.GoPackageName))
+	// 列出解析得到的依赖包，方便排查导入错误
+	if len(t.deps) > 0 {
+		t.P("//")
+		t.P("// imports:")
+		t.P("//")
+		for _, p := range t.sortedDeps() {
+			t.P("//	", t.deps[p], " ", string(p))
+		}
+	}
+	t.P(`package `, name)
 	t.P()
 }
 
 func (t *twirp) generateImports(file *protogen.File) {
-	t.P(`import `, t.pkgs["bytes"], ` "bytes"`)
 	t.P(`import `, t.pkgs["strings"], ` "strings"`)
 	t.P(`import `, t.pkgs["context"], ` "context"`)
 	t.P(`import `, t.pkgs["fmt"], ` "fmt"`)
 	t.P(`import `, t.pkgs["strconv"], ` "strconv"`)
-	t.P(`import `, t.pkgs["errors"], ` "errors"`)
-	t.P(`import `, t.pkgs["ioutil"], ` "io/ioutil"`)
+	t.P(`import `, t.pkgs["io"], ` "io"`)
 	t.P(`import `, t.pkgs["http"], ` "net/http"`)
+	if t.MessagePool {
+		t.P(`import `, t.pkgs["sync"], ` "sync"`)
+	}
 	t.P()
-	t.P(`import `, t.pkgs["jsonpb"], ` "github.com/golang/protobuf/jsonpb"`)
-	t.P(`import `, t.pkgs["proto"], ` "github.com/golang/protobuf/proto"`)
+	t.P(`import `, t.pkgs["protojson"], ` "google.golang.org/protobuf/encoding/protojson"`)
+	t.P(`import `, t.pkgs["proto"], ` "google.golang.org/protobuf/proto"`)
 	t.P(`import `, t.pkgs["ctxkit"], ` "sniper/util/ctxkit"`)
 	t.P(`import `, t.pkgs["twirp"], fmt.Sprintf(` "%s"`, t.TwirpPackage))
 	t.P()
 
+	t.generateDepImports()
+
+	t.P(`// If the request does not have any number filed, the strconv`)
+	t.P(`// is not needed. However, there is no easy way to drop it.`)
+	t.P(`var _ = `, t.pkgs["strconv"], `.IntSize`)
+	t.P(`var _ = `, t.pkgs["ctxkit"], `.GetUserID`)
+	t.P()
+}
+
+// collectDeps 记录接口方法用到的其他包中的 message
+func (t *twirp) collectDeps(file *protogen.File) {
 	// It's legal to import a message and use it as an input or output for a
 	// method. Make sure to import the package of any such message. First, dedupe
 	// them.
+	api := t.apiPackage(file)
+	names := map[protogen.GoImportPath]string{}
 	for _, s := range file.Services {
 		for _, m := range s.Methods {
 			defs := []*protogen.Message{m.Input, m.Output}
 			for _, def := range defs {
-				if def.GoIdent.GoImportPath == file.GoImportPath {
+				p := def.GoIdent.GoImportPath
+				if p == api.importPath {
+					continue
+				}
+				if _, ok := names[p]; ok {
 					continue
 				}
-				p := string(def.GoIdent.GoImportPath)
-				pkg := path.Base(p)
-				t.deps[pkg] = strconv.Quote(p)
 
+				name := path.Base(string(p))
+				if f, ok := t.plugin.FilesByPath[def.Desc.ParentFile().Path()]; ok {
+					name = string(f.GoPackageName)
+				}
+				names[p] = name
 			}
 		}
 	}
-	for pkg, importPath := range t.deps {
-		t.P(`import `, pkg, ` `, importPath)
+
+	// 按导入路径分配别名，别名不受方法顺序影响
+	paths := make([]protogen.GoImportPath, 0, len(names))
+	for p := range names {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	for _, p := range paths {
+		// 包名相同的依赖以及与运行库重名的依赖使用不同的别名
+		alias := names[p]
+		for i := 1; t.pkgNamesInUse[alias]; i++ {
+			alias = names[p] + strconv.Itoa(i)
+		}
+		t.pkgNamesInUse[alias] = true
+		t.deps[p] = alias
+	}
+}
+
+// sortedDeps 返回按导入路径排序的依赖包，保证相同输入的输出完全一致
+func (t *twirp) sortedDeps() []protogen.GoImportPath {
+	paths := make([]protogen.GoImportPath, 0, len(t.deps))
+	for p := range t.deps {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return paths
+}
+
+// generateDepImports 导入 collectDeps 记录的依赖包
+func (t *twirp) generateDepImports() {
+	for _, p := range t.sortedDeps() {
+		t.P(`import `, t.deps[p], ` `, strconv.Quote(string(p)))
 	}
 	if len(t.deps) > 0 {
 		t.P()
 	}
-
-	t.P(`// If the request does not have any number filed, the strconv`)
-	t.P(`// is not needed. However, there is no easy way to drop it.`)
-	t.P(`var _ = `, t.pkgs["strconv"], `.IntSize`)
-	t.P(`var _ = `, t.pkgs["ctxkit"], `.GetUserID`)
-	t.P()
 }
 
 // P forwards to g.gen.P, which prints output.
@@ -264,6 +583,10 @@ func (t *twirp) generateService(file *protogen.File, service *protogen.Service,
 	t.sectionComment(service.GoName + ` JSON Client`)
 	t.generateClient("JSON", file, service)
 
+	t.sectionComment(service.GoName + ` Codec Client`)
+	t.generateClient("Codec", file, service)
+	t.generateWebhookClient(file, service)
+
 	t.sectionComment(service.GoName + ` Server Handler`)
 	t.generateServer(file, service)
 }
@@ -273,28 +596,52 @@ func (t *twirp) generateTwirpInterface(file *protogen.File, service *protogen.Se
 	t.P(`type `, service.GoName, ` interface {`)
 	for _, method := range service.Methods {
 		t.printComments(method.Comments)
-		t.P(t.generateSignature(method))
+		t.P(t.generateSignature(service, method))
 		t.P()
 	}
 	t.P(`}`)
+
+	for _, method := range service.Methods {
+		funcType := methodFuncType(service, method)
+		t.P()
+		t.P(`// `, funcType, ` is the signature of `, service.GoName, `.`, method.GoName, `.`)
+		t.P(`// Implementations can be checked at build time with a method value:`)
+		t.P(`//`)
+		t.P(`//	var _ = `, funcType, `((&Server{}).`, method.GoName, `)`)
+		if isStreaming(method) {
+			params, results := t.streamSignature(service, method)
+			t.P(`type `, funcType, ` func(`, t.pkgs["context"], `.Context, `, params, `) `, results)
+		} else {
+			t.P(`type `, funcType, ` func(`, t.pkgs["context"], `.Context, *`, t.getType(method.Input), `) (*`, t.getType(method.Output), `, error)`)
+		}
+	}
+
+	t.generateStreamTypes(service)
+	t.generateLongPollTypes(service)
+	t.generateBatchTypes(service)
 }
 
-func (t *twirp) generateSignature(method *protogen.Method) string {
+func (t *twirp) generateSignature(service *protogen.Service, method *protogen.Method) string {
 	methName := method.GoName
 	inputType := t.getType(method.Input)
 	outputType := t.getType(method.Output)
+	if isStreaming(method) {
+		params, results := t.streamSignature(service, method)
+		return fmt.Sprintf(`	%s(%s.Context, %s) %s`, methName, t.pkgs["context"], params, results)
+	}
 	return fmt.Sprintf(`	%s(%s.Context, *%s) (*%s, error)`, methName, t.pkgs["context"], inputType, outputType)
 }
 
 func (t *twirp) getType(m *protogen.Message) string {
-	pkg := path.Base(string(m.GoIdent.GoImportPath))
-	if _, ok := t.deps[pkg]; ok {
-		return pkg + "." + m.GoIdent.GoName
+	if alias, ok := t.deps[m.GoIdent.GoImportPath]; ok {
+		return alias + "." + m.GoIdent.GoName
 	}
 	return m.GoIdent.GoName
 }
 
-// valid names: 'JSON', 'Protobuf'
+// valid names: 'JSON', 'Protobuf', 'Codec'
+//
+// Codec 客户端使用构造时传入的 twirp.Codec 编码请求
 func (t *twirp) generateClient(name string, file *protogen.File, service *protogen.Service) {
 	servName := service.GoName
 	pathPrefixConst := servName + "PathPrefix"
@@ -305,11 +652,19 @@ func (t *twirp) generateClient(name string, file *protogen.File, service *protog
 	t.P(`type `, structName, ` struct {`)
 	t.P(`  client `, t.pkgs["twirp"], `.HTTPClient`)
 	t.P(`  urls   [`, methCnt, `]string`)
+	if name == "Codec" {
+		t.P(`  codec  `, t.pkgs["twirp"], `.Codec`)
+	}
 	t.P(`}`)
 	t.P()
 	t.P(`// `, newClientFunc, ` creates a `, name, ` client that implements the `, servName, ` interface.`)
-	t.P(`// It communicates using `, name, ` and can be configured with a custom HTTPClient.`)
-	t.P(`func `, newClientFunc, `(addr string, client `, t.pkgs["twirp"], `.HTTPClient) `, servName, ` {`)
+	if name == "Codec" {
+		t.P(`// It communicates using the given codec and can be configured with a custom HTTPClient.`)
+		t.P(`func `, newClientFunc, `(addr string, client `, t.pkgs["twirp"], `.HTTPClient, codec `, t.pkgs["twirp"], `.Codec) `, servName, ` {`)
+	} else {
+		t.P(`// It communicates using `, name, ` and can be configured with a custom HTTPClient.`)
+		t.P(`func `, newClientFunc, `(addr string, client `, t.pkgs["twirp"], `.HTTPClient) `, servName, ` {`)
+	}
 	t.P(`  prefix := addr + `, pathPrefixConst)
 	t.P(`  urls := [`, methCnt, `]string{`)
 	for _, method := range service.Methods {
@@ -319,11 +674,23 @@ func (t *twirp) generateClient(name string, file *protogen.File, service *protog
 	t.P(`  return &`, structName, `{`)
 	t.P(`    client: client,`)
 	t.P(`    urls:   urls,`)
+	if name == "Codec" {
+		t.P(`    codec:  codec,`)
+	}
 	t.P(`  }`)
 	t.P(`}`)
 	t.P()
 
 	for i, method := range service.Methods {
+		if isServerStreaming(method) {
+			t.generateStreamClientMethod(name, structName, file, service, method, i)
+			continue
+		}
+		if isWebSocket(method) {
+			t.generateWebSocketClientMethod(name, structName, file, service, method, i)
+			continue
+		}
+
 		methName := method.GoName
 		inputType := t.getType(method.Input)
 		outputType := t.getType(method.Output)
@@ -332,14 +699,27 @@ func (t *twirp) generateClient(name string, file *protogen.File, service *protog
 		t.P(`  ctx = `, t.pkgs["twirp"], `.WithPackageName(ctx, "`, *file.Proto.Package, `")`)
 		t.P(`  ctx = `, t.pkgs["twirp"], `.WithServiceName(ctx, "`, servName, `")`)
 		t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
+		if _, ok := methodAnnotation(method, service, "priority"); ok {
+			t.P(`  ctx = `, t.pkgs["twirp"], `.WithDefaultPriority(ctx, `, t.pkgs["twirp"], `.`, t.priority(service, method), `)`)
+		}
+		t.P(`  ctx, cancel := `, t.pkgs["twirp"], `.ClientBudget(ctx)`)
+		t.P(`  defer cancel()`)
 		t.P(`  out := new(`, outputType, `)`)
-		t.P(`  err := `, t.pkgs["twirp"], `.Do`, name, `Request(ctx, c.client, c.urls[`, strconv.Itoa(i), `], in, out)`)
+		if name == "Codec" {
+			t.P(`  err := `, t.pkgs["twirp"], `.DoCodecRequest(ctx, c.client, c.codec, c.urls[`, strconv.Itoa(i), `], in, out)`)
+		} else {
+			t.P(`  err := `, t.pkgs["twirp"], `.Do`, name, `Request(ctx, c.client, c.urls[`, strconv.Itoa(i), `], in, out)`)
+		}
 		t.P(`  if err != nil {`)
 		t.P(`    return nil, err`)
 		t.P(`  }`)
 		t.P(`  return out, nil`)
 		t.P(`}`)
 		t.P()
+
+		if t.batchConcurrency(service, method) > 0 {
+			t.generateBatchClientMethod(name, structName, file, service, method, i)
+		}
 	}
 }
 
@@ -351,14 +731,36 @@ func (t *twirp) generateServer(file *protogen.File, service *protogen.Service) {
 	t.P(`type `, servStruct, ` struct {`)
 	t.P(`  `, servName)
 	t.P(`  hooks     *`, t.pkgs["twirp"], `.ServerHooks`)
+	t.P(`  canary     `, servName)
+	t.P(`  canaryRule *`, t.pkgs["twirp"], `.CanaryRule`)
 	t.P(`}`)
 	t.P()
 
 	// Constructor for server implementation
+	t.P(`// New`, servName, `Server creates a twirp server that serves svc.`)
 	t.P(`func New`, servName, `Server(svc `, servName, `, hooks *`, t.pkgs["twirp"], `.ServerHooks) `, t.pkgs["twirp"], `.Server {`)
+	t.P(`  if svc == nil {`)
+	t.P(`    panic("New`, servName, `Server: svc is nil")`)
+	t.P(`  }`)
+	t.P(`  return &`, servStruct, `{`)
+	t.P(`    `, servName, `: svc,`)
+	t.P(`    hooks: hooks,`)
+	t.P(`  }`)
+	t.P(`}`)
+	t.P()
+
+	// Constructor for server implementation with canary
+	t.P(`// New`, servName, `CanaryServer creates a server that routes requests matching rule to canary,`)
+	t.P(`// and the others to svc.`)
+	t.P(`func New`, servName, `CanaryServer(svc, canary `, servName, `, rule *`, t.pkgs["twirp"], `.CanaryRule, hooks *`, t.pkgs["twirp"], `.ServerHooks) `, t.pkgs["twirp"], `.Server {`)
+	t.P(`  if svc == nil || canary == nil {`)
+	t.P(`    panic("New`, servName, `CanaryServer: svc or canary is nil")`)
+	t.P(`  }`)
 	t.P(`  return &`, servStruct, `{`)
 	t.P(`    `, servName, `: svc,`)
 	t.P(`    hooks: hooks,`)
+	t.P(`    canary: canary,`)
+	t.P(`    canaryRule: rule,`)
 	t.P(`  }`)
 	t.P(`}`)
 	t.P()
@@ -381,24 +783,62 @@ func (t *twirp) generateServer(file *protogen.File, service *protogen.Service) {
 	t.P()
 
 	t.P(`func (s *`, servStruct, `) wrapErr(err error, msg string) error {`)
-	t.P(`	return errors.New(msg + ": " + err.Error())`)
+	t.P(`	return `, t.pkgs["fmt"], `.Errorf("%s: %w", msg, err)`)
 	t.P(`}`)
 
 	// Routing.
 	t.generateServerRouting(servStruct, file, service)
 
+	if t.MessagePool {
+		t.generateMessagePools(service)
+	}
+
+	if t.Explorer {
+		t.generateExplorerMethods(service)
+	}
+
 	// Methods.
 	for _, method := range service.Methods {
 		t.generateServerMethod(file, service, method)
 	}
 
 	t.generateServiceMetadataAccessors(file, service)
+	t.generateAssertions(service)
+}
+
+// generateAssertions 生成编译期检查，服务端和客户端没有实现接口时构建失败
+func (t *twirp) generateAssertions(service *protogen.Service) {
+	servName := service.GoName
+	t.P()
+	t.P(`// Compile-time assertions of the `, servName, ` server and clients.`)
+	t.P(`var (`)
+	t.P(`  _ `, t.pkgs["twirp"], `.Server = (*`, serviceStruct(service), `)(nil)`)
+	t.P(`  _ `, servName, ` = (*`, unexported(servName), `ProtobufClient)(nil)`)
+	t.P(`  _ `, servName, ` = (*`, unexported(servName), `JSONClient)(nil)`)
+	t.P(`  _ `, servName, ` = (*`, unexported(servName), `CodecClient)(nil)`)
+	if hasStreaming(service) {
+		t.P(`  _ `, servName, `StreamClient = (*`, unexported(servName), `ProtobufClient)(nil)`)
+		t.P(`  _ `, servName, `StreamClient = (*`, unexported(servName), `JSONClient)(nil)`)
+		t.P(`  _ `, servName, `StreamClient = (*`, unexported(servName), `CodecClient)(nil)`)
+	}
+	if t.hasBatchable(service) {
+		t.P(`  _ `, servName, `BatchClient = (*`, unexported(servName), `ProtobufClient)(nil)`)
+		t.P(`  _ `, servName, `BatchClient = (*`, unexported(servName), `JSONClient)(nil)`)
+		t.P(`  _ `, servName, `BatchClient = (*`, unexported(servName), `CodecClient)(nil)`)
+	}
+	if isWebhook(service) {
+		t.P(`  _ `, servName, ` = (*`, unexported(servName), `WebhookClient)(nil)`)
+	}
+	t.P(`)`)
 }
 
 // pathPrefix returns the base path for all methods handled by a particular
 // service. It includes a trailing slash. (for example
 // "/twitch.example.Haberdasher/").
 func (t *twirp) pathPrefix(service *protogen.Service) string {
+	if t.Compat == "twitch" {
+		return "/twirp/" + string(service.Desc.FullName()) + "/"
+	}
 	return "/" + string(service.Desc.FullName()) + "/"
 }
 
@@ -418,12 +858,64 @@ func (t *twirp) generateServerRouting(servStruct string, file *protogen.File, se
 	t.P(`const `, pathPrefixConst, ` = `, strconv.Quote(t.pathPrefix(service)))
 	t.P()
 
+	t.P(`// Paths of all methods of `, servName, `.`)
+	t.P(`const (`)
+	for _, method := range service.Methods {
+		t.P(`  `, methodPathConst(service, method), ` = `, strconv.Quote(t.pathFor(service, method)))
+		if t.batchConcurrency(service, method) > 0 {
+			t.P(`  `, batchPathConst(service, method), ` = `, strconv.Quote(t.pathFor(service, method)+"Batch"))
+		}
+	}
+	t.P(`)`)
+	t.P()
+	t.P(`// `, servName, `MethodNames contains names of all methods of `, servName, `.`)
+	t.P(`var `, servName, `MethodNames = []string{`)
+	for _, method := range service.Methods {
+		t.P(`  `, strconv.Quote(method.GoName), `,`)
+	}
+	t.P(`}`)
+	t.P()
+
+	t.P(`func init() {`)
+	for _, method := range service.Methods {
+		t.P(`  `, t.pkgs["twirp"], `.RegisterMethod(`, t.pkgs["twirp"], `.MethodInfo{`)
+		t.P(`    Service: `, strconv.Quote(string(service.Desc.FullName())), `,`)
+		t.P(`    Method:  `, strconv.Quote(method.GoName), `,`)
+		t.P(`    Path:    `, methodPathConst(service, method), `,`)
+		if docs := errorDocs(method, service); len(docs) > 0 {
+			t.P(`    Errors: []`, t.pkgs["twirp"], `.ErrorDoc{`)
+			for _, doc := range docs {
+				t.P(`      {Code: `, t.pkgs["twirp"], `.`, doc.Name, `, Comment: `, strconv.Quote(doc.Comment), `},`)
+			}
+			t.P(`    },`)
+		}
+		for _, a := range [][2]string{{"owner", "Owner"}, {"team", "Team"}, {"oncall", "Oncall"}} {
+			if v, ok := methodAnnotation(method, service, a[0]); ok && v != "" {
+				t.P(`    `, a[1], `: `, strconv.Quote(v), `,`)
+			}
+		}
+		t.P(`    Source:    `, strconv.Quote(file.Desc.Path()), `,`)
+		t.P(`    Generator: `, strconv.Quote(Version), `,`)
+		t.P(`  })`)
+	}
+	t.P(`}`)
+	t.P()
+
+	t.generateRESTRoutes(service)
+
 	t.P(`func (s *`, servStruct, `) ServeHTTP(resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
 	t.P(`  ctx := req.Context()`)
 	t.P(`  ctx = `, t.pkgs["twirp"], `.WithHttpRequest(ctx, req)`)
 	t.P(`  ctx = `, t.pkgs["twirp"], `.WithPackageName(ctx, "`, *file.Proto.Package, `")`)
 	t.P(`  ctx = `, t.pkgs["twirp"], `.WithServiceName(ctx, "`, servName, `")`)
 	t.P(`  ctx = `, t.pkgs["twirp"], `.WithResponseWriter(ctx, resp)`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithResponseHeader(ctx)`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithServerTiming(ctx, req)`)
+	if t.Compat == "twitch" {
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithTwitchCompat(ctx)`)
+	} else if _, ok := annotation(service.Comments.Leading, "problem"); ok {
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithProblemDetails(ctx)`)
+	}
 	t.P()
 	t.P(`  var err error`)
 	t.P(`  ctx, err = s.hooks.CallRequestReceived(ctx)`)
@@ -432,7 +924,66 @@ func (t *twirp) generateServerRouting(servStruct string, file *protogen.File, se
 	t.P(`    return`)
 	t.P(`  }`)
 	t.P()
-	t.P(`  if req.Method != "POST" && !`, t.pkgs["twirp"], `.AllowGET(ctx) {`)
+	if hasHTTPRules(service) {
+		// RESTful 请求改写为对应接口的 twirp 请求，之后的处理完全相同
+		t.P(`  ctx = `, t.pkgs["twirp"], `.RouteREST(ctx, req, `, restRoutesVar(service), `)`)
+		t.P()
+	}
+	if t.Explorer {
+		// 设置了构建约束时，不满足约束的构建中方法列表为空，不挂载调试页面
+		cond := ""
+		if t.BuildTags[artifactExplorer] != "" {
+			cond = `len(` + explorerMethodsVar(service) + `) > 0 && `
+		}
+		t.P(`  if `, cond, `req.URL.Path == `, strconv.Quote(t.explorerPath(service)), ` && `, t.pkgs["twirp"], `.ExplorerEnabled(ctx) {`)
+		t.P(`    `, t.pkgs["twirp"], `.ServeExplorer(resp, "`, string(service.Desc.FullName()), `", `, explorerMethodsVar(service), `)`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P()
+	}
+	if hasWebSocket(service) {
+		// WebSocket 使用 GET 请求握手，只有客户端流式和双向流式接口接受
+		t.P(`  if `, t.pkgs["twirp"], `.IsWebSocketUpgrade(req) {`)
+		t.P(`    switch req.URL.Path {`)
+		for _, method := range service.Methods {
+			if isWebSocket(method) {
+				t.P(`    case `, methodPathConst(service, method), `:`)
+				t.P(`      s.serve`, method.GoName, `(ctx, resp, req)`)
+				t.P(`      return`)
+			}
+		}
+		t.P(`    }`)
+		t.P(`  }`)
+		t.P()
+	}
+	if t.sse() && hasServerStreaming(service) {
+		// 浏览器的 EventSource 只能发送 GET 请求，参数按表单解析
+		t.P(`  if req.Method == `, t.pkgs["http"], `.MethodGet {`)
+		t.P(`    switch req.URL.Path {`)
+		for _, method := range service.Methods {
+			if isServerStreaming(method) {
+				t.P(`    case `, methodPathConst(service, method), `:`)
+				t.P(`      s.serve`, method.GoName, `(ctx, resp, req)`)
+				t.P(`      return`)
+			}
+		}
+		t.P(`    }`)
+		t.P(`  }`)
+		t.P()
+	}
+	// 接受 GET 请求的接口同时响应 HEAD 和 OPTIONS，探测请求不会产生 bad_route 错误
+	t.P(`  if (req.Method == `, t.pkgs["http"], `.MethodHead || req.Method == `, t.pkgs["http"], `.MethodOptions) && s.allowGET(ctx, req.URL.Path) {`)
+	t.P(`    if req.Method == `, t.pkgs["http"], `.MethodOptions {`)
+	t.P(`      `, t.pkgs["twirp"], `.WriteOptions(ctx, resp, s.hooks)`)
+	t.P(`      return`)
+	t.P(`    }`)
+	t.P(`    var finish func()`)
+	t.P(`    resp, finish = `, t.pkgs["twirp"], `.HeadResponse(resp, req)`)
+	t.P(`    defer finish()`)
+	t.P(`    ctx = `, t.pkgs["twirp"], `.WithResponseWriter(ctx, resp)`)
+	t.P(`  }`)
+	t.P()
+	t.P(`  if req.Method != `, t.pkgs["http"], `.MethodPost && !s.allowGET(ctx, req.URL.Path) {`)
 	t.P(`    msg := `, t.pkgs["fmt"], `.Sprintf("unsupported method %q (only POST is allowed)", req.Method)`)
 	t.P(`    err = s.badRouteError(msg, req.Method, req.URL.Path)`)
 	t.P(`    s.writeError(ctx, resp, err)`)
@@ -441,25 +992,53 @@ func (t *twirp) generateServerRouting(servStruct string, file *protogen.File, se
 	t.P()
 	t.P(`  switch req.URL.Path {`)
 	for _, method := range service.Methods {
-		path := t.pathFor(service, method)
 		methName := "serve" + method.GoName
-		t.P(`  case `, strconv.Quote(path), `:`)
+		t.P(`  case `, methodPathConst(service, method), `:`)
 		t.P(`    s.`, methName, `(ctx, resp, req)`)
-		t.P(`    return`)
+		if t.batchConcurrency(service, method) > 0 {
+			t.P(`  case `, batchPathConst(service, method), `:`)
+			t.P(`    s.`, methName, `Batch(ctx, resp, req)`)
+		}
 	}
 	t.P(`  default:`)
 	t.P(`    msg := `, t.pkgs["fmt"], `.Sprintf("no handler for path %q", req.URL.Path)`)
-	t.P(`    err = s.badRouteError(msg, req.Method, req.URL.Path)`)
-	t.P(`    s.writeError(ctx, resp, err)`)
-	t.P(`    return`)
+	t.P(`    s.writeError(ctx, resp, s.badRouteError(msg, req.Method, req.URL.Path))`)
 	t.P(`  }`)
 	t.P(`}`)
 	t.P()
+
+	var gets []string
+	for _, method := range service.Methods {
+		if _, ok := methodAnnotation(method, service, "get"); ok {
+			gets = append(gets, methodPathConst(service, method))
+		}
+	}
+	t.P(`// allowGET reports whether the method at path accepts GET requests, either by the @get`)
+	t.P(`// annotation or by hooks calling twirp.WithAllowGET.`)
+	t.P(`func (s *`, servStruct, `) allowGET(ctx `, t.pkgs["context"], `.Context, path string) bool {`)
+	if len(gets) > 0 {
+		t.P(`  switch path {`)
+		t.P(`  case `, strings.Join(gets, ", "), `:`)
+		t.P(`    return true`)
+		t.P(`  }`)
+	}
+	t.P(`  return `, t.pkgs["twirp"], `.AllowGET(ctx)`)
+	t.P(`}`)
+	t.P()
 }
 
 func (t *twirp) generateServerMethod(file *protogen.File, service *protogen.Service, method *protogen.Method) {
+	if isWebSocket(method) {
+		t.generateWebSocketServerMethod(service, method)
+		return
+	}
+
 	methName := method.GoName
 	servStruct := serviceStruct(service)
+	if ttl := t.cacheTTL(service, method); ttl > 0 {
+		t.P(`var `, responseCacheVar(service, method), ` = `, t.pkgs["twirp"], `.NewResponseCache[*`, t.getType(method.Output), `](`, methodPathConst(service, method), `, `, strconv.FormatInt(int64(ttl), 10), `) // `, ttl.String())
+		t.P()
+	}
 	t.P(`func (s *`, servStruct, `) serve`, methName, `(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
 	t.P(`  header := req.Header.Get("Content-Type")`)
 	t.P(`  i := strings.Index(header, ";")`)
@@ -472,140 +1051,515 @@ func (t *twirp) generateServerMethod(file *protogen.File, service *protogen.Serv
 		t.P(`  ctx = twirp.WithMethodOption(ctx, "`, matched[1], `")`)
 	}
 
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithRequestPriority(ctx, req, `, t.pkgs["twirp"], `.`, t.priority(service, method), `)`)
+
+	// 先路由再解析：RequestRouted 钩子和接口注解的检查在读取请求体之前执行，
+	// 降级、鉴权、限流拒绝的请求不需要解压和缓存请求体
+	t.P(`  var err error`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
+	t.P(`  ctx, err = s.hooks.CallRequestRouted(ctx)`)
+	t.P(`  if err != nil {`)
+	t.P(`    s.writeError(ctx, resp, err)`)
+	t.P(`    return`)
+	t.P(`  }`)
+	t.P()
+	t.generateMethodPrelude(service, method)
+	t.generateMethodGuard(service, method)
+
+	if ttl := t.dedupTTL(service, method); ttl > 0 {
+		pathConst := methodPathConst(service, method)
+		t.P(`  var replayed bool`)
+		t.P(`  if ctx, replayed = `, t.pkgs["twirp"], `.ReplayDedup(ctx, resp, `, pathConst, `); replayed {`)
+		t.P(`    s.hooks.CallResponseSent(ctx)`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P(`  var recorded func()`)
+		t.P(`  resp, recorded = `, t.pkgs["twirp"], `.RecordDedup(ctx, resp, `, pathConst, `, `, strconv.FormatInt(int64(ttl), 10), `) // `, ttl.String())
+		t.P(`  defer recorded()`)
+	}
+
+	// 先解压再限制大小，没有配置大小限制时解压之后的大小受 twirp.SetMaxGzipBodySize 限制，避免压缩炸弹
+	t.P(`  ctx, err = `, t.pkgs["twirp"], `.GzipRequest(ctx, req, `, strconv.FormatInt(t.GzipMinSize, 10), `)`)
+	t.P(`  if err != nil {`)
+	t.P(`    s.writeError(ctx, resp, err)`)
+	t.P(`    return`)
+	t.P(`  }`)
+
+	if max := t.maxBodySize(service, method); max > 0 {
+		t.P(`  if err := `, t.pkgs["twirp"], `.LimitRequestBody(req, `, strconv.FormatInt(max, 10), `); err != nil {`)
+		t.P(`    s.writeError(ctx, resp, err)`)
+		t.P(`    return`)
+		t.P(`  }`)
+	}
+
 	t.P(`  switch strings.TrimSpace(strings.ToLower(header[:i])) {`)
 	t.P(`  case "application/json":`)
 	t.P(`    s.serve`, methName, `JSON(ctx, resp, req)`)
 	t.P(`  case "application/protobuf":`)
+	if t.RequireContentLength {
+		t.P(`    if err := `, t.pkgs["twirp"], `.RequireContentLength(req); err != nil {`)
+		t.P(`      s.writeError(ctx, resp, err)`)
+		t.P(`      return`)
+		t.P(`    }`)
+	}
+	if t.ChunkMinSize > 0 && !isStreaming(method) {
+		// 只有 protobuf 请求分块输出，gRPC-Web 等需要完整的响应
+		t.P(`    ctx = `, t.pkgs["twirp"], `.WithChunkedResponse(ctx, `, strconv.FormatInt(t.ChunkMinSize, 10), `)`)
+	}
 	t.P(`    s.serve`, methName, `Protobuf(ctx, resp, req)`)
+	if !isStreaming(method) {
+		// 浏览器 gRPC-Web 客户端，解码之后按照 protobuf 请求处理
+		t.P(`  case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":`)
+		t.P(`    `, t.pkgs["twirp"], `.ServeGRPCWeb(ctx, resp, req, s.serve`, methName, `Protobuf)`)
+	}
 	t.P(`  default:`)
-	t.P(`    s.serve`, methName, `Form(ctx, resp, req)`)
+	// 其他 Content-Type 按照注册的 Codec 解析，保留参数以便区分 text/plain; format=prototext 等编码
+	t.P(`    if codec, ok := `, t.pkgs["twirp"], `.LookupCodec(header); ok {`)
+	t.P(`      s.serve`, methName, `Codec(ctx, resp, req, codec)`)
+	t.P(`      return`)
+	t.P(`    }`)
+	if t.Compat == "twitch" {
+		// 官方 twirp 不支持表单请求
+		t.P(`    msg := `, t.pkgs["fmt"], `.Sprintf("unexpected Content-Type: %q", req.Header.Get("Content-Type"))`)
+		t.P(`    s.writeError(ctx, resp, s.badRouteError(msg, req.Method, req.URL.Path))`)
+	} else {
+		t.P(`    s.serve`, methName, `Form(ctx, resp, req)`)
+	}
 	t.P(`  }`)
 	t.P(`}`)
 	t.P()
 	t.generateServerJSONMethod(service, method)
 	t.generateServerProtobufMethod(service, method)
-	t.generateServerFormMethod(service, method)
+	t.generateServerCodecMethod(service, method)
+	if t.Compat != "twitch" {
+		t.generateServerFormMethod(service, method)
+	}
+	if isServerStreaming(method) {
+		t.generateServerStreamHandleMethod(service, method)
+	} else if !t.InlineServe {
+		t.generateServerHandleMethod(service, method)
+	}
+	t.generateBatchServerMethod(service, method)
 }
 
-func (t *twirp) needLogin(method *protogen.Method, service *protogen.Service) bool {
-	return strings.Contains(string(method.Comments.Leading), "@auth\n") || strings.Contains(string(service.Comments.Leading), "@auth\n")
+// priority 返回 @priority 注解声明的接口优先级常量名，默认为 PriorityDefault
+func (t *twirp) priority(service *protogen.Service, method *protogen.Method) string {
+	v, ok := methodAnnotation(method, service, "priority")
+	if !ok {
+		return "PriorityDefault"
+	}
+
+	switch v {
+	case "critical":
+		return "PriorityCritical"
+	case "default":
+		return "PriorityDefault"
+	case "batch":
+		return "PriorityBatch"
+	}
+	fail("invalid @priority of %s: %q", t.pathFor(service, method), v)
+	return ""
 }
 
-func (t *twirp) generateServerJSONMethod(service *protogen.Service, method *protogen.Method) {
-	servStruct := serviceStruct(service)
-	methName := method.GoName
-	servName := service.GoName
-	t.P(`func (s *`, servStruct, `) serve`, methName, `JSON(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
-	t.P(`  var err error`)
-	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
-	t.P(`  ctx, err = s.hooks.CallRequestRouted(ctx)`)
-	t.P(`  if err != nil {`)
-	t.P(`    s.writeError(ctx, resp, err)`)
-	t.P(`    return`)
-	t.P(`  }`)
-	t.P()
-	t.P(`  reqContent := new(`, t.getType(method.Input), `)`)
-	t.P(`  unmarshaler := `, t.pkgs["jsonpb"], `.Unmarshaler{AllowUnknownFields: true}`)
-	t.P(`  if err = unmarshaler.Unmarshal(req.Body, reqContent); err != nil {`)
-	t.P(`    err = s.wrapErr(err, "failed to parse request json")`)
-	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.InvalidArgument, err.Error())`)
-	t.P(`    twerr = twerr.WithMeta("cause", `, t.pkgs["fmt"], `.Sprintf("%T", err))`)
-	t.P(`    s.writeError(ctx, resp, twerr)`)
-	t.P(`    return`)
-	t.P(`  }`)
-	t.P()
-	t.P(`  ctx = twirp.WithRequest(ctx, reqContent)`)
-	t.addValidate(method, service)
-	t.P(`  // Call service method`)
-	t.P(`  var respContent *`, t.getType(method.Output))
-	t.P(`  func() {`)
-	t.P(`    defer func() {`)
-	t.P(`      // In case of a panic, serve a 500 error and then panic.`)
-	t.P(`      if r := recover(); r != nil {`)
-	t.P(`        s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalError("Internal service panic"))`)
-	t.P(`        panic(r)`)
-	t.P(`      }`)
-	t.P(`    }()`)
-	t.P(`    respContent, err = s.`, servName, `.`, methName, `(ctx, reqContent)`)
-	t.P(`  }()`)
+// dedupTTL 返回 @dedup 注解声明的去重时间窗口，没有注解时返回 0
+func (t *twirp) dedupTTL(service *protogen.Service, method *protogen.Method) time.Duration {
+	v, ok := annotation(method.Comments.Leading, "dedup")
+	if !ok {
+		return 0
+	}
+	if v == "" {
+		return time.Minute
+	}
+
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		fail("invalid @dedup of %s: %q", t.pathFor(service, method), v)
+	}
+	return ttl
+}
+
+// cacheTTL 返回 @cache 注解声明的响应缓存时间，没有注解时返回 0
+func (t *twirp) cacheTTL(service *protogen.Service, method *protogen.Method) time.Duration {
+	v, ok := annotation(method.Comments.Leading, "cache")
+	if !ok {
+		return 0
+	}
+
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		fail("invalid @cache of %s: %q", t.pathFor(service, method), v)
+	}
+	return ttl
+}
+
+func responseCacheVar(service *protogen.Service, method *protogen.Method) string {
+	return serviceStruct(service) + method.GoName + "ResponseCache"
+}
+
+// maxBodySize 返回接口请求体的最大字节数，@maxbody 注解优先于 max_body_size 参数
+func (t *twirp) maxBodySize(service *protogen.Service, method *protogen.Method) int64 {
+	v, ok := methodAnnotation(method, service, "maxbody")
+	if !ok {
+		return t.MaxBodySize
+	}
+
+	max, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || max < 0 {
+		fail("invalid @maxbody of %s: %q", t.pathFor(service, method), v)
+	}
+	return max
+}
+
+// @strict 注解的取值
+const (
+	// strictNone 没有 @strict 注解，忽略未知字段
+	strictNone = ""
+	// strictAll @strict，所有请求都拒绝未知字段
+	strictAll = "all"
+	// strictExternal @strict:external，只有外部请求拒绝未知字段并返回所有校验错误
+	strictExternal = "external"
+)
+
+// strictMode 返回接口的 @strict 注解
+func (t *twirp) strictMode(service *protogen.Service, method *protogen.Method) string {
+	v, ok := methodAnnotation(method, service, "strict")
+	switch {
+	case !ok:
+		return strictNone
+	case v == "":
+		return strictAll
+	case v == strictExternal:
+		return strictExternal
+	}
+	fail("invalid @strict of %s: %q", t.pathFor(service, method), v)
+	return strictNone
+}
+
+type quota struct {
+	name  string
+	limit int64
+}
+
+// quotas 返回 @quota:name=limit 注解声明的配额
+func (t *twirp) quotas(service *protogen.Service, method *protogen.Method) (qs []quota) {
+	for _, v := range annotations(method.Comments.Leading, "quota") {
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			fail("invalid @quota of %s: %q", t.pathFor(service, method), v)
+		}
+
+		limit, err := strconv.ParseInt(strings.TrimSpace(v[i+1:]), 10, 64)
+		if err != nil || limit <= 0 {
+			fail("invalid @quota of %s: %q", t.pathFor(service, method), v)
+		}
+		qs = append(qs, quota{name: strings.TrimSpace(v[:i]), limit: limit})
+	}
+	return
+}
+
+// logBodyRate 返回 @logbody 注解声明的请求内容日志采样比例，没有注解返回 0
+func (t *twirp) logBodyRate(service *protogen.Service, method *protogen.Method) float64 {
+	v, ok := methodAnnotation(method, service, "logbody")
+	if !ok {
+		return 0
+	}
+
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		fail("invalid @logbody of %s: %q", t.pathFor(service, method), v)
+	}
+	return rate
+}
+
+// deprecatedFieldPaths 返回消息中所有 deprecated = true 的字段路径
+//
+// 只展开单个消息类型的字段，repeated、map 和 well-known types 不做展开
+func deprecatedFieldPaths(message *protogen.Message, prefix string, visited map[*protogen.Message]bool) (paths []string) {
+	if visited[message] {
+		return
+	}
+	visited[message] = true
+	defer delete(visited, message)
+
+	for _, field := range message.Fields {
+		path := prefix + string(field.Desc.Name())
+		if field.Desc.Options().(*descriptorpb.FieldOptions).GetDeprecated() {
+			paths = append(paths, path)
+		}
+		if field.Message == nil || field.Desc.IsList() || field.Desc.IsMap() ||
+			field.Message.Desc.FullName().Parent() == "google.protobuf" {
+			continue
+		}
+		paths = append(paths, deprecatedFieldPaths(field.Message, path+".", visited)...)
+	}
+	return
+}
+
+// generateBodyError 生成读取请求体出错时的处理逻辑
+//
+// 请求体超过大小限制或者内存记账超过限制时读取会返回 twirp.Error，需要原样返回给客户端
+func (t *twirp) generateBodyError(service *protogen.Service, method *protogen.Method) {
+	t.P(`    if twerr, ok := err.(`, t.pkgs["twirp"], `.Error); ok {`)
+	t.P(`      s.writeError(ctx, resp, twerr)`)
+	t.P(`      return`)
+	t.P(`    }`)
+}
+
+// generateMethodPrelude 生成路由之后、解析请求之前的逻辑，如功能开关、实验分桶等
+func (t *twirp) generateMethodPrelude(service *protogen.Service, method *protogen.Method) {
+	if _, ok := methodAnnotation(method, service, "internal"); ok {
+		t.P(`  if !`, t.pkgs["twirp"], `.InternalRequest(ctx) {`)
+		t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.PermissionDenied, "internal method"))`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P()
+	}
+
+	if _, ok := methodAnnotation(method, service, "tenant"); ok {
+		t.P(`  if `, t.pkgs["ctxkit"], `.GetTenantID(ctx) == "" {`)
+		t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.Unauthenticated, "tenant id is required"))`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P()
+	}
+
+	if v, ok := methodAnnotation(method, service, "origin"); ok && v != "" {
+		var origins []string
+		for _, o := range strings.Split(v, ",") {
+			origins = append(origins, strconv.Quote(strings.TrimSpace(o)))
+		}
+		t.P(`  if !`, t.pkgs["twirp"], `.OriginAllowed(ctx, `, strings.Join(origins, ", "), `) {`)
+		t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.PermissionDenied, "origin is not allowed"))`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P()
+	}
+
+	if flag, ok := methodAnnotation(method, service, "flag"); ok && flag != "" {
+		t.P(`  if !`, t.pkgs["twirp"], `.FlagEnabled(ctx, `, strconv.Quote(flag), `) {`)
+		t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.Unimplemented, `, strconv.Quote("feature "+flag+" is not enabled"), `))`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P()
+	}
+
+	if replacement, ok := t.deprecated(service, method); ok {
+		sunset := ""
+		if v, ok := methodAnnotation(method, service, "sunset"); ok {
+			date, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				fail("invalid @sunset %q of %s, should be like 2006-01-02", v, method.Desc.FullName())
+			}
+			// HTTP 日期格式，与 net/http.TimeFormat 相同
+			sunset = date.Format("Mon, 02 Jan 2006 15:04:05 GMT")
+		}
+		t.P(`  ctx = `, t.pkgs["twirp"], `.MarkDeprecated(ctx, resp, `, methodPathConst(service, method), `, `, strconv.Quote(replacement), `, `, strconv.Quote(sunset), `)`)
+		t.P()
+	}
+
+	exps := annotations(service.Comments.Leading, "experiment")
+	exps = append(exps, annotations(method.Comments.Leading, "experiment")...)
+	for _, exp := range exps {
+		if exp == "" {
+			continue
+		}
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithExperiment(ctx, `, strconv.Quote(exp), `)`)
+	}
+	if len(exps) > 0 {
+		t.P()
+	}
+
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "route")`)
+	t.P()
+}
+
+// generateMethodGuard 生成解析请求之前的登录和配额检查
+//
+// 拒绝的请求不需要读取和解析请求体，配额也不会被格式错误的请求消耗。
+func (t *twirp) generateMethodGuard(service *protogen.Service, method *protogen.Method) {
+	if t.ValidateEnable && t.needLogin(method, service) {
+		t.P(`  if ctxkit.GetUserID(ctx) == 0 {`)
+		t.P(`    s.writeError(ctx, resp, twirp.NewError(twirp.Unauthenticated, "need login"))`)
+		t.P(`    return`)
+		t.P(`  }`)
+	}
+	for _, q := range t.quotas(service, method) {
+		t.P(`  if err := `, t.pkgs["twirp"], `.CheckQuota(ctx, `, strconv.Quote(q.name), `, `, strconv.FormatInt(q.limit, 10), `); err != nil {`)
+		t.P(`    s.writeError(ctx, resp, err)`)
+		t.P(`    return`)
+		t.P(`  }`)
+	}
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "auth")`)
 	t.P()
+}
+
+// deprecated 判断接口是否废弃，返回 @deprecated 注解声明的替代接口
+//
+// 没有 @deprecated 注解但设置了 option deprecated = true 时替代接口为空。
+func (t *twirp) deprecated(service *protogen.Service, method *protogen.Method) (string, bool) {
+	if v, ok := methodAnnotation(method, service, "deprecated"); ok {
+		return strings.TrimSpace(strings.TrimPrefix(v, "use ")), true
+	}
+	return "", method.Desc.Options().(*descriptorpb.MethodOptions).GetDeprecated()
+}
+
+// isEmpty 判断消息是否为 google.protobuf.Empty，请求为 Empty 时不读取请求体
+func isEmpty(m *protogen.Message) bool {
+	return m.Desc.FullName() == "google.protobuf.Empty"
+}
+
+// hasRequired 判断消息及其嵌套消息中是否有 required 字段
+//
+// proto2 的 required 字段以及 editions 中 field_presence = LEGACY_REQUIRED 的字段
+// 都是 protoreflect.Required。
+func hasRequired(m *protogen.Message, visited map[*protogen.Message]bool) bool {
+	if visited[m] {
+		return false
+	}
+	visited[m] = true
+
+	for _, field := range m.Fields {
+		if field.Desc.Cardinality() == protoreflect.Required {
+			return true
+		}
+		if field.Message != nil && hasRequired(field.Message, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// noContent 判断接口是否输出 204 响应，需要开启 empty_no_content 参数
+func (t *twirp) noContent(method *protogen.Method) bool {
+	return t.EmptyNoContent && isEmpty(method.Output)
+}
+
+func (t *twirp) needLogin(method *protogen.Method, service *protogen.Service) bool {
+	return strings.Contains(string(method.Comments.Leading), "@auth\n") || strings.Contains(string(service.Comments.Leading), "@auth\n")
+}
+
+func (t *twirp) generateServerJSONMethod(service *protogen.Service, method *protogen.Method) {
+	t.generateServerMethodBegin(service, method, "JSON")
+	t.generateNewRequest(service, method)
+	if isEmpty(method.Input) {
+		t.P()
+		t.generateServerMethodEnd(service, method, "JSON")
+		return
+	}
+
+	// @strict 注解的接口拒绝未知字段，@strict:external 只对外部请求生效
+	strict := t.strictMode(service, method)
+	t.P(`  buf, err := `, t.pkgs["io"], `.ReadAll(req.Body)`)
 	t.P(`  if err != nil {`)
-	t.P(`    s.writeError(ctx, resp, err)`)
+	t.generateBodyError(service, method)
+	t.P(`    err = s.wrapErr(err, "failed to read request body")`)
+	t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
 	t.P(`    return`)
 	t.P(`  }`)
-	t.P(`  if respContent == nil {`)
-	t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalError("received a nil *`, t.getType(method.Output), ` and nil error while calling `, methName, `. nil responses are not supported"))`)
+	discardUnknown := strconv.FormatBool(strict == strictNone)
+	if strict == strictExternal {
+		t.P(`  strict := !`, t.pkgs["twirp"], `.InternalRequest(ctx)`)
+		discardUnknown = "!strict"
+	}
+	switch {
+	case t.UnknownFields && strict == strictNone:
+		t.P(`  if unknown := `, t.pkgs["twirp"], `.UnknownJSONFields(buf, reqContent); len(unknown) > 0 {`)
+		t.P(`    ctx = `, t.pkgs["twirp"], `.WithUnknownFields(ctx, unknown)`)
+		t.P(`  }`)
+	case t.UnknownFields && strict == strictExternal:
+		t.P(`  if unknown := `, t.pkgs["twirp"], `.UnknownJSONFields(buf, reqContent); !strict && len(unknown) > 0 {`)
+		t.P(`    ctx = `, t.pkgs["twirp"], `.WithUnknownFields(ctx, unknown)`)
+		t.P(`  }`)
+	}
+	t.P(`  unmarshaler := `, t.pkgs["protojson"], `.UnmarshalOptions{DiscardUnknown: `, discardUnknown, `, Resolver: `, t.pkgs["twirp"], `.AnyResolver}`)
+	t.P(`  if err = unmarshaler.Unmarshal(buf, reqContent); err != nil {`)
+	t.generateBodyError(service, method)
+	t.P(`    err = s.wrapErr(err, "failed to parse request json")`)
+	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.InvalidArgument, err.Error())`)
+	t.P(`    twerr = twerr.WithMeta("cause", `, t.pkgs["fmt"], `.Sprintf("%T", err))`)
+	t.P(`    s.writeError(ctx, resp, twerr)`)
 	t.P(`    return`)
 	t.P(`  }`)
 	t.P()
-	t.P(`  ctx = twirp.WithResponse(ctx, respContent)`)
-	t.P()
-	t.P(`  ctx = s.hooks.CallResponsePrepared(ctx)`)
-	t.P()
-	t.P(`  type httpBody interface {`)
-	t.P(`    GetContentType() string`)
-	t.P(`    GetData() []byte`)
-	t.P(`  }`)
-	t.P()
-	t.P(`  var respBytes []byte`)
-	t.P(`  var respStatus = `, t.pkgs["http"], `.StatusOK`)
-	t.P(`  if body, ok := interface{}(respContent).(httpBody); ok {`)
-	t.P(`    type httpStatus interface{ GetStatus() int32 }`)
-	t.P(`    if statusBody, ok := interface{}(respContent).(httpStatus); ok {`)
-	t.P(`      if status := statusBody.GetStatus(); status > 0 {`)
-	t.P(`        respStatus = int(status)`)
-	t.P(`      }`)
-	t.P(`    }`)
-	t.P(`    if contentType := body.GetContentType(); contentType != "" {`)
-	t.P(`      resp.Header().Set("Content-Type", contentType)`)
-	t.P(`    }`)
-	t.P(`    respBytes = body.GetData()`)
-	t.P(`  } else {`)
-	t.P(`    var buf `, t.pkgs["bytes"], `.Buffer`)
-	t.P(`    marshaler := &`, t.pkgs["jsonpb"], `.Marshaler{OrigName: true, EmitDefaults: true }`)
-	t.P(`    if err = marshaler.Marshal(&buf, respContent); err != nil {`)
-	t.P(`      err = s.wrapErr(err, "failed to marshal json response")`)
-	t.P(`      s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
-	t.P(`      return`)
-	t.P(`    }`)
-	t.P(`    respBytes = buf.Bytes()`)
-	t.P(`    resp.Header().Set("Content-Type", "application/json")`)
+	t.generateServerMethodEnd(service, method, "JSON")
+}
+
+func (t *twirp) generateServerProtobufMethod(service *protogen.Service, method *protogen.Method) {
+	t.generateServerMethodBegin(service, method, "Protobuf")
+	if isEmpty(method.Input) {
+		t.generateNewRequest(service, method)
+		t.P()
+		t.generateServerMethodEnd(service, method, "Protobuf")
+		return
+	}
+	t.P(`  buf, err := `, t.pkgs["io"], `.ReadAll(req.Body)`)
+	t.P(`  if err != nil {`)
+	t.generateBodyError(service, method)
+	t.P(`    err = s.wrapErr(err, "failed to read request body")`)
+	t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
+	t.P(`    return`)
 	t.P(`  }`)
-	t.P()
-	t.P(`  ctx = `, t.pkgs["twirp"], `.WithStatusCode(ctx, respStatus)`)
-	t.P(`  resp.WriteHeader(respStatus)`)
-	t.P()
-	t.P(`  if n, err := resp.Write(respBytes); err != nil {`)
-	t.P(`    msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())`)
-	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.Unknown, msg)`)
-	t.P(`    s.hooks.CallError(ctx, twerr)`)
+	t.generateNewRequest(service, method)
+	if t.VTProto {
+		t.P(`  if err = `, t.pkgs["twirp"], `.UnmarshalVT(buf, reqContent); err != nil {`)
+	} else {
+		t.P(`  if err = `, t.pkgs["proto"], `.Unmarshal(buf, reqContent); err != nil {`)
+	}
+	t.P(`    err = s.wrapErr(err, "failed to parse request proto")`)
+	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.InvalidArgument, err.Error())`)
+	t.P(`    twerr = twerr.WithMeta("cause", `, t.pkgs["fmt"], `.Sprintf("%T", err))`)
+	t.P(`    s.writeError(ctx, resp, twerr)`)
+	t.P(`    return`)
 	t.P(`  }`)
-	t.P(`  s.hooks.CallResponseSent(ctx)`)
-	t.P(`}`)
 	t.P()
+	t.generateServerMethodEnd(service, method, "Protobuf")
 }
 
-func (t *twirp) generateServerFormMethod(service *protogen.Service, method *protogen.Method) {
-	servStruct := serviceStruct(service)
-	methName := method.GoName
-	t.P(`func (s *`, servStruct, `) serve`, methName, `Form(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
-	t.P(`  var err error`)
-	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
-	t.P(`  ctx, err = s.hooks.CallRequestRouted(ctx)`)
+// generateServerCodecMethod 生成使用注册的 twirp.Codec 解析请求的 serve{Method}Codec 方法
+func (t *twirp) generateServerCodecMethod(service *protogen.Service, method *protogen.Method) {
+	t.generateServerMethodBegin(service, method, "Codec")
+	if isEmpty(method.Input) {
+		t.generateNewRequest(service, method)
+		t.P()
+		t.generateServerMethodEnd(service, method, "Codec")
+		return
+	}
+	t.P(`  buf, err := `, t.pkgs["io"], `.ReadAll(req.Body)`)
 	t.P(`  if err != nil {`)
-	t.P(`    s.writeError(ctx, resp, err)`)
+	t.generateBodyError(service, method)
+	t.P(`    err = s.wrapErr(err, "failed to read request body")`)
+	t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
+	t.P(`    return`)
+	t.P(`  }`)
+	t.generateNewRequest(service, method)
+	t.P(`  if err = codec.Unmarshal(buf, reqContent); err != nil {`)
+	t.P(`    err = s.wrapErr(err, "failed to parse request "+codec.Name())`)
+	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.InvalidArgument, err.Error())`)
+	t.P(`    twerr = twerr.WithMeta("cause", `, t.pkgs["fmt"], `.Sprintf("%T", err))`)
+	t.P(`    s.writeError(ctx, resp, twerr)`)
 	t.P(`    return`)
 	t.P(`  }`)
 	t.P()
+	t.generateServerMethodEnd(service, method, "Codec")
+}
+
+func (t *twirp) generateServerFormMethod(service *protogen.Service, method *protogen.Method) {
+	t.generateServerMethodBegin(service, method, "Form")
+	if isEmpty(method.Input) {
+		// 没有参数，不解析表单，请求体为空或者 Content-Type 不规范都不影响
+		t.generateNewRequest(service, method)
+		t.P()
+		t.generateServerMethodEnd(service, method, "JSON")
+		return
+	}
 	t.P(`  err = req.ParseForm()`)
 	t.P(`  if err != nil {`)
 	t.P(`    s.writeError(ctx, resp, err)`)
 	t.P(`    return`)
 	t.P(`  }`)
 	t.P()
-	t.P(`  reqContent := new(`, t.getType(method.Input), `)`)
+	t.generateNewRequest(service, method)
 	t.P()
-	t.addValidate(method, service)
 
 	for _, field := range method.Input.Fields {
 		ft, fs := getFieldType(field.Desc.Kind())
@@ -614,13 +1568,17 @@ func (t *twirp) generateServerFormMethod(service *protogen.Service, method *prot
 			continue
 		}
 
-		t.P(`  if v, ok := req.Form["`, string(field.Desc.Name()), `"]; ok {`)
+		if keys := formKeys(field); len(keys) == 1 {
+			t.P(`  if v, ok := req.Form[`, keys[0], `]; ok {`)
+		} else {
+			t.P(`  if v, ok := `, t.pkgs["twirp"], `.FormValues(req.Form, `, strings.Join(keys, ", "), `); ok {`)
+		}
 		if field.Desc.IsList() {
 			t.P(`    if len(v) == 1 {`)
 			t.P(`        v = strings.Split(v[0], ",")`)
 			t.P(`    }`)
 			if ft == "string" {
-				t.P(`    reqContent.`, field.GoName, ` = v `)
+				t.P(`    `, t.formAssign(field, "v"))
 			} else {
 				t.P(`    vs := make([]`, ft, fs, `, 0, len(v))`)
 				t.P(`    for _, vv := range(v) {`)
@@ -635,13 +1593,13 @@ func (t *twirp) generateServerFormMethod(service *protogen.Service, method *prot
 				t.P(`        s.writeError(ctx, resp, twirp.InvalidArgumentError("`, string(field.Desc.Name()), `", err.Error()))`)
 				t.P(`        return`)
 				t.P(`      }`)
-				t.P(`    vs = append(vs, `, ft, fs, `(vvv))`)
+				t.P(`    vs = append(vs, `, convert(ft, fs, "vvv"), `)`)
 				t.P(`    }`)
-				t.P(`    reqContent.`, field.GoName, ` = vs`)
+				t.P(`    `, t.formAssign(field, "vs"))
 			}
 		} else {
 			if ft == "string" {
-				t.P(`    reqContent.`, field.GoName, ` = v[0] `)
+				t.P(`    `, t.formAssign(field, "v[0]"))
 			} else {
 				if ft == "float" {
 					t.P(`    vv, err := strconv.ParseFloat(v[0], `, fs, `)`)
@@ -654,16 +1612,252 @@ func (t *twirp) generateServerFormMethod(service *protogen.Service, method *prot
 				t.P(`      s.writeError(ctx, resp, twirp.InvalidArgumentError("`, string(field.Desc.Name()), `", err.Error()))`)
 				t.P(`      return`)
 				t.P(`    }`)
-				t.P(`    reqContent.`, field.GoName, ` = `, ft, fs, `(vv)`)
+				t.P(`    `, t.formAssign(field, convert(ft, fs, "vv")))
 			}
 		}
 		t.P(`  }`)
 	}
-	t.P(`  ctx = twirp.WithRequest(ctx, reqContent)`)
 	t.P()
+	// JSON 和 Protobuf 反序列化时会检查 required 字段，表单请求需要单独检查
+	if hasRequired(method.Input, map[*protogen.Message]bool{}) {
+		t.P(`  if err := `, t.pkgs["proto"], `.CheckInitialized(reqContent); err != nil {`)
+		t.P(`    s.writeError(ctx, resp, twirp.NewError(twirp.InvalidArgument, err.Error()))`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P()
+	}
+	// 表单请求跟 JSON 请求使用相同的响应格式
+	t.generateServerMethodEnd(service, method, "JSON")
+}
+
+// formContentType 返回表单请求响应的 Content-Type，@form_content_type 注解优先于 form_content_type 参数
+func (t *twirp) formContentType(service *protogen.Service, method *protogen.Method) string {
+	if v, ok := methodAnnotation(method, service, "form_content_type"); ok {
+		if mediaType, _, err := mime.ParseMediaType(v); err != nil || !strings.Contains(mediaType, "/") {
+			fail("invalid @form_content_type of %s: %q", t.pathFor(service, method), v)
+		}
+		return v
+	}
+	return t.FormContentType
+}
+
+// requestPool 返回请求消息对象池的变量名
+func requestPool(service *protogen.Service, method *protogen.Method) string {
+	return serviceStruct(service) + method.GoName + "RequestPool"
+}
+
+// responsePool 返回响应消息对象池的变量名
+func responsePool(service *protogen.Service, method *protogen.Method) string {
+	return serviceStruct(service) + method.GoName + "ResponsePool"
+}
+
+// convert 返回把 strconv.ParseXxx 的结果转成字段类型的表达式
+//
+// ParseInt 等函数返回 64 位的结果，只有 32 位字段需要转换
+func convert(ft, fs, v string) string {
+	if fs != "32" {
+		return v
+	}
+	return ft + fs + "(" + v + ")"
+}
+
+// formAssign 返回把表单值 v 赋给请求字段的语句
+//
+// oneof 字段需要包装成对应的类型；有显式 presence 的标量字段是指针，包括 proto2
+// 和 proto3 的 optional 字段以及 editions 中默认的字段（field_presence = EXPLICIT）。
+func (t *twirp) formAssign(field *protogen.Field, v string) string {
+	switch {
+	case field.Desc.IsList():
+		return `reqContent.` + field.GoName + ` = ` + v
+	case field.Oneof == nil || field.Oneof.Desc.IsSynthetic():
+		if field.Desc.HasPresence() {
+			return `fv := ` + v + `; reqContent.` + field.GoName + ` = &fv`
+		}
+		return `reqContent.` + field.GoName + ` = ` + v
+	default:
+		wrapper := field.GoIdent.GoName
+		if alias, ok := t.deps[field.GoIdent.GoImportPath]; ok {
+			wrapper = alias + "." + wrapper
+		}
+		return `reqContent.` + field.Oneof.GoName + ` = &` + wrapper + `{` + field.GoName + `: ` + v + `}`
+	}
+}
+
+// formKeys 返回表单字段可以使用的参数名，已经转义成 Go 字符串
+//
+// 字段原名优先，其次是 camelCase 名，如 user_id 和 userId，最后是 @alias 声明的别名
+func formKeys(field *protogen.Field) []string {
+	names := []string{string(field.Desc.Name())}
+	if name := field.Desc.JSONName(); name != names[0] {
+		names = append(names, name)
+	}
+	for _, v := range annotations(field.Comments.Leading, "alias") {
+		for _, alias := range strings.Split(v, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				names = append(names, alias)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			keys = append(keys, strconv.Quote(name))
+		}
+	}
+	return keys
+}
+
+// generateMessagePools 生成每个接口的请求、响应对象池以及获取响应对象的函数
+func (t *twirp) generateMessagePools(service *protogen.Service) {
+	for _, method := range service.Methods {
+		inputType := t.getType(method.Input)
+		outputType := t.getType(method.Output)
+		getFunc := "Get" + service.GoName + method.GoName + "Response"
+
+		// 流式接口的请求和响应由 twirp 运行库或者业务方法逐条创建，不使用对象池
+		if isWebSocket(method) {
+			continue
+		}
+		t.P(`var `, requestPool(service, method), ` = `, t.pkgs["sync"], `.Pool{`)
+		t.P(`  New: func() interface{} { return new(`, inputType, `) },`)
+		t.P(`}`)
+		t.P()
+		if isServerStreaming(method) {
+			continue
+		}
+		t.P(`var `, responsePool(service, method), ` = `, t.pkgs["sync"], `.Pool{`)
+		t.P(`  New: func() interface{} { return new(`, outputType, `) },`)
+		t.P(`}`)
+		t.P()
+		t.P(`// `, getFunc, ` returns a *`, outputType, ` from the pool of `, method.GoName, `.`)
+		t.P(`// It is reset and put back to the pool after the response is written,`)
+		t.P(`// so the caller must not retain it after `, method.GoName, ` returns.`)
+		t.P(`func `, getFunc, `() *`, outputType, ` {`)
+		t.P(`  return `, responsePool(service, method), `.Get().(*`, outputType, `)`)
+		t.P(`}`)
+		t.P()
+	}
+}
+
+// generateNewRequest 生成创建请求消息的代码
+//
+// 开启 message_pool 后从对象池获取，并在方法返回时重置放回
+func (t *twirp) generateNewRequest(service *protogen.Service, method *protogen.Method) {
+	inputType := t.getType(method.Input)
+	if !t.MessagePool {
+		t.P(`  reqContent := new(`, inputType, `)`)
+		return
+	}
+
+	pool := requestPool(service, method)
+	t.P(`  reqContent := `, pool, `.Get().(*`, inputType, `)`)
+	t.P(`  defer func() {`)
+	t.P(`    reqContent.Reset()`)
+	t.P(`    `, pool, `.Put(reqContent)`)
+	t.P(`  }()`)
+}
+
+// generateReleaseResponse 生成响应输出之后重置并回收响应消息的代码
+//
+// 请求和响应类型相同时，业务方法可能直接返回请求对象，
+// 此时不能再放入响应对象池，否则同一个对象会被两个请求同时使用
+func (t *twirp) generateReleaseResponse(service *protogen.Service, method *protogen.Method) {
+	// @cache 接口的响应保存在缓存中，不能放回对象池
+	if !t.MessagePool || t.cacheTTL(service, method) > 0 {
+		return
+	}
 
 	t.P()
-	t.P(`  // Call service method`)
+	if method.Input.GoIdent == method.Output.GoIdent {
+		t.P(`  if respContent == reqContent {`)
+		t.P(`    return`)
+		t.P(`  }`)
+	}
+	t.P(`  respContent.Reset()`)
+	t.P(`  `, responsePool(service, method), `.Put(respContent)`)
+}
+
+// generateServerMethodBegin 生成 serve{Method}{Codec} 方法的开头部分，
+// 包括方法定义以及解密请求等解析请求之前的逻辑，RequestRouted 钩子已经在 serve{Method} 中调用
+func (t *twirp) generateServerMethodBegin(service *protogen.Service, method *protogen.Method, codec string) {
+	servStruct := serviceStruct(service)
+	methName := method.GoName
+	if codec == "Codec" {
+		t.P(`func (s *`, servStruct, `) serve`, methName, codec, `(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request, codec `, t.pkgs["twirp"], `.Codec) {`)
+	} else {
+		t.P(`func (s *`, servStruct, `) serve`, methName, codec, `(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
+	}
+	t.P(`  var err error`)
+	if contentType := t.formContentType(service, method); codec == "Form" && contentType != "" {
+		// 解密和解析表单的错误也使用相同的 Content-Type
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithResponseContentType(ctx, `, strconv.Quote(contentType), `)`)
+	}
+	if _, ok := methodAnnotation(method, service, "encrypted"); ok {
+		t.P(`  ctx, err = `, t.pkgs["twirp"], `.DecryptRequest(ctx, req)`)
+		t.P(`  if err != nil {`)
+		t.P(`    s.writeError(ctx, resp, err)`)
+		t.P(`    return`)
+		t.P(`  }`)
+		t.P()
+	}
+}
+
+// generateServerMethodEnd 生成 serve{Method}{Codec} 方法解析请求之后的部分
+//
+// 默认调用公共的 handle{Method} 方法，由 twirp 运行库负责输出响应；
+// 开启 inline_serve 后则把全部逻辑内联到当前方法，codec 决定响应的编码格式。
+func (t *twirp) generateServerMethodEnd(service *protogen.Service, method *protogen.Method, codec string) {
+	marshal := t.pkgs["twirp"] + ".Marshal" + codec
+	if codec == "Protobuf" && t.VTProto {
+		marshal = t.pkgs["twirp"] + ".MarshalVTProtobuf"
+	} else if codec == "Codec" {
+		marshal = t.pkgs["twirp"] + ".CodecMarshal(codec)"
+	}
+	// 流式接口的响应由 twirp.ServerStream 输出，不内联
+	if !t.InlineServe || isServerStreaming(method) {
+		t.P(`  s.handle`, method.GoName, `(ctx, resp, reqContent, `, marshal, `)`)
+		t.P(`}`)
+		t.P()
+		return
+	}
+
+	t.generateServerMethodCall(service, method)
+	if t.noContent(method) {
+		t.generateInlineWriteNoContent()
+	} else if codec == "Codec" || codec == "Protobuf" && t.ChunkMinSize > 0 {
+		// 注册的 Codec 以及分块输出的 protobuf 响应由运行库负责编码和输出
+		t.P(`  `, t.pkgs["twirp"], `.WriteResponse(ctx, resp, s.hooks, respContent, `, marshal, `)`)
+	} else {
+		t.generateInlineWriteResponse(codec)
+	}
+	t.generateReleaseResponse(service, method)
+	t.P(`}`)
+	t.P()
+}
+
+// generateServerHandleMethod 生成各编码格式共用的 handle{Method} 方法
+func (t *twirp) generateServerHandleMethod(service *protogen.Service, method *protogen.Method) {
+	servStruct := serviceStruct(service)
+	t.P(`func (s *`, servStruct, `) handle`, method.GoName, `(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, reqContent *`, t.getType(method.Input), `, marshal `, t.pkgs["twirp"], `.MarshalFunc) {`)
+	t.P(`  var err error`)
+	t.generateServerMethodCall(service, method)
+	if t.noContent(method) {
+		t.P(`  `, t.pkgs["twirp"], `.WriteNoContent(ctx, resp, s.hooks, respContent)`)
+	} else {
+		t.P(`  `, t.pkgs["twirp"], `.WriteResponse(ctx, resp, s.hooks, respContent, marshal)`)
+	}
+	t.generateReleaseResponse(service, method)
+	t.P(`}`)
+	t.P()
+}
+
+// generateServerMethodCall 生成请求解析之后的校验和业务方法调用逻辑
+func (t *twirp) generateServerMethodCall(service *protogen.Service, method *protogen.Method) {
+	methName := method.GoName
+	t.generateServerMethodPrepare(service, method)
 	t.P(`  var respContent *`, t.getType(method.Output))
 	t.P(`  func() {`)
 	t.P(`    defer func() {`)
@@ -673,18 +1867,104 @@ func (t *twirp) generateServerFormMethod(service *protogen.Service, method *prot
 	t.P(`        panic(r)`)
 	t.P(`      }`)
 	t.P(`    }()`)
-	t.P(`    respContent, err = s.`, methName, `(ctx, reqContent)`)
+	t.generateLongPollWait(service, method)
+	if t.cacheTTL(service, method) > 0 {
+		t.P(`    respContent, err = `, t.pkgs["twirp"], `.CachedCall(ctx, `, responseCacheVar(service, method), `, `, methodPathConst(service, method), `, reqContent, func(ctx `, t.pkgs["context"], `.Context) (*`, t.getType(method.Output), `, error) {`)
+		t.P(`      return impl.`, methName, `(ctx, reqContent)`)
+		t.P(`    })`)
+	} else {
+		t.P(`    respContent, err = impl.`, methName, `(ctx, reqContent)`)
+	}
 	t.P(`  }()`)
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "handler")`)
 	t.P()
+	for _, target := range annotations(method.Comments.Leading, "shadow") {
+		if target == "" {
+			continue
+		}
+		t.P(`  `, t.pkgs["twirp"], `.Shadow(ctx, `, strconv.Quote(target), `, reqContent, respContent, err)`)
+	}
+	if t.logBodyRate(service, method) > 0 {
+		t.P(`  if err == nil && respContent != nil {`)
+		t.P(`    `, t.pkgs["twirp"], `.SetLogResponseBody(ctx, respContent)`)
+		t.P(`  }`)
+	}
+	if len(errorDocs(method, service)) > 0 {
+		t.P(`  err = `, t.pkgs["twirp"], `.CheckErrorCode(`, methodPathConst(service, method), `, err)`)
+	}
 	t.P(`  if err != nil {`)
 	t.P(`    s.writeError(ctx, resp, err)`)
 	t.P(`    return`)
 	t.P(`  }`)
 	t.P(`  if respContent == nil {`)
-	t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalError("received a nil *`, t.getType(method.Output), ` and nil error while calling `, methName, `. nil responses are not supported"))`)
-	t.P(`    return`)
+	if isEmpty(method.Output) {
+		// Empty 没有内容，允许业务方法直接返回 nil
+		t.P(`    respContent = new(`, t.getType(method.Output), `)`)
+	} else {
+		t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalError("received a nil *`, t.getType(method.Output), ` and nil error while calling `, methName, `. nil responses are not supported"))`)
+		t.P(`    return`)
+	}
+	t.P(`  }`)
+	t.P()
+}
+
+// generateServerMethodPrepare 生成调用业务方法之前的校验和灰度逻辑，之后 impl 为调用的服务实现
+func (t *twirp) generateServerMethodPrepare(service *protogen.Service, method *protogen.Method) {
+	if len(httpRules(method)) > 0 {
+		t.P(`  if err = `, t.pkgs["twirp"], `.BindPathParams(ctx, reqContent); err != nil {`)
+		t.P(`    s.writeError(ctx, resp, err)`)
+		t.P(`    return`)
+		t.P(`  }`)
+	}
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithRequest(ctx, reqContent)`)
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "decode")`)
+	logBodyRate := t.logBodyRate(service, method)
+	if logBodyRate > 0 {
+		t.P(`  ctx = `, t.pkgs["twirp"], `.SampleLogBody(ctx, `, strconv.FormatFloat(logBodyRate, 'g', -1, 64), `, reqContent)`)
+	}
+	if paths := deprecatedFieldPaths(method.Input, "", map[*protogen.Message]bool{}); len(paths) > 0 {
+		quoted := make([]string, len(paths))
+		for i, p := range paths {
+			quoted[i] = strconv.Quote(p)
+		}
+		t.P(`  if fields := `, t.pkgs["twirp"], `.FindDeprecatedFields(reqContent, `, strings.Join(quoted, ", "), `); len(fields) > 0 {`)
+		t.P(`    ctx = `, t.pkgs["twirp"], `.WithDeprecatedFields(ctx, fields)`)
+		t.P(`  }`)
+	}
+	t.addValidate(method, service)
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "validate")`)
+	t.P(`  // Call service method`)
+	t.generateCanary(service, method)
+}
+
+// generateCanary 生成选择业务实现的代码，命中灰度规则时使用 canary 实现，结果记录在 ctx 中
+func (t *twirp) generateCanary(service *protogen.Service, method *protogen.Method) {
+	servName := service.GoName
+	t.P(`  var impl `, servName, ` = s.`, servName)
+	t.P(`  if s.canary != nil {`)
+	t.P(`    canary := s.canaryRule.Hit(ctx)`)
+	t.P(`    if canary {`)
+	t.P(`      impl = s.canary`)
+	t.P(`    }`)
+	t.P(`    ctx = `, t.pkgs["twirp"], `.WithCanary(ctx, canary)`)
 	t.P(`  }`)
+}
+
+// generateInlineWriteNoContent 生成内联的 204 响应输出逻辑，跟 twirp.WriteNoContent 保持一致
+func (t *twirp) generateInlineWriteNoContent() {
+	t.P(`  ctx = twirp.WithResponse(ctx, respContent)`)
+	t.P()
+	t.P(`  ctx = s.hooks.CallResponsePrepared(ctx)`)
 	t.P()
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "marshal")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithStatusCode(ctx, `, t.pkgs["http"], `.StatusNoContent)`)
+	t.P(`  `, t.pkgs["twirp"], `.WriteResponseHeader(ctx, resp)`)
+	t.P(`  resp.WriteHeader(`, t.pkgs["http"], `.StatusNoContent)`)
+	t.P(`  s.hooks.CallResponseSent(ctx)`)
+}
+
+// generateInlineWriteResponse 生成内联的响应输出逻辑，跟 twirp.WriteResponse 保持一致
+func (t *twirp) generateInlineWriteResponse(codec string) {
 	t.P(`  ctx = twirp.WithResponse(ctx, respContent)`)
 	t.P()
 	t.P(`  ctx = s.hooks.CallResponsePrepared(ctx)`)
@@ -708,124 +1988,53 @@ func (t *twirp) generateServerFormMethod(service *protogen.Service, method *prot
 	t.P(`    }`)
 	t.P(`    respBytes = body.GetData()`)
 	t.P(`  } else {`)
-	t.P(`    var buf `, t.pkgs["bytes"], `.Buffer`)
-	t.P(`    marshaler := &`, t.pkgs["jsonpb"], `.Marshaler{OrigName: true, EmitDefaults: true }`)
-	t.P(`    if err = marshaler.Marshal(&buf, respContent); err != nil {`)
-	t.P(`      err = s.wrapErr(err, "failed to marshal json response")`)
-	t.P(`      s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
-	t.P(`      return`)
-	t.P(`    }`)
-	t.P(`    respBytes = buf.Bytes()`)
-	t.P(`    resp.Header().Set("Content-Type", "application/json")`)
-	t.P(`  }`)
-	t.P()
-	t.P(`  ctx = `, t.pkgs["twirp"], `.WithStatusCode(ctx, respStatus)`)
-	t.P(`  resp.WriteHeader(respStatus)`)
-	t.P()
-	t.P(`  if n, err := resp.Write(respBytes); err != nil {`)
-	t.P(`    msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())`)
-	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.Unknown, msg)`)
-	t.P(`    s.hooks.CallError(ctx, twerr)`)
+	if codec == "Protobuf" {
+		if t.VTProto {
+			t.P(`    respBytes, err = `, t.pkgs["twirp"], `.MarshalVT(respContent)`)
+		} else {
+			t.P(`    respBytes, err = `, t.pkgs["proto"], `.Marshal(respContent)`)
+		}
+		t.P(`    if err != nil {`)
+		t.P(`      err = s.wrapErr(err, "failed to marshal proto response")`)
+		t.P(`      s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
+		t.P(`      return`)
+		t.P(`    }`)
+		t.P(`    resp.Header().Set("Content-Type", "application/protobuf")`)
+	} else {
+		t.P(`    marshaler := `, t.pkgs["protojson"], `.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true, Resolver: `, t.pkgs["twirp"], `.AnyResolver}`)
+		t.P(`    respBytes, err = marshaler.Marshal(respContent)`)
+		t.P(`    if err != nil {`)
+		t.P(`      err = s.wrapErr(err, "failed to marshal json response")`)
+		t.P(`      s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
+		t.P(`      return`)
+		t.P(`    }`)
+		t.P(`    resp.Header().Set("Content-Type", "application/json")`)
+	}
 	t.P(`  }`)
-	t.P(`  s.hooks.CallResponseSent(ctx)`)
-	t.P(`}`)
 	t.P()
-}
-
-func (t *twirp) generateServerProtobufMethod(service *protogen.Service, method *protogen.Method) {
-	servStruct := serviceStruct(service)
-	methName := method.GoName
-	servName := service.GoName
-	t.P(`func (s *`, servStruct, `) serve`, methName, `Protobuf(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
-	t.P(`  var err error`)
-	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
-	t.P(`  ctx, err = s.hooks.CallRequestRouted(ctx)`)
-	t.P(`  if err != nil {`)
+	t.P(`  if err = `, t.pkgs["twirp"], `.ChargeMemory(ctx, int64(len(respBytes))); err != nil {`)
 	t.P(`    s.writeError(ctx, resp, err)`)
 	t.P(`    return`)
 	t.P(`  }`)
 	t.P()
-	t.P(`  buf, err := `, t.pkgs["ioutil"], `.ReadAll(req.Body)`)
+	t.P(`  respBytes = `, t.pkgs["twirp"], `.CompressResponse(ctx, resp, respBytes)`)
+	t.P(`  respBytes, err = `, t.pkgs["twirp"], `.EncryptResponse(ctx, respBytes)`)
 	t.P(`  if err != nil {`)
-	t.P(`    err = s.wrapErr(err, "failed to read request body")`)
 	t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
 	t.P(`    return`)
 	t.P(`  }`)
-	t.P(`  reqContent := new(`, t.getType(method.Input), `)`)
-	t.P(`  if err = `, t.pkgs["proto"], `.Unmarshal(buf, reqContent); err != nil {`)
-	t.P(`    err = s.wrapErr(err, "failed to parse request proto")`)
-	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.InvalidArgument, err.Error())`)
-	t.P(`    twerr = twerr.WithMeta("cause", `, t.pkgs["fmt"], `.Sprintf("%T", err))`)
-	t.P(`    s.writeError(ctx, resp, twerr)`)
-	t.P(`    return`)
-	t.P(`  }`)
-	t.P()
-	t.P(`  ctx = twirp.WithRequest(ctx, reqContent)`)
-	t.addValidate(method, service)
-	t.P(`  // Call service method`)
-	t.P(`  var respContent *`, t.getType(method.Output))
-	t.P(`  func() {`)
-	t.P(`    defer func() {`)
-	t.P(`      // In case of a panic, serve a 500 error and then panic.`)
-	t.P(`      if r := recover(); r != nil {`)
-	t.P(`        s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalError("Internal service panic"))`)
-	t.P(`        panic(r)`)
-	t.P(`      }`)
-	t.P(`    }()`)
-	t.P(`    respContent, err = s.`, servName, `.`, methName, `(ctx, reqContent)`)
-	t.P(`  }()`)
-	t.P()
-	t.P(`  if err != nil {`)
-	t.P(`    s.writeError(ctx, resp, err)`)
-	t.P(`    return`)
-	t.P(`  }`)
-	t.P(`  if respContent == nil {`)
-	t.P(`    s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalError("received a nil *`, t.getType(method.Output), ` and nil error while calling `, methName, `. nil responses are not supported"))`)
-	t.P(`    return`)
-	t.P(`  }`)
-	t.P()
-	t.P(`  ctx = twirp.WithResponse(ctx, respContent)`)
-	t.P()
-	t.P(`  ctx = s.hooks.CallResponsePrepared(ctx)`)
-	t.P()
-	t.P(`  type httpBody interface {`)
-	t.P(`    GetContentType() string`)
-	t.P(`    GetData() []byte`)
-	t.P(`  }`)
-	t.P()
-	t.P(`  var respBytes []byte`)
-	t.P(`  var respStatus = `, t.pkgs["http"], `.StatusOK`)
-	t.P(`  if body, ok := interface{}(respContent).(httpBody); ok {`)
-	t.P(`    type httpStatus interface{ GetStatus() int32 }`)
-	t.P(`    if statusBody, ok := interface{}(respContent).(httpStatus); ok {`)
-	t.P(`      if status := statusBody.GetStatus(); status > 0 {`)
-	t.P(`        respStatus = int(status)`)
-	t.P(`      }`)
-	t.P(`    }`)
-	t.P(`    if contentType := body.GetContentType(); contentType != "" {`)
-	t.P(`      resp.Header().Set("Content-Type", contentType)`)
-	t.P(`    }`)
-	t.P(`    respBytes = body.GetData()`)
-	t.P(`  } else {`)
-	t.P(`    respBytes, err = `, t.pkgs["proto"], `.Marshal(respContent)`)
-	t.P(`    if err != nil {`)
-	t.P(`      err = s.wrapErr(err, "failed to marshal proto response")`)
-	t.P(`      s.writeError(ctx, resp, `, t.pkgs["twirp"], `.InternalErrorWith(err))`)
-	t.P(`      return`)
-	t.P(`    }`)
-	t.P(`    resp.Header().Set("Content-Type", "application/protobuf")`)
-	t.P(`  }`)
 	t.P()
+	t.P(`  `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "marshal")`)
 	t.P(`  ctx = `, t.pkgs["twirp"], `.WithStatusCode(ctx, respStatus)`)
+	t.P(`  `, t.pkgs["twirp"], `.WriteResponseHeader(ctx, resp)`)
 	t.P(`  resp.WriteHeader(respStatus)`)
+	t.P()
 	t.P(`  if n, err := resp.Write(respBytes); err != nil {`)
 	t.P(`    msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())`)
 	t.P(`    twerr := `, t.pkgs["twirp"], `.NewError(`, t.pkgs["twirp"], `.Unknown, msg)`)
 	t.P(`    s.hooks.CallError(ctx, twerr)`)
 	t.P(`  }`)
 	t.P(`  s.hooks.CallResponseSent(ctx)`)
-	t.P(`}`)
-	t.P()
 }
 
 // serviceMetadataVarName is the variable name used in generated code to refer
@@ -865,9 +2074,9 @@ func (t *twirp) generateFileDescriptor(file *protogen.File) {
 	pb := proto.Clone(file.Proto).(*descriptorpb.FileDescriptorProto)
 	pb.SourceCodeInfo = nil
 
-	b, err := proto.Marshal(pb)
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(pb)
 	if err != nil {
-		log.Fatal(err)
+		fail("marshal file descriptor: %v", err)
 	}
 
 	var buf bytes.Buffer
@@ -895,6 +2104,11 @@ func (t *twirp) generateFileDescriptor(file *protogen.File) {
 		b = b[n:]
 	}
 	t.P("}")
+	t.P()
+	t.P(`func init() {`)
+	t.P(`  // JSON 中的 google.protobuf.Any 字段可以解析本文件定义的类型`)
+	t.P(`  `, t.pkgs["twirp"], `.RegisterFileDescriptor(`, v, `)`)
+	t.P(`}`)
 }
 
 func (t *twirp) printComments(comments protogen.CommentSet) bool {
@@ -909,7 +2123,7 @@ func (t *twirp) printComments(comments protogen.CommentSet) bool {
 	return len(split) > 0
 }
 
-func (t *twirp) formattedOutput(raw []byte) []byte {
+func (t *twirp) formattedOutput(raw []byte) ([]byte, error) {
 	// Reformat generated code.
 	fset := token.NewFileSet()
 	ast, err := parser.ParseFile(fset, "", raw, parser.ParseComments)
@@ -922,39 +2136,70 @@ func (t *twirp) formattedOutput(raw []byte) []byte {
 		for line := 1; s.Scan(); line++ {
 			fmt.Fprintf(&src, "%5d\t%s\n", line, s.Bytes())
 		}
-		log.Fatal("bad Go source code was generated:", err.Error(), "\n"+src.String())
+		return nil, fmt.Errorf("bad Go source code was generated: %v\n%s", err, src.String())
 	}
 
+	// 与 gofmt 的输出保持一致，生成的代码可以通过 lint 的格式检查
 	out := bytes.NewBuffer(nil)
-	err = (&printer.Config{Mode: printer.TabIndent | printer.UseSpaces, Tabwidth: 8}).Fprint(out, fset, ast)
+	err = format.Node(out, fset, ast)
 	if err != nil {
-		log.Fatal("generated Go source code could not be reformatted:", err.Error())
+		return nil, fmt.Errorf("generated Go source code could not be reformatted: %v", err)
 	}
 
-	return out.Bytes()
+	return out.Bytes(), nil
 }
 
 func unexported(s string) string { return strings.ToLower(s[:1]) + s[1:] }
 
 func exported(s string) string { return strings.ToUpper(s[:1]) + s[1:] }
 
+// methodFuncType 返回接口方法的函数类型名，如 EchoHelloFunc
+func methodFuncType(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "Func"
+}
+
+// methodPathConst 返回接口路径常量名，如 EchoHelloPath
+func methodPathConst(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "Path"
+}
+
 func serviceStruct(service *protogen.Service) string {
 	return unexported(service.GoName) + "Server"
 }
 
+// addValidate 生成校验请求参数的代码
+//
+// 请求定义在其他 Go 包中时无法调用未导出的 validate，与嵌套 message 一样改为调用
+// api_package 模式导出的 Validate，对方没有生成时不校验。
 func (t *twirp) addValidate(method *protogen.Method, service *protogen.Service) {
 	if t.ValidateEnable {
-		t.P(`  if  validerr := reqContent.validate(); validerr != nil {`)
+		validate := "validate"
+		if t.APIPackage != "" {
+			validate = "Validate"
+		}
+		if method.Input.GoIdent.GoImportPath != t.plugin.FilesByPath[service.Location.SourceFile].GoImportPath {
+			t.P(`  if v, ok := interface{}(reqContent).(interface{ Validate() error }); ok {`)
+			t.P(`    if validerr := v.Validate(); validerr != nil {`)
+			t.P(`      s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))`)
+			t.P(`      return`)
+			t.P(`    }`)
+			t.P(`  }`)
+			t.P()
+			return
+		}
+		if t.strictMode(service, method) == strictExternal {
+			// 外部请求返回所有字段的错误，方便调用方一次修正
+			t.P(`  validate := reqContent.`, validate)
+			t.P(`  if !`, t.pkgs["twirp"], `.InternalRequest(ctx) {`)
+			t.P(`    validate = reqContent.`, validate, `All`)
+			t.P(`  }`)
+			t.P(`  if validerr := validate(); validerr != nil {`)
+		} else {
+			t.P(`  if  validerr := reqContent.`, validate, `(); validerr != nil {`)
+		}
 		t.P(`    s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))`)
 		t.P(`    return`)
 		t.P(`  }`)
 		t.P()
-		if t.needLogin(method, service) {
-			t.P(`  if ctxkit.GetUserID(ctx) == 0 {`)
-			t.P(`    s.writeError(ctx, resp, twirp.NewError(twirp.Unauthenticated, "need login"))`)
-			t.P(`    return`)
-			t.P(`  }`)
-			t.P()
-		}
 	}
 }
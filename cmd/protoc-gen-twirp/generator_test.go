@@ -0,0 +1,2270 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// echoProto 测试使用的 echo.proto
+func echoProto() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	user := field("user", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional)
+	user.TypeName = proto.String(".echo.v1.User")
+
+	msg := field("msg", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional)
+	msg.Options = &descriptorpb.FieldOptions{Deprecated: proto.Bool(true)}
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("echo.proto"),
+		Package: proto.String("echo.v1"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("sniper/rpc/echo/v1;echo_v1"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("HelloRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+				field("age", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional),
+				field("ids", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64, repeated),
+				field("score", 4, descriptorpb.FieldDescriptorProto_TYPE_FLOAT, optional),
+				user,
+				msg,
+			},
+		}, {
+			Name: proto.String("HelloResponse"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional),
+			},
+		}, {
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("user_id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional),
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Hello"),
+				InputType:  proto.String(".echo.v1.HelloRequest"),
+				OutputType: proto.String(".echo.v1.HelloResponse"),
+			}, {
+				Name:       proto.String("Reload"),
+				InputType:  proto.String(".echo.v1.User"),
+				OutputType: proto.String(".echo.v1.HelloResponse"),
+			}},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{{
+				// service Echo, method Hello
+				Path:            []int32{6, 0, 2, 0},
+				Span:            []int32{0, 0, 0},
+				LeadingComments: proto.String(" @error:NotFound user not exists\n @logbody:0.01\n @quota:daily_hello=100\n"),
+			}, {
+				// service Echo, method Reload
+				Path:            []int32{6, 0, 2, 1},
+				Span:            []int32{0, 0, 0},
+				LeadingComments: proto.String(" @internal\n @encrypted\n @maxbody:1024\n"),
+			}},
+		},
+	}
+}
+
+// runGenerator 使用 setup 设置生成参数，返回生成的文件内容
+func runGenerator(t *testing.T, setup func(g *twirp), files ...*descriptorpb.FileDescriptorProto) map[string]string {
+	t.Helper()
+
+	out := map[string]string{}
+	for _, f := range generateResponse(t, setup, files...).File {
+		out[f.GetName()] = f.GetContent()
+	}
+	return out
+}
+
+// generateResponse 生成代码并返回插件的原始响应，包含文件的输出顺序
+func generateResponse(t *testing.T, setup func(g *twirp), files ...*descriptorpb.FileDescriptorProto) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+
+	if len(files) == 0 {
+		files = []*descriptorpb.FileDescriptorProto{echoProto()}
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{ProtoFile: files}
+	for _, f := range files {
+		req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+	}
+
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	g.GatewayCluster = "sniper"
+	if setup != nil {
+		setup(g)
+	}
+	if err := g.Generate(plugin); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := plugin.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	return resp
+}
+
+// deprecatedImports 已经废弃、lint 会报错的包
+var deprecatedImports = map[string]bool{
+	"io/ioutil":                         true,
+	"github.com/golang/protobuf/jsonpb": true,
+	"github.com/golang/protobuf/proto":  true,
+}
+
+// uncheckedCalls 返回值包含 error、必须检查的方法
+var uncheckedCalls = map[string]bool{
+	"Write": true,
+	"Close": true,
+	"Flush": true,
+}
+
+// lintGo 检查常见的 lint 规则：gofmt、废弃的包、未检查的错误和导出函数的注释
+func lintGo(t *testing.T, name, src string) {
+	t.Helper()
+
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if !bytes.Equal(formatted, []byte(src)) {
+		t.Errorf("%s is not gofmt-ed", name)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if deprecatedImports[path] {
+			t.Errorf("%s: deprecated import %s", fset.Position(imp.Pos()), path)
+		}
+	}
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if ok && fn.Recv == nil && fn.Name.IsExported() && fn.Doc == nil {
+			t.Errorf("%s: exported function %s should have comment", fset.Position(fn.Pos()), fn.Name.Name)
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.ExprStmt)
+		if !ok {
+			return true
+		}
+		call, ok := stmt.X.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && uncheckedCalls[sel.Sel.Name] {
+			t.Errorf("%s: error returned by %s is not checked", fset.Position(call.Pos()), sel.Sel.Name)
+		}
+		return true
+	})
+}
+
+func TestGeneratedCodeLint(t *testing.T) {
+	cases := map[string]func(g *twirp){
+		"default": nil,
+		"inline": func(g *twirp) {
+			g.InlineServe = true
+			g.VTProto = true
+		},
+		"full": func(g *twirp) {
+			g.ValidateEnable = true
+			g.MessagePool = true
+			g.UnknownFields = true
+			g.MaxBodySize = 1 << 20
+			g.RequireContentLength = true
+			g.GzipMinSize = 1024
+			g.ChunkMinSize = 1 << 20
+			g.Explorer = true
+			g.GraphQL = true
+			g.Upstream = true
+			g.ContractTest = true
+			g.Generics = true
+			g.Messages = true
+		},
+		"compat": func(g *twirp) {
+			g.Compat = "twitch"
+		},
+		"api_package": func(g *twirp) {
+			g.APIPackage = "api"
+			g.ValidateEnable = true
+		},
+	}
+
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			files := runGenerator(t, setup)
+			if len(files) == 0 {
+				t.Fatal("no files generated")
+			}
+			for fname, content := range files {
+				// .pb.go 由 protoc-gen-go 生成，旧版本会导入 github.com/golang/protobuf/proto
+				if strings.HasSuffix(fname, ".go") && !strings.HasSuffix(fname, ".pb.go") {
+					lintGo(t, fname, content)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	setup := func(workers int) func(g *twirp) {
+		return func(g *twirp) {
+			g.ValidateEnable = true
+			g.Routes = true
+			g.Docs = true
+			g.Gateway = "envoy"
+			g.GraphQL = true
+			g.Explorer = true
+			g.ContractTest = true
+			g.Generics = true
+			g.Workers = workers
+			_ = g.BuildTags.Set("explorer=tools")
+			_ = g.BuildTags.Set("graphql=!prod")
+		}
+	}
+	files := func() []*descriptorpb.FileDescriptorProto {
+		return append([]*descriptorpb.FileDescriptorProto{echoProto()}, multiProto()...)
+	}
+
+	// 每次生成使用新的输入，并发数不同时输出的文件顺序和内容也要逐字节相同
+	want := generateResponse(t, setup(1), files()...).File
+	for i, workers := range []int{1, 2, 4, 8, 1} {
+		have := generateResponse(t, setup(workers), files()...).File
+		if len(have) != len(want) {
+			t.Fatalf("run %d: have %d files, want %d", i, len(have), len(want))
+		}
+		for j := range want {
+			if have[j].GetName() != want[j].GetName() {
+				t.Fatalf("run %d: file %d is %s, want %s", i, j, have[j].GetName(), want[j].GetName())
+			}
+			if !bytes.Equal([]byte(have[j].GetContent()), []byte(want[j].GetContent())) {
+				t.Fatalf("run %d: %s is not deterministic", i, want[j].GetName())
+			}
+		}
+	}
+}
+
+func TestGenerateCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "protoc-gen-twirp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	want := runGenerator(t, func(g *twirp) { g.ValidateEnable = true })
+	setup := func(g *twirp) {
+		g.ValidateEnable = true
+		g.CacheDir = dir
+	}
+
+	// 第一次生成写入缓存，第二次直接使用缓存
+	for i := 0; i < 2; i++ {
+		have := runGenerator(t, setup)
+		if len(have) != len(want) {
+			t.Fatalf("have %d files, want %d", len(have), len(want))
+		}
+		for name, content := range want {
+			if have[name] != content {
+				t.Fatalf("%s in run %d differs from uncached output", name, i)
+			}
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("have %d cache entries, want 1", len(entries))
+	}
+}
+
+// multiProto 测试使用的多个 proto 文件
+//
+// a.proto 和 b.proto 的 Go 包名都是 user，first.proto 只依赖 a.proto，
+// second.proto 同时依赖两者，third.proto 没有依赖。
+func multiProto() []*descriptorpb.FileDescriptorProto {
+	message := func(name string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:   proto.String("id"),
+				Number: proto.Int32(1),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}},
+		}
+	}
+	file := func(name, pkg, goPackage string, deps []string, messages ...*descriptorpb.DescriptorProto) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:        proto.String(name),
+			Package:     proto.String(pkg),
+			Syntax:      proto.String("proto3"),
+			Dependency:  deps,
+			Options:     &descriptorpb.FileOptions{GoPackage: proto.String(goPackage)},
+			MessageType: messages,
+		}
+	}
+	service := func(name, in, out string) []*descriptorpb.ServiceDescriptorProto {
+		return []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String(name),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(in),
+				OutputType: proto.String(out),
+			}},
+		}}
+	}
+
+	a := file("a.proto", "a", "sniper/rpc/a/user;user", nil, message("User"))
+	b := file("b.proto", "b", "sniper/rpc/b/user;user", nil, message("User"))
+
+	first := file("first.proto", "first", "sniper/rpc/first;first", []string{"a.proto"})
+	first.Service = service("First", ".a.User", ".a.User")
+
+	second := file("second.proto", "second", "sniper/rpc/second;second", []string{"a.proto", "b.proto"})
+	second.Service = service("Second", ".b.User", ".a.User")
+
+	third := file("third.proto", "third", "sniper/rpc/third;third", nil, message("Thing"))
+	third.Service = service("Third", ".third.Thing", ".third.Thing")
+
+	return []*descriptorpb.FileDescriptorProto{a, b, first, second, third}
+}
+
+func TestGenerateImportsPerFile(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		files := runGenerator(t, func(g *twirp) {
+			g.GraphQL = true
+			g.Generics = true
+			g.Workers = workers
+		}, multiProto()...)
+
+		source := func(suffix string) string {
+			for name, content := range files {
+				if strings.HasSuffix(name, suffix) {
+					return content
+				}
+			}
+			t.Fatalf("%s is not generated", suffix)
+			return ""
+		}
+
+		first := source("first.twirp.go")
+		if !strings.Contains(first, `import user "sniper/rpc/a/user"`) {
+			t.Errorf("first.twirp.go should import sniper/rpc/a/user:\n%s", first)
+		}
+		if strings.Contains(first, "sniper/rpc/b/user") {
+			t.Errorf("first.twirp.go should not import sniper/rpc/b/user")
+		}
+
+		for _, name := range []string{"second.twirp.go", "second.graphql.go", "second.generics.go"} {
+			second := source(name)
+			for _, s := range []string{
+				`import user "sniper/rpc/a/user"`,
+				`import user1 "sniper/rpc/b/user"`,
+				`*user1.User`,
+			} {
+				if !strings.Contains(second, s) {
+					t.Errorf("%s should contain %s", name, s)
+				}
+			}
+		}
+		if second := source("second.twirp.go"); !strings.Contains(second, "// imports:") {
+			t.Errorf("second.twirp.go should list resolved imports in header")
+		}
+
+		third := source("third.twirp.go")
+		if strings.Contains(third, "sniper/rpc/a/user") || strings.Contains(third, "sniper/rpc/b/user") {
+			t.Errorf("third.twirp.go should not import packages of other files:\n%s", third)
+		}
+		if strings.Contains(third, "// imports:") {
+			t.Errorf("third.twirp.go should not list imports in header")
+		}
+
+		for name, content := range files {
+			if strings.HasSuffix(name, ".go") {
+				lintGo(t, name, content)
+			}
+		}
+	}
+}
+
+// validateAllProto 测试使用的 proto 文件
+//
+// common.proto 与 echo.proto 在同一 Go 包，other.proto 在其他 Go 包，两者都没有服务。
+func validateAllProto() []*descriptorpb.FileDescriptorProto {
+	field := func(name, typeName string, number int32, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typeName),
+			Label:    label.Enum(),
+		}
+	}
+	message := func(name string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:   proto.String("size"),
+				Number: proto.Int32(1),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}},
+		}
+	}
+	file := func(name, pkg, goPackage string, deps []string, messages ...*descriptorpb.DescriptorProto) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:        proto.String(name),
+			Package:     proto.String(pkg),
+			Syntax:      proto.String("proto3"),
+			Dependency:  deps,
+			Options:     &descriptorpb.FileOptions{GoPackage: proto.String(goPackage)},
+			MessageType: messages,
+		}
+	}
+
+	common := file("common.proto", "common", "sniper/rpc/echo;echo", nil, message("Page"))
+	other := file("other.proto", "other", "sniper/rpc/other;other", nil, message("Tag"))
+
+	req := &descriptorpb.DescriptorProto{
+		Name: proto.String("Request"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			field("page", ".common.Page", 1, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+			field("tags", ".other.Tag", 2, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+		},
+	}
+	echo := file("echo.proto", "echo", "sniper/rpc/echo;echo", []string{"common.proto", "other.proto"}, req)
+	echo.Service = []*descriptorpb.ServiceDescriptorProto{{
+		Name: proto.String("Echo"),
+		Method: []*descriptorpb.MethodDescriptorProto{{
+			Name:       proto.String("Get"),
+			InputType:  proto.String(".echo.Request"),
+			OutputType: proto.String(".common.Page"),
+		}},
+	}}
+
+	return []*descriptorpb.FileDescriptorProto{common, other, echo}
+}
+
+func TestGenerateValidateAll(t *testing.T) {
+	files := runGenerator(t, func(g *twirp) { g.ValidateEnable = true }, validateAllProto()...)
+	for _, name := range []string{"sniper/rpc/echo/common.validate.go", "sniper/rpc/other/other.validate.go"} {
+		if _, ok := files[name]; ok {
+			t.Errorf("validate_enable should not generate %s", name)
+		}
+	}
+
+	files = runGenerator(t, func(g *twirp) { g.ValidateAll = true }, validateAllProto()...)
+	for _, name := range []string{
+		"sniper/rpc/echo/common.validate.go",
+		"sniper/rpc/other/other.validate.go",
+		"sniper/rpc/echo/echo.validate.go",
+		"sniper/rpc/echo/echo.twirp.go",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("validate_all should generate %s", name)
+		}
+	}
+	for name := range files {
+		if strings.HasPrefix(name, "sniper/rpc/other/") && !strings.HasSuffix(name, ".validate.go") {
+			t.Errorf("message-only file should only generate validate code, got %s", name)
+		}
+	}
+
+	echo := files["sniper/rpc/echo/echo.validate.go"]
+	for _, s := range []string{
+		"interface{ validate() error }",
+		"interface{ Validate() error }",
+	} {
+		if !strings.Contains(echo, s) {
+			t.Errorf("echo.validate.go should contain %s:\n%s", s, echo)
+		}
+	}
+	if twirp := files["sniper/rpc/echo/echo.twirp.go"]; !strings.Contains(twirp, "reqContent.validate()") {
+		t.Errorf("validate_all should enable request validation")
+	}
+
+	for name, content := range files {
+		if strings.HasSuffix(name, ".go") {
+			lintGo(t, name, content)
+		}
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	file := echoProto()
+	file.SourceCodeInfo.Location[1].LeadingComments = proto.String(" @maxbody:1k\n")
+
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		FileToGenerate: []string{file.GetName()},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	err = g.Generate(plugin)
+	if err == nil {
+		t.Fatal("invalid annotation should fail")
+	}
+	want := `echo.proto: invalid @maxbody of /echo.v1.Echo/Reload: "1k"`
+	if err.Error() != want {
+		t.Fatalf("have error %q, want %q", err, want)
+	}
+}
+
+func TestGenerateCollision(t *testing.T) {
+	file := func(name, pkg string) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:    proto.String(name),
+			Package: proto.String(pkg),
+			Syntax:  proto.String("proto3"),
+			// go_package 写错，两个文件输出到同一个目录
+			Options:     &descriptorpb.FileOptions{GoPackage: proto.String("sniper/rpc/user;user")},
+			MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Thing")}},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("User"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("Get"),
+					InputType:  proto.String("." + pkg + ".Thing"),
+					OutputType: proto.String("." + pkg + ".Thing"),
+				}},
+			}},
+		}
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file("v1/user.proto", "user.v1"), file("v2/user.proto", "user.v2")},
+		FileToGenerate: []string{"v1/user.proto", "v2/user.proto"},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	err = g.Generate(plugin)
+	if err == nil {
+		t.Fatal("files with the same prefix should fail")
+	}
+	if !strings.Contains(err.Error(), "v1/user.proto") || !strings.Contains(err.Error(), "v2/user.proto") {
+		t.Fatalf("error should contain both file names: %s", err)
+	}
+}
+
+// editionsProto 使用 edition 2023 的 proto 文件，字段默认有显式 presence
+func editionsProto() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, features *descriptorpb.FeatureSet) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if features != nil {
+			f.Options = &descriptorpb.FieldOptions{Features: features}
+		}
+		return f
+	}
+
+	inner := field("inner", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, &descriptorpb.FeatureSet{
+		MessageEncoding: descriptorpb.FeatureSet_DELIMITED.Enum(),
+	})
+	inner.TypeName = proto.String(".ed.v1.Inner")
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("ed.proto"),
+		Package: proto.String("ed.v1"),
+		Syntax:  proto.String("editions"),
+		Edition: descriptorpb.Edition_EDITION_2023.Enum(),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("sniper/rpc/ed/v1;ed_v1")},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("GetRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, nil),
+				field("age", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, &descriptorpb.FeatureSet{
+					FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum(),
+				}),
+				field("id", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64, &descriptorpb.FeatureSet{
+					FieldPresence: descriptorpb.FeatureSet_LEGACY_REQUIRED.Enum(),
+				}),
+				inner,
+			},
+		}, {
+			Name: proto.String("Inner"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("value", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, nil),
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Ed"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".ed.v1.GetRequest"),
+				OutputType: proto.String(".ed.v1.Inner"),
+			}},
+		}},
+	}
+}
+
+func TestGenerateEditions(t *testing.T) {
+	files := runGenerator(t, func(g *twirp) {
+		g.ValidateEnable = true
+		g.Messages = true
+	}, editionsProto())
+
+	twirp := files["sniper/rpc/ed/v1/ed.twirp.go"]
+	for _, s := range []string{
+		// 显式 presence 的字段是指针，IMPLICIT 的字段直接赋值
+		"fv := v[0]\n\t\treqContent.Name = &fv",
+		"reqContent.Age = int32(vv)",
+		// 表单请求检查 LEGACY_REQUIRED 字段
+		"proto.CheckInitialized(reqContent)",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("ed.twirp.go should contain %q", s)
+		}
+	}
+
+	// DELIMITED 编码的消息字段同样需要校验
+	if validate := files["sniper/rpc/ed/v1/ed.validate.go"]; !strings.Contains(validate, "m.GetInner()") {
+		t.Errorf("ed.validate.go should validate delimited field inner:\n%s", validate)
+	}
+
+	for name, content := range files {
+		if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, ".pb.go") {
+			lintGo(t, name, content)
+		}
+	}
+}
+
+func TestGenerateCodec(t *testing.T) {
+	files := runGenerator(t, nil)
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		"twirp.LookupCodec(header)",
+		"s.serveHelloCodec(ctx, resp, req, codec)",
+		"codec.Unmarshal(buf, reqContent)",
+		"twirp.CodecMarshal(codec)",
+		"func NewEchoCodecClient(addr string, client twirp.HTTPClient, codec twirp.Codec) Echo",
+		"twirp.DoCodecRequest(ctx, c.client, c.codec, c.urls[0], in, out)",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+}
+
+func TestGenerateGRPCWeb(t *testing.T) {
+	files := runGenerator(t, nil, streamProto())
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		`case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":`,
+		"twirp.ServeGRPCWeb(ctx, resp, req, s.serveHelloProtobuf)",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+	if strings.Contains(twirp, "s.serveTailProtobuf)") {
+		t.Error("streaming method Tail should not accept gRPC-Web requests")
+	}
+}
+
+func TestGenerateBuildTags(t *testing.T) {
+	tags := buildTags{}
+	for _, s := range []string{"explorer=tools", "generics=!prod", "graphql=tools || debug"} {
+		if err := tags.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range []string{"twirp=tools", "explorer", "explorer="} {
+		if err := (buildTags{}).Set(s); err == nil {
+			t.Errorf("build_tag %q should be invalid", s)
+		}
+	}
+
+	files := runGenerator(t, func(g *twirp) {
+		g.Explorer = true
+		g.GraphQL = true
+		g.Generics = true
+		g.ValidateEnable = true
+		g.BuildTags = tags
+	})
+
+	prefixes := map[string]string{
+		"sniper/rpc/echo/v1/echo.explorer.go": "//go:build tools\n",
+		"sniper/rpc/echo/v1/echo.generics.go": "//go:build go1.18 && !prod\n",
+		"sniper/rpc/echo/v1/echo.graphql.go":  "//go:build tools || debug\n",
+		"sniper/rpc/echo/v1/echo.twirp.go":    "// Package",
+		"sniper/rpc/echo/v1/echo.validate.go": "package",
+	}
+	for name, prefix := range prefixes {
+		content, ok := files[name]
+		if !ok {
+			t.Errorf("%s is not generated", name)
+			continue
+		}
+		if !strings.HasPrefix(content, prefix) {
+			t.Errorf("%s should start with %q:\n%s", name, prefix, content[:80])
+		}
+	}
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		"var echoExplorerMethods []twirp.ExplorerMethod",
+		"if len(echoExplorerMethods) > 0 && req.URL.Path ==",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+
+	for name, content := range files {
+		if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, ".pb.go") {
+			lintGo(t, name, content)
+		}
+	}
+}
+
+// streamProto 在 echo.proto 中增加服务端流式接口 Tail、客户端流式接口 Upload 和双向流式接口 Chat
+func streamProto() *descriptorpb.FileDescriptorProto {
+	file := echoProto()
+	method := func(name string, client, server bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".echo.v1.HelloRequest"),
+			OutputType:      proto.String(".echo.v1.HelloResponse"),
+			ClientStreaming: proto.Bool(client),
+			ServerStreaming: proto.Bool(server),
+		}
+	}
+	file.Service[0].Method = append(file.Service[0].Method,
+		method("Tail", false, true),
+		method("Upload", true, false),
+		method("Chat", true, true),
+	)
+	return file
+}
+
+func TestGenerateStreaming(t *testing.T) {
+	cases := map[string]func(g *twirp){
+		"default": nil,
+		"full": func(g *twirp) {
+			g.InlineServe = true
+			g.MessagePool = true
+			g.ValidateEnable = true
+			g.GraphQL = true
+			g.Generics = true
+			g.Explorer = true
+			g.Messages = true
+			g.Gateway = "nginx"
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			files := runGenerator(t, setup, streamProto())
+			if conf, ok := files["sniper/rpc/echo/v1/echo.nginx.conf"]; ok && !strings.Contains(conf, "location = /echo.v1.Echo/Chat {\n    proxy_pass http://sniper;\n    proxy_http_version 1.1;") {
+				t.Errorf("nginx location of Chat should upgrade websocket:\n%s", conf)
+			}
+
+			twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+			for _, s := range []string{
+				"Tail(ctx context.Context, in *HelloRequest, sender EchoTailSender) error",
+				"type EchoTailSender interface {",
+				"type EchoStreamClient interface {",
+				"TailStream(ctx context.Context, in *HelloRequest) (*EchoTailStream, error)",
+				"twirp.DoStreamRequest(ctx, c.client, twirp.JSONCodec, c.urls[2], in)",
+				"stream := twirp.NewServerStream(ctx, resp, s.hooks, marshal)",
+				"err = impl.Tail(ctx, reqContent, echoTailSender{stream: stream})",
+				"Upload(ctx context.Context, receiver EchoUploadReceiver) (*HelloResponse, error)",
+				"Chat(ctx context.Context, receiver EchoChatReceiver, sender EchoChatSender) error",
+				"UploadStream(ctx context.Context) (*EchoUploadStream, error)",
+				"func (s *EchoUploadStream) CloseAndRecv() (*HelloResponse, error) {",
+				"func (s *EchoChatStream) CloseSend() error {",
+				"twirp.DialWebSocket(ctx, c.client, twirp.ProtobufCodec, c.urls[3])",
+				"if twirp.IsWebSocketUpgrade(req) {",
+				"stream, err := twirp.AcceptWebSocket(ctx, resp, req, s.hooks, 0)",
+				"respContent, err = impl.Upload(ctx, echoUploadReceiver{stream: stream})",
+				"err = impl.Chat(ctx, echoChatReceiver{stream: stream}, echoChatSender{stream: stream})",
+			} {
+				if !strings.Contains(twirp, s) {
+					t.Errorf("echo.twirp.go should contain %q", s)
+				}
+			}
+			for _, name := range []string{"Tail", "Upload", "Chat"} {
+				if strings.Contains(files["sniper/rpc/echo/v1/echo.graphql.go"], name) {
+					t.Errorf("streaming method %s should not be exposed by graphql", name)
+				}
+			}
+
+			for name, content := range files {
+				if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, ".pb.go") {
+					lintGo(t, name, content)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateStreamingSSE(t *testing.T) {
+	files := runGenerator(t, func(g *twirp) {
+		g.StreamTransport = "sse"
+		g.InlineServe = true
+		g.Routes = true
+		g.Docs = true
+	}, streamProto())
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		"stream := twirp.NewSSEServerStream(ctx, resp, s.hooks)",
+		"if req.Method == http.MethodGet {\n\t\tswitch req.URL.Path {\n\t\tcase EchoTailPath:\n\t\t\ts.serveTail(ctx, resp, req)",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+	if strings.Contains(twirp, "twirp.NewServerStream(") {
+		t.Errorf("sse should not use framed server streams")
+	}
+	if strings.Contains(twirp, "case EchoHelloPath:\n\t\t\ts.serveHello(ctx, resp, req)\n\t\t\treturn") {
+		t.Errorf("unary methods should not accept GET without AllowGET")
+	}
+	if routes := files["sniper/rpc/echo/v1/echo.routes.json"]; !strings.Contains(routes, `"name": "Tail",
+          "path": "/echo.v1.Echo/Tail",
+          "http_methods": [
+            "GET",
+            "POST"
+          ]`) {
+		t.Errorf("routes of Tail should accept GET:\n%s", routes)
+	}
+	if docs := files["sniper/rpc/echo/v1/docs/Echo.md"]; !strings.Contains(docs, "返回 Server-Sent Events") {
+		t.Errorf("docs should describe sse:\n%s", docs)
+	}
+
+	for name, content := range files {
+		if strings.HasSuffix(name, ".go") {
+			lintGo(t, name, content)
+		}
+	}
+
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{streamProto()},
+		FileToGenerate: []string{"echo.proto"},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator()
+	g.StreamTransport = "websocket"
+	if err := g.Generate(plugin); err == nil || err.Error() != `unknown stream_transport "websocket", only sse is supported` {
+		t.Errorf("unknown stream_transport should fail, got %v", err)
+	}
+}
+
+func TestGenerateStreamingError(t *testing.T) {
+	cases := map[string]struct {
+		edit func(file *descriptorpb.FileDescriptorProto)
+		want string
+	}{
+		"encrypted": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+					Path:            []int32{6, 0, 2, 3},
+					Span:            []int32{0, 0, 0},
+					LeadingComments: proto.String(" @encrypted\n"),
+				})
+			},
+			want: "echo.proto: @encrypted is not supported by streaming method echo.v1.Echo.Upload",
+		},
+		"dedup": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+					Path:            []int32{6, 0, 2, 2},
+					Span:            []int32{0, 0, 0},
+					LeadingComments: proto.String(" @dedup\n"),
+				})
+			},
+			want: "echo.proto: @dedup is not supported by streaming method echo.v1.Echo.Tail",
+		},
+		"cache": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+					Path:            []int32{6, 0, 2, 2},
+					Span:            []int32{0, 0, 0},
+					LeadingComments: proto.String(" @cache:30s\n"),
+				})
+			},
+			want: "echo.proto: @cache is not supported by streaming method echo.v1.Echo.Tail",
+		},
+		"get": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+					Path:            []int32{6, 0, 2, 2},
+					Span:            []int32{0, 0, 0},
+					LeadingComments: proto.String(" @get\n"),
+				})
+			},
+			want: "echo.proto: @get is not supported by streaming method echo.v1.Echo.Tail",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := streamProto()
+			c.edit(file)
+			req := &pluginpb.CodeGeneratorRequest{
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+				FileToGenerate: []string{file.GetName()},
+			}
+			plugin, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			g := newGenerator()
+			g.OptionPrefix = "sniper"
+			g.TwirpPackage = "sniper/util/twirp"
+			err = g.Generate(plugin)
+			if err == nil || err.Error() != c.want {
+				t.Fatalf("have error %v, want %q", err, c.want)
+			}
+		})
+	}
+}
+
+// longPollProto 在 echo.proto 上增加 @longpoll 接口 Watch
+func longPollProto() *descriptorpb.FileDescriptorProto {
+	file := echoProto()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	file.MessageType = append(file.MessageType, &descriptorpb.DescriptorProto{
+		Name: proto.String("WatchRequest"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			field("topic", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			field("cursor", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+		},
+	}, &descriptorpb.DescriptorProto{
+		Name: proto.String("WatchResponse"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			field("cursor", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+			field("message", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+		},
+	})
+	file.Service[0].Method = append(file.Service[0].Method, &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String("Watch"),
+		InputType:  proto.String(".echo.v1.WatchRequest"),
+		OutputType: proto.String(".echo.v1.WatchResponse"),
+	})
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		Path:            []int32{6, 0, 2, 2},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @longpoll:20s\n"),
+	})
+	return file
+}
+
+func TestGenerateLongPoll(t *testing.T) {
+	cases := map[string]func(g *twirp){
+		"default": nil,
+		"full": func(g *twirp) {
+			g.InlineServe = true
+			g.MessagePool = true
+			g.ValidateEnable = true
+			g.Routes = true
+			g.Docs = true
+			g.Gateway = "envoy"
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			files := runGenerator(t, setup, longPollProto())
+
+			twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+			for _, s := range []string{
+				"type EchoWatchNotifier interface {",
+				"WatchReady(context.Context, *WatchRequest) (<-chan struct{}, error)",
+				"if notifier, ok := impl.(EchoWatchNotifier); ok {",
+				"polled := twirp.WaitLongPoll(ctx, ready, 20000000000) // 20s",
+				"respContent = &WatchResponse{Cursor: reqContent.Cursor}",
+				"twirp.RetryLongPoll(ctx, 20000000000, func() bool {",
+				"return err != nil || respContent == nil || respContent.GetCursor() != reqContent.GetCursor()",
+			} {
+				if !strings.Contains(twirp, s) {
+					t.Errorf("echo.twirp.go should contain %q", s)
+				}
+			}
+			// inline_serve 时每种编码格式都会调用一次业务方法，没有实现 Notifier 时重复调用
+			if n, want := strings.Count(twirp, "twirp.WaitLongPoll(")+strings.Count(twirp, "twirp.RetryLongPoll("), strings.Count(twirp, "impl.Watch(ctx, reqContent)"); n != want {
+				t.Errorf("only Watch should wait, have %d calls, want %d", n, want)
+			}
+
+			if routes, ok := files["sniper/rpc/echo/v1/echo.routes.json"]; ok && !strings.Contains(routes, `"longpoll": "20s"`) {
+				t.Errorf("routes should contain long polling timeout:\n%s", routes)
+			}
+			if docs, ok := files["sniper/rpc/echo/v1/docs/Echo.md"]; ok && !strings.Contains(docs, "- 长轮询：没有新数据时最多等待 `20s`") {
+				t.Errorf("docs should describe long polling:\n%s", docs)
+			}
+			if conf, ok := files["sniper/rpc/echo/v1/echo.envoy.yaml"]; ok && !strings.Contains(conf, "path: /echo.v1.Echo/Watch\n  route:\n    cluster: sniper\n    timeout: 25s") {
+				t.Errorf("envoy route of Watch should wait longer than @longpoll:\n%s", conf)
+			}
+
+			for name, content := range files {
+				if strings.HasSuffix(name, ".go") {
+					lintGo(t, name, content)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateLongPollError(t *testing.T) {
+	cases := map[string]struct {
+		edit    func(file *descriptorpb.FileDescriptorProto)
+		gateway string
+		want    string
+	}{
+		"duration": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location[2].LeadingComments = proto.String(" @longpoll:soon\n")
+			},
+			want: `echo.proto: invalid @longpoll of /echo.v1.Echo/Watch: "soon"`,
+		},
+		"cursor": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.MessageType[4].Field[0].Name = proto.String("next")
+			},
+			want: "echo.proto: long polling method echo.v1.Echo.Watch requires a cursor field in both request and response",
+		},
+		"type": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.MessageType[4].Field[0].Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+			},
+			want: "echo.proto: cursor fields of long polling method echo.v1.Echo.Watch must be scalars of the same type",
+		},
+		"streaming": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.Service[0].Method[2].ServerStreaming = proto.Bool(true)
+			},
+			want: "echo.proto: @longpoll is not supported by streaming method echo.v1.Echo.Watch",
+		},
+		"timeout": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location[2].LeadingComments = proto.String(" @longpoll:20s\n @timeout:10s\n")
+			},
+			gateway: "nginx",
+			want:    "echo.proto: @timeout of /echo.v1.Echo/Watch must be longer than @longpoll 20s",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := longPollProto()
+			c.edit(file)
+			req := &pluginpb.CodeGeneratorRequest{
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+				FileToGenerate: []string{file.GetName()},
+			}
+			plugin, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			g := newGenerator()
+			g.OptionPrefix = "sniper"
+			g.TwirpPackage = "sniper/util/twirp"
+			g.Gateway = c.gateway
+			err = g.Generate(plugin)
+			if err == nil || err.Error() != c.want {
+				t.Fatalf("have error %v, want %q", err, c.want)
+			}
+		})
+	}
+}
+
+// batchProto 在 echoProto 的基础上给 Hello 加上 @batchable 注解
+func batchProto() *descriptorpb.FileDescriptorProto {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @batchable:4\n")
+	return file
+}
+
+func TestGenerateBatch(t *testing.T) {
+	cases := map[string]func(g *twirp){
+		"default": nil,
+		"full": func(g *twirp) {
+			g.InlineServe = true
+			g.MessagePool = true
+			g.ValidateEnable = true
+			g.Routes = true
+			g.Docs = true
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			files := runGenerator(t, setup, batchProto())
+
+			twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+			for _, s := range []string{
+				`EchoHelloBatchPath = "/echo.v1.Echo/HelloBatch"`,
+				"case EchoHelloBatchPath:\n\t\ts.serveHelloBatch(ctx, resp, req)",
+				"type EchoHelloBatchResult struct {",
+				"HelloBatch(context.Context, []*HelloRequest) ([]EchoHelloBatchResult, error)",
+				"_ EchoBatchClient = (*echoCodecClient)(nil)",
+				`twirp.DoBatchRequest(ctx, c.client, twirp.JSONCodec, c.urls[0]+"Batch", items, new(HelloResponse))`,
+				"codec, items, err := twirp.ReadBatchRequest(req, new(HelloRequest))",
+				"results := twirp.RunBatch(ctx, len(items), 4, func(ctx context.Context, i int) twirp.BatchResult {",
+				`if err := twirp.CheckQuota(ctx, "daily_hello", 100); err != nil {`,
+				"err = twirp.CheckErrorCode(EchoHelloPath, err)",
+				"twirp.WriteBatchResponse(ctx, resp, s.hooks, codec, results)",
+			} {
+				if !strings.Contains(twirp, s) {
+					t.Errorf("echo.twirp.go should contain %q", s)
+				}
+			}
+			if strings.Contains(twirp, "ReloadBatch") {
+				t.Error("Reload is not batchable")
+			}
+			if validate := strings.Contains(twirp, "return twirp.BatchResult{Err: twirp.InvalidArgumentError("); validate != (name == "full") {
+				t.Errorf("batch items should be validated only with validate_enable, have %v", validate)
+			}
+
+			if routes, ok := files["sniper/rpc/echo/v1/echo.routes.json"]; ok && !strings.Contains(routes, `"batch_path": "/echo.v1.Echo/HelloBatch"`) {
+				t.Errorf("routes should contain batch path:\n%s", routes)
+			}
+			if docs, ok := files["sniper/rpc/echo/v1/docs/Echo.md"]; ok && !strings.Contains(docs, "- 批量接口：`POST /echo.v1.Echo/HelloBatch`，最多同时处理 4 个请求") {
+				t.Errorf("docs should describe batch endpoint:\n%s", docs)
+			}
+
+			for name, content := range files {
+				if strings.HasSuffix(name, ".go") {
+					lintGo(t, name, content)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateBatchError(t *testing.T) {
+	cases := map[string]struct {
+		edit func(file *descriptorpb.FileDescriptorProto)
+		want string
+	}{
+		"concurrency": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location[0].LeadingComments = proto.String(" @batchable:0\n")
+			},
+			want: `echo.proto: invalid @batchable of /echo.v1.Echo/Hello: "0"`,
+		},
+		"streaming": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.Service[0].Method[0].ServerStreaming = proto.Bool(true)
+				file.SourceCodeInfo.Location[0].LeadingComments = proto.String(" @batchable\n")
+			},
+			want: "echo.proto: @batchable is not supported by streaming method echo.v1.Echo.Hello",
+		},
+		"encrypted": {
+			edit: func(file *descriptorpb.FileDescriptorProto) {
+				file.SourceCodeInfo.Location[1].LeadingComments = proto.String(" @encrypted\n @batchable\n")
+			},
+			want: "echo.proto: @encrypted is not supported by batchable method echo.v1.Echo.Reload",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := batchProto()
+			c.edit(file)
+			req := &pluginpb.CodeGeneratorRequest{
+				ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+				FileToGenerate: []string{file.GetName()},
+			}
+			plugin, err := protogen.Options{}.New(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			g := newGenerator()
+			g.OptionPrefix = "sniper"
+			g.TwirpPackage = "sniper/util/twirp"
+			err = g.Generate(plugin)
+			if err == nil || err.Error() != c.want {
+				t.Fatalf("have error %v, want %q", err, c.want)
+			}
+		})
+	}
+}
+
+func TestGenerateGRPC(t *testing.T) {
+	cases := map[string]func(g *twirp){
+		"default": func(g *twirp) {
+			g.GRPC = true
+		},
+		"full": func(g *twirp) {
+			g.GRPC = true
+			g.ValidateEnable = true
+			g.APIPackage = "api"
+			g.BuildTags = buildTags{artifactGRPC: "grpc"}
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			files := runGenerator(t, setup, streamProto())
+
+			fname := "sniper/rpc/echo/v1/echo.grpc.go"
+			if name == "full" {
+				fname = "sniper/rpc/echo/v1/echo_v1api/echo.grpc.go"
+			}
+			grpc, ok := files[fname]
+			if !ok {
+				t.Fatalf("%s is not generated", fname)
+			}
+			for _, s := range []string{
+				"func RegisterEchoGRPCServer(s *grpc.Server, svc Echo) {",
+				`ServiceName: "echo.v1.Echo",`,
+				`{MethodName: "Hello", Handler: echoHelloGRPCHandler},`,
+				`{StreamName: "Tail", Handler: echoTailGRPCHandler, ServerStreams: true, ClientStreams: false},`,
+				`{StreamName: "Chat", Handler: echoChatGRPCHandler, ServerStreams: true, ClientStreams: true},`,
+				`info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/echo.v1.Echo/Hello"}`,
+				"return status.Error(codes.Code(twirp.GRPCCode(twerr.Code())), twerr.Msg())",
+				"return echoGRPCError(srv.(Echo).Tail(ctx, in, echoTailGRPCStream{stream}))",
+				"out, err := srv.(Echo).Upload(ctx, echoUploadGRPCStream{stream})",
+				"return echoGRPCError(srv.(Echo).Chat(ctx, s, s))",
+			} {
+				if !strings.Contains(grpc, s) {
+					t.Errorf("%s should contain %q", fname, s)
+				}
+			}
+			if validate := strings.Contains(grpc, "codes.InvalidArgument, validerr.Error()"); validate != (name == "full") {
+				t.Errorf("requests should be validated only with validate_enable, have %v", validate)
+			}
+			if constraint := strings.HasPrefix(grpc, "//go:build grpc\n"); constraint != (name == "full") {
+				t.Errorf("build constraint of %s, have %v", fname, constraint)
+			}
+
+			for name, content := range files {
+				if strings.HasSuffix(name, ".go") {
+					lintGo(t, name, content)
+				}
+			}
+		})
+	}
+}
+
+func webhookProto() *descriptorpb.FileDescriptorProto {
+	file := echoProto()
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		// service Echo
+		Path:            []int32{6, 0},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @webhook\n"),
+	})
+	return file
+}
+
+func TestGenerateWebhook(t *testing.T) {
+	files := runGenerator(t, nil, webhookProto())
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	lintGo(t, "echo.twirp.go", twirp)
+	for _, s := range []string{
+		"func NewEchoWebhookClient(addr string, client twirp.HTTPClient, config *twirp.WebhookConfig) Echo {",
+		"err := twirp.DoWebhookRequest(ctx, c.client, c.config, c.urls[1], in, out)",
+		"= (*echoWebhookClient)(nil)",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+
+	if twirp := runGenerator(t, nil)["sniper/rpc/echo/v1/echo.twirp.go"]; strings.Contains(twirp, "WebhookClient") {
+		t.Error("webhook client should be generated only with @webhook")
+	}
+
+	file := webhookProto()
+	file.Service[0].Method[0].ServerStreaming = proto.Bool(true)
+	file.SourceCodeInfo.Location[0].LeadingComments = nil
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		FileToGenerate: []string{file.GetName()},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	want := "echo.proto: @webhook is not supported by streaming method echo.v1.Echo.Hello"
+	if err := g.Generate(plugin); err == nil || err.Error() != want {
+		t.Fatalf("have error %v, want %q", err, want)
+	}
+}
+
+func TestGenerateOwnership(t *testing.T) {
+	file := echoProto()
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		// service Echo
+		Path:            []int32{6, 0},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @owner:alice\n @team:growth\n @oncall:growth-primary\n"),
+	})
+	loc := file.SourceCodeInfo.Location[1]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @team:infra\n")
+
+	files := runGenerator(t, func(g *twirp) { g.Docs = true }, file)
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		"},\n\t\tOwner:     \"alice\",\n\t\tTeam:      \"growth\",\n\t\tOncall:    \"growth-primary\",\n\t\tSource:    \"echo.proto\",\n\t\tGenerator: \"" + Version + "\",",
+		"Path:      EchoReloadPath,\n\t\tOwner:     \"alice\",\n\t\tTeam:      \"infra\",",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+	if docs := files["sniper/rpc/echo/v1/docs/Echo.md"]; !strings.Contains(docs, "- 负责人：alice\n- 团队：growth\n- 值班：growth-primary\n") {
+		t.Errorf("docs should contain the owners of Echo:\n%s", docs)
+	}
+}
+
+func strictProto() *descriptorpb.FileDescriptorProto {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @strict:external\n")
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		// message HelloRequest, field message
+		Path:            []int32{4, 0, 2, 0},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @min_len:1\n"),
+	}, &descriptorpb.SourceCodeInfo_Location{
+		// message HelloRequest, field age
+		Path:            []int32{4, 0, 2, 1},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @gte:1\n"),
+	})
+	return file
+}
+
+func TestGenerateStrictExternal(t *testing.T) {
+	files := runGenerator(t, func(g *twirp) {
+		g.ValidateEnable = true
+		g.UnknownFields = true
+	}, strictProto())
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		"strict := !twirp.InternalRequest(ctx)",
+		"if unknown := twirp.UnknownJSONFields(buf, reqContent); !strict && len(unknown) > 0 {",
+		"protojson.UnmarshalOptions{DiscardUnknown: !strict, Resolver: twirp.AnyResolver}",
+		"validate := reqContent.validate\n\tif !twirp.InternalRequest(ctx) {\n\t\tvalidate = reqContent.validateAll\n\t}",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+	if n := strings.Count(twirp, "validate = reqContent.validateAll"); n != 1 {
+		t.Errorf("only Hello should aggregate validation errors, got %d", n)
+	}
+
+	validate := files["sniper/rpc/echo/v1/echo.validate.go"]
+	lintGo(t, "echo.validate.go", validate)
+	for _, s := range []string{
+		"func (m *HelloRequest) validateAll() error {",
+		"type HelloRequestMultiError []error",
+	} {
+		if !strings.Contains(validate, s) {
+			t.Errorf("echo.validate.go should contain %q", s)
+		}
+	}
+
+	file := strictProto()
+	file.SourceCodeInfo.Location[0].LeadingComments = proto.String(" @strict:internal\n")
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		FileToGenerate: []string{file.GetName()},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	want := `echo.proto: invalid @strict of /echo.v1.Echo/Hello: "internal"`
+	if err := g.Generate(plugin); err == nil || err.Error() != want {
+		t.Fatalf("have error %v, want %q", err, want)
+	}
+}
+
+func TestGenerateUnknownFields(t *testing.T) {
+	file := echoProto()
+	// Reload 使用 @strict，其他接口只统计未知字段
+	file.SourceCodeInfo.Location[1].LeadingComments = proto.String(" @strict\n")
+	files := runGenerator(t, func(g *twirp) { g.UnknownFields = true }, file)
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	body := func(name string) string {
+		s := twirp[strings.Index(twirp, ") serve"+name+"JSON(ctx context.Context"):]
+		return s[:strings.Index(s, "\n}\n")]
+	}
+
+	hello := body("Hello")
+	for _, s := range []string{
+		"if unknown := twirp.UnknownJSONFields(buf, reqContent); len(unknown) > 0 {",
+		"ctx = twirp.WithUnknownFields(ctx, unknown)",
+		"protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: twirp.AnyResolver}",
+	} {
+		if !strings.Contains(hello, s) {
+			t.Errorf("serveHelloJSON should contain %q", s)
+		}
+	}
+	if strings.Index(hello, "twirp.UnknownJSONFields(") > strings.Index(hello, "unmarshaler.Unmarshal(buf, reqContent)") {
+		t.Error("unknown fields should be collected before the request is decoded")
+	}
+
+	// @strict 拒绝未知字段，不需要统计
+	reload := body("Reload")
+	if !strings.Contains(reload, "protojson.UnmarshalOptions{DiscardUnknown: false, Resolver: twirp.AnyResolver}") {
+		t.Error("@strict should reject unknown fields")
+	}
+	if strings.Contains(reload, "UnknownJSONFields") || strings.Contains(reload, "strict :=") {
+		t.Error("@strict should not collect unknown fields")
+	}
+}
+
+func TestGenerateGzip(t *testing.T) {
+	for _, c := range []struct {
+		minSize int64
+		inline  bool
+	}{{0, false}, {1024, false}, {1024, true}} {
+		files := runGenerator(t, func(g *twirp) {
+			g.GzipMinSize = c.minSize
+			g.MaxBodySize = 1 << 20
+			g.InlineServe = c.inline
+		})
+
+		twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+		gzip := "ctx, err = twirp.GzipRequest(ctx, req, " + strconv.FormatInt(c.minSize, 10) + ")"
+		i, j := strings.Index(twirp, gzip), strings.Index(twirp, "twirp.LimitRequestBody(req, 1048576)")
+		if i == -1 || j == -1 || i > j {
+			t.Errorf("%+v: request body should be decompressed before the size is limited", c)
+		}
+		if c.inline && !strings.Contains(twirp, "respBytes = twirp.CompressResponse(ctx, resp, respBytes)") {
+			t.Errorf("%+v: inline response should be compressed", c)
+		}
+	}
+}
+
+// encodeHTTPRule 编码 google.api.HttpRule，field 为 get、post 等字段的编号，additional 为 additional_bindings
+func encodeHTTPRule(field protowire.Number, pattern, body string, additional ...[]byte) []byte {
+	b := protowire.AppendTag(nil, field, protowire.BytesType)
+	b = protowire.AppendString(b, pattern)
+	if body != "" {
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		b = protowire.AppendString(b, body)
+	}
+	for _, a := range additional {
+		b = protowire.AppendTag(b, 11, protowire.BytesType)
+		b = protowire.AppendBytes(b, a)
+	}
+	return b
+}
+
+// restProto 给 echoProto 的 Hello 加上 google.api.http 注解
+func restProto(rule []byte) *descriptorpb.FileDescriptorProto {
+	file := echoProto()
+	opts := &descriptorpb.MethodOptions{}
+	b := protowire.AppendTag(nil, httpRuleExtension, protowire.BytesType)
+	opts.ProtoReflect().SetUnknown(protowire.AppendBytes(b, rule))
+	file.Service[0].Method[0].Options = opts
+	return file
+}
+
+func TestGenerateREST(t *testing.T) {
+	rule := encodeHTTPRule(2, "/v1/hello/{message}", "", encodeHTTPRule(4, "/v1/users/{user.user_id}/hello:send", "*"))
+	cases := map[string]func(g *twirp){
+		"default": nil,
+		"full": func(g *twirp) {
+			g.InlineServe = true
+			g.Routes = true
+			g.Docs = true
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			files := runGenerator(t, setup, restProto(rule))
+
+			twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+			for _, s := range []string{
+				"var EchoRESTPaths = []string{\n\t\"/v1/hello/\",\n\t\"/v1/users/\",\n}",
+				`twirp.NewRESTRoute("GET", "/v1/hello/{message}", EchoHelloPath, ""),`,
+				`twirp.NewRESTRoute("POST", "/v1/users/{user.user_id}/hello:send", EchoHelloPath, "*"),`,
+				"ctx = twirp.RouteREST(ctx, req, echoRESTRoutes)",
+			} {
+				if !strings.Contains(twirp, s) {
+					t.Errorf("echo.twirp.go should contain %q", s)
+				}
+			}
+			// inline_serve 时每种编码格式都要赋值路径变量
+			if n, want := strings.Count(twirp, "twirp.BindPathParams(ctx, reqContent)"), strings.Count(twirp, "impl.Hello(ctx, reqContent)"); n != want {
+				t.Errorf("only Hello should bind path params, have %d, want %d", n, want)
+			}
+			i, j := strings.Index(twirp, "twirp.RouteREST("), strings.Index(twirp, "req.Method != http.MethodPost")
+			if i == -1 || j == -1 || i > j {
+				t.Error("REST requests should be rewritten before the method is checked")
+			}
+
+			if routes, ok := files["sniper/rpc/echo/v1/echo.routes.json"]; ok && !strings.Contains(routes, `"GET /v1/hello/{message}"`) {
+				t.Errorf("routes should contain REST routes:\n%s", routes)
+			}
+			if docs, ok := files["sniper/rpc/echo/v1/docs/Echo.md"]; ok && !strings.Contains(docs, "- RESTful：`POST /v1/users/{user.user_id}/hello:send`") {
+				t.Errorf("docs should describe REST routes:\n%s", docs)
+			}
+
+			for name, content := range files {
+				if strings.HasSuffix(name, ".go") {
+					lintGo(t, name, content)
+				}
+			}
+		})
+	}
+
+	if twirp := runGenerator(t, nil)["sniper/rpc/echo/v1/echo.twirp.go"]; strings.Contains(twirp, "REST") {
+		t.Error("services without google.api.http should not have REST routes")
+	}
+}
+
+func TestGenerateRESTError(t *testing.T) {
+	for rule, want := range map[string]string{
+		string(encodeHTTPRule(2, "/v1/hello/{ids}", "")):                   "echo.proto: path variable ids of echo.v1.Echo.Hello must be a singular scalar field of echo.v1.HelloRequest",
+		string(encodeHTTPRule(2, "/v1/hello/{user}", "")):                  "echo.proto: path variable user of echo.v1.Echo.Hello must be a singular scalar field of echo.v1.HelloRequest",
+		string(encodeHTTPRule(4, "/v1/hello", "message")):                  `echo.proto: body "message" of echo.v1.Echo.Hello is not supported, use "*" or leave it empty`,
+		string(encodeHTTPRule(2, "v1/hello/{message}", "")):                `echo.proto: invalid google.api.http path "v1/hello/{message}" of echo.v1.Echo.Hello`,
+		string(encodeHTTPRule(2, "/v1/hello/x{message}", "")):              `echo.proto: invalid google.api.http path "/v1/hello/x{message}" of echo.v1.Echo.Hello`,
+		string(encodeHTTPRule(2, "/v1", "", encodeHTTPRule(2, "/v1", ""))): "echo.proto: GET /v1 of echo.v1.Echo.Hello conflicts with echo.v1.Echo.Hello",
+	} {
+		file := restProto([]byte(rule))
+		req := &pluginpb.CodeGeneratorRequest{
+			ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+			FileToGenerate: []string{file.GetName()},
+		}
+		plugin, err := protogen.Options{}.New(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := newGenerator()
+		g.OptionPrefix = "sniper"
+		g.TwirpPackage = "sniper/util/twirp"
+		if err := g.Generate(plugin); err == nil || err.Error() != want {
+			t.Errorf("have error %v, want %q", err, want)
+		}
+	}
+}
+
+func TestGenerateGET(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @get\n")
+	files := runGenerator(t, func(g *twirp) {
+		g.Routes = true
+		g.Docs = true
+	}, file)
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		"if (req.Method == http.MethodHead || req.Method == http.MethodOptions) && s.allowGET(ctx, req.URL.Path) {",
+		"twirp.WriteOptions(ctx, resp, s.hooks)",
+		"resp, finish = twirp.HeadResponse(resp, req)",
+		"ctx = twirp.WithResponseWriter(ctx, resp)",
+		"if req.Method != http.MethodPost && !s.allowGET(ctx, req.URL.Path) {",
+		"switch path {\n\tcase EchoHelloPath:\n\t\treturn true\n\t}\n\treturn twirp.AllowGET(ctx)",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+	if routes := strings.Join(strings.Fields(files["sniper/rpc/echo/v1/echo.routes.json"]), ""); !strings.Contains(routes, `"http_methods":["GET","HEAD","POST","OPTIONS"]`) {
+		t.Errorf("routes should contain GET methods:\n%s", files["sniper/rpc/echo/v1/echo.routes.json"])
+	}
+	if docs := files["sniper/rpc/echo/v1/docs/Echo.md"]; strings.Count(docs, "- GET 请求：") != 1 {
+		t.Errorf("only Hello should accept GET:\n%s", docs)
+	}
+
+	// 没有 @get 注解时只由 hooks 控制
+	twirp = runGenerator(t, nil)["sniper/rpc/echo/v1/echo.twirp.go"]
+	if !strings.Contains(twirp, "Context, path string) bool {\n\treturn twirp.AllowGET(ctx)\n}") {
+		t.Error("allowGET should only check twirp.AllowGET without @get")
+	}
+}
+
+func TestGenerateFormContentType(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @form_content_type:text/html;charset=utf-8\n")
+	for _, inline := range []bool{false, true} {
+		files := runGenerator(t, func(g *twirp) {
+			g.FormContentType = "application/json;charset=utf-8"
+			g.InlineServe = inline
+		}, file)
+
+		twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+		for _, s := range []string{
+			") serveHelloForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {\n\tvar err error\n\tctx = twirp.WithResponseContentType(ctx, \"text/html;charset=utf-8\")",
+			") serveReloadForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {\n\tvar err error\n\tctx = twirp.WithResponseContentType(ctx, \"application/json;charset=utf-8\")",
+		} {
+			if !strings.Contains(twirp, s) {
+				t.Errorf("inline=%v: echo.twirp.go should contain %q", inline, s)
+			}
+		}
+		if n := strings.Count(twirp, "twirp.WithResponseContentType("); n != 2 {
+			t.Errorf("inline=%v: only form requests should force Content-Type, have %d", inline, n)
+		}
+	}
+
+	loc.LeadingComments = proto.String(" @form_content_type:json\n")
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		FileToGenerate: []string{file.GetName()},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	want := `echo.proto: invalid @form_content_type of /echo.v1.Echo/Hello: "json"`
+	if err := g.Generate(plugin); err == nil || err.Error() != want {
+		t.Errorf("have error %v, want %q", err, want)
+	}
+}
+
+func TestGenerateChunkedResponse(t *testing.T) {
+	for _, inline := range []bool{false, true} {
+		files := runGenerator(t, func(g *twirp) {
+			g.ChunkMinSize = 1 << 20
+			g.InlineServe = inline
+		})
+
+		twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+		want := "case \"application/protobuf\":\n\t\tctx = twirp.WithChunkedResponse(ctx, 1048576)\n\t\ts.serveHelloProtobuf(ctx, resp, req)"
+		if !strings.Contains(twirp, want) {
+			t.Errorf("inline=%v: protobuf requests should be marked as chunked", inline)
+		}
+		if strings.Count(twirp, "twirp.WithChunkedResponse(") != 2 {
+			t.Errorf("inline=%v: only protobuf requests should be marked as chunked", inline)
+		}
+		if inline && strings.Contains(twirp, "respBytes, err = proto.Marshal(respContent)") {
+			t.Error("inline protobuf responses should be written by twirp.WriteResponse")
+		}
+	}
+}
+
+func TestGenerateRouteBeforeDecode(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @dedup\n @internal\n")
+	files := runGenerator(t, func(g *twirp) { g.MaxBodySize = 1 << 20 }, file)
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	serve := twirp[strings.Index(twirp, ") serveHello(ctx context.Context"):]
+	serve = serve[:strings.Index(serve, "\n}\n")]
+	last := -1
+	for _, s := range []string{
+		`ctx = twirp.WithMethodName(ctx, "Hello")`,
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		"if !twirp.InternalRequest(ctx) {",
+		"twirp.ReplayDedup(ctx, resp, EchoHelloPath)",
+		"twirp.GzipRequest(ctx, req, 0)",
+		"twirp.LimitRequestBody(req, 1048576)",
+		"s.serveHelloJSON(ctx, resp, req)",
+	} {
+		i := strings.Index(serve, s)
+		if i <= last {
+			t.Errorf("serveHello should contain %q after the previous steps", s)
+			continue
+		}
+		last = i
+	}
+
+	json := twirp[strings.Index(twirp, ") serveHelloJSON(ctx context.Context"):]
+	json = json[:strings.Index(json, "\n}\n")]
+	if strings.Contains(json, "CallRequestRouted") || strings.Contains(json, "InternalRequest") {
+		t.Error("serveHelloJSON should not call RequestRouted again")
+	}
+}
+
+func TestGenerateResponseCache(t *testing.T) {
+	for _, inline := range []bool{false, true} {
+		file := echoProto()
+		loc := file.SourceCodeInfo.Location[0]
+		loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @cache:30s\n")
+		files := runGenerator(t, func(g *twirp) {
+			g.InlineServe = inline
+			g.MessagePool = true
+		}, file)
+
+		twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+		if !strings.Contains(twirp, "var echoServerHelloResponseCache = twirp.NewResponseCache[*HelloResponse](EchoHelloPath, 30000000000) // 30s") {
+			t.Errorf("inline=%v: response cache of Hello should be declared", inline)
+		}
+		if !strings.Contains(twirp, "respContent, err = twirp.CachedCall(ctx, echoServerHelloResponseCache, EchoHelloPath, reqContent, func(ctx context.Context) (*HelloResponse, error) {") {
+			t.Errorf("inline=%v: Hello should be called through the response cache", inline)
+		}
+		if strings.Contains(twirp, "echoServerHelloResponsePool.Put(respContent)") {
+			t.Errorf("inline=%v: cached responses should not be put back to the pool", inline)
+		}
+	}
+
+	file := echoProto()
+	file.SourceCodeInfo.Location[0].LeadingComments = proto.String(" @cache:soon\n")
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		FileToGenerate: []string{file.GetName()},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	want := `echo.proto: invalid @cache of /echo.v1.Echo/Hello: "soon"`
+	if err := g.Generate(plugin); err == nil || err.Error() != want {
+		t.Fatalf("have error %v, want %q", err, want)
+	}
+}
+
+func TestGenerateAuthBeforeDecode(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @auth\n @quota:daily_hello=100\n")
+	files := runGenerator(t, func(g *twirp) { g.ValidateEnable = true }, file)
+
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	serve := twirp[strings.Index(twirp, ") serveHello(ctx context.Context"):]
+	serve = serve[:strings.Index(serve, "\n}\n")]
+	last := -1
+	for _, s := range []string{
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		`twirp.MarkServerTiming(ctx, "route")`,
+		"if ctxkit.GetUserID(ctx) == 0 {",
+		`if err := twirp.CheckQuota(ctx, "daily_hello", 100); err != nil {`,
+		`twirp.MarkServerTiming(ctx, "auth")`,
+		"twirp.GzipRequest(ctx, req, 0)",
+		"s.serveHelloJSON(ctx, resp, req)",
+	} {
+		i := strings.Index(serve, s)
+		if i <= last {
+			t.Errorf("serveHello should contain %q after the previous steps", s)
+			continue
+		}
+		last = i
+	}
+
+	json := twirp[strings.Index(twirp, ") serveHelloJSON(ctx context.Context"):]
+	json = json[:strings.Index(json, "\n}\n")]
+	if strings.Contains(json, "GetUserID") || strings.Contains(json, "CheckQuota") {
+		t.Error("serveHelloJSON should not check login and quota after decoding")
+	}
+}
+
+// serveFunc 返回生成代码中 name 函数的函数体
+func serveFunc(code, name string) string {
+	code = code[strings.Index(code, ") "+name+"(ctx context.Context"):]
+	return code[:strings.Index(code, "\n}\n")]
+}
+
+// assertSteps 检查 name 函数体 code 按顺序包含 steps
+func assertSteps(t *testing.T, name, code string, steps ...string) {
+	t.Helper()
+
+	last := -1
+	for _, s := range steps {
+		i := strings.Index(code, s)
+		if i <= last {
+			t.Errorf("%s should contain %q after the previous steps", name, s)
+			continue
+		}
+		last = i
+	}
+}
+
+func TestGenerateFlag(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @flag:new_hello\n")
+	// 服务注解对没有 @flag 的方法生效
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		Path:            []int32{6, 0},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @flag:echo\n"),
+	})
+	twirp := runGenerator(t, nil, file)["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	assertSteps(t, "serveHello", serveFunc(twirp, "serveHello"),
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		`if !twirp.FlagEnabled(ctx, "new_hello") {`,
+		`s.writeError(ctx, resp, twirp.NewError(twirp.Unimplemented, "feature new_hello is not enabled"))`,
+		`twirp.MarkServerTiming(ctx, "route")`,
+		"twirp.GzipRequest(ctx, req, 0)",
+		"s.serveHelloJSON(ctx, resp, req)",
+	)
+	assertSteps(t, "serveReload", serveFunc(twirp, "serveReload"),
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		`if !twirp.FlagEnabled(ctx, "echo") {`,
+		`"feature echo is not enabled"`,
+		"s.serveReloadJSON(ctx, resp, req)",
+	)
+	if strings.Count(twirp, "twirp.FlagEnabled(") != 2 {
+		t.Error("method @flag should override the service one")
+	}
+	if strings.Contains(serveFunc(twirp, "serveHelloJSON"), "FlagEnabled") {
+		t.Error("serveHelloJSON should not check the flag after decoding")
+	}
+}
+
+func TestGenerateExperiment(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @experiment:hello_v2\n @experiment\n")
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		Path:            []int32{6, 0},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @experiment:echo_ranking\n"),
+	})
+	twirp := runGenerator(t, nil, file)["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	// 服务的实验在前，方法的实验在后，空的 @experiment 忽略
+	assertSteps(t, "serveHello", serveFunc(twirp, "serveHello"),
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		`ctx = twirp.WithExperiment(ctx, "echo_ranking")`,
+		`ctx = twirp.WithExperiment(ctx, "hello_v2")`,
+		`twirp.MarkServerTiming(ctx, "route")`,
+		"s.serveHelloJSON(ctx, resp, req)",
+	)
+	assertSteps(t, "serveReload", serveFunc(twirp, "serveReload"),
+		`ctx = twirp.WithExperiment(ctx, "echo_ranking")`,
+		"s.serveReloadJSON(ctx, resp, req)",
+	)
+	if strings.Contains(twirp, `twirp.WithExperiment(ctx, "")`) {
+		t.Error("empty @experiment should be ignored")
+	}
+	if strings.Contains(serveFunc(twirp, "serveHelloJSON"), "WithExperiment") {
+		t.Error("serveHelloJSON should not assign experiments after decoding")
+	}
+}
+
+func TestGenerateShadow(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @shadow:echo-v2\n @shadow\n")
+	twirp := runGenerator(t, nil, file)["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	// 影子流量需要解析之后的请求和业务方法的响应，在调用业务方法之后、返回错误之前复制
+	assertSteps(t, "serveHelloJSON", serveFunc(twirp, "serveHelloJSON"),
+		"unmarshaler.Unmarshal(buf, reqContent)",
+		"s.handleHello(ctx, resp, reqContent, twirp.MarshalJSON)",
+	)
+	assertSteps(t, "handleHello", serveFunc(twirp, "handleHello"),
+		"respContent, err = impl.Hello(ctx, reqContent)",
+		`twirp.MarkServerTiming(ctx, "handler")`,
+		`twirp.Shadow(ctx, "echo-v2", reqContent, respContent, err)`,
+		"err = twirp.CheckErrorCode(EchoHelloPath, err)",
+		"s.writeError(ctx, resp, err)",
+	)
+	for _, name := range []string{"serveHello", "serveHelloJSON"} {
+		if strings.Contains(serveFunc(twirp, name), "twirp.Shadow(") {
+			t.Errorf("%s should not copy traffic before calling Hello", name)
+		}
+	}
+	if strings.Contains(twirp, `twirp.Shadow(ctx, "",`) {
+		t.Error("empty @shadow should be ignored")
+	}
+	if strings.Contains(serveFunc(twirp, "handleReload"), "twirp.Shadow(") {
+		t.Error("Reload should not copy traffic")
+	}
+}
+
+func TestGenerateOriginInternal(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @origin: app, web\n")
+	twirp := runGenerator(t, nil, file)["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	assertSteps(t, "serveHello", serveFunc(twirp, "serveHello"),
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		`if !twirp.OriginAllowed(ctx, "app", "web") {`,
+		`s.writeError(ctx, resp, twirp.NewError(twirp.PermissionDenied, "origin is not allowed"))`,
+		`twirp.MarkServerTiming(ctx, "route")`,
+		"twirp.GzipRequest(ctx, req, 0)",
+		"s.serveHelloJSON(ctx, resp, req)",
+	)
+	if strings.Contains(serveFunc(twirp, "serveHello"), "InternalRequest") {
+		t.Error("Hello is not an internal method")
+	}
+
+	// Reload 有 @internal 注解
+	assertSteps(t, "serveReload", serveFunc(twirp, "serveReload"),
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		"if !twirp.InternalRequest(ctx) {",
+		`s.writeError(ctx, resp, twirp.NewError(twirp.PermissionDenied, "internal method"))`,
+		`twirp.MarkServerTiming(ctx, "route")`,
+		"twirp.LimitRequestBody(req, 1024)",
+		"s.serveReloadJSON(ctx, resp, req)",
+	)
+	if strings.Contains(serveFunc(twirp, "serveReload"), "OriginAllowed") {
+		t.Error("Reload should not check origin")
+	}
+	for _, name := range []string{"serveHelloJSON", "serveReloadJSON"} {
+		json := serveFunc(twirp, name)
+		if strings.Contains(json, "OriginAllowed") || strings.Contains(json, "InternalRequest") {
+			t.Errorf("%s should not check the caller after decoding", name)
+		}
+	}
+}
+
+func TestGenerateTenant(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @tenant\n @flag:new_hello\n")
+	twirp := runGenerator(t, nil, file)["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	// 没有租户的请求在功能开关之前拒绝
+	assertSteps(t, "serveHello", serveFunc(twirp, "serveHello"),
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		`if ctxkit.GetTenantID(ctx) == "" {`,
+		`s.writeError(ctx, resp, twirp.NewError(twirp.Unauthenticated, "tenant id is required"))`,
+		`if !twirp.FlagEnabled(ctx, "new_hello") {`,
+		`twirp.MarkServerTiming(ctx, "route")`,
+		"s.serveHelloJSON(ctx, resp, req)",
+	)
+	if !strings.Contains(twirp, `"sniper/util/ctxkit"`) {
+		t.Error("ctxkit should be imported")
+	}
+	if strings.Contains(serveFunc(twirp, "serveReload"), "GetTenantID") {
+		t.Error("Reload does not require tenant")
+	}
+	if strings.Contains(serveFunc(twirp, "serveHelloJSON"), "GetTenantID") {
+		t.Error("serveHelloJSON should not check tenant after decoding")
+	}
+}
+
+// generateFail 生成代码并返回生成失败的错误
+func generateFail(t *testing.T, file *descriptorpb.FileDescriptorProto) error {
+	t.Helper()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+		FileToGenerate: []string{file.GetName()},
+	}
+	plugin, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newGenerator()
+	g.OptionPrefix = "sniper"
+	g.TwirpPackage = "sniper/util/twirp"
+	return g.Generate(plugin)
+}
+
+func TestGeneratePriority(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @priority:critical\n")
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		Path:            []int32{6, 0},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @priority:batch\n"),
+	})
+	twirp := runGenerator(t, nil, file)["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	// 优先级在 RequestRouted 之前设置，降级钩子根据优先级丢弃请求
+	assertSteps(t, "serveHello", serveFunc(twirp, "serveHello"),
+		"ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityCritical)",
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		"twirp.GzipRequest(ctx, req, 0)",
+		"s.serveHelloJSON(ctx, resp, req)",
+	)
+	assertSteps(t, "serveReload", serveFunc(twirp, "serveReload"),
+		"ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityBatch)",
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		"s.serveReloadJSON(ctx, resp, req)",
+	)
+	if strings.Contains(serveFunc(twirp, "serveHelloJSON"), "WithRequestPriority") {
+		t.Error("serveHelloJSON should not set priority after decoding")
+	}
+
+	// 没有注解时为默认优先级
+	twirp = runGenerator(t, nil)["sniper/rpc/echo/v1/echo.twirp.go"]
+	if !strings.Contains(serveFunc(twirp, "serveHello"), "ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityDefault)") {
+		t.Error("Hello should have default priority")
+	}
+
+	file = echoProto()
+	file.SourceCodeInfo.Location[0].LeadingComments = proto.String(" @priority:high\n")
+	want := `echo.proto: invalid @priority of /echo.v1.Echo/Hello: "high"`
+	if err := generateFail(t, file); err == nil || err.Error() != want {
+		t.Fatalf("have error %v, want %q", err, want)
+	}
+}
+
+func TestGenerateDeprecated(t *testing.T) {
+	file := echoProto()
+	loc := file.SourceCodeInfo.Location[0]
+	loc.LeadingComments = proto.String(loc.GetLeadingComments() + " @deprecated:use echo.v2.Echo/Hello\n @sunset:2026-12-31\n")
+	file.Service[0].Method[1].Options = &descriptorpb.MethodOptions{Deprecated: proto.Bool(true)}
+	twirp := runGenerator(t, nil, file)["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	// 路由之后、解析请求之前标记废弃响应头，@sunset 转换为 HTTP 日期格式
+	assertSteps(t, "serveHello", serveFunc(twirp, "serveHello"),
+		"ctx, err = s.hooks.CallRequestRouted(ctx)",
+		`ctx = twirp.MarkDeprecated(ctx, resp, EchoHelloPath, "echo.v2.Echo/Hello", "Thu, 31 Dec 2026 00:00:00 GMT")`,
+		`twirp.MarkServerTiming(ctx, "route")`,
+		"twirp.GzipRequest(ctx, req, 0)",
+		"s.serveHelloJSON(ctx, resp, req)",
+	)
+	// option deprecated = true 没有替代接口和下线时间
+	assertSteps(t, "serveReload", serveFunc(twirp, "serveReload"),
+		"if !twirp.InternalRequest(ctx) {",
+		`ctx = twirp.MarkDeprecated(ctx, resp, EchoReloadPath, "", "")`,
+		"s.serveReloadJSON(ctx, resp, req)",
+	)
+	if strings.Contains(serveFunc(twirp, "serveHelloJSON"), "MarkDeprecated") {
+		t.Error("serveHelloJSON should not mark deprecation after decoding")
+	}
+
+	twirp = runGenerator(t, nil)["sniper/rpc/echo/v1/echo.twirp.go"]
+	if strings.Contains(twirp, "MarkDeprecated") {
+		t.Error("methods are not deprecated")
+	}
+
+	file = echoProto()
+	file.SourceCodeInfo.Location[0].LeadingComments = proto.String(" @deprecated\n @sunset:2026/12/31\n")
+	want := `echo.proto: invalid @sunset "2026/12/31" of echo.v1.Echo.Hello, should be like 2006-01-02`
+	if err := generateFail(t, file); err == nil || err.Error() != want {
+		t.Fatalf("have error %v, want %q", err, want)
+	}
+}
+
+func TestGenerateMessagePool(t *testing.T) {
+	file := echoProto()
+	file.Service[0].Method = append(file.Service[0].Method, &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String("Mirror"),
+		InputType:  proto.String(".echo.v1.User"),
+		OutputType: proto.String(".echo.v1.User"),
+	})
+	files := runGenerator(t, func(g *twirp) { g.MessagePool = true }, file)
+	twirp := files["sniper/rpc/echo/v1/echo.twirp.go"]
+
+	for _, s := range []string{
+		"reqContent := echoServerHelloRequestPool.Get().(*HelloRequest)",
+		"reqContent.Reset()\n\t\techoServerHelloRequestPool.Put(reqContent)",
+		"respContent.Reset()\n\techoServerHelloResponsePool.Put(respContent)",
+		"func GetEchoHelloResponse() *HelloResponse {",
+	} {
+		if !strings.Contains(twirp, s) {
+			t.Errorf("generated code should contain %q", s)
+		}
+	}
+
+	// 直接返回请求对象时不能再放入响应对象池
+	i := strings.Index(twirp, "if respContent == reqContent {")
+	j := strings.Index(twirp, "echoServerMirrorResponsePool.Put(respContent)")
+	if i == -1 || j == -1 || i > j {
+		t.Error("response of Mirror should not be put back when it is the request")
+	}
+	if strings.Count(twirp, "if respContent == reqContent {") != 1 {
+		t.Error("only Mirror has the same request and response type")
+	}
+}
+
+func TestGatewayDuration(t *testing.T) {
+	cases := []struct {
+		d            time.Duration
+		envoy, nginx string
+	}{
+		{5 * time.Second, "5s", "5s"},
+		{time.Minute + 5*time.Second, "65s", "65s"},
+		{500 * time.Millisecond, "0.5s", "500ms"},
+		{1500 * time.Millisecond, "1.5s", "1500ms"},
+		{1500 * time.Microsecond, "0.0015s", "2ms"},
+	}
+	for _, c := range cases {
+		if s := envoyDuration(c.d); s != c.envoy {
+			t.Errorf("envoyDuration(%s) = %s, want %s", c.d, s, c.envoy)
+		}
+		if s := nginxDuration(c.d); s != c.nginx {
+			t.Errorf("nginxDuration(%s) = %s, want %s", c.d, s, c.nginx)
+		}
+	}
+}
+
+func TestGenerateGatewayTimeout(t *testing.T) {
+	file := echoProto()
+	file.SourceCodeInfo.Location[0].LeadingComments = proto.String(" @timeout:1m5s\n")
+	file.SourceCodeInfo.Location[1].LeadingComments = proto.String(" @timeout:500ms\n @retry:2\n")
+
+	for gateway, want := range map[string][]string{
+		"envoy": {
+			"path: /echo.v1.Echo/Hello\n  route:\n    cluster: sniper\n    timeout: 65s\n",
+			"path: /echo.v1.Echo/Reload\n  route:\n    cluster: sniper\n    timeout: 0.5s\n",
+		},
+		"nginx": {
+			"location = /echo.v1.Echo/Hello {\n    proxy_pass http://sniper;\n    proxy_read_timeout 65s;\n",
+			"location = /echo.v1.Echo/Reload {\n    proxy_pass http://sniper;\n    proxy_read_timeout 500ms;\n",
+		},
+	} {
+		files := runGenerator(t, func(g *twirp) { g.Gateway = gateway }, file)
+		name := "sniper/rpc/echo/v1/echo.envoy.yaml"
+		if gateway == "nginx" {
+			name = "sniper/rpc/echo/v1/echo.nginx.conf"
+		}
+		for _, s := range want {
+			if !strings.Contains(files[name], s) {
+				t.Errorf("%s should contain %q:\n%s", name, s, files[name])
+			}
+		}
+	}
+}
+
+func TestGenerateTwitchCompat(t *testing.T) {
+	file := echoProto()
+	file.SourceCodeInfo.Location = append(file.SourceCodeInfo.Location, &descriptorpb.SourceCodeInfo_Location{
+		// service Echo
+		Path:            []int32{6, 0},
+		Span:            []int32{0, 0, 0},
+		LeadingComments: proto.String(" @problem\n"),
+	})
+	files := runGenerator(t, func(g *twirp) { g.Compat = "twitch" }, file)
+
+	code := files["sniper/rpc/echo/v1/echo.twirp.go"]
+	for _, s := range []string{
+		`const EchoPathPrefix = "/twirp/echo.v1.Echo/"`,
+		"ctx = twirp.WithTwitchCompat(ctx)",
+	} {
+		if !strings.Contains(code, s) {
+			t.Errorf("echo.twirp.go should contain %q", s)
+		}
+	}
+	for _, s := range []string{"WithProblemDetails", "serveHelloForm"} {
+		if strings.Contains(code, s) {
+			t.Errorf("echo.twirp.go should not contain %q", s)
+		}
+	}
+
+	for name, setup := range map[string]func(g *twirp){
+		"empty_no_content": func(g *twirp) { g.EmptyNoContent = true },
+		"chunk_min_size":   func(g *twirp) { g.ChunkMinSize = 1 << 20 },
+	} {
+		plugin, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+			ProtoFile:      []*descriptorpb.FileDescriptorProto{echoProto()},
+			FileToGenerate: []string{"echo.proto"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := newGenerator()
+		g.OptionPrefix = "sniper"
+		g.TwirpPackage = "sniper/util/twirp"
+		g.Compat = "twitch"
+		setup(g)
+		want := name + " is not supported by compat=twitch"
+		if err := g.Generate(plugin); err == nil || err.Error() != want {
+			t.Errorf("have error %v, want %q", err, want)
+		}
+	}
+}
+
+func TestGenerateMessages(t *testing.T) {
+	files := runGenerator(t, func(g *twirp) { g.Messages = true })
+
+	pb, ok := files["sniper/rpc/echo/v1/echo.pb.go"]
+	if !ok {
+		t.Fatal("messages=true should generate echo.pb.go")
+	}
+	// internal_gengo 没有兼容性保证，升级 google.golang.org/protobuf 需要同时修改 GengoVersion
+	if !strings.Contains(pb, "protoc-gen-go "+GengoVersion+"\n") {
+		t.Errorf("echo.pb.go is not generated by protoc-gen-go %s:\n%s", GengoVersion, pb[:strings.Index(pb, "package ")])
+	}
+}
+
+func TestBufPluginVersion(t *testing.T) {
+	b, err := ioutil.ReadFile("buf.plugin.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := string(b)
+	for _, s := range []string{
+		"plugin_version: " + Version + "\n",
+		"module: google.golang.org/protobuf\n        version: " + GengoVersion + "\n",
+	} {
+		if !strings.Contains(conf, s) {
+			t.Errorf("buf.plugin.yaml should contain %q", s)
+		}
+	}
+}
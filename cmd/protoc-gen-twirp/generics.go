@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateGenerics 生成 {file}.generics.go
+//
+// 提供基于泛型的 New{Service}TypedServer 构造函数，可以按接口方法注册强类型的
+// 拦截函数，中间件不再需要对 twirp.Request 做类型断言。需要 Go 1.18 及以上版本。
+func (t *twirp) generateGenerics(file *protogen.File) {
+	api := t.apiPackage(file)
+	t.generateBuildConstraint(artifactGenerics, "go1.18")
+	t.P("// Package ", string(api.name), " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.Desc.Path())
+	t.P(`package `, string(api.name))
+	t.P()
+	t.P(`import `, t.pkgs["context"], ` "context"`)
+	t.P()
+	t.P(`import `, t.pkgs["twirp"], fmt.Sprintf(` "%s"`, t.TwirpPackage))
+	t.P()
+	t.generateDepImports()
+	for _, service := range file.Services {
+		t.generateTypedServer(service)
+	}
+
+	fname := api.filenamePrefix + ".generics.go"
+	t.writeFile(fname, t.output.Bytes(), true)
+	t.output.Reset()
+}
+
+func (t *twirp) generateTypedServer(service *protogen.Service) {
+	servName := service.GoName
+	option := servName + "ServerOption"
+	config := unexported(servName) + "ServerConfig"
+	typed := unexported(servName) + "TypedService"
+	ctx := t.pkgs["context"] + ".Context"
+
+	t.P(`type `, config, ` struct {`)
+	t.P(`  hooks *`, t.pkgs["twirp"], `.ServerHooks`)
+	for _, method := range service.Methods {
+		t.P(`  `, unexported(method.GoName), ` []func(`, ctx, `, *`, t.getType(method.Input), `) error`)
+	}
+	t.P(`}`)
+	t.P()
+	t.P(`// `, option, ` configures the server created by New`, servName, `TypedServer.`)
+	t.P(`type `, option, ` func(*`, config, `)`)
+	t.P()
+	t.P(`// With`, servName, `Hooks sets the server hooks.`)
+	t.P(`func With`, servName, `Hooks(hooks *`, t.pkgs["twirp"], `.ServerHooks) `, option, ` {`)
+	t.P(`  return func(c *`, config, `) { c.hooks = hooks }`)
+	t.P(`}`)
+	t.P()
+	for _, method := range service.Methods {
+		name := unexported(method.GoName)
+		t.P(`// On`, servName, method.GoName, ` adds an interceptor of `, method.GoName, `, which is called after`)
+		t.P(`// the request is validated. If it returns an error, `, method.GoName, ` is not called.`)
+		t.P(`func On`, servName, method.GoName, `(fn func(`, ctx, `, *`, t.getType(method.Input), `) error) `, option, ` {`)
+		t.P(`  return func(c *`, config, `) { c.`, name, ` = append(c.`, name, `, fn) }`)
+		t.P(`}`)
+		t.P()
+	}
+
+	t.P(`// New`, servName, `TypedServer creates a server of svc like New`, servName, `Server,`)
+	t.P(`// with typed interceptors registered by On`, servName, `{Method} options.`)
+	t.P(`func New`, servName, `TypedServer[T `, servName, `](svc T, opts ...`, option, `) `, t.pkgs["twirp"], `.Server {`)
+	t.P(`  c := &`, config, `{}`)
+	t.P(`  for _, opt := range opts {`)
+	t.P(`    opt(c)`)
+	t.P(`  }`)
+	t.P(`  return New`, servName, `Server(&`, typed, `[T]{svc: svc, config: c}, c.hooks)`)
+	t.P(`}`)
+	t.P()
+	t.P(`type `, typed, `[T `, servName, `] struct {`)
+	t.P(`  svc    T`)
+	t.P(`  config *`, config)
+	t.P(`}`)
+	t.P()
+	for _, method := range service.Methods {
+		if isWebSocket(method) {
+			t.generateTypedReceiver(service, method, typed)
+			continue
+		}
+		if isServerStreaming(method) {
+			t.P(`func (s *`, typed, `[T]) `, method.GoName, `(ctx `, ctx, `, req *`, t.getType(method.Input), `, sender `, streamSender(service, method), `) error {`)
+			t.P(`  for _, fn := range s.config.`, unexported(method.GoName), ` {`)
+			t.P(`    if err := fn(ctx, req); err != nil {`)
+			t.P(`      return err`)
+			t.P(`    }`)
+			t.P(`  }`)
+			t.P(`  return s.svc.`, method.GoName, `(ctx, req, sender)`)
+			t.P(`}`)
+			t.P()
+			continue
+		}
+		t.P(`func (s *`, typed, `[T]) `, method.GoName, `(ctx `, ctx, `, req *`, t.getType(method.Input), `) (*`, t.getType(method.Output), `, error) {`)
+		t.P(`  for _, fn := range s.config.`, unexported(method.GoName), ` {`)
+		t.P(`    if err := fn(ctx, req); err != nil {`)
+		t.P(`      return nil, err`)
+		t.P(`    }`)
+		t.P(`  }`)
+		t.P(`  return s.svc.`, method.GoName, `(ctx, req)`)
+		t.P(`}`)
+		t.P()
+	}
+}
+
+// generateTypedReceiver 生成客户端流式和双向流式接口的强类型包装，拦截函数在收到每条请求时调用
+func (t *twirp) generateTypedReceiver(service *protogen.Service, method *protogen.Method, typed string) {
+	ctx := t.pkgs["context"] + ".Context"
+	inputType := t.getType(method.Input)
+	receiver := streamReceiver(service, method)
+	wrapper := unexported(service.GoName) + method.GoName + "TypedReceiver"
+
+	t.P(`type `, wrapper, ` struct {`)
+	t.P(`  `, receiver)
+	t.P(`  ctx `, ctx)
+	t.P(`  fns []func(`, ctx, `, *`, inputType, `) error`)
+	t.P(`}`)
+	t.P()
+	t.P(`func (r `, wrapper, `) Recv() (*`, inputType, `, error) {`)
+	t.P(`  in, err := r.`, receiver, `.Recv()`)
+	t.P(`  if err != nil {`)
+	t.P(`    return nil, err`)
+	t.P(`  }`)
+	t.P(`  for _, fn := range r.fns {`)
+	t.P(`    if err := fn(r.ctx, in); err != nil {`)
+	t.P(`      return nil, err`)
+	t.P(`    }`)
+	t.P(`  }`)
+	t.P(`  return in, nil`)
+	t.P(`}`)
+	t.P()
+
+	typedReceiver := wrapper + `{` + receiver + `: receiver, ctx: ctx, fns: s.config.` + unexported(method.GoName) + `}`
+	if method.Desc.IsStreamingServer() {
+		t.P(`func (s *`, typed, `[T]) `, method.GoName, `(ctx `, ctx, `, receiver `, receiver, `, sender `, streamSender(service, method), `) error {`)
+		t.P(`  return s.svc.`, method.GoName, `(ctx, `, typedReceiver, `, sender)`)
+	} else {
+		t.P(`func (s *`, typed, `[T]) `, method.GoName, `(ctx `, ctx, `, receiver `, receiver, `) (*`, t.getType(method.Output), `, error) {`)
+		t.P(`  return s.svc.`, method.GoName, `(ctx, `, typedReceiver, `)`)
+	}
+	t.P(`}`)
+	t.P()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/golden/out")
+
+const (
+	// goldenDir fixture 所在目录，生成结果保存在 out 子目录
+	goldenDir = "testdata/golden"
+	// goldenModule fixture 的 go_package 前缀
+	goldenModule = "sniper/fixture"
+	// goldenParams 生成 golden 文件使用的插件参数
+	goldenParams = "messages=true,validate_enable=true,routes=true,graphql=true,generics=true"
+)
+
+// goldenFixtures 参与测试的 proto 文件，路径相对于 goldenDir
+var goldenFixtures = []string{
+	"scalars.proto",
+	"collections.proto",
+	"common/user.proto",
+	"multi.proto",
+}
+
+// compileFixtures 使用 protoc 编译 fixture，返回包含依赖和源码信息的描述，没有安装 protoc 时跳过测试
+func compileFixtures(t *testing.T) []*descriptorpb.FileDescriptorProto {
+	t.Helper()
+
+	protoc, err := exec.LookPath("protoc")
+	if err != nil {
+		t.Skip("protoc is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "fixtures.pb")
+	args := []string{"-I", goldenDir, "--include_imports", "--include_source_info", "--descriptor_set_out=" + out}
+	cmd := exec.Command(protoc, append(args, goldenFixtures...)...)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("protoc: %v\n%s", err, b)
+	}
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		t.Fatal(err)
+	}
+	return set.File
+}
+
+// generateFixtures 按照 protoc 调用插件的方式生成代码
+func generateFixtures(t *testing.T, files []*descriptorpb.FileDescriptorProto) map[string]string {
+	t.Helper()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: goldenFixtures,
+		Parameter:      proto.String(goldenParams),
+		ProtoFile:      files,
+	}
+
+	g := newGenerator()
+	var flags flag.FlagSet
+	registerFlags(&flags, g)
+
+	plugin, err := protogen.Options{ParamFunc: flags.Set}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Generate(plugin); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := plugin.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+
+	out := map[string]string{}
+	for _, f := range resp.File {
+		out[f.GetName()] = f.GetContent()
+	}
+	return out
+}
+
+// TestGoldenFixtures 检查 fixtureFiles 与 protoc 编译 fixture 的结果一致
+func TestGoldenFixtures(t *testing.T) {
+	want := compileFixtures(t)
+	got := fixtureFiles()
+	if len(got) != len(want) {
+		t.Fatalf("fixtureFiles returns %d files, protoc returns %d", len(got), len(want))
+	}
+
+	for i, w := range want {
+		g := proto.Clone(got[i]).(*descriptorpb.FileDescriptorProto)
+		w = proto.Clone(w).(*descriptorpb.FileDescriptorProto)
+		if gc, wc := fixtureLeadingComments(g), fixtureLeadingComments(w); !reflect.DeepEqual(gc, wc) {
+			t.Errorf("comments of %s differ:\n got: %q\nwant: %q", w.GetName(), gc, wc)
+		}
+		g.SourceCodeInfo, w.SourceCodeInfo = nil, nil
+		if !proto.Equal(g, w) {
+			t.Errorf("%s differs from protoc:\n got: %s\nwant: %s", w.GetName(), prototext.Format(g), prototext.Format(w))
+		}
+	}
+}
+
+// fixtureLeadingComments 返回 leading comments，key 为位置的路径
+func fixtureLeadingComments(f *descriptorpb.FileDescriptorProto) map[string]string {
+	comments := map[string]string{}
+	for _, loc := range f.GetSourceCodeInfo().GetLocation() {
+		if loc.LeadingComments != nil {
+			comments[fmt.Sprint(loc.Path)] = loc.GetLeadingComments()
+		}
+	}
+	return comments
+}
+
+func TestGolden(t *testing.T) {
+	files := generateFixtures(t, fixtureFiles())
+
+	outDir := filepath.Join(goldenDir, "out")
+	if *update {
+		if err := os.RemoveAll(outDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, content := range files {
+		golden := filepath.Join(outDir, filepath.FromSlash(name))
+		if *update {
+			if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(golden, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Errorf("%s: %v, run go test -run TestGolden -update to create it", name, err)
+			continue
+		}
+		if !bytes.Equal(want, []byte(content)) {
+			t.Errorf("%s differs from %s, run go test -run TestGolden -update after checking the diff", name, golden)
+		}
+	}
+
+	// golden 文件多于生成结果，说明有文件不再生成了
+	err := filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, _ := filepath.Rel(outDir, path)
+		if _, ok := files[filepath.ToSlash(name)]; !ok {
+			t.Errorf("%s is not generated any more", path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+// TestGoldenBuild 编译生成的代码，需要 go 命令和完整的依赖
+func TestGoldenBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip building generated code in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+
+	files := generateFixtures(t, fixtureFiles())
+
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 生成的代码依赖 sniper 的运行库，通过 replace 指向当前项目
+	gomod := "module " + goldenModule + "\n\ngo 1.18\n\nrequire sniper v0.0.0\n\nreplace sniper => " + root + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), sum, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, goldenModule+"/")))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goBin, "build", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, b)
+	}
+}
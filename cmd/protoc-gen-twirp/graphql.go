@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// graphqlScalars 常用 well-known types 对应的 GraphQL 类型
+var graphqlScalars = map[protoreflect.FullName]string{
+	"google.protobuf.Timestamp":   "String",
+	"google.protobuf.Duration":    "String",
+	"google.protobuf.FieldMask":   "String",
+	"google.protobuf.StringValue": "String",
+	"google.protobuf.BytesValue":  "String",
+	"google.protobuf.BoolValue":   "Boolean",
+	"google.protobuf.Int32Value":  "Int",
+	"google.protobuf.UInt32Value": "Int",
+	"google.protobuf.Int64Value":  "String",
+	"google.protobuf.UInt64Value": "String",
+	"google.protobuf.FloatValue":  "Float",
+	"google.protobuf.DoubleValue": "Float",
+	"google.protobuf.Struct":      "JSON",
+	"google.protobuf.Value":       "JSON",
+	"google.protobuf.ListValue":   "JSON",
+	"google.protobuf.Any":         "JSON",
+}
+
+// graphqlSchema 收集接口请求、响应中用到的 message 和 enum
+type graphqlSchema struct {
+	inputs  []*protogen.Message
+	outputs []*protogen.Message
+	enums   []*protogen.Enum
+
+	seen map[string]bool
+}
+
+func (s *graphqlSchema) addMessage(m *protogen.Message, input bool) {
+	if _, ok := graphqlScalars[m.Desc.FullName()]; ok {
+		return
+	}
+
+	name := graphqlTypeName(m, input)
+	if s.seen[name] {
+		return
+	}
+	s.seen[name] = true
+
+	if input {
+		s.inputs = append(s.inputs, m)
+	} else {
+		s.outputs = append(s.outputs, m)
+	}
+
+	for _, field := range m.Fields {
+		if field.Desc.IsMap() {
+			continue
+		}
+		if field.Message != nil {
+			s.addMessage(field.Message, input)
+		}
+		if field.Enum != nil {
+			s.addEnum(field.Enum)
+		}
+	}
+}
+
+func (s *graphqlSchema) addEnum(e *protogen.Enum) {
+	name := e.GoIdent.GoName
+	if s.seen[name] {
+		return
+	}
+	s.seen[name] = true
+	s.enums = append(s.enums, e)
+}
+
+func graphqlTypeName(m *protogen.Message, input bool) string {
+	if scalar, ok := graphqlScalars[m.Desc.FullName()]; ok {
+		return scalar
+	}
+	if input {
+		return m.GoIdent.GoName + "Input"
+	}
+	return m.GoIdent.GoName
+}
+
+// graphqlFieldName 返回 GraphQL 接口字段名，如 echoHello
+func graphqlFieldName(service *protogen.Service, method *protogen.Method) string {
+	return unexported(service.GoName) + method.GoName
+}
+
+// graphqlFieldType 返回字段的 GraphQL 类型
+//
+// 64 位整数按照 proto3 JSON 的规则使用 String，map 字段使用 JSON 标量。
+// 请求字段都是可选的，响应中的标量和列表字段都不为空。
+func graphqlFieldType(field *protogen.Field, input bool) string {
+	if field.Desc.IsMap() {
+		return "JSON"
+	}
+
+	var typ string
+	switch field.Desc.Kind() {
+	case protoreflect.BoolKind:
+		typ = "Boolean"
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		typ = "String"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		typ = "Int"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		typ = "String"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		typ = "Float"
+	case protoreflect.EnumKind:
+		typ = field.Enum.GoIdent.GoName
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		typ = graphqlTypeName(field.Message, input)
+	}
+
+	nonNull := !input && field.Message == nil
+	if field.Desc.IsList() {
+		typ = "[" + typ + "!]"
+		nonNull = !input
+	}
+	if nonNull {
+		typ += "!"
+	}
+	return typ
+}
+
+// graphqlDescription 把 proto 注释转换为 GraphQL 描述，忽略注解行
+func graphqlDescription(buf *bytes.Buffer, comments protogen.Comments, indent string) {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(comments)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "@") {
+			continue
+		}
+		lines = append(lines, strings.ReplaceAll(line, `"""`, `\"""`))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "%s\"\"\"\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(buf, "%s%s\n", indent, line)
+	}
+	fmt.Fprintf(buf, "%s\"\"\"\n", indent)
+}
+
+// generateGraphQL 生成 {file}.graphql schema 和调用 twirp 客户端的 resolver
+func (t *twirp) generateGraphQL(file *protogen.File) {
+	t.generateGraphQLSchema(file)
+	t.generateGraphQLResolvers(file)
+}
+
+func (t *twirp) generateGraphQLSchema(file *protogen.File) {
+	schema := &graphqlSchema{seen: map[string]bool{}}
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			// 流式接口对应 GraphQL subscription，暂不生成
+			if isStreaming(method) {
+				continue
+			}
+			schema.addMessage(method.Input, true)
+			schema.addMessage(method.Output, false)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by protoc-gen-twirp %s, DO NOT EDIT.\n", Version)
+	fmt.Fprintf(&buf, "# source: %s\n", file.Desc.Path())
+
+	for _, query := range []bool{true, false} {
+		var fields bytes.Buffer
+		for _, service := range file.Services {
+			for _, method := range service.Methods {
+				_, ok := methodAnnotation(method, service, "query")
+				if ok != query || isStreaming(method) {
+					continue
+				}
+				graphqlDescription(&fields, method.Comments.Leading, "  ")
+				fmt.Fprintf(&fields, "  %s(input: %s): %s\n",
+					graphqlFieldName(service, method), graphqlTypeName(method.Input, true), graphqlTypeName(method.Output, false))
+			}
+		}
+		if fields.Len() == 0 {
+			continue
+		}
+
+		root := "Mutation"
+		if query {
+			root = "Query"
+		}
+		fmt.Fprintf(&buf, "\nextend type %s {\n", root)
+		buf.Write(fields.Bytes())
+		fmt.Fprintf(&buf, "}\n")
+	}
+
+	for _, input := range []bool{true, false} {
+		messages := schema.outputs
+		keyword := "type"
+		if input {
+			messages = schema.inputs
+			keyword = "input"
+		}
+
+		for _, m := range messages {
+			fmt.Fprintf(&buf, "\n")
+			graphqlDescription(&buf, m.Comments.Leading, "")
+			fmt.Fprintf(&buf, "%s %s {\n", keyword, graphqlTypeName(m, input))
+			if len(m.Fields) == 0 {
+				// GraphQL 不允许空类型
+				fmt.Fprintf(&buf, "  _: Boolean\n")
+			}
+			for _, field := range m.Fields {
+				graphqlDescription(&buf, field.Comments.Leading, "  ")
+				fmt.Fprintf(&buf, "  %s: %s\n", field.Desc.JSONName(), graphqlFieldType(field, input))
+			}
+			fmt.Fprintf(&buf, "}\n")
+		}
+	}
+
+	for _, e := range schema.enums {
+		fmt.Fprintf(&buf, "\n")
+		graphqlDescription(&buf, e.Comments.Leading, "")
+		fmt.Fprintf(&buf, "enum %s {\n", e.GoIdent.GoName)
+		for _, v := range e.Values {
+			fmt.Fprintf(&buf, "  %s\n", v.Desc.Name())
+		}
+		fmt.Fprintf(&buf, "}\n")
+	}
+
+	fname := file.GeneratedFilenamePrefix + ".graphql"
+	t.writeFile(fname, buf.Bytes(), false)
+}
+
+// generateGraphQLResolvers 生成 {file}.graphql.go
+//
+// 每个服务生成一个 resolver，方法名与 GraphQL 字段名对应，
+// 可以直接嵌入 gqlgen 等框架生成的 Query/Mutation resolver 中使用。
+func (t *twirp) generateGraphQLResolvers(file *protogen.File) {
+	api := t.apiPackage(file)
+	t.generateBuildConstraint(artifactGraphQL, "")
+	t.P("// Package ", string(api.name), " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.Desc.Path())
+	t.P(`package `, string(api.name))
+	t.P()
+	t.P(`import `, t.pkgs["context"], ` "context"`)
+	t.P()
+	t.generateDepImports()
+
+	for _, service := range file.Services {
+		servName := service.GoName
+		resolver := servName + "GraphQLResolver"
+
+		t.P(`// `, resolver, ` resolves the GraphQL fields of `, servName, ` by calling a twirp client.`)
+		t.P(`type `, resolver, ` struct {`)
+		t.P(`  client `, servName)
+		t.P(`}`)
+		t.P()
+		t.P(`// New`, resolver, ` creates a resolver, client is usually created by New`, servName, `JSONClient.`)
+		t.P(`func New`, resolver, `(client `, servName, `) *`, resolver, ` {`)
+		t.P(`  return &`, resolver, `{client: client}`)
+		t.P(`}`)
+		t.P()
+
+		for _, method := range service.Methods {
+			if isStreaming(method) {
+				continue
+			}
+			field := graphqlFieldName(service, method)
+			t.P(`// `, exported(field), ` resolves `, field, `.`)
+			t.P(`func (r *`, resolver, `) `, exported(field), `(ctx `, t.pkgs["context"], `.Context, input *`, t.getType(method.Input), `) (*`, t.getType(method.Output), `, error) {`)
+			t.P(`  if input == nil {`)
+			t.P(`    input = new(`, t.getType(method.Input), `)`)
+			t.P(`  }`)
+			t.P(`  return r.client.`, method.GoName, `(ctx, input)`)
+			t.P(`}`)
+			t.P()
+		}
+	}
+
+	fname := api.filenamePrefix + ".graphql.go"
+	t.writeFile(fname, t.output.Bytes(), true)
+	t.output.Reset()
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateGRPC 生成 {file}.grpc.go
+//
+// 同一个服务接口同时对内提供 gRPC、对外提供 twirp/HTTP，不需要维护两份服务定义。
+// 业务方法返回的 twirp.Error 转换为错误码相同的 gRPC status，生成的代码依赖 google.golang.org/grpc。
+func (t *twirp) generateGRPC(file *protogen.File) {
+	api := t.apiPackage(file)
+	t.generateBuildConstraint(artifactGRPC, "")
+	t.P("// Package ", string(api.name), " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.Desc.Path())
+	t.P(`package `, string(api.name))
+	t.P()
+	t.P(`import `, t.pkgs["context"], ` "context"`)
+	t.P()
+	t.P(`import `, t.pkgs["grpc"], ` "google.golang.org/grpc"`)
+	t.P(`import `, t.pkgs["codes"], ` "google.golang.org/grpc/codes"`)
+	t.P(`import `, t.pkgs["status"], ` "google.golang.org/grpc/status"`)
+	t.P(`import `, t.pkgs["twirp"], fmt.Sprintf(` "%s"`, t.TwirpPackage))
+	t.P()
+	t.generateDepImports()
+
+	for _, service := range file.Services {
+		t.generateGRPCService(file, service)
+	}
+
+	fname := api.filenamePrefix + ".grpc.go"
+	t.writeFile(fname, t.output.Bytes(), true)
+	t.output.Reset()
+}
+
+// grpcMethodName 返回 gRPC 使用的方法名，与 proto 文件中的名称相同
+func grpcMethodName(method *protogen.Method) string {
+	return string(method.Desc.Name())
+}
+
+func grpcHandler(service *protogen.Service, method *protogen.Method) string {
+	return unexported(service.GoName) + method.GoName + "GRPCHandler"
+}
+
+func grpcStream(service *protogen.Service, method *protogen.Method) string {
+	return unexported(service.GoName) + method.GoName + "GRPCStream"
+}
+
+// grpcContextFunc 返回设置 twirp 上下文的函数名，业务代码可能通过 twirp.MethodName 等读取上下文
+func grpcContextFunc(service *protogen.Service) string {
+	return unexported(service.GoName) + "GRPCContext"
+}
+
+// grpcErrorFunc 返回把 twirp.Error 转换为 gRPC status 的函数名
+func grpcErrorFunc(service *protogen.Service) string {
+	return unexported(service.GoName) + "GRPCError"
+}
+
+func (t *twirp) generateGRPCService(file *protogen.File, service *protogen.Service) {
+	servName := service.GoName
+	grpcPkg := t.pkgs["grpc"]
+	desc := unexported(servName) + "GRPCServiceDesc"
+	ctxFunc := grpcContextFunc(service)
+	errFunc := grpcErrorFunc(service)
+
+	t.P(`// Register`, servName, `GRPCServer registers svc on s, so that the same `, servName, ` implementation`)
+	t.P(`// serves both twirp and gRPC. A twirp.Error returned by svc is converted to a gRPC status`)
+	t.P(`// with the same code and message.`)
+	t.P(`func Register`, servName, `GRPCServer(s *`, grpcPkg, `.Server, svc `, servName, `) {`)
+	t.P(`  s.RegisterService(&`, desc, `, svc)`)
+	t.P(`}`)
+	t.P()
+
+	t.P(`var `, desc, ` = `, grpcPkg, `.ServiceDesc{`)
+	t.P(`  ServiceName: `, strconv.Quote(string(service.Desc.FullName())), `,`)
+	t.P(`  HandlerType: (*`, servName, `)(nil),`)
+	t.P(`  Methods: []`, grpcPkg, `.MethodDesc{`)
+	for _, method := range service.Methods {
+		if isStreaming(method) {
+			continue
+		}
+		t.P(`    {MethodName: `, strconv.Quote(grpcMethodName(method)), `, Handler: `, grpcHandler(service, method), `},`)
+	}
+	t.P(`  },`)
+	t.P(`  Streams: []`, grpcPkg, `.StreamDesc{`)
+	for _, method := range service.Methods {
+		if !isStreaming(method) {
+			continue
+		}
+		t.P(`    {StreamName: `, strconv.Quote(grpcMethodName(method)), `, Handler: `, grpcHandler(service, method),
+			`, ServerStreams: `, strconv.FormatBool(method.Desc.IsStreamingServer()),
+			`, ClientStreams: `, strconv.FormatBool(method.Desc.IsStreamingClient()), `},`)
+	}
+	t.P(`  },`)
+	t.P(`  Metadata: `, strconv.Quote(file.Desc.Path()), `,`)
+	t.P(`}`)
+	t.P()
+
+	t.P(`func `, ctxFunc, `(ctx `, t.pkgs["context"], `.Context, method string) `, t.pkgs["context"], `.Context {`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithPackageName(ctx, `, strconv.Quote(file.Proto.GetPackage()), `)`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithServiceName(ctx, `, strconv.Quote(servName), `)`)
+	t.P(`  return `, t.pkgs["twirp"], `.WithMethodName(ctx, method)`)
+	t.P(`}`)
+	t.P()
+	t.P(`func `, errFunc, `(err error) error {`)
+	t.P(`  if twerr, ok := err.(`, t.pkgs["twirp"], `.Error); ok {`)
+	t.P(`    return `, t.pkgs["status"], `.Error(`, t.pkgs["codes"], `.Code(`, t.pkgs["twirp"], `.GRPCCode(twerr.Code())), twerr.Msg())`)
+	t.P(`  }`)
+	t.P(`  return err`)
+	t.P(`}`)
+
+	for _, method := range service.Methods {
+		t.P()
+		if isStreaming(method) {
+			t.generateGRPCStreamHandler(service, method)
+		} else {
+			t.generateGRPCUnaryHandler(service, method)
+		}
+	}
+	t.P()
+}
+
+// generateGRPCValidate 开启 validate_enable 时与 twirp 接口一样校验请求
+//
+// v 为请求变量，ret 为错误之前的其他返回值。
+func (t *twirp) generateGRPCValidate(v, ret, indent string) {
+	if !t.ValidateEnable {
+		return
+	}
+	validate := "validate"
+	if t.APIPackage != "" {
+		validate = "Validate"
+	}
+	t.P(indent, `if validerr := `, v, `.`, validate, `(); validerr != nil {`)
+	t.P(indent, `  return `, ret, t.pkgs["status"], `.Error(`, t.pkgs["codes"], `.InvalidArgument, validerr.Error())`)
+	t.P(indent, `}`)
+}
+
+func (t *twirp) generateGRPCUnaryHandler(service *protogen.Service, method *protogen.Method) {
+	servName := service.GoName
+	inputType := t.getType(method.Input)
+	ctx := t.pkgs["context"] + ".Context"
+	fullMethod := "/" + string(service.Desc.FullName()) + "/" + grpcMethodName(method)
+
+	t.P(`func `, grpcHandler(service, method), `(srv interface{}, ctx `, ctx, `, dec func(interface{}) error, interceptor `, t.pkgs["grpc"], `.UnaryServerInterceptor) (interface{}, error) {`)
+	t.P(`  in := new(`, inputType, `)`)
+	t.P(`  if err := dec(in); err != nil {`)
+	t.P(`    return nil, err`)
+	t.P(`  }`)
+	t.P(`  ctx = `, grpcContextFunc(service), `(ctx, `, strconv.Quote(method.GoName), `)`)
+	t.P(`  handler := func(ctx `, ctx, `, req interface{}) (interface{}, error) {`)
+	t.generateGRPCValidate(`req.(*`+inputType+`)`, `nil, `, `    `)
+	t.P(`    out, err := srv.(`, servName, `).`, method.GoName, `(ctx, req.(*`, inputType, `))`)
+	t.P(`    if err != nil {`)
+	t.P(`      return nil, `, grpcErrorFunc(service), `(err)`)
+	t.P(`    }`)
+	t.P(`    return out, nil`)
+	t.P(`  }`)
+	t.P(`  if interceptor == nil {`)
+	t.P(`    return handler(ctx, in)`)
+	t.P(`  }`)
+	t.P(`  info := &`, t.pkgs["grpc"], `.UnaryServerInfo{Server: srv, FullMethod: `, strconv.Quote(fullMethod), `}`)
+	t.P(`  return interceptor(ctx, in, info, handler)`)
+	t.P(`}`)
+}
+
+// generateGRPCStreamHandler 生成流式接口的 handler，grpc.ServerStream 适配为生成的 Sender 和 Receiver 接口
+func (t *twirp) generateGRPCStreamHandler(service *protogen.Service, method *protogen.Method) {
+	servName := service.GoName
+	inputType := t.getType(method.Input)
+	outputType := t.getType(method.Output)
+	stream := grpcStream(service, method)
+
+	t.P(`type `, stream, ` struct {`)
+	t.P(`  `, t.pkgs["grpc"], `.ServerStream`)
+	t.P(`}`)
+	if method.Desc.IsStreamingServer() {
+		t.P()
+		t.P(`func (s `, stream, `) Send(m *`, outputType, `) error {`)
+		t.P(`  return s.ServerStream.SendMsg(m)`)
+		t.P(`}`)
+	}
+	if method.Desc.IsStreamingClient() {
+		t.P()
+		t.P(`func (s `, stream, `) Recv() (*`, inputType, `, error) {`)
+		t.P(`  m := new(`, inputType, `)`)
+		t.P(`  if err := s.ServerStream.RecvMsg(m); err != nil {`)
+		t.P(`    return nil, err`)
+		t.P(`  }`)
+		t.P(`  return m, nil`)
+		t.P(`}`)
+	}
+	t.P()
+
+	t.P(`func `, grpcHandler(service, method), `(srv interface{}, stream `, t.pkgs["grpc"], `.ServerStream) error {`)
+	t.P(`  ctx := `, grpcContextFunc(service), `(stream.Context(), `, strconv.Quote(method.GoName), `)`)
+	switch {
+	case isServerStreaming(method):
+		t.P(`  in := new(`, inputType, `)`)
+		t.P(`  if err := stream.RecvMsg(in); err != nil {`)
+		t.P(`    return err`)
+		t.P(`  }`)
+		t.generateGRPCValidate(`in`, ``, `  `)
+		t.P(`  return `, grpcErrorFunc(service), `(srv.(`, servName, `).`, method.GoName, `(ctx, in, `, stream, `{stream}))`)
+	case method.Desc.IsStreamingServer():
+		t.P(`  s := `, stream, `{stream}`)
+		t.P(`  return `, grpcErrorFunc(service), `(srv.(`, servName, `).`, method.GoName, `(ctx, s, s))`)
+	default:
+		t.P(`  out, err := srv.(`, servName, `).`, method.GoName, `(ctx, `, stream, `{stream})`)
+		t.P(`  if err != nil {`)
+		t.P(`    return `, grpcErrorFunc(service), `(err)`)
+		t.P(`  }`)
+		t.P(`  return stream.SendMsg(out)`)
+	}
+	t.P(`}`)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// httpRuleExtension google.api.http 扩展的字段号，见 google/api/annotations.proto
+const httpRuleExtension = 72295728
+
+// httpRule google.api.http 注解声明的一条 RESTful 路由
+type httpRule struct {
+	method       string
+	pattern      string
+	body         string
+	responseBody string
+}
+
+// httpRules 返回接口通过 google.api.http 注解声明的 RESTful 路由，包括 additional_bindings
+//
+// 不依赖 google.golang.org/genproto，扩展没有注册，直接解析 MethodOptions 中的未知字段。
+func httpRules(method *protogen.Method) []httpRule {
+	opts, ok := method.Desc.Options().(*descriptorpb.MethodOptions)
+	if !ok || opts == nil {
+		return nil
+	}
+
+	var rules []httpRule
+	b := opts.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			fail("invalid options of %s", method.Desc.FullName())
+		}
+		b = b[n:]
+		if num == httpRuleExtension && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				fail("invalid google.api.http of %s", method.Desc.FullName())
+			}
+			rules = append(rules, parseHTTPRule(method, v)...)
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			fail("invalid options of %s", method.Desc.FullName())
+		}
+		b = b[n:]
+	}
+	return rules
+}
+
+// parseHTTPRule 解析 google.api.HttpRule，返回的第一条为规则本身，之后为 additional_bindings
+func parseHTTPRule(method *protogen.Method, b []byte) []httpRule {
+	var rule httpRule
+	var additional []httpRule
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			fail("invalid google.api.http of %s", method.Desc.FullName())
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		} else {
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			switch num {
+			case 2, 3, 4, 5, 6:
+				rule.method = [...]string{"GET", "PUT", "POST", "DELETE", "PATCH"}[num-2]
+				rule.pattern = string(v)
+			case 7:
+				rule.body = string(v)
+			case 8:
+				// CustomHttpPattern，kind 为 1，path 为 2
+				for len(v) > 0 {
+					cnum, ctyp, cn := protowire.ConsumeTag(v)
+					if cn < 0 || ctyp != protowire.BytesType {
+						fail("invalid google.api.http of %s", method.Desc.FullName())
+					}
+					s, sn := protowire.ConsumeBytes(v[cn:])
+					if sn < 0 {
+						fail("invalid google.api.http of %s", method.Desc.FullName())
+					}
+					if cnum == 1 {
+						rule.method = strings.ToUpper(string(s))
+					} else if cnum == 2 {
+						rule.pattern = string(s)
+					}
+					v = v[cn+sn:]
+				}
+			case 11:
+				additional = append(additional, parseHTTPRule(method, v)...)
+			case 12:
+				rule.responseBody = string(v)
+			}
+		}
+		if n < 0 {
+			fail("invalid google.api.http of %s", method.Desc.FullName())
+		}
+		b = b[n:]
+	}
+	if rule.pattern == "" {
+		return additional
+	}
+	return append([]httpRule{rule}, additional...)
+}
+
+// httpPatternVars 返回路径模板中的变量，模板格式不正确时返回 false
+func httpPatternVars(pattern string) ([]string, bool) {
+	if !strings.HasPrefix(pattern, "/") || strings.Contains(pattern, "//") {
+		return nil, false
+	}
+	var vars []string
+	rest := pattern[1:]
+	for rest != "" {
+		i := strings.IndexByte(rest, '{')
+		if i == -1 {
+			return vars, !strings.ContainsAny(rest, "}=")
+		}
+		end := strings.IndexByte(rest, '}')
+		if end < i || i > 0 && rest[i-1] != '/' || strings.ContainsAny(rest[:i], "}=") {
+			return nil, false
+		}
+		v := rest[i+1 : end]
+		if j := strings.IndexByte(v, '='); j != -1 {
+			if v[j+1:] == "" || strings.ContainsAny(v[j+1:], "{=") {
+				return nil, false
+			}
+			v = v[:j]
+		}
+		if v == "" || strings.ContainsAny(v, "{/") {
+			return nil, false
+		}
+		vars = append(vars, v)
+		rest = rest[end+1:]
+		if rest != "" && rest[0] != '/' && rest[0] != ':' {
+			return nil, false
+		}
+	}
+	return vars, true
+}
+
+// restPath 返回路径模板中变量和通配符之前的固定部分，用于挂载到 http.ServeMux
+//
+// 整个模板都是固定内容时返回完整路径，否则返回以 / 结尾的前缀。
+func restPath(pattern string) string {
+	i := strings.IndexAny(pattern, "{*")
+	if i == -1 {
+		return pattern
+	}
+	return pattern[:strings.LastIndexByte(pattern[:i], '/')+1]
+}
+
+// checkHTTPRules 检查 google.api.http 注解
+//
+// 路径变量只能对应请求中的单个标量字段，可以是嵌套 message 的字段；请求体只支持 "*" 或者为空。
+func (t *twirp) checkHTTPRules(file *protogen.File) {
+	for _, service := range file.Services {
+		routes := map[string]string{}
+		for _, method := range service.Methods {
+			rules := httpRules(method)
+			if len(rules) == 0 {
+				continue
+			}
+			if isStreaming(method) {
+				fail("google.api.http is not supported by streaming method %s", method.Desc.FullName())
+			}
+			for _, rule := range rules {
+				route := rule.method + " " + rule.pattern
+				if name, ok := routes[route]; ok {
+					fail("%s of %s conflicts with %s", route, method.Desc.FullName(), name)
+				}
+				routes[route] = string(method.Desc.FullName())
+
+				if rule.body != "" && rule.body != "*" {
+					fail(`body %q of %s is not supported, use "*" or leave it empty`, rule.body, method.Desc.FullName())
+				}
+				if rule.responseBody != "" {
+					fail("response_body of %s is not supported", method.Desc.FullName())
+				}
+				vars, ok := httpPatternVars(rule.pattern)
+				if !ok {
+					fail("invalid google.api.http path %q of %s", rule.pattern, method.Desc.FullName())
+				}
+				for _, v := range vars {
+					if !pathField(method.Input, v) {
+						fail("path variable %s of %s must be a singular scalar field of %s", v, method.Desc.FullName(), method.Input.Desc.FullName())
+					}
+				}
+			}
+		}
+	}
+}
+
+// pathField 判断 path 是否为 message 中的单个标量字段
+func pathField(message *protogen.Message, path string) bool {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		var field *protogen.Field
+		for _, f := range message.Fields {
+			if string(f.Desc.Name()) == name {
+				field = f
+			}
+		}
+		if field == nil || field.Desc.IsList() || field.Desc.IsMap() {
+			return false
+		}
+		if i == len(names)-1 {
+			return field.Desc.Message() == nil
+		}
+		if field.Desc.Message() == nil {
+			return false
+		}
+		message = field.Message
+	}
+	return false
+}
+
+func hasHTTPRules(service *protogen.Service) bool {
+	for _, method := range service.Methods {
+		if len(httpRules(method)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func restRoutesVar(service *protogen.Service) string {
+	return unexported(service.GoName) + "RESTRoutes"
+}
+
+// generateRESTRoutes 生成 google.api.http 注解声明的 RESTful 路由
+func (t *twirp) generateRESTRoutes(service *protogen.Service) {
+	if !hasHTTPRules(service) {
+		return
+	}
+	servName := service.GoName
+
+	paths := map[string]bool{}
+	for _, method := range service.Methods {
+		for _, rule := range httpRules(method) {
+			paths[restPath(rule.pattern)] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	t.P(`// `, servName, `RESTPaths contains paths of RESTful routes declared by google.api.http options.`)
+	t.P(`// Mount them on the same mux as `, servName, `PathPrefix to serve REST consumers.`)
+	t.P(`var `, servName, `RESTPaths = []string{`)
+	for _, p := range sorted {
+		t.P(`  `, strconv.Quote(p), `,`)
+	}
+	t.P(`}`)
+	t.P()
+	t.P(`var `, restRoutesVar(service), ` = []*`, t.pkgs["twirp"], `.RESTRoute{`)
+	for _, method := range service.Methods {
+		for _, rule := range httpRules(method) {
+			t.P(`  `, t.pkgs["twirp"], `.NewRESTRoute(`, strconv.Quote(rule.method), `, `, strconv.Quote(rule.pattern), `, `, methodPathConst(service, method), `, `, strconv.Quote(rule.body), `),`)
+		}
+	}
+	t.P(`}`)
+	t.P()
+}
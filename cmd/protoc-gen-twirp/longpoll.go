@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// longPollCursor 长轮询接口的请求和响应中保存游标的字段名
+const longPollCursor = "cursor"
+
+// longPollTimeout 返回 @longpoll 注解声明的最长等待时间，默认 30s，没有注解时返回 0
+func (t *twirp) longPollTimeout(service *protogen.Service, method *protogen.Method) time.Duration {
+	v, ok := annotation(method.Comments.Leading, "longpoll")
+	if !ok {
+		return 0
+	}
+	if v == "" {
+		return 30 * time.Second
+	}
+
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout <= 0 {
+		fail("invalid @longpoll of %s: %q", t.pathFor(service, method), v)
+	}
+	return timeout
+}
+
+func longPollNotifier(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "Notifier"
+}
+
+// cursorField 返回 message 中的游标字段，没有时返回 nil
+func cursorField(message *protogen.Message) *protogen.Field {
+	for _, field := range message.Fields {
+		if field.Desc.Name() == longPollCursor {
+			return field
+		}
+	}
+	return nil
+}
+
+// isScalar 判断字段是否为单个标量或者枚举值
+func isScalar(field *protogen.Field) bool {
+	if oneof := field.Desc.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+		return false
+	}
+	return !field.Desc.IsList() && !field.Desc.IsMap() && field.Desc.Message() == nil
+}
+
+// checkLongPoll 检查长轮询接口
+//
+// 超时返回的空响应需要带上请求中的游标，请求和响应都必须有类型相同的 cursor 字段。
+func (t *twirp) checkLongPoll(file *protogen.File) {
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if t.longPollTimeout(service, method) == 0 {
+				continue
+			}
+			if isStreaming(method) {
+				fail("@longpoll is not supported by streaming method %s", method.Desc.FullName())
+			}
+
+			in, out := cursorField(method.Input), cursorField(method.Output)
+			if in == nil || out == nil {
+				fail("long polling method %s requires a %s field in both request and response", method.Desc.FullName(), longPollCursor)
+			}
+			if !isScalar(in) || !isScalar(out) || in.Desc.Kind() != out.Desc.Kind() ||
+				in.Desc.HasPresence() != out.Desc.HasPresence() || in.Desc.Enum() != out.Desc.Enum() {
+				fail("%s fields of long polling method %s must be scalars of the same type", longPollCursor, method.Desc.FullName())
+			}
+		}
+	}
+}
+
+// generateLongPollTypes 生成长轮询接口的 {Service}{Method}Notifier 接口
+func (t *twirp) generateLongPollTypes(service *protogen.Service) {
+	for _, method := range service.Methods {
+		if t.longPollTimeout(service, method) == 0 {
+			continue
+		}
+		notifier := longPollNotifier(service, method)
+		methName := method.GoName
+		t.P()
+		t.P(`// `, notifier, ` can be implemented by `, service.GoName, ` to hold `, methName, ` requests`)
+		t.P(`// until new data is available. `, methName, ` is called after the returned channel`)
+		t.P(`// receives a value or is closed, otherwise a response with only the request cursor`)
+		t.P(`// is returned after `, t.longPollTimeout(service, method).String(), `.`)
+		t.P(`type `, notifier, ` interface {`)
+		t.P(`  `, methName, `Ready(`, t.pkgs["context"], `.Context, *`, t.getType(method.Input), `) (<-chan struct{}, error)`)
+		t.P(`}`)
+	}
+}
+
+// generateLongPollWait 在业务方法调用之前等待新数据，超时则直接返回带游标的空响应
+//
+// 服务没有实现 Notifier 时反复调用业务方法，直到响应的游标与请求不同。
+func (t *twirp) generateLongPollWait(service *protogen.Service, method *protogen.Method) {
+	timeout := t.longPollTimeout(service, method)
+	if timeout == 0 {
+		return
+	}
+	field := cursorField(method.Output).GoName
+	changed := `respContent.Get` + field + `() != reqContent.Get` + cursorField(method.Input).GoName + `()`
+	if cursorField(method.Output).Desc.Kind() == protoreflect.BytesKind {
+		changed = `string(respContent.Get` + field + `()) != string(reqContent.Get` + cursorField(method.Input).GoName + `())`
+	}
+	t.P(`    if notifier, ok := impl.(`, longPollNotifier(service, method), `); ok {`)
+	t.P(`      var ready <-chan struct{}`)
+	t.P(`      if ready, err = notifier.`, method.GoName, `Ready(ctx, reqContent); err != nil {`)
+	t.P(`        return`)
+	t.P(`      }`)
+	t.P(`      polled := `, t.pkgs["twirp"], `.WaitLongPoll(ctx, ready, `, strconv.FormatInt(int64(timeout), 10), `) // `, timeout.String())
+	t.P(`      `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "longpoll")`)
+	t.P(`      if !polled {`)
+	t.P(`        respContent = &`, t.getType(method.Output), `{`, field, `: reqContent.`, cursorField(method.Input).GoName, `}`)
+	t.P(`        return`)
+	t.P(`      }`)
+	t.P(`    } else {`)
+	t.P(`      `, t.pkgs["twirp"], `.RetryLongPoll(ctx, `, strconv.FormatInt(int64(timeout), 10), `, func() bool {`)
+	t.P(`        respContent, err = impl.`, method.GoName, `(ctx, reqContent)`)
+	t.P(`        return err != nil || respContent == nil || `, changed)
+	t.P(`      })`)
+	t.P(`      `, t.pkgs["twirp"], `.MarkServerTiming(ctx, "longpoll")`)
+	t.P(`      return`)
+	t.P(`    }`)
+}
@@ -32,12 +32,44 @@ func main() {
 	g := newGenerator()
 
 	var flags flag.FlagSet
-
-	flags.StringVar(&g.OptionPrefix, "option_prefix", "sniper", "")
-	flags.StringVar(&g.TwirpPackage, "twirp_package", "sniper/util/twirp", "")
-	flags.BoolVar(&g.ValidateEnable, "validate_enable", false, "")
+	registerFlags(&flags, g)
 
 	protogen.Options{
 		ParamFunc: flags.Set,
 	}.Run(g.Generate)
 }
+
+// registerFlags 注册插件参数，参数名与 protoc 的 --twirp_out 选项一致
+func registerFlags(flags *flag.FlagSet, g *twirp) {
+	flags.StringVar(&g.OptionPrefix, "option_prefix", "sniper", "")
+	flags.StringVar(&g.TwirpPackage, "twirp_package", "sniper/util/twirp", "")
+	flags.BoolVar(&g.ValidateEnable, "validate_enable", false, "")
+	flags.BoolVar(&g.ValidateAll, "validate_all", false, "")
+	flags.BoolVar(&g.InlineServe, "inline_serve", false, "")
+	flags.BoolVar(&g.VTProto, "vtproto", false, "")
+	flags.BoolVar(&g.MessagePool, "message_pool", false, "")
+	flags.BoolVar(&g.Routes, "routes", false, "")
+	flags.BoolVar(&g.Docs, "docs", false, "")
+	flags.StringVar(&g.Gateway, "gateway", "", "")
+	flags.StringVar(&g.GatewayCluster, "gateway_cluster", "sniper", "")
+	flags.BoolVar(&g.GraphQL, "graphql", false, "")
+	flags.BoolVar(&g.Explorer, "explorer", false, "")
+	flags.StringVar(&g.Compat, "compat", "", "")
+	flags.BoolVar(&g.Upstream, "upstream", false, "")
+	flags.BoolVar(&g.ContractTest, "contract_test", false, "")
+	flags.BoolVar(&g.UnknownFields, "unknown_fields", false, "")
+	flags.Int64Var(&g.MaxBodySize, "max_body_size", 0, "")
+	flags.BoolVar(&g.RequireContentLength, "require_content_length", false, "")
+	flags.Int64Var(&g.GzipMinSize, "gzip_min_size", 0, "")
+	flags.Int64Var(&g.ChunkMinSize, "chunk_min_size", 0, "")
+	flags.StringVar(&g.FormContentType, "form_content_type", "", "")
+	flags.StringVar(&g.APIPackage, "api_package", "", "")
+	flags.BoolVar(&g.Generics, "generics", false, "")
+	flags.BoolVar(&g.GRPC, "grpc", false, "")
+	flags.BoolVar(&g.Messages, "messages", false, "")
+	flags.StringVar(&g.CacheDir, "cache_dir", "", "")
+	flags.IntVar(&g.Workers, "workers", 0, "")
+	flags.BoolVar(&g.EmptyNoContent, "empty_no_content", false, "")
+	flags.StringVar(&g.StreamTransport, "stream_transport", "", "")
+	flags.Var(g.BuildTags, "build_tag", "")
+}
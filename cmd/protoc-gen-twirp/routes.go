@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// routeManifest 路由清单，供网关等外部系统同步接口配置
+type routeManifest struct {
+	Package  string         `json:"package"`
+	Source   string         `json:"source"`
+	Services []serviceRoute `json:"services"`
+}
+
+type serviceRoute struct {
+	Name       string        `json:"name"`
+	PathPrefix string        `json:"path_prefix"`
+	Methods    []methodRoute `json:"methods"`
+}
+
+type methodRoute struct {
+	Name        string       `json:"name"`
+	Path        string       `json:"path"`
+	HTTPMethods []string     `json:"http_methods"`
+	Input       string       `json:"input"`
+	Output      string       `json:"output"`
+	Auth        bool         `json:"auth"`
+	Streaming   bool         `json:"streaming,omitempty"`
+	WebSocket   bool         `json:"websocket,omitempty"`
+	Internal    bool         `json:"internal,omitempty"`
+	Timeout     string       `json:"timeout,omitempty"`
+	LongPoll    string       `json:"longpoll,omitempty"`
+	BatchPath   string       `json:"batch_path,omitempty"`
+	REST        []string     `json:"rest,omitempty"`
+	Retries     string       `json:"retries,omitempty"`
+	Option      string       `json:"option,omitempty"`
+	Flag        string       `json:"flag,omitempty"`
+	Deprecated  bool         `json:"deprecated,omitempty"`
+	Errors      []routeError `json:"errors,omitempty"`
+}
+
+type routeError struct {
+	Code    string `json:"code"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// generateRoutes 生成 {file}.routes.json 路由清单
+func (t *twirp) generateRoutes(file *protogen.File) {
+	manifest := routeManifest{
+		Package:  file.Proto.GetPackage(),
+		Source:   file.Desc.Path(),
+		Services: []serviceRoute{},
+	}
+
+	for _, service := range file.Services {
+		sr := serviceRoute{
+			Name:       service.GoName,
+			PathPrefix: t.pathPrefix(service),
+			Methods:    []methodRoute{},
+		}
+
+		for _, method := range service.Methods {
+			mr := methodRoute{
+				Name:        method.GoName,
+				Path:        t.pathFor(service, method),
+				HTTPMethods: []string{"POST"},
+				Input:       string(method.Input.Desc.FullName()),
+				Output:      string(method.Output.Desc.FullName()),
+				Auth:        t.needLogin(method, service),
+				Streaming:   method.Desc.IsStreamingServer(),
+			}
+			if isWebSocket(method) {
+				mr.HTTPMethods = []string{"GET"}
+				mr.WebSocket = true
+			} else if isServerStreaming(method) && t.sse() {
+				mr.HTTPMethods = []string{"GET", "POST"}
+			} else if _, ok := methodAnnotation(method, service, "get"); ok {
+				mr.HTTPMethods = []string{"GET", "HEAD", "POST", "OPTIONS"}
+			}
+			_, mr.Deprecated = t.deprecated(service, method)
+			_, mr.Internal = methodAnnotation(method, service, "internal")
+			mr.Flag, _ = methodAnnotation(method, service, "flag")
+			mr.Timeout, _ = methodAnnotation(method, service, "timeout")
+			mr.Retries, _ = methodAnnotation(method, service, "retry")
+			if longpoll := t.longPollTimeout(service, method); longpoll > 0 {
+				mr.LongPoll = longpoll.String()
+			}
+			if t.batchConcurrency(service, method) > 0 {
+				mr.BatchPath = t.pathFor(service, method) + "Batch"
+			}
+			for _, rule := range httpRules(method) {
+				mr.REST = append(mr.REST, rule.method+" "+rule.pattern)
+			}
+
+			matched := t.methodOptionRegexp.FindStringSubmatch(method.Comments.Trailing.String())
+			if len(matched) == 2 {
+				mr.Option = matched[1]
+			}
+
+			for _, doc := range errorDocs(method, service) {
+				mr.Errors = append(mr.Errors, routeError{Code: doc.Code, Comment: doc.Comment})
+			}
+
+			sr.Methods = append(sr.Methods, mr)
+		}
+
+		manifest.Services = append(manifest.Services, sr)
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		fail("marshal routes: %v", err)
+	}
+
+	fname := file.GeneratedFilenamePrefix + ".routes.json"
+	t.writeFile(fname, append(b, '\n'), false)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// isServerStreaming 判断是否为服务端流式接口，即 returns (stream Resp)
+func isServerStreaming(method *protogen.Method) bool {
+	return method.Desc.IsStreamingServer() && !method.Desc.IsStreamingClient()
+}
+
+// isWebSocket 判断是否为客户端流式或者双向流式接口，即 rpc M(stream Req)，这类接口使用 WebSocket 传输
+func isWebSocket(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient()
+}
+
+// isStreaming 判断是否为任意一种流式接口
+func isStreaming(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+// hasStreaming 判断服务是否有流式接口
+func hasStreaming(service *protogen.Service) bool {
+	for _, method := range service.Methods {
+		if isStreaming(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasServerStreaming 判断服务是否有服务端流式接口
+func hasServerStreaming(service *protogen.Service) bool {
+	for _, method := range service.Methods {
+		if isServerStreaming(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// sse 判断服务端流式接口是否输出 Server-Sent Events
+func (t *twirp) sse() bool {
+	return t.StreamTransport == "sse"
+}
+
+// hasWebSocket 判断服务是否有使用 WebSocket 传输的接口
+func hasWebSocket(service *protogen.Service) bool {
+	for _, method := range service.Methods {
+		if isWebSocket(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStreaming 检查流式接口
+//
+// 依赖完整请求或者响应的注解和官方 twirp 兼容模式不能用于流式接口。
+func (t *twirp) checkStreaming(file *protogen.File) {
+	for _, service := range file.Services {
+		for _, method := range service.Methods {
+			if !isStreaming(method) {
+				continue
+			}
+			if t.Compat == "twitch" || t.Upstream {
+				fail("streaming method %s is not supported by upstream twirp", method.Desc.FullName())
+			}
+			for _, name := range []string{"dedup", "cache", "encrypted", "shadow", "logbody", "get"} {
+				if _, ok := methodAnnotation(method, service, name); ok {
+					fail("@%s is not supported by streaming method %s", name, method.Desc.FullName())
+				}
+			}
+		}
+	}
+}
+
+func streamSender(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "Sender"
+}
+
+func streamReceiver(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "Receiver"
+}
+
+func streamReader(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + method.GoName + "Stream"
+}
+
+// streamSignature 返回流式接口在服务接口中的参数（不含 ctx）和返回值
+//
+// 服务端流式为 (*Req, Sender) error，客户端流式为 (Receiver) (*Resp, error)，
+// 双向流式为 (Receiver, Sender) error。
+func (t *twirp) streamSignature(service *protogen.Service, method *protogen.Method) (params, results string) {
+	switch {
+	case isServerStreaming(method):
+		return `*` + t.getType(method.Input) + `, ` + streamSender(service, method), `error`
+	case method.Desc.IsStreamingServer():
+		return streamReceiver(service, method) + `, ` + streamSender(service, method), `error`
+	default:
+		return streamReceiver(service, method), `(*` + t.getType(method.Output) + `, error)`
+	}
+}
+
+// generateStreamTypes 生成流式接口的发送、接收接口，客户端使用的 Stream 类型和 {Service}StreamClient 接口
+func (t *twirp) generateStreamTypes(service *protogen.Service) {
+	if !hasStreaming(service) {
+		return
+	}
+
+	servName := service.GoName
+	ctx := t.pkgs["context"] + ".Context"
+	for _, method := range service.Methods {
+		if !isStreaming(method) {
+			continue
+		}
+		inputType := t.getType(method.Input)
+		outputType := t.getType(method.Output)
+		reader := streamReader(service, method)
+
+		if isWebSocket(method) {
+			receiver := streamReceiver(service, method)
+			t.P()
+			t.P(`// `, receiver, ` receives the requests of `, servName, `.`, method.GoName, `.`)
+			t.P(`type `, receiver, ` interface {`)
+			t.P(`  // Recv returns the next request, or io.EOF after the last one.`)
+			t.P(`  Recv() (*`, inputType, `, error)`)
+			t.P(`}`)
+		}
+		if method.Desc.IsStreamingServer() {
+			sender := streamSender(service, method)
+			t.P()
+			t.P(`// `, sender, ` sends the responses of `, servName, `.`, method.GoName, `.`)
+			t.P(`type `, sender, ` interface {`)
+			t.P(`  Send(*`, outputType, `) error`)
+			t.P(`}`)
+		}
+
+		t.P()
+		if !isWebSocket(method) {
+			t.P(`// `, reader, ` receives the responses of `, servName, `.`, method.GoName, `,`)
+			t.P(`// it is returned by `, method.GoName, `Stream of the `, servName, ` clients.`)
+			t.P(`type `, reader, ` struct {`)
+			t.P(`  r *`, t.pkgs["twirp"], `.StreamReader`)
+			t.P(`}`)
+			t.P()
+			t.P(`// Recv returns the next response, or io.EOF after the last one.`)
+			t.P(`func (s *`, reader, `) Recv() (*`, outputType, `, error) {`)
+			t.P(`  out := new(`, outputType, `)`)
+			t.P(`  if err := s.r.Recv(out); err != nil {`)
+			t.P(`    return nil, err`)
+			t.P(`  }`)
+			t.P(`  return out, nil`)
+			t.P(`}`)
+			t.P()
+			t.P(`// Close stops receiving, it must be called if Recv has not returned an error.`)
+			t.P(`func (s *`, reader, `) Close() error {`)
+			t.P(`  return s.r.Close()`)
+			t.P(`}`)
+			continue
+		}
+
+		t.P(`// `, reader, ` is a WebSocket stream of `, servName, `.`, method.GoName, `,`)
+		t.P(`// it is returned by `, method.GoName, `Stream of the `, servName, ` clients.`)
+		t.P(`type `, reader, ` struct {`)
+		t.P(`  s *`, t.pkgs["twirp"], `.WebSocketClientStream`)
+		t.P(`}`)
+		t.P()
+		t.P(`// Send sends a request.`)
+		t.P(`func (s *`, reader, `) Send(in *`, inputType, `) error {`)
+		t.P(`  return s.s.Send(in)`)
+		t.P(`}`)
+		t.P()
+		if method.Desc.IsStreamingServer() {
+			t.P(`// CloseSend tells the server that all requests have been sent.`)
+			t.P(`func (s *`, reader, `) CloseSend() error {`)
+			t.P(`  return s.s.CloseSend()`)
+			t.P(`}`)
+			t.P()
+			t.P(`// Recv returns the next response, or io.EOF after the last one.`)
+			t.P(`func (s *`, reader, `) Recv() (*`, outputType, `, error) {`)
+			t.P(`  out := new(`, outputType, `)`)
+			t.P(`  if err := s.s.Recv(out); err != nil {`)
+			t.P(`    return nil, err`)
+			t.P(`  }`)
+			t.P(`  return out, nil`)
+			t.P(`}`)
+		} else {
+			t.P(`// CloseAndRecv tells the server that all requests have been sent, and returns the response.`)
+			t.P(`func (s *`, reader, `) CloseAndRecv() (*`, outputType, `, error) {`)
+			t.P(`  out := new(`, outputType, `)`)
+			t.P(`  if err := s.s.CloseAndRecv(out); err != nil {`)
+			t.P(`    return nil, err`)
+			t.P(`  }`)
+			t.P(`  return out, nil`)
+			t.P(`}`)
+		}
+		t.P()
+		t.P(`// Close closes the connection, it must be called if the stream is not finished.`)
+		t.P(`func (s *`, reader, `) Close() error {`)
+		t.P(`  return s.s.Close()`)
+		t.P(`}`)
+	}
+
+	t.P()
+	t.P(`// `, servName, `StreamClient is implemented by the `, servName, ` clients,`)
+	t.P(`// the {Method}Stream methods give direct access to the streams of streaming methods.`)
+	t.P(`type `, servName, `StreamClient interface {`)
+	t.P(`  `, servName)
+	for _, method := range service.Methods {
+		switch {
+		case isWebSocket(method):
+			t.P(`  `, method.GoName, `Stream(`, ctx, `) (*`, streamReader(service, method), `, error)`)
+		case isServerStreaming(method):
+			t.P(`  `, method.GoName, `Stream(`, ctx, `, *`, t.getType(method.Input), `) (*`, streamReader(service, method), `, error)`)
+		}
+	}
+	t.P(`}`)
+}
+
+// generateStreamClientMethod 生成客户端的流式接口方法
+//
+// {Method}Stream 返回逐条读取响应的 Stream，{Method} 把读到的响应交给 sender，
+// 客户端与服务端实现相同的接口，可以直接作为其他服务的实现转发。
+func (t *twirp) generateStreamClientMethod(name, structName string, file *protogen.File, service *protogen.Service, method *protogen.Method, index int) {
+	methName := method.GoName
+	inputType := t.getType(method.Input)
+	reader := streamReader(service, method)
+	ctx := t.pkgs["context"] + ".Context"
+
+	codec := t.pkgs["twirp"] + "." + name + "Codec"
+	if name == "Codec" {
+		codec = "c.codec"
+	}
+
+	t.P(`func (c *`, structName, `) `, methName, `Stream(ctx `, ctx, `, in *`, inputType, `) (*`, reader, `, error) {`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithPackageName(ctx, "`, *file.Proto.Package, `")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithServiceName(ctx, "`, service.GoName, `")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
+	if _, ok := methodAnnotation(method, service, "priority"); ok {
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithDefaultPriority(ctx, `, t.pkgs["twirp"], `.`, t.priority(service, method), `)`)
+	}
+	// 流式接口的耗时取决于响应的数量，不使用默认的超时预算
+	t.P(`  r, err := `, t.pkgs["twirp"], `.DoStreamRequest(ctx, c.client, `, codec, `, c.urls[`, strconv.Itoa(index), `], in)`)
+	t.P(`  if err != nil {`)
+	t.P(`    return nil, err`)
+	t.P(`  }`)
+	t.P(`  return &`, reader, `{r: r}, nil`)
+	t.P(`}`)
+	t.P()
+	t.P(`func (c *`, structName, `) `, methName, `(ctx `, ctx, `, in *`, inputType, `, sender `, streamSender(service, method), `) error {`)
+	t.P(`  stream, err := c.`, methName, `Stream(ctx, in)`)
+	t.P(`  if err != nil {`)
+	t.P(`    return err`)
+	t.P(`  }`)
+	t.P(`  defer stream.Close()`)
+	t.P(`  for {`)
+	t.P(`    out, err := stream.Recv()`)
+	t.P(`    if err == `, t.pkgs["io"], `.EOF {`)
+	t.P(`      return nil`)
+	t.P(`    }`)
+	t.P(`    if err != nil {`)
+	t.P(`      return err`)
+	t.P(`    }`)
+	t.P(`    if err = sender.Send(out); err != nil {`)
+	t.P(`      return err`)
+	t.P(`    }`)
+	t.P(`  }`)
+	t.P(`}`)
+	t.P()
+}
+
+// generateServerStreamHandleMethod 生成流式接口的 handle{Method} 方法，各编码格式共用
+//
+// 业务方法通过 sender 逐条输出响应，返回的错误写入结束帧；还没有输出响应时作为普通的错误响应输出。
+func (t *twirp) generateServerStreamHandleMethod(service *protogen.Service, method *protogen.Method) {
+	servStruct := serviceStruct(service)
+	adapter := unexported(streamSender(service, method))
+	outputType := t.getType(method.Output)
+
+	t.P(`type `, adapter, ` struct {`)
+	t.P(`  stream *`, t.pkgs["twirp"], `.ServerStream`)
+	t.P(`}`)
+	t.P()
+	t.P(`func (s `, adapter, `) Send(m *`, outputType, `) error {`)
+	t.P(`  return s.stream.Send(m)`)
+	t.P(`}`)
+	t.P()
+	t.P(`func (s *`, servStruct, `) handle`, method.GoName, `(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, reqContent *`, t.getType(method.Input), `, marshal `, t.pkgs["twirp"], `.MarshalFunc) {`)
+	t.P(`  var err error`)
+	t.generateServerMethodPrepare(service, method)
+	if t.sse() {
+		// 事件始终使用 JSON 编码，不使用请求编码格式对应的 marshal
+		t.P(`  stream := `, t.pkgs["twirp"], `.NewSSEServerStream(ctx, resp, s.hooks)`)
+	} else {
+		t.P(`  stream := `, t.pkgs["twirp"], `.NewServerStream(ctx, resp, s.hooks, marshal)`)
+	}
+	t.P(`  func() {`)
+	t.P(`    defer func() {`)
+	t.P(`      // In case of a panic, end the stream with an internal error and then panic.`)
+	t.P(`      if r := recover(); r != nil {`)
+	t.P(`        stream.Finish(`, t.pkgs["twirp"], `.InternalError("Internal service panic"))`)
+	t.P(`        panic(r)`)
+	t.P(`      }`)
+	t.P(`    }()`)
+	t.P(`    err = impl.`, method.GoName, `(ctx, reqContent, `, adapter, `{stream: stream})`)
+	t.P(`  }()`)
+	if len(errorDocs(method, service)) > 0 {
+		t.P(`  err = `, t.pkgs["twirp"], `.CheckErrorCode(`, methodPathConst(service, method), `, err)`)
+	}
+	t.P(`  stream.Finish(err)`)
+	t.P(`}`)
+	t.P()
+}
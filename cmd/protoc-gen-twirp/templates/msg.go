@@ -11,6 +11,36 @@ func (m *{{ msgTyp . }}) validate() error {
 	return nil
 }
 
+// validateAll 与 validate 相同，但是检查所有字段，返回全部错误
+func (m *{{ msgTyp . }}) validateAll() error {
+	if m == nil { return nil }
+
+	var errs {{ msgTyp . }}MultiError
+	{{ range .Fields }}{{ if or (validate .) (trim (message .)) }}
+	if err := func() error {
+		{{ template "field" . }}
+		return nil
+	}(); err != nil {
+		errs = append(errs, err)
+	}
+	{{ end }}{{ end }}
+
+	if len(errs) > 0 { return errs }
+	return nil
+}
+
+// {{ msgTyp . }}MultiError is the list of errors returned by validateAll
+type {{ msgTyp . }}MultiError []error
+
+// Error satisfies the builtin error interface
+func (e {{ msgTyp . }}MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
 type {{ errname . }} struct {
 	field  string
 	reason string
@@ -0,0 +1,16 @@
+package rule
+
+const currencyTpl = `
+		var {{ .Field.GoIdent.GoName }}_Currency = map[string]struct{}{
+			{{ range slice .Value }}
+				"{{ trim . }}":{},
+			{{ end }}
+		}
+
+		if _, ok := {{ .Field.GoIdent.GoName }}_Currency[{{ .Key }}.GetCurrencyCode()]; !ok {
+			return {{ .Field.Parent.GoIdent.GoName }}ValidationError {
+				field:  "{{ .Field.GoName }}",
+				reason: "currency must be in list {{ escape .Value }}",
+			}
+		}
+`
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -45,6 +47,8 @@ const maxItemsTyp = "max_items"
 const uniqueTyp = "unique"
 const typeTyp = "type"
 const rangeTyp = "range"
+const currencyTyp = "currency"
+const scaleTyp = "scale"
 
 var tienum = map[string]string{
 	eqTyp:          eqTpl,
@@ -67,6 +71,8 @@ var tienum = map[string]string{
 	uniqueTyp:      uniqueTpl,
 	typeTyp:        typeTpl,
 	rangeTyp:       rangeTpl,
+	currencyTyp:    currencyTpl,
+	scaleTyp:       scaleTpl,
 }
 
 // TemplateInfo 用以生成最终的 rule 模版
@@ -95,6 +101,8 @@ func RegisterFunctions(tpl *template.Template) {
 		"rangeRule": rangeRulefunc,
 		"validate":  validatefunc,
 		"message":   messagefunc,
+		"trim":      strings.TrimSpace,
+		"nanosStep": nanosStepfunc,
 	})
 }
 
@@ -176,7 +184,8 @@ func protoTypeToGoType(kind protoreflect.Kind) (typ string) {
 	case protoreflect.MessageKind:
 		return messageTyp
 	case protoreflect.GroupKind:
-		return ""
+		// editions 中 message_encoding = DELIMITED 的消息字段
+		return messageTyp
 	default:
 		return ""
 	}
@@ -205,6 +214,20 @@ func rangeRulefunc(key string, value string) string {
 	return key + v1 + v2 + "&&" + key + v4 + v3
 }
 
+// nanosStepfunc 返回 scale 规则对应的 nanos 步长，如 scale 为 2 时 nanos 必须是 10000000 的倍数
+func nanosStepfunc(value string) string {
+	scale, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || scale < 0 || scale > 9 {
+		panic(value + " scale value 不规范")
+	}
+
+	step := 1
+	for i := scale; i < 9; i++ {
+		step *= 10
+	}
+	return strconv.Itoa(step)
+}
+
 // validatefunc 返回 field 校验规则
 func validatefunc(field protogen.Field) (ss []string) {
 	rs := getRules(field.Comments) // 获取所有规则
@@ -217,14 +240,22 @@ func validatefunc(field protogen.Field) (ss []string) {
 }
 
 // messagefunc 处理 message 间的互相调用 repeated 需要增加循环
+//
+// 其他 Go 包的 message 无法调用未导出的 validate，改为调用 api_package 模式导出的 Validate。
 func messagefunc(field protogen.Field) (str string) {
-	if field.Desc.Kind() != protoreflect.MessageKind {
+	// 使用分隔编码（group、editions 的 DELIMITED）的消息字段同样需要校验
+	if field.Desc.Kind() != protoreflect.MessageKind && field.Desc.Kind() != protoreflect.GroupKind {
 		return
 	}
 
+	validate := "validate"
+	if field.Message != nil && field.Message.GoIdent.GoImportPath != field.Parent.GoIdent.GoImportPath {
+		validate = "Validate"
+	}
+
 	str = `
-		if v, ok := interface{}(` + accessor(field) + `).(interface{ validate() error }); ok {
-			if err := v.validate(); err != nil {
+		if v, ok := interface{}(` + accessor(field) + `).(interface{ ` + validate + `() error }); ok {
+			if err := v.` + validate + `(); err != nil {
 				return ` + field.Parent.GoIdent.GoName + `ValidationError {
 					field:  "` + field.GoName + `",
 					reason: "embedded message failed validation " + err.Error(),
@@ -236,8 +267,8 @@ func messagefunc(field protogen.Field) (str string) {
 	if field.Desc.IsList() {
 		str = `
 	for _, item := range ` + accessor(field) + ` {
-		if v, ok := interface{}(item).(interface{ validate() error }); ok {
-			if err := v.validate(); err != nil {
+		if v, ok := interface{}(item).(interface{ ` + validate + `() error }); ok {
+			if err := v.` + validate + `(); err != nil {
 				return ` + field.Parent.GoIdent.GoName + `ValidationError {
 					field:  "` + field.GoName + `",
 					reason: "embedded message failed validation " + err.Error(),
@@ -289,9 +320,11 @@ func getTemplateInfo(field protogen.Field, r Rule) (s string) {
 // getRules 返回了每行符合正则的 rules 数组
 func getRules(cs protogen.CommentSet) (rs []Rule) {
 	ops := make([]string, 0, len(tienum))
-	for op, _ := range tienum {
+	for op := range tienum {
 		ops = append(ops, op)
 	}
+	// 按名称排序，保证每次生成的正则完全相同
+	sort.Strings(ops)
 
 	r := "@(" + strings.Join(ops, "|") + "):\\s*(.+)\\s*"
 	re := regexp.MustCompile(r)
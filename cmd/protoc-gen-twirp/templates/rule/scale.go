@@ -0,0 +1,18 @@
+package rule
+
+const scaleTpl = `
+		if n := {{ .Key }}.GetNanos(); n <= -1000000000 || n >= 1000000000 ||
+			({{ .Key }}.GetUnits() > 0 && n < 0) || ({{ .Key }}.GetUnits() < 0 && n > 0) {
+			return {{ .Field.Parent.GoIdent.GoName }}ValidationError {
+				field:  "{{ .Field.GoName }}",
+				reason: "value must have valid units and nanos",
+			}
+		}
+
+		if {{ .Key }}.GetNanos() % {{ nanosStep .Value }} != 0 {
+			return {{ .Field.Parent.GoIdent.GoName }}ValidationError {
+				field:  "{{ .Field.GoName }}",
+				reason: "value must have at most {{ .Value }} decimal places",
+			}
+		}
+`
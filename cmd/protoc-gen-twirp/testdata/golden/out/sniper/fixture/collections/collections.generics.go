@@ -0,0 +1,68 @@
+//go:build go1.18
+
+// Package collections is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: collections.proto
+package collections
+
+import context "context"
+
+import twirp "sniper/util/twirp"
+
+type collectionsServerConfig struct {
+	hooks *twirp.ServerHooks
+	list  []func(context.Context, *ListRequest) error
+	save  []func(context.Context, *Collection) error
+}
+
+// CollectionsServerOption configures the server created by NewCollectionsTypedServer.
+type CollectionsServerOption func(*collectionsServerConfig)
+
+// WithCollectionsHooks sets the server hooks.
+func WithCollectionsHooks(hooks *twirp.ServerHooks) CollectionsServerOption {
+	return func(c *collectionsServerConfig) { c.hooks = hooks }
+}
+
+// OnCollectionsList adds an interceptor of List, which is called after
+// the request is validated. If it returns an error, List is not called.
+func OnCollectionsList(fn func(context.Context, *ListRequest) error) CollectionsServerOption {
+	return func(c *collectionsServerConfig) { c.list = append(c.list, fn) }
+}
+
+// OnCollectionsSave adds an interceptor of Save, which is called after
+// the request is validated. If it returns an error, Save is not called.
+func OnCollectionsSave(fn func(context.Context, *Collection) error) CollectionsServerOption {
+	return func(c *collectionsServerConfig) { c.save = append(c.save, fn) }
+}
+
+// NewCollectionsTypedServer creates a server of svc like NewCollectionsServer,
+// with typed interceptors registered by OnCollections{Method} options.
+func NewCollectionsTypedServer[T Collections](svc T, opts ...CollectionsServerOption) twirp.Server {
+	c := &collectionsServerConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return NewCollectionsServer(&collectionsTypedService[T]{svc: svc, config: c}, c.hooks)
+}
+
+type collectionsTypedService[T Collections] struct {
+	svc    T
+	config *collectionsServerConfig
+}
+
+func (s *collectionsTypedService[T]) List(ctx context.Context, req *ListRequest) (*Collection, error) {
+	for _, fn := range s.config.list {
+		if err := fn(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return s.svc.List(ctx, req)
+}
+
+func (s *collectionsTypedService[T]) Save(ctx context.Context, req *Collection) (*Collection, error) {
+	for _, fn := range s.config.save {
+		if err := fn(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return s.svc.Save(ctx, req)
+}
@@ -0,0 +1,31 @@
+// Package collections is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: collections.proto
+package collections
+
+import context "context"
+
+// CollectionsGraphQLResolver resolves the GraphQL fields of Collections by calling a twirp client.
+type CollectionsGraphQLResolver struct {
+	client Collections
+}
+
+// NewCollectionsGraphQLResolver creates a resolver, client is usually created by NewCollectionsJSONClient.
+func NewCollectionsGraphQLResolver(client Collections) *CollectionsGraphQLResolver {
+	return &CollectionsGraphQLResolver{client: client}
+}
+
+// CollectionsList resolves collectionsList.
+func (r *CollectionsGraphQLResolver) CollectionsList(ctx context.Context, input *ListRequest) (*Collection, error) {
+	if input == nil {
+		input = new(ListRequest)
+	}
+	return r.client.List(ctx, input)
+}
+
+// CollectionsSave resolves collectionsSave.
+func (r *CollectionsGraphQLResolver) CollectionsSave(ctx context.Context, input *Collection) (*Collection, error) {
+	if input == nil {
+		input = new(Collection)
+	}
+	return r.client.Save(ctx, input)
+}
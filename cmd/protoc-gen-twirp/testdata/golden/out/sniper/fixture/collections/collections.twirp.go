@@ -0,0 +1,791 @@
+// Package collections is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: collections.proto
+package collections
+
+import strings "strings"
+import context "context"
+import fmt "fmt"
+import strconv "strconv"
+import io "io"
+import http "net/http"
+
+import protojson "google.golang.org/protobuf/encoding/protojson"
+import proto "google.golang.org/protobuf/proto"
+import ctxkit "sniper/util/ctxkit"
+import twirp "sniper/util/twirp"
+
+// If the request does not have any number filed, the strconv
+// is not needed. However, there is no easy way to drop it.
+var _ = strconv.IntSize
+var _ = ctxkit.GetUserID
+
+// =====================
+// Collections Interface
+// =====================
+
+type Collections interface {
+	// 查询列表
+	List(context.Context, *ListRequest) (*Collection, error)
+
+	Save(context.Context, *Collection) (*Collection, error)
+}
+
+// CollectionsListFunc is the signature of Collections.List.
+// Implementations can be checked at build time with a method value:
+//
+//	var _ = CollectionsListFunc((&Server{}).List)
+type CollectionsListFunc func(context.Context, *ListRequest) (*Collection, error)
+
+// CollectionsSaveFunc is the signature of Collections.Save.
+// Implementations can be checked at build time with a method value:
+//
+//	var _ = CollectionsSaveFunc((&Server{}).Save)
+type CollectionsSaveFunc func(context.Context, *Collection) (*Collection, error)
+
+// ===========================
+// Collections Protobuf Client
+// ===========================
+
+type collectionsProtobufClient struct {
+	client twirp.HTTPClient
+	urls   [2]string
+}
+
+// NewCollectionsProtobufClient creates a Protobuf client that implements the Collections interface.
+// It communicates using Protobuf and can be configured with a custom HTTPClient.
+func NewCollectionsProtobufClient(addr string, client twirp.HTTPClient) Collections {
+	prefix := addr + CollectionsPathPrefix
+	urls := [2]string{
+		prefix + "List",
+		prefix + "Save",
+	}
+	return &collectionsProtobufClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *collectionsProtobufClient) List(ctx context.Context, in *ListRequest) (*Collection, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.collections")
+	ctx = twirp.WithServiceName(ctx, "Collections")
+	ctx = twirp.WithMethodName(ctx, "List")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Collection)
+	err := twirp.DoProtobufRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectionsProtobufClient) Save(ctx context.Context, in *Collection) (*Collection, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.collections")
+	ctx = twirp.WithServiceName(ctx, "Collections")
+	ctx = twirp.WithMethodName(ctx, "Save")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Collection)
+	err := twirp.DoProtobufRequest(ctx, c.client, c.urls[1], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// =======================
+// Collections JSON Client
+// =======================
+
+type collectionsJSONClient struct {
+	client twirp.HTTPClient
+	urls   [2]string
+}
+
+// NewCollectionsJSONClient creates a JSON client that implements the Collections interface.
+// It communicates using JSON and can be configured with a custom HTTPClient.
+func NewCollectionsJSONClient(addr string, client twirp.HTTPClient) Collections {
+	prefix := addr + CollectionsPathPrefix
+	urls := [2]string{
+		prefix + "List",
+		prefix + "Save",
+	}
+	return &collectionsJSONClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *collectionsJSONClient) List(ctx context.Context, in *ListRequest) (*Collection, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.collections")
+	ctx = twirp.WithServiceName(ctx, "Collections")
+	ctx = twirp.WithMethodName(ctx, "List")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Collection)
+	err := twirp.DoJSONRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectionsJSONClient) Save(ctx context.Context, in *Collection) (*Collection, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.collections")
+	ctx = twirp.WithServiceName(ctx, "Collections")
+	ctx = twirp.WithMethodName(ctx, "Save")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Collection)
+	err := twirp.DoJSONRequest(ctx, c.client, c.urls[1], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ========================
+// Collections Codec Client
+// ========================
+
+type collectionsCodecClient struct {
+	client twirp.HTTPClient
+	urls   [2]string
+	codec  twirp.Codec
+}
+
+// NewCollectionsCodecClient creates a Codec client that implements the Collections interface.
+// It communicates using the given codec and can be configured with a custom HTTPClient.
+func NewCollectionsCodecClient(addr string, client twirp.HTTPClient, codec twirp.Codec) Collections {
+	prefix := addr + CollectionsPathPrefix
+	urls := [2]string{
+		prefix + "List",
+		prefix + "Save",
+	}
+	return &collectionsCodecClient{
+		client: client,
+		urls:   urls,
+		codec:  codec,
+	}
+}
+
+func (c *collectionsCodecClient) List(ctx context.Context, in *ListRequest) (*Collection, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.collections")
+	ctx = twirp.WithServiceName(ctx, "Collections")
+	ctx = twirp.WithMethodName(ctx, "List")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Collection)
+	err := twirp.DoCodecRequest(ctx, c.client, c.codec, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectionsCodecClient) Save(ctx context.Context, in *Collection) (*Collection, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.collections")
+	ctx = twirp.WithServiceName(ctx, "Collections")
+	ctx = twirp.WithMethodName(ctx, "Save")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Collection)
+	err := twirp.DoCodecRequest(ctx, c.client, c.codec, c.urls[1], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ==========================
+// Collections Server Handler
+// ==========================
+
+type collectionsServer struct {
+	Collections
+	hooks      *twirp.ServerHooks
+	canary     Collections
+	canaryRule *twirp.CanaryRule
+}
+
+// NewCollectionsServer creates a twirp server that serves svc.
+func NewCollectionsServer(svc Collections, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil {
+		panic("NewCollectionsServer: svc is nil")
+	}
+	return &collectionsServer{
+		Collections: svc,
+		hooks:       hooks,
+	}
+}
+
+// NewCollectionsCanaryServer creates a server that routes requests matching rule to canary,
+// and the others to svc.
+func NewCollectionsCanaryServer(svc, canary Collections, rule *twirp.CanaryRule, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil || canary == nil {
+		panic("NewCollectionsCanaryServer: svc or canary is nil")
+	}
+	return &collectionsServer{
+		Collections: svc,
+		hooks:       hooks,
+		canary:      canary,
+		canaryRule:  rule,
+	}
+}
+
+// writeError writes an HTTP response with a valid Twirp error format, and triggers hooks.
+// If err is not a twirp.Error, it will get wrapped with twirp.InternalErrorWith(err)
+func (s *collectionsServer) writeError(ctx context.Context, resp http.ResponseWriter, err error) {
+	s.hooks.WriteError(ctx, resp, err)
+}
+
+// badRouteError is used when the twirp server cannot route a request
+func (s *collectionsServer) badRouteError(msg string, method, url string) twirp.Error {
+	err := twirp.NewError(twirp.BadRoute, msg)
+	err = err.WithMeta("twirp_invalid_route", method+" "+url)
+	return err
+}
+
+func (s *collectionsServer) wrapErr(err error, msg string) error {
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// CollectionsPathPrefix is used for all URL paths on a twirp Collections server.
+// Requests are always: POST CollectionsPathPrefix/method
+// It can be used in an HTTP mux to route twirp requests along with non-twirp requests on other routes.
+const CollectionsPathPrefix = "/fixture.collections.Collections/"
+
+// Paths of all methods of Collections.
+const (
+	CollectionsListPath = "/fixture.collections.Collections/List"
+	CollectionsSavePath = "/fixture.collections.Collections/Save"
+)
+
+// CollectionsMethodNames contains names of all methods of Collections.
+var CollectionsMethodNames = []string{
+	"List",
+	"Save",
+}
+
+func init() {
+	twirp.RegisterMethod(twirp.MethodInfo{
+		Service:   "fixture.collections.Collections",
+		Method:    "List",
+		Path:      CollectionsListPath,
+		Source:    "collections.proto",
+		Generator: "v0.1.0",
+	})
+	twirp.RegisterMethod(twirp.MethodInfo{
+		Service:   "fixture.collections.Collections",
+		Method:    "Save",
+		Path:      CollectionsSavePath,
+		Source:    "collections.proto",
+		Generator: "v0.1.0",
+	})
+}
+
+func (s *collectionsServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ctx = twirp.WithHttpRequest(ctx, req)
+	ctx = twirp.WithPackageName(ctx, "fixture.collections")
+	ctx = twirp.WithServiceName(ctx, "Collections")
+	ctx = twirp.WithResponseWriter(ctx, resp)
+	ctx = twirp.WithResponseHeader(ctx)
+	ctx = twirp.WithServerTiming(ctx, req)
+
+	var err error
+	ctx, err = s.hooks.CallRequestReceived(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	if (req.Method == http.MethodHead || req.Method == http.MethodOptions) && s.allowGET(ctx, req.URL.Path) {
+		if req.Method == http.MethodOptions {
+			twirp.WriteOptions(ctx, resp, s.hooks)
+			return
+		}
+		var finish func()
+		resp, finish = twirp.HeadResponse(resp, req)
+		defer finish()
+		ctx = twirp.WithResponseWriter(ctx, resp)
+	}
+
+	if req.Method != http.MethodPost && !s.allowGET(ctx, req.URL.Path) {
+		msg := fmt.Sprintf("unsupported method %q (only POST is allowed)", req.Method)
+		err = s.badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	switch req.URL.Path {
+	case CollectionsListPath:
+		s.serveList(ctx, resp, req)
+	case CollectionsSavePath:
+		s.serveSave(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		s.writeError(ctx, resp, s.badRouteError(msg, req.Method, req.URL.Path))
+	}
+}
+
+// allowGET reports whether the method at path accepts GET requests, either by the @get
+// annotation or by hooks calling twirp.WithAllowGET.
+func (s *collectionsServer) allowGET(ctx context.Context, path string) bool {
+	return twirp.AllowGET(ctx)
+}
+
+func (s *collectionsServer) serveList(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityDefault)
+	var err error
+	ctx = twirp.WithMethodName(ctx, "List")
+	ctx, err = s.hooks.CallRequestRouted(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "route")
+
+	twirp.MarkServerTiming(ctx, "auth")
+
+	ctx, err = twirp.GzipRequest(ctx, req, 0)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveListJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveListProtobuf(ctx, resp, req)
+	case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":
+		twirp.ServeGRPCWeb(ctx, resp, req, s.serveListProtobuf)
+	default:
+		if codec, ok := twirp.LookupCodec(header); ok {
+			s.serveListCodec(ctx, resp, req, codec)
+			return
+		}
+		s.serveListForm(ctx, resp, req)
+	}
+}
+
+func (s *collectionsServer) serveListJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	reqContent := new(ListRequest)
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: twirp.AnyResolver}
+	if err = unmarshaler.Unmarshal(buf, reqContent); err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to parse request json")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleList(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *collectionsServer) serveListProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(ListRequest)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request proto")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleList(ctx, resp, reqContent, twirp.MarshalProtobuf)
+}
+
+func (s *collectionsServer) serveListCodec(ctx context.Context, resp http.ResponseWriter, req *http.Request, codec twirp.Codec) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(ListRequest)
+	if err = codec.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request "+codec.Name())
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleList(ctx, resp, reqContent, twirp.CodecMarshal(codec))
+}
+
+func (s *collectionsServer) serveListForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	err = req.ParseForm()
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(ListRequest)
+
+	if v, ok := req.Form["offset"]; ok {
+		vv, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("offset", err.Error()))
+			return
+		}
+		reqContent.Offset = vv
+	}
+	if v, ok := req.Form["limit"]; ok {
+		vv, err := strconv.ParseInt(v[0], 10, 32)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("limit", err.Error()))
+			return
+		}
+		reqContent.Limit = int32(vv)
+	}
+
+	s.handleList(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *collectionsServer) handleList(ctx context.Context, resp http.ResponseWriter, reqContent *ListRequest, marshal twirp.MarshalFunc) {
+	var err error
+	ctx = twirp.WithRequest(ctx, reqContent)
+	twirp.MarkServerTiming(ctx, "decode")
+	if validerr := reqContent.validate(); validerr != nil {
+		s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "validate")
+	// Call service method
+	var impl Collections = s.Collections
+	if s.canary != nil {
+		canary := s.canaryRule.Hit(ctx)
+		if canary {
+			impl = s.canary
+		}
+		ctx = twirp.WithCanary(ctx, canary)
+	}
+	var respContent *Collection
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = impl.List(ctx, reqContent)
+	}()
+	twirp.MarkServerTiming(ctx, "handler")
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *Collection and nil error while calling List. nil responses are not supported"))
+		return
+	}
+
+	twirp.WriteResponse(ctx, resp, s.hooks, respContent, marshal)
+}
+
+func (s *collectionsServer) serveSave(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityDefault)
+	var err error
+	ctx = twirp.WithMethodName(ctx, "Save")
+	ctx, err = s.hooks.CallRequestRouted(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "route")
+
+	twirp.MarkServerTiming(ctx, "auth")
+
+	ctx, err = twirp.GzipRequest(ctx, req, 0)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveSaveJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveSaveProtobuf(ctx, resp, req)
+	case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":
+		twirp.ServeGRPCWeb(ctx, resp, req, s.serveSaveProtobuf)
+	default:
+		if codec, ok := twirp.LookupCodec(header); ok {
+			s.serveSaveCodec(ctx, resp, req, codec)
+			return
+		}
+		s.serveSaveForm(ctx, resp, req)
+	}
+}
+
+func (s *collectionsServer) serveSaveJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	reqContent := new(Collection)
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: twirp.AnyResolver}
+	if err = unmarshaler.Unmarshal(buf, reqContent); err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to parse request json")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleSave(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *collectionsServer) serveSaveProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(Collection)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request proto")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleSave(ctx, resp, reqContent, twirp.MarshalProtobuf)
+}
+
+func (s *collectionsServer) serveSaveCodec(ctx context.Context, resp http.ResponseWriter, req *http.Request, codec twirp.Codec) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(Collection)
+	if err = codec.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request "+codec.Name())
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleSave(ctx, resp, reqContent, twirp.CodecMarshal(codec))
+}
+
+func (s *collectionsServer) serveSaveForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	err = req.ParseForm()
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(Collection)
+
+	if v, ok := req.Form["ids"]; ok {
+		if len(v) == 1 {
+			v = strings.Split(v[0], ",")
+		}
+		vs := make([]int64, 0, len(v))
+		for _, vv := range v {
+			vvv, err := strconv.ParseInt(vv, 10, 64)
+			if err != nil {
+				s.writeError(ctx, resp, twirp.InvalidArgumentError("ids", err.Error()))
+				return
+			}
+			vs = append(vs, vvv)
+		}
+		reqContent.Ids = vs
+	}
+	if v, ok := req.Form["names"]; ok {
+		if len(v) == 1 {
+			v = strings.Split(v[0], ",")
+		}
+		reqContent.Names = v
+	}
+	if v, ok := twirp.FormValues(req.Form, "key_id", "keyId"); ok {
+		vv, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("key_id", err.Error()))
+			return
+		}
+		reqContent.Key = &Collection_KeyId{KeyId: vv}
+	}
+	if v, ok := twirp.FormValues(req.Form, "key_name", "keyName"); ok {
+		reqContent.Key = &Collection_KeyName{KeyName: v[0]}
+	}
+
+	s.handleSave(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *collectionsServer) handleSave(ctx context.Context, resp http.ResponseWriter, reqContent *Collection, marshal twirp.MarshalFunc) {
+	var err error
+	ctx = twirp.WithRequest(ctx, reqContent)
+	twirp.MarkServerTiming(ctx, "decode")
+	if validerr := reqContent.validate(); validerr != nil {
+		s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "validate")
+	// Call service method
+	var impl Collections = s.Collections
+	if s.canary != nil {
+		canary := s.canaryRule.Hit(ctx)
+		if canary {
+			impl = s.canary
+		}
+		ctx = twirp.WithCanary(ctx, canary)
+	}
+	var respContent *Collection
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = impl.Save(ctx, reqContent)
+	}()
+	twirp.MarkServerTiming(ctx, "handler")
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *Collection and nil error while calling Save. nil responses are not supported"))
+		return
+	}
+
+	twirp.WriteResponse(ctx, resp, s.hooks, respContent, marshal)
+}
+
+func (s *collectionsServer) ServiceDescriptor() ([]byte, int) {
+	return twirpFileDescriptor1SHA350227260deb9ee17c288047d9560e65b4b06983, 0
+}
+
+func (s *collectionsServer) ProtocGenTwirpVersion() string {
+	return "v0.1.0"
+}
+
+// Compile-time assertions of the Collections server and clients.
+var (
+	_ twirp.Server = (*collectionsServer)(nil)
+	_ Collections  = (*collectionsProtobufClient)(nil)
+	_ Collections  = (*collectionsJSONClient)(nil)
+	_ Collections  = (*collectionsCodecClient)(nil)
+)
+
+var twirpFileDescriptor1SHA350227260deb9ee17c288047d9560e65b4b06983 = []byte{
+	// 497 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x5d, 0x6b, 0xdb, 0x30,
+	0x14, 0x8d, 0x2d, 0x3b, 0x6d, 0x6e, 0x58, 0x97, 0xde, 0x7d, 0x69, 0xd9, 0xc3, 0x4c, 0x1e, 0x86,
+	0xc9, 0x20, 0x19, 0x19, 0x8c, 0x7d, 0xc0, 0x18, 0x4d, 0xbd, 0xc5, 0x50, 0xd2, 0xa1, 0xb0, 0x97,
+	0xbd, 0x94, 0x2c, 0x56, 0x40, 0xc4, 0x1f, 0x9d, 0xa5, 0x94, 0xe6, 0xef, 0xec, 0x4f, 0xed, 0xef,
+	0x0c, 0x49, 0x26, 0xf1, 0x43, 0xd6, 0xf4, 0xed, 0x5e, 0xeb, 0x9c, 0xa3, 0xe3, 0x73, 0x8c, 0xe1,
+	0x74, 0x51, 0xa4, 0x29, 0x5f, 0x28, 0x51, 0xe4, 0x72, 0x70, 0x5d, 0x16, 0xaa, 0xc0, 0x47, 0x4b,
+	0x71, 0xab, 0xd6, 0x25, 0x1f, 0xd4, 0x8e, 0x7a, 0x7d, 0xf0, 0x62, 0xc5, 0x33, 0x3c, 0x01, 0x57,
+	0x24, 0xd4, 0x09, 0x9c, 0x90, 0x30, 0x57, 0x24, 0x88, 0xe0, 0xe5, 0xf3, 0x8c, 0x53, 0x37, 0x70,
+	0xc2, 0x16, 0x33, 0x73, 0xef, 0xaf, 0x07, 0x30, 0xde, 0x72, 0xb1, 0x03, 0x44, 0x24, 0x92, 0x3a,
+	0x01, 0x09, 0x09, 0xd3, 0x23, 0x3e, 0x06, 0x5f, 0x03, 0x25, 0x75, 0x03, 0x12, 0xb6, 0x98, 0x5d,
+	0x70, 0x08, 0xbe, 0x50, 0x3c, 0x93, 0x94, 0x04, 0x24, 0x6c, 0x8f, 0x9e, 0x0f, 0xf6, 0xf8, 0x18,
+	0x68, 0x13, 0xcc, 0xe2, 0x70, 0x0c, 0xcd, 0x45, 0xb1, 0xce, 0x95, 0xa4, 0x9e, 0x61, 0xbc, 0xde,
+	0xcb, 0xd8, 0x39, 0x19, 0x8c, 0x0d, 0x3a, 0xca, 0x55, 0xb9, 0x61, 0x15, 0x15, 0xbf, 0x80, 0x2f,
+	0xf2, 0x84, 0xdf, 0x52, 0xdf, 0x68, 0xf4, 0x0f, 0x69, 0xc4, 0x1a, 0x6c, 0x25, 0x2c, 0x11, 0xdf,
+	0x80, 0xbf, 0x28, 0xd2, 0xa2, 0xa4, 0xcd, 0xc0, 0x09, 0x4f, 0x46, 0xdd, 0xff, 0x29, 0x14, 0x25,
+	0xb3, 0x40, 0x1c, 0x69, 0xe3, 0x69, 0x51, 0x4a, 0x7a, 0x14, 0x90, 0x03, 0x94, 0x0a, 0x89, 0xcf,
+	0xa0, 0xb9, 0xe2, 0x9b, 0x2b, 0x91, 0xd0, 0x63, 0x1d, 0xfe, 0xa4, 0xc1, 0xfc, 0x15, 0xdf, 0xc4,
+	0x09, 0xbe, 0x80, 0x63, 0x7d, 0x60, 0x5a, 0x68, 0xe9, 0x16, 0x26, 0x0d, 0x76, 0xb4, 0xe2, 0x9b,
+	0xe9, 0x3c, 0xe3, 0xf8, 0xce, 0x1e, 0xea, 0xbc, 0x28, 0x04, 0xce, 0x9d, 0xb1, 0x56, 0x3c, 0x3d,
+	0x76, 0x3f, 0x40, 0xbb, 0x16, 0x96, 0xae, 0x70, 0xc5, 0x37, 0xa6, 0xf6, 0x16, 0xd3, 0xa3, 0xae,
+	0xf0, 0x66, 0x9e, 0xae, 0x6d, 0xf1, 0x84, 0xd9, 0xe5, 0xa3, 0xfb, 0xde, 0xe9, 0xce, 0x00, 0x76,
+	0x19, 0xd5, 0x99, 0xc4, 0x32, 0x87, 0x75, 0xe6, 0xdd, 0x35, 0x6f, 0x45, 0xcf, 0x7c, 0x23, 0xd3,
+	0xcb, 0xa0, 0x7d, 0x21, 0xa4, 0x62, 0xfc, 0xf7, 0x9a, 0x4b, 0x85, 0x4f, 0xa1, 0x59, 0x2c, 0x97,
+	0x92, 0xab, 0x4a, 0xbf, 0xda, 0xb4, 0xb9, 0x54, 0x64, 0x42, 0x99, 0x2b, 0x7c, 0x66, 0x97, 0x5d,
+	0x4f, 0xe4, 0x9e, 0x3d, 0xf5, 0x3f, 0x83, 0x6f, 0x76, 0x7c, 0x02, 0xa7, 0xe3, 0xcb, 0x8b, 0x4b,
+	0x76, 0xf5, 0x63, 0x3a, 0xfb, 0x1e, 0x8d, 0xe3, 0xaf, 0x71, 0x74, 0xde, 0x69, 0xe0, 0x03, 0x68,
+	0xd9, 0xc7, 0x2c, 0x3a, 0xef, 0x38, 0xf8, 0x10, 0xda, 0x76, 0xfd, 0xc6, 0xa2, 0x68, 0xda, 0x71,
+	0x47, 0x7f, 0x1c, 0x1d, 0xe3, 0x56, 0x1c, 0x63, 0xf0, 0xb4, 0x7d, 0x0c, 0xf6, 0x5e, 0x5d, 0x7b,
+	0xb3, 0xee, 0xcb, 0x03, 0x9f, 0x21, 0x4e, 0xc0, 0x9b, 0xcd, 0x6f, 0x38, 0x1e, 0x02, 0x1e, 0x54,
+	0x3a, 0x0b, 0x7f, 0xbe, 0x92, 0xb9, 0xb8, 0xe6, 0xe5, 0xb0, 0x02, 0x0e, 0x6b, 0xc0, 0x4f, 0xb5,
+	0xf9, 0x57, 0xd3, 0xfc, 0x1f, 0xde, 0xfe, 0x1b, 0x00, 0x9e, 0x9c, 0x48, 0x49, 0x34, 0x04, 0x00,
+	0x00,
+}
+
+func init() {
+	// JSON 中的 google.protobuf.Any 字段可以解析本文件定义的类型
+	twirp.RegisterFileDescriptor(twirpFileDescriptor1SHA350227260deb9ee17c288047d9560e65b4b06983)
+}
@@ -0,0 +1,265 @@
+package collections
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ensure the imports are used
+var (
+	_ = fmt.Print
+	_ = utf8.UTFMax
+	_ = (*regexp.Regexp)(nil)
+	_ = (*strings.Reader)(nil)
+	_ = net.IPv4len
+	_ = (*url.URL)(nil)
+)
+
+func (m *Item) validate() error {
+	if m == nil {
+		return nil
+	}
+
+	return nil
+}
+
+// validateAll 与 validate 相同，但是检查所有字段，返回全部错误
+func (m *Item) validateAll() error {
+	if m == nil {
+		return nil
+	}
+
+	var errs ItemMultiError
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ItemMultiError is the list of errors returned by validateAll
+type ItemMultiError []error
+
+// Error satisfies the builtin error interface
+func (e ItemMultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type ItemValidationError struct {
+	field  string
+	reason string
+}
+
+// Error satisfies the builtin error interface
+func (e ItemValidationError) Error() string {
+	return fmt.Sprintf(
+		"invalid Item.%s: %s",
+		e.field,
+		e.reason)
+}
+
+func (m *Collection) validate() error {
+	if m == nil {
+		return nil
+	}
+
+	for _, item := range m.GetItems() {
+		if v, ok := interface{}(item).(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return CollectionValidationError{
+					field:  "Items",
+					reason: "embedded message failed validation " + err.Error(),
+				}
+			}
+		}
+	}
+
+	if v, ok := interface{}(m.GetCounts()).(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			return CollectionValidationError{
+				field:  "Counts",
+				reason: "embedded message failed validation " + err.Error(),
+			}
+		}
+	}
+
+	if v, ok := interface{}(m.GetIndex()).(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			return CollectionValidationError{
+				field:  "Index",
+				reason: "embedded message failed validation " + err.Error(),
+			}
+		}
+	}
+
+	if v, ok := interface{}(m.GetKeyItem()).(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			return CollectionValidationError{
+				field:  "KeyItem",
+				reason: "embedded message failed validation " + err.Error(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAll 与 validate 相同，但是检查所有字段，返回全部错误
+func (m *Collection) validateAll() error {
+	if m == nil {
+		return nil
+	}
+
+	var errs CollectionMultiError
+
+	if err := func() error {
+
+		for _, item := range m.GetItems() {
+			if v, ok := interface{}(item).(interface{ validate() error }); ok {
+				if err := v.validate(); err != nil {
+					return CollectionValidationError{
+						field:  "Items",
+						reason: "embedded message failed validation " + err.Error(),
+					}
+				}
+			}
+		}
+
+		return nil
+	}(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := func() error {
+
+		if v, ok := interface{}(m.GetCounts()).(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return CollectionValidationError{
+					field:  "Counts",
+					reason: "embedded message failed validation " + err.Error(),
+				}
+			}
+		}
+
+		return nil
+	}(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := func() error {
+
+		if v, ok := interface{}(m.GetIndex()).(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return CollectionValidationError{
+					field:  "Index",
+					reason: "embedded message failed validation " + err.Error(),
+				}
+			}
+		}
+
+		return nil
+	}(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := func() error {
+
+		if v, ok := interface{}(m.GetKeyItem()).(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return CollectionValidationError{
+					field:  "KeyItem",
+					reason: "embedded message failed validation " + err.Error(),
+				}
+			}
+		}
+
+		return nil
+	}(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// CollectionMultiError is the list of errors returned by validateAll
+type CollectionMultiError []error
+
+// Error satisfies the builtin error interface
+func (e CollectionMultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type CollectionValidationError struct {
+	field  string
+	reason string
+}
+
+// Error satisfies the builtin error interface
+func (e CollectionValidationError) Error() string {
+	return fmt.Sprintf(
+		"invalid Collection.%s: %s",
+		e.field,
+		e.reason)
+}
+
+func (m *ListRequest) validate() error {
+	if m == nil {
+		return nil
+	}
+
+	return nil
+}
+
+// validateAll 与 validate 相同，但是检查所有字段，返回全部错误
+func (m *ListRequest) validateAll() error {
+	if m == nil {
+		return nil
+	}
+
+	var errs ListRequestMultiError
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ListRequestMultiError is the list of errors returned by validateAll
+type ListRequestMultiError []error
+
+// Error satisfies the builtin error interface
+func (e ListRequestMultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type ListRequestValidationError struct {
+	field  string
+	reason string
+}
+
+// Error satisfies the builtin error interface
+func (e ListRequestValidationError) Error() string {
+	return fmt.Sprintf(
+		"invalid ListRequest.%s: %s",
+		e.field,
+		e.reason)
+}
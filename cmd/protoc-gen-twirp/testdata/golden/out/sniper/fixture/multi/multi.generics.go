@@ -0,0 +1,113 @@
+//go:build go1.18
+
+// Package multi is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: multi.proto
+package multi
+
+import context "context"
+
+import twirp "sniper/util/twirp"
+
+import common "sniper/fixture/common"
+
+type usersServerConfig struct {
+	hooks  *twirp.ServerHooks
+	get    []func(context.Context, *common.UserID) error
+	update []func(context.Context, *common.User) error
+}
+
+// UsersServerOption configures the server created by NewUsersTypedServer.
+type UsersServerOption func(*usersServerConfig)
+
+// WithUsersHooks sets the server hooks.
+func WithUsersHooks(hooks *twirp.ServerHooks) UsersServerOption {
+	return func(c *usersServerConfig) { c.hooks = hooks }
+}
+
+// OnUsersGet adds an interceptor of Get, which is called after
+// the request is validated. If it returns an error, Get is not called.
+func OnUsersGet(fn func(context.Context, *common.UserID) error) UsersServerOption {
+	return func(c *usersServerConfig) { c.get = append(c.get, fn) }
+}
+
+// OnUsersUpdate adds an interceptor of Update, which is called after
+// the request is validated. If it returns an error, Update is not called.
+func OnUsersUpdate(fn func(context.Context, *common.User) error) UsersServerOption {
+	return func(c *usersServerConfig) { c.update = append(c.update, fn) }
+}
+
+// NewUsersTypedServer creates a server of svc like NewUsersServer,
+// with typed interceptors registered by OnUsers{Method} options.
+func NewUsersTypedServer[T Users](svc T, opts ...UsersServerOption) twirp.Server {
+	c := &usersServerConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return NewUsersServer(&usersTypedService[T]{svc: svc, config: c}, c.hooks)
+}
+
+type usersTypedService[T Users] struct {
+	svc    T
+	config *usersServerConfig
+}
+
+func (s *usersTypedService[T]) Get(ctx context.Context, req *common.UserID) (*common.User, error) {
+	for _, fn := range s.config.get {
+		if err := fn(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return s.svc.Get(ctx, req)
+}
+
+func (s *usersTypedService[T]) Update(ctx context.Context, req *common.User) (*Empty, error) {
+	for _, fn := range s.config.update {
+		if err := fn(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return s.svc.Update(ctx, req)
+}
+
+type adminServerConfig struct {
+	hooks  *twirp.ServerHooks
+	delete []func(context.Context, *common.UserID) error
+}
+
+// AdminServerOption configures the server created by NewAdminTypedServer.
+type AdminServerOption func(*adminServerConfig)
+
+// WithAdminHooks sets the server hooks.
+func WithAdminHooks(hooks *twirp.ServerHooks) AdminServerOption {
+	return func(c *adminServerConfig) { c.hooks = hooks }
+}
+
+// OnAdminDelete adds an interceptor of Delete, which is called after
+// the request is validated. If it returns an error, Delete is not called.
+func OnAdminDelete(fn func(context.Context, *common.UserID) error) AdminServerOption {
+	return func(c *adminServerConfig) { c.delete = append(c.delete, fn) }
+}
+
+// NewAdminTypedServer creates a server of svc like NewAdminServer,
+// with typed interceptors registered by OnAdmin{Method} options.
+func NewAdminTypedServer[T Admin](svc T, opts ...AdminServerOption) twirp.Server {
+	c := &adminServerConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return NewAdminServer(&adminTypedService[T]{svc: svc, config: c}, c.hooks)
+}
+
+type adminTypedService[T Admin] struct {
+	svc    T
+	config *adminServerConfig
+}
+
+func (s *adminTypedService[T]) Delete(ctx context.Context, req *common.UserID) (*Empty, error) {
+	for _, fn := range s.config.delete {
+		if err := fn(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return s.svc.Delete(ctx, req)
+}
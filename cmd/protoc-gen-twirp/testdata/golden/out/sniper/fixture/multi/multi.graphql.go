@@ -0,0 +1,51 @@
+// Package multi is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: multi.proto
+package multi
+
+import context "context"
+
+import common "sniper/fixture/common"
+
+// UsersGraphQLResolver resolves the GraphQL fields of Users by calling a twirp client.
+type UsersGraphQLResolver struct {
+	client Users
+}
+
+// NewUsersGraphQLResolver creates a resolver, client is usually created by NewUsersJSONClient.
+func NewUsersGraphQLResolver(client Users) *UsersGraphQLResolver {
+	return &UsersGraphQLResolver{client: client}
+}
+
+// UsersGet resolves usersGet.
+func (r *UsersGraphQLResolver) UsersGet(ctx context.Context, input *common.UserID) (*common.User, error) {
+	if input == nil {
+		input = new(common.UserID)
+	}
+	return r.client.Get(ctx, input)
+}
+
+// UsersUpdate resolves usersUpdate.
+func (r *UsersGraphQLResolver) UsersUpdate(ctx context.Context, input *common.User) (*Empty, error) {
+	if input == nil {
+		input = new(common.User)
+	}
+	return r.client.Update(ctx, input)
+}
+
+// AdminGraphQLResolver resolves the GraphQL fields of Admin by calling a twirp client.
+type AdminGraphQLResolver struct {
+	client Admin
+}
+
+// NewAdminGraphQLResolver creates a resolver, client is usually created by NewAdminJSONClient.
+func NewAdminGraphQLResolver(client Admin) *AdminGraphQLResolver {
+	return &AdminGraphQLResolver{client: client}
+}
+
+// AdminDelete resolves adminDelete.
+func (r *AdminGraphQLResolver) AdminDelete(ctx context.Context, input *common.UserID) (*Empty, error) {
+	if input == nil {
+		input = new(common.UserID)
+	}
+	return r.client.Delete(ctx, input)
+}
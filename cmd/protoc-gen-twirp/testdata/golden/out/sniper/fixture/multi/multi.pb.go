@@ -0,0 +1,154 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.1
+// 	protoc        (unknown)
+// source: multi.proto
+
+package multi
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	common "sniper/fixture/common"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Empty struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_multi_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_multi_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_multi_proto_rawDescGZIP(), []int{0}
+}
+
+var File_multi_proto protoreflect.FileDescriptor
+
+var file_multi_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0d, 0x66,
+	0x69, 0x78, 0x74, 0x75, 0x72, 0x65, 0x2e, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x1a, 0x11, 0x63, 0x6f,
+	0x6d, 0x6d, 0x6f, 0x6e, 0x2f, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0x07, 0x0a, 0x05, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x32, 0x72, 0x0a, 0x05, 0x55, 0x73, 0x65, 0x72,
+	0x73, 0x12, 0x33, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x16, 0x2e, 0x66, 0x69, 0x78, 0x74, 0x75,
+	0x72, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44,
+	0x1a, 0x14, 0x2e, 0x66, 0x69, 0x78, 0x74, 0x75, 0x72, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f,
+	0x6e, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x12, 0x34, 0x0a, 0x06, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x12, 0x14, 0x2e, 0x66, 0x69, 0x78, 0x74, 0x75, 0x72, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f,
+	0x6e, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x1a, 0x14, 0x2e, 0x66, 0x69, 0x78, 0x74, 0x75, 0x72, 0x65,
+	0x2e, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x32, 0x3f, 0x0a, 0x05,
+	0x41, 0x64, 0x6d, 0x69, 0x6e, 0x12, 0x36, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12,
+	0x16, 0x2e, 0x66, 0x69, 0x78, 0x74, 0x75, 0x72, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e,
+	0x2e, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44, 0x1a, 0x14, 0x2e, 0x66, 0x69, 0x78, 0x74, 0x75, 0x72,
+	0x65, 0x2e, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x42, 0x1c, 0x5a,
+	0x1a, 0x73, 0x6e, 0x69, 0x70, 0x65, 0x72, 0x2f, 0x66, 0x69, 0x78, 0x74, 0x75, 0x72, 0x65, 0x2f,
+	0x6d, 0x75, 0x6c, 0x74, 0x69, 0x3b, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_multi_proto_rawDescOnce sync.Once
+	file_multi_proto_rawDescData = file_multi_proto_rawDesc
+)
+
+func file_multi_proto_rawDescGZIP() []byte {
+	file_multi_proto_rawDescOnce.Do(func() {
+		file_multi_proto_rawDescData = protoimpl.X.CompressGZIP(file_multi_proto_rawDescData)
+	})
+	return file_multi_proto_rawDescData
+}
+
+var file_multi_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_multi_proto_goTypes = []interface{}{
+	(*Empty)(nil),         // 0: fixture.multi.Empty
+	(*common.UserID)(nil), // 1: fixture.common.UserID
+	(*common.User)(nil),   // 2: fixture.common.User
+}
+var file_multi_proto_depIdxs = []int32{
+	1, // 0: fixture.multi.Users.Get:input_type -> fixture.common.UserID
+	2, // 1: fixture.multi.Users.Update:input_type -> fixture.common.User
+	1, // 2: fixture.multi.Admin.Delete:input_type -> fixture.common.UserID
+	2, // 3: fixture.multi.Users.Get:output_type -> fixture.common.User
+	0, // 4: fixture.multi.Users.Update:output_type -> fixture.multi.Empty
+	0, // 5: fixture.multi.Admin.Delete:output_type -> fixture.multi.Empty
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_multi_proto_init() }
+func file_multi_proto_init() {
+	if File_multi_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_multi_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Empty); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_multi_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_multi_proto_goTypes,
+		DependencyIndexes: file_multi_proto_depIdxs,
+		MessageInfos:      file_multi_proto_msgTypes,
+	}.Build()
+	File_multi_proto = out.File
+	file_multi_proto_rawDesc = nil
+	file_multi_proto_goTypes = nil
+	file_multi_proto_depIdxs = nil
+}
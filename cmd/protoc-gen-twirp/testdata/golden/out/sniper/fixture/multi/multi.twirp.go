@@ -0,0 +1,1221 @@
+// Package multi is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: multi.proto
+//
+// imports:
+//
+//	common sniper/fixture/common
+package multi
+
+import strings "strings"
+import context "context"
+import fmt "fmt"
+import strconv "strconv"
+import io "io"
+import http "net/http"
+
+import protojson "google.golang.org/protobuf/encoding/protojson"
+import proto "google.golang.org/protobuf/proto"
+import ctxkit "sniper/util/ctxkit"
+import twirp "sniper/util/twirp"
+
+import common "sniper/fixture/common"
+
+// If the request does not have any number filed, the strconv
+// is not needed. However, there is no easy way to drop it.
+var _ = strconv.IntSize
+var _ = ctxkit.GetUserID
+
+// ===============
+// Users Interface
+// ===============
+
+// 请求和响应都是其他包中的 message
+type Users interface {
+	// @cache:1m
+	Get(context.Context, *common.UserID) (*common.User, error)
+
+	Update(context.Context, *common.User) (*Empty, error)
+}
+
+// UsersGetFunc is the signature of Users.Get.
+// Implementations can be checked at build time with a method value:
+//
+//	var _ = UsersGetFunc((&Server{}).Get)
+type UsersGetFunc func(context.Context, *common.UserID) (*common.User, error)
+
+// UsersUpdateFunc is the signature of Users.Update.
+// Implementations can be checked at build time with a method value:
+//
+//	var _ = UsersUpdateFunc((&Server{}).Update)
+type UsersUpdateFunc func(context.Context, *common.User) (*Empty, error)
+
+// =====================
+// Users Protobuf Client
+// =====================
+
+type usersProtobufClient struct {
+	client twirp.HTTPClient
+	urls   [2]string
+}
+
+// NewUsersProtobufClient creates a Protobuf client that implements the Users interface.
+// It communicates using Protobuf and can be configured with a custom HTTPClient.
+func NewUsersProtobufClient(addr string, client twirp.HTTPClient) Users {
+	prefix := addr + UsersPathPrefix
+	urls := [2]string{
+		prefix + "Get",
+		prefix + "Update",
+	}
+	return &usersProtobufClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *usersProtobufClient) Get(ctx context.Context, in *common.UserID) (*common.User, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Users")
+	ctx = twirp.WithMethodName(ctx, "Get")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(common.User)
+	err := twirp.DoProtobufRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersProtobufClient) Update(ctx context.Context, in *common.User) (*Empty, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Users")
+	ctx = twirp.WithMethodName(ctx, "Update")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Empty)
+	err := twirp.DoProtobufRequest(ctx, c.client, c.urls[1], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// =================
+// Users JSON Client
+// =================
+
+type usersJSONClient struct {
+	client twirp.HTTPClient
+	urls   [2]string
+}
+
+// NewUsersJSONClient creates a JSON client that implements the Users interface.
+// It communicates using JSON and can be configured with a custom HTTPClient.
+func NewUsersJSONClient(addr string, client twirp.HTTPClient) Users {
+	prefix := addr + UsersPathPrefix
+	urls := [2]string{
+		prefix + "Get",
+		prefix + "Update",
+	}
+	return &usersJSONClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *usersJSONClient) Get(ctx context.Context, in *common.UserID) (*common.User, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Users")
+	ctx = twirp.WithMethodName(ctx, "Get")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(common.User)
+	err := twirp.DoJSONRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersJSONClient) Update(ctx context.Context, in *common.User) (*Empty, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Users")
+	ctx = twirp.WithMethodName(ctx, "Update")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Empty)
+	err := twirp.DoJSONRequest(ctx, c.client, c.urls[1], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ==================
+// Users Codec Client
+// ==================
+
+type usersCodecClient struct {
+	client twirp.HTTPClient
+	urls   [2]string
+	codec  twirp.Codec
+}
+
+// NewUsersCodecClient creates a Codec client that implements the Users interface.
+// It communicates using the given codec and can be configured with a custom HTTPClient.
+func NewUsersCodecClient(addr string, client twirp.HTTPClient, codec twirp.Codec) Users {
+	prefix := addr + UsersPathPrefix
+	urls := [2]string{
+		prefix + "Get",
+		prefix + "Update",
+	}
+	return &usersCodecClient{
+		client: client,
+		urls:   urls,
+		codec:  codec,
+	}
+}
+
+func (c *usersCodecClient) Get(ctx context.Context, in *common.UserID) (*common.User, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Users")
+	ctx = twirp.WithMethodName(ctx, "Get")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(common.User)
+	err := twirp.DoCodecRequest(ctx, c.client, c.codec, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersCodecClient) Update(ctx context.Context, in *common.User) (*Empty, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Users")
+	ctx = twirp.WithMethodName(ctx, "Update")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Empty)
+	err := twirp.DoCodecRequest(ctx, c.client, c.codec, c.urls[1], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ====================
+// Users Server Handler
+// ====================
+
+type usersServer struct {
+	Users
+	hooks      *twirp.ServerHooks
+	canary     Users
+	canaryRule *twirp.CanaryRule
+}
+
+// NewUsersServer creates a twirp server that serves svc.
+func NewUsersServer(svc Users, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil {
+		panic("NewUsersServer: svc is nil")
+	}
+	return &usersServer{
+		Users: svc,
+		hooks: hooks,
+	}
+}
+
+// NewUsersCanaryServer creates a server that routes requests matching rule to canary,
+// and the others to svc.
+func NewUsersCanaryServer(svc, canary Users, rule *twirp.CanaryRule, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil || canary == nil {
+		panic("NewUsersCanaryServer: svc or canary is nil")
+	}
+	return &usersServer{
+		Users:      svc,
+		hooks:      hooks,
+		canary:     canary,
+		canaryRule: rule,
+	}
+}
+
+// writeError writes an HTTP response with a valid Twirp error format, and triggers hooks.
+// If err is not a twirp.Error, it will get wrapped with twirp.InternalErrorWith(err)
+func (s *usersServer) writeError(ctx context.Context, resp http.ResponseWriter, err error) {
+	s.hooks.WriteError(ctx, resp, err)
+}
+
+// badRouteError is used when the twirp server cannot route a request
+func (s *usersServer) badRouteError(msg string, method, url string) twirp.Error {
+	err := twirp.NewError(twirp.BadRoute, msg)
+	err = err.WithMeta("twirp_invalid_route", method+" "+url)
+	return err
+}
+
+func (s *usersServer) wrapErr(err error, msg string) error {
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// UsersPathPrefix is used for all URL paths on a twirp Users server.
+// Requests are always: POST UsersPathPrefix/method
+// It can be used in an HTTP mux to route twirp requests along with non-twirp requests on other routes.
+const UsersPathPrefix = "/fixture.multi.Users/"
+
+// Paths of all methods of Users.
+const (
+	UsersGetPath    = "/fixture.multi.Users/Get"
+	UsersUpdatePath = "/fixture.multi.Users/Update"
+)
+
+// UsersMethodNames contains names of all methods of Users.
+var UsersMethodNames = []string{
+	"Get",
+	"Update",
+}
+
+func init() {
+	twirp.RegisterMethod(twirp.MethodInfo{
+		Service:   "fixture.multi.Users",
+		Method:    "Get",
+		Path:      UsersGetPath,
+		Source:    "multi.proto",
+		Generator: "v0.1.0",
+	})
+	twirp.RegisterMethod(twirp.MethodInfo{
+		Service:   "fixture.multi.Users",
+		Method:    "Update",
+		Path:      UsersUpdatePath,
+		Source:    "multi.proto",
+		Generator: "v0.1.0",
+	})
+}
+
+func (s *usersServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ctx = twirp.WithHttpRequest(ctx, req)
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Users")
+	ctx = twirp.WithResponseWriter(ctx, resp)
+	ctx = twirp.WithResponseHeader(ctx)
+	ctx = twirp.WithServerTiming(ctx, req)
+
+	var err error
+	ctx, err = s.hooks.CallRequestReceived(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	if (req.Method == http.MethodHead || req.Method == http.MethodOptions) && s.allowGET(ctx, req.URL.Path) {
+		if req.Method == http.MethodOptions {
+			twirp.WriteOptions(ctx, resp, s.hooks)
+			return
+		}
+		var finish func()
+		resp, finish = twirp.HeadResponse(resp, req)
+		defer finish()
+		ctx = twirp.WithResponseWriter(ctx, resp)
+	}
+
+	if req.Method != http.MethodPost && !s.allowGET(ctx, req.URL.Path) {
+		msg := fmt.Sprintf("unsupported method %q (only POST is allowed)", req.Method)
+		err = s.badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	switch req.URL.Path {
+	case UsersGetPath:
+		s.serveGet(ctx, resp, req)
+	case UsersUpdatePath:
+		s.serveUpdate(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		s.writeError(ctx, resp, s.badRouteError(msg, req.Method, req.URL.Path))
+	}
+}
+
+// allowGET reports whether the method at path accepts GET requests, either by the @get
+// annotation or by hooks calling twirp.WithAllowGET.
+func (s *usersServer) allowGET(ctx context.Context, path string) bool {
+	return twirp.AllowGET(ctx)
+}
+
+var usersServerGetResponseCache = twirp.NewResponseCache[*common.User](UsersGetPath, 60000000000) // 1m0s
+
+func (s *usersServer) serveGet(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityDefault)
+	var err error
+	ctx = twirp.WithMethodName(ctx, "Get")
+	ctx, err = s.hooks.CallRequestRouted(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "route")
+
+	twirp.MarkServerTiming(ctx, "auth")
+
+	ctx, err = twirp.GzipRequest(ctx, req, 0)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveGetJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveGetProtobuf(ctx, resp, req)
+	case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":
+		twirp.ServeGRPCWeb(ctx, resp, req, s.serveGetProtobuf)
+	default:
+		if codec, ok := twirp.LookupCodec(header); ok {
+			s.serveGetCodec(ctx, resp, req, codec)
+			return
+		}
+		s.serveGetForm(ctx, resp, req)
+	}
+}
+
+func (s *usersServer) serveGetJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	reqContent := new(common.UserID)
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: twirp.AnyResolver}
+	if err = unmarshaler.Unmarshal(buf, reqContent); err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to parse request json")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleGet(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *usersServer) serveGetProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(common.UserID)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request proto")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleGet(ctx, resp, reqContent, twirp.MarshalProtobuf)
+}
+
+func (s *usersServer) serveGetCodec(ctx context.Context, resp http.ResponseWriter, req *http.Request, codec twirp.Codec) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(common.UserID)
+	if err = codec.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request "+codec.Name())
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleGet(ctx, resp, reqContent, twirp.CodecMarshal(codec))
+}
+
+func (s *usersServer) serveGetForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	err = req.ParseForm()
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(common.UserID)
+
+	if v, ok := req.Form["id"]; ok {
+		vv, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("id", err.Error()))
+			return
+		}
+		reqContent.Id = vv
+	}
+
+	s.handleGet(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *usersServer) handleGet(ctx context.Context, resp http.ResponseWriter, reqContent *common.UserID, marshal twirp.MarshalFunc) {
+	var err error
+	ctx = twirp.WithRequest(ctx, reqContent)
+	twirp.MarkServerTiming(ctx, "decode")
+	if v, ok := interface{}(reqContent).(interface{ Validate() error }); ok {
+		if validerr := v.Validate(); validerr != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))
+			return
+		}
+	}
+
+	twirp.MarkServerTiming(ctx, "validate")
+	// Call service method
+	var impl Users = s.Users
+	if s.canary != nil {
+		canary := s.canaryRule.Hit(ctx)
+		if canary {
+			impl = s.canary
+		}
+		ctx = twirp.WithCanary(ctx, canary)
+	}
+	var respContent *common.User
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = twirp.CachedCall(ctx, usersServerGetResponseCache, UsersGetPath, reqContent, func(ctx context.Context) (*common.User, error) {
+			return impl.Get(ctx, reqContent)
+		})
+	}()
+	twirp.MarkServerTiming(ctx, "handler")
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *common.User and nil error while calling Get. nil responses are not supported"))
+		return
+	}
+
+	twirp.WriteResponse(ctx, resp, s.hooks, respContent, marshal)
+}
+
+func (s *usersServer) serveUpdate(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityDefault)
+	var err error
+	ctx = twirp.WithMethodName(ctx, "Update")
+	ctx, err = s.hooks.CallRequestRouted(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "route")
+
+	twirp.MarkServerTiming(ctx, "auth")
+
+	ctx, err = twirp.GzipRequest(ctx, req, 0)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveUpdateJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveUpdateProtobuf(ctx, resp, req)
+	case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":
+		twirp.ServeGRPCWeb(ctx, resp, req, s.serveUpdateProtobuf)
+	default:
+		if codec, ok := twirp.LookupCodec(header); ok {
+			s.serveUpdateCodec(ctx, resp, req, codec)
+			return
+		}
+		s.serveUpdateForm(ctx, resp, req)
+	}
+}
+
+func (s *usersServer) serveUpdateJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	reqContent := new(common.User)
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: twirp.AnyResolver}
+	if err = unmarshaler.Unmarshal(buf, reqContent); err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to parse request json")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleUpdate(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *usersServer) serveUpdateProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(common.User)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request proto")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleUpdate(ctx, resp, reqContent, twirp.MarshalProtobuf)
+}
+
+func (s *usersServer) serveUpdateCodec(ctx context.Context, resp http.ResponseWriter, req *http.Request, codec twirp.Codec) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(common.User)
+	if err = codec.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request "+codec.Name())
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleUpdate(ctx, resp, reqContent, twirp.CodecMarshal(codec))
+}
+
+func (s *usersServer) serveUpdateForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	err = req.ParseForm()
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(common.User)
+
+	if v, ok := req.Form["id"]; ok {
+		vv, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("id", err.Error()))
+			return
+		}
+		reqContent.Id = vv
+	}
+	if v, ok := req.Form["name"]; ok {
+		reqContent.Name = v[0]
+	}
+
+	s.handleUpdate(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *usersServer) handleUpdate(ctx context.Context, resp http.ResponseWriter, reqContent *common.User, marshal twirp.MarshalFunc) {
+	var err error
+	ctx = twirp.WithRequest(ctx, reqContent)
+	twirp.MarkServerTiming(ctx, "decode")
+	if v, ok := interface{}(reqContent).(interface{ Validate() error }); ok {
+		if validerr := v.Validate(); validerr != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))
+			return
+		}
+	}
+
+	twirp.MarkServerTiming(ctx, "validate")
+	// Call service method
+	var impl Users = s.Users
+	if s.canary != nil {
+		canary := s.canaryRule.Hit(ctx)
+		if canary {
+			impl = s.canary
+		}
+		ctx = twirp.WithCanary(ctx, canary)
+	}
+	var respContent *Empty
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = impl.Update(ctx, reqContent)
+	}()
+	twirp.MarkServerTiming(ctx, "handler")
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *Empty and nil error while calling Update. nil responses are not supported"))
+		return
+	}
+
+	twirp.WriteResponse(ctx, resp, s.hooks, respContent, marshal)
+}
+
+func (s *usersServer) ServiceDescriptor() ([]byte, int) {
+	return twirpFileDescriptor2SHA78e9fa6a64c3b041795850e82ecc6f1a77fc51c8, 0
+}
+
+func (s *usersServer) ProtocGenTwirpVersion() string {
+	return "v0.1.0"
+}
+
+// Compile-time assertions of the Users server and clients.
+var (
+	_ twirp.Server = (*usersServer)(nil)
+	_ Users        = (*usersProtobufClient)(nil)
+	_ Users        = (*usersJSONClient)(nil)
+	_ Users        = (*usersCodecClient)(nil)
+)
+
+// ===============
+// Admin Interface
+// ===============
+
+// 同一个文件中的第二个服务
+//
+// @internal
+type Admin interface {
+	// @error:NotFound user not exists
+	Delete(context.Context, *common.UserID) (*Empty, error)
+}
+
+// AdminDeleteFunc is the signature of Admin.Delete.
+// Implementations can be checked at build time with a method value:
+//
+//	var _ = AdminDeleteFunc((&Server{}).Delete)
+type AdminDeleteFunc func(context.Context, *common.UserID) (*Empty, error)
+
+// =====================
+// Admin Protobuf Client
+// =====================
+
+type adminProtobufClient struct {
+	client twirp.HTTPClient
+	urls   [1]string
+}
+
+// NewAdminProtobufClient creates a Protobuf client that implements the Admin interface.
+// It communicates using Protobuf and can be configured with a custom HTTPClient.
+func NewAdminProtobufClient(addr string, client twirp.HTTPClient) Admin {
+	prefix := addr + AdminPathPrefix
+	urls := [1]string{
+		prefix + "Delete",
+	}
+	return &adminProtobufClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *adminProtobufClient) Delete(ctx context.Context, in *common.UserID) (*Empty, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Admin")
+	ctx = twirp.WithMethodName(ctx, "Delete")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Empty)
+	err := twirp.DoProtobufRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// =================
+// Admin JSON Client
+// =================
+
+type adminJSONClient struct {
+	client twirp.HTTPClient
+	urls   [1]string
+}
+
+// NewAdminJSONClient creates a JSON client that implements the Admin interface.
+// It communicates using JSON and can be configured with a custom HTTPClient.
+func NewAdminJSONClient(addr string, client twirp.HTTPClient) Admin {
+	prefix := addr + AdminPathPrefix
+	urls := [1]string{
+		prefix + "Delete",
+	}
+	return &adminJSONClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *adminJSONClient) Delete(ctx context.Context, in *common.UserID) (*Empty, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Admin")
+	ctx = twirp.WithMethodName(ctx, "Delete")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Empty)
+	err := twirp.DoJSONRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ==================
+// Admin Codec Client
+// ==================
+
+type adminCodecClient struct {
+	client twirp.HTTPClient
+	urls   [1]string
+	codec  twirp.Codec
+}
+
+// NewAdminCodecClient creates a Codec client that implements the Admin interface.
+// It communicates using the given codec and can be configured with a custom HTTPClient.
+func NewAdminCodecClient(addr string, client twirp.HTTPClient, codec twirp.Codec) Admin {
+	prefix := addr + AdminPathPrefix
+	urls := [1]string{
+		prefix + "Delete",
+	}
+	return &adminCodecClient{
+		client: client,
+		urls:   urls,
+		codec:  codec,
+	}
+}
+
+func (c *adminCodecClient) Delete(ctx context.Context, in *common.UserID) (*Empty, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Admin")
+	ctx = twirp.WithMethodName(ctx, "Delete")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Empty)
+	err := twirp.DoCodecRequest(ctx, c.client, c.codec, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ====================
+// Admin Server Handler
+// ====================
+
+type adminServer struct {
+	Admin
+	hooks      *twirp.ServerHooks
+	canary     Admin
+	canaryRule *twirp.CanaryRule
+}
+
+// NewAdminServer creates a twirp server that serves svc.
+func NewAdminServer(svc Admin, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil {
+		panic("NewAdminServer: svc is nil")
+	}
+	return &adminServer{
+		Admin: svc,
+		hooks: hooks,
+	}
+}
+
+// NewAdminCanaryServer creates a server that routes requests matching rule to canary,
+// and the others to svc.
+func NewAdminCanaryServer(svc, canary Admin, rule *twirp.CanaryRule, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil || canary == nil {
+		panic("NewAdminCanaryServer: svc or canary is nil")
+	}
+	return &adminServer{
+		Admin:      svc,
+		hooks:      hooks,
+		canary:     canary,
+		canaryRule: rule,
+	}
+}
+
+// writeError writes an HTTP response with a valid Twirp error format, and triggers hooks.
+// If err is not a twirp.Error, it will get wrapped with twirp.InternalErrorWith(err)
+func (s *adminServer) writeError(ctx context.Context, resp http.ResponseWriter, err error) {
+	s.hooks.WriteError(ctx, resp, err)
+}
+
+// badRouteError is used when the twirp server cannot route a request
+func (s *adminServer) badRouteError(msg string, method, url string) twirp.Error {
+	err := twirp.NewError(twirp.BadRoute, msg)
+	err = err.WithMeta("twirp_invalid_route", method+" "+url)
+	return err
+}
+
+func (s *adminServer) wrapErr(err error, msg string) error {
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// AdminPathPrefix is used for all URL paths on a twirp Admin server.
+// Requests are always: POST AdminPathPrefix/method
+// It can be used in an HTTP mux to route twirp requests along with non-twirp requests on other routes.
+const AdminPathPrefix = "/fixture.multi.Admin/"
+
+// Paths of all methods of Admin.
+const (
+	AdminDeletePath = "/fixture.multi.Admin/Delete"
+)
+
+// AdminMethodNames contains names of all methods of Admin.
+var AdminMethodNames = []string{
+	"Delete",
+}
+
+func init() {
+	twirp.RegisterMethod(twirp.MethodInfo{
+		Service: "fixture.multi.Admin",
+		Method:  "Delete",
+		Path:    AdminDeletePath,
+		Errors: []twirp.ErrorDoc{
+			{Code: twirp.NotFound, Comment: "user not exists"},
+		},
+		Source:    "multi.proto",
+		Generator: "v0.1.0",
+	})
+}
+
+func (s *adminServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ctx = twirp.WithHttpRequest(ctx, req)
+	ctx = twirp.WithPackageName(ctx, "fixture.multi")
+	ctx = twirp.WithServiceName(ctx, "Admin")
+	ctx = twirp.WithResponseWriter(ctx, resp)
+	ctx = twirp.WithResponseHeader(ctx)
+	ctx = twirp.WithServerTiming(ctx, req)
+
+	var err error
+	ctx, err = s.hooks.CallRequestReceived(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	if (req.Method == http.MethodHead || req.Method == http.MethodOptions) && s.allowGET(ctx, req.URL.Path) {
+		if req.Method == http.MethodOptions {
+			twirp.WriteOptions(ctx, resp, s.hooks)
+			return
+		}
+		var finish func()
+		resp, finish = twirp.HeadResponse(resp, req)
+		defer finish()
+		ctx = twirp.WithResponseWriter(ctx, resp)
+	}
+
+	if req.Method != http.MethodPost && !s.allowGET(ctx, req.URL.Path) {
+		msg := fmt.Sprintf("unsupported method %q (only POST is allowed)", req.Method)
+		err = s.badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	switch req.URL.Path {
+	case AdminDeletePath:
+		s.serveDelete(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		s.writeError(ctx, resp, s.badRouteError(msg, req.Method, req.URL.Path))
+	}
+}
+
+// allowGET reports whether the method at path accepts GET requests, either by the @get
+// annotation or by hooks calling twirp.WithAllowGET.
+func (s *adminServer) allowGET(ctx context.Context, path string) bool {
+	return twirp.AllowGET(ctx)
+}
+
+func (s *adminServer) serveDelete(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityDefault)
+	var err error
+	ctx = twirp.WithMethodName(ctx, "Delete")
+	ctx, err = s.hooks.CallRequestRouted(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	if !twirp.InternalRequest(ctx) {
+		s.writeError(ctx, resp, twirp.NewError(twirp.PermissionDenied, "internal method"))
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "route")
+
+	twirp.MarkServerTiming(ctx, "auth")
+
+	ctx, err = twirp.GzipRequest(ctx, req, 0)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveDeleteJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveDeleteProtobuf(ctx, resp, req)
+	case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":
+		twirp.ServeGRPCWeb(ctx, resp, req, s.serveDeleteProtobuf)
+	default:
+		if codec, ok := twirp.LookupCodec(header); ok {
+			s.serveDeleteCodec(ctx, resp, req, codec)
+			return
+		}
+		s.serveDeleteForm(ctx, resp, req)
+	}
+}
+
+func (s *adminServer) serveDeleteJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	reqContent := new(common.UserID)
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: twirp.AnyResolver}
+	if err = unmarshaler.Unmarshal(buf, reqContent); err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to parse request json")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleDelete(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *adminServer) serveDeleteProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(common.UserID)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request proto")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleDelete(ctx, resp, reqContent, twirp.MarshalProtobuf)
+}
+
+func (s *adminServer) serveDeleteCodec(ctx context.Context, resp http.ResponseWriter, req *http.Request, codec twirp.Codec) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(common.UserID)
+	if err = codec.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request "+codec.Name())
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleDelete(ctx, resp, reqContent, twirp.CodecMarshal(codec))
+}
+
+func (s *adminServer) serveDeleteForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	err = req.ParseForm()
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(common.UserID)
+
+	if v, ok := req.Form["id"]; ok {
+		vv, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("id", err.Error()))
+			return
+		}
+		reqContent.Id = vv
+	}
+
+	s.handleDelete(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *adminServer) handleDelete(ctx context.Context, resp http.ResponseWriter, reqContent *common.UserID, marshal twirp.MarshalFunc) {
+	var err error
+	ctx = twirp.WithRequest(ctx, reqContent)
+	twirp.MarkServerTiming(ctx, "decode")
+	if v, ok := interface{}(reqContent).(interface{ Validate() error }); ok {
+		if validerr := v.Validate(); validerr != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))
+			return
+		}
+	}
+
+	twirp.MarkServerTiming(ctx, "validate")
+	// Call service method
+	var impl Admin = s.Admin
+	if s.canary != nil {
+		canary := s.canaryRule.Hit(ctx)
+		if canary {
+			impl = s.canary
+		}
+		ctx = twirp.WithCanary(ctx, canary)
+	}
+	var respContent *Empty
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = impl.Delete(ctx, reqContent)
+	}()
+	twirp.MarkServerTiming(ctx, "handler")
+
+	err = twirp.CheckErrorCode(AdminDeletePath, err)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *Empty and nil error while calling Delete. nil responses are not supported"))
+		return
+	}
+
+	twirp.WriteResponse(ctx, resp, s.hooks, respContent, marshal)
+}
+
+func (s *adminServer) ServiceDescriptor() ([]byte, int) {
+	return twirpFileDescriptor2SHA78e9fa6a64c3b041795850e82ecc6f1a77fc51c8, 1
+}
+
+func (s *adminServer) ProtocGenTwirpVersion() string {
+	return "v0.1.0"
+}
+
+// Compile-time assertions of the Admin server and clients.
+var (
+	_ twirp.Server = (*adminServer)(nil)
+	_ Admin        = (*adminProtobufClient)(nil)
+	_ Admin        = (*adminJSONClient)(nil)
+	_ Admin        = (*adminCodecClient)(nil)
+)
+
+var twirpFileDescriptor2SHA78e9fa6a64c3b041795850e82ecc6f1a77fc51c8 = []byte{
+	// 168 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xce, 0x2d, 0xcd, 0x29,
+	0xc9, 0xd4, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x4d, 0xcb, 0xac, 0x28, 0x29, 0x2d, 0x4a,
+	0xd5, 0x03, 0x0b, 0x4a, 0x09, 0x26, 0xe7, 0xe7, 0xe6, 0xe6, 0xe7, 0xe9, 0x97, 0x16, 0xa7, 0x16,
+	0x41, 0x54, 0x28, 0xb1, 0x73, 0xb1, 0xba, 0xe6, 0x16, 0x94, 0x54, 0x1a, 0x15, 0x71, 0xb1, 0x86,
+	0x16, 0xa7, 0x16, 0x15, 0x0b, 0x19, 0x73, 0x31, 0xbb, 0xa7, 0x96, 0x08, 0x89, 0xe9, 0xc1, 0xf4,
+	0x42, 0x34, 0xe9, 0x81, 0x64, 0x3d, 0x5d, 0xa4, 0x44, 0xb0, 0x89, 0x0b, 0x99, 0x70, 0xb1, 0x85,
+	0x16, 0xa4, 0x24, 0x96, 0xa4, 0x0a, 0x61, 0x95, 0x47, 0xd2, 0x05, 0x71, 0x1e, 0xc4, 0x4e, 0x7b,
+	0x2e, 0x56, 0xc7, 0x94, 0xdc, 0xcc, 0x3c, 0x21, 0x33, 0x2e, 0x36, 0x97, 0xd4, 0x9c, 0xd4, 0x92,
+	0x54, 0x22, 0xac, 0x45, 0x32, 0xc0, 0x49, 0x26, 0x4a, 0xaa, 0x38, 0x2f, 0xb3, 0x20, 0xb5, 0x48,
+	0x1f, 0x2a, 0xab, 0x0f, 0x96, 0xb5, 0x06, 0x93, 0x49, 0x6c, 0x60, 0x2f, 0x1a, 0x03, 0x06, 0x00,
+	0x08, 0xaa, 0x27, 0xeb, 0x13, 0x01, 0x00, 0x00,
+}
+
+func init() {
+	// JSON 中的 google.protobuf.Any 字段可以解析本文件定义的类型
+	twirp.RegisterFileDescriptor(twirpFileDescriptor2SHA78e9fa6a64c3b041795850e82ecc6f1a77fc51c8)
+}
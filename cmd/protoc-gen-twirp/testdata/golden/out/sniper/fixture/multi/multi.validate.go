@@ -0,0 +1,67 @@
+package multi
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ensure the imports are used
+var (
+	_ = fmt.Print
+	_ = utf8.UTFMax
+	_ = (*regexp.Regexp)(nil)
+	_ = (*strings.Reader)(nil)
+	_ = net.IPv4len
+	_ = (*url.URL)(nil)
+)
+
+func (m *Empty) validate() error {
+	if m == nil {
+		return nil
+	}
+
+	return nil
+}
+
+// validateAll 与 validate 相同，但是检查所有字段，返回全部错误
+func (m *Empty) validateAll() error {
+	if m == nil {
+		return nil
+	}
+
+	var errs EmptyMultiError
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// EmptyMultiError is the list of errors returned by validateAll
+type EmptyMultiError []error
+
+// Error satisfies the builtin error interface
+func (e EmptyMultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type EmptyValidationError struct {
+	field  string
+	reason string
+}
+
+// Error satisfies the builtin error interface
+func (e EmptyValidationError) Error() string {
+	return fmt.Sprintf(
+		"invalid Empty.%s: %s",
+		e.field,
+		e.reason)
+}
@@ -0,0 +1,52 @@
+//go:build go1.18
+
+// Package scalars is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: scalars.proto
+package scalars
+
+import context "context"
+
+import twirp "sniper/util/twirp"
+
+type echoServerConfig struct {
+	hooks *twirp.ServerHooks
+	echo  []func(context.Context, *Scalars) error
+}
+
+// EchoServerOption configures the server created by NewEchoTypedServer.
+type EchoServerOption func(*echoServerConfig)
+
+// WithEchoHooks sets the server hooks.
+func WithEchoHooks(hooks *twirp.ServerHooks) EchoServerOption {
+	return func(c *echoServerConfig) { c.hooks = hooks }
+}
+
+// OnEchoEcho adds an interceptor of Echo, which is called after
+// the request is validated. If it returns an error, Echo is not called.
+func OnEchoEcho(fn func(context.Context, *Scalars) error) EchoServerOption {
+	return func(c *echoServerConfig) { c.echo = append(c.echo, fn) }
+}
+
+// NewEchoTypedServer creates a server of svc like NewEchoServer,
+// with typed interceptors registered by OnEcho{Method} options.
+func NewEchoTypedServer[T Echo](svc T, opts ...EchoServerOption) twirp.Server {
+	c := &echoServerConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return NewEchoServer(&echoTypedService[T]{svc: svc, config: c}, c.hooks)
+}
+
+type echoTypedService[T Echo] struct {
+	svc    T
+	config *echoServerConfig
+}
+
+func (s *echoTypedService[T]) Echo(ctx context.Context, req *Scalars) (*Scalars, error) {
+	for _, fn := range s.config.echo {
+		if err := fn(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return s.svc.Echo(ctx, req)
+}
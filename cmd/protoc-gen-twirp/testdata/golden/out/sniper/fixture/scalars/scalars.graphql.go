@@ -0,0 +1,23 @@
+// Package scalars is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: scalars.proto
+package scalars
+
+import context "context"
+
+// EchoGraphQLResolver resolves the GraphQL fields of Echo by calling a twirp client.
+type EchoGraphQLResolver struct {
+	client Echo
+}
+
+// NewEchoGraphQLResolver creates a resolver, client is usually created by NewEchoJSONClient.
+func NewEchoGraphQLResolver(client Echo) *EchoGraphQLResolver {
+	return &EchoGraphQLResolver{client: client}
+}
+
+// EchoEcho resolves echoEcho.
+func (r *EchoGraphQLResolver) EchoEcho(ctx context.Context, input *Scalars) (*Scalars, error) {
+	if input == nil {
+		input = new(Scalars)
+	}
+	return r.client.Echo(ctx, input)
+}
@@ -0,0 +1,552 @@
+// Package scalars is generated by protoc-gen-twirp v0.1.0, DO NOT EDIT.
+// source: scalars.proto
+package scalars
+
+import strings "strings"
+import context "context"
+import fmt "fmt"
+import strconv "strconv"
+import io "io"
+import http "net/http"
+
+import protojson "google.golang.org/protobuf/encoding/protojson"
+import proto "google.golang.org/protobuf/proto"
+import ctxkit "sniper/util/ctxkit"
+import twirp "sniper/util/twirp"
+
+// If the request does not have any number filed, the strconv
+// is not needed. However, there is no easy way to drop it.
+var _ = strconv.IntSize
+var _ = ctxkit.GetUserID
+
+// ==============
+// Echo Interface
+// ==============
+
+type Echo interface {
+	// 原样返回请求
+	Echo(context.Context, *Scalars) (*Scalars, error)
+}
+
+// EchoEchoFunc is the signature of Echo.Echo.
+// Implementations can be checked at build time with a method value:
+//
+//	var _ = EchoEchoFunc((&Server{}).Echo)
+type EchoEchoFunc func(context.Context, *Scalars) (*Scalars, error)
+
+// ====================
+// Echo Protobuf Client
+// ====================
+
+type echoProtobufClient struct {
+	client twirp.HTTPClient
+	urls   [1]string
+}
+
+// NewEchoProtobufClient creates a Protobuf client that implements the Echo interface.
+// It communicates using Protobuf and can be configured with a custom HTTPClient.
+func NewEchoProtobufClient(addr string, client twirp.HTTPClient) Echo {
+	prefix := addr + EchoPathPrefix
+	urls := [1]string{
+		prefix + "Echo",
+	}
+	return &echoProtobufClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *echoProtobufClient) Echo(ctx context.Context, in *Scalars) (*Scalars, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.scalars")
+	ctx = twirp.WithServiceName(ctx, "Echo")
+	ctx = twirp.WithMethodName(ctx, "Echo")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Scalars)
+	err := twirp.DoProtobufRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ================
+// Echo JSON Client
+// ================
+
+type echoJSONClient struct {
+	client twirp.HTTPClient
+	urls   [1]string
+}
+
+// NewEchoJSONClient creates a JSON client that implements the Echo interface.
+// It communicates using JSON and can be configured with a custom HTTPClient.
+func NewEchoJSONClient(addr string, client twirp.HTTPClient) Echo {
+	prefix := addr + EchoPathPrefix
+	urls := [1]string{
+		prefix + "Echo",
+	}
+	return &echoJSONClient{
+		client: client,
+		urls:   urls,
+	}
+}
+
+func (c *echoJSONClient) Echo(ctx context.Context, in *Scalars) (*Scalars, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.scalars")
+	ctx = twirp.WithServiceName(ctx, "Echo")
+	ctx = twirp.WithMethodName(ctx, "Echo")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Scalars)
+	err := twirp.DoJSONRequest(ctx, c.client, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// =================
+// Echo Codec Client
+// =================
+
+type echoCodecClient struct {
+	client twirp.HTTPClient
+	urls   [1]string
+	codec  twirp.Codec
+}
+
+// NewEchoCodecClient creates a Codec client that implements the Echo interface.
+// It communicates using the given codec and can be configured with a custom HTTPClient.
+func NewEchoCodecClient(addr string, client twirp.HTTPClient, codec twirp.Codec) Echo {
+	prefix := addr + EchoPathPrefix
+	urls := [1]string{
+		prefix + "Echo",
+	}
+	return &echoCodecClient{
+		client: client,
+		urls:   urls,
+		codec:  codec,
+	}
+}
+
+func (c *echoCodecClient) Echo(ctx context.Context, in *Scalars) (*Scalars, error) {
+	ctx = twirp.WithPackageName(ctx, "fixture.scalars")
+	ctx = twirp.WithServiceName(ctx, "Echo")
+	ctx = twirp.WithMethodName(ctx, "Echo")
+	ctx, cancel := twirp.ClientBudget(ctx)
+	defer cancel()
+	out := new(Scalars)
+	err := twirp.DoCodecRequest(ctx, c.client, c.codec, c.urls[0], in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ===================
+// Echo Server Handler
+// ===================
+
+type echoServer struct {
+	Echo
+	hooks      *twirp.ServerHooks
+	canary     Echo
+	canaryRule *twirp.CanaryRule
+}
+
+// NewEchoServer creates a twirp server that serves svc.
+func NewEchoServer(svc Echo, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil {
+		panic("NewEchoServer: svc is nil")
+	}
+	return &echoServer{
+		Echo:  svc,
+		hooks: hooks,
+	}
+}
+
+// NewEchoCanaryServer creates a server that routes requests matching rule to canary,
+// and the others to svc.
+func NewEchoCanaryServer(svc, canary Echo, rule *twirp.CanaryRule, hooks *twirp.ServerHooks) twirp.Server {
+	if svc == nil || canary == nil {
+		panic("NewEchoCanaryServer: svc or canary is nil")
+	}
+	return &echoServer{
+		Echo:       svc,
+		hooks:      hooks,
+		canary:     canary,
+		canaryRule: rule,
+	}
+}
+
+// writeError writes an HTTP response with a valid Twirp error format, and triggers hooks.
+// If err is not a twirp.Error, it will get wrapped with twirp.InternalErrorWith(err)
+func (s *echoServer) writeError(ctx context.Context, resp http.ResponseWriter, err error) {
+	s.hooks.WriteError(ctx, resp, err)
+}
+
+// badRouteError is used when the twirp server cannot route a request
+func (s *echoServer) badRouteError(msg string, method, url string) twirp.Error {
+	err := twirp.NewError(twirp.BadRoute, msg)
+	err = err.WithMeta("twirp_invalid_route", method+" "+url)
+	return err
+}
+
+func (s *echoServer) wrapErr(err error, msg string) error {
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// EchoPathPrefix is used for all URL paths on a twirp Echo server.
+// Requests are always: POST EchoPathPrefix/method
+// It can be used in an HTTP mux to route twirp requests along with non-twirp requests on other routes.
+const EchoPathPrefix = "/fixture.scalars.Echo/"
+
+// Paths of all methods of Echo.
+const (
+	EchoEchoPath = "/fixture.scalars.Echo/Echo"
+)
+
+// EchoMethodNames contains names of all methods of Echo.
+var EchoMethodNames = []string{
+	"Echo",
+}
+
+func init() {
+	twirp.RegisterMethod(twirp.MethodInfo{
+		Service:   "fixture.scalars.Echo",
+		Method:    "Echo",
+		Path:      EchoEchoPath,
+		Source:    "scalars.proto",
+		Generator: "v0.1.0",
+	})
+}
+
+func (s *echoServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ctx = twirp.WithHttpRequest(ctx, req)
+	ctx = twirp.WithPackageName(ctx, "fixture.scalars")
+	ctx = twirp.WithServiceName(ctx, "Echo")
+	ctx = twirp.WithResponseWriter(ctx, resp)
+	ctx = twirp.WithResponseHeader(ctx)
+	ctx = twirp.WithServerTiming(ctx, req)
+
+	var err error
+	ctx, err = s.hooks.CallRequestReceived(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	if (req.Method == http.MethodHead || req.Method == http.MethodOptions) && s.allowGET(ctx, req.URL.Path) {
+		if req.Method == http.MethodOptions {
+			twirp.WriteOptions(ctx, resp, s.hooks)
+			return
+		}
+		var finish func()
+		resp, finish = twirp.HeadResponse(resp, req)
+		defer finish()
+		ctx = twirp.WithResponseWriter(ctx, resp)
+	}
+
+	if req.Method != http.MethodPost && !s.allowGET(ctx, req.URL.Path) {
+		msg := fmt.Sprintf("unsupported method %q (only POST is allowed)", req.Method)
+		err = s.badRouteError(msg, req.Method, req.URL.Path)
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	switch req.URL.Path {
+	case EchoEchoPath:
+		s.serveEcho(ctx, resp, req)
+	default:
+		msg := fmt.Sprintf("no handler for path %q", req.URL.Path)
+		s.writeError(ctx, resp, s.badRouteError(msg, req.Method, req.URL.Path))
+	}
+}
+
+// allowGET reports whether the method at path accepts GET requests, either by the @get
+// annotation or by hooks calling twirp.WithAllowGET.
+func (s *echoServer) allowGET(ctx context.Context, path string) bool {
+	return twirp.AllowGET(ctx)
+}
+
+func (s *echoServer) serveEcho(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	header := req.Header.Get("Content-Type")
+	i := strings.Index(header, ";")
+	if i == -1 {
+		i = len(header)
+	}
+	ctx = twirp.WithRequestPriority(ctx, req, twirp.PriorityDefault)
+	var err error
+	ctx = twirp.WithMethodName(ctx, "Echo")
+	ctx, err = s.hooks.CallRequestRouted(ctx)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "route")
+
+	twirp.MarkServerTiming(ctx, "auth")
+
+	ctx, err = twirp.GzipRequest(ctx, req, 0)
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	switch strings.TrimSpace(strings.ToLower(header[:i])) {
+	case "application/json":
+		s.serveEchoJSON(ctx, resp, req)
+	case "application/protobuf":
+		s.serveEchoProtobuf(ctx, resp, req)
+	case "application/grpc-web", "application/grpc-web+proto", "application/grpc-web-text", "application/grpc-web-text+proto":
+		twirp.ServeGRPCWeb(ctx, resp, req, s.serveEchoProtobuf)
+	default:
+		if codec, ok := twirp.LookupCodec(header); ok {
+			s.serveEchoCodec(ctx, resp, req, codec)
+			return
+		}
+		s.serveEchoForm(ctx, resp, req)
+	}
+}
+
+func (s *echoServer) serveEchoJSON(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	reqContent := new(Scalars)
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	unmarshaler := protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: twirp.AnyResolver}
+	if err = unmarshaler.Unmarshal(buf, reqContent); err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to parse request json")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleEcho(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *echoServer) serveEchoProtobuf(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(Scalars)
+	if err = proto.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request proto")
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleEcho(ctx, resp, reqContent, twirp.MarshalProtobuf)
+}
+
+func (s *echoServer) serveEchoCodec(ctx context.Context, resp http.ResponseWriter, req *http.Request, codec twirp.Codec) {
+	var err error
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(twirp.Error); ok {
+			s.writeError(ctx, resp, twerr)
+			return
+		}
+		err = s.wrapErr(err, "failed to read request body")
+		s.writeError(ctx, resp, twirp.InternalErrorWith(err))
+		return
+	}
+	reqContent := new(Scalars)
+	if err = codec.Unmarshal(buf, reqContent); err != nil {
+		err = s.wrapErr(err, "failed to parse request "+codec.Name())
+		twerr := twirp.NewError(twirp.InvalidArgument, err.Error())
+		twerr = twerr.WithMeta("cause", fmt.Sprintf("%T", err))
+		s.writeError(ctx, resp, twerr)
+		return
+	}
+
+	s.handleEcho(ctx, resp, reqContent, twirp.CodecMarshal(codec))
+}
+
+func (s *echoServer) serveEchoForm(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	var err error
+	err = req.ParseForm()
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+
+	reqContent := new(Scalars)
+
+	if v, ok := twirp.FormValues(req.Form, "double_value", "doubleValue"); ok {
+		vv, err := strconv.ParseFloat(v[0], 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("double_value", err.Error()))
+			return
+		}
+		reqContent.DoubleValue = vv
+	}
+	if v, ok := twirp.FormValues(req.Form, "float_value", "floatValue"); ok {
+		vv, err := strconv.ParseFloat(v[0], 32)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("float_value", err.Error()))
+			return
+		}
+		reqContent.FloatValue = float32(vv)
+	}
+	if v, ok := twirp.FormValues(req.Form, "int32_value", "int32Value"); ok {
+		vv, err := strconv.ParseInt(v[0], 10, 32)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("int32_value", err.Error()))
+			return
+		}
+		reqContent.Int32Value = int32(vv)
+	}
+	if v, ok := twirp.FormValues(req.Form, "int64_value", "int64Value"); ok {
+		vv, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("int64_value", err.Error()))
+			return
+		}
+		reqContent.Int64Value = vv
+	}
+	if v, ok := twirp.FormValues(req.Form, "uint32_value", "uint32Value"); ok {
+		vv, err := strconv.ParseUint(v[0], 10, 32)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("uint32_value", err.Error()))
+			return
+		}
+		reqContent.Uint32Value = uint32(vv)
+	}
+	if v, ok := twirp.FormValues(req.Form, "uint64_value", "uint64Value"); ok {
+		vv, err := strconv.ParseUint(v[0], 10, 64)
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("uint64_value", err.Error()))
+			return
+		}
+		reqContent.Uint64Value = vv
+	}
+	if v, ok := twirp.FormValues(req.Form, "bool_value", "boolValue"); ok {
+		vv, err := strconv.ParseBool(v[0])
+		if err != nil {
+			s.writeError(ctx, resp, twirp.InvalidArgumentError("bool_value", err.Error()))
+			return
+		}
+		reqContent.BoolValue = vv
+	}
+	if v, ok := twirp.FormValues(req.Form, "string_value", "stringValue"); ok {
+		reqContent.StringValue = v[0]
+	}
+
+	s.handleEcho(ctx, resp, reqContent, twirp.MarshalJSON)
+}
+
+func (s *echoServer) handleEcho(ctx context.Context, resp http.ResponseWriter, reqContent *Scalars, marshal twirp.MarshalFunc) {
+	var err error
+	ctx = twirp.WithRequest(ctx, reqContent)
+	twirp.MarkServerTiming(ctx, "decode")
+	if validerr := reqContent.validate(); validerr != nil {
+		s.writeError(ctx, resp, twirp.InvalidArgumentError("argument", validerr.Error()))
+		return
+	}
+
+	twirp.MarkServerTiming(ctx, "validate")
+	// Call service method
+	var impl Echo = s.Echo
+	if s.canary != nil {
+		canary := s.canaryRule.Hit(ctx)
+		if canary {
+			impl = s.canary
+		}
+		ctx = twirp.WithCanary(ctx, canary)
+	}
+	var respContent *Scalars
+	func() {
+		defer func() {
+			// In case of a panic, serve a 500 error and then panic.
+			if r := recover(); r != nil {
+				s.writeError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		respContent, err = impl.Echo(ctx, reqContent)
+	}()
+	twirp.MarkServerTiming(ctx, "handler")
+
+	if err != nil {
+		s.writeError(ctx, resp, err)
+		return
+	}
+	if respContent == nil {
+		s.writeError(ctx, resp, twirp.InternalError("received a nil *Scalars and nil error while calling Echo. nil responses are not supported"))
+		return
+	}
+
+	twirp.WriteResponse(ctx, resp, s.hooks, respContent, marshal)
+}
+
+func (s *echoServer) ServiceDescriptor() ([]byte, int) {
+	return twirpFileDescriptor0SHA2de41165f74a0b74b287b80006edd1936e3dc1b1, 0
+}
+
+func (s *echoServer) ProtocGenTwirpVersion() string {
+	return "v0.1.0"
+}
+
+// Compile-time assertions of the Echo server and clients.
+var (
+	_ twirp.Server = (*echoServer)(nil)
+	_ Echo         = (*echoProtobufClient)(nil)
+	_ Echo         = (*echoJSONClient)(nil)
+	_ Echo         = (*echoCodecClient)(nil)
+)
+
+var twirpFileDescriptor0SHA2de41165f74a0b74b287b80006edd1936e3dc1b1 = []byte{
+	// 328 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0xd2, 0x4f, 0x6b, 0xb3, 0x40,
+	0x10, 0xc7, 0x71, 0x26, 0x31, 0xff, 0x46, 0x4d, 0x9e, 0xc7, 0x53, 0x28, 0xb4, 0x9d, 0xb4, 0x14,
+	0xe6, 0x64, 0x20, 0x09, 0x39, 0xb4, 0xb7, 0x40, 0xdf, 0xc0, 0x16, 0x7a, 0xe8, 0xa5, 0x68, 0x62,
+	0x5a, 0x41, 0x62, 0x70, 0xb5, 0xa4, 0x6f, 0xa4, 0xaf, 0xb7, 0x44, 0xc7, 0xad, 0x16, 0x7a, 0x5a,
+	0xf9, 0xf9, 0xe1, 0x0b, 0xe2, 0xa2, 0xab, 0xb7, 0x41, 0x12, 0x64, 0xda, 0x3f, 0x66, 0x69, 0x9e,
+	0x7a, 0x93, 0x7d, 0x7c, 0xca, 0x8b, 0x2c, 0xf2, 0x65, 0xbe, 0xf9, 0xb2, 0x70, 0xf0, 0x54, 0x3d,
+	0x7b, 0x33, 0x74, 0x76, 0x69, 0x11, 0x26, 0xd1, 0xeb, 0x47, 0x90, 0x14, 0xd1, 0x14, 0x08, 0x18,
+	0x94, 0x5d, 0x6d, 0xcf, 0xe7, 0xc9, 0xbb, 0x46, 0x7b, 0x9f, 0xa4, 0x41, 0x2e, 0xa2, 0x43, 0xc0,
+	0x1d, 0x85, 0xe5, 0x64, 0x40, 0x7c, 0xc8, 0x97, 0x0b, 0x01, 0x5d, 0x02, 0xee, 0x29, 0x2c, 0xa7,
+	0x26, 0x58, 0xaf, 0x04, 0x58, 0x04, 0xdc, 0x2d, 0xc1, 0x7a, 0x55, 0x81, 0x19, 0x3a, 0x45, 0x33,
+	0xd1, 0x23, 0x60, 0x57, 0xd9, 0x45, 0xa3, 0x21, 0xc4, 0x44, 0xfa, 0x04, 0x6c, 0x55, 0xa4, 0x51,
+	0xd1, 0xcd, 0xca, 0x80, 0x80, 0xff, 0x2b, 0x5b, 0xb7, 0x2b, 0xba, 0x59, 0x19, 0x12, 0xb0, 0x57,
+	0x91, 0xba, 0x72, 0x8b, 0xee, 0x3e, 0x3e, 0x45, 0x3b, 0x93, 0x19, 0x11, 0xf0, 0x40, 0x39, 0x32,
+	0xb6, 0x91, 0x09, 0x21, 0x01, 0xf7, 0x05, 0xd5, 0xa5, 0x3b, 0x1c, 0xeb, 0x76, 0xca, 0x26, 0xe0,
+	0x89, 0x72, 0x75, 0xab, 0x65, 0x98, 0x89, 0x39, 0x04, 0xfc, 0xaf, 0x66, 0x75, 0xed, 0x12, 0x31,
+	0x4c, 0xd3, 0x44, 0x88, 0x4b, 0xc0, 0x43, 0x35, 0x3a, 0x2f, 0x3f, 0x5f, 0x96, 0x67, 0xf1, 0xe1,
+	0x4d, 0xc0, 0x98, 0x80, 0x47, 0xca, 0xae, 0x36, 0xf3, 0x1b, 0xc2, 0xcf, 0x3c, 0xd2, 0x22, 0x26,
+	0x04, 0xec, 0x28, 0x2c, 0xa7, 0x12, 0x2c, 0x36, 0x68, 0x3d, 0x6e, 0xdf, 0x53, 0xef, 0x5e, 0xce,
+	0xa9, 0xff, 0xeb, 0xea, 0xf8, 0x72, 0x6d, 0x2e, 0xfe, 0x7c, 0xb3, 0xa1, 0x97, 0x2b, 0x7d, 0x88,
+	0x8f, 0x51, 0x36, 0x17, 0x31, 0x17, 0xf1, 0x20, 0x67, 0xd8, 0x2f, 0xaf, 0xe5, 0xf2, 0x7b, 0x00,
+	0xf3, 0x23, 0x99, 0x3c, 0xa7, 0x02, 0x00, 0x00,
+}
+
+func init() {
+	// JSON 中的 google.protobuf.Any 字段可以解析本文件定义的类型
+	twirp.RegisterFileDescriptor(twirpFileDescriptor0SHA2de41165f74a0b74b287b80006edd1936e3dc1b1)
+}
@@ -0,0 +1,67 @@
+package scalars
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ensure the imports are used
+var (
+	_ = fmt.Print
+	_ = utf8.UTFMax
+	_ = (*regexp.Regexp)(nil)
+	_ = (*strings.Reader)(nil)
+	_ = net.IPv4len
+	_ = (*url.URL)(nil)
+)
+
+func (m *Scalars) validate() error {
+	if m == nil {
+		return nil
+	}
+
+	return nil
+}
+
+// validateAll 与 validate 相同，但是检查所有字段，返回全部错误
+func (m *Scalars) validateAll() error {
+	if m == nil {
+		return nil
+	}
+
+	var errs ScalarsMultiError
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ScalarsMultiError is the list of errors returned by validateAll
+type ScalarsMultiError []error
+
+// Error satisfies the builtin error interface
+func (e ScalarsMultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type ScalarsValidationError struct {
+	field  string
+	reason string
+}
+
+// Error satisfies the builtin error interface
+func (e ScalarsValidationError) Error() string {
+	return fmt.Sprintf(
+		"invalid Scalars.%s: %s",
+		e.field,
+		e.reason)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateUpstream 生成 {file}.upstream.go
+//
+// 迁移期间同一个服务实现需要同时挂载到 sniper 和官方 twirp 生成的服务上，
+// 官方 twirp 的代码需要生成到其他包中，message 类型共用即可满足双方的接口定义。
+func (t *twirp) generateUpstream(file *protogen.File) {
+	api := t.apiPackage(file)
+	t.generateBuildConstraint(artifactUpstream, "")
+	t.P("// Package ", string(api.name), " is generated by protoc-gen-twirp ", Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.Desc.Path())
+	t.P(`package `, string(api.name))
+	t.P()
+	t.P(`import `, t.pkgs["context"], ` "context"`)
+	t.P(`import `, t.pkgs["http"], ` "net/http"`)
+	t.P(`import `, t.pkgs["strconv"], ` "strconv"`)
+	t.P(`import `, t.pkgs["strings"], ` "strings"`)
+	t.P()
+	t.P(`import `, t.pkgs["upstream"], ` "github.com/twitchtv/twirp"`)
+	t.P(`import `, t.pkgs["twirp"], fmt.Sprintf(` "%s"`, t.TwirpPackage))
+	t.P()
+
+	for _, service := range file.Services {
+		t.generateUpstreamHooks(file, service)
+		t.generateDualServer(service)
+	}
+
+	fname := api.filenamePrefix + ".upstream.go"
+	t.writeFile(fname, t.output.Bytes(), true)
+	t.output.Reset()
+}
+
+func (t *twirp) generateUpstreamHooks(file *protogen.File, service *protogen.Service) {
+	servName := service.GoName
+	up := t.pkgs["upstream"]
+	tw := t.pkgs["twirp"]
+
+	t.P(`// New`, servName, `UpstreamHooks converts sniper hooks to upstream twirp hooks.`)
+	t.P(`//`)
+	t.P(`// Package, service and method names, status code and the http request`)
+	t.P(`// (when served by New`, servName, `DualServer) are copied into the sniper context,`)
+	t.P(`// so most sniper hooks work as is. Request and response messages are not available.`)
+	t.P(`func New`, servName, `UpstreamHooks(hooks *`, tw, `.ServerHooks) *`, up, `.ServerHooks {`)
+	t.P(`  return &`, up, `.ServerHooks{`)
+	t.P(`    RequestReceived: func(ctx `, t.pkgs["context"], `.Context) (`, t.pkgs["context"], `.Context, error) {`)
+	t.P(`      ctx = `, tw, `.WithPackageName(ctx, `, strconv.Quote(file.Proto.GetPackage()), `)`)
+	t.P(`      ctx = `, tw, `.WithServiceName(ctx, `, strconv.Quote(servName), `)`)
+	t.P(`      return hooks.CallRequestReceived(ctx)`)
+	t.P(`    },`)
+	t.P(`    RequestRouted: func(ctx `, t.pkgs["context"], `.Context) (`, t.pkgs["context"], `.Context, error) {`)
+	t.P(`      if name, ok := `, up, `.MethodName(ctx); ok {`)
+	t.P(`        ctx = `, tw, `.WithMethodName(ctx, name)`)
+	t.P(`      }`)
+	t.P(`      return hooks.CallRequestRouted(ctx)`)
+	t.P(`    },`)
+	t.P(`    ResponsePrepared: hooks.CallResponsePrepared,`)
+	t.P(`    ResponseSent: func(ctx `, t.pkgs["context"], `.Context) {`)
+	t.P(`      if code, ok := `, up, `.StatusCode(ctx); ok {`)
+	t.P(`        if status, err := `, t.pkgs["strconv"], `.Atoi(code); err == nil {`)
+	t.P(`          ctx = `, tw, `.WithStatusCode(ctx, status)`)
+	t.P(`        }`)
+	t.P(`      }`)
+	t.P(`      hooks.CallResponseSent(ctx)`)
+	t.P(`    },`)
+	t.P(`    Error: func(ctx `, t.pkgs["context"], `.Context, err `, up, `.Error) `, t.pkgs["context"], `.Context {`)
+	t.P(`      twerr := `, tw, `.NewError(`, tw, `.ErrorCode(err.Code()), err.Msg())`)
+	t.P(`      for k, v := range err.MetaMap() {`)
+	t.P(`        twerr = twerr.WithMeta(k, v)`)
+	t.P(`      }`)
+	t.P(`      return hooks.CallError(ctx, twerr)`)
+	t.P(`    },`)
+	t.P(`  }`)
+	t.P(`}`)
+	t.P()
+}
+
+func (t *twirp) generateDualServer(service *protogen.Service) {
+	servName := service.GoName
+	http := t.pkgs["http"]
+	tw := t.pkgs["twirp"]
+
+	t.P(`// New`, servName, `DualServer serves svc with both the sniper server and the upstream twirp server.`)
+	t.P(`//`)
+	t.P(`// Requests with the /twirp/ prefix go to the server created by newUpstream, which usually`)
+	t.P(`// wraps the upstream generated constructor:`)
+	t.P(`//`)
+	t.P(`//	New`, servName, `DualServer(svc, hooks, func(svc `, servName, `, hooks *twirp.ServerHooks) http.Handler {`)
+	t.P(`//		return upstreampb.New`, servName, `Server(svc, twirp.WithServerHooks(hooks))`)
+	t.P(`//	})`)
+	t.P(`func New`, servName, `DualServer(svc `, servName, `, hooks *`, tw, `.ServerHooks, newUpstream func(`, servName, `, *`, t.pkgs["upstream"], `.ServerHooks) `, http, `.Handler) `, http, `.Handler {`)
+	t.P(`  sniperServer := New`, servName, `Server(svc, hooks)`)
+	t.P(`  upstreamServer := newUpstream(svc, New`, servName, `UpstreamHooks(hooks))`)
+	t.P(`  return `, http, `.HandlerFunc(func(resp `, http, `.ResponseWriter, req *`, http, `.Request) {`)
+	t.P(`    if !`, t.pkgs["strings"], `.HasPrefix(req.URL.Path, "/twirp/") {`)
+	t.P(`      sniperServer.ServeHTTP(resp, req)`)
+	t.P(`      return`)
+	t.P(`    }`)
+	t.P(`    ctx := `, tw, `.WithHttpRequest(req.Context(), req)`)
+	t.P(`    ctx = `, tw, `.WithResponseWriter(ctx, resp)`)
+	t.P(`    upstreamServer.ServeHTTP(resp, req.WithContext(ctx))`)
+	t.P(`  })`)
+	t.P(`}`)
+	t.P()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// isWebhook 判断服务是否声明了 @webhook 注解
+//
+// 回调服务由合作方实现、我们调用，除了普通客户端，还生成带签名、重试和死信处理的 {Service}WebhookClient。
+func isWebhook(service *protogen.Service) bool {
+	_, ok := annotation(service.Comments.Leading, "webhook")
+	return ok
+}
+
+// checkWebhook 检查回调服务，回调只支持普通接口
+func (t *twirp) checkWebhook(file *protogen.File) {
+	for _, service := range file.Services {
+		if !isWebhook(service) {
+			continue
+		}
+		for _, method := range service.Methods {
+			if isStreaming(method) {
+				fail("@webhook is not supported by streaming method %s", method.Desc.FullName())
+			}
+		}
+	}
+}
+
+// generateWebhookClient 生成 {Service}WebhookClient
+//
+// 请求以 JSON 格式发送，每个接口调用都经过 twirp.DoWebhookRequest 签名、重试，并记录投递日志。
+func (t *twirp) generateWebhookClient(file *protogen.File, service *protogen.Service) {
+	if !isWebhook(service) {
+		return
+	}
+	servName := service.GoName
+	structName := unexported(servName) + "WebhookClient"
+	newClientFunc := "New" + servName + "WebhookClient"
+	methCnt := strconv.Itoa(len(service.Methods))
+
+	t.sectionComment(servName + ` Webhook Client`)
+	t.P(`type `, structName, ` struct {`)
+	t.P(`  client `, t.pkgs["twirp"], `.HTTPClient`)
+	t.P(`  config *`, t.pkgs["twirp"], `.WebhookConfig`)
+	t.P(`  urls   [`, methCnt, `]string`)
+	t.P(`}`)
+	t.P()
+	t.P(`// `, newClientFunc, ` creates a client that delivers `, servName, ` callbacks to a partner at addr.`)
+	t.P(`// Requests are sent as JSON and signed with config.Secret, failed deliveries are retried`)
+	t.P(`// and finally passed to config.DeadLetter. The retries are bounded by the ctx of each call.`)
+	t.P(`func `, newClientFunc, `(addr string, client `, t.pkgs["twirp"], `.HTTPClient, config *`, t.pkgs["twirp"], `.WebhookConfig) `, servName, ` {`)
+	t.P(`  prefix := addr + `, servName, `PathPrefix`)
+	t.P(`  urls := [`, methCnt, `]string{`)
+	for _, method := range service.Methods {
+		t.P(`    	prefix + "`, method.GoName, `",`)
+	}
+	t.P(`  }`)
+	t.P(`  return &`, structName, `{`)
+	t.P(`    client: client,`)
+	t.P(`    config: config,`)
+	t.P(`    urls:   urls,`)
+	t.P(`  }`)
+	t.P(`}`)
+	t.P()
+
+	for i, method := range service.Methods {
+		methName := method.GoName
+		outputType := t.getType(method.Output)
+
+		t.P(`func (c *`, structName, `) `, methName, `(ctx `, t.pkgs["context"], `.Context, in *`, t.getType(method.Input), `) (*`, outputType, `, error) {`)
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithPackageName(ctx, "`, *file.Proto.Package, `")`)
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithServiceName(ctx, "`, servName, `")`)
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
+		t.P(`  out := new(`, outputType, `)`)
+		t.P(`  err := `, t.pkgs["twirp"], `.DoWebhookRequest(ctx, c.client, c.config, c.urls[`, strconv.Itoa(i), `], in, out)`)
+		t.P(`  if err != nil {`)
+		t.P(`    return nil, err`)
+		t.P(`  }`)
+		t.P(`  return out, nil`)
+		t.P(`}`)
+		t.P()
+	}
+}
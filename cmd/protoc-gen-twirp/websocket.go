@@ -0,0 +1,205 @@
+package main
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateWebSocketClientMethod 生成客户端的客户端流式和双向流式接口方法
+//
+// {Method}Stream 建立 WebSocket 连接，{Method} 把 receiver 中的请求发送给服务端，
+// 双向流式接口同时把收到的响应交给 sender，客户端可以直接作为其他服务的实现转发。
+func (t *twirp) generateWebSocketClientMethod(name, structName string, file *protogen.File, service *protogen.Service, method *protogen.Method, index int) {
+	methName := method.GoName
+	reader := streamReader(service, method)
+	ctx := t.pkgs["context"] + ".Context"
+
+	codec := t.pkgs["twirp"] + "." + name + "Codec"
+	if name == "Codec" {
+		codec = "c.codec"
+	}
+
+	t.P(`func (c *`, structName, `) `, methName, `Stream(ctx `, ctx, `) (*`, reader, `, error) {`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithPackageName(ctx, "`, *file.Proto.Package, `")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithServiceName(ctx, "`, service.GoName, `")`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
+	if _, ok := methodAnnotation(method, service, "priority"); ok {
+		t.P(`  ctx = `, t.pkgs["twirp"], `.WithDefaultPriority(ctx, `, t.pkgs["twirp"], `.`, t.priority(service, method), `)`)
+	}
+	t.P(`  s, err := `, t.pkgs["twirp"], `.DialWebSocket(ctx, c.client, `, codec, `, c.urls[`, strconv.Itoa(index), `])`)
+	t.P(`  if err != nil {`)
+	t.P(`    return nil, err`)
+	t.P(`  }`)
+	t.P(`  return &`, reader, `{s: s}, nil`)
+	t.P(`}`)
+	t.P()
+
+	if !method.Desc.IsStreamingServer() {
+		t.P(`func (c *`, structName, `) `, methName, `(ctx `, ctx, `, receiver `, streamReceiver(service, method), `) (*`, t.getType(method.Output), `, error) {`)
+		t.P(`  stream, err := c.`, methName, `Stream(ctx)`)
+		t.P(`  if err != nil {`)
+		t.P(`    return nil, err`)
+		t.P(`  }`)
+		t.P(`  defer stream.Close()`)
+		t.P(`  for {`)
+		t.P(`    in, err := receiver.Recv()`)
+		t.P(`    if err == `, t.pkgs["io"], `.EOF {`)
+		t.P(`      return stream.CloseAndRecv()`)
+		t.P(`    }`)
+		t.P(`    if err != nil {`)
+		t.P(`      return nil, err`)
+		t.P(`    }`)
+		t.P(`    if err = stream.Send(in); err != nil {`)
+		t.P(`      return nil, err`)
+		t.P(`    }`)
+		t.P(`  }`)
+		t.P(`}`)
+		t.P()
+		return
+	}
+
+	t.P(`func (c *`, structName, `) `, methName, `(ctx `, ctx, `, receiver `, streamReceiver(service, method), `, sender `, streamSender(service, method), `) error {`)
+	t.P(`  stream, err := c.`, methName, `Stream(ctx)`)
+	t.P(`  if err != nil {`)
+	t.P(`    return err`)
+	t.P(`  }`)
+	t.P(`  defer stream.Close()`)
+	t.P(`  // The requests are sent in the background, the stream is closed if receiver fails.`)
+	t.P(`  go func() {`)
+	t.P(`    for {`)
+	t.P(`      in, err := receiver.Recv()`)
+	t.P(`      if err == `, t.pkgs["io"], `.EOF {`)
+	t.P(`        _ = stream.CloseSend()`)
+	t.P(`        return`)
+	t.P(`      }`)
+	t.P(`      if err != nil {`)
+	t.P(`        _ = stream.Close()`)
+	t.P(`        return`)
+	t.P(`      }`)
+	t.P(`      if err = stream.Send(in); err != nil {`)
+	t.P(`        return`)
+	t.P(`      }`)
+	t.P(`    }`)
+	t.P(`  }()`)
+	t.P(`  for {`)
+	t.P(`    out, err := stream.Recv()`)
+	t.P(`    if err == `, t.pkgs["io"], `.EOF {`)
+	t.P(`      return nil`)
+	t.P(`    }`)
+	t.P(`    if err != nil {`)
+	t.P(`      return err`)
+	t.P(`    }`)
+	t.P(`    if err = sender.Send(out); err != nil {`)
+	t.P(`      return err`)
+	t.P(`    }`)
+	t.P(`  }`)
+	t.P(`}`)
+	t.P()
+}
+
+// generateWebSocketServerMethod 生成客户端流式和双向流式接口的 serve{Method} 方法
+//
+// 握手之前的逻辑与普通接口相同，出错时输出普通的错误响应；握手之后请求由 receiver 逐条读取，
+// 开启校验时每条请求都会校验，@maxbody 限制单条请求的大小。业务方法返回的错误写入结束帧。
+func (t *twirp) generateWebSocketServerMethod(service *protogen.Service, method *protogen.Method) {
+	servStruct := serviceStruct(service)
+	methName := method.GoName
+	inputType := t.getType(method.Input)
+	outputType := t.getType(method.Output)
+	receiver := unexported(streamReceiver(service, method))
+
+	t.P(`type `, receiver, ` struct {`)
+	t.P(`  stream *`, t.pkgs["twirp"], `.WebSocketServerStream`)
+	t.P(`}`)
+	t.P()
+	t.P(`func (r `, receiver, `) Recv() (*`, inputType, `, error) {`)
+	t.P(`  in := new(`, inputType, `)`)
+	t.P(`  if err := r.stream.Recv(in); err != nil {`)
+	t.P(`    return nil, err`)
+	t.P(`  }`)
+	if t.ValidateEnable {
+		validate := "validate"
+		if t.APIPackage != "" {
+			validate = "Validate"
+		}
+		t.P(`  if validerr := in.`, validate, `(); validerr != nil {`)
+		t.P(`    return nil, `, t.pkgs["twirp"], `.InvalidArgumentError("argument", validerr.Error())`)
+		t.P(`  }`)
+	}
+	t.P(`  return in, nil`)
+	t.P(`}`)
+	t.P()
+
+	sender := unexported(streamSender(service, method))
+	if method.Desc.IsStreamingServer() {
+		t.P(`type `, sender, ` struct {`)
+		t.P(`  stream *`, t.pkgs["twirp"], `.WebSocketServerStream`)
+		t.P(`}`)
+		t.P()
+		t.P(`func (s `, sender, `) Send(m *`, outputType, `) error {`)
+		t.P(`  return s.stream.Send(m)`)
+		t.P(`}`)
+		t.P()
+	}
+
+	t.P(`func (s *`, servStruct, `) serve`, methName, `(ctx `, t.pkgs["context"], `.Context, resp `, t.pkgs["http"], `.ResponseWriter, req *`, t.pkgs["http"], `.Request) {`)
+	t.P(`  var err error`)
+	matched := t.methodOptionRegexp.FindStringSubmatch(method.Comments.Trailing.String())
+	if len(matched) == 2 {
+		t.P(`  ctx = twirp.WithMethodOption(ctx, "`, matched[1], `")`)
+	}
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithRequestPriority(ctx, req, `, t.pkgs["twirp"], `.`, t.priority(service, method), `)`)
+	t.P(`  ctx = `, t.pkgs["twirp"], `.WithMethodName(ctx, "`, methName, `")`)
+	t.P(`  ctx, err = s.hooks.CallRequestRouted(ctx)`)
+	t.P(`  if err != nil {`)
+	t.P(`    s.writeError(ctx, resp, err)`)
+	t.P(`    return`)
+	t.P(`  }`)
+	t.P()
+	t.generateMethodPrelude(service, method)
+	t.generateMethodGuard(service, method)
+	t.generateCanary(service, method)
+	t.P()
+	t.P(`  stream, err := `, t.pkgs["twirp"], `.AcceptWebSocket(ctx, resp, req, s.hooks, `, strconv.FormatInt(t.maxBodySize(service, method), 10), `)`)
+	t.P(`  if err != nil {`)
+	t.P(`    s.writeError(ctx, resp, err)`)
+	t.P(`    return`)
+	t.P(`  }`)
+	t.P(`  ctx = stream.Context()`)
+	if !method.Desc.IsStreamingServer() {
+		t.P(`  var respContent *`, outputType)
+	}
+	t.P(`  func() {`)
+	t.P(`    defer func() {`)
+	t.P(`      // In case of a panic, end the stream with an internal error and then panic.`)
+	t.P(`      if r := recover(); r != nil {`)
+	t.P(`        stream.Finish(`, t.pkgs["twirp"], `.InternalError("Internal service panic"))`)
+	t.P(`        panic(r)`)
+	t.P(`      }`)
+	t.P(`    }()`)
+	if method.Desc.IsStreamingServer() {
+		t.P(`    err = impl.`, methName, `(ctx, `, receiver, `{stream: stream}, `, sender, `{stream: stream})`)
+	} else {
+		t.P(`    respContent, err = impl.`, methName, `(ctx, `, receiver, `{stream: stream})`)
+	}
+	t.P(`  }()`)
+	if len(errorDocs(method, service)) > 0 {
+		t.P(`  err = `, t.pkgs["twirp"], `.CheckErrorCode(`, methodPathConst(service, method), `, err)`)
+	}
+	if !method.Desc.IsStreamingServer() {
+		t.P(`  if err == nil && respContent == nil {`)
+		if isEmpty(method.Output) {
+			t.P(`    respContent = new(`, outputType, `)`)
+		} else {
+			t.P(`    err = `, t.pkgs["twirp"], `.InternalError("received a nil *`, outputType, ` and nil error while calling `, methName, `. nil responses are not supported")`)
+		}
+		t.P(`  }`)
+		t.P(`  if err == nil {`)
+		t.P(`    err = stream.Send(respContent)`)
+		t.P(`  }`)
+	}
+	t.P(`  stream.Finish(err)`)
+	t.P(`}`)
+	t.P()
+}
@@ -1,12 +1,15 @@
 package server
 
 import (
+	"sniper/cmd/app"
+
 	"github.com/spf13/cobra"
 )
 
 var port int
 var isInternal bool
 var isManage bool
+var adminAddr string
 
 // Cmd run http server
 var Cmd = &cobra.Command{
@@ -22,4 +25,7 @@ func init() {
 	Cmd.Flags().IntVar(&port, "port", 8080, "listen port")
 	Cmd.Flags().BoolVar(&isInternal, "internal", false, "internal service")
 	Cmd.Flags().BoolVar(&isManage, "manage", false, "manage service")
+	Cmd.Flags().StringVar(&adminAddr, "admin-addr", "127.0.0.1:8081", "admin listen address for /monitor/conf and /monitor/methods, empty to disable")
+
+	app.Register(Cmd)
 }
@@ -0,0 +1,33 @@
+package hook
+
+import (
+	"context"
+
+	"sniper/util/trace"
+	"sniper/util/twirp"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// NewBaggage 解析上游传递的 baggage 请求头并记录到 ctx，通过 trace.GetBaggage 获取
+//
+// 只保留 TRACE_BAGGAGE_KEYS 允许的 key，并作为 baggage.{key} 标签记录到当前 span。
+// 调用下游 twirp 服务和发送消息时会继续传递。
+func NewBaggage() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			req, ok := twirp.HttpRequest(ctx)
+			if !ok {
+				return ctx, nil
+			}
+
+			ctx = trace.ExtractBaggage(ctx, req.Header.Get(trace.BaggageHeader))
+			if span := opentracing.SpanFromContext(ctx); span != nil {
+				for k, v := range trace.AllBaggage(ctx) {
+					span.SetTag("baggage."+k, v)
+				}
+			}
+			return ctx, nil
+		},
+	}
+}
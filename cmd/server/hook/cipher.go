@@ -0,0 +1,38 @@
+package hook
+
+import (
+	"context"
+	"encoding/base64"
+
+	"sniper/util/conf"
+	"sniper/util/log"
+	"sniper/util/twirp"
+)
+
+// NewCipher 注入基于配置的 AES-GCM 加解密，配合接口的 @encrypted 注解使用
+//
+// 密钥配置为 ENCRYPTION_KEY，内容为 base64 编码的 16、24 或 32 字节密钥，只在创建时读取一次。
+// 没有配置时不注入，配置有误时记录错误日志，@encrypted 接口都会返回 Internal 错误。
+func NewCipher() *twirp.ServerHooks {
+	value := conf.Get("ENCRYPTION_KEY")
+	if value == "" {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		log.Get(context.Background()).Errorf("invalid ENCRYPTION_KEY: %v", err)
+		return nil
+	}
+	c, err := twirp.NewAESGCM(key)
+	if err != nil {
+		log.Get(context.Background()).Errorf("invalid ENCRYPTION_KEY: %v", err)
+		return nil
+	}
+
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return twirp.WithCipher(ctx, c), nil
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package hook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"sniper/util/conf"
+	"sniper/util/ctxkit"
+	"sniper/util/twirp"
+)
+
+// NewDedup 注入请求去重，配合接口的 @dedup 注解使用
+//
+// 网关通过请求头 DEDUP_HEADER（默认 X-Request-Id）传递请求 ID，重试时保持不变。
+// 去重键包含客户端 IP、用户 ID 以及 Authorization 和 Cookie 请求头的摘要，猜到请求 ID
+// 也拿不到其他用户的响应，需要放在 NewClientIP 和设置用户 ID 的钩子之后。
+// store 为空时使用进程内的缓存。
+func NewDedup(store twirp.DedupStore) *twirp.ServerHooks {
+	if store == nil {
+		store = twirp.NewMemoryDedupStore(int(conf.GetInt64("DEDUP_MEMORY_SIZE")))
+	}
+
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			req, ok := twirp.HttpRequest(ctx)
+			if !ok {
+				return ctx, nil
+			}
+
+			header := conf.Get("DEDUP_HEADER")
+			if header == "" {
+				header = "X-Request-Id"
+			}
+			id := req.Header.Get(header)
+			if id == "" {
+				return ctx, nil
+			}
+			key := ctxkit.GetUserIP(ctx) + ":" + strconv.FormatInt(ctxkit.GetUserID(ctx), 10) + ":" + credentialHash(req.Header) + ":" + id
+			return twirp.WithDedup(ctx, store, key), nil
+		},
+	}
+}
+
+// credentialHash 返回请求中登录凭证的摘要，不同用户的请求使用相同的请求 ID 时去重键不同
+func credentialHash(h http.Header) string {
+	sum := sha256.Sum256([]byte(h.Get("Authorization") + "\n" + h.Get("Cookie")))
+	return hex.EncodeToString(sum[:8])
+}
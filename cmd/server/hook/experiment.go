@@ -0,0 +1,16 @@
+package hook
+
+import (
+	"context"
+
+	"sniper/util/twirp"
+)
+
+// NewExperiment 注入实验分桶客户端，配合接口的 @experiment 注解使用
+func NewExperiment(client twirp.ExperimentClient) *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return twirp.WithExperimentClient(ctx, client), nil
+		},
+	}
+}
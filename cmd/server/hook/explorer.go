@@ -0,0 +1,20 @@
+package hook
+
+import (
+	"context"
+
+	"sniper/util/conf"
+	"sniper/util/twirp"
+)
+
+// NewExplorer 在非生产环境开启接口调试页面，配合 explorer 生成参数使用
+func NewExplorer() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			if conf.IsProdEnv {
+				return ctx, nil
+			}
+			return twirp.WithExplorer(ctx), nil
+		},
+	}
+}
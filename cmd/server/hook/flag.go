@@ -0,0 +1,26 @@
+package hook
+
+import (
+	"context"
+
+	"sniper/util/flag"
+	"sniper/util/twirp"
+)
+
+// NewFlag 注入功能开关，配合接口的 @flag 注解使用
+//
+// 开关规则由 util/flag 读取，默认使用配置 FLAG_${NAME}，${NAME} 为注解中开关名称的大写形式，
+// 支持用户白名单、按比例灰度和请求属性规则，参考 flag.ConfBackend。
+func NewFlag() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return twirp.WithFlagChecker(ctx, flagChecker{}), nil
+		},
+	}
+}
+
+type flagChecker struct{}
+
+func (flagChecker) Enabled(ctx context.Context, name string) bool {
+	return flag.Enabled(ctx, name)
+}
@@ -0,0 +1,50 @@
+package hook
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"sniper/util/conf"
+	"sniper/util/twirp"
+)
+
+// NewInternal 注入基于配置的内部请求检查，配合接口的 @internal 注解使用
+//
+// 满足以下任意条件即认为是内部请求：
+// - 请求头 INTERNAL_GATEWAY_HEADER（默认 X-Internal-Token）的值等于 INTERNAL_GATEWAY_TOKEN
+// - 使用 mTLS 访问，且客户端证书的 CommonName 在 INTERNAL_TLS_NAMES 中
+func NewInternal() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return twirp.WithInternalChecker(ctx, confInternal{}), nil
+		},
+	}
+}
+
+type confInternal struct{}
+
+func (confInternal) Internal(req *http.Request) bool {
+	if token := conf.Get("INTERNAL_GATEWAY_TOKEN"); token != "" {
+		header := conf.Get("INTERNAL_GATEWAY_HEADER")
+		if header == "" {
+			header = "X-Internal-Token"
+		}
+
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get(header)), []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return false
+	}
+
+	cn := req.TLS.VerifiedChains[0][0].Subject.CommonName
+	for _, name := range conf.GetStrings("INTERNAL_TLS_NAMES") {
+		if name == cn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package hook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sniper/util/conf"
+	"sniper/util/twirp"
+)
+
+func TestInternal(t *testing.T) {
+	conf.Set("INTERNAL_GATEWAY_TOKEN", "secret")
+	conf.Set("INTERNAL_TLS_NAMES", "order,payment")
+	defer conf.Set("INTERNAL_GATEWAY_TOKEN", "")
+	defer conf.Set("INTERNAL_TLS_NAMES", "")
+
+	withCert := func(cn string) func(*http.Request) {
+		return func(req *http.Request) {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+	}
+	withHeader := func(k, v string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set(k, v) }
+	}
+
+	cases := []struct {
+		name   string
+		header string
+		setup  func(*http.Request)
+		want   bool
+	}{
+		{name: "no credential", setup: func(*http.Request) {}, want: false},
+		{name: "gateway token", setup: withHeader("X-Internal-Token", "secret"), want: true},
+		{name: "wrong token", setup: withHeader("X-Internal-Token", "secret1"), want: false},
+		{name: "custom header", header: "X-Gateway", setup: withHeader("X-Gateway", "secret"), want: true},
+		{name: "default header ignored", header: "X-Gateway", setup: withHeader("X-Internal-Token", "secret"), want: false},
+		{name: "tls name", setup: withCert("payment"), want: true},
+		{name: "unknown tls name", setup: withCert("user"), want: false},
+		{name: "unverified tls", setup: func(req *http.Request) { req.TLS = &tls.ConnectionState{} }, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conf.Set("INTERNAL_GATEWAY_HEADER", c.header)
+			defer conf.Set("INTERNAL_GATEWAY_HEADER", "")
+
+			req := httptest.NewRequest("POST", "/", nil)
+			c.setup(req)
+
+			ctx := twirp.WithHttpRequest(context.Background(), req)
+			ctx, err := NewInternal().RequestReceived(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := twirp.InternalRequest(ctx); got != c.want {
+				t.Errorf("internal = %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	// 没有配置 token 时请求头不生效
+	conf.Set("INTERNAL_GATEWAY_TOKEN", "")
+	if (confInternal{}).Internal(httptest.NewRequest("POST", "/", nil)) {
+		t.Error("empty token should not match an empty header")
+	}
+}
@@ -0,0 +1,96 @@
+package hook
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"sniper/util/conf"
+	"sniper/util/ctxkit"
+	"sniper/util/twirp"
+)
+
+// NewClientIP 解析用户真实 IP 并记录到 ctx，通过 ctxkit.GetUserIP 获取
+//
+// 只有直连地址在 TRUSTED_PROXIES（CIDR 或者 IP 列表）中时才会使用
+// X-Forwarded-For 和 X-Real-IP，避免客户端伪造请求头
+func NewClientIP() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			req, ok := twirp.HttpRequest(ctx)
+			if !ok {
+				return ctx, nil
+			}
+
+			ip := clientIP(req, trustedProxies(conf.GetStrings("TRUSTED_PROXIES")))
+			return ctxkit.WithUserIP(ctx, ip), nil
+		},
+	}
+}
+
+func trustedProxies(cidrs []string) (nets []*net.IPNet) {
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+
+		if _, n, err := net.ParseCIDR(s); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return
+}
+
+func trusted(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 从右往左查找 X-Forwarded-For 中第一个不可信的地址
+func clientIP(req *http.Request, nets []*net.IPNet) string {
+	remote, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remote = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(remote)
+	if ip == nil || !trusted(ip, nets) {
+		return remote
+	}
+
+	if xff := req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		addrs := strings.Split(strings.Join(xff, ","), ",")
+		client := remote
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				break
+			}
+			client = addr
+			if !trusted(ip, nets) {
+				break
+			}
+		}
+		return client
+	}
+
+	if addr := strings.TrimSpace(req.Header.Get("X-Real-IP")); net.ParseIP(addr) != nil {
+		return addr
+	}
+
+	return remote
+}
@@ -0,0 +1,114 @@
+package hook
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	nets := trustedProxies([]string{"10.0.0.0/8", " 192.168.1.1 ", "fd00::/8", "bad"})
+
+	cases := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{
+			name:   "untrusted peer",
+			remote: "1.2.3.4:1234",
+			xff:    []string{"5.6.7.8"},
+			realIP: "5.6.7.8",
+			want:   "1.2.3.4",
+		},
+		{
+			name:   "no port",
+			remote: "1.2.3.4",
+			want:   "1.2.3.4",
+		},
+		{
+			name:   "trusted peer without header",
+			remote: "10.0.0.1:1234",
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "spoofed leftmost entry",
+			remote: "10.0.0.1:1234",
+			xff:    []string{"9.9.9.9, 1.2.3.4"},
+			want:   "1.2.3.4",
+		},
+		{
+			name:   "trusted hops",
+			remote: "10.0.0.1:1234",
+			xff:    []string{"9.9.9.9, 1.2.3.4, 192.168.1.1", "10.0.0.2"},
+			want:   "1.2.3.4",
+		},
+		{
+			name:   "all hops trusted",
+			remote: "10.0.0.1:1234",
+			xff:    []string{"10.0.0.3, 10.0.0.2"},
+			want:   "10.0.0.3",
+		},
+		{
+			name:   "malformed entry",
+			remote: "10.0.0.1:1234",
+			xff:    []string{"1.2.3.4, unknown, 10.0.0.2"},
+			want:   "10.0.0.2",
+		},
+		{
+			name:   "malformed last entry",
+			remote: "10.0.0.1:1234",
+			xff:    []string{"1.2.3.4, 5.6.7.8:80"},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "ipv6",
+			remote: "[fd00::1]:1234",
+			xff:    []string{"2001:db8::1, fd00::2"},
+			want:   "2001:db8::1",
+		},
+		{
+			name:   "untrusted ipv6 peer",
+			remote: "[2001:db8::2]:1234",
+			xff:    []string{"2001:db8::1"},
+			want:   "2001:db8::2",
+		},
+		{
+			name:   "x-real-ip",
+			remote: "10.0.0.1:1234",
+			realIP: " 1.2.3.4 ",
+			want:   "1.2.3.4",
+		},
+		{
+			name:   "malformed x-real-ip",
+			remote: "10.0.0.1:1234",
+			realIP: "unknown",
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "x-forwarded-for before x-real-ip",
+			remote: "10.0.0.1:1234",
+			xff:    []string{"1.2.3.4"},
+			realIP: "5.6.7.8",
+			want:   "1.2.3.4",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = c.remote
+			for _, v := range c.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if c.realIP != "" {
+				req.Header.Set("X-Real-IP", c.realIP)
+			}
+
+			if got := clientIP(req, nets); got != c.want {
+				t.Errorf("clientIP() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
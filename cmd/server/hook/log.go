@@ -2,6 +2,9 @@ package hook
 
 import (
 	"context"
+	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	"sniper/util/conf"
@@ -52,10 +55,12 @@ func NewLog() *twirp.ServerHooks {
 			duration := time.Since(start)
 
 			status, _ := twirp.StatusCode(ctx)
-			if _, ok := ctx.Deadline(); ok {
-				if ctx.Err() != nil {
-					status = "503"
-				}
+			switch ctx.Err() {
+			case context.Canceled:
+				// 客户端断开连接，不计入服务端错误
+				status = strconv.Itoa(twirp.StatusClientClosedRequest)
+			case context.DeadlineExceeded:
+				status = "503"
 			}
 
 			hreq, _ := twirp.HttpRequest(ctx)
@@ -64,10 +69,38 @@ func NewLog() *twirp.ServerHooks {
 			// 外部爬接口脚本会请求任意 API
 			// 导致 prometheus 无法展示数据
 			if status != "404" {
+				info, _ := twirp.LookupMethod(path)
 				metrics.RPCDurationsSeconds.WithLabelValues(
 					path,
 					status,
+					info.Team,
+					info.Oncall,
 				).Observe(duration.Seconds())
+
+				for _, stage := range twirp.ServerStages(ctx) {
+					metrics.RPCStageDurationsSeconds.WithLabelValues(path, stage.Name).Observe(stage.Duration.Seconds())
+				}
+
+				recordUnknownFields(ctx, path)
+
+				for _, field := range twirp.DeprecatedFields(ctx) {
+					metrics.DeprecatedFieldTotal.WithLabelValues(path, field).Inc()
+					log.Get(ctx).Warnf("deprecated field %s of %s is used", field, path)
+				}
+
+				recordDeprecatedCall(ctx, path)
+
+				if canary, ok := twirp.Canary(ctx); ok {
+					version := "primary"
+					if canary {
+						version = "canary"
+					}
+					metrics.CanaryDurationsSeconds.WithLabelValues(
+						path,
+						version,
+						status,
+					).Observe(duration.Seconds())
+				}
 			}
 
 			form := hreq.Form
@@ -78,14 +111,19 @@ func NewLog() *twirp.ServerHooks {
 				form.Del("sign")
 			}
 
-			log.Get(ctx).WithFields(log.Fields{
+			fields := log.Fields{
 				"path":     path,
 				"status":   status,
 				"params":   form.Encode(),
 				"cost":     duration.Seconds(),
 				"biz_code": bizCode,
 				"biz_msg":  bizMsg,
-			}).Info("new rpc")
+			}
+			if req, resp, ok := twirp.LogBody(ctx); ok {
+				fields["request"] = req
+				fields["response"] = resp
+			}
+			log.Get(ctx).WithFields(fields).Info("new rpc")
 		},
 		Error: func(ctx context.Context, err twirp.Error) context.Context {
 			c := twirp.ServerHTTPStatusFromErrorCode(err.Code())
@@ -112,3 +150,53 @@ func cause(err twirp.Error) error {
 
 	return err
 }
+
+// defaultUnknownFieldLogRate UNKNOWN_FIELD_LOG_RATE 没有配置时的默认值
+const defaultUnknownFieldLogRate = 0.01
+
+// recordUnknownFields 按接口统计请求中的未知字段，并按比例采样输出字段名
+//
+// 字段名由客户端决定，不能作为指标的标签，否则任何调用方都可以让指标无限增长。
+// 采样比例通过 UNKNOWN_FIELD_LOG_RATE 配置，默认为 1%。
+func recordUnknownFields(ctx context.Context, path string) {
+	fields := twirp.UnknownFields(ctx)
+	if len(fields) == 0 {
+		return
+	}
+	metrics.UnknownFieldTotal.WithLabelValues(path).Add(float64(len(fields)))
+
+	rate := defaultUnknownFieldLogRate
+	if conf.Get("UNKNOWN_FIELD_LOG_RATE") != "" {
+		rate = conf.GetFloat64("UNKNOWN_FIELD_LOG_RATE")
+	}
+	if rand.Float64() < rate {
+		log.Get(ctx).Infof("unknown fields %s of %s", strings.Join(fields, ","), path)
+	}
+}
+
+// recordDeprecatedCall 按调用方统计废弃接口的调用量
+//
+// 调用方来自请求头，没有经过认证，只有 DEPRECATED_CALLERS 中配置的调用方
+// 作为指标的标签，其他调用方记为 other，日志中仍然输出原始的调用方。
+func recordDeprecatedCall(ctx context.Context, path string) {
+	caller, ok := twirp.DeprecatedCaller(ctx)
+	if !ok {
+		return
+	}
+
+	label := "other"
+	if caller == "unknown" {
+		label = caller
+	}
+	for _, c := range conf.GetStrings("DEPRECATED_CALLERS") {
+		if strings.TrimSpace(c) == caller {
+			label = caller
+			break
+		}
+	}
+	metrics.DeprecatedCallTotal.WithLabelValues(path, label).Inc()
+
+	if conf.GetBool("DEPRECATED_LOG") {
+		log.Get(ctx).Warnf("deprecated method %s is called by %s", path, caller)
+	}
+}
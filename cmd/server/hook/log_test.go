@@ -0,0 +1,72 @@
+package hook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sniper/util/conf"
+	"sniper/util/metrics"
+	"sniper/util/twirp"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordUnknownFields(t *testing.T) {
+	const path = "/echo.Echo/Hello"
+	conf.Set("UNKNOWN_FIELD_LOG_RATE", "0")
+	defer conf.Set("UNKNOWN_FIELD_LOG_RATE", "")
+
+	// 字段名不作为标签，指标只按接口区分
+	counter := metrics.UnknownFieldTotal.WithLabelValues(path)
+	before := testutil.ToFloat64(counter)
+
+	recordUnknownFields(context.Background(), path)
+	for _, fields := range [][]string{{"foo", "user.bar"}, {"random_1"}} {
+		recordUnknownFields(twirp.WithUnknownFields(context.Background(), fields), path)
+	}
+
+	if n := testutil.ToFloat64(counter) - before; n != 3 {
+		t.Errorf("unknown fields = %v, want 3", n)
+	}
+}
+
+func TestRecordDeprecatedCall(t *testing.T) {
+	const path = "/echo.Echo/Reload"
+	conf.Set("DEPRECATED_CALLERS", "order, payment")
+	defer conf.Set("DEPRECATED_CALLERS", "")
+
+	cases := []struct {
+		caller string
+		label  string
+	}{
+		{"order", "order"},
+		{"payment", "payment"},
+		{"unknown", "unknown"},
+		{"random-1", "other"},
+		{"random-2", "other"},
+	}
+	before := map[string]float64{}
+	for _, c := range cases {
+		before[c.label] = testutil.ToFloat64(metrics.DeprecatedCallTotal.WithLabelValues(path, c.label))
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if c.caller != "unknown" {
+			req.Header.Set(twirp.CallerHeader, c.caller)
+		}
+		ctx := twirp.WithHttpRequest(context.Background(), req)
+		ctx = twirp.MarkDeprecated(ctx, httptest.NewRecorder(), path, "", "")
+		recordDeprecatedCall(ctx, path)
+	}
+
+	want := map[string]float64{"order": 1, "payment": 1, "unknown": 1, "other": 2}
+	for label, n := range want {
+		got := testutil.ToFloat64(metrics.DeprecatedCallTotal.WithLabelValues(path, label)) - before[label]
+		if got != n {
+			t.Errorf("deprecated calls of %s = %v, want %v", label, got, n)
+		}
+	}
+}
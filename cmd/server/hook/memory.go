@@ -0,0 +1,60 @@
+package hook
+
+import (
+	"context"
+
+	"sniper/util/conf"
+	"sniper/util/metrics"
+	"sniper/util/twirp"
+)
+
+// NewMemory 按请求记账请求体和响应编码使用的内存
+//
+// 单个请求超过 REQUEST_MEMORY_LIMIT 字节时返回 resource_exhausted 错误；
+// 所有进行中的请求超过 PROCESS_MEMORY_BUDGET 字节时直接拒绝新请求，避免进程被 OOM。
+// 两个配置都为 0 时不记账。
+func NewMemory() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			limit := conf.GetInt64("REQUEST_MEMORY_LIMIT")
+			budget := conf.GetInt64("PROCESS_MEMORY_BUDGET")
+			twirp.SetMemoryBudget(budget)
+			if limit <= 0 && budget <= 0 {
+				return ctx, nil
+			}
+
+			if err := twirp.CheckMemoryBudget(); err != nil {
+				return ctx, err
+			}
+			return twirp.WithMemoryAccount(ctx, limit), nil
+		},
+		ResponseSent: func(ctx context.Context) {
+			used := twirp.ReleaseMemory(ctx)
+			if used == 0 {
+				return
+			}
+
+			metrics.RequestMemoryBytes.WithLabelValues(requestPath(ctx)).Observe(float64(used))
+			metrics.MemoryInUseBytes.Set(float64(twirp.MemoryInUse()))
+		},
+		Error: func(ctx context.Context, err twirp.Error) context.Context {
+			if err.Code() != twirp.ResourceExhausted {
+				return ctx
+			}
+			if err.Meta("memory_limit") != "" {
+				metrics.MemoryShedTotal.WithLabelValues(requestPath(ctx), "request").Inc()
+			} else if err.Meta("memory_budget") != "" {
+				metrics.MemoryShedTotal.WithLabelValues(requestPath(ctx), "process").Inc()
+			}
+			return ctx
+		},
+	}
+}
+
+// requestPath 返回请求路径，没有请求时为 unknown
+func requestPath(ctx context.Context) string {
+	if req, ok := twirp.HttpRequest(ctx); ok {
+		return req.URL.Path
+	}
+	return "unknown"
+}
@@ -0,0 +1,70 @@
+package hook
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"sniper/util/ctxkit"
+	"sniper/util/twirp"
+)
+
+// UserQuota 按用户计算的配额，由业务基于 redis 等存储实现
+type UserQuota interface {
+	// Take 为用户 uid 消耗一次名为 name 的配额，返回剩余配额，配额不足时 ok 为 false
+	Take(ctx context.Context, uid int64, name string, limit int64) (remaining int64, ok bool, err error)
+}
+
+// NewQuota 注入按用户计算的配额检查，配合接口的 @quota 注解使用
+//
+// 用户 ID 通过 ctxkit.GetUserID 获取，未登录用户直接返回 Unauthenticated 错误。
+// quota 为空时使用进程内按天计数的配额，多实例部署时每个实例单独计数。
+func NewQuota(quota UserQuota) *twirp.ServerHooks {
+	if quota == nil {
+		quota = &memoryQuota{}
+	}
+
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return twirp.WithQuotaChecker(ctx, userQuota{quota}), nil
+		},
+	}
+}
+
+type userQuota struct {
+	quota UserQuota
+}
+
+func (q userQuota) Take(ctx context.Context, name string, limit int64) (int64, bool, error) {
+	uid := ctxkit.GetUserID(ctx)
+	if uid == 0 {
+		return 0, false, twirp.NewError(twirp.Unauthenticated, "login required")
+	}
+	return q.quota.Take(ctx, uid, name, limit)
+}
+
+// memoryQuota 进程内按天计数的配额，每天零点（本地时间）重置
+type memoryQuota struct {
+	mu     sync.Mutex
+	day    string
+	counts map[string]int64
+}
+
+func (q *memoryQuota) Take(ctx context.Context, uid int64, name string, limit int64) (int64, bool, error) {
+	day := time.Now().Format("2006-01-02")
+	key := strconv.FormatInt(uid, 10) + ":" + name
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.day != day {
+		q.day, q.counts = day, map[string]int64{}
+	}
+	n := q.counts[key]
+	if n >= limit {
+		return 0, false, nil
+	}
+	q.counts[key] = n + 1
+	return limit - n - 1, true, nil
+}
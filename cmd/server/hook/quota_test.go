@@ -0,0 +1,69 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sniper/util/ctxkit"
+	"sniper/util/twirp"
+)
+
+type errQuota struct{ err error }
+
+func (q errQuota) Take(ctx context.Context, uid int64, name string, limit int64) (int64, bool, error) {
+	return 0, false, q.err
+}
+
+func TestQuota(t *testing.T) {
+	received := func(quota UserQuota, uid int64) context.Context {
+		ctx := context.Background()
+		if uid != 0 {
+			ctx = context.WithValue(ctx, ctxkit.UserIDKey, uid)
+		}
+		ctx, err := NewQuota(quota).RequestReceived(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ctx
+	}
+
+	ctx := received(nil, 1)
+	for i := 0; i < 2; i++ {
+		if err := twirp.CheckQuota(ctx, "send", 2); err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+	}
+	err := twirp.CheckQuota(ctx, "send", 2)
+	if terr, ok := err.(twirp.Error); !ok || terr.Code() != twirp.ResourceExhausted || terr.Meta("remaining") != "0" {
+		t.Errorf("quota should be exhausted, got %v", err)
+	}
+
+	// 不同用户、不同配额分别计数
+	quota := &memoryQuota{}
+	if _, ok, _ := quota.Take(ctx, 1, "send", 1); !ok {
+		t.Error("uid 1 should have quota")
+	}
+	if _, ok, _ := quota.Take(ctx, 2, "send", 1); !ok {
+		t.Error("uid 2 should have quota")
+	}
+	if remaining, ok, _ := quota.Take(ctx, 1, "like", 3); !ok || remaining != 2 {
+		t.Errorf("like quota = %d, %v, want 2, true", remaining, ok)
+	}
+
+	// 跨天之后重置
+	quota.day = "2006-01-02"
+	if _, ok, _ := quota.Take(ctx, 1, "send", 1); !ok {
+		t.Error("quota should be reset on a new day")
+	}
+
+	err = twirp.CheckQuota(received(nil, 0), "send", 2)
+	if terr, ok := err.(twirp.Error); !ok || terr.Code() != twirp.Unauthenticated {
+		t.Errorf("anonymous user should be rejected, got %v", err)
+	}
+
+	err = twirp.CheckQuota(received(errQuota{errors.New("redis down")}, 1), "send", 2)
+	if terr, ok := err.(twirp.Error); !ok || terr.Code() != twirp.Internal {
+		t.Errorf("store error should be internal, got %v", err)
+	}
+}
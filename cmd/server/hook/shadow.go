@@ -0,0 +1,16 @@
+package hook
+
+import (
+	"context"
+
+	"sniper/util/twirp"
+)
+
+// NewShadow 注入影子流量处理器，配合接口的 @shadow 注解使用
+func NewShadow(s twirp.Shadower) *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			return twirp.WithShadower(ctx, s), nil
+		},
+	}
+}
@@ -0,0 +1,220 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/metrics"
+	"sniper/util/twirp"
+)
+
+// sloBuckets 滑动窗口的分桶数量
+const sloBuckets = 10
+
+// minSLOWindow SLO_WINDOW 的最小值，保证每个桶至少 1ms
+const minSLOWindow = sloBuckets * time.Millisecond
+
+// 服务整体和核心请求的窗口，与接口路径（以 / 开头）不会冲突
+const (
+	sloService  = "service"
+	sloCritical = "critical"
+)
+
+type sloKeyType struct{}
+
+// sloState 单个请求的 SLO 状态，在 RequestReceived 阶段放入 ctx
+type sloState struct {
+	path string
+	// 低优先级请求，不计入核心请求的成功率
+	low bool
+	// 被降级拒绝的请求不计入成功率，否则拒绝越多错误率越高
+	shed bool
+}
+
+// NewSLO 按接口统计滑动窗口内的成功率，上报错误预算的消耗速度（burn rate）
+//
+// 状态码为 5xx 的请求视为失败，调用方取消的请求不统计；burn rate 为窗口内错误率与
+// 错误预算（1 - SLO_TARGET）之比，大于 1 表示按当前速度错误预算会提前耗尽，指标为
+// sniper_slo_burn_rate。除了每个接口，path 为 service 的指标统计服务整体，
+// 为 critical 的指标统计低优先级请求以外的核心请求。
+//
+// 开启 SLO_SHED 后，核心请求的 burn rate 达到 SLO_SHED_BURN_RATE 时拒绝
+// SLO_LOW_PRIORITY_PATHS 中列出的低优先级接口以及优先级为 batch 的请求，返回 unavailable
+// 错误，把资源让给核心接口；核心请求数不足 SLO_MIN_REQUESTS 时按服务整体判断。
+// 低优先级接口自身的错误不会导致它被拒绝。
+func NewSLO() *twirp.ServerHooks {
+	tracker := &sloTracker{windows: map[string]*sloWindow{}}
+
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			req, ok := twirp.HttpRequest(ctx)
+			if !ok {
+				return ctx, nil
+			}
+			// 只统计生成代码注册过的接口，避免随意请求的路径撑大内存
+			if _, ok := twirp.LookupMethod(req.URL.Path); !ok {
+				return ctx, nil
+			}
+			return context.WithValue(ctx, sloKeyType{}, &sloState{path: req.URL.Path}), nil
+		},
+		RequestRouted: func(ctx context.Context) (context.Context, error) {
+			state, ok := ctx.Value(sloKeyType{}).(*sloState)
+			if !ok {
+				return ctx, nil
+			}
+			// 优先级在 RequestReceived 之后才从请求头解析
+			state.low = lowPriority(ctx, state.path)
+			if !state.low || !conf.GetBool("SLO_SHED") {
+				return ctx, nil
+			}
+
+			threshold := conf.GetFloat64("SLO_SHED_BURN_RATE")
+			if threshold <= 0 {
+				threshold = 1
+			}
+			now := time.Now()
+			rate, ok := tracker.burnRate(sloCritical, now)
+			if !ok {
+				rate, ok = tracker.burnRate(sloService, now)
+			}
+			if ok && rate >= threshold {
+				state.shed = true
+				metrics.SLOShedTotal.WithLabelValues(state.path).Inc()
+				return ctx, twirp.NewError(twirp.Unavailable, "error budget is exhausted").
+					WithMeta("burn_rate", strconv.FormatFloat(rate, 'f', 2, 64))
+			}
+			return ctx, nil
+		},
+		ResponseSent: func(ctx context.Context) {
+			state, ok := ctx.Value(sloKeyType{}).(*sloState)
+			if !ok || state.shed {
+				return
+			}
+			// 调用方取消的请求与服务质量无关，既不算成功也不算失败
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+
+			status, _ := twirp.StatusCode(ctx)
+			failed := len(status) == 3 && status[0] == '5'
+
+			now := time.Now()
+			keys := []string{state.path, sloService}
+			if !state.low {
+				keys = append(keys, sloCritical)
+			}
+			for _, key := range keys {
+				tracker.add(key, now, failed)
+				if rate, ok := tracker.burnRate(key, now); ok {
+					metrics.SLOBurnRate.WithLabelValues(key).Set(rate)
+				}
+			}
+		},
+	}
+}
+
+// lowPriority 优先级为 batch 的请求以及 SLO_LOW_PRIORITY_PATHS 中的接口可以被拒绝
+func lowPriority(ctx context.Context, path string) bool {
+	if twirp.GetPriority(ctx) == twirp.PriorityBatch {
+		return true
+	}
+	for _, p := range conf.GetStrings("SLO_LOW_PRIORITY_PATHS") {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+type sloTracker struct {
+	mu      sync.Mutex
+	windows map[string]*sloWindow
+}
+
+func (t *sloTracker) window(path string) *sloWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.windows[path]
+	if !ok {
+		w = &sloWindow{}
+		t.windows[path] = w
+	}
+	return w
+}
+
+func (t *sloTracker) add(path string, now time.Time, failed bool) {
+	t.window(path).add(now, sloWindowSize(), failed)
+}
+
+// burnRate 返回接口当前的 burn rate，请求数不足 SLO_MIN_REQUESTS 时 ok 为 false
+func (t *sloTracker) burnRate(path string, now time.Time) (rate float64, ok bool) {
+	total, failed := t.window(path).count(now, sloWindowSize())
+	if total == 0 || total < conf.GetInt64("SLO_MIN_REQUESTS") {
+		return 0, false
+	}
+
+	target := conf.GetFloat64("SLO_TARGET")
+	if target <= 0 || target >= 1 {
+		target = 0.999
+	}
+	return float64(failed) / float64(total) / (1 - target), true
+}
+
+// sloWindowSize 返回滑动窗口的大小，小于 minSLOWindow 时使用 minSLOWindow，避免桶的大小为 0
+func sloWindowSize() time.Duration {
+	d := conf.GetDuration("SLO_WINDOW")
+	if d <= 0 {
+		return 5 * time.Minute
+	}
+	if d < minSLOWindow {
+		return minSLOWindow
+	}
+	return d
+}
+
+// sloWindow 分桶实现的滑动窗口，每个桶记录一段时间内的请求数和失败数
+type sloWindow struct {
+	mu      sync.Mutex
+	buckets [sloBuckets]struct {
+		// 桶对应的时间段序号，与当前序号相差超过桶数量时视为过期
+		index  int64
+		total  int64
+		failed int64
+	}
+}
+
+func (w *sloWindow) add(now time.Time, size time.Duration, failed bool) {
+	index := now.UnixNano() / int64(size/sloBuckets)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[index%sloBuckets]
+	if b.index != index {
+		b.index, b.total, b.failed = index, 0, 0
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+}
+
+func (w *sloWindow) count(now time.Time, size time.Duration) (total, failed int64) {
+	index := now.UnixNano() / int64(size/sloBuckets)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, b := range w.buckets {
+		if index-b.index < sloBuckets {
+			total += b.total
+			failed += b.failed
+		}
+	}
+	return
+}
@@ -0,0 +1,109 @@
+package hook
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/twirp"
+)
+
+func TestSLOWindow(t *testing.T) {
+	const size = 10 * time.Second
+	now := time.Unix(1700000000, 0)
+	w := &sloWindow{}
+
+	w.add(now, size, true)
+	w.add(now.Add(5*time.Second), size, false)
+	if total, failed := w.count(now.Add(5*time.Second), size); total != 2 || failed != 1 {
+		t.Errorf("count = %d, %d, want 2, 1", total, failed)
+	}
+
+	// 第一个桶滚出窗口
+	if total, failed := w.count(now.Add(size), size); total != 1 || failed != 0 {
+		t.Errorf("count after rollover = %d, %d, want 1, 0", total, failed)
+	}
+
+	// 复用第一个桶时先清空过期的计数
+	w.add(now.Add(size), size, false)
+	if total, failed := w.count(now.Add(size), size); total != 2 || failed != 0 {
+		t.Errorf("count after reuse = %d, %d, want 2, 0", total, failed)
+	}
+
+	if total, failed := w.count(now.Add(3*size), size); total != 0 || failed != 0 {
+		t.Errorf("count of expired window = %d, %d, want 0, 0", total, failed)
+	}
+}
+
+func TestSLOShed(t *testing.T) {
+	const (
+		core = "/slo.v1.SLO/Core"
+		low  = "/slo.v1.SLO/Low"
+	)
+	twirp.RegisterMethod(twirp.MethodInfo{Path: core})
+	twirp.RegisterMethod(twirp.MethodInfo{Path: low})
+
+	for k, v := range map[string]string{
+		"SLO_SHED":               "true",
+		"SLO_SHED_BURN_RATE":     "2",
+		"SLO_TARGET":             "0.9",
+		"SLO_MIN_REQUESTS":       "10",
+		"SLO_LOW_PRIORITY_PATHS": low,
+	} {
+		conf.Set(k, v)
+		defer conf.Set(k, "")
+	}
+
+	hooks := NewSLO()
+	call := func(path string, p twirp.Priority, status int) error {
+		ctx := twirp.WithHttpRequest(context.Background(), httptest.NewRequest("POST", path, nil))
+		ctx, err := hooks.RequestReceived(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, err = hooks.RequestRouted(twirp.WithPriority(ctx, p))
+		if err != nil {
+			status = 503
+		}
+		hooks.ResponseSent(twirp.WithStatusCode(ctx, status))
+		return err
+	}
+	callN := func(n int, path string, status int) {
+		for i := 0; i < n; i++ {
+			call(path, twirp.PriorityDefault, status)
+		}
+	}
+
+	// 核心请求错误率 10%，burn rate 为 1，没有达到阈值
+	callN(9, core, 200)
+	callN(1, core, 500)
+	if err := call(low, twirp.PriorityDefault, 200); err != nil {
+		t.Fatalf("low priority request is shed below threshold: %v", err)
+	}
+
+	// 核心请求错误率 20%，burn rate 达到 2
+	callN(7, core, 200)
+	callN(3, core, 500)
+
+	err := call(low, twirp.PriorityDefault, 200)
+	if terr, ok := err.(twirp.Error); !ok || terr.Code() != twirp.Unavailable || terr.Meta("burn_rate") != "2.00" {
+		t.Errorf("low priority path should be shed, got %v", err)
+	}
+	if err := call(core, twirp.PriorityBatch, 200); err == nil {
+		t.Error("batch request should be shed")
+	}
+
+	// 核心接口以及 default、critical 优先级的请求不会被拒绝
+	for _, p := range []twirp.Priority{twirp.PriorityDefault, twirp.PriorityCritical} {
+		if err := call(core, p, 200); err != nil {
+			t.Errorf("%s request should not be shed: %v", p, err)
+		}
+	}
+
+	// 没有注册的接口不统计
+	if err := call("/slo.v1.SLO/Unknown", twirp.PriorityBatch, 500); err != nil {
+		t.Errorf("unregistered path should be ignored: %v", err)
+	}
+}
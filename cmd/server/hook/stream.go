@@ -0,0 +1,21 @@
+package hook
+
+import (
+	"context"
+
+	"sniper/util/metrics"
+	"sniper/util/twirp"
+)
+
+func init() {
+	twirp.StreamObserver = observeStream
+}
+
+// observeStream 记录 twirp.StreamWriter 的输出分块大小和客户端吞吐量
+func observeStream(ctx context.Context, f twirp.StreamFlush) {
+	path := requestPath(ctx)
+	metrics.StreamChunkBytes.WithLabelValues(path, f.Reason).Observe(float64(f.Bytes))
+	if f.Throughput > 0 {
+		metrics.StreamThroughputBytes.WithLabelValues(path).Observe(f.Throughput)
+	}
+}
@@ -0,0 +1,47 @@
+package hook
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"sniper/util/conf"
+	"sniper/util/ctxkit"
+	"sniper/util/twirp"
+)
+
+// NewTenant 从 TENANT_HEADER 请求头解析租户 ID 并记录到 ctx，通过 ctxkit.GetTenantID 获取
+//
+// 请求头只在直连地址属于 TRUSTED_PROXIES 时生效，避免客户端伪造租户；
+// ctx 中已经有租户 ID（比如登录 hook 从 token 中解析）时不覆盖。
+// 使用 @tenant 注解的接口会拒绝没有租户 ID 的请求。
+func NewTenant() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			header := conf.Get("TENANT_HEADER")
+			if header == "" || ctxkit.GetTenantID(ctx) != "" {
+				return ctx, nil
+			}
+
+			req, ok := twirp.HttpRequest(ctx)
+			if !ok {
+				return ctx, nil
+			}
+
+			id := strings.TrimSpace(req.Header.Get(header))
+			if id == "" {
+				return ctx, nil
+			}
+
+			remote, _, err := net.SplitHostPort(req.RemoteAddr)
+			if err != nil {
+				remote = req.RemoteAddr
+			}
+			ip := net.ParseIP(remote)
+			if ip == nil || !trusted(ip, trustedProxies(conf.GetStrings("TRUSTED_PROXIES"))) {
+				return ctx, nil
+			}
+			return ctxkit.WithTenantID(ctx, id), nil
+		},
+	}
+}
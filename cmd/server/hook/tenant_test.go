@@ -0,0 +1,62 @@
+package hook
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"sniper/util/conf"
+	"sniper/util/ctxkit"
+	"sniper/util/twirp"
+)
+
+func TestTenant(t *testing.T) {
+	conf.Set("TENANT_HEADER", "X-Tenant-Id")
+	conf.Set("TRUSTED_PROXIES", "10.0.0.0/8")
+	defer conf.Set("TENANT_HEADER", "")
+	defer conf.Set("TRUSTED_PROXIES", "")
+
+	cases := []struct {
+		name   string
+		remote string
+		header string
+		tenant string
+		want   string
+	}{
+		{name: "trusted proxy", remote: "10.0.0.1:1234", header: " t1 ", want: "t1"},
+		{name: "untrusted peer", remote: "1.2.3.4:1234", header: "t1", want: ""},
+		{name: "malformed peer", remote: "unknown", header: "t1", want: ""},
+		{name: "empty header", remote: "10.0.0.1:1234", header: " ", want: ""},
+		{name: "tenant from token", remote: "10.0.0.1:1234", header: "t1", tenant: "t2", want: "t2"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", nil)
+			req.RemoteAddr = c.remote
+			req.Header.Set("X-Tenant-Id", c.header)
+
+			ctx := twirp.WithHttpRequest(context.Background(), req)
+			if c.tenant != "" {
+				ctx = ctxkit.WithTenantID(ctx, c.tenant)
+			}
+			ctx, err := NewTenant().RequestReceived(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ctxkit.GetTenantID(ctx); got != c.want {
+				t.Errorf("tenant = %q, want %q", got, c.want)
+			}
+		})
+	}
+
+	// 没有配置 TENANT_HEADER 时不解析请求头
+	conf.Set("TENANT_HEADER", "")
+	req := httptest.NewRequest("POST", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Tenant-Id", "t1")
+	ctx, _ := NewTenant().RequestReceived(twirp.WithHttpRequest(context.Background(), req))
+	if got := ctxkit.GetTenantID(ctx); got != "" {
+		t.Errorf("tenant = %q without TENANT_HEADER", got)
+	}
+}
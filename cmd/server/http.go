@@ -5,12 +5,22 @@ import (
 
 	"sniper/cmd/server/hook"
 	"sniper/util/twirp"
-
 )
 
 var hooks = twirp.ChainHooks(
 	hook.NewRequestID(),
+	hook.NewClientIP(),
+	hook.NewTenant(),
+	hook.NewBaggage(),
 	hook.NewLog(),
+	hook.NewMemory(),
+	hook.NewSLO(),
+	hook.NewDedup(nil),
+	hook.NewQuota(nil),
+	hook.NewFlag(),
+	hook.NewExplorer(),
+	hook.NewInternal(),
+	hook.NewCipher(),
 )
 
 func initMux(mux *http.ServeMux, isInternal bool) {
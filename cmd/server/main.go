@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net"
@@ -14,11 +15,13 @@ import (
 	"syscall"
 	"time"
 
+	"sniper/cmd/app"
 	"sniper/util"
 	"sniper/util/conf"
 	"sniper/util/ctxkit"
 	"sniper/util/log"
 	"sniper/util/trace"
+	"sniper/util/twirp"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
@@ -27,6 +30,12 @@ import (
 
 var server *http.Server
 
+// adminServer 管理端口，只在内网访问
+var adminServer *http.Server
+
+// health 健康检查，通过 {RPC_PREFIX}/grpc.health.v1.Health/Check 访问
+var health = twirp.NewHealthServer()
+
 type panicHandler struct {
 	handler http.Handler
 }
@@ -106,6 +115,8 @@ func main() {
 	conf.WatchConfig()
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 
+	// 预热完成之前不监听端口，/monitor/ping 不可用，负载均衡不会转发请求
+	app.RunWarmup()
 	startServer()
 
 	for {
@@ -131,6 +142,8 @@ func startServer() {
 	rand.Seed(int64(time.Now().Nanosecond()))
 
 	mux := http.NewServeMux()
+	mux.Handle(twirp.HealthPath, health)
+	health.Resume()
 
 	timeout := 600 * time.Millisecond
 	initMux(mux, isInternal)
@@ -170,8 +183,42 @@ func startServer() {
 	server = &http.Server{
 		IdleTimeout: 60 * time.Second,
 	}
+	listen(server, addr)
+
+	if adminAddr != "" {
+		adminServer = &http.Server{Handler: newAdminMux()}
+		listen(adminServer, adminAddr)
+	}
+}
 
-	// 配置下发可能会多次触发重启，必须等待 Listen() 调用成功
+// newAdminMux 管理端口的路由，输出配置等内部信息，不能通过对外的端口访问
+func newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// 输出生效的配置及其来源，敏感配置的值被隐藏
+	mux.HandleFunc("/monitor/conf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "# env = %s\n", conf.Env)
+		for _, s := range conf.Dump() {
+			fmt.Fprintf(w, "%s = %q # %s\n", s.Key, s.Value, s.Source)
+		}
+	})
+
+	// 输出所有注册的接口及其负责人、生成信息，告警和排查问题时定位负责的团队
+	mux.HandleFunc("/monitor/methods", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(twirp.Methods())
+	})
+
+	return mux
+}
+
+// listen 监听 addr 并在后台处理请求，监听成功之后才返回
+//
+// 配置下发可能会多次触发重启，必须等待 Listen() 调用成功
+func listen(srv *http.Server, addr string) {
 	var wg sync.WaitGroup
 
 	wg.Add(1)
@@ -183,7 +230,7 @@ func startServer() {
 		}
 		wg.Done()
 
-		err = server.Serve(tcpKeepAliveListener{ln.(*net.TCPListener)})
+		err = srv.Serve(tcpKeepAliveListener{ln.(*net.TCPListener)})
 		if err != http.ErrServerClosed {
 			panic(err)
 		}
@@ -195,12 +242,21 @@ func startServer() {
 func stopServer() {
 	logger.Info("stop server")
 
+	// 先让负载均衡通过健康检查摘除流量
+	health.Shutdown()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Fatal(err)
 	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Fatal(err)
+		}
+		adminServer = nil
+	}
 
 	util.Reset()
 }
@@ -0,0 +1,124 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/spf13/cobra"
+)
+
+var rootDir, rootPkg, name string
+
+func init() {
+	wd, _ := os.Getwd()
+
+	Cmd.Flags().StringVar(&rootDir, "root", wd, "项目根目录")
+	Cmd.Flags().StringVar(&rootPkg, "package", getModuleName(wd), "项目总包名")
+	Cmd.Flags().StringVar(&name, "name", "", "子命令名")
+
+	Cmd.MarkFlagRequired("name")
+}
+
+func getModuleName(wd string) string {
+	module := "sniper"
+
+	f, err := os.Open(wd + "/go.mod")
+	if err != nil {
+		return module
+	}
+	defer f.Close()
+
+	l, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		panic(err)
+	}
+	if fields := strings.Fields(l); len(fields) == 2 {
+		module = fields[1]
+	}
+	return module
+}
+
+// Cmd 子命令生成工具
+var Cmd = &cobra.Command{
+	Use:   "cmd",
+	Short: "生成子命令",
+	Long: `脚手架功能：
+- 生成 cmd/{name}/cmd.go 模版
+- 注册子命令到 cmd/commands.go`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !regexp.MustCompile(`^[a-z][a-z0-9_]*$`).MatchString(name) {
+			panic("invalid command name " + name)
+		}
+
+		genCommand()
+		registerCommand()
+	},
+}
+
+func genCommand() {
+	file := fmt.Sprintf("%s/cmd/%s/cmd.go", rootDir, name)
+	if _, err := os.Stat(file); err == nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		panic(err)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	tpl := template.Must(template.New("cmd").Parse(cmdTpl))
+	if err := tpl.Execute(f, map[string]string{"Name": name, "RootPkg": rootPkg}); err != nil {
+		panic(err)
+	}
+}
+
+// registerCommand 在 cmd/commands.go 中追加子命令的 import
+func registerCommand() {
+	file := fmt.Sprintf("%s/cmd/commands.go", rootDir)
+	path := strconv.Quote(fmt.Sprintf("%s/cmd/%s", rootPkg, name))
+
+	f, err := decorator.ParseFile(token.NewFileSet(), file, nil, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	for _, i := range f.Imports {
+		if i.Path.Value == path {
+			return
+		}
+	}
+
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*dst.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		gen.Specs = append(gen.Specs, &dst.ImportSpec{
+			Name: dst.NewIdent("_"),
+			Path: &dst.BasicLit{Kind: token.STRING, Value: path},
+		})
+		break
+	}
+
+	out, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		panic(err)
+	}
+	defer out.Close()
+	if err := decorator.Fprint(out, f); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,54 @@
+package command
+
+var cmdTpl = `package {{.Name}}
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"{{.RootPkg}}/cmd/app"
+	"{{.RootPkg}}/util"
+	"{{.RootPkg}}/util/conf"
+	"{{.RootPkg}}/util/log"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	app.Register(Cmd)
+}
+
+// Cmd {{.Name}} 子命令
+var Cmd = &cobra.Command{
+	Use:   "{{.Name}}",
+	Short: "Run {{.Name}}",
+	Long:  ` + "`Run {{.Name}}`" + `,
+	Run: func(cmd *cobra.Command, args []string) {
+		conf.OnConfigChange(func() { util.Reset() })
+		conf.WatchConfig()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+			<-stop
+			cancel()
+		}()
+
+		app.RunWarmup()
+
+		// 通过参数声明依赖，由 app.Provide 注册的构造函数创建
+		app.MustInvoke(func(logger log.Logger) error {
+			logger.Info("start {{.Name}}")
+
+			// FIXME 请开始你的表演，ctx 取消后返回
+			<-ctx.Done()
+			return nil
+		})
+
+		util.Stop()
+	},
+}
+`
@@ -0,0 +1,173 @@
+package dao
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"sniper/util/db"
+
+	"github.com/spf13/cobra"
+)
+
+var rootDir, rootPkg, name, schema string
+
+func init() {
+	wd, _ := os.Getwd()
+
+	Cmd.Flags().StringVar(&rootDir, "root", wd, "项目根目录")
+	Cmd.Flags().StringVar(&rootPkg, "package", getModuleName(wd), "项目总包名")
+	Cmd.Flags().StringVar(&name, "name", "", "dao 包名")
+	Cmd.Flags().StringVar(&schema, "schema", "", "表结构文件，包含 CREATE TABLE 语句")
+
+	Cmd.MarkFlagRequired("name")
+	Cmd.MarkFlagRequired("schema")
+}
+
+func getModuleName(wd string) string {
+	module := "sniper"
+
+	f, err := os.Open(wd + "/go.mod")
+	if err != nil {
+		return module
+	}
+	defer f.Close()
+
+	l, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		panic(err)
+	}
+	if fields := strings.Fields(l); len(fields) == 2 {
+		module = fields[1]
+	}
+	return module
+}
+
+// Cmd dao 生成工具
+var Cmd = &cobra.Command{
+	Use:   "dao",
+	Short: "根据表结构生成列定义",
+	Long: `脚手架功能：
+- 解析 --schema 文件中的 CREATE TABLE 语句（如 mysqldump --no-data 的输出）
+- 生成 dao/{name}/schema.go，包含每张表的 db.Column 列定义
+- 同时有 id 和 version 列的表生成 Update{Table}WithVersion 乐观锁更新方法
+
+表结构变化后重新执行即可，生成的文件不要手动修改。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !regexp.MustCompile(`^[a-z][a-z0-9_]*$`).MatchString(name) {
+			panic("invalid dao name " + name)
+		}
+
+		b, err := ioutil.ReadFile(schema)
+		if err != nil {
+			panic(err)
+		}
+		tables, err := parseSchema(string(b))
+		if err != nil {
+			panic(err)
+		}
+		if len(tables) == 0 {
+			panic("no CREATE TABLE statement in " + schema)
+		}
+
+		code, err := genDao(name, rootPkg, filepath.Base(schema), tables)
+		if err != nil {
+			panic(err)
+		}
+
+		file := fmt.Sprintf("%s/dao/%s/schema.go", rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(file, code, 0644); err != nil {
+			panic(err)
+		}
+	},
+}
+
+type tplColumn struct {
+	column
+	GoName string
+}
+
+type tplTable struct {
+	Name    string
+	GoName  string
+	Columns []tplColumn
+	// Version 同时有 id 和 version 列，IDType 为 id 列的类型
+	Version bool
+	IDType  string
+}
+
+// genDao 生成 dao 包中的列定义代码
+func genDao(pkg, rootPkg, source string, tables []table) ([]byte, error) {
+	imports := map[string]bool{}
+	data := struct {
+		Name    string
+		RootPkg string
+		Source  string
+		Imports []string
+		Tables  []tplTable
+	}{Name: pkg, RootPkg: rootPkg, Source: source}
+
+	tableNames := map[string]string{}
+	for _, t := range tables {
+		tt := tplTable{Name: t.Name, GoName: goName(t.Name)}
+		if prev, ok := tableNames[tt.GoName]; ok {
+			return nil, fmt.Errorf("table %s and %s have the same go name %s", prev, t.Name, tt.GoName)
+		}
+		tableNames[tt.GoName] = t.Name
+
+		names := map[string]string{}
+		var id string
+		for _, c := range t.Columns {
+			tc := tplColumn{column: c, GoName: goName(c.Name)}
+			// 与表名字段冲突
+			if tc.GoName == "Table" {
+				tc.GoName = "TableColumn"
+			}
+			if prev, ok := names[tc.GoName]; ok {
+				return nil, fmt.Errorf("column %s and %s of table %s have the same go name %s", prev, c.Name, t.Name, tc.GoName)
+			}
+			names[tc.GoName] = c.Name
+
+			if strings.HasPrefix(c.Type, "time.") {
+				imports["time"] = true
+			}
+			if c.Name == "id" {
+				id = c.Type
+			}
+			if c.Name == db.VersionColumn && c.Type == "int64" {
+				tt.Version = true
+			}
+			tt.Columns = append(tt.Columns, tc)
+		}
+
+		tt.Version = tt.Version && id != ""
+		if tt.Version {
+			tt.IDType = id
+			imports["context"] = true
+		}
+		data.Tables = append(data.Tables, tt)
+	}
+
+	for i := range imports {
+		data.Imports = append(data.Imports, i)
+	}
+	sort.Strings(data.Imports)
+
+	var buf bytes.Buffer
+	tpl := template.Must(template.New("dao").Parse(daoTpl))
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchema = "" +
+	"DROP TABLE IF EXISTS `order`;\n" +
+	"CREATE TABLE `order` (\n" +
+	"  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `user_id` bigint(20) NOT NULL COMMENT 'buyer, seller',\n" +
+	"  `status` tinyint(4) NOT NULL DEFAULT '0',\n" +
+	"  `paid` tinyint(1) NOT NULL DEFAULT '0',\n" +
+	"  `amount` decimal(10,2) NOT NULL,\n" +
+	"  `remark` varchar(255) DEFAULT 'it\\'s (ok)',\n" +
+	"  `kind` enum('a','b') NOT NULL,\n" +
+	"  `created_at` datetime NOT NULL,\n" +
+	"  `version` int NOT NULL DEFAULT '0',\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `idx_user` (`user_id`, `status`),\n" +
+	"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+	"create table if not exists shop.user_tag (tag_name varchar(32), `table` int, data blob);\n"
+
+func TestParseSchema(t *testing.T) {
+	tables, err := parseSchema(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []table{
+		{Name: "order", Columns: []column{
+			{"id", "uint64"},
+			{"user_id", "int64"},
+			{"status", "int64"},
+			{"paid", "bool"},
+			{"amount", "string"},
+			{"remark", "string"},
+			{"kind", "string"},
+			{"created_at", "time.Time"},
+			{"version", "int64"},
+		}},
+		{Name: "user_tag", Columns: []column{
+			{"tag_name", "string"},
+			{"table", "int64"},
+			{"data", "[]byte"},
+		}},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables = %+v, want %+v", tables, want)
+	}
+
+	if _, err := parseSchema("CREATE TABLE t (location geometry)"); err == nil {
+		t.Error("unsupported type should return error")
+	}
+	if _, err := parseSchema("CREATE TABLE t (id int"); err == nil {
+		t.Error("unbalanced parentheses should return error")
+	}
+}
+
+func TestGenDao(t *testing.T) {
+	tables, err := parseSchema(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := genDao("order", "sniper", "order.sql", tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(code)
+	for _, line := range []string{
+		"// Code generated by sniper dao. DO NOT EDIT.",
+		"//go:build go1.18",
+		"\t\"context\"\n\t\"time\"\n\n\t\"sniper/util/db\"\n",
+		"var Order = struct {",
+		"\tUserID    db.Column[int64]",
+		"\tCreatedAt db.Column[time.Time]",
+		"\tUserID:    db.NewColumn[int64](\"user_id\"),",
+		"func UpdateOrderWithVersion(ctx context.Context, e db.Execer, id uint64, version int64, assignments ...db.Assignment) error {",
+		"\treturn db.UpdateWithVersion(ctx, e, Order.Table, id, version, db.Fields(assignments...))",
+		"var UserTag = struct {",
+		"\tTableColumn db.Column[int64]",
+		"\tTable:       \"user_tag\",",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("generated code should contain %q\n%s", line, s)
+		}
+	}
+	if strings.Contains(s, "UpdateUserTagWithVersion") {
+		t.Error("table without version column should not have UpdateWithVersion")
+	}
+
+	tables = append(tables, table{Name: "order-table", Columns: []column{{"id", "int64"}}}, table{Name: "order_table", Columns: []column{{"id", "int64"}}})
+	if _, err := genDao("order", "sniper", "order.sql", tables); err == nil {
+		t.Error("duplicated go name should return error")
+	}
+}
@@ -0,0 +1,187 @@
+package dao
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// table 表结构
+type table struct {
+	Name    string
+	Columns []column
+}
+
+// column 列定义，Type 为 db.Column 的类型参数
+type column struct {
+	Name string
+	Type string
+}
+
+var (
+	createRe = regexp.MustCompile("(?is)CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?((?:`[^`]+`|\\w+)(?:\\.(?:`[^`]+`|\\w+))?)\\s*\\(")
+	columnRe = regexp.MustCompile("(?is)^(`[^`]+`|\\w+)\\s+(\\w+)(\\s*\\([^)]*\\))?(.*)$")
+)
+
+// 不是列定义的行
+var keywords = map[string]bool{
+	"PRIMARY":    true,
+	"KEY":        true,
+	"INDEX":      true,
+	"UNIQUE":     true,
+	"FULLTEXT":   true,
+	"SPATIAL":    true,
+	"CONSTRAINT": true,
+	"FOREIGN":    true,
+	"CHECK":      true,
+}
+
+// parseSchema 解析 CREATE TABLE 语句，一般为 mysqldump --no-data 的输出
+func parseSchema(sql string) ([]table, error) {
+	var tables []table
+	for _, loc := range createRe.FindAllStringSubmatchIndex(sql, -1) {
+		name := sql[loc[2]:loc[3]]
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		t := table{Name: strings.Trim(name, "`")}
+
+		body, ok := tableBody(sql[loc[1]:])
+		if !ok {
+			return nil, fmt.Errorf("table %s: unbalanced parentheses", t.Name)
+		}
+		for _, def := range splitDefs(body) {
+			m := columnRe.FindStringSubmatch(def)
+			if m == nil || keywords[strings.ToUpper(m[1])] {
+				continue
+			}
+			typ, err := goType(m[2], m[3], m[4])
+			if err != nil {
+				return nil, fmt.Errorf("table %s: %v", t.Name, err)
+			}
+			t.Columns = append(t.Columns, column{Name: strings.Trim(m[1], "`"), Type: typ})
+		}
+		if len(t.Columns) == 0 {
+			return nil, fmt.Errorf("table %s has no columns", t.Name)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// tableBody 返回 CREATE TABLE 括号中的内容，s 从左括号之后开始
+func tableBody(s string) (string, bool) {
+	depth := 1
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 {
+				return s[:i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// splitDefs 按照括号和引号之外的逗号拆分列定义
+func splitDefs(body string) (defs []string) {
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, strings.TrimSpace(body[start:i]))
+			start = i + 1
+		}
+	}
+	return append(defs, strings.TrimSpace(body[start:]))
+}
+
+// goType 把 MySQL 类型转换为 Go 类型，可以为 NULL 的列仍然使用值类型，判断 NULL 使用 IsNull
+func goType(typ, args, attrs string) (string, error) {
+	unsigned := strings.Contains(strings.ToUpper(attrs), "UNSIGNED")
+	switch strings.ToLower(typ) {
+	case "bool", "boolean":
+		return "bool", nil
+	case "tinyint":
+		if strings.ReplaceAll(args, " ", "") == "(1)" {
+			return "bool", nil
+		}
+		return "int64", nil
+	case "smallint", "mediumint", "int", "integer":
+		return "int64", nil
+	case "bigint":
+		if unsigned {
+			return "uint64", nil
+		}
+		return "int64", nil
+	case "float", "double", "real":
+		return "float64", nil
+	case "decimal", "numeric":
+		// 使用字符串避免精度丢失
+		return "string", nil
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set", "json", "time", "year":
+		return "string", nil
+	case "date", "datetime", "timestamp":
+		return "time.Time", nil
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bit":
+		return "[]byte", nil
+	}
+	return "", fmt.Errorf("unsupported column type %s", typ)
+}
+
+// 转换为 Go 名称时全部大写的单词
+var initialisms = map[string]bool{
+	"api":  true,
+	"http": true,
+	"id":   true,
+	"ip":   true,
+	"json": true,
+	"sql":  true,
+	"uid":  true,
+	"url":  true,
+	"uuid": true,
+}
+
+// goName 把下划线分隔的名称转换为驼峰，如 user_id 转换为 UserID
+func goName(name string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' }) {
+		word = strings.ToLower(word)
+		if initialisms[word] {
+			sb.WriteString(strings.ToUpper(word))
+			continue
+		}
+		sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	s := sb.String()
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		s = "T" + s
+	}
+	return s
+}
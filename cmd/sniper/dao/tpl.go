@@ -0,0 +1,40 @@
+package dao
+
+var daoTpl = `// Code generated by sniper dao. DO NOT EDIT.
+// source: {{.Source}}
+
+//go:build go1.18
+
+package {{.Name}}
+
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+{{- if .Imports}}
+{{end}}
+	"{{.RootPkg}}/util/db"
+)
+{{- range .Tables}}
+
+// {{.GoName}} {{.Name}} 表的列定义
+var {{.GoName}} = struct {
+	Table string
+{{- range .Columns}}
+	{{.GoName}} db.Column[{{.Type}}]
+{{- end}}
+}{
+	Table: "{{.Name}}",
+{{- range .Columns}}
+	{{.GoName}}: db.NewColumn[{{.Type}}]("{{.Name}}"),
+{{- end}}
+}
+{{- if .Version}}
+
+// Update{{.GoName}}WithVersion 使用乐观锁更新 {{.Name}} 表主键为 id 的记录，版本号不匹配时返回 *db.ConflictError
+func Update{{.GoName}}WithVersion(ctx context.Context, e db.Execer, id {{.IDType}}, version int64, assignments ...db.Assignment) error {
+	return db.UpdateWithVersion(ctx, e, {{.GoName}}.Table, id, version, db.Fields(assignments...))
+}
+{{- end}}
+{{- end}}
+`
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sniper/cmd/sniper/command"
+	"sniper/cmd/sniper/dao"
 	"sniper/cmd/sniper/rename"
 	"sniper/cmd/sniper/rpc"
 
@@ -10,6 +12,8 @@ import (
 func init() {
 	Cmd.AddCommand(rpc.Cmd)
 	Cmd.AddCommand(rename.Cmd)
+	Cmd.AddCommand(command.Cmd)
+	Cmd.AddCommand(dao.Cmd)
 }
 
 // Cmd 脚手架命令
@@ -10,6 +10,9 @@ import (
 )
 
 type {{.Service}}Server struct{}
+
+// 编译期检查 {{.Service}}Server 是否实现了全部接口方法
+var _ pb.{{.Service}} = (*{{.Service}}Server)(nil)
 `
 
 var funcTpl = `
@@ -53,6 +56,9 @@ import (
 )
 
 type {{.Service}}Server struct{}
+
+// 编译期检查 {{.Service}}Server 是否实现了全部接口方法
+var _ {{.Service}} = (*{{.Service}}Server)(nil)
 `
 
 	funcTpl = `
@@ -1,13 +1,12 @@
 module sniper
 
-go 1.13
+go 1.18
 
 require (
 	bou.ke/monkey v1.0.1
-	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
 	github.com/dave/dst v0.25.5
 	github.com/fsnotify/fsnotify v1.4.7
-	github.com/golang/protobuf v1.4.2
+	github.com/golang/protobuf v1.5.3
 	github.com/jarcoal/httpmock v1.0.3
 	github.com/k0kubun/pp/v3 v3.0.3
 	github.com/mattn/go-isatty v0.0.12
@@ -16,14 +15,44 @@ require (
 	github.com/prometheus/client_golang v1.3.0
 	github.com/robfig/cron v0.0.0-20180505203441-b41be1df6967
 	github.com/sirupsen/logrus v1.4.2
-	github.com/spf13/afero v1.3.3 // indirect
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/viper v1.3.2
 	github.com/stretchr/testify v1.6.1
+	github.com/twitchtv/twirp v8.1.3+incompatible
 	github.com/uber/jaeger-client-go v2.22.1+incompatible
 	github.com/uber/jaeger-lib v2.1.1+incompatible
-	go.uber.org/atomic v1.5.1 // indirect
 	go.uber.org/automaxprocs v1.3.0
-	golang.org/x/text v0.3.2 // indirect
-	google.golang.org/protobuf v1.25.0
+	google.golang.org/grpc v1.56.3
+	// protoc-gen-twirp 依赖 internal_gengo，升级前见 rpc/README.md
+	google.golang.org/protobuf v1.34.1
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/magiconair/properties v1.8.0 // indirect
+	github.com/mattn/go-colorable v0.1.7 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/mitchellh/mapstructure v1.1.2 // indirect
+	github.com/pelletier/go-toml v1.2.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.1.0 // indirect
+	github.com/prometheus/common v0.7.0 // indirect
+	github.com/prometheus/procfs v0.0.8 // indirect
+	github.com/spf13/afero v1.3.3 // indirect
+	github.com/spf13/cast v1.3.0 // indirect
+	github.com/spf13/jwalterweatherman v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.3 // indirect
+	go.uber.org/atomic v1.5.1 // indirect
+	golang.org/x/mod v0.8.0 // indirect
+	golang.org/x/net v0.9.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+	golang.org/x/tools v0.6.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
@@ -0,0 +1,14 @@
+//go:build tools
+// +build tools
+
+// 记录 protoc-gen-twirp 生成的可选代码需要的依赖，避免 go mod tidy 删除
+package main
+
+import (
+	// upstream=true 生成的 *.upstream.go
+	_ "github.com/twitchtv/twirp"
+	// grpc=true 生成的 *.grpc.go
+	_ "google.golang.org/grpc"
+	_ "google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/status"
+)
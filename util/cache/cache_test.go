@@ -0,0 +1,124 @@
+//go:build go1.18
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU[string, int]("test", 2, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be evicted as the least recently used")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("a = %v, %v, want 1, true", v, ok)
+	}
+
+	c.SetWithTTL("d", 4, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := c.Get("d"); ok {
+		t.Error("d should be expired")
+	}
+}
+
+type mapStore map[string][]byte
+
+func (s mapStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, ok := s[key]
+	return b, ok, nil
+}
+
+func (s mapStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s[key] = value
+	return nil
+}
+
+func (s mapStore) Delete(ctx context.Context, key string) error {
+	delete(s, key)
+	return nil
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	remote := mapStore{}
+	c := NewTiered("test", NewLRU[string, int]("test", 10, time.Minute), remote, time.Hour)
+
+	loads := 0
+	load := func(ctx context.Context) (int, error) {
+		loads++
+		return 42, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if v, err := c.Get(ctx, "answer", load); err != nil || v != 42 {
+			t.Fatalf("Get = %v, %v, want 42, nil", v, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("load is called %d times, want 1", loads)
+	}
+	if string(remote["answer"]) != "42" {
+		t.Errorf("remote = %q, want 42", remote["answer"])
+	}
+
+	// 一级缓存失效后从二级缓存回填
+	c.local.Delete("answer")
+	if v, _ := c.Get(ctx, "answer", load); v != 42 || loads != 1 {
+		t.Errorf("Get = %v with %d loads, want 42 from remote", v, loads)
+	}
+
+	_ = c.Delete(ctx, "answer")
+	fail := func(ctx context.Context) (int, error) { return 0, errors.New("db down") }
+	if _, err := c.Get(ctx, "answer", fail); err == nil {
+		t.Error("error of load should be returned")
+	}
+	if _, ok := remote["answer"]; ok {
+		t.Error("failed load should not be cached")
+	}
+}
+
+func TestTieredLoadPanic(t *testing.T) {
+	ctx := context.Background()
+	c := NewTiered[int]("test", NewLRU[string, int]("test", 10, time.Minute), nil, time.Hour)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	leader := make(chan interface{})
+	go func() {
+		defer func() { leader <- recover() }()
+		_, _ = c.Get(ctx, "answer", func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, "answer", func(ctx context.Context) (int, error) { return 42, nil })
+		waiter <- err
+	}()
+	// 等待第二个请求进入等待状态
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if r := <-leader; r != "boom" {
+		t.Errorf("leader recovered %v, want boom", r)
+	}
+	if err := <-waiter; err == nil || err.Error() != "cache: load panic: boom" {
+		t.Errorf("waiter err = %v, want load panic", err)
+	}
+	if v, err := c.Get(ctx, "answer", func(ctx context.Context) (int, error) { return 42, nil }); err != nil || v != 42 {
+		t.Errorf("Get after panic = %v, %v, want 42, nil", v, err)
+	}
+}
@@ -0,0 +1,120 @@
+//go:build go1.18
+
+// Package cache 进程内缓存
+//
+// LRU 按容量淘汰，支持为每个元素设置过期时间，命中率记录在 sniper_cache_total 指标中。
+// Tiered 将 LRU 作为一级缓存放在 redis 等二级缓存之前，都未命中时调用加载函数。
+// 需要 Go 1.18 及以上版本。
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"sniper/util/metrics"
+)
+
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	deadline time.Time
+}
+
+// LRU 容量有限的进程内缓存，并发安全
+type LRU[K comparable, V any] struct {
+	name string
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+// NewLRU 创建名为 name 的缓存，最多保存 size 个元素，ttl 为默认过期时间，为零表示不过期
+//
+// name 用作监控指标的标签。
+func NewLRU[K comparable, V any](name string, size int, ttl time.Duration) *LRU[K, V] {
+	if size <= 0 {
+		size = 1
+	}
+	return &LRU[K, V]{
+		name:  name,
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+// Get 查询缓存，不存在或者已过期时 ok 为 false
+func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, hit := c.items[key]; hit {
+		ent := e.Value.(*entry[K, V])
+		if ent.deadline.IsZero() || time.Now().Before(ent.deadline) {
+			c.ll.MoveToFront(e)
+			metrics.CacheTotal.WithLabelValues(c.name, "hit").Inc()
+			return ent.value, true
+		}
+		c.remove(e)
+	}
+
+	metrics.CacheTotal.WithLabelValues(c.name, "miss").Inc()
+	return value, false
+}
+
+// Set 使用默认过期时间保存 value
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL 保存 value，ttl 后过期，为零表示不过期
+func (c *LRU[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var deadline time.Time
+	if ttl > 0 {
+		deadline = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		ent := e.Value.(*entry[K, V])
+		ent.value, ent.deadline = value, deadline
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, deadline: deadline})
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+		metrics.CacheTotal.WithLabelValues(c.name, "evict").Inc()
+	}
+}
+
+// Delete 删除缓存
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+}
+
+// Len 返回缓存的元素数量，包括已过期但没有被清理的元素
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *LRU[K, V]) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,136 @@
+//go:build go1.18
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"sniper/util/log"
+	"sniper/util/metrics"
+)
+
+// Store 二级缓存，由业务基于 redis 等共享存储实现
+type Store interface {
+	// Get 查询 key，不存在时 ok 为 false
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set 保存 key，ttl 后过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete 删除 key
+	Delete(ctx context.Context, key string) error
+}
+
+// Tiered 两级缓存，依次查询进程内的 LRU 和 Store，都未命中时调用加载函数并回填
+//
+// 二级缓存中的数据使用 encoding/json 编码。同一个 key 并发未命中时只调用一次加载函数，
+// 避免缓存失效时大量请求同时访问数据库。Store 出错时按未命中处理，不影响接口。
+type Tiered[V any] struct {
+	name   string
+	local  *LRU[string, V]
+	remote Store
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+type call[V any] struct {
+	wg    sync.WaitGroup
+	value V
+	err   error
+}
+
+// NewTiered 创建两级缓存，ttl 为二级缓存的过期时间，一级缓存使用 local 的默认过期时间
+//
+// remote 为空时只使用进程内缓存。
+func NewTiered[V any](name string, local *LRU[string, V], remote Store, ttl time.Duration) *Tiered[V] {
+	return &Tiered[V]{
+		name:   name,
+		local:  local,
+		remote: remote,
+		ttl:    ttl,
+		calls:  make(map[string]*call[V]),
+	}
+}
+
+// Get 查询 key，都未命中时调用 load 加载，load 返回错误时不缓存
+func (t *Tiered[V]) Get(ctx context.Context, key string, load func(context.Context) (V, error)) (V, error) {
+	if v, ok := t.local.Get(key); ok {
+		return v, nil
+	}
+
+	t.mu.Lock()
+	if c, ok := t.calls[key]; ok {
+		t.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err
+	}
+	c := &call[V]{}
+	c.wg.Add(1)
+	t.calls[key] = c
+	t.mu.Unlock()
+
+	// load panic 时等待的请求返回错误，panic 继续交给当前请求的 recover 中间件处理
+	defer func() {
+		r := recover()
+		if r != nil {
+			c.err = fmt.Errorf("cache: load panic: %v", r)
+		}
+		t.mu.Lock()
+		delete(t.calls, key)
+		t.mu.Unlock()
+		c.wg.Done()
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	c.value, c.err = t.load(ctx, key, load)
+	return c.value, c.err
+}
+
+func (t *Tiered[V]) load(ctx context.Context, key string, load func(context.Context) (V, error)) (v V, err error) {
+	if t.remote != nil {
+		b, ok, err := t.remote.Get(ctx, key)
+		if err != nil {
+			log.Get(ctx).Warnf("[cache] %s get %s: %v", t.name, key, err)
+		}
+		if ok && json.Unmarshal(b, &v) == nil {
+			metrics.CacheTotal.WithLabelValues(t.name, "remote_hit").Inc()
+			t.local.Set(key, v)
+			return v, nil
+		}
+		metrics.CacheTotal.WithLabelValues(t.name, "remote_miss").Inc()
+	}
+
+	if v, err = load(ctx); err != nil {
+		return v, err
+	}
+	t.local.Set(key, v)
+
+	if t.remote != nil {
+		b, err := json.Marshal(v)
+		if err == nil {
+			err = t.remote.Set(ctx, key, b, t.ttl)
+		}
+		if err != nil {
+			log.Get(ctx).Warnf("[cache] %s set %s: %v", t.name, key, err)
+		}
+	}
+	return v, nil
+}
+
+// Delete 删除两级缓存中的 key，一般在数据更新之后调用
+//
+// 多实例部署时只能删除当前进程的一级缓存，其他实例要等到过期，
+// 一级缓存的过期时间应该设置得比较短。
+func (t *Tiered[V]) Delete(ctx context.Context, key string) error {
+	t.local.Delete(key)
+	if t.remote == nil {
+		return nil
+	}
+	return t.remote.Delete(ctx, key)
+}
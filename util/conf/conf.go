@@ -2,10 +2,13 @@
 package conf
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -15,6 +18,7 @@ import (
 
 var path string
 var files map[string]*Conf
+var filesMu sync.RWMutex
 
 var (
 	// Hostname 主机名
@@ -41,7 +45,11 @@ func init() {
 		logger().Warn("env APP_ID is empty")
 	}
 
-	if env := os.Getenv("DEPLOY_ENV"); env != "" {
+	env := os.Getenv("SNIPER_ENV")
+	if env == "" {
+		env = os.Getenv("DEPLOY_ENV")
+	}
+	if env != "" {
 		Env = env
 	} else {
 		logger().Warn("env DEPLOY_ENV is empty")
@@ -53,15 +61,6 @@ func init() {
 		logger().Warn("env ZONE is empty")
 	}
 
-	switch Env {
-	case "prod", "pre":
-		IsProdEnv = true
-	case "uat":
-		IsUatEnv = true
-	default:
-		IsDevEnv = true
-	}
-
 	path = os.Getenv("CONF_PATH")
 
 	if path == "" {
@@ -73,45 +72,137 @@ func init() {
 		logger().WithField("path", path).Info("use default conf path")
 	}
 
+	setEnv(Env)
+}
+
+// SetEnv 切换运行环境并重新加载配置文件，命令行参数 --env 使用
+//
+// 只能在程序启动时、读取配置之前调用，不能跟读取配置并发执行。
+func SetEnv(env string) {
+	if env == "" || env == Env {
+		return
+	}
+	setEnv(env)
+	logger().WithField("env", env).Info("switch conf env")
+}
+
+func setEnv(env string) {
+	Env = env
+	IsDevEnv, IsUatEnv, IsProdEnv = false, false, false
 
-	fs, err := ioutil.ReadDir(path)
+	// staging 与 uat 一样是上线前的集成环境，不开启开发环境的功能
+	switch Env {
+	case "prod", "pre":
+		IsProdEnv = true
+	case "uat", "staging":
+		IsUatEnv = true
+	default:
+		IsDevEnv = true
+	}
+
+	confs, err := load(path, Env)
 	if err != nil {
 		panic(err)
 	}
+	filesMu.Lock()
+	files = confs
+	filesMu.Unlock()
+}
 
-	files = make(map[string]*Conf, len(fs))
+// envDir 多环境配置所在的目录，相对于 CONF_PATH
+const envDir = "conf"
 
+// load 加载 dir 目录下的所有 toml 文件
+//
+// dir/conf/base.toml 存在时作为 sniper 的基础配置，dir/conf/{env}.toml 是 env 环境的覆盖配置，
+// 同时存在时覆盖配置优先，其他环境的覆盖配置不加载；此时 dir 下不能再有 sniper.toml。
+// 环境变量的优先级最高。
+func load(dir, env string) (map[string]*Conf, error) {
+	fs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	confs := map[string]*Conf{}
 	for _, f := range fs {
-		if !strings.HasSuffix(f.Name(), ".toml") {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".toml") {
 			continue
 		}
+		name := strings.TrimSuffix(f.Name(), ".toml")
+		c := &Conf{name: name}
+		if c.base, err = readFile(filepath.Join(dir, f.Name())); err != nil {
+			return nil, err
+		}
+		confs[name] = c
+	}
 
-		v := viper.New()
-		v.SetConfigFile(path + "/" + f.Name())
-		if err := v.ReadInConfig(); err != nil {
-			panic(err)
+	base := filepath.Join(dir, envDir, "base.toml")
+	if _, err := os.Stat(base); err != nil {
+		if os.IsNotExist(err) {
+			return confs, nil
 		}
-		v.AutomaticEnv()
+		return nil, err
+	}
+	if _, ok := confs["sniper"]; ok {
+		return nil, fmt.Errorf("conf: both sniper.toml and %s/base.toml exist in %s", envDir, dir)
+	}
 
-		name := strings.TrimSuffix(f.Name(), ".toml")
-		files[name] = &Conf{v}
+	c := &Conf{name: "sniper"}
+	if c.base, err = readFile(base); err != nil {
+		return nil, err
+	}
+	overlay := filepath.Join(dir, envDir, env+".toml")
+	if _, err := os.Stat(overlay); err == nil && env != "base" {
+		if c.overlay, err = readFile(overlay); err != nil {
+			return nil, err
+		}
 	}
+	confs["sniper"] = c
+	return confs, nil
 }
 
+// empty 没有对应配置文件时使用的空配置，只能读取环境变量
+func empty(name string) *Conf {
+	v := viper.New()
+	v.AutomaticEnv()
+	return &Conf{name: name, base: v}
+}
+
+func readFile(file string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	v.AutomaticEnv()
+	return v, nil
+}
+
+// Conf 一个配置文件及其当前环境的覆盖配置
 type Conf struct {
-	viper *viper.Viper
+	name    string
+	base    *viper.Viper
+	overlay *viper.Viper
+}
+
+// viper 返回 key 生效的配置，覆盖配置中存在时使用覆盖配置
+func (c *Conf) viper(key string) *viper.Viper {
+	if c.overlay != nil && c.overlay.IsSet(key) {
+		return c.overlay
+	}
+	return c.base
 }
 
 // GetFloat64 获取浮点数配置
 func GetFloat64(key string) float64 { return File("sniper").GetFloat64(key) }
 func (c *Conf) GetFloat64(key string) float64 {
-	return c.viper.GetFloat64(key)
+	return c.viper(key).GetFloat64(key)
 }
 
 // Get 获取字符串配置
 func Get(key string) string { return File("sniper").Get(key) }
 func (c *Conf) Get(key string) string {
-	return c.viper.GetString(key)
+	return c.viper(key).GetString(key)
 }
 
 // GetStrings 获取字符串列表
@@ -161,25 +252,25 @@ func (c *Conf) GetInt64s(key string) (s []int64, err error) {
 // GetInt 获取整数配置
 func GetInt(key string) int { return File("sniper").GetInt(key) }
 func (c *Conf) GetInt(key string) int {
-	return c.viper.GetInt(key)
+	return c.viper(key).GetInt(key)
 }
 
 // GetInt32 获取 int32 配置
 func GetInt32(key string) int32 { return File("sniper").GetInt32(key) }
 func (c *Conf) GetInt32(key string) int32 {
-	return c.viper.GetInt32(key)
+	return c.viper(key).GetInt32(key)
 }
 
 // GetInt64 获取 int64 配置
 func GetInt64(key string) int64 { return File("sniper").GetInt64(key) }
 func (c *Conf) GetInt64(key string) int64 {
-	return c.viper.GetInt64(key)
+	return c.viper(key).GetInt64(key)
 }
 
 // GetDuration 获取时间配置
 func GetDuration(key string) time.Duration { return File("sniper").GetDuration(key) }
 func (c *Conf) GetDuration(key string) time.Duration {
-	return c.viper.GetDuration(key)
+	return c.viper(key).GetDuration(key)
 }
 
 // GetTime 查询时间配置
@@ -195,42 +286,85 @@ func (c *Conf) GetTime(key string, args ...string) time.Time {
 		fmt = args[0]
 	}
 
-	t, _ := time.ParseInLocation(fmt, c.viper.GetString(key), time.Local)
+	t, _ := time.ParseInLocation(fmt, c.viper(key).GetString(key), time.Local)
 	return t
 }
 
 // GetBool 获取配置布尔配置
 func GetBool(key string) bool { return File("sniper").GetBool(key) }
 func (c *Conf) GetBool(key string) bool {
-	return c.viper.GetBool(key)
+	return c.viper(key).GetBool(key)
 }
 
 // Set 设置配置，仅用于测试
 func Set(key string, value string) { File("sniper").Set(key, value) }
 func (c *Conf) Set(key string, value string) {
-	c.viper.Set(key, value)
+	c.base.Set(key, value)
+	if c.overlay != nil {
+		c.overlay.Set(key, value)
+	}
 }
 
 // File 根据文件名获取对应配置对象
 // 目前仅支持 toml 文件，不用传扩展名
 // 如果要读取 foo.toml 配置，可以 File("foo").Get("bar")
+//
+// 配置文件不存在时返回空配置，只能读取环境变量，单元测试不需要准备配置文件。
 func File(name string) *Conf {
-	return files[name]
+	filesMu.RLock()
+	c, ok := files[name]
+	filesMu.RUnlock()
+	if ok {
+		return c
+	}
+
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	if c, ok = files[name]; !ok {
+		c = empty(name)
+		files[name] = c
+	}
+	return c
+}
+
+// loaded 返回从配置文件加载的配置
+func loaded() []*Conf {
+	filesMu.RLock()
+	defer filesMu.RUnlock()
+
+	confs := make([]*Conf, 0, len(files))
+	for _, c := range files {
+		if c.base.ConfigFileUsed() != "" {
+			confs = append(confs, c)
+		}
+	}
+	return confs
 }
 
 // OnConfigChange 注册配置文件变更回调
 // 需要在 WatchConfig 之前调用
 func OnConfigChange(run func()) {
-	for _, v := range files {
-		v.viper.OnConfigChange(func(in fsnotify.Event) { run() })
+	for _, c := range loaded() {
+		for _, v := range c.vipers() {
+			v.OnConfigChange(func(in fsnotify.Event) { run() })
+		}
 	}
 }
 
 // WatchConfig 启动配置变更监听，业务代码不要调用。
 func WatchConfig() {
-	for _, v := range files {
-		v.viper.WatchConfig()
+	for _, c := range loaded() {
+		for _, v := range c.vipers() {
+			v.WatchConfig()
+		}
+	}
+}
+
+func (c *Conf) vipers() []*viper.Viper {
+	if c.overlay != nil {
+		return []*viper.Viper{c.base, c.overlay}
 	}
+	return []*viper.Viper{c.base}
 }
 
 var levels = map[string]logrus.Level{
@@ -3,6 +3,8 @@ package ctxkit
 
 import (
 	"context"
+	"errors"
+	"time"
 )
 
 type key int
@@ -16,8 +18,13 @@ const (
 	UserIPKey
 	// UserIDKey 用户 ID，未登录则为 0，类型：int64
 	UserIDKey
+	// TenantIDKey 租户 ID，类型：string
+	TenantIDKey
 )
 
+// ErrNoTenant ctx 中没有租户 ID
+var ErrNoTenant = errors.New("ctxkit: tenant id is required")
+
 // GetTraceID 获取用户请求标识
 func GetTraceID(ctx context.Context) string {
 	id, _ := ctx.Value(TraceIDKey).(string)
@@ -35,8 +42,61 @@ func GetUserIP(ctx context.Context) string {
 	return ip
 }
 
+// WithUserIP 注入用户 IP
+func WithUserIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, UserIPKey, ip)
+}
+
 // GetUserID 获取当前登录用户 ID
 func GetUserID(ctx context.Context) int64 {
 	uid, _ := ctx.Value(UserIDKey).(int64)
 	return uid
 }
+
+// GetTenantID 获取当前请求的租户 ID，没有则为空
+func GetTenantID(ctx context.Context) string {
+	id, _ := ctx.Value(TenantIDKey).(string)
+	return id
+}
+
+// WithTenantID 注入租户 ID，一般由登录 hook 从 token 中解析
+func WithTenantID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, TenantIDKey, id)
+}
+
+// RequireTenantID 获取租户 ID，没有则返回 ErrNoTenant
+//
+// 按租户隔离的数据访问代码应该使用该方法拼接 tenant_id 条件，
+// 避免遗漏租户 ID 时查询到其他租户的数据：
+//
+//	tid, err := ctxkit.RequireTenantID(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	db.QueryContext(ctx, "select * from orders where tenant_id = ? and id = ?", tid, id)
+func RequireTenantID(ctx context.Context) (string, error) {
+	id := GetTenantID(ctx)
+	if id == "" {
+		return "", ErrNoTenant
+	}
+	return id, nil
+}
+
+// Detach 返回保留 ctx 中的值（trace_id、用户等），但不继承 deadline 和取消信号的 ctx
+//
+// 请求返回后还要继续执行的异步任务使用，避免任务随请求结束而被取消。
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxPlaceholders 单条语句最多的参数个数，MySQL 和 Postgres 都是 65535
+//
+// 批量写入按照这个限制拆分语句，即使 batchSize 设置得很大也不会超过。
+const MaxPlaceholders = 65535
+
+// BatchError 单个批次执行失败
+type BatchError struct {
+	// Offset 批次第一行在 rows 中的下标
+	Offset int
+	// Rows 批次的行数
+	Rows int
+	Err  error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("db: batch of rows [%d, %d) failed: %v", e.Offset, e.Offset+e.Rows, e.Err)
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// BulkError 批量写入时部分批次失败，其他批次已经写入
+//
+// 需要全部成功或者全部失败时在事务中调用，出错后回滚。
+type BulkError struct {
+	Table   string
+	Batches []*BatchError
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("db: %d batches of %s failed, first: %v", len(e.Batches), e.Table, e.Batches[0])
+}
+
+// Unwrap 返回第一个失败批次的错误
+func (e *BulkError) Unwrap() error { return e.Batches[0] }
+
+// BulkInsert 分批插入 rows，每批最多 batchSize 行，batchSize 为 0 时只受参数个数限制
+//
+// rows 的每一行按照 columns 的顺序排列。单个批次失败不影响后续批次，
+// 所有批次执行完之后返回 *BulkError；ctx 结束时不再执行剩余的批次。
+//
+//	INSERT INTO `user` (`id`, `name`) VALUES (?, ?), (?, ?)
+func BulkInsert(ctx context.Context, e Execer, table string, columns []string, rows [][]interface{}, batchSize int) error {
+	b := bulk{table: table, columns: columns, quote: quote, placeholder: mysqlPlaceholder}
+	return b.exec(ctx, e, rows, batchSize)
+}
+
+// Upsert 使用 MySQL 的 ON DUPLICATE KEY UPDATE 分批插入或者更新 rows
+//
+// 跟唯一索引冲突的行更新 update 列，update 为空时更新所有列。
+// 分批和错误处理跟 BulkInsert 一样。
+//
+//	INSERT INTO `user` (`id`, `name`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)
+func Upsert(ctx context.Context, e Execer, table string, columns []string, rows [][]interface{}, update []string, batchSize int) error {
+	if len(update) == 0 {
+		update = columns
+	}
+
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = quote(column) + " = VALUES(" + quote(column) + ")"
+	}
+
+	b := bulk{
+		table:       table,
+		columns:     columns,
+		quote:       quote,
+		placeholder: mysqlPlaceholder,
+		suffix:      " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "),
+	}
+	return b.exec(ctx, e, rows, batchSize)
+}
+
+// UpsertPostgres 使用 Postgres 的 ON CONFLICT 分批插入或者更新 rows
+//
+// conflict 为冲突检测的列，必须有对应的唯一索引；冲突的行更新 update 列，
+// update 为空时更新除 conflict 之外的所有列。占位符使用 $1、$2 的形式。
+//
+//	INSERT INTO "user" ("id", "name") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"
+func UpsertPostgres(ctx context.Context, e Execer, table string, columns []string, rows [][]interface{}, conflict, update []string, batchSize int) error {
+	if len(conflict) == 0 {
+		return errors.New("db: no conflict columns for upsert")
+	}
+
+	if len(update) == 0 {
+		keys := make(map[string]bool, len(conflict))
+		for _, column := range conflict {
+			keys[column] = true
+		}
+		for _, column := range columns {
+			if !keys[column] {
+				update = append(update, column)
+			}
+		}
+	}
+
+	targets := make([]string, len(conflict))
+	for i, column := range conflict {
+		targets[i] = quotePostgres(column)
+	}
+	suffix := " ON CONFLICT (" + strings.Join(targets, ", ") + ") DO NOTHING"
+	if len(update) > 0 {
+		sets := make([]string, len(update))
+		for i, column := range update {
+			sets[i] = quotePostgres(column) + " = EXCLUDED." + quotePostgres(column)
+		}
+		suffix = " ON CONFLICT (" + strings.Join(targets, ", ") + ") DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	b := bulk{
+		table:       table,
+		columns:     columns,
+		quote:       quotePostgres,
+		placeholder: postgresPlaceholder,
+		suffix:      suffix,
+	}
+	return b.exec(ctx, e, rows, batchSize)
+}
+
+// bulk 批量写入语句，不同数据库的标识符和占位符不同
+type bulk struct {
+	table       string
+	columns     []string
+	quote       func(string) string
+	placeholder func(n int) string
+	suffix      string
+}
+
+func mysqlPlaceholder(int) string { return "?" }
+
+func postgresPlaceholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// quotePostgres 使用双引号包裹标识符，schema.table 形式的名称分别包裹
+func quotePostgres(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.ReplaceAll(p, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+func (b *bulk) exec(ctx context.Context, e Execer, rows [][]interface{}, batchSize int) error {
+	if len(b.columns) == 0 {
+		return errors.New("db: no columns to insert")
+	}
+	for i, row := range rows {
+		if len(row) != len(b.columns) {
+			return fmt.Errorf("db: row %d has %d values, want %d", i, len(row), len(b.columns))
+		}
+	}
+
+	if max := MaxPlaceholders / len(b.columns); batchSize <= 0 || batchSize > max {
+		batchSize = max
+	}
+
+	var failed []*BatchError
+	for offset := 0; offset < len(rows); offset += batchSize {
+		end := offset + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		if err := ctx.Err(); err != nil {
+			failed = append(failed, &BatchError{Offset: offset, Rows: len(rows) - offset, Err: err})
+			break
+		}
+
+		query, args := b.build(rows[offset:end])
+		if _, err := e.ExecContext(ctx, query, args...); err != nil {
+			failed = append(failed, &BatchError{Offset: offset, Rows: end - offset, Err: err})
+		}
+	}
+
+	if len(failed) > 0 {
+		return &BulkError{Table: b.table, Batches: failed}
+	}
+	return nil
+}
+
+func (b *bulk) build(rows [][]interface{}) (string, []interface{}) {
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(rows)*len(b.columns))
+
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(b.quote(b.table))
+	sb.WriteString(" (")
+	for i, column := range b.columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(b.quote(column))
+	}
+	sb.WriteString(") VALUES ")
+
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j, v := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			args = append(args, v)
+			sb.WriteString(b.placeholder(len(args)))
+		}
+		sb.WriteString(")")
+	}
+
+	sb.WriteString(b.suffix)
+	return sb.String(), args
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// batchExecer 记录每次执行的语句，第 fail 次执行返回错误
+type batchExecer struct {
+	fail    int
+	queries []string
+	args    [][]interface{}
+}
+
+var errBatch = errors.New("duplicate entry")
+
+func (e *batchExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.queries = append(e.queries, query)
+	e.args = append(e.args, args)
+	if len(e.queries) == e.fail {
+		return nil, errBatch
+	}
+	return execResult(0), nil
+}
+
+func TestBulkInsert(t *testing.T) {
+	ctx := context.Background()
+	rows := [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}}
+
+	e := &batchExecer{}
+	if err := BulkInsert(ctx, e, "user", []string{"id", "name"}, rows, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"INSERT INTO `user` (`id`, `name`) VALUES (?, ?), (?, ?)",
+		"INSERT INTO `user` (`id`, `name`) VALUES (?, ?)",
+	}
+	if !reflect.DeepEqual(e.queries, want) {
+		t.Errorf("queries = %q", e.queries)
+	}
+	if !reflect.DeepEqual(e.args[1], []interface{}{3, "c"}) {
+		t.Errorf("args = %v", e.args)
+	}
+
+	e = &batchExecer{fail: 1}
+	err := BulkInsert(ctx, e, "user", []string{"id", "name"}, rows, 2)
+	var bulkErr *BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Batches) != 1 {
+		t.Fatalf("err = %v, want one failed batch", err)
+	}
+	if b := bulkErr.Batches[0]; b.Offset != 0 || b.Rows != 2 || !errors.Is(err, errBatch) {
+		t.Errorf("batch = %+v", b)
+	}
+	if len(e.queries) != 2 {
+		t.Errorf("remaining batches should still run, got %d queries", len(e.queries))
+	}
+
+	if err := BulkInsert(ctx, &batchExecer{}, "user", []string{"id", "name"}, [][]interface{}{{1}}, 0); err == nil {
+		t.Error("row with wrong number of values should fail")
+	}
+}
+
+func TestBulkInsertPlaceholderLimit(t *testing.T) {
+	columns := make([]string, 1000)
+	row := make([]interface{}, len(columns))
+	for i := range columns {
+		columns[i] = "c"
+	}
+	rows := make([][]interface{}, 100)
+	for i := range rows {
+		rows[i] = row
+	}
+
+	e := &batchExecer{}
+	if err := BulkInsert(context.Background(), e, "t", columns, rows, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.queries) != 2 || len(e.args[0]) != 65000 || len(e.args[1]) != 35000 {
+		t.Errorf("batches should stay below %d placeholders, got %d queries", MaxPlaceholders, len(e.queries))
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	ctx := context.Background()
+	rows := [][]interface{}{{1, "a"}, {2, "b"}}
+
+	e := &batchExecer{}
+	if err := Upsert(ctx, e, "user", []string{"id", "name"}, rows, []string{"name"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO `user` (`id`, `name`) VALUES (?, ?), (?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)"
+	if e.queries[0] != want {
+		t.Errorf("query = %s\nwant    %s", e.queries[0], want)
+	}
+
+	e = &batchExecer{}
+	if err := UpsertPostgres(ctx, e, "user", []string{"id", "name"}, rows, []string{"id"}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	want = `INSERT INTO "user" ("id", "name") VALUES ($1, $2), ($3, $4) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`
+	if e.queries[0] != want {
+		t.Errorf("query = %s\nwant    %s", e.queries[0], want)
+	}
+
+	e = &batchExecer{}
+	if err := UpsertPostgres(ctx, e, "user", []string{"id"}, [][]interface{}{{1}}, []string{"id"}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(e.queries[0], `ON CONFLICT ("id") DO NOTHING`) {
+		t.Errorf("query = %s", e.queries[0])
+	}
+}
@@ -0,0 +1,12 @@
+package db
+
+import "strings"
+
+// quote 使用反引号包裹标识符，db.table 形式的名称分别包裹
+func quote(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = "`" + strings.ReplaceAll(p, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"sniper/util/conf"
+	"sniper/util/twirp"
+)
+
+// defaultBatchConnsRatio DB_BATCH_CONNS_RATIO 没有配置时的默认值
+const defaultBatchConnsRatio = 0.5
+
+var (
+	batchSlotsMu sync.Mutex
+	batchSlots   = map[*sql.DB]chan struct{}{}
+)
+
+// Conn 按照 ctx 中的请求优先级从连接池获取一个连接，使用完成之后需要调用 Close
+//
+// 优先级为 batch 的请求最多同时占用 MaxOpenConns * DB_BATCH_CONNS_RATIO（默认 0.5，至少 1 个）
+// 个连接，超过时排队等待其他 batch 请求归还，剩余的连接留给 critical 和 default 请求，
+// 批处理流量不会占满连接池。没有设置 MaxOpenConns 的连接池不限制。
+//
+// 直接调用 *sql.DB 的方法不区分优先级，批处理任务中的查询应该先通过 Conn 获取连接。
+func Conn(ctx context.Context, db *sql.DB) (*PriorityConn, error) {
+	var slots chan struct{}
+	if twirp.GetPriority(ctx) == twirp.PriorityBatch {
+		slots = batchSlotsOf(db)
+	}
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		if slots != nil {
+			<-slots
+		}
+		return nil, err
+	}
+	return &PriorityConn{Conn: c, slots: slots}, nil
+}
+
+// PriorityConn Conn 返回的连接，Close 时归还 batch 请求占用的名额
+type PriorityConn struct {
+	*sql.Conn
+
+	once  sync.Once
+	slots chan struct{}
+}
+
+// Close 把连接归还给连接池
+func (c *PriorityConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		if c.slots != nil {
+			<-c.slots
+		}
+	})
+	return err
+}
+
+// batchSlotsOf 返回 db 的 batch 名额，连接数不限制时返回 nil
+//
+// 名额在第一次获取时按照当时的 MaxOpenConns 计算，之后修改 MaxOpenConns 不会生效。
+func batchSlotsOf(db *sql.DB) chan struct{} {
+	batchSlotsMu.Lock()
+	defer batchSlotsMu.Unlock()
+
+	if slots, ok := batchSlots[db]; ok {
+		return slots
+	}
+
+	var slots chan struct{}
+	if max := db.Stats().MaxOpenConnections; max > 0 {
+		ratio := conf.GetFloat64("DB_BATCH_CONNS_RATIO")
+		if ratio <= 0 || ratio > 1 {
+			ratio = defaultBatchConnsRatio
+		}
+		n := int(float64(max) * ratio)
+		if n < 1 {
+			n = 1
+		}
+		slots = make(chan struct{}, n)
+	}
+	batchSlots[db] = slots
+	return slots
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"sniper/util/twirp"
+)
+
+func TestConnBatchPriority(t *testing.T) {
+	pool, err := sql.Open("sniper_fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	pool.SetMaxOpenConns(2)
+
+	batch := twirp.WithPriority(context.Background(), twirp.PriorityBatch)
+	first, err := Conn(batch, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// batch 请求最多占用一半的连接
+	ctx, cancel := context.WithTimeout(batch, 20*time.Millisecond)
+	defer cancel()
+	if _, err := Conn(ctx, pool); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second batch conn should wait, got %v", err)
+	}
+
+	// 剩余的连接留给其他优先级的请求
+	other, err := Conn(context.Background(), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	first.Close()
+	first.Close()
+	second, err := Conn(batch, pool)
+	if err != nil {
+		t.Fatalf("batch conn should be available after Close: %v", err)
+	}
+	second.Close()
+}
@@ -0,0 +1,271 @@
+//go:build go1.18
+
+// Package db 数据库访问工具
+//
+// 查询构造器配合根据表结构生成的列定义使用，列名和参数类型在编译期检查，
+// 常见的单表查询不需要手写 SQL，复杂查询仍然可以使用 Raw 或者直接写 SQL。
+// 列定义由 sniper dao 命令生成，形如：
+//
+//	var User = struct {
+//		Table string
+//		ID    db.Column[int64]
+//		Name  db.Column[string]
+//	}{
+//		Table: "user",
+//		ID:    db.NewColumn[int64]("id"),
+//		Name:  db.NewColumn[string]("name"),
+//	}
+//
+// 使用方式：
+//
+//	query, args := db.Select(User.ID, User.Name).From(User.Table).
+//		Where(User.Name.Eq("foo"), User.ID.Gt(10)).
+//		OrderBy(User.ID.Desc()).Limit(10).Build()
+//
+// 需要 Go 1.18 及以上版本。
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Field 查询的字段
+type Field interface {
+	ColumnName() string
+}
+
+// Column 类型为 T 的列，由代码生成，比较的参数必须是 T 类型
+type Column[T any] struct {
+	name string
+}
+
+// NewColumn 创建名为 name 的列
+func NewColumn[T any](name string) Column[T] {
+	return Column[T]{name: name}
+}
+
+// ColumnName 返回列名
+func (c Column[T]) ColumnName() string {
+	return c.name
+}
+
+// Eq 列等于 v
+func (c Column[T]) Eq(v T) Cond { return c.compare("=", v) }
+
+// Ne 列不等于 v
+func (c Column[T]) Ne(v T) Cond { return c.compare("<>", v) }
+
+// Gt 列大于 v
+func (c Column[T]) Gt(v T) Cond { return c.compare(">", v) }
+
+// Ge 列大于等于 v
+func (c Column[T]) Ge(v T) Cond { return c.compare(">=", v) }
+
+// Lt 列小于 v
+func (c Column[T]) Lt(v T) Cond { return c.compare("<", v) }
+
+// Le 列小于等于 v
+func (c Column[T]) Le(v T) Cond { return c.compare("<=", v) }
+
+// Like 列匹配 pattern，pattern 中的 % 和 _ 需要调用方自行转义
+func (c Column[T]) Like(pattern string) Cond {
+	return Cond{sql: quote(c.name) + " LIKE ?", args: []interface{}{pattern}}
+}
+
+// In 列的值属于 vs，vs 为空时条件永远不成立
+func (c Column[T]) In(vs ...T) Cond {
+	if len(vs) == 0 {
+		return Cond{sql: "1 = 0"}
+	}
+
+	args := make([]interface{}, len(vs))
+	for i, v := range vs {
+		args[i] = v
+	}
+	placeholders := strings.Repeat("?, ", len(vs)-1) + "?"
+	return Cond{sql: quote(c.name) + " IN (" + placeholders + ")", args: args}
+}
+
+// IsNull 列为 NULL
+func (c Column[T]) IsNull() Cond { return Cond{sql: quote(c.name) + " IS NULL"} }
+
+// IsNotNull 列不为 NULL
+func (c Column[T]) IsNotNull() Cond { return Cond{sql: quote(c.name) + " IS NOT NULL"} }
+
+// Asc 按列升序排列
+func (c Column[T]) Asc() Order { return Order{sql: quote(c.name) + " ASC"} }
+
+// Desc 按列降序排列
+func (c Column[T]) Desc() Order { return Order{sql: quote(c.name) + " DESC"} }
+
+// Set 把列更新为 v，配合 Fields 使用
+func (c Column[T]) Set(v T) Assignment {
+	return Assignment{column: c.name, value: v}
+}
+
+func (c Column[T]) compare(op string, v T) Cond {
+	return Cond{sql: quote(c.name) + " " + op + " ?", args: []interface{}{v}}
+}
+
+// Assignment 列的新值
+type Assignment struct {
+	column string
+	value  interface{}
+}
+
+// Fields 把 Assignment 转换为 UpdateWithVersion 需要的参数
+//
+//	err := db.UpdateWithVersion(ctx, conn, Order.Table, id, version, db.Fields(Order.Status.Set(2)))
+func Fields(assignments ...Assignment) map[string]interface{} {
+	fields := make(map[string]interface{}, len(assignments))
+	for _, a := range assignments {
+		fields[a.column] = a.value
+	}
+	return fields
+}
+
+// Cond 查询条件
+type Cond struct {
+	sql  string
+	args []interface{}
+}
+
+// Raw 使用原始 SQL 作为条件，生成的列无法表达的条件使用
+func Raw(sql string, args ...interface{}) Cond {
+	return Cond{sql: sql, args: args}
+}
+
+// And 所有条件都成立，没有条件时永远成立
+func And(conds ...Cond) Cond { return join(" AND ", "1 = 1", conds) }
+
+// Or 任一条件成立，没有条件时永远不成立
+func Or(conds ...Cond) Cond { return join(" OR ", "1 = 0", conds) }
+
+func join(sep, empty string, conds []Cond) Cond {
+	switch len(conds) {
+	case 0:
+		return Cond{sql: empty}
+	case 1:
+		return conds[0]
+	}
+
+	var c Cond
+	parts := make([]string, len(conds))
+	for i, cond := range conds {
+		parts[i] = "(" + cond.sql + ")"
+		c.args = append(c.args, cond.args...)
+	}
+	c.sql = strings.Join(parts, sep)
+	return c
+}
+
+// Order 排序
+type Order struct {
+	sql string
+}
+
+// SelectBuilder 构造 SELECT 语句
+type SelectBuilder struct {
+	fields []Field
+	table  string
+	where  []Cond
+	orders []Order
+	limit  int
+	offset int
+}
+
+// Select 查询 fields 字段，没有字段时查询所有字段
+func Select(fields ...Field) *SelectBuilder {
+	return &SelectBuilder{fields: fields}
+}
+
+// From 设置查询的表
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.table = table
+	return b
+}
+
+// Where 追加查询条件，多个条件之间为 AND 关系
+func (b *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+// OrderBy 追加排序
+func (b *SelectBuilder) OrderBy(orders ...Order) *SelectBuilder {
+	b.orders = append(b.orders, orders...)
+	return b
+}
+
+// Limit 最多返回 n 条记录，0 表示不限制
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset 跳过前 n 条记录，需要同时设置 Limit
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// Build 返回 SQL 和参数
+func (b *SelectBuilder) Build() (string, []interface{}) {
+	var sb strings.Builder
+	var args []interface{}
+
+	sb.WriteString("SELECT ")
+	if len(b.fields) == 0 {
+		sb.WriteString("*")
+	}
+	for i, f := range b.fields {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quote(f.ColumnName()))
+	}
+
+	sb.WriteString(" FROM ")
+	sb.WriteString(quote(b.table))
+
+	if len(b.where) > 0 {
+		where := And(b.where...)
+		sb.WriteString(" WHERE ")
+		sb.WriteString(where.sql)
+		args = append(args, where.args...)
+	}
+
+	for i, o := range b.orders {
+		if i == 0 {
+			sb.WriteString(" ORDER BY ")
+		} else {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(o.sql)
+	}
+
+	if b.limit > 0 {
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(strconv.Itoa(b.limit))
+		if b.offset > 0 {
+			sb.WriteString(" OFFSET ")
+			sb.WriteString(strconv.Itoa(b.offset))
+		}
+	}
+
+	return sb.String(), args
+}
+
+// Querier 执行查询，*sql.DB、*sql.Tx 和 *sql.Conn 都实现了该接口
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Query 执行查询
+func (b *SelectBuilder) Query(ctx context.Context, q Querier) (*sql.Rows, error) {
+	query, args := b.Build()
+	return q.QueryContext(ctx, query, args...)
+}
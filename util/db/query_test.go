@@ -0,0 +1,69 @@
+//go:build go1.18
+
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+var user = struct {
+	Table string
+	ID    Column[int64]
+	Name  Column[string]
+	Email Column[string]
+}{
+	Table: "user",
+	ID:    NewColumn[int64]("id"),
+	Name:  NewColumn[string]("name"),
+	Email: NewColumn[string]("email"),
+}
+
+func TestSelect(t *testing.T) {
+	cases := []struct {
+		b    *SelectBuilder
+		sql  string
+		args []interface{}
+	}{
+		{
+			b:   Select().From(user.Table),
+			sql: "SELECT * FROM `user`",
+		},
+		{
+			b: Select(user.ID, user.Name).From(user.Table).
+				Where(user.Name.Eq("foo"), user.ID.Gt(10)).
+				OrderBy(user.ID.Desc(), user.Name.Asc()).
+				Limit(10).Offset(20),
+			sql:  "SELECT `id`, `name` FROM `user` WHERE (`name` = ?) AND (`id` > ?) ORDER BY `id` DESC, `name` ASC LIMIT 10 OFFSET 20",
+			args: []interface{}{"foo", int64(10)},
+		},
+		{
+			b: Select(user.ID).From(user.Table).
+				Where(Or(user.ID.In(1, 2, 3), user.Email.IsNull()), Raw("created_at > NOW() - INTERVAL ? DAY", 7)),
+			sql:  "SELECT `id` FROM `user` WHERE ((`id` IN (?, ?, ?)) OR (`email` IS NULL)) AND (created_at > NOW() - INTERVAL ? DAY)",
+			args: []interface{}{int64(1), int64(2), int64(3), 7},
+		},
+		{
+			b:   Select(user.ID).From("db.user").Where(user.ID.In()),
+			sql: "SELECT `id` FROM `db`.`user` WHERE 1 = 0",
+		},
+	}
+
+	for _, c := range cases {
+		sql, args := c.b.Build()
+		if sql != c.sql {
+			t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+		}
+		if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("args of %s = %v, want %v", sql, args, c.args)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	got := Fields(user.Name.Set("foo"), user.ID.Set(1))
+	want := map[string]interface{}{"name": "foo", "id": int64(1)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %v, want %v", got, want)
+	}
+}
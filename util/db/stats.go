@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/log"
+	"sniper/util/metrics"
+)
+
+// defaultWaitWarnThreshold DB_WAIT_WARN_THRESHOLD 没有配置时的默认值
+const defaultWaitWarnThreshold = 100 * time.Millisecond
+
+type pool struct {
+	db   *sql.DB
+	last sql.DBStats
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = map[string]*pool{}
+)
+
+// Register 注册名为 name 的数据源，收集监控指标时上报连接池状态
+//
+// 重复注册时后注册的覆盖先注册的。
+func Register(name string, db *sql.DB) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	pools[name] = &pool{db: db, last: db.Stats()}
+}
+
+// GatherMetrics 上报所有数据源的连接池指标，由 util.GatherMetrics 在抓取 /metrics 时调用
+//
+// 两次收集之间获取连接的平均等待时间超过 DB_WAIT_WARN_THRESHOLD 时输出告警日志，
+// 连接池耗尽时请求会一直排队直到超时，只看超时错误很难定位原因。
+func GatherMetrics() {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	threshold := conf.GetDuration("DB_WAIT_WARN_THRESHOLD")
+	if threshold <= 0 {
+		threshold = defaultWaitWarnThreshold
+	}
+
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := pools[name]
+		stats := p.db.Stats()
+		last := p.last
+		p.last = stats
+
+		metrics.DBMaxOpenConnections.WithLabelValues(name).Set(float64(stats.MaxOpenConnections))
+		metrics.DBOpenConnections.WithLabelValues(name).Set(float64(stats.OpenConnections))
+		metrics.DBInUseConnections.WithLabelValues(name).Set(float64(stats.InUse))
+		metrics.DBIdleConnections.WithLabelValues(name).Set(float64(stats.Idle))
+		metrics.DBWaitCount.WithLabelValues(name).Add(float64(stats.WaitCount - last.WaitCount))
+		metrics.DBWaitDurationSeconds.WithLabelValues(name).Add((stats.WaitDuration - last.WaitDuration).Seconds())
+		metrics.DBMaxIdleClosed.WithLabelValues(name).Add(float64(stats.MaxIdleClosed - last.MaxIdleClosed))
+		metrics.DBMaxLifetimeClosed.WithLabelValues(name).Add(float64(stats.MaxLifetimeClosed - last.MaxLifetimeClosed))
+
+		waits := stats.WaitCount - last.WaitCount
+		if waits <= 0 {
+			continue
+		}
+		avg := (stats.WaitDuration - last.WaitDuration) / time.Duration(waits)
+		if avg < threshold {
+			continue
+		}
+		log.Get(context.Background()).WithFields(log.Fields{
+			"name":          name,
+			"max_open":      stats.MaxOpenConnections,
+			"open":          stats.OpenConnections,
+			"in_use":        stats.InUse,
+			"wait_count":    waits,
+			"wait_duration": (stats.WaitDuration - last.WaitDuration).Seconds(),
+			"wait_avg":      avg.Seconds(),
+		}).Warn("db pool is saturated")
+	}
+}
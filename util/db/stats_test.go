@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"sniper/util/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeDriver 只用于测试连接池，不支持执行语句
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register("sniper_fake", fakeDriver{})
+}
+
+func TestGatherMetrics(t *testing.T) {
+	pool, err := sql.Open("sniper_fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	Register("test", pool)
+
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 连接池只有一个连接，第二次获取需要等待
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if c, err := pool.Conn(ctx); err == nil {
+			c.Close()
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	conn.Close()
+	<-done
+
+	GatherMetrics()
+
+	if got := testutil.ToFloat64(metrics.DBMaxOpenConnections.WithLabelValues("test")); got != 1 {
+		t.Errorf("max open = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.DBWaitCount.WithLabelValues("test")); got != 1 {
+		t.Errorf("wait count = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.DBWaitDurationSeconds.WithLabelValues("test")); got <= 0 {
+		t.Errorf("wait duration = %v, want > 0", got)
+	}
+
+	// 再次收集时只累加增量
+	GatherMetrics()
+	if got := testutil.ToFloat64(metrics.DBWaitCount.WithLabelValues("test")); got != 1 {
+		t.Errorf("wait count after second gather = %v, want 1", got)
+	}
+}
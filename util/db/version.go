@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sniper/util/twirp"
+)
+
+// VersionColumn 乐观锁使用的版本列，每次更新加一
+const VersionColumn = "version"
+
+// Execer 执行语句，*sql.DB、*sql.Tx 和 *sql.Conn 都实现了该接口
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ConflictError 乐观锁冲突，记录已经被其他请求修改或者已经不存在
+//
+// 实现了 twirp.Error 接口，错误码为 aborted，可以直接在接口中返回，
+// 客户端应该重新读取最新的数据之后再重试。
+type ConflictError struct {
+	Table   string
+	ID      interface{}
+	Version int64
+}
+
+// Code 返回 twirp 错误码 aborted
+func (e *ConflictError) Code() twirp.ErrorCode { return twirp.Aborted }
+
+// Msg 返回错误信息
+func (e *ConflictError) Msg() string { return "record has been modified, please reload and retry" }
+
+// Meta 返回 table、id 和 version
+func (e *ConflictError) Meta(key string) string {
+	switch key {
+	case "table":
+		return e.Table
+	case "id":
+		return fmt.Sprint(e.ID)
+	case "version":
+		return strconv.FormatInt(e.Version, 10)
+	}
+	return ""
+}
+
+// MetaMap 返回所有 meta 信息
+func (e *ConflictError) MetaMap() map[string]string {
+	return map[string]string{
+		"table":   e.Meta("table"),
+		"id":      e.Meta("id"),
+		"version": e.Meta("version"),
+	}
+}
+
+// WithMeta 返回附加了 meta 信息的 twirp 错误
+func (e *ConflictError) WithMeta(key string, val string) twirp.Error {
+	err := twirp.NewError(e.Code(), e.Msg())
+	for k, v := range e.MetaMap() {
+		err = err.WithMeta(k, v)
+	}
+	return err.WithMeta(key, val)
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("db: version conflict of %s %v at version %d", e.Table, e.ID, e.Version)
+}
+
+// IsConflict 判断 err 是否为乐观锁冲突
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
+
+// UpdateWithVersion 使用乐观锁更新主键为 id 的记录
+//
+// 只有记录的 version 列等于 version 时才更新 fields，同时 version 加一，
+// 否则返回 *ConflictError。fields 的键为列名，不能包含 id 和 version 列。
+//
+//	UPDATE `order` SET `status` = ?, `version` = `version` + 1 WHERE `id` = ? AND `version` = ?
+func UpdateWithVersion(ctx context.Context, e Execer, table string, id interface{}, version int64, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return errors.New("db: no fields to update")
+	}
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if column == "id" || column == VersionColumn {
+			return fmt.Errorf("db: can not update %s column with version", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(fields)+2)
+	sb.WriteString("UPDATE ")
+	sb.WriteString(quote(table))
+	sb.WriteString(" SET ")
+	for _, column := range columns {
+		sb.WriteString(quote(column))
+		sb.WriteString(" = ?, ")
+		args = append(args, fields[column])
+	}
+	v := quote(VersionColumn)
+	sb.WriteString(v + " = " + v + " + 1 WHERE `id` = ? AND " + v + " = ?")
+	args = append(args, id, version)
+
+	result, err := e.ExecContext(ctx, sb.String(), args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &ConflictError{Table: table, ID: id, Version: version}
+	}
+	return nil
+}
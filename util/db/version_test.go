@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"sniper/util/errors"
+	"sniper/util/twirp"
+)
+
+type execResult int64
+
+func (r execResult) LastInsertId() (int64, error) { return 0, nil }
+func (r execResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+// fakeExecer 记录执行的语句，返回固定的影响行数
+type fakeExecer struct {
+	rows  int64
+	query string
+	args  []interface{}
+}
+
+func (e *fakeExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.query, e.args = query, args
+	return execResult(e.rows), nil
+}
+
+func TestUpdateWithVersion(t *testing.T) {
+	ctx := context.Background()
+	e := &fakeExecer{rows: 1}
+
+	err := UpdateWithVersion(ctx, e, "order", 42, 3, map[string]interface{}{"status": 2, "amount": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "UPDATE `order` SET `amount` = ?, `status` = ?, `version` = `version` + 1 WHERE `id` = ? AND `version` = ?"
+	if e.query != want {
+		t.Errorf("query = %s\nwant    %s", e.query, want)
+	}
+	if !reflect.DeepEqual(e.args, []interface{}{100, 2, 42, int64(3)}) {
+		t.Errorf("args = %v", e.args)
+	}
+
+	e.rows = 0
+	err = UpdateWithVersion(ctx, e, "order", 42, 3, map[string]interface{}{"status": 2})
+	if !IsConflict(errors.Wrap(err)) {
+		t.Fatalf("err = %v, want conflict", err)
+	}
+	if twerr, ok := err.(twirp.Error); !ok || twerr.Code() != twirp.Aborted || twerr.Meta("version") != "3" {
+		t.Errorf("conflict should be an aborted twirp error, got %v", err)
+	}
+
+	if err := UpdateWithVersion(ctx, e, "order", 42, 3, map[string]interface{}{"version": 4}); err == nil || IsConflict(err) {
+		t.Errorf("updating version column should fail, got %v", err)
+	}
+}
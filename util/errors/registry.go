@@ -0,0 +1,169 @@
+package errors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"sniper/util/twirp"
+)
+
+// BizCodeMeta 业务错误码在 twirp 错误 meta 中的 key
+const BizCodeMeta = "biz_code"
+
+var (
+	registryMu sync.Mutex
+	ranges     []*Range
+	codes      = map[int32]*BizError{}
+)
+
+// Range 服务的业务错误码区间，如用户服务使用 10000 到 10999
+//
+// 一般在服务包中作为全局变量声明，所有区间和错误码在 init 阶段注册，
+// 区间重叠或者错误码重复时直接 panic，避免上线后才发现冲突。
+type Range struct {
+	Service string
+	Min     int32
+	Max     int32
+}
+
+// NewRange 注册 service 的错误码区间 [min, max]
+func NewRange(service string, min, max int32) *Range {
+	if min <= 0 || min > max {
+		panic(fmt.Sprintf("errors: invalid code range [%d, %d] of %s", min, max, service))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, r := range ranges {
+		if min <= r.Max && r.Min <= max {
+			panic(fmt.Sprintf("errors: code range [%d, %d] of %s overlaps [%d, %d] of %s",
+				min, max, service, r.Min, r.Max, r.Service))
+		}
+	}
+
+	r := &Range{Service: service, Min: min, Max: max}
+	ranges = append(ranges, r)
+	return r
+}
+
+// Define 在区间内声明业务错误，code 为业务错误码，twcode 决定 HTTP 状态码
+//
+//	var userErrors = errors.NewRange("user", 10000, 10999)
+//	var ErrUserBlocked = userErrors.Define(10001, twirp.PermissionDenied, "user is blocked")
+func (r *Range) Define(code int32, twcode twirp.ErrorCode, msg string) *BizError {
+	if code < r.Min || code > r.Max {
+		panic(fmt.Sprintf("errors: code %d is out of range [%d, %d] of %s", code, r.Min, r.Max, r.Service))
+	}
+	if !twirp.IsValidErrorCode(twcode) || twcode == twirp.NoError {
+		panic(fmt.Sprintf("errors: invalid twirp code %q of %d", twcode, code))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if e, ok := codes[code]; ok {
+		panic(fmt.Sprintf("errors: code %d of %s is already defined as %q", code, r.Service, e.msg))
+	}
+
+	e := &BizError{service: r.Service, code: code, twcode: twcode, msg: msg}
+	codes[code] = e
+	return e
+}
+
+// BizError 业务错误，实现了 twirp.Error 接口，可以直接在接口中返回
+//
+// 返回的 twirp 错误 meta 中会包含 biz_code，客户端应该根据 biz_code 而不是 msg 判断错误类型。
+type BizError struct {
+	service string
+	code    int32
+	twcode  twirp.ErrorCode
+	msg     string
+}
+
+// Service 返回错误所属的服务
+func (e *BizError) Service() string { return e.service }
+
+// BizCode 返回业务错误码
+func (e *BizError) BizCode() int32 { return e.code }
+
+// Code 返回 twirp 错误码
+func (e *BizError) Code() twirp.ErrorCode { return e.twcode }
+
+// Msg 返回错误信息
+func (e *BizError) Msg() string { return e.msg }
+
+// Meta 返回 meta 信息，只包含 biz_code
+func (e *BizError) Meta(key string) string {
+	if key == BizCodeMeta {
+		return strconv.Itoa(int(e.code))
+	}
+	return ""
+}
+
+// MetaMap 返回所有 meta 信息
+func (e *BizError) MetaMap() map[string]string {
+	return map[string]string{BizCodeMeta: e.Meta(BizCodeMeta)}
+}
+
+// WithMeta 返回附加了 meta 信息的 twirp 错误，biz_code 保持不变
+func (e *BizError) WithMeta(key string, val string) twirp.Error {
+	err := twirp.NewError(e.twcode, e.msg).WithMeta(BizCodeMeta, e.Meta(BizCodeMeta))
+	if key == BizCodeMeta {
+		return err
+	}
+	return err.WithMeta(key, val)
+}
+
+func (e *BizError) Error() string {
+	return fmt.Sprintf("twirp error %s: %s", e.twcode, e.msg)
+}
+
+// BizCode 提取 twirp 错误中的业务错误码，没有业务错误码时返回 0 和 false
+func BizCode(err error) (int32, bool) {
+	twerr, ok := Cause(err).(twirp.Error)
+	if !ok {
+		return 0, false
+	}
+	code, perr := strconv.ParseInt(twerr.Meta(BizCodeMeta), 10, 32)
+	if perr != nil {
+		return 0, false
+	}
+	return int32(code), true
+}
+
+// Is 判断 err 是否为 target 业务错误，支持 WithMeta 之后以及 Wrap 之后的错误
+func Is(err error, target *BizError) bool {
+	code, ok := BizCode(err)
+	return ok && code == target.code
+}
+
+// CodeInfo 导出的错误码信息，用于生成文档
+type CodeInfo struct {
+	Service   string `json:"service"`
+	Code      int32  `json:"code"`
+	TwirpCode string `json:"twirp_code"`
+	Status    int    `json:"status"`
+	Msg       string `json:"msg"`
+}
+
+// Codes 返回所有注册过的业务错误码，按照错误码排序
+func Codes() []CodeInfo {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	infos := make([]CodeInfo, 0, len(codes))
+	for _, e := range codes {
+		infos = append(infos, CodeInfo{
+			Service:   e.service,
+			Code:      e.code,
+			TwirpCode: string(e.twcode),
+			Status:    twirp.ServerHTTPStatusFromErrorCode(e.twcode),
+			Msg:       e.msg,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Code < infos[j].Code })
+	return infos
+}
@@ -0,0 +1,55 @@
+package errors
+
+import (
+	"testing"
+
+	"sniper/util/twirp"
+)
+
+var testErrors = NewRange("test", 90000, 90099)
+
+var errTestBlocked = testErrors.Define(90001, twirp.PermissionDenied, "user is blocked")
+
+func mustPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s should panic", name)
+		}
+	}()
+	f()
+}
+
+func TestRegistry(t *testing.T) {
+	mustPanic(t, "overlapped range", func() { NewRange("other", 90050, 90150) })
+	mustPanic(t, "duplicated code", func() { testErrors.Define(90001, twirp.NotFound, "dup") })
+	mustPanic(t, "out of range", func() { testErrors.Define(90100, twirp.NotFound, "out") })
+
+	var found bool
+	for _, c := range Codes() {
+		if c.Code == 90001 {
+			found = c.Service == "test" && c.Status == 403 && c.Msg == "user is blocked"
+		}
+	}
+	if !found {
+		t.Errorf("code 90001 is not exported: %v", Codes())
+	}
+}
+
+func TestBizError(t *testing.T) {
+	var err error = errTestBlocked
+	if code, ok := BizCode(err); !ok || code != 90001 {
+		t.Errorf("BizCode = %d, %v", code, ok)
+	}
+
+	withMeta := errTestBlocked.WithMeta("uid", "1")
+	if withMeta.Code() != twirp.PermissionDenied || withMeta.Meta(BizCodeMeta) != "90001" || withMeta.Meta("uid") != "1" {
+		t.Errorf("WithMeta = %v %v", withMeta, withMeta.MetaMap())
+	}
+	if !Is(Wrap(withMeta), errTestBlocked) {
+		t.Errorf("wrapped error should match")
+	}
+	if Is(twirp.NotFoundError("x"), errTestBlocked) {
+		t.Errorf("other error should not match")
+	}
+}
@@ -0,0 +1,41 @@
+package flag
+
+import (
+	"strings"
+
+	"sniper/util/conf"
+)
+
+// ConfBackend 从配置文件读取开关规则，配置支持热更新
+//
+// 开关 name 对应以下配置，NAME 为 name 的大写形式：
+//
+//	FLAG_NAME = true                                  # 全量打开
+//	FLAG_NAME_UIDS = "1,2,3"                          # 用户白名单
+//	FLAG_NAME_PERCENT = 10                            # 按用户灰度 10%
+//	FLAG_NAME_ATTRS = "platform=ios,platform=android" # 只对 ios 和 android 生效
+type ConfBackend struct{}
+
+// Rule 实现 Backend 接口，所有配置都为空时规则不存在
+func (ConfBackend) Rule(name string) (Rule, bool) {
+	key := "FLAG_" + strings.ToUpper(name)
+
+	var rule Rule
+	rule.Enabled = conf.GetBool(key)
+	rule.UIDs, _ = conf.GetInt64s(key + "_UIDS")
+	rule.Percent = conf.GetFloat64(key + "_PERCENT")
+	for _, attr := range conf.GetStrings(key + "_ATTRS") {
+		i := strings.Index(attr, "=")
+		if i <= 0 {
+			continue
+		}
+		if rule.Attrs == nil {
+			rule.Attrs = map[string][]string{}
+		}
+		k := strings.TrimSpace(attr[:i])
+		rule.Attrs[k] = append(rule.Attrs[k], strings.TrimSpace(attr[i+1:]))
+	}
+
+	ok := rule.Enabled || len(rule.UIDs) > 0 || rule.Percent > 0
+	return rule, ok
+}
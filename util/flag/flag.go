@@ -0,0 +1,121 @@
+// Package flag 功能开关
+//
+// 支持全量打开、按用户 ID 比例灰度、用户白名单以及按请求属性过滤，
+// 开关规则默认从配置文件读取，也可以通过 SetBackend 切换到远程配置。
+//
+//	if flag.Enabled(ctx, "new_checkout") {
+//		// 新逻辑
+//	}
+package flag
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+
+	"sniper/util/ctxkit"
+	"sniper/util/metrics"
+)
+
+// Rule 开关规则
+//
+// Attrs 不为空时，请求属性需要全部匹配（同一个属性匹配任意一个值即可），
+// 匹配之后满足 Enabled、UIDs、Percent 任意一项则开关打开。
+type Rule struct {
+	// Enabled 全量打开
+	Enabled bool `json:"enabled"`
+	// UIDs 用户白名单
+	UIDs []int64 `json:"uids"`
+	// Percent 按用户 ID 灰度的比例，取值 0 到 100，未登录用户不参与灰度
+	Percent float64 `json:"percent"`
+	// Attrs 请求属性规则，如 {"platform": ["ios", "android"]}
+	Attrs map[string][]string `json:"attrs"`
+}
+
+// Backend 开关规则来源
+type Backend interface {
+	// Rule 返回名为 name 的开关规则，规则不存在时 ok 为 false
+	Rule(name string) (rule Rule, ok bool)
+}
+
+var backend atomic.Value
+
+func init() {
+	SetBackend(ConfBackend{})
+}
+
+// SetBackend 设置开关规则来源，一般在 main 函数中调用，默认为 ConfBackend
+func SetBackend(b Backend) {
+	backend.Store(&b)
+}
+
+type attrsKey struct{}
+
+// WithAttr 设置请求属性，用于匹配开关规则的 Attrs，一般在 hook 中调用
+func WithAttr(ctx context.Context, key, value string) context.Context {
+	old, _ := ctx.Value(attrsKey{}).(map[string]string)
+	attrs := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		attrs[k] = v
+	}
+	attrs[key] = value
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// Enabled 判断开关 name 是否对当前请求（用户）打开，规则不存在则认为是关闭的
+//
+// 判断结果记录在 sniper_flag_evaluation_total 指标中。
+func Enabled(ctx context.Context, name string) bool {
+	rule, ok := (*backend.Load().(*Backend)).Rule(name)
+	enabled := ok && rule.match(ctx, name)
+	metrics.FlagEvaluationTotal.WithLabelValues(name, strconv.FormatBool(enabled)).Inc()
+	return enabled
+}
+
+func (r Rule) match(ctx context.Context, name string) bool {
+	if len(r.Attrs) > 0 {
+		attrs, _ := ctx.Value(attrsKey{}).(map[string]string)
+		for k, values := range r.Attrs {
+			if !contains(values, attrs[k]) {
+				return false
+			}
+		}
+	}
+
+	if r.Enabled {
+		return true
+	}
+
+	uid := ctxkit.GetUserID(ctx)
+	if uid == 0 {
+		return false
+	}
+	for _, v := range r.UIDs {
+		if v == uid {
+			return true
+		}
+	}
+	return r.Percent > 0 && float64(bucket(name, uid)) < r.Percent*100
+}
+
+// bucket 将用户分到 [0, 10000) 的桶中，不同开关的分桶相互独立
+func bucket(name string, uid int64) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte(":"))
+	h.Write([]byte(strconv.FormatInt(uid, 10)))
+	return h.Sum32() % 10000
+}
+
+func contains(values []string, v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
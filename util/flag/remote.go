@@ -0,0 +1,137 @@
+package flag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"sniper/util/log"
+	"sniper/util/xhttp"
+)
+
+// RemoteBackend 定时从远程地址拉取开关规则
+//
+// 接口返回开关名称到规则的 JSON 对象，如：
+//
+//	{"new_checkout": {"percent": 10, "attrs": {"platform": ["ios"]}}}
+//
+// 拉取失败时继续使用上次的规则。不再使用时调用 Close 停止拉取。
+type RemoteBackend struct {
+	url    string
+	client xhttp.Client
+
+	mu    sync.RWMutex
+	rules map[string]Rule
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRemoteBackend 创建 RemoteBackend，每隔 interval（默认 1 分钟）拉取一次规则
+//
+// 首次拉取在返回之前完成，失败时所有开关都是关闭的。
+func NewRemoteBackend(url string, interval time.Duration) *RemoteBackend {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	b := &RemoteBackend{
+		url:    url,
+		client: xhttp.NewClient(5 * time.Second),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	b.refresh(context.Background())
+	go b.run(interval)
+	return b
+}
+
+// Close 停止定时拉取，等待正在进行的拉取结束，之后 Rule 继续返回最后一次拉取的规则
+func (b *RemoteBackend) Close() error {
+	b.closeOnce.Do(func() { close(b.stop) })
+	<-b.done
+	return nil
+}
+
+func (b *RemoteBackend) run(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-b.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.refresh(ctx)
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Rule 实现 Backend 接口
+func (b *RemoteBackend) Rule(name string) (Rule, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	rule, ok := b.rules[name]
+	return rule, ok
+}
+
+func (b *RemoteBackend) refresh(ctx context.Context) {
+	rules, err := b.fetch(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Get(ctx).Warnf("[flag] fetch rules from %s: %v", b.url, err)
+		return
+	}
+
+	b.mu.Lock()
+	b.rules = rules
+	b.mu.Unlock()
+}
+
+func (b *RemoteBackend) fetch(ctx context.Context) (map[string]Rule, error) {
+	req, err := http.NewRequest(http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules map[string]Rule
+	if err := json.Unmarshal(body, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
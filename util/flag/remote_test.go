@@ -0,0 +1,57 @@
+package flag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteBackend(t *testing.T) {
+	var calls int64
+	var body atomic.Value
+	body.Store(`{"new_checkout": {"enabled": true}}`)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	defer s.Close()
+
+	b := NewRemoteBackend(s.URL, 10*time.Millisecond)
+	if rule, ok := b.Rule("new_checkout"); !ok || !rule.Enabled {
+		t.Fatalf("rules should be fetched before NewRemoteBackend returns, got %v %v", rule, ok)
+	}
+
+	// 拉取失败时继续使用上次的规则
+	body.Store(`invalid`)
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := b.Rule("new_checkout"); !ok {
+		t.Error("rules should be kept when fetch fails")
+	}
+
+	body.Store(`{"new_search": {"percent": 10}}`)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := b.Rule("new_search"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rules should be refreshed periodically")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Close()
+	n := atomic.LoadInt64(&calls)
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt64(&calls) != n {
+		t.Error("Close should stop refreshing")
+	}
+	if _, ok := b.Rule("new_search"); !ok {
+		t.Error("rules should be kept after Close")
+	}
+}
@@ -0,0 +1,157 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnowflake(t *testing.T) {
+	s, err := NewSnowflake(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := int64(0)
+	for i := 0; i < 10000; i++ {
+		id, err := s.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatalf("id %d is not greater than %d", id, last)
+		}
+		last = id
+	}
+
+	if worker := last >> sequenceBits & MaxWorkerID; worker != 3 {
+		t.Errorf("worker id = %d, want 3", worker)
+	}
+	if d := time.Since(Time(last)); d < 0 || d > time.Second {
+		t.Errorf("time of id is %v ago", d)
+	}
+
+	if _, err := NewSnowflake(MaxWorkerID + 1); err != ErrInvalidWorkerID {
+		t.Errorf("err = %v, want ErrInvalidWorkerID", err)
+	}
+
+	// 时钟回拨不超过 MaxClockBackwards 时等待，超过时返回错误
+	s.last = s.now() + 2
+	if id, err := s.Next(); err != nil || id <= last {
+		t.Errorf("Next after small clock backwards = %d, %v", id, err)
+	}
+	s.last = s.now() + MaxClockBackwards.Milliseconds() + 1000
+	if _, err := s.Next(); err != ErrClockBackwards {
+		t.Errorf("err = %v, want ErrClockBackwards", err)
+	}
+}
+
+// memEvaler 按照脚本的语义模拟 redis，不处理过期
+type memEvaler struct {
+	mu     sync.Mutex
+	values map[string]string
+	// renew 为 false 时续期总是失败，模拟租约被其他进程抢占
+	renew bool
+}
+
+func (e *memEvaler) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key, value := keys[0], args[0].(string)
+	switch script {
+	case acquireScript:
+		if _, ok := e.values[key]; ok {
+			return int64(0), nil
+		}
+		e.values[key] = value
+		return int64(1), nil
+	case renewScript:
+		if e.renew && e.values[key] == value {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, errors.New("unknown script")
+}
+
+func TestLeaseAllocator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	evaler := &memEvaler{values: map[string]string{"idgen:test:0": "other"}, renew: true}
+	a := &LeaseAllocator{Store: NewRedisLeaseStore(evaler), Prefix: "idgen:test:", TTL: 30 * time.Millisecond}
+	lease, err := a.Allocate(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.WorkerID != 1 {
+		t.Errorf("worker id = %d, want 1", lease.WorkerID)
+	}
+
+	// 续期成功时租约一直有效
+	s := NewLeaseSnowflake(lease)
+	time.Sleep(50 * time.Millisecond)
+	if _, err := s.Next(); err != nil {
+		t.Fatalf("Next with renewed lease: %v", err)
+	}
+
+	evaler.mu.Lock()
+	evaler.renew = false
+	evaler.mu.Unlock()
+	lost := make(chan int64, 1)
+	lease, err = a.Allocate(ctx, func(id int64) { lost <- id })
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-lost:
+		if id != 2 {
+			t.Errorf("lost worker id = %d, want 2", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("onLost should be called when renew fails")
+	}
+	if _, err := NewLeaseSnowflake(lease).Next(); err != ErrLeaseExpired {
+		t.Errorf("err = %v, want ErrLeaseExpired", err)
+	}
+}
+
+func TestLeaseSafetyMargin(t *testing.T) {
+	a := &LeaseAllocator{
+		Store:        NewRedisLeaseStore(&memEvaler{values: map[string]string{}}),
+		TTL:          time.Hour,
+		SafetyMargin: 10 * time.Minute,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lease, err := a.Allocate(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Unix(0, lease.deadline)
+	if d := time.Until(deadline); d > 50*time.Minute || d < 49*time.Minute {
+		t.Errorf("lease is valid for %v, want TTL - SafetyMargin", d)
+	}
+}
+
+func TestBase62(t *testing.T) {
+	for _, n := range []int64{0, 1, 61, 62, 1<<63 - 1} {
+		s := EncodeBase62(n)
+		if v, err := DecodeBase62(s); err != nil || v != n {
+			t.Errorf("DecodeBase62(%q) = %d, %v, want %d", s, v, err, n)
+		}
+	}
+
+	for _, s := range []string{"", "a-b", "zzzzzzzzzzz"} {
+		if _, err := DecodeBase62(s); err == nil {
+			t.Errorf("DecodeBase62(%q) should fail", s)
+		}
+	}
+
+	if id := ShortID(22); len(id) != 22 || id == ShortID(22) {
+		t.Errorf("unexpected short id %q", id)
+	}
+}
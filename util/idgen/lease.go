@@ -0,0 +1,125 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/log"
+)
+
+// ErrNoWorkerID 所有 worker ID 都已经被占用
+var ErrNoWorkerID = errors.New("idgen: no worker id available")
+
+// ErrLeaseExpired worker ID 的租约已经失效，不能继续生成 ID
+var ErrLeaseExpired = errors.New("idgen: lease of worker id expired")
+
+// LeaseStore 分配 worker ID 使用的存储，redis 可以使用 NewRedisLeaseStore，其他存储由业务实现
+type LeaseStore interface {
+	// Acquire key 不存在时写入 value，ttl 后过期，返回是否写入成功
+	Acquire(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Renew key 的值等于 value 时延长过期时间，返回是否续期成功
+	Renew(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+}
+
+// LeaseAllocator 通过租约分配唯一的 worker ID
+//
+// 依次尝试占用 {prefix}{id}，占用成功后每隔 ttl/3 续期一次。
+// 续期失败说明租约已经过期，worker ID 可能被其他进程占用，此时调用 onLost，
+// 一般应该停止生成 ID 并退出进程。
+type LeaseAllocator struct {
+	Store  LeaseStore
+	Prefix string
+	TTL    time.Duration
+	// SafetyMargin 租约到期之前提前停止生成 ID 的时间，默认为 TTL/5，应该大于各实例之间的时钟误差
+	SafetyMargin time.Duration
+}
+
+// Lease 分配到的 worker ID 及其租约
+type Lease struct {
+	WorkerID int64
+
+	// deadline 停止生成 ID 的时间，即最近一次成功续期的开始时间加上 TTL - SafetyMargin，单位为纳秒
+	deadline int64
+}
+
+// Valid 判断租约是否还在有效期内，失效之后 worker ID 可能被其他进程占用
+func (l *Lease) Valid() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&l.deadline)
+}
+
+func (l *Lease) extend(start time.Time, valid time.Duration) {
+	atomic.StoreInt64(&l.deadline, start.Add(valid).UnixNano())
+}
+
+// Allocate 分配 worker ID，并在后台续期直到 ctx 结束
+//
+// 只在租约到期前 SafetyMargin 之内使用返回的 worker ID，NewLeaseSnowflake 创建的生成器会自动检查。
+func (a *LeaseAllocator) Allocate(ctx context.Context, onLost func(workerID int64)) (*Lease, error) {
+	ttl := a.TTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	margin := a.SafetyMargin
+	if margin <= 0 || margin >= ttl {
+		margin = ttl / 5
+	}
+	prefix := a.Prefix
+	if prefix == "" {
+		prefix = "idgen:" + conf.AppID + ":"
+	}
+	value := conf.Hostname + ":" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	for id := int64(0); id <= MaxWorkerID; id++ {
+		key := prefix + strconv.FormatInt(id, 10)
+		// 从请求发出的时间开始计算有效期，存储的响应延迟不会让有效期超过租约
+		start := time.Now()
+		ok, err := a.Store.Acquire(ctx, key, value, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			lease := &Lease{WorkerID: id}
+			lease.extend(start, ttl-margin)
+			go a.renew(ctx, key, value, ttl, margin, lease, onLost)
+			return lease, nil
+		}
+	}
+	return nil, ErrNoWorkerID
+}
+
+func (a *LeaseAllocator) renew(ctx context.Context, key, value string, ttl, margin time.Duration, lease *Lease, onLost func(int64)) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	id := lease.WorkerID
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		ok, err := a.Store.Renew(ctx, key, value, ttl)
+		if err == nil && ok {
+			lease.extend(start, ttl-margin)
+			continue
+		}
+		if err != nil {
+			log.Get(ctx).Warnf("[idgen] renew worker id %d: %v", id, err)
+		}
+		// 存储暂时不可用时租约可能还没有过期，过期之后才认为丢失
+		if (err == nil && !ok) || !lease.Valid() {
+			lease.extend(start, 0)
+			log.Get(ctx).Errorf("[idgen] lease of worker id %d is lost", id)
+			if onLost != nil {
+				onLost(id)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package idgen
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// acquireScript SET key value NX PX ttl，写入成功返回 1
+	acquireScript = `if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then return 1 end return 0`
+	// renewScript key 的值等于 value 时 PEXPIRE，续期成功返回 1
+	renewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+)
+
+// RedisEvaler 执行 lua 脚本的 redis 客户端，由业务基于具体的客户端实现
+//
+// go-redis 的实现为 client.Eval(ctx, script, keys, args...).Result()。
+type RedisEvaler interface {
+	// Eval 执行 EVAL script，脚本返回整数时结果为 int64
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// NewRedisLeaseStore 返回基于 redis 的 LeaseStore，续期时比较 value，不会延长其他进程的租约
+func NewRedisLeaseStore(client RedisEvaler) LeaseStore {
+	return &redisLeaseStore{client: client}
+}
+
+type redisLeaseStore struct {
+	client RedisEvaler
+}
+
+func (s *redisLeaseStore) Acquire(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return s.eval(ctx, acquireScript, key, value, ttl)
+}
+
+func (s *redisLeaseStore) Renew(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return s.eval(ctx, renewScript, key, value, ttl)
+}
+
+func (s *redisLeaseStore) eval(ctx context.Context, script, key, value string, ttl time.Duration) (bool, error) {
+	v, err := s.client.Eval(ctx, script, []string{key}, value, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return false, fmt.Errorf("idgen: unexpected redis reply %T", v)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,72 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrInvalidBase62 字符串不是合法的 base62 编码
+var ErrInvalidBase62 = errors.New("idgen: invalid base62 string")
+
+// EncodeBase62 将非负整数编码为 base62 字符串，可以缩短 snowflake ID 的长度
+func EncodeBase62(n int64) string {
+	if n <= 0 {
+		return "0"
+	}
+
+	var b [11]byte
+	i := len(b)
+	for n > 0 {
+		i--
+		b[i] = base62[n%62]
+		n /= 62
+	}
+	return string(b[i:])
+}
+
+// DecodeBase62 解析 EncodeBase62 生成的字符串
+func DecodeBase62(s string) (int64, error) {
+	if s == "" || len(s) > 11 {
+		return 0, ErrInvalidBase62
+	}
+
+	var n int64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		var d int64
+		switch {
+		case c >= '0' && c <= '9':
+			d = int64(c - '0')
+		case c >= 'A' && c <= 'Z':
+			d = int64(c-'A') + 10
+		case c >= 'a' && c <= 'z':
+			d = int64(c-'a') + 36
+		default:
+			return 0, ErrInvalidBase62
+		}
+		if n > (1<<63-1-d)/62 {
+			return 0, ErrInvalidBase62
+		}
+		n = n*62 + d
+	}
+	return n, nil
+}
+
+// ShortID 生成长度为 n 的随机 base62 字符串，n 为 22 时随机性与 UUID 相当
+//
+// 使用 crypto/rand，不能被猜到，适合分享链接、邀请码等对外暴露的 ID。
+func ShortID(n int) string {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(base62)))
+	for i := range b {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = base62[v.Int64()]
+	}
+	return string(b)
+}
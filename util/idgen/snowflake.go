@@ -0,0 +1,133 @@
+// Package idgen 分布式 ID 生成
+//
+// Snowflake 生成按时间递增的 64 位整数 ID，适合作为数据库主键；
+// ShortID 生成随机的 base62 字符串，适合对外暴露、不能被猜到的 ID。
+package idgen
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"sniper/util/conf"
+)
+
+const (
+	workerBits   = 10
+	sequenceBits = 12
+
+	// MaxWorkerID 最大的 worker ID
+	MaxWorkerID = 1<<workerBits - 1
+
+	maxSequence = 1<<sequenceBits - 1
+)
+
+// Epoch snowflake 时间戳的起点，2020-01-01 00:00:00 UTC，41 位毫秒时间戳可以使用到 2089 年
+var Epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ErrInvalidWorkerID worker ID 超出范围
+var ErrInvalidWorkerID = errors.New("idgen: worker id must be between 0 and 1023")
+
+// ErrClockBackwards 系统时钟回拨超过 MaxClockBackwards
+var ErrClockBackwards = errors.New("idgen: clock moved backwards")
+
+// MaxClockBackwards 允许等待的时钟回拨时间，超过时 Next 返回 ErrClockBackwards
+var MaxClockBackwards = 10 * time.Millisecond
+
+// Snowflake ID 生成器，ID 由 41 位毫秒时间戳、10 位 worker ID 和 12 位序号组成
+//
+// 同一时刻使用相同 worker ID 的进程会生成重复的 ID，多实例部署时需要保证 worker ID 唯一，
+// 参考 LeaseAllocator。
+type Snowflake struct {
+	mu       sync.Mutex
+	workerID int64
+	lease    *Lease
+	last     int64
+	sequence int64
+}
+
+// NewSnowflake 创建 worker ID 为 workerID 的生成器
+func NewSnowflake(workerID int64) (*Snowflake, error) {
+	if workerID < 0 || workerID > MaxWorkerID {
+		return nil, ErrInvalidWorkerID
+	}
+	return &Snowflake{workerID: workerID}, nil
+}
+
+// NewLeaseSnowflake 创建使用租约中 worker ID 的生成器，租约失效后 Next 返回 ErrLeaseExpired
+func NewLeaseSnowflake(lease *Lease) *Snowflake {
+	return &Snowflake{workerID: lease.WorkerID, lease: lease}
+}
+
+// Next 生成下一个 ID
+//
+// 每毫秒最多生成 4096 个 ID，超过时等待下一毫秒。系统时钟回拨时等待时钟追上上次生成 ID 的时间，
+// 保证 ID 递增，回拨超过 MaxClockBackwards 时返回 ErrClockBackwards。等待时不占用锁。
+func (s *Snowflake) Next() (int64, error) {
+	for {
+		id, wait, err := s.next()
+		if wait <= 0 {
+			return id, err
+		}
+		time.Sleep(wait)
+	}
+}
+
+// next 生成 ID，需要等待时钟前进时返回等待时间
+func (s *Snowflake) next() (id int64, wait time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lease != nil && !s.lease.Valid() {
+		return 0, 0, ErrLeaseExpired
+	}
+
+	now := s.now()
+	if now < s.last {
+		wait = time.Duration(s.last-now) * time.Millisecond
+		if wait > MaxClockBackwards {
+			return 0, 0, ErrClockBackwards
+		}
+		return 0, wait, nil
+	}
+
+	if now == s.last {
+		if s.sequence == maxSequence {
+			return 0, 100 * time.Microsecond, nil
+		}
+		s.sequence++
+	} else {
+		s.sequence = 0
+	}
+	s.last = now
+
+	return now<<(workerBits+sequenceBits) | s.workerID<<sequenceBits | s.sequence, 0, nil
+}
+
+func (s *Snowflake) now() int64 {
+	return time.Since(Epoch).Nanoseconds() / int64(time.Millisecond)
+}
+
+// Time 返回 snowflake ID 的生成时间
+func Time(id int64) time.Time {
+	ms := id >> (workerBits + sequenceBits)
+	return Epoch.Add(time.Duration(ms) * time.Millisecond)
+}
+
+var (
+	defaultOnce sync.Once
+	defaultGen  *Snowflake
+)
+
+// Next 使用配置 IDGEN_WORKER_ID 作为 worker ID 生成 ID，配置超出范围时 panic
+//
+// 使用 LeaseAllocator 分配 worker ID 的服务应该使用 NewLeaseSnowflake 自己创建 Snowflake。
+func Next() (int64, error) {
+	defaultOnce.Do(func() {
+		var err error
+		if defaultGen, err = NewSnowflake(conf.GetInt64("IDGEN_WORKER_ID")); err != nil {
+			panic(err)
+		}
+	})
+	return defaultGen.Next()
+}
@@ -7,18 +7,64 @@ import (
 )
 
 var (
-	// RPCDurationsSeconds rpc 服务耗时
+	// RPCDurationsSeconds rpc 服务耗时，team、oncall 来自接口的 @team、@oncall 注解，用于告警路由
 	RPCDurationsSeconds *prometheus.HistogramVec
+	// RPCStageDurationsSeconds rpc 服务各处理阶段（route、auth、decode 等）的耗时
+	RPCStageDurationsSeconds *prometheus.HistogramVec
+	// CanaryDurationsSeconds 配置了灰度实现的 rpc 服务耗时，按实现区分
+	CanaryDurationsSeconds *prometheus.HistogramVec
+	// UnknownFieldTotal JSON 请求中未知字段数量统计
+	UnknownFieldTotal *prometheus.CounterVec
+	// DeprecatedFieldTotal 请求中废弃字段的使用次数统计
+	DeprecatedFieldTotal *prometheus.CounterVec
+	// DeprecatedCallTotal 废弃接口的调用次数统计，按调用方区分
+	DeprecatedCallTotal *prometheus.CounterVec
+	// CacheTotal 进程内缓存命中统计
+	CacheTotal *prometheus.CounterVec
+	// WorkerTaskTotal 后台任务数量统计
+	WorkerTaskTotal *prometheus.CounterVec
+	// WorkerDurationsSeconds 后台任务耗时
+	WorkerDurationsSeconds *prometheus.HistogramVec
+	// FlagEvaluationTotal 功能开关判断次数
+	FlagEvaluationTotal *prometheus.CounterVec
+	// WarmupDurationSeconds 启动预热任务耗时
+	WarmupDurationSeconds *prometheus.GaugeVec
+	// SLOBurnRate 滑动窗口内错误预算的消耗速度
+	SLOBurnRate *prometheus.GaugeVec
+	// SLOShedTotal 错误预算耗尽时拒绝的低优先级请求数量
+	SLOShedTotal *prometheus.CounterVec
 	// DBDurationsSeconds mysql 调用耗时
 	DBDurationsSeconds *prometheus.HistogramVec
 	// MCDurationsSeconds memcache 调用耗时
 	MCDurationsSeconds *prometheus.HistogramVec
 	// RedisDurationsSeconds redis 调用耗时
 	RedisDurationsSeconds *prometheus.HistogramVec
+	// RedisHotKeyTotal 检测到热点 key 并复制到进程内缓存的次数
+	RedisHotKeyTotal *prometheus.CounterVec
+	// RPCClientDurationsSeconds 调用下游 twirp 服务的耗时，按结果区分
+	RPCClientDurationsSeconds *prometheus.HistogramVec
+	// RPCClientRetryTotal 调用下游 twirp 服务的重试次数
+	RPCClientRetryTotal *prometheus.CounterVec
 	// HTTPDurationsSeconds http 调用耗时
 	HTTPDurationsSeconds *prometheus.HistogramVec
+	// HTTPRetryTotal http 调用重试次数
+	HTTPRetryTotal *prometheus.CounterVec
+	// HTTPBreakerOpen http 调用是否处于熔断状态
+	HTTPBreakerOpen *prometheus.GaugeVec
+	// StreamChunkBytes 流式响应每次输出的字节数，按输出原因区分
+	StreamChunkBytes *prometheus.HistogramVec
+	// StreamThroughputBytes 流式响应估算的客户端吞吐量，单位为字节每秒
+	StreamThroughputBytes *prometheus.HistogramVec
+	// RequestMemoryBytes 单个请求记账的内存
+	RequestMemoryBytes *prometheus.HistogramVec
+	// MemoryInUseBytes 所有进行中请求记账的内存总量
+	MemoryInUseBytes prometheus.Gauge
+	// MemoryShedTotal 内存超过限制被拒绝的请求数量，按单个请求超限和进程预算耗尽区分
+	MemoryShedTotal *prometheus.CounterVec
 	// MQDurationsSeconds databus 调用耗时
 	MQDurationsSeconds *prometheus.HistogramVec
+	// MQDedupTotal 消费消息时的去重结果
+	MQDedupTotal *prometheus.CounterVec
 
 	// LogTotal log 调用数量统计
 	LogTotal *prometheus.CounterVec
@@ -48,6 +94,8 @@ var (
 	DBIdleConnections *prometheus.GaugeVec
 	// DBWaitCount 从 DB 连接池取不到连接需要等待的总数量
 	DBWaitCount *prometheus.CounterVec
+	// DBWaitDurationSeconds 从 DB 连接池取连接等待的总时间
+	DBWaitDurationSeconds *prometheus.CounterVec
 	// DBMaxIdleClosed 因为 SetMaxIdleConns 而被关闭的连接总数量
 	DBMaxIdleClosed *prometheus.CounterVec
 	// DBMaxLifetimeClosed 因为 SetConnMaxLifetime 而被关闭的连接总数量
@@ -63,9 +111,108 @@ func init() {
 		Help:        "RPC latency distributions",
 		Buckets:     defBuckets,
 		ConstLabels: map[string]string{"app": conf.AppID},
-	}, []string{"path", "code"})
+	}, []string{"path", "code", "team", "oncall"})
 	prometheus.MustRegister(RPCDurationsSeconds)
 
+	RPCStageDurationsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "sniper",
+		Name:        "rpc_stage_durations_seconds",
+		Help:        "RPC latency distributions of each handler stage",
+		Buckets:     []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path", "stage"})
+	prometheus.MustRegister(RPCStageDurationsSeconds)
+
+	CanaryDurationsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "sniper",
+		Name:        "canary_durations_seconds",
+		Help:        "RPC latency distributions of primary and canary implementations",
+		Buckets:     defBuckets,
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path", "version", "code"})
+	prometheus.MustRegister(CanaryDurationsSeconds)
+
+	UnknownFieldTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "unknown_field_total",
+		Help:        "unknown fields in json requests",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path"})
+	prometheus.MustRegister(UnknownFieldTotal)
+
+	DeprecatedFieldTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "deprecated_field_total",
+		Help:        "deprecated fields in requests",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path", "field"})
+	prometheus.MustRegister(DeprecatedFieldTotal)
+
+	DeprecatedCallTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "deprecated_call_total",
+		Help:        "calls of deprecated methods by caller",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path", "caller"})
+	prometheus.MustRegister(DeprecatedCallTotal)
+
+	CacheTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "cache_total",
+		Help:        "cache lookups by result",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"name", "result"})
+	prometheus.MustRegister(CacheTotal)
+
+	WorkerTaskTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "worker_task_total",
+		Help:        "background tasks by result",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"pool", "task", "result"})
+	prometheus.MustRegister(WorkerTaskTotal)
+
+	WorkerDurationsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "sniper",
+		Name:        "worker_durations_seconds",
+		Help:        "background task latency distributions",
+		Buckets:     defBuckets,
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"pool", "task"})
+	prometheus.MustRegister(WorkerDurationsSeconds)
+
+	WarmupDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "sniper",
+		Name:        "warmup_duration_seconds",
+		Help:        "warmup task duration by result",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"task", "result"})
+	prometheus.MustRegister(WarmupDurationSeconds)
+
+	FlagEvaluationTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "flag_evaluation_total",
+		Help:        "feature flag evaluations",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"flag", "enabled"})
+	prometheus.MustRegister(FlagEvaluationTotal)
+
+	SLOBurnRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "sniper",
+		Name:        "slo_burn_rate",
+		Help:        "error budget burn rate in the rolling window",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path"})
+	prometheus.MustRegister(SLOBurnRate)
+
+	SLOShedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "slo_shed_total",
+		Help:        "low priority requests rejected when error budget is exhausted",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path"})
+	prometheus.MustRegister(SLOShedTotal)
+
 	DBDurationsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   "sniper",
 		Name:        "db_durations_seconds",
@@ -93,6 +240,31 @@ func init() {
 	}, []string{"name", "cmd"})
 	prometheus.MustRegister(RedisDurationsSeconds)
 
+	RedisHotKeyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "redis_hot_key_total",
+		Help:        "redis hot keys promoted to local cache",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"name"})
+	prometheus.MustRegister(RedisHotKeyTotal)
+
+	RPCClientDurationsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "sniper",
+		Name:        "rpc_client_durations_seconds",
+		Help:        "RPC client latency distributions",
+		Buckets:     defBuckets,
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"service", "method", "code"})
+	prometheus.MustRegister(RPCClientDurationsSeconds)
+
+	RPCClientRetryTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "rpc_client_retry_total",
+		Help:        "RPC client retries",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"service", "method"})
+	prometheus.MustRegister(RPCClientRetryTotal)
+
 	HTTPDurationsSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   "sniper",
 		Name:        "http_durations_seconds",
@@ -102,6 +274,65 @@ func init() {
 	}, []string{"url", "status"})
 	prometheus.MustRegister(HTTPDurationsSeconds)
 
+	HTTPRetryTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "http_retry_total",
+		Help:        "HTTP retries by host",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"host"})
+	prometheus.MustRegister(HTTPRetryTotal)
+
+	HTTPBreakerOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "sniper",
+		Name:        "http_breaker_open",
+		Help:        "whether the circuit breaker of host is open",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"host"})
+	prometheus.MustRegister(HTTPBreakerOpen)
+
+	StreamChunkBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "sniper",
+		Name:        "stream_chunk_bytes",
+		Help:        "stream chunk sizes by flush reason",
+		Buckets:     prometheus.ExponentialBuckets(256, 4, 7),
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path", "reason"})
+	prometheus.MustRegister(StreamChunkBytes)
+
+	StreamThroughputBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "sniper",
+		Name:        "stream_throughput_bytes",
+		Help:        "estimated client throughput of streams in bytes per second",
+		Buckets:     prometheus.ExponentialBuckets(16<<10, 4, 7),
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path"})
+	prometheus.MustRegister(StreamThroughputBytes)
+
+	RequestMemoryBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "sniper",
+		Name:        "request_memory_bytes",
+		Help:        "approximate memory allocated per request",
+		Buckets:     prometheus.ExponentialBuckets(1<<10, 4, 8),
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path"})
+	prometheus.MustRegister(RequestMemoryBytes)
+
+	MemoryInUseBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   "sniper",
+		Name:        "memory_in_use_bytes",
+		Help:        "approximate memory used by in-flight requests",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	})
+	prometheus.MustRegister(MemoryInUseBytes)
+
+	MemoryShedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "memory_shed_total",
+		Help:        "requests rejected by memory limits",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"path", "reason"})
+	prometheus.MustRegister(MemoryShedTotal)
+
 	LogTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace:   "sniper",
 		Name:        "log_total",
@@ -127,6 +358,14 @@ func init() {
 	}, []string{"name", "role"})
 	prometheus.MustRegister(MQDurationsSeconds)
 
+	MQDedupTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "mq_dedup_total",
+		Help:        "mq consumer dedup results",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"topic", "result"})
+	prometheus.MustRegister(MQDedupTotal)
+
 	NetPoolHits = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace:   "sniper",
 		Name:        "net_pool_hits",
@@ -215,6 +454,14 @@ func init() {
 	}, []string{"name"})
 	prometheus.MustRegister(DBWaitCount)
 
+	DBWaitDurationSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "sniper",
+		Name:        "db_wait_duration_seconds",
+		Help:        "db total time blocked waiting for a new connection",
+		ConstLabels: map[string]string{"app": conf.AppID},
+	}, []string{"name"})
+	prometheus.MustRegister(DBWaitDurationSeconds)
+
 	DBMaxIdleClosed = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace:   "sniper",
 		Name:        "db_max_idle_closed",
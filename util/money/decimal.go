@@ -0,0 +1,254 @@
+// Package money 定点小数和金额
+//
+// 金额计算不能使用 float64，0.1 + 0.2 != 0.3。Decimal 使用整数系数和小数位数表示，
+// 加减乘法都是精确的，只有除法和 Round 会按照四舍五入舍弃精度。
+// Money 在 Decimal 的基础上增加币种，不同币种之间不能计算。
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrDivisionByZero 除数为零
+var ErrDivisionByZero = errors.New("money: division by zero")
+
+// Decimal 定点小数，值为 coef / 10^scale，零值表示 0
+//
+// Decimal 是不可变的，所有运算都返回新的值。
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+// New 返回 coef / 10^scale，如 New(1230, 2) 为 12.30
+func New(coef int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(coef), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(coef), scale: scale}
+}
+
+// NewFromInt 返回整数 i
+func NewFromInt(i int64) Decimal {
+	return New(i, 0)
+}
+
+// Parse 解析十进制字符串，如 "-12.30"，不支持科学计数法
+//
+// 小数位数保持不变，Parse("1.50").String() 为 "1.50"。
+func Parse(s string) (Decimal, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	var scale int32
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = int32(len(digits) - i - 1)
+		digits = digits[:i] + digits[i+1:]
+	}
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("money: invalid decimal %q", s)
+	}
+
+	coef, _ := new(big.Int).SetString(digits, 10)
+	if strings.HasPrefix(s, "-") {
+		coef.Neg(coef)
+	}
+	return Decimal{coef: coef, scale: scale}, nil
+}
+
+// MustParse 与 Parse 相同，解析失败时 panic，用于常量
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) value() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// Scale 返回小数位数
+func (d Decimal) Scale() int32 { return d.scale }
+
+// rescale 返回小数位数为 scale 的系数，scale 必须不小于 d.scale
+func (d Decimal) rescale(scale int32) *big.Int {
+	c := d.value()
+	if scale == d.scale {
+		return c
+	}
+	return new(big.Int).Mul(c, pow10(scale-d.scale))
+}
+
+func align(a, b Decimal) (x, y *big.Int, scale int32) {
+	scale = a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.rescale(scale), b.rescale(scale), scale
+}
+
+// Add 返回 d + o
+func (d Decimal) Add(o Decimal) Decimal {
+	x, y, scale := align(d, o)
+	return Decimal{coef: new(big.Int).Add(x, y), scale: scale}
+}
+
+// Sub 返回 d - o
+func (d Decimal) Sub(o Decimal) Decimal {
+	x, y, scale := align(d, o)
+	return Decimal{coef: new(big.Int).Sub(x, y), scale: scale}
+}
+
+// Mul 返回 d * o，小数位数为两者之和
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.value(), o.value()), scale: d.scale + o.scale}
+}
+
+// Div 返回 d / o，结果四舍五入保留 scale 位小数
+func (d Decimal) Div(o Decimal, scale int32) (Decimal, error) {
+	if o.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+	// d / o = (dc * 10^(scale + os - ds + 1)) / oc / 10^(scale+1)，多算一位用于四舍五入
+	num := new(big.Int).Set(d.value())
+	den := new(big.Int).Set(o.value())
+	if e := scale + o.scale - d.scale + 1; e >= 0 {
+		num.Mul(num, pow10(e))
+	} else {
+		den.Mul(den, pow10(-e))
+	}
+	q := Decimal{coef: num.Quo(num, den), scale: scale + 1}
+	return q.Round(scale), nil
+}
+
+// Neg 返回 -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.value()), scale: d.scale}
+}
+
+// Abs 返回 d 的绝对值
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.value()), scale: d.scale}
+}
+
+// Sign d 小于零、等于零、大于零时分别返回 -1、0、1
+func (d Decimal) Sign() int { return d.value().Sign() }
+
+// IsZero 判断 d 是否为零
+func (d Decimal) IsZero() bool { return d.Sign() == 0 }
+
+// Cmp d 小于、等于、大于 o 时分别返回 -1、0、1，忽略小数位数的差异
+func (d Decimal) Cmp(o Decimal) int {
+	x, y, _ := align(d, o)
+	return x.Cmp(y)
+}
+
+// Equal 判断数值是否相等，1.5 与 1.50 相等
+func (d Decimal) Equal(o Decimal) bool { return d.Cmp(o) == 0 }
+
+// Round 四舍五入（远离零）保留 scale 位小数，scale 大于当前小数位数时补零
+func (d Decimal) Round(scale int32) Decimal {
+	if scale >= d.scale {
+		return Decimal{coef: d.rescale(scale), scale: scale}
+	}
+
+	unit := pow10(d.scale - scale)
+	q, r := new(big.Int).QuoRem(d.value(), unit, new(big.Int))
+	// |r| * 2 >= unit 时进位
+	if r.Abs(r).Lsh(r, 1).Cmp(unit) >= 0 {
+		if d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Decimal{coef: q, scale: scale}
+}
+
+// Units 返回保留 scale 位小数时的整数系数，如 12.30 在 scale 为 2 时为 1230
+//
+// 需要舍弃精度或者超出 int64 范围时 ok 为 false。
+func (d Decimal) Units(scale int32) (units int64, ok bool) {
+	r := d.Round(scale)
+	if r.Cmp(d) != 0 || !r.coef.IsInt64() {
+		return 0, false
+	}
+	return r.coef.Int64(), true
+}
+
+// String 返回十进制字符串，保留所有小数位，如 "-12.30"
+func (d Decimal) String() string {
+	c := d.value()
+	s := new(big.Int).Abs(c).String()
+	if d.scale > 0 {
+		if n := int(d.scale) + 1 - len(s); n > 0 {
+			s = strings.Repeat("0", n) + s
+		}
+		s = s[:len(s)-int(d.scale)] + "." + s[len(s)-int(d.scale):]
+	}
+	if c.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalJSON 编码为字符串，避免调用方按 float64 解析丢失精度
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON 支持字符串和数字
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Scan 实现 sql.Scanner，支持 DECIMAL（[]byte、string）和整数列
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case int64:
+		*d = NewFromInt(v)
+		return nil
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	}
+	return fmt.Errorf("money: cannot scan %T into Decimal", src)
+}
+
+func (d *Decimal) scanString(s string) (err error) {
+	*d, err = Parse(s)
+	return
+}
+
+// Value 实现 driver.Valuer，写入 DECIMAL 列
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
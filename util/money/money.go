@@ -0,0 +1,199 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrCurrencyMismatch 不同币种之间计算
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	// ErrUnknownCurrency 币种不在白名单中
+	ErrUnknownCurrency = errors.New("money: unknown currency")
+	// ErrScale 金额的小数位数超过币种的最小单位
+	ErrScale = errors.New("money: too many decimal places")
+)
+
+var (
+	currenciesMu sync.RWMutex
+	// currencies ISO 4217 币种代码到最小单位小数位数的映射
+	currencies = map[string]int32{
+		"CNY": 2,
+		"HKD": 2,
+		"MOP": 2,
+		"TWD": 2,
+		"USD": 2,
+		"EUR": 2,
+		"GBP": 2,
+		"SGD": 2,
+		"AUD": 2,
+		"CAD": 2,
+		"CHF": 2,
+		"THB": 2,
+		"JPY": 0,
+		"KRW": 0,
+		"VND": 0,
+	}
+)
+
+// RegisterCurrency 注册币种及其最小单位的小数位数，如 RegisterCurrency("KWD", 3)
+func RegisterCurrency(code string, exponent int32) {
+	currenciesMu.Lock()
+	defer currenciesMu.Unlock()
+
+	currencies[code] = exponent
+}
+
+// Exponent 返回币种最小单位的小数位数，币种不存在时 ok 为 false
+func Exponent(code string) (exponent int32, ok bool) {
+	currenciesMu.RLock()
+	defer currenciesMu.RUnlock()
+
+	exponent, ok = currencies[code]
+	return
+}
+
+// Money 金额
+type Money struct {
+	// Currency ISO 4217 币种代码，如 CNY
+	Currency string `json:"currency"`
+	// Amount 金额，小数位数不超过币种的最小单位，但可以更少
+	Amount Decimal `json:"amount"`
+}
+
+// NewMoney 创建金额，检查币种是否在白名单中以及小数位数是否超过最小单位
+func NewMoney(currency string, amount Decimal) (Money, error) {
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+	if _, ok := amount.Units(exp); !ok {
+		return Money{}, ErrScale
+	}
+	return Money{Currency: currency, Amount: amount}, nil
+}
+
+// FromMinor 使用最小单位创建金额，如 FromMinor("CNY", 1230) 为 12.30 元
+func FromMinor(currency string, minor int64) (Money, error) {
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+	return Money{Currency: currency, Amount: New(minor, exp)}, nil
+}
+
+// Minor 返回最小单位的金额，用于存储和调用支付接口，超出 int64 范围时 ok 为 false
+func (m Money) Minor() (minor int64, ok bool) {
+	exp, known := Exponent(m.Currency)
+	if !known {
+		return 0, false
+	}
+	return m.Amount.Units(exp)
+}
+
+// Add 返回 m + o，币种不同时返回 ErrCurrencyMismatch
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Currency: m.Currency, Amount: m.Amount.Add(o.Amount)}, nil
+}
+
+// Sub 返回 m - o，币种不同时返回 ErrCurrencyMismatch
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Currency: m.Currency, Amount: m.Amount.Sub(o.Amount)}, nil
+}
+
+// Mul 返回 m * factor，四舍五入到币种的最小单位，如计算折扣和税费
+func (m Money) Mul(factor Decimal) (Money, error) {
+	exp, ok := Exponent(m.Currency)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+	return Money{Currency: m.Currency, Amount: m.Amount.Mul(factor).Round(exp)}, nil
+}
+
+// Split 将金额平均分成 n 份，余下的最小单位依次分给前面几份，各份之和等于 m
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, ErrDivisionByZero
+	}
+	minor, ok := m.Minor()
+	if !ok {
+		return nil, ErrScale
+	}
+	exp, _ := Exponent(m.Currency)
+
+	parts := make([]Money, n)
+	each, rest := minor/int64(n), minor%int64(n)
+	for i := range parts {
+		v := each
+		if int64(i) < rest {
+			v++
+		} else if int64(i) < -rest {
+			v--
+		}
+		parts[i] = Money{Currency: m.Currency, Amount: New(v, exp)}
+	}
+	return parts, nil
+}
+
+// String 返回金额和币种，如 "12.30 CNY"
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
+
+// UnmarshalJSON 解析 {"currency": "CNY", "amount": "12.30"}，并检查币种和小数位数
+func (m *Money) UnmarshalJSON(b []byte) error {
+	type plain Money
+	var v plain
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	parsed, err := NewMoney(v.Currency, v.Amount)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// nanosPerUnit proto 中 nanos 字段的单位
+const nanosPerUnit = 1000000000
+
+// FromProto 从推荐的 proto 表示（与 google.type.Money 相同）转换：
+//
+//	message Money {
+//	  string currency_code = 1;
+//	  int64 units = 2;
+//	  int32 nanos = 3;
+//	}
+//
+// units 和 nanos 的符号必须一致，nanos 的绝对值小于 10^9。
+func FromProto(currency string, units int64, nanos int32) (Money, error) {
+	if nanos <= -nanosPerUnit || nanos >= nanosPerUnit || (units > 0 && nanos < 0) || (units < 0 && nanos > 0) {
+		return Money{}, fmt.Errorf("money: invalid units %d and nanos %d", units, nanos)
+	}
+	amount := NewFromInt(units).Add(New(int64(nanos), 9))
+	// 去掉 nanos 多余的零，保持与币种一致的小数位数
+	if exp, ok := Exponent(currency); ok {
+		if _, exact := amount.Units(exp); exact {
+			amount = amount.Round(exp)
+		}
+	}
+	return NewMoney(currency, amount)
+}
+
+// Proto 返回推荐的 proto 表示，参考 FromProto
+func (m Money) Proto() (currency string, units int64, nanos int32, err error) {
+	total, ok := m.Amount.Units(9)
+	if !ok {
+		return "", 0, 0, ErrScale
+	}
+	return m.Currency, total / nanosPerUnit, int32(total % nanosPerUnit), nil
+}
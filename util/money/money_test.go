@@ -0,0 +1,162 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"0":       "0",
+		"12.30":   "12.30",
+		"-0.05":   "-0.05",
+		"+1.5":    "1.5",
+		".5":      "0.5",
+		"100":     "100",
+		"-100.00": "-100.00",
+	}
+	for in, want := range cases {
+		d, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if got := d.String(); got != want {
+			t.Errorf("Parse(%q) = %s, want %s", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "-", "1.2.3", "abc", "1e3"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) should fail", in)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := MustParse("0.1"), MustParse("0.2")
+	if got := a.Add(b); !got.Equal(MustParse("0.3")) {
+		t.Errorf("0.1 + 0.2 = %s", got)
+	}
+	if got := a.Sub(b).String(); got != "-0.1" {
+		t.Errorf("0.1 - 0.2 = %s", got)
+	}
+	if got := MustParse("1.25").Mul(MustParse("0.5")).String(); got != "0.625" {
+		t.Errorf("1.25 * 0.5 = %s", got)
+	}
+
+	var zero Decimal
+	if !zero.IsZero() || zero.String() != "0" || zero.Add(a).String() != "0.1" {
+		t.Errorf("zero value should be 0")
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		in    string
+		scale int32
+		want  string
+	}{
+		{"1.005", 2, "1.01"},
+		{"1.004", 2, "1.00"},
+		{"-1.005", 2, "-1.01"},
+		{"2.5", 0, "3"},
+		{"1.2", 3, "1.200"},
+	}
+	for _, c := range cases {
+		if got := MustParse(c.in).Round(c.scale).String(); got != c.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", c.in, c.scale, got, c.want)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	got, err := MustParse("10").Div(MustParse("3"), 2)
+	if err != nil || got.String() != "3.33" {
+		t.Errorf("10 / 3 = %s, %v", got, err)
+	}
+	got, err = MustParse("0.002").Div(MustParse("0.3"), 2)
+	if err != nil || got.String() != "0.01" {
+		t.Errorf("0.002 / 0.3 = %s, %v", got, err)
+	}
+	if _, err := MustParse("1").Div(Decimal{}, 2); err != ErrDivisionByZero {
+		t.Errorf("division by zero: %v", err)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	if _, err := NewMoney("XXX", MustParse("1")); err != ErrUnknownCurrency {
+		t.Errorf("unknown currency: %v", err)
+	}
+	if _, err := NewMoney("JPY", MustParse("1.5")); err != ErrScale {
+		t.Errorf("JPY scale: %v", err)
+	}
+
+	price, _ := FromMinor("CNY", 1999)
+	if price.String() != "19.99 CNY" {
+		t.Errorf("price = %s", price)
+	}
+
+	usd, _ := FromMinor("USD", 100)
+	if _, err := price.Add(usd); err != ErrCurrencyMismatch {
+		t.Errorf("currency mismatch: %v", err)
+	}
+
+	discounted, _ := price.Mul(MustParse("0.85"))
+	if minor, _ := discounted.Minor(); minor != 1699 {
+		t.Errorf("discounted = %s", discounted)
+	}
+
+	parts, _ := price.Split(3)
+	var sum int64
+	for _, p := range parts {
+		minor, _ := p.Minor()
+		sum += minor
+	}
+	if sum != 1999 || parts[0].String() != "6.67 CNY" || parts[2].String() != "6.66 CNY" {
+		t.Errorf("split = %v", parts)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	m, _ := FromMinor("CNY", 1230)
+	b, err := json.Marshal(m)
+	if err != nil || string(b) != `{"currency":"CNY","amount":"12.30"}` {
+		t.Fatalf("marshal = %s, %v", b, err)
+	}
+
+	var got Money
+	if err := json.Unmarshal([]byte(`{"currency":"CNY","amount":12.3}`), &got); err != nil || got.String() != "12.3 CNY" {
+		t.Errorf("unmarshal = %s, %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(`{"currency":"CNY","amount":"12.345"}`), &got); err != ErrScale {
+		t.Errorf("unmarshal scale: %v", err)
+	}
+}
+
+func TestProto(t *testing.T) {
+	m, err := FromProto("CNY", -12, -300000000)
+	if err != nil || m.String() != "-12.30 CNY" {
+		t.Fatalf("FromProto = %s, %v", m, err)
+	}
+	currency, units, nanos, err := m.Proto()
+	if err != nil || currency != "CNY" || units != -12 || nanos != -300000000 {
+		t.Errorf("Proto = %s %d %d, %v", currency, units, nanos, err)
+	}
+
+	if _, err := FromProto("CNY", 1, -1); err == nil {
+		t.Errorf("sign mismatch should fail")
+	}
+	if _, err := FromProto("CNY", 1, 1000); err != ErrScale {
+		t.Errorf("scale: %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	var d Decimal
+	if err := d.Scan([]byte("99.90")); err != nil || d.String() != "99.90" {
+		t.Errorf("scan = %s, %v", d, err)
+	}
+	if v, err := d.Value(); err != nil || v != "99.90" {
+		t.Errorf("value = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,35 @@
+package mq
+
+import (
+	"context"
+
+	"sniper/util/trace"
+)
+
+// InjectBaggage 把 ctx 中允许传递的 baggage 写入消息头，发送消息之前调用
+//
+// 消息头中已经有 baggage 时不覆盖，LocalBus 发送消息时会自动调用。
+func InjectBaggage(ctx context.Context, msg *Message) {
+	if _, ok := msg.Header[trace.BaggageHeader]; ok {
+		return
+	}
+	b := trace.EncodeBaggage(ctx)
+	if b == "" {
+		return
+	}
+	if msg.Header == nil {
+		msg.Header = map[string]string{}
+	}
+	msg.Header[trace.BaggageHeader] = b
+}
+
+// Baggage 返回解析消息头中 baggage 的 Middleware，Handler 中可以通过 trace.GetBaggage 获取
+//
+// 异步处理的消息和发送消息的请求使用相同的用户、租户和实验分桶等信息输出日志和监控。
+func Baggage() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			return next(trace.ExtractBaggage(ctx, msg.Header[trace.BaggageHeader]), msg)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package mq
+
+import (
+	"context"
+	"testing"
+
+	"sniper/util/trace"
+)
+
+func TestBaggage(t *testing.T) {
+	bus := NewLocalBus()
+	defer bus.Close()
+
+	var got, header string
+	_ = bus.Subscribe("order", Chain(func(ctx context.Context, msg *Message) error {
+		got = trace.GetBaggage(ctx, "tenant_id")
+		header = msg.Header[trace.BaggageHeader]
+		return nil
+	}, Baggage()))
+
+	// 消费者使用独立的 ctx，只能通过消息头获取 baggage
+	h := Baggage()(func(ctx context.Context, msg *Message) error {
+		got = trace.GetBaggage(ctx, "tenant_id")
+		return nil
+	})
+	_ = h(context.Background(), &Message{Header: map[string]string{trace.BaggageHeader: "tenant_id=t2"}})
+	if got != "t2" {
+		t.Errorf("baggage should be extracted from header, got %q", got)
+	}
+
+	msg := &Message{Topic: "order"}
+	ctx := trace.WithBaggage(context.Background(), "tenant_id", "t1")
+	if err := bus.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	bus.Wait()
+
+	if got != "t1" || header != "tenant_id=t1" {
+		t.Errorf("unexpected baggage %q, header %q", got, header)
+	}
+	if msg.Header != nil {
+		t.Error("Publish should not modify the message")
+	}
+}
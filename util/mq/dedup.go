@@ -0,0 +1,172 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"sniper/util/log"
+	"sniper/util/metrics"
+)
+
+// ErrInProgress 相同的消息正在被其他消费者处理，返回该错误让消息队列稍后重新投递
+var ErrInProgress = errors.New("mq: message is being processed")
+
+// DedupState 消息的处理状态
+type DedupState int
+
+const (
+	// DedupNew 消息没有处理过，已经标记为处理中
+	DedupNew DedupState = iota
+	// DedupProcessing 消息正在处理，租约还没有过期
+	DedupProcessing
+	// DedupDone 消息已经处理完成
+	DedupDone
+)
+
+// DedupStore 记录已处理的消息，由业务基于 redis、数据库等共享存储实现，
+// 单机部署和测试可以使用 NewMemoryDedupStore
+//
+// redis 可以使用 SET key processing NX PX lease 实现 Acquire，写入失败时 GET 判断状态，
+// Complete 使用 SET key done PX ttl，Release 使用 DEL。
+// 数据库可以使用以 key 为主键的表，INSERT 成功表示 DedupNew，
+// 主键冲突时查询状态，处理中且租约已经过期的记录可以通过 UPDATE ... WHERE expire_at < now 接管。
+// 消息处理和 Complete 写到同一个数据库事务中时才能严格保证只处理一次。
+type DedupStore interface {
+	// Acquire 查询 key 的状态，没有记录或者处理中的租约已经过期时标记为处理中，lease 后过期
+	Acquire(ctx context.Context, key string, lease time.Duration) (DedupState, error)
+	// Complete 标记 key 处理完成，ttl 后过期
+	Complete(ctx context.Context, key string, ttl time.Duration) error
+	// Release 删除处理中的标记，处理失败时调用，重新投递时可以再次处理
+	Release(ctx context.Context, key string) error
+}
+
+// DedupOptions 消息去重参数，零值字段使用默认值
+type DedupOptions struct {
+	// Lease 处理中状态的租约，超过该时间没有完成认为消费者已经退出，默认 5 分钟
+	Lease time.Duration
+	// TTL 处理完成的记录保留的时间，应该大于消息队列最长的重新投递时间，默认 7 天
+	TTL time.Duration
+	// Key 返回去重使用的 key，默认为 topic:id
+	Key func(msg *Message) string
+}
+
+// Dedup 返回消息去重的 Middleware，重新投递的消息不会被再次处理
+//
+// 处理成功之后记录消息已完成，之后收到相同的消息直接返回 nil；
+// 处理失败时删除处理中的标记，重新投递时再次处理。
+// 相同的消息正在被其他消费者处理时返回 ErrInProgress。
+// ID 为空的消息无法去重，直接处理。存储出错时返回错误，消息会被重新投递。
+func Dedup(store DedupStore, opts DedupOptions) Middleware {
+	if opts.Lease <= 0 {
+		opts.Lease = 5 * time.Minute
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 7 * 24 * time.Hour
+	}
+	if opts.Key == nil {
+		opts.Key = func(msg *Message) string { return msg.Topic + ":" + msg.ID }
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			if msg.ID == "" {
+				return next(ctx, msg)
+			}
+
+			key := opts.Key(msg)
+			state, err := store.Acquire(ctx, key, opts.Lease)
+			if err != nil {
+				return err
+			}
+
+			switch state {
+			case DedupDone:
+				metrics.MQDedupTotal.WithLabelValues(msg.Topic, "duplicate").Inc()
+				log.Get(ctx).WithField("key", key).Info("skip duplicate message")
+				return nil
+			case DedupProcessing:
+				metrics.MQDedupTotal.WithLabelValues(msg.Topic, "in_progress").Inc()
+				return ErrInProgress
+			}
+			metrics.MQDedupTotal.WithLabelValues(msg.Topic, "new").Inc()
+
+			// 处理 panic 时也要释放标记，否则需要等租约过期才能重新处理
+			completed := false
+			defer func() {
+				if !completed {
+					if err := store.Release(ctx, key); err != nil {
+						log.Get(ctx).WithField("key", key).Errorf("release message error: %v", err)
+					}
+				}
+			}()
+
+			if err := next(ctx, msg); err != nil {
+				return err
+			}
+
+			// 已经处理成功，Complete 失败时保留处理中的标记，租约过期之前不会重复处理
+			completed = true
+			return store.Complete(ctx, key, opts.TTL)
+		}
+	}
+}
+
+// NewMemoryDedupStore 返回进程内的 DedupStore
+//
+// 记录保存在内存中，进程重启后丢失，多实例部署时需要使用 redis 等共享存储。
+func NewMemoryDedupStore() DedupStore {
+	return &memoryDedupStore{items: map[string]memoryDedupItem{}}
+}
+
+type memoryDedupItem struct {
+	state    DedupState
+	deadline time.Time
+}
+
+type memoryDedupStore struct {
+	mu    sync.Mutex
+	items map[string]memoryDedupItem
+	// cleaned 上次清理过期记录的时间，每分钟最多清理一次
+	cleaned time.Time
+}
+
+func (s *memoryDedupStore) Acquire(ctx context.Context, key string, lease time.Duration) (DedupState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if item, ok := s.items[key]; ok && now.Before(item.deadline) {
+		return item.state, nil
+	}
+	s.items[key] = memoryDedupItem{state: DedupProcessing, deadline: now.Add(lease)}
+	return DedupNew, nil
+}
+
+func (s *memoryDedupStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.cleaned) > time.Minute {
+		s.cleaned = now
+		for k, item := range s.items {
+			if now.After(item.deadline) {
+				delete(s.items, k)
+			}
+		}
+	}
+	s.items[key] = memoryDedupItem{state: DedupDone, deadline: now.Add(ttl)}
+	return nil
+}
+
+func (s *memoryDedupStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item, ok := s.items[key]; ok && item.state == DedupProcessing {
+		delete(s.items, key)
+	}
+	return nil
+}
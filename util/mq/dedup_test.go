@@ -0,0 +1,78 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDedupStore()
+
+	calls := 0
+	fail := true
+	handler := Chain(func(ctx context.Context, msg *Message) error {
+		calls++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}, Dedup(store, DedupOptions{}))
+
+	msg := &Message{ID: "1", Topic: "payment"}
+	if err := handler(ctx, msg); err == nil {
+		t.Fatal("handler error should be returned")
+	}
+
+	// 处理失败的消息重新投递时再次处理
+	fail = false
+	if err := handler(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, redelivered message should be skipped", calls)
+	}
+
+	// 其他 topic 相同 ID 的消息不受影响
+	if err := handler(ctx, &Message{ID: "1", Topic: "refund"}); err != nil || calls != 3 {
+		t.Errorf("err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestDedupInProgress(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDedupStore()
+	if _, err := store.Acquire(ctx, "payment:1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := Dedup(store, DedupOptions{})(func(ctx context.Context, msg *Message) error {
+		t.Error("message in progress should not be processed")
+		return nil
+	})
+	if err := handler(ctx, &Message{ID: "1", Topic: "payment"}); err != ErrInProgress {
+		t.Errorf("err = %v, want ErrInProgress", err)
+	}
+}
+
+func TestDedupPanic(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDedupStore()
+	handler := Dedup(store, DedupOptions{})(func(ctx context.Context, msg *Message) error {
+		panic("boom")
+	})
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = handler(ctx, &Message{ID: "1", Topic: "payment"})
+	}()
+
+	if state, _ := store.Acquire(ctx, "payment:1", time.Minute); state != DedupNew {
+		t.Errorf("state = %v, mark should be released after panic", state)
+	}
+}
@@ -0,0 +1,202 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"sniper/util/ctxkit"
+	"sniper/util/log"
+)
+
+// ErrBusClosed LocalBus 已经关闭
+var ErrBusClosed = errors.New("mq: bus is closed")
+
+// LocalBus 进程内的消息总线，实现了 Publisher 和 Subscriber
+//
+// 每个订阅使用一个 goroutine 按照发送顺序处理消息，Handler 返回错误时立即重新投递，
+// 最多重新投递 MaxRedeliveries 次，之后丢弃并输出错误日志。
+// 消息只保存在内存中，进程退出时未处理的消息会丢失，只适合本地开发、单体部署和测试。
+type LocalBus struct {
+	// MaxRedeliveries 处理失败时最多重新投递的次数，需要在 Subscribe 之前设置
+	MaxRedeliveries int
+
+	mu     sync.RWMutex
+	closed bool
+	subs   map[string][]*localSubscription
+
+	seq     int64
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+type localSubscription struct {
+	handler Handler
+	queue   chan delivery
+}
+
+type delivery struct {
+	ctx context.Context
+	msg *Message
+}
+
+// NewLocalBus 创建进程内的消息总线，重新投递次数默认为 3
+func NewLocalBus() *LocalBus {
+	return &LocalBus{MaxRedeliveries: 3, subs: map[string][]*localSubscription{}}
+}
+
+// Subscribe 订阅 topic，同一个 topic 的多个订阅都会收到所有消息
+func (b *LocalBus) Subscribe(topic string, h Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	sub := &localSubscription{handler: h, queue: make(chan delivery, 1024)}
+	b.subs[topic] = append(b.subs[topic], sub)
+	b.workers.Add(1)
+	go b.consume(sub)
+	return nil
+}
+
+// Publish 发送消息，消息异步投递给订阅了 msg.Topic 的所有 Handler
+//
+// ID 为空时自动生成，ctx 中的 baggage 写入消息头。Handler 收到的 ctx 保留了 ctx 中的值，但不继承 deadline 和取消信号。
+// 订阅的队列已满时阻塞。
+func (b *LocalBus) Publish(ctx context.Context, msg *Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	m := copyMessage(msg)
+	if m.ID == "" {
+		m.ID = "local-" + strconv.FormatInt(atomic.AddInt64(&b.seq, 1), 10)
+	}
+	InjectBaggage(ctx, m)
+
+	for _, sub := range b.subs[m.Topic] {
+		b.pending.Add(1)
+		sub.queue <- delivery{ctx: ctxkit.Detach(ctx), msg: copyMessage(m)}
+	}
+	return nil
+}
+
+// Wait 等待已经发送的消息全部处理完成，包括 Handler 中发送的消息，测试中使用
+func (b *LocalBus) Wait() {
+	b.pending.Wait()
+}
+
+// Close 停止接收消息，等待已经发送的消息处理完成
+func (b *LocalBus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	for _, subs := range b.subs {
+		for _, sub := range subs {
+			close(sub.queue)
+		}
+	}
+	b.mu.Unlock()
+
+	b.workers.Wait()
+	return nil
+}
+
+func (b *LocalBus) consume(sub *localSubscription) {
+	defer b.workers.Done()
+
+	for d := range sub.queue {
+		b.deliver(sub.handler, d)
+		b.pending.Done()
+	}
+}
+
+func (b *LocalBus) deliver(h Handler, d delivery) {
+	var err error
+	for i := 0; i <= b.MaxRedeliveries; i++ {
+		if err = b.handle(h, d); err == nil {
+			return
+		}
+	}
+	log.Get(d.ctx).WithField("topic", d.msg.Topic).WithField("id", d.msg.ID).
+		Errorf("[mq] drop message after %d redeliveries: %v", b.MaxRedeliveries, err)
+}
+
+// handle 调用 h，panic 时按处理失败计算
+func (b *LocalBus) handle(h Handler, d delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("mq: handler panic")
+			log.Get(d.ctx).Error("[mq] ", d.msg.Topic, " handler panic: ", r)
+		}
+	}()
+	return h(d.ctx, d.msg)
+}
+
+// copyMessage 每个订阅收到独立的消息，Handler 修改消息不会影响其他订阅
+func copyMessage(msg *Message) *Message {
+	m := *msg
+	m.Body = append([]byte(nil), msg.Body...)
+	if msg.Header != nil {
+		m.Header = make(map[string]string, len(msg.Header))
+		for k, v := range msg.Header {
+			m.Header[k] = v
+		}
+	}
+	return &m
+}
+
+// Recorder 记录处理成功的消息，测试中断言消费结果使用
+//
+//	rec := mq.NewRecorder()
+//	_ = bus.Subscribe("payment", mq.Chain(handlePayment, rec.Middleware()))
+//	bus.Wait()
+//	msgs := rec.Consumed("payment")
+type Recorder struct {
+	mu       sync.Mutex
+	consumed map[string][]*Message
+}
+
+// NewRecorder 创建 Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{consumed: map[string][]*Message{}}
+}
+
+// Middleware 返回记录消息的 Middleware，只记录 Handler 返回 nil 的消息
+func (r *Recorder) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			if err := next(ctx, msg); err != nil {
+				return err
+			}
+			r.mu.Lock()
+			r.consumed[msg.Topic] = append(r.consumed[msg.Topic], msg)
+			r.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// Consumed 返回 topic 中处理成功的消息，按照处理顺序排列
+func (r *Recorder) Consumed(topic string) []*Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Message(nil), r.consumed[topic]...)
+}
+
+// Reset 清空记录
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.consumed = map[string][]*Message{}
+}
@@ -0,0 +1,73 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLocalBus(t *testing.T) {
+	ctx := context.Background()
+	bus := NewLocalBus()
+	defer bus.Close()
+
+	rec := NewRecorder()
+	if err := bus.Subscribe("order", Chain(func(ctx context.Context, msg *Message) error {
+		return bus.Publish(ctx, &Message{Topic: "payment", Body: msg.Body})
+	}, rec.Middleware())); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := 0
+	_ = bus.Subscribe("payment", Chain(func(ctx context.Context, msg *Message) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}, rec.Middleware()))
+
+	for _, body := range []string{"1", "2"} {
+		if err := bus.Publish(ctx, &Message{Topic: "order", Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bus.Wait()
+
+	orders := rec.Consumed("order")
+	if len(orders) != 2 || string(orders[0].Body) != "1" || string(orders[1].Body) != "2" {
+		t.Errorf("orders = %v, want consumed in order", orders)
+	}
+	if orders[0].ID == "" || orders[0].ID == orders[1].ID {
+		t.Errorf("ids = %s, %s, want generated unique ids", orders[0].ID, orders[1].ID)
+	}
+	if payments := rec.Consumed("payment"); len(payments) != 2 || attempts != 3 {
+		t.Errorf("payments = %d, attempts = %d, failed message should be redelivered", len(payments), attempts)
+	}
+}
+
+func TestLocalBusDedup(t *testing.T) {
+	ctx := context.Background()
+	bus := NewLocalBus()
+
+	rec := NewRecorder()
+	calls := 0
+	_ = bus.Subscribe("payment", Chain(func(ctx context.Context, msg *Message) error {
+		calls++
+		return nil
+	}, Dedup(NewMemoryDedupStore(), DedupOptions{}), rec.Middleware()))
+
+	for i := 0; i < 3; i++ {
+		_ = bus.Publish(ctx, &Message{ID: "1", Topic: "payment"})
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 || len(rec.Consumed("payment")) != 1 {
+		t.Errorf("calls = %d, duplicate messages should be skipped", calls)
+	}
+	if err := bus.Publish(ctx, &Message{Topic: "payment"}); err != ErrBusClosed {
+		t.Errorf("err = %v, want ErrBusClosed", err)
+	}
+}
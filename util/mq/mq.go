@@ -0,0 +1,50 @@
+// Package mq 消息队列消费工具
+//
+// 本包不依赖具体的消息队列，业务把收到的消息转换为 Message 之后交给 Handler 处理，
+// 通用的处理逻辑（去重、日志等）以 Middleware 的形式组合：
+//
+//	handler := mq.Chain(handlePayment, mq.Dedup(store, mq.DedupOptions{}))
+//	err := handler(ctx, &mq.Message{ID: id, Topic: "payment", Body: body})
+//
+// Handler 返回错误时应该让消息队列重新投递，返回 nil 时确认消息。
+//
+// 发送和订阅通过 Publisher 和 Subscriber 接口，业务基于具体的消息队列实现；
+// 本地开发、单体部署和测试可以使用进程内的 LocalBus，不需要启动消息队列。
+package mq
+
+import "context"
+
+// Message 收到的消息
+type Message struct {
+	// ID 消息的唯一标识，重新投递时保持不变，一般为生产者写入的业务 ID
+	ID    string
+	Topic string
+	// Key 分区使用的 key
+	Key    string
+	Body   []byte
+	Header map[string]string
+}
+
+// Handler 处理消息，返回错误时消息会被重新投递
+type Handler func(ctx context.Context, msg *Message) error
+
+// Publisher 发送消息
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// Subscriber 订阅 topic 的消息，收到的消息交给 h 处理
+type Subscriber interface {
+	Subscribe(topic string, h Handler) error
+}
+
+// Middleware 包装 Handler，实现通用的处理逻辑
+type Middleware func(Handler) Handler
+
+// Chain 使用 mws 包装 h，第一个 Middleware 在最外层
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
@@ -0,0 +1,62 @@
+package mq
+
+import (
+	"context"
+
+	"sniper/util/twirp"
+)
+
+// PriorityPublisher 按照 ctx 中的请求优先级发送消息，由 NewPriorityPublisher 创建
+type PriorityPublisher struct {
+	pub   Publisher
+	slots chan struct{}
+}
+
+// NewPriorityPublisher 包装 pub，发送消息时区分请求优先级
+//
+// 优先级写入消息头 twirp.PriorityHeader，消费端通过 Priority 恢复；优先级为 batch 的消息
+// 最多同时发送 batchLimit 条，超过时排队等待，批处理任务不会占满生产者的连接和缓冲区，
+// 在线请求的消息不受影响。batchLimit 小于等于 0 时只传递优先级，不限制并发。
+func NewPriorityPublisher(pub Publisher, batchLimit int) *PriorityPublisher {
+	p := &PriorityPublisher{pub: pub}
+	if batchLimit > 0 {
+		p.slots = make(chan struct{}, batchLimit)
+	}
+	return p
+}
+
+// Publish 发送消息，batch 消息排队时 ctx 取消返回 ctx.Err()
+func (p *PriorityPublisher) Publish(ctx context.Context, msg *Message) error {
+	priority := twirp.GetPriority(ctx)
+	if priority == twirp.PriorityBatch && p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+			defer func() { <-p.slots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if _, ok := msg.Header[twirp.PriorityHeader]; !ok {
+		msg = copyMessage(msg)
+		if msg.Header == nil {
+			msg.Header = map[string]string{}
+		}
+		msg.Header[twirp.PriorityHeader] = priority.String()
+	}
+	return p.pub.Publish(ctx, msg)
+}
+
+// Priority 返回把消息头中的优先级恢复到 ctx 中的 Middleware，Handler 中可以通过 twirp.GetPriority 获取
+//
+// Handler 中发起的 twirp 调用和发送的消息会继续使用该优先级。
+func Priority() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			if p, ok := twirp.ParsePriority(msg.Header[twirp.PriorityHeader]); ok {
+				ctx = twirp.WithPriority(ctx, p)
+			}
+			return next(ctx, msg)
+		}
+	}
+}
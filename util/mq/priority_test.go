@@ -0,0 +1,63 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sniper/util/twirp"
+)
+
+// blockingPublisher 阻塞到 release 关闭，记录收到的消息
+type blockingPublisher struct {
+	release chan struct{}
+	msgs    chan *Message
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, msg *Message) error {
+	p.msgs <- msg
+	<-p.release
+	return nil
+}
+
+func TestPriorityPublisher(t *testing.T) {
+	pub := &blockingPublisher{release: make(chan struct{}), msgs: make(chan *Message, 10)}
+	p := NewPriorityPublisher(pub, 1)
+
+	batch := twirp.WithPriority(context.Background(), twirp.PriorityBatch)
+	go p.Publish(batch, &Message{Topic: "report"})
+	if msg := <-pub.msgs; msg.Header[twirp.PriorityHeader] != "batch" {
+		t.Errorf("priority should be written to header, got %v", msg.Header)
+	}
+
+	// 第二条 batch 消息排队等待
+	ctx, cancel := context.WithTimeout(batch, 20*time.Millisecond)
+	defer cancel()
+	if err := p.Publish(ctx, &Message{Topic: "report"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second batch message should wait, got %v", err)
+	}
+
+	// 其他优先级的消息不受限制
+	msg := &Message{Topic: "order"}
+	go p.Publish(context.Background(), msg)
+	if got := <-pub.msgs; got.Header[twirp.PriorityHeader] != "default" {
+		t.Errorf("unexpected header %v", got.Header)
+	}
+	close(pub.release)
+	if msg.Header != nil {
+		t.Error("Publish should not modify the message")
+	}
+}
+
+func TestPriority(t *testing.T) {
+	var got twirp.Priority
+	h := Priority()(func(ctx context.Context, msg *Message) error {
+		got = twirp.GetPriority(ctx)
+		return nil
+	})
+	_ = h(context.Background(), &Message{Header: map[string]string{twirp.PriorityHeader: "batch"}})
+	if got != twirp.PriorityBatch {
+		t.Errorf("priority should be extracted from header, got %v", got)
+	}
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Codec 值的编码格式
+type Codec interface {
+	// Name 编码名称，如 json
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal 解析 b 到 v，v 必须是指针
+	Unmarshal(b []byte, v interface{}) error
+}
+
+var (
+	// JSON 使用 encoding/json 编码，可读性好，适合跟其他语言的服务共享数据
+	JSON Codec = jsonCodec{}
+	// Proto 使用 protobuf 编码，值必须是 proto 消息，体积小、解析快
+	Proto Codec = protoCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string { return "proto" }
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("redis: %T is not a proto message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal v 可以是 *Message，也可以是 **Message，后者为空时自动创建
+func (protoCodec) Unmarshal(b []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(b, m)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return fmt.Errorf("redis: %T is not a pointer to proto message", v)
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+	}
+	m, ok := rv.Elem().Interface().(proto.Message)
+	if !ok {
+		return fmt.Errorf("redis: %T is not a pointer to proto message", v)
+	}
+	return proto.Unmarshal(b, m)
+}
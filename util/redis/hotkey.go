@@ -0,0 +1,133 @@
+//go:build go1.18
+
+package redis
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"sniper/util/cache"
+	"sniper/util/log"
+	"sniper/util/metrics"
+)
+
+// HotKeyOptions 热点 key 检测参数，零值字段使用默认值
+type HotKeyOptions struct {
+	// SampleRate 采样比例，默认 0.1
+	SampleRate float64
+	// Threshold 一个窗口内估算的访问次数达到该值时认为是热点，默认 1000
+	Threshold int
+	// Window 统计窗口，默认 1s
+	Window time.Duration
+	// TTL 热点 key 在进程内缓存的时间，默认 1s
+	TTL time.Duration
+	// Capacity 进程内最多缓存的热点 key 数量，默认 1000
+	Capacity int
+}
+
+func (o *HotKeyOptions) withDefaults() {
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		o.SampleRate = 0.1
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = 1000
+	}
+	if o.Window <= 0 {
+		o.Window = time.Second
+	}
+	if o.TTL <= 0 {
+		o.TTL = time.Second
+	}
+	if o.Capacity <= 0 {
+		o.Capacity = 1000
+	}
+}
+
+// hotKeys 按照采样统计每个窗口内 key 的访问次数
+//
+// 只统计采样到的访问，每个窗口最多跟踪 Capacity*10 个 key，避免大量冷 key 占用内存。
+type hotKeys struct {
+	opts HotKeyOptions
+	// need 采样到的访问次数达到 need 时认为是热点
+	need int
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newHotKeys(opts HotKeyOptions) *hotKeys {
+	need := int(math.Ceil(float64(opts.Threshold) * opts.SampleRate))
+	if need < 1 {
+		need = 1
+	}
+	return &hotKeys{opts: opts, need: need, start: time.Now(), counts: map[string]int{}}
+}
+
+// record 记录一次访问，key 在当前窗口成为热点时返回 true，每个窗口只返回一次
+func (h *hotKeys) record(key string) bool {
+	if h.opts.SampleRate < 1 && rand.Float64() >= h.opts.SampleRate {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if now := time.Now(); now.Sub(h.start) >= h.opts.Window {
+		h.start = now
+		h.counts = map[string]int{}
+	}
+
+	n, ok := h.counts[key]
+	if !ok && len(h.counts) >= h.opts.Capacity*10 {
+		return false
+	}
+	n++
+	h.counts[key] = n
+	return n == h.need
+}
+
+// forget 清除 key 的访问次数，本地副本失效后可以重新成为热点
+func (h *hotKeys) forget(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.counts, key)
+}
+
+// EnableHotKeys 开启热点 key 检测
+//
+// Get 时在客户端采样统计访问次数，访问过于频繁的 key 复制到进程内的 LRU 中，
+// TTL 内直接从进程内返回，流量突增时保护 redis。当前进程 Set 和 Delete 时删除本地副本，
+// 其他实例写入时只能等待本地副本过期，所以 TTL 应该设置得比较短。
+// 需要在并发使用 Typed 之前调用。
+func (t *Typed[T]) EnableHotKeys(opts HotKeyOptions) {
+	opts.withDefaults()
+	t.hot = newHotKeys(opts)
+	t.local = cache.NewLRU[string, T]("redis:"+t.ns.prefix, opts.Capacity, opts.TTL)
+}
+
+func (t *Typed[T]) getLocal(key string) (v T, ok bool) {
+	if t.local == nil {
+		return v, false
+	}
+	return t.local.Get(key)
+}
+
+func (t *Typed[T]) promote(ctx context.Context, key string, v T) {
+	if t.hot == nil || !t.hot.record(key) {
+		return
+	}
+	t.local.Set(key, v)
+	metrics.RedisHotKeyTotal.WithLabelValues(t.ns.prefix).Inc()
+	log.Get(ctx).WithField("key", key).Info("redis hot key is replicated to local cache")
+}
+
+func (t *Typed[T]) invalidate(key string) {
+	if t.local != nil {
+		t.local.Delete(key)
+		t.hot.forget(key)
+	}
+}
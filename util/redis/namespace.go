@@ -0,0 +1,93 @@
+// Package redis redis 访问工具
+//
+// 本包不依赖具体的 redis 客户端，业务基于自己使用的客户端实现 Client 接口。
+// Namespace 管理 key 前缀，同一个进程内前缀重复或者互相包含时直接 panic，
+// 避免不同业务写入相同的 key；每个 Namespace 固定一种编码格式，
+// 读写双方引用同一个 Namespace 定义就不会出现编码不一致的问题。
+// Typed 按照 Namespace 的编码格式读写类型为 T 的值，需要 Go 1.18 及以上版本。
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client redis 客户端，由业务基于具体的客户端实现
+type Client interface {
+	// Get 查询 key，不存在时 ok 为 false
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set 保存 key，ttl 后过期，ttl 为 0 表示不过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete 删除 key
+	Delete(ctx context.Context, key string) error
+}
+
+// Namespace key 前缀，前缀为 service:name，所有 key 都以 service:name: 开头
+type Namespace struct {
+	service string
+	prefix  string
+	codec   Codec
+}
+
+var (
+	namespacesMu sync.Mutex
+	namespaces   = map[string]*Namespace{}
+)
+
+// NewNamespace 注册 service 服务名为 name 的 key 前缀，codec 为值的编码格式
+//
+// 一般定义为包级变量：
+//
+//	var userNS = redis.NewNamespace("user", "profile", redis.Proto)
+//
+// 前缀已经注册过，或者跟已注册的前缀互相包含（如 user:profile 和 user:profile:v2）时 panic。
+func NewNamespace(service, name string, codec Codec) *Namespace {
+	if service == "" || name == "" {
+		panic("redis: service and name of namespace must not be empty")
+	}
+
+	ns := &Namespace{service: service, prefix: service + ":" + name, codec: codec}
+
+	namespacesMu.Lock()
+	defer namespacesMu.Unlock()
+	for prefix := range namespaces {
+		if prefix == ns.prefix ||
+			strings.HasPrefix(prefix, ns.prefix+":") ||
+			strings.HasPrefix(ns.prefix, prefix+":") {
+			panic(fmt.Sprintf("redis: namespace %s conflicts with %s", ns.prefix, prefix))
+		}
+	}
+	namespaces[ns.prefix] = ns
+	return ns
+}
+
+// Namespaces 返回所有已注册的前缀，排查 key 归属时使用
+func Namespaces() []string {
+	namespacesMu.Lock()
+	defer namespacesMu.Unlock()
+
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// Service 返回前缀所属的服务
+func (n *Namespace) Service() string { return n.service }
+
+// Prefix 返回前缀 service:name
+func (n *Namespace) Prefix() string { return n.prefix }
+
+// Codec 返回值的编码格式
+func (n *Namespace) Codec() Codec { return n.codec }
+
+// Key 返回 service:name:part1:part2 形式的 key
+func (n *Namespace) Key(parts ...string) string {
+	return n.prefix + ":" + strings.Join(parts, ":")
+}
@@ -0,0 +1,172 @@
+//go:build go1.18
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"sniper/util/cache"
+)
+
+type memClient struct {
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func newMemClient() *memClient {
+	return &memClient{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (c *memClient) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, ok := c.values[key]
+	return v, ok, nil
+}
+
+func (c *memClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.values[key], c.ttls[key] = value, ttl
+	return nil
+}
+
+func (c *memClient) Delete(ctx context.Context, key string) error {
+	delete(c.values, key)
+	return nil
+}
+
+func TestNamespace(t *testing.T) {
+	ns := NewNamespace("test", "ns", JSON)
+	if key := ns.Key("1", "name"); key != "test:ns:1:name" {
+		t.Errorf("key = %s", key)
+	}
+
+	for _, name := range []string{"ns", "ns:v2"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("namespace test:%s should conflict with test:ns", name)
+				}
+			}()
+			NewNamespace("test", name, JSON)
+		}()
+	}
+	NewNamespace("test", "ns2", JSON)
+}
+
+type user struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTypedJSON(t *testing.T) {
+	ctx := context.Background()
+	client := newMemClient()
+	users := NewTyped[*user](NewNamespace("test", "json_user", JSON), client, time.Minute)
+
+	if _, ok, err := users.Get(ctx, "1"); ok || err != nil {
+		t.Fatalf("missing key: ok = %v, err = %v", ok, err)
+	}
+	if err := users.Set(ctx, &user{ID: 1, Name: "foo"}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if string(client.values["test:json_user:1"]) != `{"id":1,"name":"foo"}` {
+		t.Errorf("value = %s", client.values["test:json_user:1"])
+	}
+	if ttl := client.ttls["test:json_user:1"]; ttl < time.Minute || ttl >= time.Minute+6*time.Second {
+		t.Errorf("ttl = %v, want jitter within 10%%", ttl)
+	}
+
+	u, ok, err := users.Get(ctx, "1")
+	if err != nil || !ok || u.Name != "foo" {
+		t.Errorf("Get = %+v, %v, %v", u, ok, err)
+	}
+
+	users.Jitter = 0
+	_ = users.SetWithTTL(ctx, u, time.Second, "2")
+	if ttl := client.ttls["test:json_user:2"]; ttl != time.Second {
+		t.Errorf("ttl = %v, want 1s without jitter", ttl)
+	}
+}
+
+func TestTypedProto(t *testing.T) {
+	ctx := context.Background()
+	names := NewTyped[*wrapperspb.StringValue](NewNamespace("test", "proto_name", Proto), newMemClient(), 0)
+
+	if err := names.Set(ctx, wrapperspb.String("foo"), "1"); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := names.Get(ctx, "1")
+	if err != nil || !ok || v.GetValue() != "foo" {
+		t.Errorf("Get = %v, %v, %v", v, ok, err)
+	}
+
+	bad := NewTyped[string](NewNamespace("test", "proto_bad", Proto), newMemClient(), 0)
+	if err := bad.Set(ctx, "foo", "1"); err == nil {
+		t.Error("proto codec should reject non proto values")
+	}
+}
+
+func TestHotKeys(t *testing.T) {
+	ctx := context.Background()
+	client := newMemClient()
+	users := NewTyped[*user](NewNamespace("test", "hot_user", JSON), client, 0)
+	users.EnableHotKeys(HotKeyOptions{SampleRate: 1, Threshold: 3, Window: time.Minute, TTL: time.Minute})
+
+	_ = users.Set(ctx, &user{Name: "foo"}, "1")
+	for i := 0; i < 3; i++ {
+		_, _, _ = users.Get(ctx, "1")
+	}
+
+	// 热点 key 不再访问 redis
+	client.values["test:hot_user:1"] = []byte(`{"name":"bar"}`)
+	if u, _, _ := users.Get(ctx, "1"); u.Name != "foo" {
+		t.Errorf("hot key should be served locally, got %s", u.Name)
+	}
+
+	// 写入时删除本地副本
+	_ = users.Set(ctx, &user{Name: "baz"}, "1")
+	if u, _, _ := users.Get(ctx, "1"); u.Name != "baz" {
+		t.Errorf("Set should invalidate local copy, got %s", u.Name)
+	}
+	for i := 0; i < 2; i++ {
+		_, _, _ = users.Get(ctx, "1")
+	}
+	client.values["test:hot_user:1"] = []byte(`{"name":"bar"}`)
+	if u, _, _ := users.Get(ctx, "1"); u.Name != "baz" {
+		t.Errorf("key should be promoted again after invalidation, got %s", u.Name)
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	client := newMemClient()
+	local := cache.NewLRU[string, user]("test", 10, time.Minute)
+	users := cache.NewTiered("test", local, NewStore(NewNamespace("test", "store", JSON), client), time.Hour)
+
+	load := func(ctx context.Context) (user, error) { return user{ID: 1, Name: "foo"}, nil }
+	if u, err := users.Get(ctx, "1", load); err != nil || u.Name != "foo" {
+		t.Fatalf("Get = %v, %v", u, err)
+	}
+	if string(client.values["test:store:1"]) != `{"id":1,"name":"foo"}` {
+		t.Errorf("value = %s", client.values["test:store:1"])
+	}
+	if ttl := client.ttls["test:store:1"]; ttl < time.Hour || ttl >= time.Hour*11/10 {
+		t.Errorf("ttl = %v, want jitter in [1h, 1.1h)", ttl)
+	}
+
+	if err := users.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.values["test:store:1"]; ok {
+		t.Error("key should be deleted")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("proto namespace should be rejected")
+		}
+	}()
+	NewStore(NewNamespace("test", "store_proto", Proto), client)
+}
@@ -0,0 +1,53 @@
+//go:build go1.18
+
+package redis
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"sniper/util/cache"
+	"sniper/util/metrics"
+)
+
+// NewStore 返回 cache.Tiered 使用的二级缓存，key 使用 ns 的前缀
+//
+// Tiered 使用 JSON 编码，ns 的编码格式不是 JSON 时 panic。写入时过期时间按照
+// DefaultJitter 随机，调用耗时记录在 sniper_redis_durations_seconds 指标中：
+//
+//	var userNS = redis.NewNamespace("user", "cache", redis.JSON)
+//	var users = cache.NewTiered("user", local, redis.NewStore(userNS, client), time.Hour)
+func NewStore(ns *Namespace, client Client) cache.Store {
+	if ns.codec.Name() != JSON.Name() {
+		panic("redis: codec of store namespace " + ns.prefix + " must be json")
+	}
+	return &store{ns: ns, client: client}
+}
+
+type store struct {
+	ns     *Namespace
+	client Client
+}
+
+func (s *store) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	defer s.observe("get", time.Now())
+	return s.client.Get(ctx, s.ns.Key(key))
+}
+
+func (s *store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	defer s.observe("set", time.Now())
+	if ttl > 0 {
+		ttl += time.Duration(rand.Float64() * DefaultJitter * float64(ttl))
+	}
+	return s.client.Set(ctx, s.ns.Key(key), value, ttl)
+}
+
+func (s *store) Delete(ctx context.Context, key string) error {
+	defer s.observe("del", time.Now())
+	return s.client.Delete(ctx, s.ns.Key(key))
+}
+
+func (s *store) observe(cmd string, start time.Time) {
+	metrics.RedisDurationsSeconds.WithLabelValues(s.ns.prefix, cmd).Observe(time.Since(start).Seconds())
+}
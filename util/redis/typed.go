@@ -0,0 +1,101 @@
+//go:build go1.18
+
+package redis
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"sniper/util/cache"
+	"sniper/util/metrics"
+)
+
+// DefaultJitter 过期时间默认的随机比例
+const DefaultJitter = 0.1
+
+// Typed 读写类型为 T 的值，key 使用 Namespace 的前缀，值使用 Namespace 的编码格式
+//
+// 写入时过期时间在 [ttl, ttl*(1+Jitter)) 之间随机，避免同时写入的 key 同时过期，
+// 大量请求一起回源。调用耗时记录在 sniper_redis_durations_seconds 指标中，name 标签为前缀。
+type Typed[T any] struct {
+	ns     *Namespace
+	client Client
+	ttl    time.Duration
+
+	hot   *hotKeys
+	local *cache.LRU[string, T]
+
+	// Jitter 过期时间的随机比例，默认为 DefaultJitter，0 表示不随机
+	Jitter float64
+}
+
+// NewTyped 创建 Typed，ttl 为默认的过期时间，0 表示不过期
+func NewTyped[T any](ns *Namespace, client Client, ttl time.Duration) *Typed[T] {
+	return &Typed[T]{ns: ns, client: client, ttl: ttl, Jitter: DefaultJitter}
+}
+
+// Key 返回 parts 对应的完整 key
+func (t *Typed[T]) Key(parts ...string) string {
+	return t.ns.Key(parts...)
+}
+
+// Get 查询 parts 对应的 key，不存在时 ok 为 false
+//
+// 开启热点 key 检测后，热点 key 直接使用进程内的副本，返回的值不能修改。
+func (t *Typed[T]) Get(ctx context.Context, parts ...string) (v T, ok bool, err error) {
+	key := t.ns.Key(parts...)
+	if v, ok := t.getLocal(key); ok {
+		return v, true, nil
+	}
+
+	defer t.observe("get", time.Now())
+
+	b, ok, err := t.client.Get(ctx, key)
+	if err != nil || !ok {
+		return v, false, err
+	}
+	if err = t.ns.codec.Unmarshal(b, &v); err != nil {
+		return v, false, err
+	}
+	t.promote(ctx, key, v)
+	return v, true, nil
+}
+
+// Set 使用默认的过期时间保存 v
+func (t *Typed[T]) Set(ctx context.Context, v T, parts ...string) error {
+	return t.SetWithTTL(ctx, v, t.ttl, parts...)
+}
+
+// SetWithTTL 保存 v，ttl 后过期
+func (t *Typed[T]) SetWithTTL(ctx context.Context, v T, ttl time.Duration, parts ...string) error {
+	defer t.observe("set", time.Now())
+
+	b, err := t.ns.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	key := t.ns.Key(parts...)
+	t.invalidate(key)
+	return t.client.Set(ctx, key, b, t.jitter(ttl))
+}
+
+// Delete 删除 parts 对应的 key
+func (t *Typed[T]) Delete(ctx context.Context, parts ...string) error {
+	defer t.observe("del", time.Now())
+
+	key := t.ns.Key(parts...)
+	t.invalidate(key)
+	return t.client.Delete(ctx, key)
+}
+
+func (t *Typed[T]) jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 || t.Jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Float64()*t.Jitter*float64(ttl))
+}
+
+func (t *Typed[T]) observe(cmd string, start time.Time) {
+	metrics.RedisDurationsSeconds.WithLabelValues(t.ns.prefix, cmd).Observe(time.Since(start).Seconds())
+}
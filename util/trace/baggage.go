@@ -0,0 +1,143 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sniper/util/conf"
+	"sniper/util/ctxkit"
+)
+
+// BaggageHeader 传递 baggage 的请求头和消息头，格式参考 https://www.w3.org/TR/baggage/
+const BaggageHeader = "baggage"
+
+// MaxBaggageSize 编码后 baggage 的最大长度，超出的条目被丢弃
+const MaxBaggageSize = 8192
+
+// DefaultBaggageKeys 没有配置 TRACE_BAGGAGE_KEYS 时允许传递的 baggage，以 * 结尾表示前缀匹配
+var DefaultBaggageKeys = []string{"user_id", "tenant_id", "job", "exp.*"}
+
+type baggageKey struct{}
+
+// WithBaggage 在 ctx 中设置 baggage，value 为空时删除
+//
+// baggage 会随 twirp 请求、消息和任务传递给下游，只有 TRACE_BAGGAGE_KEYS 允许的 key 才会传递。
+// 实验分桶建议使用 exp. 前缀，如 exp.new_home=b。
+func WithBaggage(ctx context.Context, key, value string) context.Context {
+	old, _ := ctx.Value(baggageKey{}).(map[string]string)
+	m := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	if value == "" {
+		delete(m, key)
+	} else {
+		m[key] = value
+	}
+	return context.WithValue(ctx, baggageKey{}, m)
+}
+
+// GetBaggage 查询 ctx 中的 baggage
+func GetBaggage(ctx context.Context, key string) string {
+	return AllBaggage(ctx)[key]
+}
+
+// AllBaggage 返回 ctx 中所有的 baggage
+//
+// ctxkit 中的用户 ID 和租户 ID 分别作为 user_id 和 tenant_id，优先于上游传递的值。
+func AllBaggage(ctx context.Context) map[string]string {
+	old, _ := ctx.Value(baggageKey{}).(map[string]string)
+	m := make(map[string]string, len(old)+2)
+	for k, v := range old {
+		m[k] = v
+	}
+	if uid := ctxkit.GetUserID(ctx); uid != 0 {
+		m["user_id"] = strconv.FormatInt(uid, 10)
+	}
+	if id := ctxkit.GetTenantID(ctx); id != "" {
+		m["tenant_id"] = id
+	}
+	return m
+}
+
+// EncodeBaggage 把允许传递的 baggage 按照 key 排序编码为 baggage 头
+func EncodeBaggage(ctx context.Context) string {
+	all := AllBaggage(ctx)
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		if baggageAllowed(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		item := k + "=" + url.PathEscape(all[k])
+		if b.Len()+len(item)+1 > MaxBaggageSize {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(item)
+	}
+	return b.String()
+}
+
+// ExtractBaggage 解析 baggage 头，把允许传递的条目设置到 ctx 中
+//
+// 格式错误的条目和条目的属性被忽略。baggage 可以被调用方伪造，只能用于日志、监控和 trace，
+// 不能用于鉴权，用户 ID 和租户 ID 仍然使用 ctxkit 中的值。
+func ExtractBaggage(ctx context.Context, header string) context.Context {
+	if header == "" || len(header) > MaxBaggageSize {
+		return ctx
+	}
+
+	for _, item := range strings.Split(header, ",") {
+		if i := strings.IndexByte(item, ';'); i >= 0 {
+			item = item[:i]
+		}
+		i := strings.IndexByte(item, '=')
+		if i <= 0 {
+			continue
+		}
+		k := strings.TrimSpace(item[:i])
+		v, err := url.PathUnescape(strings.TrimSpace(item[i+1:]))
+		if err != nil || !baggageAllowed(k) {
+			continue
+		}
+		ctx = WithBaggage(ctx, k, v)
+	}
+	return ctx
+}
+
+// InjectBaggageHeader 把 ctx 中的 baggage 写入请求头
+func InjectBaggageHeader(ctx context.Context, header http.Header) {
+	if b := EncodeBaggage(ctx); b != "" {
+		header.Set(BaggageHeader, b)
+	}
+}
+
+// baggageAllowed 判断 key 是否允许传递
+func baggageAllowed(key string) bool {
+	patterns := conf.GetStrings("TRACE_BAGGAGE_KEYS")
+	if len(patterns) == 0 {
+		patterns = DefaultBaggageKeys
+	}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(key, p[:len(p)-1]) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"sniper/util/conf"
+	"sniper/util/ctxkit"
+)
+
+func TestBaggage(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxkit.UserIDKey, int64(42))
+	ctx = WithBaggage(ctx, "exp.home", "b 1")
+	ctx = WithBaggage(ctx, "secret", "x")
+	ctx = WithBaggage(ctx, "user_id", "7")
+
+	if v := GetBaggage(ctx, "user_id"); v != "42" {
+		t.Errorf("ctxkit user id should win, got %q", v)
+	}
+	if v := GetBaggage(ctx, "secret"); v != "x" {
+		t.Errorf("local baggage should be kept, got %q", v)
+	}
+
+	h := http.Header{}
+	InjectBaggageHeader(ctx, h)
+	if v := h.Get(BaggageHeader); v != "exp.home=b%201,user_id=42" {
+		t.Errorf("unexpected header %q", v)
+	}
+
+	ctx = ExtractBaggage(context.Background(), " tenant_id = t1;prop=1, bad,secret=x,exp.home=b%201,=v")
+	all := AllBaggage(ctx)
+	if len(all) != 2 || all["tenant_id"] != "t1" || all["exp.home"] != "b 1" {
+		t.Errorf("unexpected baggage %v", all)
+	}
+
+	if v := GetBaggage(WithBaggage(ctx, "tenant_id", ""), "tenant_id"); v != "" {
+		t.Errorf("empty value should delete baggage, got %q", v)
+	}
+}
+
+func TestBaggageKeys(t *testing.T) {
+	conf.Set("TRACE_BAGGAGE_KEYS", "order_id, region.*")
+	defer conf.Set("TRACE_BAGGAGE_KEYS", "")
+
+	ctx := ExtractBaggage(context.Background(), "order_id=1,region.cn=sh,user_id=2")
+	all := AllBaggage(ctx)
+	if len(all) != 2 || all["order_id"] != "1" || all["region.cn"] != "sh" {
+		t.Errorf("unexpected baggage %v", all)
+	}
+}
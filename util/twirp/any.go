@@ -0,0 +1,119 @@
+package twirp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// AnyResolver JSON 编解码 google.protobuf.Any 字段时查找消息类型
+//
+// 优先使用全局注册的类型，找不到时使用生成代码通过 RegisterFileDescriptor 注册的描述，
+// 按照 dynamicpb 解析，客户端没有链接对应的 message 代码也可以正常编解码。
+// 同时实现了 protojson 的 Resolver 和 jsonpb 的 AnyResolver 接口。
+var AnyResolver = &anyResolver{}
+
+type anyResolver struct {
+	mu sync.Mutex
+	// pending 尚未解析的文件描述，第一次找不到类型时才解析，避免拖慢启动
+	pending [][]byte
+	types   protoregistry.Types
+}
+
+// RegisterFileDescriptor 注册 gzip 压缩的 FileDescriptorProto，由生成的代码在 init 中调用
+func RegisterFileDescriptor(gz []byte) {
+	AnyResolver.mu.Lock()
+	defer AnyResolver.mu.Unlock()
+
+	AnyResolver.pending = append(AnyResolver.pending, gz)
+}
+
+// FindMessageByName 实现 protoregistry.MessageTypeResolver
+func (r *anyResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.load()
+	return r.types.FindMessageByName(name)
+}
+
+// FindMessageByURL 实现 protoregistry.MessageTypeResolver，url 的最后一段为消息类型全名
+func (r *anyResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	name := url
+	if i := strings.LastIndexByte(url, '/'); i >= 0 {
+		name = url[i+1:]
+	}
+	return r.FindMessageByName(protoreflect.FullName(name))
+}
+
+// FindExtensionByName 实现 protoregistry.ExtensionTypeResolver，只查找全局注册的扩展
+func (r *anyResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+// FindExtensionByNumber 实现 protoregistry.ExtensionTypeResolver，只查找全局注册的扩展
+func (r *anyResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// Resolve 实现 jsonpb.AnyResolver
+func (r *anyResolver) Resolve(typeURL string) (protov1.Message, error) {
+	mt, err := r.FindMessageByURL(typeURL)
+	if err != nil {
+		return nil, err
+	}
+	return protov1.MessageV1(mt.New().Interface()), nil
+}
+
+// load 解析 pending 中的文件描述，需要持有 r.mu
+//
+// 导入的文件必须已经注册到 protoregistry.GlobalFiles，解析失败的文件直接忽略。
+func (r *anyResolver) load() {
+	for _, gz := range r.pending {
+		zr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadAll(zr)
+		if err != nil {
+			continue
+		}
+		fdp := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(b, fdp); err != nil {
+			continue
+		}
+		fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+		if err != nil {
+			continue
+		}
+		r.register(fd.Messages())
+	}
+	r.pending = nil
+}
+
+func (r *anyResolver) register(mds protoreflect.MessageDescriptors) {
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		if _, err := r.types.FindMessageByName(md.FullName()); err != nil {
+			_ = r.types.RegisterMessage(dynamicpb.NewMessageType(md))
+		}
+		r.register(md.Messages())
+	}
+}
@@ -0,0 +1,435 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 批量接口（@batchable）的请求和响应格式
+//
+// JSON 请求为 {"items":[...]}，响应为 {"results":[...]}，每个结果为 {"result":{...}} 或者
+// {"error":{"code":...,"msg":...,"meta":{...}}}，顺序与请求一致。protobuf 请求和响应的编码与下面的消息相同：
+//
+//	message BatchRequest { repeated Request items = 1; }
+//	message BatchResponse { repeated BatchResult results = 1; }
+//	message BatchResult { Response result = 1; BatchError error = 2; }
+//	message BatchError { string code = 1; string msg = 2; map<string, string> meta = 3; }
+//
+// 单个请求失败不影响其他请求，整个批量请求只有在无法解析时返回错误响应。
+
+// MaxBatchSize 批量请求最多包含的请求数量
+var MaxBatchSize = 100
+
+// BatchItems 批量请求中的消息
+//
+// 生成的代码使用 google.golang.org/protobuf/proto，通过这个类型传入消息，不需要引用 proto.Message。
+type BatchItems []proto.Message
+
+// BatchResult 批量请求中单个请求的结果，Response 和 Err 只有一个不为空
+type BatchResult struct {
+	Response proto.Message
+	Err      error
+}
+
+// newMessage 创建与 m 类型相同的空消息
+func newMessage(m proto.Message) proto.Message {
+	return proto.MessageV1(proto.MessageReflect(m).New().Interface())
+}
+
+// batchCodec 返回批量接口支持的编码格式，只支持 JSON 和 protobuf
+func batchCodec(contentType string) (Codec, bool) {
+	switch normalizeContentType(contentType) {
+	case "application/json":
+		return JSONCodec, true
+	case "application/protobuf":
+		return ProtobufCodec, true
+	}
+	return nil, false
+}
+
+// ReadBatchRequest 读取批量请求，每个请求解析为与 item 类型相同的消息
+//
+// 返回请求使用的编码格式，响应使用相同的格式输出。
+func ReadBatchRequest(req *http.Request, item proto.Message) (Codec, BatchItems, error) {
+	c, ok := batchCodec(req.Header.Get("Content-Type"))
+	if !ok {
+		err := NewError(BadRoute, fmt.Sprintf("unexpected Content-Type: %q, batch requests only support json and protobuf", req.Header.Get("Content-Type")))
+		return nil, nil, err.WithMeta("twirp_invalid_route", req.Method+" "+req.URL.Path)
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(Error); ok {
+			return nil, nil, twerr
+		}
+		return nil, nil, InternalErrorWith(wrapErr(err, "failed to read request body"))
+	}
+
+	parts, err := splitBatch(c, "items", buf)
+	if err != nil {
+		err = wrapErr(err, "failed to parse batch request")
+		return nil, nil, NewError(InvalidArgument, err.Error()).WithMeta("cause", fmt.Sprintf("%T", err))
+	}
+	if len(parts) > MaxBatchSize {
+		return nil, nil, InvalidArgumentError("items", fmt.Sprintf("must contain at most %d requests", MaxBatchSize))
+	}
+
+	items := make(BatchItems, len(parts))
+	for i, part := range parts {
+		items[i] = newMessage(item)
+		if err := c.Unmarshal(part, items[i]); err != nil {
+			err = wrapErr(err, fmt.Sprintf("failed to parse items[%d]", i))
+			return nil, nil, NewError(InvalidArgument, err.Error()).WithMeta("cause", fmt.Sprintf("%T", err))
+		}
+	}
+	return c, items, nil
+}
+
+// RunBatch 最多使用 concurrency 个 goroutine 依次调用 call 处理 n 个请求，返回与请求顺序一致的结果
+//
+// call 中的 panic 只影响对应的请求，转换为 Internal 错误。ctx 结束之后还没有开始的请求直接返回错误。
+func RunBatch(ctx context.Context, n, concurrency int, call func(ctx context.Context, i int) BatchResult) []BatchResult {
+	results := make([]BatchResult, n)
+	if concurrency <= 0 || concurrency > n {
+		concurrency = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runBatchItem(ctx, i, call)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func runBatchItem(ctx context.Context, i int, call func(ctx context.Context, i int) BatchResult) (result BatchResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = BatchResult{Err: InternalError("Internal service panic")}
+		}
+	}()
+
+	switch ctx.Err() {
+	case context.Canceled:
+		return BatchResult{Err: NewError(Canceled, "request canceled before processing")}
+	case context.DeadlineExceeded:
+		return BatchResult{Err: NewError(DeadlineExceeded, "deadline exceeded before processing")}
+	}
+
+	return call(ctx, i)
+}
+
+// WriteBatchResponse 使用 c 输出批量请求的结果，不是 twirp.Error 的错误转换为 Internal 错误
+func WriteBatchResponse(ctx context.Context, resp http.ResponseWriter, hooks *ServerHooks, c Codec, results []BatchResult) {
+	ctx = hooks.CallResponsePrepared(ctx)
+
+	parts := make([][]byte, len(results))
+	for i, r := range results {
+		b, err := encodeBatchResult(c, r)
+		if err != nil {
+			hooks.WriteError(ctx, resp, InternalErrorWith(err))
+			return
+		}
+		parts[i] = b
+	}
+	respBytes := joinBatch(c, "results", parts)
+
+	if err := ChargeMemory(ctx, int64(len(respBytes))); err != nil {
+		hooks.WriteError(ctx, resp, err)
+		return
+	}
+
+	MarkServerTiming(ctx, "marshal")
+	ctx = WithStatusCode(ctx, http.StatusOK)
+	WriteResponseHeader(ctx, resp)
+	resp.Header().Set("Content-Type", c.ContentType())
+	resp.WriteHeader(http.StatusOK)
+
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		hooks.CallError(ctx, NewError(Unknown, msg))
+	}
+	hooks.CallResponseSent(ctx)
+}
+
+// DoBatchRequest 发送批量请求，每个响应解析为与 out 类型相同的消息
+//
+// 返回的 error 表示整个批量请求失败，单个请求的错误保存在对应的 BatchResult 中。
+func DoBatchRequest(ctx context.Context, client HTTPClient, c Codec, url string, in BatchItems, out proto.Message) (_ []BatchResult, err error) {
+	if _, ok := batchCodec(c.ContentType()); !ok {
+		return nil, clientError("batch requests only support json and protobuf", fmt.Errorf("unsupported codec %s", c.Name()))
+	}
+
+	parts := make([][]byte, len(in))
+	for i, m := range in {
+		if parts[i], err = c.Marshal(m); err != nil {
+			return nil, clientError("failed to marshal "+c.Name()+" request", err)
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, clientError("aborted because context was done", err)
+	}
+
+	call := newClientCall(ctx)
+	defer func() { call.done(err) }()
+
+	req, err := newRequest(ctx, url, bytes.NewReader(joinBatch(c, "items", parts)), c.ContentType())
+	if err != nil {
+		return nil, clientError("could not build request", err)
+	}
+	if req, err = call.prepare(req); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, clientError("failed to do request", &transportError{cause: err})
+	}
+
+	defer func() {
+		cerr := resp.Body.Close()
+		if err == nil && cerr != nil {
+			err = clientError("failed to close response body", cerr)
+		}
+	}()
+
+	if err = ctx.Err(); err != nil {
+		return nil, clientError("aborted because context was done", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, errorFromResponse(resp)
+	}
+
+	respBodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, clientError("failed to read response body", err)
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, clientError("aborted because context was done", err)
+	}
+
+	if parts, err = splitBatch(c, "results", respBodyBytes); err != nil {
+		return nil, clientError("failed to unmarshal "+c.Name()+" response", err)
+	}
+	if len(parts) != len(in) {
+		return nil, clientError("failed to unmarshal "+c.Name()+" response", fmt.Errorf("got %d results for %d requests", len(parts), len(in)))
+	}
+
+	results := make([]BatchResult, len(parts))
+	for i, part := range parts {
+		if results[i], err = decodeBatchResult(c, part, newMessage(out)); err != nil {
+			return nil, clientError("failed to unmarshal "+c.Name()+" response", err)
+		}
+	}
+	return results, nil
+}
+
+// joinBatch 把编码之后的消息拼接为 JSON 数组字段 name 或者 protobuf 的第 1 个 repeated 字段
+func joinBatch(c Codec, name string, parts [][]byte) []byte {
+	if isJSONCodec(c) {
+		b := append([]byte(`{"`+name+`":[`), bytes.Join(parts, []byte(","))...)
+		return append(b, "]}"...)
+	}
+
+	var b []byte
+	for _, part := range parts {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, part)
+	}
+	return b
+}
+
+// splitBatch 是 joinBatch 的逆过程，protobuf 忽略未知字段
+func splitBatch(c Codec, name string, b []byte) ([][]byte, error) {
+	if isJSONCodec(c) {
+		var body map[string][]json.RawMessage
+		if err := json.Unmarshal(b, &body); err != nil {
+			return nil, err
+		}
+		parts := make([][]byte, len(body[name]))
+		for i, raw := range body[name] {
+			parts[i] = raw
+		}
+		return parts, nil
+	}
+
+	var parts [][]byte
+	err := rangeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) {
+		if num == 1 && typ == protowire.BytesType {
+			parts = append(parts, v)
+		}
+	})
+	return parts, err
+}
+
+// rangeFields 依次解析 protobuf 编码的字段，v 为 bytes 字段的内容或者 varint 字段的原始编码
+func rangeFields(b []byte, f func(num protowire.Number, typ protowire.Type, v []byte)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v []byte
+		if typ == protowire.BytesType {
+			v, n = protowire.ConsumeBytes(b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f(num, typ, v)
+	}
+	return nil
+}
+
+func encodeBatchResult(c Codec, r BatchResult) ([]byte, error) {
+	if r.Err != nil {
+		twerr, ok := r.Err.(Error)
+		if !ok {
+			twerr = InternalErrorWith(r.Err)
+		}
+		if isJSONCodec(c) {
+			return append(append([]byte(`{"error":`), marshalErrorToJSON(twerr)...), '}'), nil
+		}
+		return protowire.AppendBytes(protowire.AppendTag(nil, 2, protowire.BytesType), encodeBatchError(twerr)), nil
+	}
+
+	b, err := c.Marshal(r.Response)
+	if err != nil {
+		return nil, wrapErr(err, "failed to marshal "+c.Name()+" response")
+	}
+	if isJSONCodec(c) {
+		return append(append([]byte(`{"result":`), b...), '}'), nil
+	}
+	return protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), b), nil
+}
+
+func decodeBatchResult(c Codec, b []byte, out proto.Message) (BatchResult, error) {
+	if isJSONCodec(c) {
+		var t streamTrailer
+		if err := json.Unmarshal(b, &t); err != nil {
+			return BatchResult{}, err
+		}
+		if t.Error != nil {
+			return BatchResult{Err: trailerError(t.Error.Code, t.Error.Msg, t.Error.Meta)}, nil
+		}
+		if t.Result == nil {
+			return BatchResult{}, fmt.Errorf("unexpected result %s", b)
+		}
+		if err := c.Unmarshal(t.Result, out); err != nil {
+			return BatchResult{}, err
+		}
+		return BatchResult{Response: out}, nil
+	}
+
+	var result, errBytes []byte
+	err := rangeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			result = v
+		case num == 2 && typ == protowire.BytesType:
+			errBytes = v
+		}
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+	if errBytes != nil {
+		twerr, err := decodeBatchError(errBytes)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		return BatchResult{Err: twerr}, nil
+	}
+	// 空消息编码之后长度为 0，result 为空但是字段存在
+	if err := c.Unmarshal(result, out); err != nil {
+		return BatchResult{}, err
+	}
+	return BatchResult{Response: out}, nil
+}
+
+func encodeBatchError(twerr Error) []byte {
+	b := protowire.AppendTag(nil, 1, protowire.BytesType)
+	b = protowire.AppendString(b, string(twerr.Code()))
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, twerr.Msg())
+
+	meta := twerr.MetaMap()
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		entry := protowire.AppendTag(nil, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, k)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, meta[k])
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+func decodeBatchError(b []byte) (Error, error) {
+	var code, msg string
+	meta := map[string]string{}
+	var entryErr error
+	err := rangeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) {
+		if typ != protowire.BytesType {
+			return
+		}
+		switch num {
+		case 1:
+			code = string(v)
+		case 2:
+			msg = string(v)
+		case 3:
+			var key, value string
+			if err := rangeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					key = string(v)
+				case num == 2 && typ == protowire.BytesType:
+					value = string(v)
+				}
+			}); err != nil {
+				entryErr = err
+			}
+			meta[key] = value
+		}
+	})
+	if err == nil {
+		err = entryErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return trailerError(code, msg, meta), nil
+}
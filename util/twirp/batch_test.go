@@ -0,0 +1,162 @@
+package twirp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// batchServer 对每个请求返回大写的字符串，fail 返回 NotFound，panic 触发 panic
+func batchServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		codec, items, err := ReadBatchRequest(r, new(wrapperspb.StringValue))
+		if err != nil {
+			(&ServerHooks{}).WriteError(ctx, w, err)
+			return
+		}
+		results := RunBatch(ctx, len(items), 2, func(ctx context.Context, i int) BatchResult {
+			switch v := items[i].(*wrapperspb.StringValue).Value; v {
+			case "fail":
+				return BatchResult{Err: NotFoundError("fail").WithMeta("index", "1")}
+			case "error":
+				return BatchResult{Err: errors.New("plain error")}
+			case "panic":
+				panic(v)
+			default:
+				return BatchResult{Response: wrapperspb.String(strings.ToUpper(v))}
+			}
+		})
+		WriteBatchResponse(ctx, w, &ServerHooks{}, codec, results)
+	}))
+}
+
+func TestBatch(t *testing.T) {
+	ts := batchServer(t)
+	defer ts.Close()
+
+	in := BatchItems{
+		wrapperspb.String("a"),
+		wrapperspb.String("fail"),
+		wrapperspb.String(""),
+		wrapperspb.String("error"),
+		wrapperspb.String("panic"),
+	}
+	for _, codec := range []Codec{JSONCodec, ProtobufCodec} {
+		results, err := DoBatchRequest(context.Background(), http.DefaultClient, codec, ts.URL, in, new(wrapperspb.StringValue))
+		if err != nil {
+			t.Fatalf("%s: %v", codec.Name(), err)
+		}
+		if len(results) != len(in) {
+			t.Fatalf("%s: got %d results", codec.Name(), len(results))
+		}
+
+		if out, ok := results[0].Response.(*wrapperspb.StringValue); !ok || out.Value != "A" || results[0].Err != nil {
+			t.Errorf("%s: results[0] = %+v", codec.Name(), results[0])
+		}
+		if twerr, ok := results[1].Err.(Error); !ok || twerr.Code() != NotFound || twerr.Meta("index") != "1" {
+			t.Errorf("%s: results[1] = %+v", codec.Name(), results[1])
+		}
+		if out, ok := results[2].Response.(*wrapperspb.StringValue); !ok || out.Value != "" {
+			t.Errorf("%s: empty response = %+v", codec.Name(), results[2])
+		}
+		if twerr, ok := results[3].Err.(Error); !ok || twerr.Code() != Internal || twerr.Msg() != "plain error" {
+			t.Errorf("%s: results[3] = %+v", codec.Name(), results[3])
+		}
+		if twerr, ok := results[4].Err.(Error); !ok || twerr.Code() != Internal || twerr.Msg() != "Internal service panic" {
+			t.Errorf("%s: results[4] = %+v", codec.Name(), results[4])
+		}
+	}
+
+	resp, err := http.Post(ts.URL, "application/json", strings.NewReader(`{"items":["a","fail"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"results":[{"result":"A"},{"error":{"code":"not_found","msg":"fail","meta":{"index":"1"}}}]}`
+	if string(body) != want {
+		t.Errorf("json body = %s, want %s", body, want)
+	}
+}
+
+func TestBatchRequestErrors(t *testing.T) {
+	ts := batchServer(t)
+	defer ts.Close()
+
+	size := MaxBatchSize
+	MaxBatchSize = 2
+	defer func() { MaxBatchSize = size }()
+
+	for _, c := range []struct {
+		contentType, body string
+		code              ErrorCode
+	}{
+		{"application/json", `{"items":["a","b","c"]}`, InvalidArgument},
+		{"application/json", `{"items":[1]}`, InvalidArgument},
+		{"application/json", `[]`, InvalidArgument},
+		{"application/protobuf", "\x0a", InvalidArgument},
+		{"text/plain", `a`, BadRoute},
+	} {
+		resp, err := http.Post(ts.URL, c.contentType, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		twerr := errorFromResponse(resp)
+		resp.Body.Close()
+		if twerr.Code() != c.code {
+			t.Errorf("%s %s: got %v, want %s", c.contentType, c.body, twerr, c.code)
+		}
+	}
+
+	if _, err := DoBatchRequest(context.Background(), http.DefaultClient, textCodec{}, ts.URL, nil, new(wrapperspb.StringValue)); err == nil {
+		t.Error("batch request with unsupported codec should fail")
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	var running, max int32
+	results := RunBatch(context.Background(), 10, 3, func(ctx context.Context, i int) BatchResult {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return BatchResult{Response: wrapperspb.Int32(int32(i))}
+	})
+	if max > 3 {
+		t.Errorf("concurrency = %d, want at most 3", max)
+	}
+	for i, r := range results {
+		if r.Response.(*wrapperspb.Int32Value).Value != int32(i) {
+			t.Errorf("results[%d] = %v", i, r.Response)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = RunBatch(ctx, 2, 1, func(ctx context.Context, i int) BatchResult {
+		t.Error("call should not be invoked after ctx is done")
+		return BatchResult{}
+	})
+	for _, r := range results {
+		if twerr, ok := r.Err.(Error); !ok || twerr.Code() != Canceled {
+			t.Errorf("result of canceled batch = %+v", r)
+		}
+	}
+}
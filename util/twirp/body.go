@@ -0,0 +1,55 @@
+package twirp
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// LimitRequestBody 限制请求体的大小，由生成的代码在解析请求之前调用
+//
+// Content-Length 超过 max 时直接返回 ResourceExhausted 错误；
+// 否则包装 req.Body，读取超过 max 字节（如 chunked 请求）时返回同样的错误。
+func LimitRequestBody(req *http.Request, max int64) error {
+	if req.ContentLength > max {
+		return bodyTooLarge(max)
+	}
+	req.Body = &limitedBody{ReadCloser: req.Body, remain: max, max: max}
+	return nil
+}
+
+// RequireContentLength 要求请求必须带有 Content-Length 头
+func RequireContentLength(req *http.Request) error {
+	if req.ContentLength < 0 {
+		return NewError(ResourceExhausted, "Content-Length is required")
+	}
+	return nil
+}
+
+func bodyTooLarge(max int64) Error {
+	return NewError(ResourceExhausted, "request body too large").
+		WithMeta("max_body_size", strconv.FormatInt(max, 10))
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remain int64
+	max    int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remain < 0 {
+		return 0, bodyTooLarge(b.max)
+	}
+
+	// 多读一个字节用于判断是否超过限制
+	if int64(len(p)) > b.remain+1 {
+		p = p[:b.remain+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remain -= int64(n)
+	if b.remain < 0 {
+		return 0, bodyTooLarge(b.max)
+	}
+	return n, err
+}
@@ -0,0 +1,36 @@
+package twirp
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader("12345"))
+	if err := LimitRequestBody(req, 4); err == nil {
+		t.Fatalf("Content-Length exceeds the limit")
+	}
+
+	// 模拟 chunked 请求
+	req = httptest.NewRequest("POST", "/", strings.NewReader("12345"))
+	req.ContentLength = -1
+	if err := LimitRequestBody(req, 4); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ioutil.ReadAll(req.Body)
+	if twerr, ok := err.(Error); !ok || twerr.Code() != ResourceExhausted {
+		t.Fatalf("want resource_exhausted, have=%v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("1234"))
+	req.ContentLength = -1
+	if err := LimitRequestBody(req, 4); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil || string(b) != "1234" {
+		t.Fatalf("have=%q, err=%v", b, err)
+	}
+}
@@ -0,0 +1,59 @@
+package twirp
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultBudget 生成的客户端调用下游时使用的超时比例
+//
+// 下游只能使用剩余时间的 DefaultBudget，剩下的时间留给当前服务处理下游的结果或者错误。
+// 设置为 1 表示直接使用当前请求的 deadline。
+var DefaultBudget = 0.8
+
+type noBudgetKey struct{}
+
+// WithoutBudget 关闭 ctx 中客户端调用的超时预算，下游直接使用 ctx 的 deadline
+func WithoutBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noBudgetKey{}, true)
+}
+
+// ClientBudget 按照 DefaultBudget 设置下游调用的 deadline，由生成的客户端调用
+//
+// 只处理从当前服务收到的请求继承的 deadline；调用方自己通过 context.WithTimeout 等设置的
+// deadline 是为这次调用准备的，原样使用。通过 WithoutBudget 关闭时同样不修改。
+func ClientBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if off, _ := ctx.Value(noBudgetKey{}).(bool); off {
+		return ctx, func() {}
+	}
+	req, ok := HttpRequest(ctx)
+	if !ok {
+		return ctx, func() {}
+	}
+	inherited, ok := req.Context().Deadline()
+	if deadline, has := ctx.Deadline(); !ok || !has || !deadline.Equal(inherited) {
+		return ctx, func() {}
+	}
+	return Budget(ctx, DefaultBudget)
+}
+
+// Budget 按剩余时间的 fraction 设置下游调用的 deadline
+//
+// 所有嵌套调用共用同一个 deadline 时，调用链越长，最后一跳能用的时间就越少，
+// 超时后上游也来不及处理。ctx 没有 deadline、已经超时或者 fraction 不在 (0, 1)
+// 之间时不修改 deadline。
+//
+//	ctx, cancel := twirp.Budget(ctx, 0.5)
+//	defer cancel()
+func Budget(ctx context.Context, fraction float64) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || fraction <= 0 || fraction >= 1 {
+		return ctx, func() {}
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(float64(remaining)*fraction))
+}
@@ -0,0 +1,70 @@
+package twirp
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ctx, cancel := Budget(parent, 0.5)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("child should have a deadline")
+	}
+	if d := time.Until(deadline); d > 500*time.Millisecond || d < 400*time.Millisecond {
+		t.Errorf("remaining of child = %v, want about 500ms", d)
+	}
+
+	if ctx, _ := Budget(context.Background(), 0.5); ctx != context.Background() {
+		t.Error("context without deadline should not be changed")
+	}
+	if ctx, _ := Budget(parent, 1); ctx != parent {
+		t.Error("fraction 1 should not change the deadline")
+	}
+}
+
+func TestClientBudget(t *testing.T) {
+	req := httptest.NewRequest("POST", "/twirp/echo.Echo/Hello", nil)
+	reqCtx, cancel := context.WithTimeout(req.Context(), time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	server := WithHttpRequest(reqCtx, req)
+
+	// 继承自服务端请求的 deadline 只给下游 DefaultBudget
+	ctx, cancel := ClientBudget(server)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	if d := time.Until(deadline); d > 800*time.Millisecond {
+		t.Errorf("remaining of inherited deadline = %v, want at most 800ms", d)
+	}
+
+	// 调用方自己设置的 deadline 原样使用
+	own, cancel := context.WithTimeout(server, 500*time.Millisecond)
+	defer cancel()
+	if ctx, _ := ClientBudget(own); ctx != own {
+		t.Error("deadline set by caller should not be changed")
+	}
+
+	if ctx, _ := ClientBudget(WithoutBudget(server)); hasShorterDeadline(ctx, reqCtx) {
+		t.Error("WithoutBudget should disable the budget")
+	}
+
+	background, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if ctx, _ := ClientBudget(background); ctx != background {
+		t.Error("deadline outside of a server request should not be changed")
+	}
+}
+
+func hasShorterDeadline(ctx, parent context.Context) bool {
+	d, _ := ctx.Deadline()
+	p, _ := parent.Deadline()
+	return d.Before(p)
+}
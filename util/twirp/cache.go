@@ -0,0 +1,77 @@
+//go:build go1.18
+
+package twirp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"sniper/util/cache"
+)
+
+// responseCacheSize @cache 接口进程内缓存的响应数量
+const responseCacheSize = 1024
+
+var responseCacheStore atomic.Value
+
+// SetResponseCacheStore 设置 @cache 接口的二级缓存，一般在 main 函数中调用
+//
+// 没有设置时只使用进程内缓存，多实例部署可以使用 redis.NewStore。
+func SetResponseCacheStore(store cache.Store) {
+	responseCacheStore.Store(&store)
+}
+
+// NewResponseCache 创建 path 接口的响应缓存，接口使用 @cache 注解后由生成的代码调用
+//
+// 一级缓存最多保存 1024 个响应，一级和二级缓存都在 ttl 后过期。
+func NewResponseCache[T proto.Message](path string, ttl time.Duration) *cache.Tiered[T] {
+	return cache.NewTiered[T](path, cache.NewLRU[string, T](path, responseCacheSize, ttl), responseStore{}, ttl)
+}
+
+// CachedCall 优先返回缓存的响应，未命中时调用 call，缓存键为 path 和请求内容的摘要
+//
+// 缓存的响应会被多个请求共享，业务方法和 hooks 都不能修改。
+func CachedCall[T proto.Message](ctx context.Context, c *cache.Tiered[T], path string, req proto.Message, call func(context.Context) (T, error)) (T, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return call(ctx)
+	}
+	sum := sha256.Sum256(b)
+	return c.Get(ctx, path+":"+hex.EncodeToString(sum[:]), call)
+}
+
+// responseStore 转发到 SetResponseCacheStore 设置的二级缓存，生成的代码在包初始化时创建缓存
+type responseStore struct{}
+
+func (responseStore) store() cache.Store {
+	if s, ok := responseCacheStore.Load().(*cache.Store); ok {
+		return *s
+	}
+	return nil
+}
+
+func (s responseStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if store := s.store(); store != nil {
+		return store.Get(ctx, key)
+	}
+	return nil, false, nil
+}
+
+func (s responseStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if store := s.store(); store != nil {
+		return store.Set(ctx, key, value, ttl)
+	}
+	return nil
+}
+
+func (s responseStore) Delete(ctx context.Context, key string) error {
+	if store := s.store(); store != nil {
+		return store.Delete(ctx, key)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build go1.18
+
+package twirp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type mapCacheStore map[string][]byte
+
+func (s mapCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, ok := s[key]
+	return b, ok, nil
+}
+
+func (s mapCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s[key] = value
+	return nil
+}
+
+func (s mapCacheStore) Delete(ctx context.Context, key string) error {
+	delete(s, key)
+	return nil
+}
+
+func TestCachedCall(t *testing.T) {
+	const path = "/echo.Echo/Hello"
+	ctx := context.Background()
+	c := NewResponseCache[*wrapperspb.StringValue](path, time.Minute)
+
+	calls := 0
+	call := func(req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return CachedCall(ctx, c, path, req, func(ctx context.Context) (*wrapperspb.StringValue, error) {
+			calls++
+			return wrapperspb.String("hello " + req.GetValue()), nil
+		})
+	}
+
+	for _, name := range []string{"foo", "foo", "bar"} {
+		if resp, err := call(wrapperspb.String(name)); err != nil || resp.GetValue() != "hello "+name {
+			t.Fatalf("resp = %v, %v", resp, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler is called %d times, want 2", calls)
+	}
+
+	store := mapCacheStore{}
+	SetResponseCacheStore(store)
+	defer SetResponseCacheStore(nil)
+	if _, err := call(wrapperspb.String("baz")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store) != 1 {
+		t.Errorf("response should be written to the store, got %v", store)
+	}
+}
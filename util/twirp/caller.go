@@ -0,0 +1,57 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+)
+
+// InternalChecker 判断请求是否来自可信的内部调用方
+//
+// 接口使用 @internal 注解后，生成的代码会在调用业务方法之前检查，
+// 非内部请求返回 PermissionDenied 错误。
+type InternalChecker interface {
+	// Internal 判断请求是否来自内部，如检查网关注入的请求头、mTLS 证书等
+	Internal(req *http.Request) bool
+}
+
+// WithInternalChecker 注入内部请求检查器，一般在 hook.RequestReceived 阶段调用
+func WithInternalChecker(ctx context.Context, checker InternalChecker) context.Context {
+	return context.WithValue(ctx, InternalCheckerKey, checker)
+}
+
+// InternalRequest 判断当前请求是否来自内部，没有注入 InternalChecker 时返回 false
+func InternalRequest(ctx context.Context) bool {
+	checker, ok := ctx.Value(InternalCheckerKey).(InternalChecker)
+	if !ok {
+		return false
+	}
+
+	req, ok := HttpRequest(ctx)
+	if !ok {
+		return false
+	}
+
+	return checker.Internal(req)
+}
+
+// OriginAllowed 判断请求的 Origin 头是否在 origins 中
+//
+// 没有 Origin 头的请求（非浏览器发起）总是允许。
+func OriginAllowed(ctx context.Context, origins ...string) bool {
+	req, ok := HttpRequest(ctx)
+	if !ok {
+		return true
+	}
+
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, o := range origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
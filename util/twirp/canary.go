@@ -0,0 +1,69 @@
+package twirp
+
+import (
+	"context"
+	"math/rand"
+)
+
+// CanaryRule 灰度规则，决定请求是否交给灰度实现处理
+//
+// 判断顺序为 Match、Header、Percent，满足任意一条即使用灰度实现。
+type CanaryRule struct {
+	// Match 自定义规则
+	Match func(ctx context.Context) bool
+	// Header 请求带有该头（值不为空即可）时使用灰度实现，方便测试
+	Header string
+	// Percent 灰度比例，取值 0-100
+	Percent int64
+	// Key 返回分桶依据，如用户 ID，保证同一用户的结果稳定
+	// 为空或者返回 0 时随机分配
+	Key func(ctx context.Context) int64
+}
+
+// Hit 判断当前请求是否使用灰度实现
+func (r *CanaryRule) Hit(ctx context.Context) bool {
+	if r == nil {
+		return false
+	}
+
+	if r.Match != nil && r.Match(ctx) {
+		return true
+	}
+
+	if r.Header != "" {
+		if req, ok := HttpRequest(ctx); ok && req.Header.Get(r.Header) != "" {
+			return true
+		}
+	}
+
+	if r.Percent <= 0 {
+		return false
+	}
+
+	var key int64
+	if r.Key != nil {
+		key = r.Key(ctx)
+	}
+	if key == 0 {
+		key = rand.Int63()
+	}
+	bucket := key % 100
+	if bucket < 0 {
+		bucket = -bucket
+	}
+
+	return bucket < r.Percent
+}
+
+// WithCanary 记录当前请求是否使用灰度实现，由生成的代码调用
+func WithCanary(ctx context.Context, canary bool) context.Context {
+	return context.WithValue(ctx, CanaryKey, canary)
+}
+
+// Canary 返回当前请求是否使用灰度实现
+//
+// 服务没有配置灰度实现时 ok 为 false
+func Canary(ctx context.Context) (canary bool, ok bool) {
+	canary, ok = ctx.Value(CanaryKey).(bool)
+	return
+}
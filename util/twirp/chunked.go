@@ -0,0 +1,240 @@
+package twirp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 分块输出的 protobuf 响应通过 HTTP trailer 返回处理结果，与 gRPC 的 grpc-status 类似
+const (
+	// StatusTrailer 成功时为 ok，否则为 twirp 错误码
+	StatusTrailer = "Twirp-Status"
+	// MessageTrailer 错误信息，按照 grpc-message 的规则做百分号编码
+	MessageTrailer = "Twirp-Message"
+
+	trailerOK = "ok"
+)
+
+const (
+	// chunkBufferSize 分块输出的缓冲区大小
+	chunkBufferSize = 32 << 10
+	// chunkListSize 顶层 repeated、map 字段每次编码的元素个数
+	chunkListSize = 1024
+)
+
+// WithChunkedResponse 标记 protobuf 响应不小于 minSize 字节时分块输出，由生成的代码调用
+//
+// 开启 chunk_min_size 参数后，生成的代码只对 application/protobuf 请求调用本函数，
+// WriteResponse 会逐个字段编码并输出响应，不再先把整个响应编码到内存中，
+// 因为 Content-Length 未知，处理结果通过 Twirp-Status 和 Twirp-Message trailer 返回。
+func WithChunkedResponse(ctx context.Context, minSize int64) context.Context {
+	return context.WithValue(ctx, chunkedResponseKey, minSize)
+}
+
+// chunkedResponse 判断是否需要分块输出 m
+//
+// 需要整体加密的响应仍然按照原来的方式输出。
+func chunkedResponse(ctx context.Context, m protov1.Message) bool {
+	minSize, ok := ctx.Value(chunkedResponseKey).(int64)
+	if !ok || minSize <= 0 {
+		return false
+	}
+	if _, ok := ctx.Value(encryptResponseKey).(Cipher); ok {
+		return false
+	}
+	return int64(proto.Size(protov1.MessageV2(m))) >= minSize
+}
+
+// writeChunkedResponse 分块输出 protobuf 响应，由 WriteResponse 调用
+func writeChunkedResponse(ctx context.Context, resp http.ResponseWriter, hooks *ServerHooks, respContent protov1.Message) {
+	m := protov1.MessageV2(respContent)
+	h := resp.Header()
+	h.Set("Content-Type", "application/protobuf")
+	h.Del("Content-Length")
+	h.Set("Trailer", StatusTrailer+", "+MessageTrailer)
+
+	var w io.Writer = resp
+	var zw *gzip.Writer
+	if minSize, ok := ctx.Value(gzipResponseKey).(int64); ok {
+		h.Add("Vary", "Accept-Encoding")
+		if int64(proto.Size(m)) >= minSize && h.Get("Content-Encoding") == "" {
+			h.Set("Content-Encoding", "gzip")
+			zw = gzip.NewWriter(resp)
+			w = zw
+		}
+	}
+
+	ctx = WithStatusCode(ctx, http.StatusOK)
+	WriteResponseHeader(ctx, resp)
+	resp.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriterSize(w, chunkBufferSize)
+	e := &chunkEncoder{w: bw}
+	err := e.encode(m.ProtoReflect(), true)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	MarkServerTiming(ctx, "marshal")
+
+	if err != nil {
+		// 响应头已经输出，只能通过 trailer 返回错误
+		twerr, ok := err.(Error)
+		if !ok {
+			twerr = NewError(Unknown, "failed to write chunked response: "+err.Error())
+		}
+		h.Set(StatusTrailer, string(twerr.Code()))
+		h.Set(MessageTrailer, encodeGRPCMessage(twerr.Msg()))
+		hooks.CallError(ctx, twerr)
+	} else {
+		h.Set(StatusTrailer, trailerOK)
+	}
+	hooks.CallResponseSent(ctx)
+}
+
+// chunkEncoder 逐个字段编码并输出 message
+//
+// protobuf 的编码就是各个字段编码的拼接，嵌套的 message 先用 proto.Size 计算长度，再递归输出，
+// 内存占用只与单个非 message 字段的大小有关。
+type chunkEncoder struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// encode 输出 m 的所有字段，top 表示 m 是响应本身
+//
+// 顶层的 repeated 和 map 字段没有外层长度的限制，可以分段编码，packed 字段分段之后仍然可以正确解析；
+// 嵌套 message 中的字段必须与 proto.Size 的计算结果完全一致，只能整体编码。
+func (e *chunkEncoder) encode(m protoreflect.Message, top bool) (err error) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Kind() == protoreflect.MessageKind:
+			list := v.List()
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = e.message(fd.Number(), list.Get(i).Message())
+			}
+		case fd.IsList() && top:
+			list := v.List()
+			for i := 0; i < list.Len() && err == nil; i += chunkListSize {
+				part := m.New()
+				l := part.Mutable(fd).List()
+				for j := i; j < i+chunkListSize && j < list.Len(); j++ {
+					l.Append(list.Get(j))
+				}
+				err = e.marshal(part)
+			}
+		case fd.IsMap() && top:
+			part := m.New()
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				part.Mutable(fd).Map().Set(k, v)
+				if part.Get(fd).Map().Len() >= chunkListSize {
+					err = e.marshal(part)
+					part = m.New()
+				}
+				return err == nil
+			})
+			if err == nil && part.Has(fd) {
+				err = e.marshal(part)
+			}
+		case !fd.IsList() && !fd.IsMap() && fd.Kind() == protoreflect.MessageKind:
+			err = e.message(fd.Number(), v.Message())
+		default:
+			part := m.New()
+			part.Set(fd, v)
+			err = e.marshal(part)
+		}
+		return err == nil
+	})
+	if err == nil {
+		err = e.write(m.GetUnknown())
+	}
+	return err
+}
+
+// message 输出嵌套的 message 字段
+func (e *chunkEncoder) message(num protoreflect.FieldNumber, m protoreflect.Message) error {
+	e.buf = protowire.AppendTag(e.buf[:0], num, protowire.BytesType)
+	e.buf = protowire.AppendVarint(e.buf, uint64(proto.Size(m.Interface())))
+	if err := e.write(e.buf); err != nil {
+		return err
+	}
+	return e.encode(m, false)
+}
+
+func (e *chunkEncoder) marshal(m protoreflect.Message) error {
+	var err error
+	e.buf, err = proto.MarshalOptions{}.MarshalAppend(e.buf[:0], m.Interface())
+	if err != nil {
+		return InternalErrorWith(wrapErr(err, "failed to marshal proto response"))
+	}
+	return e.write(e.buf)
+}
+
+func (e *chunkEncoder) write(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	_, err := e.w.Write(b)
+	return err
+}
+
+// chunkedTrailerError 检查分块输出的响应的 trailer，需要在读完响应体之后调用
+//
+// 服务端声明了 Twirp-Status trailer 却没有返回时，说明响应不完整。
+func chunkedTrailerError(resp *http.Response) Error {
+	if _, ok := resp.Trailer[StatusTrailer]; !ok {
+		return nil
+	}
+	switch status := resp.Trailer.Get(StatusTrailer); status {
+	case trailerOK:
+		return nil
+	case "":
+		return InternalError("incomplete chunked response")
+	default:
+		return trailerError(status, decodeGRPCMessage(resp.Trailer.Get(MessageTrailer)), nil)
+	}
+}
+
+// decodeGRPCMessage 解码 encodeGRPCMessage 编码的信息
+func decodeGRPCMessage(msg string) string {
+	if !strings.Contains(msg, "%") {
+		return msg
+	}
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '%' && i+2 < len(msg) {
+			if c, ok := unhex(msg[i+1]); ok {
+				if d, ok := unhex(msg[i+2]); ok {
+					b.WriteByte(c<<4 | d)
+					i += 2
+					continue
+				}
+			}
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,143 @@
+package twirp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func chunkedMessages(t *testing.T) []proto.Message {
+	t.Helper()
+	path := make([]int32, 3000)
+	for i := range path {
+		path[i] = int32(i)
+	}
+	loc := &descriptorpb.SourceCodeInfo_Location{
+		Path:                    path,
+		Span:                    []int32{1, 2, 3},
+		LeadingComments:         proto.String("hello"),
+		LeadingDetachedComments: []string{"a", "b"},
+	}
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:           proto.String("echo.proto"),
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{Location: []*descriptorpb.SourceCodeInfo_Location{loc, loc}},
+	}
+	for i := 0; i < 100; i++ {
+		file.MessageType = append(file.MessageType, &descriptorpb.DescriptorProto{
+			Name:    proto.String(strings.Repeat("m", i)),
+			Field:   []*descriptorpb.FieldDescriptorProto{{Name: proto.String("f"), Number: proto.Int32(int32(i))}},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(i%2 == 0)},
+		})
+	}
+
+	fields := map[string]interface{}{}
+	for i := 0; i < 3000; i++ {
+		fields[strings.Repeat("k", i%50)+string(rune('a'+i%26))+strings.Repeat("x", i/26)] = []interface{}{float64(i), "v", map[string]interface{}{"n": true}}
+	}
+	st, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := &descriptorpb.SourceCodeInfo_Location{Span: []int32{1}}
+	unknown.ProtoReflect().SetUnknown([]byte{0xf8, 0x01, 0x01})
+
+	return []proto.Message{loc, file, st, unknown, &descriptorpb.FileDescriptorProto{}}
+}
+
+func TestChunkEncoder(t *testing.T) {
+	for _, m := range chunkedMessages(t) {
+		var buf bytes.Buffer
+		w := bufio.NewWriterSize(&buf, 16)
+		e := &chunkEncoder{w: w}
+		if err := e.encode(m.ProtoReflect(), true); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		out := m.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(buf.Bytes(), out); err != nil {
+			t.Fatalf("%T: %v", m, err)
+		}
+		if !proto.Equal(m, out) {
+			t.Errorf("%T: decoded message is not equal to the original", m)
+		}
+	}
+}
+
+func TestWriteChunkedResponse(t *testing.T) {
+	file := chunkedMessages(t)[1].(*descriptorpb.FileDescriptorProto)
+	var contentLength string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithChunkedResponse(r.Context(), 1024)
+		if r.URL.Path == "/small" {
+			WriteResponse(ctx, w, &ServerHooks{}, &descriptorpb.FileDescriptorProto{Name: proto.String("a")}, MarshalProtobuf)
+		} else {
+			WriteResponse(ctx, w, &ServerHooks{}, file, MarshalProtobuf)
+		}
+	}))
+	defer ts.Close()
+
+	client := doFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			contentLength = resp.Header.Get("Content-Length")
+		}
+		return resp, err
+	})
+
+	out := new(descriptorpb.FileDescriptorProto)
+	if err := DoProtobufRequest(context.Background(), client, ts.URL+"/large", file, out); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(file, out) || contentLength != "" {
+		t.Errorf("chunked response: Content-Length %q, equal %v", contentLength, proto.Equal(file, out))
+	}
+
+	out = new(descriptorpb.FileDescriptorProto)
+	if err := DoProtobufRequest(context.Background(), client, ts.URL+"/small", file, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.GetName() != "a" || contentLength == "" {
+		t.Errorf("small response: Content-Length %q, name %q", contentLength, out.GetName())
+	}
+}
+
+type doFunc func(req *http.Request) (*http.Response, error)
+
+func (f doFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestChunkedTrailerError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", StatusTrailer+", "+MessageTrailer)
+		w.Header().Set("Content-Type", "application/protobuf")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte{0x0a, 0x01, 'a'})
+		if r.URL.Path == "/error" {
+			w.Header().Set(StatusTrailer, string(Unavailable))
+			w.Header().Set(MessageTrailer, encodeGRPCMessage("100% down"))
+		}
+	}))
+	defer ts.Close()
+
+	out := new(descriptorpb.FileDescriptorProto)
+	err := DoProtobufRequest(context.Background(), http.DefaultClient, ts.URL+"/error", out, out)
+	if twerr, ok := err.(Error); !ok || twerr.Code() != Unavailable || twerr.Msg() != "100% down" {
+		t.Errorf("got error %v, want unavailable", err)
+	}
+	err = DoProtobufRequest(context.Background(), http.DefaultClient, ts.URL+"/truncated", out, out)
+	if twerr, ok := err.(Error); !ok || twerr.Code() != Internal {
+		t.Errorf("got error %v, want internal", err)
+	}
+}
@@ -0,0 +1,122 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Cipher 请求/响应加解密接口
+//
+// 接口使用 @encrypted 注解后，生成的代码会在解析请求之前调用 Decrypt 解密请求体，
+// 并在输出响应之前调用 Encrypt 加密响应内容。错误响应不加密。
+type Cipher interface {
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// EncryptedEncodingHeader 加密响应的压缩格式
+//
+// 加密之后的内容无法压缩，所以加密响应先压缩再加密。响应体的最外层是密文，不能使用
+// Content-Encoding，否则客户端会先解压密文；客户端解密之后按照该响应头解压。
+const EncryptedEncodingHeader = "X-Encrypted-Content-Encoding"
+
+// WithCipher 注入加解密对象，一般在 hook.RequestReceived 阶段调用
+func WithCipher(ctx context.Context, c Cipher) context.Context {
+	return context.WithValue(ctx, CipherKey, c)
+}
+
+// DecryptRequest 解密请求体，并在 ctx 中标记需要加密响应
+//
+// 如果 ctx 中没有注入 Cipher，则返回 Internal 错误，避免敏感数据明文传输。
+func DecryptRequest(ctx context.Context, req *http.Request) (context.Context, error) {
+	c, ok := ctx.Value(CipherKey).(Cipher)
+	if !ok {
+		return ctx, NewError(Internal, "cipher is not configured")
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		if twerr, ok := err.(Error); ok {
+			return ctx, twerr
+		}
+		return ctx, InternalErrorWith(err)
+	}
+
+	plain, err := c.Decrypt(ctx, buf)
+	if err != nil {
+		return ctx, NewError(InvalidArgument, "failed to decrypt request body")
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(plain))
+	req.ContentLength = int64(len(plain))
+
+	return context.WithValue(ctx, encryptResponseKey, c), nil
+}
+
+// EncryptResponse 加密响应内容，请求没有经过 DecryptRequest 解密时原样返回
+//
+// 需要在 CompressResponse 之后调用。
+func EncryptResponse(ctx context.Context, b []byte) ([]byte, error) {
+	c, ok := ctx.Value(encryptResponseKey).(Cipher)
+	if !ok {
+		return b, nil
+	}
+	return c.Encrypt(ctx, b)
+}
+
+type aesGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM 返回使用 AES-GCM 算法的 Cipher，key 长度为 16、24 或 32 字节
+//
+// 密文格式为 nonce + 密文 + tag，nonce 长度为 12 字节。附加数据（AAD）为方向和接口路径，
+// 解密请求时为 "request /package.Service/Method"，加密响应时为 "response /package.Service/Method"，
+// 密文不能被替换到其他接口，也不能在请求和响应之间互换。
+func NewAESGCM(key []byte) (Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesGCM{aead: aead}, nil
+}
+
+func (c aesGCM) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, cipherAAD(ctx, "response")), nil
+}
+
+func (c aesGCM) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, errors.New("ciphertext too short")
+	}
+	return c.aead.Open(nil, ciphertext[:size], ciphertext[size:], cipherAAD(ctx, "request"))
+}
+
+// cipherAAD 返回 direction 和当前接口路径组成的附加数据
+func cipherAAD(ctx context.Context, direction string) []byte {
+	pkg, _ := PackageName(ctx)
+	service, _ := ServiceName(ctx)
+	method, _ := MethodName(ctx)
+	if pkg != "" {
+		service = pkg + "." + service
+	}
+	return []byte(direction + " /" + service + "/" + method)
+}
@@ -0,0 +1,126 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+var testCipherKey = bytes.Repeat([]byte("k"), 16)
+
+func cipherContext(method string) context.Context {
+	ctx := WithPackageName(context.Background(), "echo.v1")
+	ctx = WithServiceName(ctx, "Echo")
+	return WithMethodName(ctx, method)
+}
+
+// sealRequest 按照客户端的方式加密请求体
+func sealRequest(t *testing.T, key []byte, ctx context.Context, plaintext []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(nonce, nonce, plaintext, cipherAAD(ctx, "request"))
+}
+
+// openResponse 按照客户端的方式解密响应
+func openResponse(key []byte, ctx context.Context, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	size := aead.NonceSize()
+	return aead.Open(nil, ciphertext[:size], ciphertext[size:], cipherAAD(ctx, "response"))
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewAESGCM(testCipherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := cipherContext("Hello")
+
+	req := httptest.NewRequest("POST", "/echo.v1.Echo/Hello", bytes.NewReader(sealRequest(t, testCipherKey, ctx, []byte(`{"msg":"hi"}`))))
+	ctx, err = DecryptRequest(WithCipher(ctx, c), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(req.Body); string(b) != `{"msg":"hi"}` || req.ContentLength != int64(len(b)) {
+		t.Errorf("request body = %q, length %d", b, req.ContentLength)
+	}
+
+	out, err := EncryptResponse(ctx, []byte(`{"msg":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := openResponse(testCipherKey, ctx, out); err != nil || string(b) != `{"msg":"hello"}` {
+		t.Errorf("response = %q, %v", b, err)
+	}
+
+	// 没有解密请求时响应不加密
+	if b, _ := EncryptResponse(context.Background(), []byte("{}")); string(b) != "{}" {
+		t.Errorf("response should not be encrypted, got %q", b)
+	}
+}
+
+func TestCipherTamper(t *testing.T) {
+	c, err := NewAESGCM(testCipherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := cipherContext("Hello")
+	body := sealRequest(t, testCipherKey, ctx, []byte(`{"msg":"hi"}`))
+
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-1] ^= 1
+
+	other, err := NewAESGCM(bytes.Repeat([]byte("x"), 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Encrypt(ctx, []byte(`{"msg":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		cipher Cipher
+		ctx    context.Context
+		body   []byte
+	}{
+		{"tampered", c, ctx, tampered},
+		{"too short", c, ctx, body[:8]},
+		{"wrong key", other, ctx, body},
+		{"other method", c, cipherContext("Reload"), body},
+		{"response as request", c, ctx, resp},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(tc.body))
+			_, err := DecryptRequest(WithCipher(tc.ctx, tc.cipher), req)
+			if terr, ok := err.(Error); !ok || terr.Code() != InvalidArgument {
+				t.Errorf("err = %v, want invalid_argument", err)
+			}
+		})
+	}
+
+	// 没有配置 Cipher 时不能明文传输
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	if _, err := DecryptRequest(ctx, req); err == nil {
+		t.Error("request without cipher should fail")
+	}
+}
@@ -37,13 +37,19 @@ func DoProtobufRequest(ctx context.Context, client HTTPClient, url string, in, o
 		return clientError("aborted because context was done", err)
 	}
 
+	call := newClientCall(ctx)
+	defer func() { call.done(err) }()
+
 	req, err := newRequest(ctx, url, reqBody, "application/protobuf")
 	if err != nil {
 		return clientError("could not build request", err)
 	}
+	if req, err = call.prepare(req); err != nil {
+		return err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return clientError("failed to do request", err)
+		return clientError("failed to do request", &transportError{cause: err})
 	}
 
 	defer func() {
@@ -57,6 +63,10 @@ func DoProtobufRequest(ctx context.Context, client HTTPClient, url string, in, o
 		return clientError("aborted because context was done", err)
 	}
 
+	// 返回 google.protobuf.Empty 的接口可能输出 204，out 保持为空
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
 	if resp.StatusCode != 200 {
 		return errorFromResponse(resp)
 	}
@@ -68,6 +78,10 @@ func DoProtobufRequest(ctx context.Context, client HTTPClient, url string, in, o
 	if err = ctx.Err(); err != nil {
 		return clientError("aborted because context was done", err)
 	}
+	// 分块输出的响应通过 trailer 返回错误
+	if twerr := chunkedTrailerError(resp); twerr != nil {
+		return twerr
+	}
 
 	if err = proto.Unmarshal(respBodyBytes, out); err != nil {
 		return clientError("failed to unmarshal proto response", err)
@@ -78,7 +92,7 @@ func DoProtobufRequest(ctx context.Context, client HTTPClient, url string, in, o
 // DoJSONRequest is common code to make a request to the remote twirp service.
 func DoJSONRequest(ctx context.Context, client HTTPClient, url string, in, out proto.Message) (err error) {
 	reqBody := bytes.NewBuffer(nil)
-	marshaler := &jsonpb.Marshaler{OrigName: true}
+	marshaler := &jsonpb.Marshaler{OrigName: true, AnyResolver: AnyResolver}
 	if err = marshaler.Marshal(reqBody, in); err != nil {
 		return clientError("failed to marshal json request", err)
 	}
@@ -86,13 +100,19 @@ func DoJSONRequest(ctx context.Context, client HTTPClient, url string, in, out p
 		return clientError("aborted because context was done", err)
 	}
 
+	call := newClientCall(ctx)
+	defer func() { call.done(err) }()
+
 	req, err := newRequest(ctx, url, reqBody, "application/json")
 	if err != nil {
 		return clientError("could not build request", err)
 	}
+	if req, err = call.prepare(req); err != nil {
+		return err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return clientError("failed to do request", err)
+		return clientError("failed to do request", &transportError{cause: err})
 	}
 
 	defer func() {
@@ -106,11 +126,15 @@ func DoJSONRequest(ctx context.Context, client HTTPClient, url string, in, out p
 		return clientError("aborted because context was done", err)
 	}
 
+	// 返回 google.protobuf.Empty 的接口可能输出 204，out 保持为空
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
 	if resp.StatusCode != 200 {
 		return errorFromResponse(resp)
 	}
 
-	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true, AnyResolver: AnyResolver}
 	if err = unmarshaler.Unmarshal(resp.Body, out); err != nil {
 		return clientError("failed to unmarshal json response", err)
 	}
@@ -133,6 +157,12 @@ func newRequest(ctx context.Context, url string, reqBody io.Reader, contentType
 	req.Header.Set("Accept", contentType)
 	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Twirp-Version", "v5.5.0")
+	if p, ok := ctx.Value(PriorityKey).(Priority); ok {
+		req.Header.Set(PriorityHeader, p.String())
+	}
+	if CallerName != "" {
+		req.Header.Set(CallerHeader, CallerName)
+	}
 	return req, nil
 }
 
@@ -171,6 +201,7 @@ type wrappedError struct {
 func wrapErr(err error, msg string) error { return &wrappedError{msg: msg, cause: err} }
 func (e *wrappedError) Cause() error      { return e.cause }
 func (e *wrappedError) Error() string     { return e.msg + ": " + e.cause.Error() }
+func (e *wrappedError) Unwrap() error     { return e.cause }
 
 // errorFromResponse builds a Error from a non-200 HTTP response.
 // If the response has a valid serialized Twirp error, then it's returned.
@@ -193,19 +224,28 @@ func errorFromResponse(resp *http.Response) Error {
 	if err != nil {
 		return clientError("failed to read server error response body", err)
 	}
-	var tj twerr
+	var tj struct {
+		Code string            `json:"code"`
+		Msg  string            `json:"msg"`
+		Meta map[string]string `json:"meta"`
+	}
 	if err := json.Unmarshal(respBodyBytes, &tj); err != nil {
 		// Invalid JSON response; it must be an error from an intermediary.
 		msg := fmt.Sprintf("Error from intermediary with HTTP status code %d %q", statusCode, statusText)
 		return twirpErrorFromIntermediary(statusCode, msg, string(respBodyBytes))
 	}
 
-	if !IsValidErrorCode(tj.Code()) {
-		msg := "invalid type returned from server error response: " + string(tj.Code())
+	code := ErrorCode(tj.Code)
+	if !IsValidErrorCode(code) {
+		msg := "invalid type returned from server error response: " + tj.Code
 		return InternalError(msg)
 	}
 
-	return &tj
+	twerr := NewError(code, tj.Msg)
+	for k, v := range tj.Meta {
+		twerr = twerr.WithMeta(k, v)
+	}
+	return twerr
 }
 
 // twirpErrorFromIntermediary maps HTTP errors from non-twirp sources to twirp errors.
@@ -0,0 +1,148 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+)
+
+// ClientHooks 生成的客户端调用下游接口时的回调，用于记录监控、日志等
+//
+// ctx 中已经设置了 PackageName、ServiceName 和 MethodName。请求编码成功之后先调用
+// RequestPrepared，之后成功时调用 ResponseReceived，失败时调用 Error，两者只会调用一个。
+// 请求编码失败时不会调用任何回调。
+type ClientHooks struct {
+	// RequestPrepared 请求已经编码、即将发送时调用，可以修改请求头，
+	// 返回错误时不发送请求，错误直接返回给调用方
+	RequestPrepared func(context.Context, *http.Request) (context.Context, error)
+
+	// ResponseReceived 成功解析响应之后调用
+	ResponseReceived func(context.Context)
+
+	// Error 调用失败时调用，包括网络错误、下游返回的错误和响应解析错误
+	Error func(context.Context, Error)
+}
+
+// DefaultClientHooks 所有生成的客户端使用的回调，在程序启动时设置，不能跟调用并发修改
+var DefaultClientHooks *ClientHooks
+
+// CallRequestPrepared 调用 RequestPrepared 回调，没有设置时直接返回
+func (h *ClientHooks) CallRequestPrepared(ctx context.Context, req *http.Request) (context.Context, error) {
+	if h == nil || h.RequestPrepared == nil {
+		return ctx, nil
+	}
+	return h.RequestPrepared(ctx, req)
+}
+
+// CallResponseReceived 调用 ResponseReceived 回调，没有设置时直接返回
+func (h *ClientHooks) CallResponseReceived(ctx context.Context) {
+	if h == nil || h.ResponseReceived == nil {
+		return
+	}
+	h.ResponseReceived(ctx)
+}
+
+// CallError 调用 Error 回调，没有设置时直接返回
+func (h *ClientHooks) CallError(ctx context.Context, err Error) {
+	if h == nil || h.Error == nil {
+		return
+	}
+	h.Error(ctx, err)
+}
+
+// ChainClientHooks 按顺序组合多个 ClientHooks，RequestPrepared 返回错误时不再调用后面的回调
+func ChainClientHooks(hooks ...*ClientHooks) *ClientHooks {
+	if len(hooks) == 0 {
+		return nil
+	}
+	if len(hooks) == 1 {
+		return hooks[0]
+	}
+	return &ClientHooks{
+		RequestPrepared: func(ctx context.Context, req *http.Request) (context.Context, error) {
+			var err error
+			for _, h := range hooks {
+				if ctx, err = h.CallRequestPrepared(ctx, req); err != nil {
+					return ctx, err
+				}
+			}
+			return ctx, nil
+		},
+		ResponseReceived: func(ctx context.Context) {
+			for _, h := range hooks {
+				h.CallResponseReceived(ctx)
+			}
+		},
+		Error: func(ctx context.Context, err Error) {
+			for _, h := range hooks {
+				h.CallError(ctx, err)
+			}
+		},
+	}
+}
+
+// clientCall 记录一次调用，结束时根据结果调用 ResponseReceived 或者 Error
+type clientCall struct {
+	hooks *ClientHooks
+	ctx   context.Context
+}
+
+func newClientCall(ctx context.Context) *clientCall {
+	return &clientCall{hooks: DefaultClientHooks, ctx: ctx}
+}
+
+// prepare 调用 RequestPrepared，返回使用新 ctx 的请求
+func (c *clientCall) prepare(req *http.Request) (*http.Request, error) {
+	ctx, err := c.hooks.CallRequestPrepared(c.ctx, req)
+	if err != nil {
+		return req, err
+	}
+	c.ctx = ctx
+	return req.WithContext(ctx), nil
+}
+
+func (c *clientCall) done(err error) {
+	if err == nil {
+		c.hooks.CallResponseReceived(c.ctx)
+		return
+	}
+	twerr, ok := err.(Error)
+	if !ok {
+		twerr = InternalErrorWith(err)
+	}
+	c.hooks.CallError(c.ctx, twerr)
+}
+
+// transportError 发送请求失败，没有收到下游的响应
+type transportError struct {
+	cause error
+}
+
+func (e *transportError) Error() string { return e.cause.Error() }
+func (e *transportError) Cause() error  { return e.cause }
+
+// IsTransportError 判断 err 是否为发送请求失败，如连接失败、超时、熔断等，
+// 这类错误下游服务端统计不到，需要通过客户端的监控发现
+func IsTransportError(err error) bool {
+	for err != nil {
+		if _, ok := err.(*transportError); ok {
+			return true
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = cause.Cause()
+	}
+	return false
+}
+
+// ClientMethod 返回生成的客户端设置在 ctx 中的下游服务和方法，
+// service 为 package.Service 形式，不是客户端调用时返回空字符串
+func ClientMethod(ctx context.Context) (service, method string) {
+	service, _ = ServiceName(ctx)
+	if pkg, ok := PackageName(ctx); ok && pkg != "" && service != "" {
+		service = pkg + "." + service
+	}
+	method, _ = MethodName(ctx)
+	return service, method
+}
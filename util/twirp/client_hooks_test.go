@@ -0,0 +1,93 @@
+package twirp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestClientHooks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "1" {
+			t.Error("RequestPrepared should be able to set headers")
+		}
+		if r.URL.Path == "/missing" {
+			(*ServerHooks)(nil).WriteError(r.Context(), w, NotFoundError("missing"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`"pong"`))
+	}))
+	defer ts.Close()
+
+	var events []string
+	var service, method string
+	DefaultClientHooks = &ClientHooks{
+		RequestPrepared: func(ctx context.Context, req *http.Request) (context.Context, error) {
+			req.Header.Set("X-Test", "1")
+			service, method = ClientMethod(ctx)
+			return ctx, nil
+		},
+		ResponseReceived: func(ctx context.Context) { events = append(events, "ok") },
+		Error: func(ctx context.Context, err Error) {
+			if IsTransportError(err) {
+				events = append(events, "transport")
+				return
+			}
+			events = append(events, string(err.Code()))
+		},
+	}
+	defer func() { DefaultClientHooks = nil }()
+
+	ctx := WithPackageName(context.Background(), "foo.v1")
+	ctx = WithServiceName(ctx, "Echo")
+	ctx = WithMethodName(ctx, "Hello")
+	out := new(wrapperspb.StringValue)
+
+	if err := DoJSONRequest(ctx, http.DefaultClient, ts.URL+"/hello", wrapperspb.String("ping"), out); err != nil {
+		t.Fatal(err)
+	}
+	if service != "foo.v1.Echo" || method != "Hello" {
+		t.Errorf("ClientMethod = %s, %s", service, method)
+	}
+	_ = DoJSONRequest(ctx, http.DefaultClient, ts.URL+"/missing", wrapperspb.String("ping"), out)
+	_ = DoProtobufRequest(ctx, http.DefaultClient, "http://127.0.0.1:0/hello", wrapperspb.String("ping"), out)
+
+	want := []string{"ok", "not_found", "transport"}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events = %v, want %v", events, want)
+		}
+	}
+}
+
+func TestClientHooksReject(t *testing.T) {
+	called := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer ts.Close()
+
+	rejected := errors.New("rejected")
+	var got Error
+	DefaultClientHooks = ChainClientHooks(
+		&ClientHooks{RequestPrepared: func(ctx context.Context, req *http.Request) (context.Context, error) {
+			return ctx, rejected
+		}},
+		&ClientHooks{Error: func(ctx context.Context, err Error) { got = err }},
+	)
+	defer func() { DefaultClientHooks = nil }()
+
+	err := DoProtobufRequest(context.Background(), http.DefaultClient, ts.URL, wrapperspb.String("ping"), new(wrapperspb.StringValue))
+	if err != rejected || called {
+		t.Errorf("err = %v, called = %v, request should not be sent", err, called)
+	}
+	if got == nil || IsTransportError(got) {
+		t.Errorf("Error hook should receive the rejection, got %v", got)
+	}
+}
@@ -0,0 +1,204 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// Codec 请求和响应的编码格式
+//
+// 生成的服务端代码内置了 JSON、protobuf 和表单的处理逻辑，其他 Content-Type
+// 的请求按照 Content-Type 查找注册的 Codec 解析，响应使用相同的 Codec 编码；
+// 生成的 New{Service}CodecClient 使用指定的 Codec 发送请求。
+// 新增 msgpack、cbor 等格式只需要实现 Codec 并调用 RegisterCodec，不需要修改生成器。
+type Codec interface {
+	// Name 编码名称，如 msgpack
+	Name() string
+	// ContentType 请求和响应的 Content-Type，如 application/msgpack
+	ContentType() string
+	Marshal(m proto.Message) ([]byte, error)
+	Unmarshal(b []byte, m proto.Message) error
+}
+
+var (
+	// JSONCodec 内置的 JSON 编码，与 JSON 客户端的编码相同
+	JSONCodec Codec = jsonCodec{}
+	// ProtobufCodec 内置的 protobuf 编码
+	ProtobufCodec Codec = protobufCodec{}
+	// PrototextCodec 内置的 protobuf 文本编码，Content-Type 为 text/plain; format=prototext
+	//
+	// 主要用于排查问题时使用 curl 直接调用接口，嵌套较深的 message 不需要手写 JSON：
+	//
+	//	curl -H 'Content-Type: text/plain; format=prototext' -d 'message: "hi" user { id: 1 }' ...
+	PrototextCodec Codec = prototextCodec{}
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(JSONCodec)
+	RegisterCodec(ProtobufCodec)
+	RegisterCodec(PrototextCodec)
+}
+
+// RegisterCodec 按照 Content-Type 注册 Codec，重复注册时后注册的覆盖先注册的
+//
+// 一般在 init 中调用。application/json 和 application/protobuf 请求由生成的代码直接处理，
+// 覆盖这两个 Codec 只影响 LookupCodec 和 Codec 客户端。
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[normalizeContentType(c.ContentType())] = c
+}
+
+// LookupCodec 按照 Content-Type 查找 Codec，忽略大小写和 charset 等参数
+//
+// format 参数用于区分同一 Content-Type 下的不同编码，如 text/plain; format=prototext，查找时不会忽略。
+func LookupCodec(contentType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[normalizeContentType(contentType)]
+	return c, ok
+}
+
+func normalizeContentType(contentType string) string {
+	i := strings.Index(contentType, ";")
+	if i == -1 {
+		return strings.TrimSpace(strings.ToLower(contentType))
+	}
+	mt := strings.TrimSpace(strings.ToLower(contentType[:i]))
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["format"] != "" {
+		return mt + "; format=" + strings.ToLower(params["format"])
+	}
+	return mt
+}
+
+// CodecMarshal 返回使用 c 编码响应的 MarshalFunc
+func CodecMarshal(c Codec) MarshalFunc {
+	return func(m proto.Message) ([]byte, string, error) {
+		b, err := c.Marshal(m)
+		if err != nil {
+			return nil, "", wrapErr(err, "failed to marshal "+c.Name()+" response")
+		}
+		return b, c.ContentType(), nil
+	}
+}
+
+// DoCodecRequest 使用 c 编码请求和解析响应，Codec 客户端使用
+func DoCodecRequest(ctx context.Context, client HTTPClient, c Codec, url string, in, out proto.Message) (err error) {
+	reqBodyBytes, err := c.Marshal(in)
+	if err != nil {
+		return clientError("failed to marshal "+c.Name()+" request", err)
+	}
+	if err = ctx.Err(); err != nil {
+		return clientError("aborted because context was done", err)
+	}
+
+	call := newClientCall(ctx)
+	defer func() { call.done(err) }()
+
+	req, err := newRequest(ctx, url, bytes.NewReader(reqBodyBytes), c.ContentType())
+	if err != nil {
+		return clientError("could not build request", err)
+	}
+	if req, err = call.prepare(req); err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return clientError("failed to do request", &transportError{cause: err})
+	}
+
+	defer func() {
+		cerr := resp.Body.Close()
+		if err == nil && cerr != nil {
+			err = clientError("failed to close response body", cerr)
+		}
+	}()
+
+	if err = ctx.Err(); err != nil {
+		return clientError("aborted because context was done", err)
+	}
+
+	// 返回 google.protobuf.Empty 的接口可能输出 204，out 保持为空
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if resp.StatusCode != 200 {
+		return errorFromResponse(resp)
+	}
+
+	respBodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return clientError("failed to read response body", err)
+	}
+	if err = ctx.Err(); err != nil {
+		return clientError("aborted because context was done", err)
+	}
+
+	// 服务端不支持请求的编码时可能返回其他格式，按照响应的 Content-Type 解析
+	codec := c
+	if ct := resp.Header.Get("Content-Type"); ct != "" && normalizeContentType(ct) != normalizeContentType(c.ContentType()) {
+		if rc, ok := LookupCodec(ct); ok {
+			codec = rc
+		}
+	}
+	if err = codec.Unmarshal(respBodyBytes, out); err != nil {
+		return clientError("failed to unmarshal "+codec.Name()+" response", err)
+	}
+	return nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return "json" }
+func (jsonCodec) ContentType() string { return "application/json" }
+
+func (jsonCodec) Marshal(m proto.Message) ([]byte, error) {
+	return jsonMarshaler.Marshal(proto.MessageV2(m))
+}
+
+func (jsonCodec) Unmarshal(b []byte, m proto.Message) error {
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true, AnyResolver: AnyResolver}
+	return unmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return "application/protobuf" }
+
+func (protobufCodec) Marshal(m proto.Message) ([]byte, error) {
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(b []byte, m proto.Message) error {
+	return proto.Unmarshal(b, m)
+}
+
+type prototextCodec struct{}
+
+func (prototextCodec) Name() string        { return "prototext" }
+func (prototextCodec) ContentType() string { return "text/plain; format=prototext" }
+
+func (prototextCodec) Marshal(m proto.Message) ([]byte, error) {
+	marshaler := prototext.MarshalOptions{Multiline: true, Resolver: AnyResolver}
+	return marshaler.Marshal(proto.MessageV2(m))
+}
+
+func (prototextCodec) Unmarshal(b []byte, m proto.Message) error {
+	unmarshaler := prototext.UnmarshalOptions{Resolver: AnyResolver}
+	return unmarshaler.Unmarshal(b, proto.MessageV2(m))
+}
@@ -0,0 +1,96 @@
+package twirp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// textCodec 测试用的编码，只支持 StringValue
+type textCodec struct{}
+
+func (textCodec) Name() string        { return "text" }
+func (textCodec) ContentType() string { return "application/x-test-text" }
+
+func (textCodec) Marshal(m proto.Message) ([]byte, error) {
+	return []byte(m.(*wrapperspb.StringValue).GetValue()), nil
+}
+
+func (textCodec) Unmarshal(b []byte, m proto.Message) error {
+	m.(*wrapperspb.StringValue).Value = string(b)
+	return nil
+}
+
+func TestLookupCodec(t *testing.T) {
+	RegisterCodec(textCodec{})
+
+	for contentType, want := range map[string]string{
+		"application/json; charset=utf-8":             "json",
+		"Application/Protobuf":                        "protobuf",
+		" application/x-test-text ":                   "text",
+		"text/plain; format=prototext":                "prototext",
+		"Text/Plain; charset=utf-8; Format=PROTOTEXT": "prototext",
+	} {
+		c, ok := LookupCodec(contentType)
+		if !ok || c.Name() != want {
+			t.Errorf("LookupCodec(%q) = %v %v, want %s", contentType, c, ok, want)
+		}
+	}
+	if _, ok := LookupCodec("application/x-www-form-urlencoded"); ok {
+		t.Error("form should not be registered as codec")
+	}
+	if _, ok := LookupCodec("text/plain"); ok {
+		t.Error("text/plain without format should not be registered as codec")
+	}
+}
+
+func TestDoCodecRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		codec, ok := LookupCodec(r.Header.Get("Content-Type"))
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		in := new(wrapperspb.StringValue)
+		_ = codec.Unmarshal(b, in)
+
+		w.Header().Set("Content-Type", codec.ContentType())
+		out, _ := codec.Marshal(wrapperspb.String("hello " + in.GetValue()))
+		_, _ = w.Write(out)
+	}))
+	defer ts.Close()
+
+	for _, c := range []Codec{textCodec{}, jsonCodec{}, protobufCodec{}, prototextCodec{}} {
+		out := new(wrapperspb.StringValue)
+		err := DoCodecRequest(context.Background(), http.DefaultClient, c, ts.URL, wrapperspb.String(c.Name()), out)
+		if err != nil || out.GetValue() != "hello "+c.Name() {
+			t.Errorf("%s: out = %v, err = %v", c.Name(), out, err)
+		}
+	}
+}
+
+func TestPrototextCodec(t *testing.T) {
+	// prototext 故意输出不稳定的空白，只检查能否解析回来
+	b, err := PrototextCodec.Marshal(wrapperspb.String("hello"))
+	if err != nil || !strings.Contains(string(b), `"hello"`) {
+		t.Fatalf("Marshal = %q, %v", b, err)
+	}
+
+	out := new(wrapperspb.StringValue)
+	if err := PrototextCodec.Unmarshal(b, out); err != nil || out.GetValue() != "hello" {
+		t.Errorf("Unmarshal(%q) = %v, %v", b, out, err)
+	}
+	if err := PrototextCodec.Unmarshal([]byte(`value: "world"`), out); err != nil || out.GetValue() != "world" {
+		t.Errorf("Unmarshal = %v, %v", out, err)
+	}
+	if err := PrototextCodec.Unmarshal([]byte(`foo: 1`), out); err == nil {
+		t.Error("unknown field should be rejected")
+	}
+}
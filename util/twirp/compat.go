@@ -0,0 +1,16 @@
+package twirp
+
+import "context"
+
+// WithTwitchCompat 按照官方 twirp 的协议输出错误，由 compat=twitch 生成的代码调用
+//
+// 错误总是使用 {"code":"...","msg":"...","meta":{...}} 格式，即使开启了 @problem 也不会返回
+// RFC 7807 格式；客户端断开连接时的状态码与官方一致为 408，而不是 StatusClientClosedRequest。
+func WithTwitchCompat(ctx context.Context) context.Context {
+	return context.WithValue(ctx, twitchCompatKey, true)
+}
+
+func twitchCompat(ctx context.Context) bool {
+	compat, _ := ctx.Value(twitchCompatKey).(bool)
+	return compat
+}
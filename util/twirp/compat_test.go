@@ -0,0 +1,37 @@
+package twirp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWriteErrorTwitchCompat(t *testing.T) {
+	req := httptest.NewRequest("POST", "/twirp/echo.Echo/Hello", nil)
+	req.Header.Set("Accept", ProblemContentType)
+	ctx := WithTwitchCompat(WithProblemDetails(WithHttpRequest(context.Background(), req)))
+
+	resp := httptest.NewRecorder()
+	(&ServerHooks{}).WriteError(ctx, resp, NotFoundError("no such user").WithMeta("uid", "1"))
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"code": "not_found", "msg": "no such user", "meta": map[string]interface{}{"uid": "1"}}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	resp = httptest.NewRecorder()
+	(&ServerHooks{}).WriteError(canceled, resp, context.Canceled)
+	if resp.Code != 408 {
+		t.Errorf("status of canceled request = %d, want 408", resp.Code)
+	}
+}
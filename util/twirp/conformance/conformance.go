@@ -0,0 +1,203 @@
+// Package conformance 检查 twirp 服务是否遵循 sniper 的 twirp 协议
+//
+// 生成的服务和其他实现（比如迁移期间手写的适配代码）都可以使用：
+//
+//	func TestEchoConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Config{
+//			Server:      echo_v1.NewEchoServer(&EchoServer{}, nil),
+//			Prefix:      echo_v1.EchoPathPrefix,
+//			Method:      "Hello",
+//			PanicMethod: "Panic",
+//		})
+//	}
+//
+// 检查的内容包括支持的 Content-Type、错误响应的格式、未知路径、GET 请求以及
+// 接口 panic 时的响应，协议细节参考 PROTOCOL.md。
+package conformance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sniper/util/twirp"
+)
+
+// Config 检查配置
+type Config struct {
+	// Server 被检查的服务
+	Server twirp.Server
+	// Prefix 服务的路径前缀，一般为生成代码中的 {Service}PathPrefix
+	Prefix string
+	// Method 用于检查编解码的接口方法名，如 Hello
+	// 请求为空消息，接口可以返回成功或者 twirp 错误
+	Method string
+	// PanicMethod 服务实现中总是 panic 的接口方法名，为空表示不检查
+	PanicMethod string
+	// AllowGET 服务是否通过 hooks 开启了 GET 请求
+	AllowGET bool
+}
+
+// Run 执行所有检查，每项检查是一个子测试
+func Run(t *testing.T, c Config) {
+	if c.Server == nil || c.Method == "" {
+		t.Fatal("conformance: Server and Method are required")
+	}
+
+	t.Run("ServiceDescriptor", func(t *testing.T) { checkDescriptor(t, c) })
+	t.Run("JSON", func(t *testing.T) {
+		resp := serve(c.Server, http.MethodPost, c.Prefix+c.Method, "application/json", "{}")
+		checkResponse(t, resp, "application/json")
+	})
+	t.Run("JSONWithCharset", func(t *testing.T) {
+		resp := serve(c.Server, http.MethodPost, c.Prefix+c.Method, "application/json; charset=utf-8", "{}")
+		checkResponse(t, resp, "application/json")
+	})
+	t.Run("Protobuf", func(t *testing.T) {
+		resp := serve(c.Server, http.MethodPost, c.Prefix+c.Method, "application/protobuf", "")
+		checkResponse(t, resp, "application/protobuf")
+	})
+	t.Run("MalformedJSON", func(t *testing.T) {
+		resp := serve(c.Server, http.MethodPost, c.Prefix+c.Method, "application/json", "{")
+		checkError(t, resp, twirp.InvalidArgument)
+	})
+	t.Run("MalformedProtobuf", func(t *testing.T) {
+		// 字段编号为 0 的 tag 是非法的
+		resp := serve(c.Server, http.MethodPost, c.Prefix+c.Method, "application/protobuf", "\x00")
+		checkError(t, resp, twirp.InvalidArgument)
+	})
+	t.Run("BadRoute", func(t *testing.T) {
+		resp := serve(c.Server, http.MethodPost, c.Prefix+"NoSuchMethod", "application/json", "{}")
+		checkError(t, resp, twirp.BadRoute)
+	})
+	t.Run("GET", func(t *testing.T) {
+		resp := serve(c.Server, http.MethodGet, c.Prefix+c.Method, "", "")
+		if c.AllowGET {
+			checkResponse(t, resp, "application/json")
+		} else {
+			checkError(t, resp, twirp.BadRoute)
+		}
+	})
+	if c.PanicMethod != "" {
+		t.Run("Panic", func(t *testing.T) { checkPanic(t, c) })
+	}
+}
+
+func serve(h http.Handler, method, url, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// checkResponse 检查成功响应的 Content-Type，失败时检查错误格式
+func checkResponse(t *testing.T, resp *httptest.ResponseRecorder, contentType string) {
+	t.Helper()
+
+	if resp.Code != http.StatusOK {
+		checkErrorShape(t, resp)
+		return
+	}
+
+	if ct := resp.Header().Get("Content-Type"); ct != contentType {
+		t.Errorf("Content-Type = %q, want %q", ct, contentType)
+	}
+	if contentType == "application/json" && !json.Valid(resp.Body.Bytes()) {
+		t.Errorf("response is not valid json: %s", resp.Body.String())
+	}
+}
+
+// checkError 检查错误响应的错误码
+func checkError(t *testing.T, resp *httptest.ResponseRecorder, code twirp.ErrorCode) {
+	t.Helper()
+
+	if have := checkErrorShape(t, resp); have != "" && have != code {
+		t.Errorf("error code = %q, want %q", have, code)
+	}
+}
+
+// checkErrorShape 检查错误响应的格式，返回错误码
+//
+// 错误响应总是 JSON，格式为 {"code": "...", "msg": "...", "meta": {...}}，
+// http 状态码与错误码一一对应。
+func checkErrorShape(t *testing.T, resp *httptest.ResponseRecorder) twirp.ErrorCode {
+	t.Helper()
+
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type of error = %q, want application/json", ct)
+	}
+
+	var body struct {
+		Code *string           `json:"code"`
+		Msg  *string           `json:"msg"`
+		Meta map[string]string `json:"meta"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Errorf("invalid error body: %v\n%s", err, resp.Body.String())
+		return ""
+	}
+	if body.Code == nil || body.Msg == nil {
+		t.Errorf("error body must have code and msg: %s", resp.Body.String())
+		return ""
+	}
+
+	code := twirp.ErrorCode(*body.Code)
+	if !twirp.IsValidErrorCode(code) {
+		t.Errorf("invalid error code %q", code)
+		return ""
+	}
+	if want := twirp.ServerHTTPStatusFromErrorCode(code); resp.Code != want {
+		t.Errorf("status of %s = %d, want %d", code, resp.Code, want)
+	}
+	return code
+}
+
+// checkPanic 接口 panic 时返回 internal 错误，然后继续 panic 由 http.Server 处理
+func checkPanic(t *testing.T, c Config) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, c.Prefix+c.PanicMethod, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+
+	panicked := func() (panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		c.Server.ServeHTTP(w, req)
+		return
+	}()
+
+	if !panicked {
+		t.Error("panic of the implementation should be re-raised")
+	}
+	checkError(t, w, twirp.Internal)
+}
+
+// checkDescriptor 检查服务描述和生成器版本
+func checkDescriptor(t *testing.T, c Config) {
+	if v := c.Server.ProtocGenTwirpVersion(); v == "" {
+		t.Error("ProtocGenTwirpVersion is empty")
+	}
+
+	b, index := c.Server.ServiceDescriptor()
+	if index < 0 {
+		t.Errorf("invalid service index %d", index)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("ServiceDescriptor is not gzipped: %v", err)
+	}
+	if b, err = ioutil.ReadAll(r); err != nil {
+		t.Fatalf("ServiceDescriptor is not gzipped: %v", err)
+	}
+	if len(b) == 0 {
+		t.Error("ServiceDescriptor is empty")
+	}
+}
@@ -0,0 +1,82 @@
+package conformance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sniper/util/twirp"
+)
+
+// fakeServer 按照协议手写的服务，只有 Hello 和 Panic 两个方法
+type fakeServer struct {
+	hooks *twirp.ServerHooks
+}
+
+func (s *fakeServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	ctx := context.Background()
+	if req.Method != http.MethodPost {
+		msg := fmt.Sprintf("unsupported method %q (only POST is allowed)", req.Method)
+		s.hooks.WriteError(ctx, resp, twirp.NewError(twirp.BadRoute, msg))
+		return
+	}
+
+	switch req.URL.Path {
+	case "/echo.Echo/Hello":
+	case "/echo.Echo/Panic":
+		defer func() {
+			if r := recover(); r != nil {
+				s.hooks.WriteError(ctx, resp, twirp.InternalError("Internal service panic"))
+				panic(r)
+			}
+		}()
+		panic("boom")
+	default:
+		s.hooks.WriteError(ctx, resp, twirp.NewError(twirp.BadRoute, "no handler for path "+req.URL.Path))
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	ct := req.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(ct, "application/json"):
+		if string(body) != "{}" {
+			s.hooks.WriteError(ctx, resp, twirp.NewError(twirp.InvalidArgument, "failed to parse request json"))
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		_, _ = resp.Write([]byte(`{"message":""}`))
+	case ct == "application/protobuf":
+		if len(body) > 0 {
+			s.hooks.WriteError(ctx, resp, twirp.NewError(twirp.InvalidArgument, "failed to parse request proto"))
+			return
+		}
+		resp.Header().Set("Content-Type", "application/protobuf")
+	default:
+		s.hooks.WriteError(ctx, resp, twirp.NewError(twirp.BadRoute, "unexpected Content-Type"))
+	}
+}
+
+func (s *fakeServer) ServiceDescriptor() ([]byte, int) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, _ = w.Write([]byte("descriptor"))
+	_ = w.Close()
+	return buf.Bytes(), 0
+}
+
+func (s *fakeServer) ProtocGenTwirpVersion() string { return "v0.1.0" }
+
+func TestRun(t *testing.T) {
+	Run(t, Config{
+		Server:      &fakeServer{hooks: &twirp.ServerHooks{}},
+		Prefix:      "/echo.Echo/",
+		Method:      "Hello",
+		PanicMethod: "Panic",
+	})
+}
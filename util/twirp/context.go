@@ -35,6 +35,30 @@ const (
 	ResponseKey
 	AllowGETKey
 	MethodOptionKey
+	FlagCheckerKey
+	ExperimentClientKey
+	ResponseHeaderKey
+	ServerTimingKey
+	ShadowerKey
+	CanaryKey
+	ExplorerKey
+	UnknownFieldsKey
+	InternalCheckerKey
+	DeprecatedFieldsKey
+	LogBodyKey
+	QuotaCheckerKey
+	CipherKey
+	encryptResponseKey
+	PriorityKey
+	ProblemDetailsKey
+	DedupKey
+	DeprecatedCallKey
+	MemoryAccountKey
+	gzipResponseKey
+	chunkedResponseKey
+	responseContentTypeKey
+	pathParamsKey
+	twitchCompatKey
 )
 
 // MethodName extracts the name of the method being handled in the given
@@ -79,6 +103,12 @@ func HttpRequest(ctx context.Context) (*http.Request, bool) {
 	return req, ok
 }
 
+// ResponseWriter 返回当前请求的 http.ResponseWriter，流式响应使用
+func ResponseWriter(ctx context.Context) (http.ResponseWriter, bool) {
+	w, ok := ctx.Value(ResponseWriterKey).(http.ResponseWriter)
+	return w, ok
+}
+
 // Request 返回解析后的请求对象
 func Request(ctx context.Context) (proto.Message, bool) {
 	req, ok := ctx.Value(RequestKey).(proto.Message)
@@ -0,0 +1,122 @@
+// Package contract 回放录制的请求，检查生成的 twirp 服务响应是否发生变化
+//
+// 用例保存在 JSON 文件中，每个文件一个用例：
+//
+//	{
+//	  "method": "Hello",
+//	  "header": {"Content-Type": "application/json"},
+//	  "request": "{\"message\":\"hello\"}",
+//	  "status": 200,
+//	  "response": "{\"msg\":\"hello\"}"
+//	}
+//
+// 设置环境变量 CONTRACT_UPDATE=1 运行测试会把实际的响应写回用例文件，
+// 用于录制新的用例或者确认变更之后更新用例。
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// UpdateEnv 设置该环境变量后将实际响应写回用例文件
+const UpdateEnv = "CONTRACT_UPDATE"
+
+// Case 一个请求用例
+type Case struct {
+	// Method 接口方法名，如 Hello
+	Method string `json:"method"`
+	// Header 请求头，默认 Content-Type 为 application/json
+	Header map[string]string `json:"header,omitempty"`
+	// Request 请求体
+	Request string `json:"request"`
+	// Status 期望的 http 状态码
+	Status int `json:"status"`
+	// Response 期望的响应体，JSON 响应会忽略格式和字段顺序的差异
+	Response string `json:"response"`
+}
+
+// Run 回放 dir 目录下所有的 *.json 用例
+//
+// prefix 为服务的路径前缀，一般为生成代码中的 {Service}PathPrefix
+func Run(t *testing.T, handler http.Handler, prefix, dir string) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skipf("no contract cases in %s", dir)
+	}
+
+	update := os.Getenv(UpdateEnv) != ""
+	for _, file := range files {
+		file := file
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		t.Run(name, func(t *testing.T) {
+			runFile(t, handler, prefix, file, update)
+		})
+	}
+}
+
+func runFile(t *testing.T, handler http.Handler, prefix, file string, update bool) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c Case
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatalf("invalid case %s: %v", file, err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, prefix+c.Method, strings.NewReader(c.Request))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.Header {
+		req.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	status, body := w.Code, w.Body.String()
+
+	if update {
+		c.Status, c.Response = status, body
+		b, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, append(b, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if status != c.Status {
+		t.Errorf("status = %d, want %d\nresponse: %s", status, c.Status, body)
+	}
+	if !equalBody(body, c.Response) {
+		t.Errorf("response changed\n got: %s\nwant: %s", body, c.Response)
+	}
+}
+
+// equalBody 比较响应体，都是 JSON 时比较解析之后的值
+func equalBody(got, want string) bool {
+	if got == want {
+		return true
+	}
+
+	var g, w interface{}
+	if json.Unmarshal([]byte(got), &g) != nil || json.Unmarshal([]byte(want), &w) != nil {
+		return bytes.Equal(bytes.TrimSpace([]byte(got)), bytes.TrimSpace([]byte(want)))
+	}
+	return reflect.DeepEqual(g, w)
+}
@@ -61,3 +61,7 @@ func WithResponse(ctx context.Context, resp proto.Message) context.Context {
 func WithMethodOption(ctx context.Context, option string) context.Context {
 	return context.WithValue(ctx, MethodOptionKey, option)
 }
+
+func WithResponseHeader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ResponseHeaderKey, &responseHeader{header: make(http.Header)})
+}
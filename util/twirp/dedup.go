@@ -0,0 +1,187 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DedupReplayedHeader 重复请求返回缓存的响应时设置的响应头
+const DedupReplayedHeader = "Sniper-Dedup-Replayed"
+
+// dedupMaxBody 超过该大小的响应不缓存
+const dedupMaxBody = 1 << 20
+
+// dedupSkipHeaders 每次响应都不同的响应头，不缓存
+var dedupSkipHeaders = []string{"Date", "Server-Timing", DedupReplayedHeader}
+
+// DedupResponse 缓存的响应
+type DedupResponse struct {
+	Status int `json:"status"`
+	// Header 输出状态码时的全部响应头，包括 Content-Type、Set-Cookie 和业务设置的响应头
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// DedupStore 保存已处理请求的响应，由业务基于 redis 等存储实现，
+// 单机部署可以使用 NewMemoryDedupStore
+type DedupStore interface {
+	// Get 返回 key 对应的响应，不存在时 ok 为 false
+	Get(ctx context.Context, key string) (resp *DedupResponse, ok bool, err error)
+	// Set 保存 key 对应的响应，ttl 后过期
+	Set(ctx context.Context, key string, resp *DedupResponse, ttl time.Duration) error
+}
+
+type dedup struct {
+	store DedupStore
+	key   string
+}
+
+// WithDedup 注入请求去重使用的存储和请求标识，一般在 hook.RequestReceived 阶段调用
+//
+// key 一般为网关生成的请求 ID，重试时保持不变，为空表示不去重。
+func WithDedup(ctx context.Context, store DedupStore, key string) context.Context {
+	if store == nil || key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, DedupKey, dedup{store: store, key: key})
+}
+
+// ReplayDedup 查询 path 接口是否处理过相同的请求，处理过则直接输出缓存的响应
+//
+// 接口使用 @dedup 注解后由生成的代码调用，查询失败时按照新请求处理。
+func ReplayDedup(ctx context.Context, resp http.ResponseWriter, path string) (context.Context, bool) {
+	d, ok := ctx.Value(DedupKey).(dedup)
+	if !ok {
+		return ctx, false
+	}
+
+	cached, ok, err := d.store.Get(ctx, path+":"+d.key)
+	if err != nil || !ok {
+		return ctx, false
+	}
+
+	ctx = WithStatusCode(ctx, cached.Status)
+	WriteResponseHeader(ctx, resp)
+	for k, vs := range cached.Header {
+		resp.Header()[k] = append([]string(nil), vs...)
+	}
+	resp.Header().Set(DedupReplayedHeader, "1")
+	resp.WriteHeader(cached.Status)
+	_, _ = resp.Write(cached.Body)
+	return ctx, true
+}
+
+// RecordDedup 记录 path 接口的响应，调用返回的函数时保存到 DedupStore
+//
+// 只保存状态码小于 500 的响应，服务端错误允许重试。
+// 相同的请求并发到达时都会被处理，这里只解决响应丢失之后网关重试的问题。
+func RecordDedup(ctx context.Context, resp http.ResponseWriter, path string, ttl time.Duration) (http.ResponseWriter, func()) {
+	d, ok := ctx.Value(DedupKey).(dedup)
+	if !ok {
+		return resp, func() {}
+	}
+
+	w := &dedupWriter{ResponseWriter: resp}
+	return w, func() {
+		if w.status == 0 || w.status >= 500 || w.body.Len() > dedupMaxBody {
+			return
+		}
+		_ = d.store.Set(ctx, path+":"+d.key, &DedupResponse{
+			Status: w.status,
+			Header: w.header,
+			Body:   w.body.Bytes(),
+		}, ttl)
+	}
+}
+
+// dedupWriter 记录状态码、响应头和响应体
+type dedupWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *dedupWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.record(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *dedupWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.record(http.StatusOK)
+	}
+	if w.body.Len() <= dedupMaxBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// record 记录状态码和此时的响应头，之后修改的响应头不会输出
+func (w *dedupWriter) record(status int) {
+	w.status = status
+	w.header = w.Header().Clone()
+	for _, k := range dedupSkipHeaders {
+		w.header.Del(k)
+	}
+}
+
+// NewMemoryDedupStore 返回进程内的 DedupStore，最多保存 size 个响应，size 不大于 0 时为 1024
+//
+// 多实例部署时重试请求可能落到其他实例，需要使用 redis 等共享存储。
+func NewMemoryDedupStore(size int) DedupStore {
+	if size <= 0 {
+		size = 1024
+	}
+	return &memoryDedupStore{size: size, items: map[string]memoryDedupItem{}}
+}
+
+type memoryDedupItem struct {
+	resp     *DedupResponse
+	deadline time.Time
+}
+
+type memoryDedupStore struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]memoryDedupItem
+}
+
+func (s *memoryDedupStore) Get(ctx context.Context, key string) (*DedupResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok || time.Now().After(item.deadline) {
+		return nil, false, nil
+	}
+	return item.resp, true, nil
+}
+
+func (s *memoryDedupStore) Set(ctx context.Context, key string, resp *DedupResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.items) >= s.size {
+		// 先清理过期的响应，仍然没有空间时随机淘汰一个
+		for k, item := range s.items {
+			if now.After(item.deadline) {
+				delete(s.items, k)
+			}
+		}
+		for k := range s.items {
+			if len(s.items) < s.size {
+				break
+			}
+			delete(s.items, k)
+		}
+	}
+	s.items[key] = memoryDedupItem{resp: resp, deadline: now.Add(ttl)}
+	return nil
+}
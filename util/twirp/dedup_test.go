@@ -0,0 +1,50 @@
+package twirp
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	const path = "/order.Order/Create"
+	ctx := WithDedup(context.Background(), NewMemoryDedupStore(0), "req-1")
+
+	serve := func(status int, body string) (*httptest.ResponseRecorder, bool) {
+		resp := httptest.NewRecorder()
+		if _, replayed := ReplayDedup(ctx, resp, path); replayed {
+			return resp, true
+		}
+		w, done := RecordDedup(ctx, resp, path, time.Minute)
+		defer done()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Order-Id", body)
+		w.Header().Set("Date", time.Now().String())
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+		return resp, false
+	}
+
+	if _, replayed := serve(500, `{"code":"internal"}`); replayed {
+		t.Fatal("first request should not be replayed")
+	}
+	if _, replayed := serve(200, `{"id":1}`); replayed {
+		t.Fatal("5xx response should not be cached")
+	}
+
+	resp, replayed := serve(200, `{"id":2}`)
+	if !replayed {
+		t.Fatal("duplicated request should be replayed")
+	}
+	if resp.Code != 200 || resp.Body.String() != `{"id":1}` {
+		t.Errorf("replayed response = %d %s, want 200 {\"id\":1}", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get(DedupReplayedHeader) != "1" || resp.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected header of replayed response: %v", resp.Header())
+	}
+	// 业务设置的响应头同样回放，Date 等每次不同的响应头不缓存
+	if resp.Header().Get("X-Order-Id") != `{"id":1}` || resp.Header().Get("Date") != "" {
+		t.Errorf("unexpected header of replayed response: %v", resp.Header())
+	}
+}
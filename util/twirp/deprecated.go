@@ -0,0 +1,89 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FindDeprecatedFields 返回 m 中已赋值的废弃字段
+//
+// paths 由 protoc-gen-twirp 根据 deprecated = true 选项生成，嵌套字段使用 .
+// 连接，如 user.nick_name。只支持单个消息字段的嵌套，不检查 repeated 和 map。
+func FindDeprecatedFields(m proto.Message, paths ...string) (fields []string) {
+	msg := m.ProtoReflect()
+	for _, path := range paths {
+		if hasField(msg, path) {
+			fields = append(fields, path)
+		}
+	}
+	return
+}
+
+func hasField(msg protoreflect.Message, path string) bool {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fd := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil || !msg.Has(fd) {
+			return false
+		}
+		if i < len(names)-1 {
+			msg = msg.Get(fd).Message()
+		}
+	}
+	return true
+}
+
+// WithDeprecatedFields 记录请求中已赋值的废弃字段，由生成的代码调用
+func WithDeprecatedFields(ctx context.Context, fields []string) context.Context {
+	return context.WithValue(ctx, DeprecatedFieldsKey, fields)
+}
+
+// DeprecatedFields 返回请求中已赋值的废弃字段
+func DeprecatedFields(ctx context.Context) []string {
+	fields, _ := ctx.Value(DeprecatedFieldsKey).([]string)
+	return fields
+}
+
+// CallerHeader 传递调用方标识的请求头，生成的客户端使用 CallerName 填充
+const CallerHeader = "Sniper-Caller"
+
+// CallerName 当前服务的标识，生成的客户端通过 CallerHeader 传给下游，默认为空
+var CallerName string
+
+// callerMaxLen 调用方标识的最大长度，避免任意请求头撑大指标
+const callerMaxLen = 64
+
+// MarkDeprecated 输出废弃接口的 Warning 和 Sunset 响应头，由生成的代码调用
+//
+// 接口使用 @deprecated 注解或者 option deprecated = true 时生成，replacement
+// 为注解中的替代接口，sunset 为 @sunset 注解声明的下线时间（HTTP 日期格式），
+// 为空时不输出 Sunset 头。调用方记录在 ctx 中，由 hook 统计调用量。
+func MarkDeprecated(ctx context.Context, resp http.ResponseWriter, path, replacement, sunset string) context.Context {
+	msg := path + " is deprecated"
+	if replacement != "" {
+		msg += ", use " + replacement
+	}
+	resp.Header().Set("Warning", "299 - "+strconv.Quote(msg))
+	if sunset != "" {
+		resp.Header().Set("Sunset", sunset)
+	}
+
+	caller := "unknown"
+	if req, ok := HttpRequest(ctx); ok {
+		if c := req.Header.Get(CallerHeader); c != "" && len(c) <= callerMaxLen {
+			caller = c
+		}
+	}
+	return context.WithValue(ctx, DeprecatedCallKey, caller)
+}
+
+// DeprecatedCaller 返回废弃接口的调用方，当前接口没有废弃时 ok 为 false
+func DeprecatedCaller(ctx context.Context) (caller string, ok bool) {
+	caller, ok = ctx.Value(DeprecatedCallKey).(string)
+	return
+}
@@ -0,0 +1,43 @@
+package twirp
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMarkDeprecated(t *testing.T) {
+	const path = "/user.User/GetInfo"
+
+	req := httptest.NewRequest("POST", path, nil)
+	req.Header.Set(CallerHeader, "order")
+	ctx := WithHttpRequest(context.Background(), req)
+
+	if _, ok := DeprecatedCaller(ctx); ok {
+		t.Fatal("method should not be deprecated before MarkDeprecated")
+	}
+
+	resp := httptest.NewRecorder()
+	ctx = MarkDeprecated(ctx, resp, path, "UserService.GetProfile", "Thu, 31 Dec 2026 00:00:00 GMT")
+
+	want := `299 - "/user.User/GetInfo is deprecated, use UserService.GetProfile"`
+	if got := resp.Header().Get("Warning"); got != want {
+		t.Errorf("Warning = %s, want %s", got, want)
+	}
+	if got := resp.Header().Get("Sunset"); got != "Thu, 31 Dec 2026 00:00:00 GMT" {
+		t.Errorf("Sunset = %s", got)
+	}
+	if caller, ok := DeprecatedCaller(ctx); !ok || caller != "order" {
+		t.Errorf("caller = %s %v, want order", caller, ok)
+	}
+
+	req.Header.Del(CallerHeader)
+	resp = httptest.NewRecorder()
+	ctx = MarkDeprecated(WithHttpRequest(context.Background(), req), resp, path, "", "")
+	if got := resp.Header().Get("Sunset"); got != "" {
+		t.Errorf("Sunset should be empty, got %s", got)
+	}
+	if caller, _ := DeprecatedCaller(ctx); caller != "unknown" {
+		t.Errorf("caller = %s, want unknown", caller)
+	}
+}
@@ -42,6 +42,8 @@
 package twirp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 )
 
@@ -134,6 +136,36 @@ func InternalErrorWith(err error) Error {
 	}
 }
 
+// StatusClientClosedRequest 客户端断开连接时输出的 HTTP 状态码，与 nginx 的 499 相同
+const StatusClientClosedRequest = 499
+
+// ContextError 把 context.Canceled 和 context.DeadlineExceeded（包括被包装过的）转换为
+// Canceled 和 DeadlineExceeded 错误，err 不是 context 错误时返回 nil
+//
+// 业务方法以及 db、redis 等组件在请求取消或者超时之后返回的错误由 WriteError 自动转换，
+// 不再作为 Internal 错误计入错误率。
+func ContextError(err error) Error {
+	var code ErrorCode
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled):
+		code = Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = DeadlineExceeded
+	default:
+		return nil
+	}
+	msg := err.Error()
+	if twerr, ok := err.(Error); ok {
+		msg = twerr.Msg()
+	}
+	return &wrappedErr{
+		wrapper: NewError(code, msg),
+		cause:   err,
+	}
+}
+
 // ErrorCode represents a Twirp error type.
 type ErrorCode string
 
@@ -341,3 +373,4 @@ func (e *wrappedErr) WithMeta(key string, val string) Error {
 	}
 }
 func (e *wrappedErr) Cause() error { return e.cause }
+func (e *wrappedErr) Unwrap() error { return e.cause }
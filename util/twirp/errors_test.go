@@ -14,7 +14,9 @@
 package twirp
 
 import (
+	"context"
 	"fmt"
+	"net/http/httptest"
 	"sync"
 	"testing"
 
@@ -49,3 +51,60 @@ func TestErrorCause(t *testing.T) {
 		t.Errorf("got wrong cause for err. have=%q, want=%q", cause, rootCause)
 	}
 }
+
+func TestContextError(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		code ErrorCode
+	}{
+		{context.Canceled, Canceled},
+		{context.DeadlineExceeded, DeadlineExceeded},
+		{errors.Wrap(context.DeadlineExceeded, "redis: get"), DeadlineExceeded},
+		{fmt.Errorf("db: query: %w", context.Canceled), Canceled},
+		{clientError("aborted because context was done", context.Canceled), Canceled},
+		{fmt.Errorf("this is only a test"), NoError},
+		{nil, NoError},
+	} {
+		twerr := ContextError(c.err)
+		if twerr != nil && twerr.Msg() == "" {
+			t.Errorf("ContextError(%v) should keep the message", c.err)
+		}
+		if c.code == NoError {
+			if twerr != nil {
+				t.Errorf("ContextError(%v) = %v, want nil", c.err, twerr)
+			}
+			continue
+		}
+		if twerr == nil || twerr.Code() != c.code || !errors.Is(twerr, c.err) {
+			t.Errorf("ContextError(%v) = %v, want %s", c.err, twerr, c.code)
+		}
+	}
+}
+
+func TestWriteContextError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, c := range []struct {
+		ctx    context.Context
+		err    error
+		status int
+	}{
+		{canceled, errors.Wrap(context.Canceled, "db: query"), StatusClientClosedRequest},
+		{context.Background(), context.Canceled, 408},
+		{context.Background(), InternalErrorWith(context.DeadlineExceeded), 408},
+		{context.Background(), NewError(Unavailable, "deadline exceeded"), 503},
+		{context.Background(), fmt.Errorf("this is only a test"), 500},
+	} {
+		var hooked Error
+		hooks := &ServerHooks{Error: func(ctx context.Context, err Error) context.Context {
+			hooked = err
+			return ctx
+		}}
+		resp := httptest.NewRecorder()
+		hooks.WriteError(c.ctx, resp, c.err)
+		if resp.Code != c.status || hooked == nil || ServerHTTPStatusFromErrorCode(hooked.Code()) == 500 && c.status != 500 {
+			t.Errorf("%v: status %d, error hook got %v, want status %d", c.err, resp.Code, hooked, c.status)
+		}
+	}
+}
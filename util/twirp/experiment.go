@@ -0,0 +1,43 @@
+package twirp
+
+import "context"
+
+// ExperimentClient 实验分桶接口
+//
+// 接口使用 @experiment:name 注解后，生成的代码会在调用业务方法之前查询分桶，
+// 业务代码通过 ExperimentBucket 获取结果。
+type ExperimentClient interface {
+	// Bucket 返回当前请求（用户）在实验 exp 中的分桶
+	Bucket(ctx context.Context, exp string) (string, error)
+}
+
+type experimentKey string
+
+// WithExperimentClient 注入实验分桶客户端，一般在 hook.RequestReceived 阶段调用
+func WithExperimentClient(ctx context.Context, client ExperimentClient) context.Context {
+	return context.WithValue(ctx, ExperimentClientKey, client)
+}
+
+// WithExperiment 查询实验 exp 的分桶并写入 ctx
+//
+// 没有注入 ExperimentClient 或者查询失败都不会写入分桶，
+// 此时 ExperimentBucket 返回 ("", false)，业务代码应按对照组处理。
+func WithExperiment(ctx context.Context, exp string) context.Context {
+	client, ok := ctx.Value(ExperimentClientKey).(ExperimentClient)
+	if !ok {
+		return ctx
+	}
+
+	bucket, err := client.Bucket(ctx, exp)
+	if err != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, experimentKey(exp), bucket)
+}
+
+// ExperimentBucket 返回当前请求在实验 exp 中的分桶
+func ExperimentBucket(ctx context.Context, exp string) (string, bool) {
+	bucket, ok := ctx.Value(experimentKey(exp)).(string)
+	return bucket, ok
+}
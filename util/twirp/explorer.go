@@ -0,0 +1,126 @@
+package twirp
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+)
+
+// ExplorerMethod 接口调试页面展示的方法信息
+type ExplorerMethod struct {
+	// Name 方法名
+	Name string
+	// Comment 方法注释
+	Comment string
+	// Example 请求示例，JSON 格式
+	Example string
+}
+
+// WithExplorer 开启接口调试页面，一般在 hook.RequestReceived 阶段调用
+//
+// 调试页面可以直接调用接口，请勿在生产环境中开启
+func WithExplorer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ExplorerKey, true)
+}
+
+// ExplorerEnabled 判断是否开启了接口调试页面
+func ExplorerEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(ExplorerKey).(bool)
+	return enabled
+}
+
+// ServeExplorer 输出接口调试页面，由生成的代码调用
+func ServeExplorer(resp http.ResponseWriter, service string, methods []ExplorerMethod) {
+	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	explorerTemplate.Execute(resp, struct {
+		Service string
+		Methods []ExplorerMethod
+	}{service, methods})
+}
+
+var explorerTemplate = template.Must(template.New("explorer").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Service}}</title>
+<style>
+body { font-family: sans-serif; margin: 0; display: flex; height: 100vh; }
+nav { width: 240px; border-right: 1px solid #ddd; overflow: auto; }
+nav a { display: block; padding: 8px 12px; color: #333; text-decoration: none; }
+nav a.active { background: #eef; }
+main { flex: 1; padding: 12px 24px; overflow: auto; }
+textarea, pre { width: 100%; box-sizing: border-box; font-family: monospace; }
+textarea { height: 240px; }
+pre { background: #f6f6f6; padding: 8px; min-height: 120px; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<nav>
+<h3 style="padding: 0 12px">{{.Service}}</h3>
+{{range .Methods}}<a href="#{{.Name}}" data-method="{{.Name}}" data-example="{{.Example}}" title="{{.Comment}}">{{.Name}}</a>
+{{end}}</nav>
+<main>
+<h2 id="name"></h2>
+<p id="comment"></p>
+<p>
+<label><input type="radio" name="type" value="json" checked> JSON</label>
+<label><input type="radio" name="type" value="form"> Form</label>
+<button id="send">Send</button>
+</p>
+<textarea id="body"></textarea>
+<p id="status"></p>
+<pre id="resp"></pre>
+</main>
+<script>
+var current = "";
+function select(a) {
+  document.querySelectorAll("nav a").forEach(function (e) { e.className = ""; });
+  a.className = "active";
+  current = a.dataset.method;
+  document.getElementById("name").textContent = current;
+  document.getElementById("comment").textContent = a.title;
+  document.getElementById("body").value = JSON.stringify(JSON.parse(a.dataset.example), null, 2);
+}
+function toForm(obj) {
+  var params = new URLSearchParams();
+  Object.keys(obj).forEach(function (k) {
+    var v = obj[k];
+    params.append(k, Array.isArray(v) ? v.join(",") : (typeof v === "object" ? JSON.stringify(v) : v));
+  });
+  return params.toString();
+}
+document.querySelectorAll("nav a").forEach(function (a) {
+  a.onclick = function () { select(a); };
+  if (location.hash === "#" + a.dataset.method || !current) { select(a); }
+});
+document.getElementById("send").onclick = function () {
+  var status = document.getElementById("status"), out = document.getElementById("resp");
+  var body, type;
+  try {
+    body = JSON.parse(document.getElementById("body").value || "{}");
+  } catch (e) {
+    status.textContent = "invalid json: " + e.message;
+    return;
+  }
+  if (document.querySelector("input[name=type]:checked").value === "form") {
+    type = "application/x-www-form-urlencoded";
+    body = toForm(body);
+  } else {
+    type = "application/json";
+    body = JSON.stringify(body);
+  }
+  var start = Date.now();
+  fetch(current, { method: "POST", headers: { "Content-Type": type }, body: body }).then(function (r) {
+    status.textContent = r.status + " " + r.statusText + " (" + (Date.now() - start) + "ms)";
+    return r.text();
+  }).then(function (text) {
+    try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) {}
+    out.textContent = text;
+  }).catch(function (e) {
+    status.textContent = e.message;
+  });
+};
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,24 @@
+package twirp
+
+import "context"
+
+// FlagChecker 功能开关检查接口
+//
+// 接口使用 @flag:name 注解后，生成的代码会在调用业务方法之前调用 Enabled，
+// 开关关闭则直接返回 Unimplemented 错误。
+type FlagChecker interface {
+	// Enabled 判断开关 flag 是否对当前请求（用户）打开
+	Enabled(ctx context.Context, flag string) bool
+}
+
+// WithFlagChecker 注入功能开关检查对象，一般在 hook.RequestReceived 阶段调用
+func WithFlagChecker(ctx context.Context, checker FlagChecker) context.Context {
+	return context.WithValue(ctx, FlagCheckerKey, checker)
+}
+
+// FlagEnabled 判断功能开关是否打开
+// 如果 ctx 中没有注入 FlagChecker，则认为所有开关都是关闭的
+func FlagEnabled(ctx context.Context, flag string) bool {
+	checker, ok := ctx.Value(FlagCheckerKey).(FlagChecker)
+	return ok && checker.Enabled(ctx, flag)
+}
@@ -0,0 +1,16 @@
+package twirp
+
+import "net/url"
+
+// FormValues 按顺序查找表单参数，返回第一个存在的参数值
+//
+// 生成的表单解析代码使用字段原名、camelCase 名以及 @alias 别名依次查找，
+// 字段原名优先。
+func FormValues(form url.Values, keys ...string) ([]string, bool) {
+	for _, key := range keys {
+		if v, ok := form[key]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,47 @@
+package twirp
+
+// 生成的 {file}.grpc.go 把服务挂载到 *grpc.Server 上，返回的 twirp.Error 转换为 gRPC status
+//
+// 运行库不依赖 google.golang.org/grpc，这里只提供错误码的映射，生成的代码负责构造 status。
+
+// GRPCCode 返回与 twirp 错误码对应的 gRPC 错误码（google.golang.org/grpc/codes.Code 的值）
+//
+// 除了 bad_route 对应 Unimplemented 以外，其他错误码与 gRPC 一一对应，无效的错误码返回 Unknown。
+func GRPCCode(code ErrorCode) uint32 {
+	switch code {
+	case Canceled:
+		return 1
+	case Unknown:
+		return 2
+	case InvalidArgument:
+		return 3
+	case DeadlineExceeded:
+		return 4
+	case NotFound:
+		return 5
+	case AlreadyExists:
+		return 6
+	case PermissionDenied:
+		return 7
+	case ResourceExhausted:
+		return 8
+	case FailedPrecondition:
+		return 9
+	case Aborted:
+		return 10
+	case OutOfRange:
+		return 11
+	case Unimplemented, BadRoute:
+		return 12
+	case Internal:
+		return 13
+	case Unavailable:
+		return 14
+	case DataLoss:
+		return 15
+	case Unauthenticated:
+		return 16
+	default:
+		return 2 // Unknown
+	}
+}
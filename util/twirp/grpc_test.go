@@ -0,0 +1,20 @@
+package twirp
+
+import "testing"
+
+func TestGRPCCode(t *testing.T) {
+	for code, want := range map[ErrorCode]uint32{
+		Canceled:         1,
+		InvalidArgument:  3,
+		NotFound:         5,
+		BadRoute:         12,
+		Unimplemented:    12,
+		Internal:         13,
+		Unauthenticated:  16,
+		ErrorCode("bad"): 2,
+	} {
+		if got := GRPCCode(code); got != want {
+			t.Errorf("GRPCCode(%q) = %d, want %d", code, got, want)
+		}
+	}
+}
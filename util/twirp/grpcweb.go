@@ -0,0 +1,206 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gRPC-Web 帧的标志位，见 https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md
+const (
+	grpcWebCompressed = 0x01
+	grpcWebTrailer    = 0x80
+)
+
+// ServeGRPCWeb 处理浏览器 gRPC-Web 客户端的请求，由生成的代码在 Content-Type 为 gRPC-Web 时调用
+//
+// 请求帧解码为 protobuf 请求体之后交给 serve（生成的 serve{Method}Protobuf）处理，
+// 鉴权、校验、hooks 等逻辑与 protobuf 请求完全相同；响应转换为数据帧，错误转换为 trailer 帧中的
+// grpc-status 和 grpc-message。只支持普通接口，不支持压缩。
+func ServeGRPCWeb(ctx context.Context, resp http.ResponseWriter, req *http.Request, serve func(context.Context, http.ResponseWriter, *http.Request)) {
+	text := strings.HasPrefix(strings.ToLower(req.Header.Get("Content-Type")), "application/grpc-web-text")
+
+	msg, err := readGRPCWebRequest(req.Body, text)
+	if err != nil {
+		// 交给 serve 读取请求体时返回，错误由生成的代码通过 hooks 输出
+		req.Body = errorBody{err}
+	} else {
+		req.Body = ioutil.NopCloser(bytes.NewReader(msg))
+	}
+
+	w := &grpcWebWriter{ResponseWriter: resp, header: make(http.Header), text: text}
+	serve(ctx, w, req)
+	w.finish()
+}
+
+// readGRPCWebRequest 读取请求体中的数据帧，返回 protobuf 编码的消息
+func readGRPCWebRequest(body io.Reader, text bool) ([]byte, error) {
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		if twerr, ok := err.(Error); ok {
+			return nil, twerr
+		}
+		return nil, InternalErrorWith(wrapErr(err, "failed to read request body"))
+	}
+	if text {
+		if buf, err = decodeGRPCWebText(buf); err != nil {
+			return nil, NewError(InvalidArgument, "invalid grpc-web-text request body")
+		}
+	}
+
+	var msg []byte
+	for len(buf) > 0 {
+		if len(buf) < 5 {
+			return nil, NewError(InvalidArgument, "malformed grpc-web frame")
+		}
+		flag, n := buf[0], binary.BigEndian.Uint32(buf[1:5])
+		if uint64(len(buf)-5) < uint64(n) {
+			return nil, NewError(InvalidArgument, "malformed grpc-web frame")
+		}
+		if flag&grpcWebCompressed != 0 {
+			return nil, NewError(Unimplemented, "grpc-web compression is not supported")
+		}
+		if flag&grpcWebTrailer == 0 {
+			if msg != nil {
+				return nil, NewError(InvalidArgument, "grpc-web request must contain exactly one message")
+			}
+			msg = buf[5 : 5+n]
+		}
+		buf = buf[5+n:]
+	}
+	// 没有数据帧时按照空消息处理
+	return msg, nil
+}
+
+// decodeGRPCWebText 解码 base64 请求体，客户端可能分段编码，每段都带有填充
+func decodeGRPCWebText(buf []byte) ([]byte, error) {
+	var out []byte
+	s := strings.Join(strings.Fields(string(buf)), "")
+	for s != "" {
+		i := strings.IndexByte(s, '=')
+		if i == -1 {
+			i = len(s)
+		}
+		for i < len(s) && s[i] == '=' {
+			i++
+		}
+		b, err := base64.StdEncoding.DecodeString(s[:i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+		s = s[i:]
+	}
+	return out, nil
+}
+
+type errorBody struct {
+	err error
+}
+
+func (b errorBody) Read([]byte) (int, error) { return 0, b.err }
+func (b errorBody) Close() error             { return nil }
+
+// grpcWebWriter 缓存 serve 输出的 twirp 响应，结束之后转换为 gRPC-Web 响应
+type grpcWebWriter struct {
+	http.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+	text   bool
+}
+
+func (w *grpcWebWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *grpcWebWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *grpcWebWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *grpcWebWriter) finish() {
+	var out bytes.Buffer
+	var status uint32
+	var message string
+	switch w.status {
+	case 0, http.StatusOK, http.StatusNoContent:
+		// 204 为 google.protobuf.Empty，对应长度为 0 的消息
+		writeGRPCWebFrame(&out, 0, w.body.Bytes())
+	default:
+		twerr := grpcWebError(w.status, w.body.Bytes())
+		status, message = GRPCCode(twerr.Code()), twerr.Msg()
+	}
+
+	trailer := "grpc-status:" + strconv.FormatUint(uint64(status), 10) + "\r\n"
+	if message != "" {
+		trailer += "grpc-message:" + encodeGRPCMessage(message) + "\r\n"
+	}
+	writeGRPCWebFrame(&out, grpcWebTrailer, []byte(trailer))
+
+	header := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	header.Del("Content-Length")
+	body := out.Bytes()
+	if w.text {
+		header.Set("Content-Type", "application/grpc-web-text+proto")
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	} else {
+		header.Set("Content-Type", "application/grpc-web+proto")
+	}
+	// gRPC-Web 的错误在 trailer 中，HTTP 状态码总是 200
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// grpcWebError 解析 serve 输出的错误响应，不是 twirp 错误格式（如 problem+json）时按照 HTTP 状态码转换
+func grpcWebError(status int, body []byte) Error {
+	var tj struct {
+		Code string `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &tj); err == nil && IsValidErrorCode(ErrorCode(tj.Code)) {
+		return NewError(ErrorCode(tj.Code), tj.Msg)
+	}
+	return twirpErrorFromIntermediary(status, http.StatusText(status), string(body))
+}
+
+func writeGRPCWebFrame(w *bytes.Buffer, flag byte, data []byte) {
+	var prefix [5]byte
+	prefix[0] = flag
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	w.Write(prefix[:])
+	w.Write(data)
+}
+
+// encodeGRPCMessage 按照 gRPC 协议对 grpc-message 做百分号编码
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// grpcWebServe 模拟生成的 serve{Method}Protobuf，返回大写的字符串，fail 返回 NotFound
+func grpcWebServe(ctx context.Context, resp http.ResponseWriter, req *http.Request) {
+	hooks := &ServerHooks{}
+	buf, err := io.ReadAll(req.Body)
+	if err != nil {
+		hooks.WriteError(ctx, resp, err)
+		return
+	}
+	in := new(wrapperspb.StringValue)
+	if err := proto.Unmarshal(buf, in); err != nil {
+		hooks.WriteError(ctx, resp, NewError(InvalidArgument, err.Error()))
+		return
+	}
+	switch in.Value {
+	case "fail":
+		hooks.WriteError(ctx, resp, NotFoundError("100% fail"))
+	case "empty":
+		WriteNoContent(ctx, resp, hooks, in)
+	default:
+		WriteResponse(ctx, resp, hooks, wrapperspb.String(strings.ToUpper(in.Value)), MarshalProtobuf)
+	}
+}
+
+func grpcWebFrames(t *testing.T, body []byte) (msg []byte, trailer string) {
+	t.Helper()
+	for len(body) > 0 {
+		if len(body) < 5 {
+			t.Fatalf("malformed frame %q", body)
+		}
+		n := binary.BigEndian.Uint32(body[1:5])
+		if body[0] == grpcWebTrailer {
+			trailer = string(body[5 : 5+n])
+		} else {
+			msg = body[5 : 5+n]
+		}
+		body = body[5+n:]
+	}
+	return msg, trailer
+}
+
+func TestServeGRPCWeb(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeGRPCWeb(r.Context(), w, r, grpcWebServe)
+	}))
+	defer ts.Close()
+
+	request := func(text bool, value string) (*http.Response, []byte, string) {
+		data, err := proto.Marshal(wrapperspb.String(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var frame bytes.Buffer
+		writeGRPCWebFrame(&frame, 0, data)
+		body, contentType := frame.Bytes(), "application/grpc-web+proto"
+		if text {
+			body, contentType = []byte(base64.StdEncoding.EncodeToString(body)), "application/grpc-web-text"
+		}
+		resp, err := http.Post(ts.URL, contentType, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if body, err = io.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		if text {
+			if body, err = base64.StdEncoding.DecodeString(string(body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		msg, trailer := grpcWebFrames(t, body)
+		return resp, msg, trailer
+	}
+
+	for _, text := range []bool{false, true} {
+		resp, msg, trailer := request(text, "a")
+		out := new(wrapperspb.StringValue)
+		if err := proto.Unmarshal(msg, out); err != nil || out.Value != "A" {
+			t.Errorf("text=%v: response = %q, %v", text, msg, err)
+		}
+		if trailer != "grpc-status:0\r\n" {
+			t.Errorf("text=%v: trailer = %q", text, trailer)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/grpc-web") || strings.Contains(ct, "text") != text {
+			t.Errorf("text=%v: Content-Type = %q", text, ct)
+		}
+	}
+
+	resp, msg, trailer := request(false, "fail")
+	if resp.StatusCode != http.StatusOK || msg != nil || trailer != "grpc-status:5\r\ngrpc-message:100%25 fail\r\n" {
+		t.Errorf("error response: status %d, msg %q, trailer %q", resp.StatusCode, msg, trailer)
+	}
+	if _, msg, trailer = request(false, "empty"); len(msg) != 0 || trailer != "grpc-status:0\r\n" {
+		t.Errorf("empty response: msg %q, trailer %q", msg, trailer)
+	}
+}
+
+func TestReadGRPCWebRequest(t *testing.T) {
+	frame := func(flag byte, data string) string {
+		var b bytes.Buffer
+		writeGRPCWebFrame(&b, flag, []byte(data))
+		return b.String()
+	}
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	for _, c := range []struct {
+		body string
+		text bool
+		msg  string
+		code ErrorCode
+	}{
+		{body: frame(0, "abc"), msg: "abc"},
+		{body: "", msg: ""},
+		{body: encode(frame(0, "a")[:2]) + encode(frame(0, "a")[2:]), text: true, msg: "a"},
+		{body: frame(0, "abc")[:6], code: InvalidArgument},
+		{body: frame(0, "a") + frame(0, "b"), code: InvalidArgument},
+		{body: frame(grpcWebCompressed, "a"), code: Unimplemented},
+		{body: "!!", text: true, code: InvalidArgument},
+	} {
+		msg, err := readGRPCWebRequest(strings.NewReader(c.body), c.text)
+		if c.code != "" {
+			if twerr, ok := err.(Error); !ok || twerr.Code() != c.code {
+				t.Errorf("%q: got error %v, want %s", c.body, err, c.code)
+			}
+			continue
+		}
+		if err != nil || string(msg) != c.msg {
+			t.Errorf("%q: got %q, %v", c.body, msg, err)
+		}
+	}
+}
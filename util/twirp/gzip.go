@@ -0,0 +1,143 @@
+package twirp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxGzipBodySize 解压之后请求体的默认大小上限，32MB
+const DefaultMaxGzipBodySize = 32 << 20
+
+var maxGzipBodySize int64 = DefaultMaxGzipBodySize
+
+// SetMaxGzipBodySize 设置解压之后请求体的大小上限，不大于 0 时使用 DefaultMaxGzipBodySize
+//
+// 没有配置 max_body_size 和 @maxbody 的接口也受这个限制，避免压缩炸弹。
+func SetMaxGzipBodySize(max int64) {
+	if max <= 0 {
+		max = DefaultMaxGzipBodySize
+	}
+	atomic.StoreInt64(&maxGzipBodySize, max)
+}
+
+// GzipRequest 解压 Content-Encoding 为 gzip 的请求体，并根据 Accept-Encoding 决定是否压缩响应
+//
+// 由生成的代码在限制请求体大小之前调用，max_body_size 限制的是解压之后的大小；
+// 没有配置时解压之后的大小不能超过 SetMaxGzipBodySize 设置的上限，超过时读取返回 ResourceExhausted。
+// minSize 大于零且客户端支持 gzip 时，不小于 minSize 字节的响应会由 CompressResponse 压缩。
+// 不支持的 Content-Encoding 返回 Unimplemented 错误。
+func GzipRequest(ctx context.Context, req *http.Request, minSize int64) (context.Context, error) {
+	switch encoding := strings.TrimSpace(strings.ToLower(req.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			if twerr, ok := err.(Error); ok {
+				return ctx, twerr
+			}
+			return ctx, invalidGzip()
+		}
+		max := atomic.LoadInt64(&maxGzipBodySize)
+		req.Body = &limitedBody{ReadCloser: &gzipBody{Reader: zr, body: req.Body}, remain: max, max: max}
+		req.ContentLength = -1
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+	default:
+		return ctx, NewError(Unimplemented, "unsupported Content-Encoding").WithMeta("content_encoding", encoding)
+	}
+
+	if minSize > 0 && acceptGzip(req.Header.Get("Accept-Encoding")) {
+		ctx = context.WithValue(ctx, gzipResponseKey, minSize)
+	}
+	return ctx, nil
+}
+
+// CompressResponse 压缩响应内容并设置 Content-Encoding，必须在输出响应头之前调用
+//
+// 只有经过 GzipRequest 且客户端支持 gzip 的请求才会压缩，小于阈值的响应原样返回。
+// 需要加密的响应在 EncryptResponse 之前压缩，通过 EncryptedEncodingHeader 声明压缩格式。
+func CompressResponse(ctx context.Context, resp http.ResponseWriter, b []byte) []byte {
+	minSize, ok := ctx.Value(gzipResponseKey).(int64)
+	if !ok {
+		return b
+	}
+	// 响应内容与 Accept-Encoding 有关，缓存需要区分
+	resp.Header().Add("Vary", "Accept-Encoding")
+	header := "Content-Encoding"
+	if _, ok := ctx.Value(encryptResponseKey).(Cipher); ok {
+		header = EncryptedEncodingHeader
+	}
+	if int64(len(b)) < minSize || resp.Header().Get(header) != "" {
+		return b
+	}
+
+	var buf bytes.Buffer
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return b
+	}
+	if err := zw.Close(); err != nil {
+		return b
+	}
+
+	resp.Header().Set(header, "gzip")
+	resp.Header().Del("Content-Length")
+	return buf.Bytes()
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// acceptGzip 判断 Accept-Encoding 是否包含 gzip，q=0 表示不接受
+func acceptGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params := part, ""
+		if i := strings.Index(part, ";"); i >= 0 {
+			coding, params = part[:i], part[i+1:]
+		}
+		coding = strings.TrimSpace(strings.ToLower(coding))
+		if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+			continue
+		}
+		q := strings.ReplaceAll(strings.ToLower(params), " ", "")
+		if q == "q=0" || strings.HasPrefix(q, "q=0.") && strings.Trim(q[4:], "0") == "" {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func invalidGzip() Error {
+	return NewError(InvalidArgument, "invalid gzip request body")
+}
+
+// gzipBody 解压请求体，解压失败时返回 InvalidArgument 错误
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		if twerr, ok := err.(Error); ok {
+			return n, twerr
+		}
+		return n, invalidGzip()
+	}
+	return n, err
+}
+
+func (b *gzipBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,186 @@
+package twirp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGzipRequest(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(gzipBytes(t, "hello")))
+	req.Header.Set("Content-Encoding", "gzip")
+	if _, err := GzipRequest(context.Background(), req, 0); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(req.Body); err != nil || string(b) != "hello" {
+		t.Errorf("body = %q, %v", b, err)
+	}
+	if req.ContentLength != -1 || req.Header.Get("Content-Encoding") != "" {
+		t.Errorf("ContentLength = %d, Content-Encoding = %q", req.ContentLength, req.Header.Get("Content-Encoding"))
+	}
+
+	// 解压之后的大小受 LimitRequestBody 限制
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(gzipBytes(t, strings.Repeat("a", 100))))
+	req.Header.Set("Content-Encoding", "gzip")
+	if _, err := GzipRequest(context.Background(), req, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := LimitRequestBody(req, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(req.Body); err == nil || err.(Error).Code() != ResourceExhausted {
+		t.Errorf("want ResourceExhausted, got %v", err)
+	}
+
+	// 没有配置 max_body_size 时解压之后的大小也有上限
+	SetMaxGzipBodySize(1 << 10)
+	defer SetMaxGzipBodySize(0)
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(gzipBytes(t, strings.Repeat("a", 1<<20))))
+	req.Header.Set("Content-Encoding", "gzip")
+	if _, err := GzipRequest(context.Background(), req, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(req.Body); err == nil || err.(Error).Code() != ResourceExhausted {
+		t.Errorf("decompressed body without limit: want ResourceExhausted, got %v", err)
+	}
+
+	for encoding, code := range map[string]ErrorCode{"gzip": InvalidArgument, "br": Unimplemented} {
+		req = httptest.NewRequest("POST", "/", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", encoding)
+		if _, err := GzipRequest(context.Background(), req, 0); err == nil || err.(Error).Code() != code {
+			t.Errorf("%s: want %s, got %v", encoding, code, err)
+		}
+	}
+
+	// 截断的 gzip 在读取时返回 InvalidArgument
+	b := gzipBytes(t, strings.Repeat("hello", 100))
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(b[:len(b)-10]))
+	req.Header.Set("Content-Encoding", "gzip")
+	if _, err := GzipRequest(context.Background(), req, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(req.Body); err == nil || err.(Error).Code() != InvalidArgument {
+		t.Errorf("want InvalidArgument, got %v", err)
+	}
+}
+
+func TestCompressResponse(t *testing.T) {
+	for _, c := range []struct {
+		accept  string
+		minSize int64
+		body    string
+		gzip    bool
+	}{
+		{accept: "gzip", minSize: 10, body: strings.Repeat("a", 10), gzip: true},
+		{accept: "deflate, gzip;q=0.5", minSize: 10, body: strings.Repeat("a", 10), gzip: true},
+		{accept: "gzip", minSize: 10, body: "short"},
+		{accept: "gzip", minSize: 0, body: strings.Repeat("a", 10)},
+		{accept: "gzip;q=0", minSize: 10, body: strings.Repeat("a", 10)},
+		{accept: "", minSize: 10, body: strings.Repeat("a", 10)},
+	} {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("Accept-Encoding", c.accept)
+		ctx, err := GzipRequest(context.Background(), req, c.minSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp := httptest.NewRecorder()
+		b := CompressResponse(ctx, resp, []byte(c.body))
+		if gz := resp.Header().Get("Content-Encoding") == "gzip"; gz != c.gzip {
+			t.Errorf("%+v: Content-Encoding = %q", c, resp.Header().Get("Content-Encoding"))
+			continue
+		}
+		if c.gzip {
+			zr, err := gzip.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, err = ioutil.ReadAll(zr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if string(b) != c.body {
+			t.Errorf("%+v: body = %q", c, b)
+		}
+	}
+}
+
+func TestWriteResponseGzip(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	ctx, err := GzipRequest(context.Background(), req, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	WriteResponse(ctx, resp, &ServerHooks{}, wrapperspb.String("hello"), MarshalJSON)
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("status %d, headers %v", resp.Code, resp.Header())
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != `"hello"` {
+		t.Errorf("body = %q", b)
+	}
+}
+
+func TestWriteResponseGzipEncrypted(t *testing.T) {
+	c, err := NewAESGCM(testCipherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := sealRequest(t, testCipherKey, context.Background(), []byte("{}"))
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Accept-Encoding", "gzip")
+	ctx, err := GzipRequest(WithCipher(context.Background(), c), req, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx, err = DecryptRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	WriteResponse(ctx, resp, &ServerHooks{}, wrapperspb.String(strings.Repeat("hello", 100)), MarshalJSON)
+	if resp.Header().Get("Content-Encoding") != "" || resp.Header().Get(EncryptedEncodingHeader) != "gzip" {
+		t.Fatalf("headers %v, ciphertext should not be compressed", resp.Header())
+	}
+
+	// 先解密再解压
+	plain, err := openResponse(testCipherKey, ctx, resp.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != `"`+strings.Repeat("hello", 100)+`"` {
+		t.Errorf("body = %q", b)
+	}
+}
@@ -0,0 +1,64 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// allowedMethods 接受 GET 请求的接口允许的请求方法
+const allowedMethods = "GET, HEAD, POST, OPTIONS"
+
+// WriteOptions 输出 OPTIONS 请求的响应，状态码为 204，通过 Allow 响应头声明允许的请求方法
+//
+// 接口使用 @get 注解或者 hooks 通过 WithAllowGET 开启 GET 请求后由生成的代码调用，
+// 健康检查和 CDN 探测不再产生 bad_route 错误。跨域预检请求由服务入口处理，不会调用到这里。
+func WriteOptions(ctx context.Context, resp http.ResponseWriter, hooks *ServerHooks) {
+	resp.Header().Set("Allow", allowedMethods)
+	ctx = WithStatusCode(ctx, http.StatusNoContent)
+	WriteResponseHeader(ctx, resp)
+	resp.WriteHeader(http.StatusNoContent)
+	hooks.CallResponseSent(ctx)
+}
+
+// HeadResponse 把 HEAD 请求按照 GET 请求处理，由生成的代码在分发请求之前调用
+//
+// 返回的 ResponseWriter 只记录响应体的长度，不输出响应体；处理完成之后调用 finish
+// 输出响应头，Content-Length 为对应 GET 请求响应体的长度。
+func HeadResponse(resp http.ResponseWriter, req *http.Request) (http.ResponseWriter, func()) {
+	req.Method = http.MethodGet
+	w := &headWriter{ResponseWriter: resp}
+	return w, w.finish
+}
+
+// headWriter 不实现 http.Flusher，响应头只在 finish 中输出
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	length int64
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.length += int64(len(b))
+	return len(b), nil
+}
+
+func (w *headWriter) finish() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.ResponseWriter.Header()
+	if w.status != http.StatusNoContent && w.status != http.StatusNotModified && h.Get("Content-Length") == "" {
+		h.Set("Content-Length", strconv.FormatInt(w.length, 10))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
@@ -0,0 +1,44 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestHeadResponse(t *testing.T) {
+	req := httptest.NewRequest("HEAD", "/echo.v1.Echo/Hello", nil)
+	rec := httptest.NewRecorder()
+	resp, finish := HeadResponse(rec, req)
+	if req.Method != http.MethodGet {
+		t.Errorf("HEAD request should be served as GET, have %s", req.Method)
+	}
+	(&ServerHooks{}).WriteError(context.Background(), resp, NotFoundError("not found"))
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Error("response should not be written before finish")
+	}
+	finish()
+
+	length := strconv.Itoa(len(marshalErrorToJSON(NotFoundError("not found"))))
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 || rec.Header().Get("Content-Length") != length {
+		t.Errorf("HEAD response: %d %v %q", rec.Code, rec.Header(), rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	resp, finish = HeadResponse(rec, req)
+	resp.WriteHeader(http.StatusNoContent)
+	finish()
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("204 response should not have Content-Length: %v", rec.Header())
+	}
+}
+
+func TestWriteOptions(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteOptions(context.Background(), rec, &ServerHooks{})
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != "GET, HEAD, POST, OPTIONS" {
+		t.Errorf("OPTIONS response: %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,92 @@
+package twirp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// responseHeader 缓存业务代码设置的响应头
+// 生成的代码会在写入状态码之前统一输出，避免跟 WriteHeader 竞争
+type responseHeader struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+// SetResponseHeader 设置响应头，如果 key 已存在则覆盖原有内容
+//
+// 跟 SetHTTPResponseHeader 直接修改 http.ResponseWriter 不同，
+// SetResponseHeader 设置的内容会先缓存起来，等到生成的代码写响应时再统一输出，
+// 所以可以在业务方法的任意位置调用，也可以在多个协程中并发调用。
+//
+// ctx 不是由 twirp 服务传入的则会忽略设置，方便编写单元测试。
+func SetResponseHeader(ctx context.Context, key, value string) error {
+	return updateResponseHeader(ctx, key, func(h http.Header) { h.Set(key, value) })
+}
+
+// AddResponseHeader 追加响应头，规则同 SetResponseHeader
+func AddResponseHeader(ctx context.Context, key, value string) error {
+	return updateResponseHeader(ctx, key, func(h http.Header) { h.Add(key, value) })
+}
+
+// SetCookie 追加 Set-Cookie 响应头，规则同 SetResponseHeader
+func SetCookie(ctx context.Context, cookie *http.Cookie) error {
+	v := cookie.String()
+	if v == "" {
+		return errors.New("invalid cookie")
+	}
+	return AddResponseHeader(ctx, "Set-Cookie", v)
+}
+
+func updateResponseHeader(ctx context.Context, key string, update func(http.Header)) error {
+	if http.CanonicalHeaderKey(key) == "Content-Type" {
+		return errors.New("header key can not be Content-Type")
+	}
+
+	rh, ok := ctx.Value(ResponseHeaderKey).(*responseHeader)
+	if !ok {
+		return nil
+	}
+
+	rh.mu.Lock()
+	update(rh.header)
+	rh.mu.Unlock()
+	return nil
+}
+
+// WithResponseContentType 所有响应（包括错误和 204 响应）都使用 contentType 作为 Content-Type
+//
+// 接口声明 @form_content_type 注解或者开启 form_content_type 参数后，生成的代码在处理表单请求时调用，
+// 兼容只接受固定 Content-Type 的旧网关，如 application/json;charset=utf-8。
+func WithResponseContentType(ctx context.Context, contentType string) context.Context {
+	return context.WithValue(ctx, responseContentTypeKey, contentType)
+}
+
+// writeResponseContentType 使用 WithResponseContentType 设置的 Content-Type 覆盖 resp 中的值
+func writeResponseContentType(ctx context.Context, resp http.ResponseWriter) {
+	if contentType, ok := ctx.Value(responseContentTypeKey).(string); ok {
+		resp.Header().Set("Content-Type", contentType)
+	}
+}
+
+// WriteResponseHeader 将缓存的响应头写入 resp，需要在 resp.WriteHeader 之前调用
+// 缓存的响应头会覆盖直接写入 resp 的同名响应头
+// 如果开启了耗时记录，还会同时输出 Server-Timing 响应头
+func WriteResponseHeader(ctx context.Context, resp http.ResponseWriter) {
+	writeServerTiming(ctx, resp)
+	writeResponseContentType(ctx, resp)
+
+	rh, ok := ctx.Value(ResponseHeaderKey).(*responseHeader)
+	if !ok {
+		return
+	}
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	h := resp.Header()
+	for k, vv := range rh.header {
+		h[k] = append([]string(nil), vv...)
+	}
+}
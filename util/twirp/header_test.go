@@ -0,0 +1,73 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseHeader(t *testing.T) {
+	resp := httptest.NewRecorder()
+	resp.Header().Set("X-Trace-Id", "foo")
+
+	ctx := WithResponseHeader(context.Background())
+
+	if err := SetResponseHeader(ctx, "content-type", "text/plain"); err == nil {
+		t.Errorf("Content-Type should not be set")
+	}
+
+	_ = SetResponseHeader(ctx, "X-Foo", "1")
+	_ = SetResponseHeader(ctx, "X-Foo", "2")
+	_ = AddResponseHeader(ctx, "X-Bar", "1")
+	_ = AddResponseHeader(ctx, "X-Bar", "2")
+	_ = SetCookie(ctx, &http.Cookie{Name: "sid", Value: "baz"})
+
+	if len(resp.Header()) != 1 {
+		t.Fatalf("header should be buffered, have=%v", resp.Header())
+	}
+
+	WriteResponseHeader(ctx, resp)
+
+	h := resp.Header()
+	if v := h.Get("X-Trace-Id"); v != "foo" {
+		t.Errorf("unexpected X-Trace-Id, have=%q, want=%q", v, "foo")
+	}
+	if v := h.Get("X-Foo"); v != "2" {
+		t.Errorf("unexpected X-Foo, have=%q, want=%q", v, "2")
+	}
+	if v := h["X-Bar"]; len(v) != 2 {
+		t.Errorf("unexpected X-Bar, have=%v", v)
+	}
+	if v := h.Get("Set-Cookie"); v != "sid=baz" {
+		t.Errorf("unexpected Set-Cookie, have=%q, want=%q", v, "sid=baz")
+	}
+
+	// 非 twirp 服务传入的 ctx 直接忽略
+	if err := SetResponseHeader(context.Background(), "X-Foo", "1"); err != nil {
+		t.Errorf("unexpected err %v", err)
+	}
+}
+
+func TestResponseContentType(t *testing.T) {
+	ctx := WithResponseContentType(context.Background(), "application/json;charset=utf-8")
+
+	resp := httptest.NewRecorder()
+	resp.Header().Set("Content-Type", "application/json")
+	WriteResponseHeader(ctx, resp)
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json;charset=utf-8" {
+		t.Errorf("response Content-Type = %q", ct)
+	}
+
+	resp = httptest.NewRecorder()
+	(&ServerHooks{}).WriteError(ctx, resp, NotFoundError("not found"))
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json;charset=utf-8" {
+		t.Errorf("error Content-Type = %q", ct)
+	}
+
+	resp = httptest.NewRecorder()
+	(&ServerHooks{}).WriteError(context.Background(), resp, NotFoundError("not found"))
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("default error Content-Type = %q", ct)
+	}
+}
@@ -0,0 +1,343 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// HealthPath 健康检查接口路径，跟 gRPC 健康检查协议的方法名保持一致
+const HealthPath = "/grpc.health.v1.Health/Check"
+
+// HealthStatus 服务状态，取值跟 grpc.health.v1.HealthCheckResponse.ServingStatus 一致
+type HealthStatus int32
+
+const (
+	HealthUnknown        HealthStatus = 0
+	HealthServing        HealthStatus = 1
+	HealthNotServing     HealthStatus = 2
+	HealthServiceUnknown HealthStatus = 3
+)
+
+var healthStatusNames = map[HealthStatus]string{
+	HealthUnknown:        "UNKNOWN",
+	HealthServing:        "SERVING",
+	HealthNotServing:     "NOT_SERVING",
+	HealthServiceUnknown: "SERVICE_UNKNOWN",
+}
+
+func (s HealthStatus) String() string {
+	if name, ok := healthStatusNames[s]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// MarshalJSON 跟 protojson 一样输出枚举名
+func (s HealthStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON 支持枚举名和数字
+func (s *HealthStatus) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		var n int32
+		if err := json.Unmarshal(b, &n); err != nil {
+			return err
+		}
+		*s = HealthStatus(n)
+		return nil
+	}
+	*s = HealthUnknown
+	for status, n := range healthStatusNames {
+		if n == name {
+			*s = status
+		}
+	}
+	return nil
+}
+
+// healthRequest 对应 grpc.health.v1.HealthCheckRequest
+type healthRequest struct {
+	Service string `json:"service"`
+}
+
+// healthResponse 对应 grpc.health.v1.HealthCheckResponse
+type healthResponse struct {
+	Status HealthStatus `json:"status"`
+}
+
+// HealthServer 按照 gRPC 健康检查协议返回各个服务的状态
+//
+// 服务名为空表示整个进程的状态。没有单独设置状态的服务，只要已经通过
+// RegisterMethod 注册过接口就使用进程的状态，否则返回 not_found 错误，
+// 对应 gRPC 的 NOT_FOUND。
+//
+// POST 请求跟普通 twirp 接口一样支持 JSON 和 protobuf 编码；
+// 负载均衡可以使用 GET 请求，用 service 参数指定服务，SERVING 时返回 200，否则返回 503。
+type HealthServer struct {
+	mu       sync.RWMutex
+	shutdown bool
+	statuses map[string]HealthStatus
+}
+
+// NewHealthServer 创建 HealthServer，进程状态为 SERVING
+func NewHealthServer() *HealthServer {
+	return &HealthServer{statuses: map[string]HealthStatus{"": HealthServing}}
+}
+
+// SetServingStatus 设置服务的状态，service 为空时设置进程的状态
+//
+// Shutdown 之后只记录状态，Resume 之后生效。
+func (h *HealthServer) SetServingStatus(service string, status HealthStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statuses[service] = status
+}
+
+// Shutdown 将所有服务标记为 NOT_SERVING，一般在停止服务之前调用，让负载均衡摘除流量
+func (h *HealthServer) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = true
+}
+
+// Resume 恢复 Shutdown 之前的状态
+func (h *HealthServer) Resume() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shutdown = false
+}
+
+// Check 返回服务的状态，服务不存在时 ok 为 false
+func (h *HealthServer) Check(service string) (status HealthStatus, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	status, ok = h.statuses[service]
+	if !ok {
+		if !hasService(service) {
+			return HealthServiceUnknown, false
+		}
+		status = h.statuses[""]
+	}
+	if h.shutdown {
+		status = HealthNotServing
+	}
+	return status, true
+}
+
+func hasService(service string) bool {
+	for _, info := range Methods() {
+		if info.Service == service {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *HealthServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	var hooks *ServerHooks
+	ctx := WithHttpRequest(req.Context(), req)
+
+	if req.Method == http.MethodGet {
+		status, ok := h.Check(req.URL.Query().Get("service"))
+		if !ok {
+			hooks.WriteError(ctx, resp, NotFoundError("unknown service"))
+			return
+		}
+		code := http.StatusOK
+		if status != HealthServing {
+			code = http.StatusServiceUnavailable
+		}
+		resp.Header().Set("Content-Type", "text/plain")
+		resp.WriteHeader(code)
+		_, _ = resp.Write([]byte(status.String()))
+		return
+	}
+
+	if req.Method != http.MethodPost {
+		hooks.WriteError(ctx, resp, NewError(BadRoute, "unsupported method "+req.Method))
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		hooks.WriteError(ctx, resp, InternalErrorWith(err))
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	isJSON := contentType == "application/json"
+
+	var in healthRequest
+	if isJSON {
+		if len(bytes.TrimSpace(body)) > 0 {
+			err = json.Unmarshal(body, &in)
+		}
+	} else {
+		in.Service, err = unmarshalHealthRequest(body)
+	}
+	if err != nil {
+		hooks.WriteError(ctx, resp, InvalidArgumentError("body", "the request could not be decoded"))
+		return
+	}
+
+	status, ok := h.Check(in.Service)
+	if !ok {
+		hooks.WriteError(ctx, resp, NotFoundError("unknown service "+in.Service))
+		return
+	}
+
+	var out []byte
+	if isJSON {
+		out, _ = json.Marshal(healthResponse{Status: status})
+		resp.Header().Set("Content-Type", "application/json")
+	} else {
+		out = protowire.AppendTag(nil, 1, protowire.VarintType)
+		out = protowire.AppendVarint(out, uint64(status))
+		resp.Header().Set("Content-Type", "application/protobuf")
+	}
+	resp.WriteHeader(http.StatusOK)
+	_, _ = resp.Write(out)
+}
+
+// unmarshalHealthRequest 解析 protobuf 编码的 HealthCheckRequest
+func unmarshalHealthRequest(b []byte) (service string, err error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if num == 1 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return "", protowire.ParseError(n)
+			}
+			service, b = v, b[n:]
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return service, nil
+}
+
+// unmarshalHealthResponse 解析 protobuf 编码的 HealthCheckResponse
+func unmarshalHealthResponse(b []byte) (status HealthStatus, err error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if num == 1 && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			status, b = HealthStatus(v), b[n:]
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return 0, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return status, nil
+}
+
+// HealthClient 健康检查客户端，负载均衡探活和服务注册之前的就绪检查使用
+type HealthClient struct {
+	client HTTPClient
+	url    string
+}
+
+// NewHealthClient 创建健康检查客户端，baseURL 为服务地址加 RPC 前缀，
+// 如 http://127.0.0.1:8080/api
+func NewHealthClient(baseURL string, client HTTPClient) *HealthClient {
+	return &HealthClient{
+		client: client,
+		url:    strings.TrimSuffix(baseURL, "/") + HealthPath,
+	}
+}
+
+// Check 查询服务的状态，service 为空时查询进程的状态
+//
+// 服务不存在时返回 not_found 错误。
+func (c *HealthClient) Check(ctx context.Context, service string) (HealthStatus, error) {
+	var in []byte
+	if service != "" {
+		in = protowire.AppendTag(in, 1, protowire.BytesType)
+		in = protowire.AppendString(in, service)
+	}
+
+	req, err := newRequest(ctx, c.url, bytes.NewReader(in), "application/protobuf")
+	if err != nil {
+		return HealthUnknown, clientError("could not build request", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return HealthUnknown, clientError("failed to do request", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return HealthUnknown, errorFromResponse(resp)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return HealthUnknown, clientError("failed to read response body", err)
+	}
+	status, err := unmarshalHealthResponse(b)
+	if err != nil {
+		return HealthUnknown, clientError("failed to unmarshal proto response", err)
+	}
+	return status, nil
+}
+
+// WaitServing 每隔 interval 查询一次，直到服务变为 SERVING 或者 ctx 结束
+//
+// 服务发现注册实例之前调用，避免把还没有准备好的实例暴露给调用方。
+func (c *HealthClient) WaitServing(ctx context.Context, service string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.Check(ctx, service)
+		if err == nil && status == HealthServing {
+			return nil
+		}
+		if twerr, ok := err.(Error); ok && twerr.Code() == NotFound {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = NewError(Unavailable, "service is "+status.String())
+			}
+			return err
+		case <-ticker.C:
+		}
+	}
+}
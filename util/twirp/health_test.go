@@ -0,0 +1,72 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	RegisterMethod(MethodInfo{Service: "health.v1.Echo", Method: "Hello", Path: "/health.v1.Echo/Hello"})
+
+	h := NewHealthServer()
+	h.SetServingStatus("health.v1.Order", HealthNotServing)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	c := NewHealthClient(ts.URL+"/", http.DefaultClient)
+	ctx := context.Background()
+
+	for service, want := range map[string]HealthStatus{
+		"":                HealthServing,
+		"health.v1.Echo":  HealthServing,
+		"health.v1.Order": HealthNotServing,
+	} {
+		if got, err := c.Check(ctx, service); err != nil || got != want {
+			t.Errorf("Check(%q) = %v %v, want %v", service, got, err, want)
+		}
+	}
+
+	_, err := c.Check(ctx, "health.v1.Unknown")
+	if twerr, ok := err.(Error); !ok || twerr.Code() != NotFound {
+		t.Errorf("unknown service should return not_found, got %v", err)
+	}
+
+	h.Shutdown()
+	if got, _ := c.Check(ctx, "health.v1.Echo"); got != HealthNotServing {
+		t.Errorf("status after Shutdown = %v", got)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := c.WaitServing(timeout, "", time.Millisecond); err == nil {
+		t.Error("WaitServing should fail after Shutdown")
+	}
+
+	h.Resume()
+	if err := c.WaitServing(ctx, "", time.Millisecond); err != nil {
+		t.Errorf("WaitServing = %v", err)
+	}
+}
+
+func TestHealthHTTP(t *testing.T) {
+	h := NewHealthServer()
+
+	req := httptest.NewRequest("POST", HealthPath, strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if got := resp.Body.String(); got != `{"status":"SERVING"}` {
+		t.Errorf("json response = %s", got)
+	}
+
+	h.Shutdown()
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", HealthPath, nil))
+	if resp.Code != http.StatusServiceUnavailable || resp.Body.String() != "NOT_SERVING" {
+		t.Errorf("GET response = %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -42,7 +42,10 @@ type ServerHooks struct {
 	RequestReceived func(context.Context) (context.Context, error)
 
 	// RequestRouted is called when a request has been routed to a
-	// particular method of the Twirp server.
+	// particular method of the Twirp server, before the request body is
+	// read or decoded. The method name and priority are available, so
+	// load shedding, auth and rate limiting can reject requests here
+	// without buffering their bodies.
 	RequestRouted func(context.Context) (context.Context, error)
 
 	// ResponsePrepared is called when a request has been handled and a
@@ -101,20 +104,39 @@ func (h *ServerHooks) CallError(ctx context.Context, err Error) context.Context
 
 // WriteError writes Twirp errors in the response and triggers hooks.
 func (h *ServerHooks) WriteError(ctx context.Context, resp http.ResponseWriter, err error) {
-	// Non-twirp errors are wrapped as Internal (default)
+	// Non-twirp errors are wrapped as Internal (default), context errors
+	// (including Internal errors caused by them) are translated to
+	// Canceled and DeadlineExceeded.
 	twerr, ok := err.(Error)
+	if !ok || twerr.Code() == Internal {
+		if cerr := ContextError(err); cerr != nil {
+			twerr, ok = cerr, true
+		}
+	}
 	if !ok {
 		twerr = InternalErrorWith(err)
 	}
 
 	statusCode := ServerHTTPStatusFromErrorCode(twerr.Code())
+	if twerr.Code() == Canceled && ctx.Err() == context.Canceled && !twitchCompat(ctx) {
+		// The request itself was canceled, most likely the client has gone away.
+		statusCode = StatusClientClosedRequest
+	}
 	ctx = WithStatusCode(ctx, statusCode)
 	ctx = h.CallError(ctx, twerr)
 
-	resp.Header().Set("Content-Type", "application/json") // Error responses are always JSON (instead of protobuf)
-	resp.WriteHeader(statusCode)                          // HTTP response status code
+	WriteResponseHeader(ctx, resp)
+	var respBody []byte
+	if acceptProblem(ctx) {
+		resp.Header().Set("Content-Type", ProblemContentType)
+		respBody = marshalErrorToProblem(twerr, statusCode)
+	} else {
+		resp.Header().Set("Content-Type", "application/json") // Error responses are always JSON (instead of protobuf)
+		respBody = marshalErrorToJSON(twerr)
+	}
+	writeResponseContentType(ctx, resp)
+	resp.WriteHeader(statusCode) // HTTP response status code
 
-	respBody := marshalErrorToJSON(twerr)
 	_, writeErr := resp.Write(respBody)
 	if writeErr != nil {
 		// We have three options here. We could log the error, call the Error
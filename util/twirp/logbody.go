@@ -0,0 +1,54 @@
+package twirp
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// maxLogBodySize 记录到日志的请求/响应内容最大字节数，超过部分截断
+const maxLogBodySize = 4 << 10
+
+type logBody struct {
+	req  string
+	resp string
+}
+
+// SampleLogBody 按 rate 比例采样记录请求内容，由 @logbody 注解生成的代码调用
+//
+// 采中的请求会在 ctx 中记录 JSON 编码后的请求内容，响应内容通过
+// SetLogResponseBody 补充，日志 hook 通过 LogBody 获取。
+func SampleLogBody(ctx context.Context, rate float64, req proto.Message) context.Context {
+	if rate <= 0 || rand.Float64() >= rate {
+		return ctx
+	}
+	return context.WithValue(ctx, LogBodyKey, &logBody{req: marshalLogBody(req)})
+}
+
+// SetLogResponseBody 记录响应内容，请求没有被采中时不做任何处理
+func SetLogResponseBody(ctx context.Context, resp proto.Message) {
+	if b, ok := ctx.Value(LogBodyKey).(*logBody); ok && resp != nil {
+		b.resp = marshalLogBody(resp)
+	}
+}
+
+// LogBody 返回采样记录的请求和响应内容，ok 表示请求是否被采中
+func LogBody(ctx context.Context) (req, resp string, ok bool) {
+	b, ok := ctx.Value(LogBodyKey).(*logBody)
+	if !ok {
+		return "", "", false
+	}
+	return b.req, b.resp, true
+}
+
+func marshalLogBody(m proto.Message) string {
+	b, _, err := MarshalJSON(m)
+	if err != nil {
+		return ""
+	}
+	if len(b) > maxLogBodySize {
+		return string(b[:maxLogBodySize]) + "..."
+	}
+	return string(b)
+}
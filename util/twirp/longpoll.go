@@ -0,0 +1,76 @@
+package twirp
+
+import (
+	"context"
+	"time"
+)
+
+// LongPollInterval 服务没有实现 Notifier 时，长轮询接口重新调用业务方法的间隔
+var LongPollInterval = time.Second
+
+// WaitLongPoll 等待 ready 通知有新数据，收到通知或者 ready 被关闭时返回 true
+//
+// 接口使用 @longpoll 注解后由生成的代码调用。超过 timeout 没有新数据时返回 false，
+// 由生成的代码返回只带游标的空响应，客户端使用相同的游标重新发起请求。
+// ctx 带有 deadline 时最多等待剩余时间的 DefaultBudget，留出输出响应的时间；
+// ctx 结束时同样返回 false。ready 为 nil 表示一直等到超时。
+func WaitLongPoll(ctx context.Context, ready <-chan struct{}, timeout time.Duration) bool {
+	timeout = longPollBudget(ctx, timeout)
+	if timeout <= 0 {
+		select {
+		case <-ready:
+			return true
+		default:
+			return false
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// RetryLongPoll 反复调用 call 直到返回 true，表示有新数据或者出错
+//
+// 接口使用 @longpoll 注解、服务没有实现生成的 {Service}{Method}Notifier 接口时由生成的代码调用，
+// 响应的游标与请求相同时表示没有新数据，等待 LongPollInterval 之后重新调用业务方法。
+// call 至少调用一次，超过 timeout 或者 ctx 结束后不再调用，生成的代码返回最后一次调用的结果。
+// 等待时间的计算与 WaitLongPoll 相同。
+func RetryLongPoll(ctx context.Context, timeout time.Duration, call func() bool) {
+	deadline := time.Now().Add(longPollBudget(ctx, timeout))
+	for !call() {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return
+		}
+		if wait > LongPollInterval {
+			wait = LongPollInterval
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// longPollBudget ctx 带有 deadline 时最多等待剩余时间的 DefaultBudget，留出输出响应的时间
+func longPollBudget(ctx context.Context, timeout time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok && DefaultBudget > 0 && DefaultBudget < 1 {
+		if budget := time.Duration(float64(time.Until(deadline)) * DefaultBudget); budget < timeout {
+			return budget
+		}
+	}
+	return timeout
+}
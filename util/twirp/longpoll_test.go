@@ -0,0 +1,81 @@
+package twirp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitLongPoll(t *testing.T) {
+	ready := make(chan struct{}, 1)
+	ready <- struct{}{}
+	if !WaitLongPoll(context.Background(), ready, time.Second) {
+		t.Error("signaled channel should be ready")
+	}
+
+	closed := make(chan struct{})
+	close(closed)
+	if !WaitLongPoll(context.Background(), closed, time.Second) {
+		t.Error("closed channel should be ready")
+	}
+
+	start := time.Now()
+	if WaitLongPoll(context.Background(), nil, 20*time.Millisecond) {
+		t.Error("nil channel should time out")
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("returned after %v, want at least 20ms", d)
+	}
+
+	// 带有 deadline 时只等待剩余时间的 DefaultBudget
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if WaitLongPoll(ctx, make(chan struct{}), time.Minute) {
+		t.Error("should time out before the deadline")
+	}
+	if d := time.Since(start); d > 190*time.Millisecond {
+		t.Errorf("returned after %v, want about 160ms", d)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if WaitLongPoll(ctx, make(chan struct{}), time.Minute) {
+		t.Error("canceled context should not be ready")
+	}
+}
+
+func TestRetryLongPoll(t *testing.T) {
+	defer func(interval time.Duration) { LongPollInterval = interval }(LongPollInterval)
+	LongPollInterval = 5 * time.Millisecond
+
+	calls := 0
+	RetryLongPoll(context.Background(), time.Second, func() bool {
+		calls++
+		return calls == 3
+	})
+	if calls != 3 {
+		t.Errorf("call should be retried until ready, have %d calls", calls)
+	}
+
+	calls = 0
+	start := time.Now()
+	RetryLongPoll(context.Background(), 20*time.Millisecond, func() bool {
+		calls++
+		return false
+	})
+	if d := time.Since(start); d < 20*time.Millisecond || calls < 2 {
+		t.Errorf("returned after %v with %d calls, want at least 20ms", d, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	RetryLongPoll(ctx, time.Minute, func() bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("canceled context should be called once, have %d calls", calls)
+	}
+}
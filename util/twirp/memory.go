@@ -0,0 +1,127 @@
+package twirp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// MemoryAccount 单个请求的内存记账，统计请求体和编码缓冲区等主要的内存分配
+//
+// 只是近似值，用于防止单个异常请求或者大量并发请求耗尽进程内存。
+type MemoryAccount struct {
+	limit int64
+	used  int64
+}
+
+var (
+	// processMemory 所有进行中请求的内存总量
+	processMemory int64
+	// processMemoryBudget 进程内存预算，0 表示不限制
+	processMemoryBudget int64
+)
+
+// SetMemoryBudget 设置所有进行中请求的内存预算，0 表示不限制
+func SetMemoryBudget(budget int64) {
+	atomic.StoreInt64(&processMemoryBudget, budget)
+}
+
+// MemoryInUse 返回所有进行中请求记账的内存总量
+func MemoryInUse() int64 {
+	return atomic.LoadInt64(&processMemory)
+}
+
+// CheckMemoryBudget 进程内存预算已经用完时返回 ResourceExhausted 错误，新请求应该直接拒绝
+func CheckMemoryBudget() error {
+	budget := atomic.LoadInt64(&processMemoryBudget)
+	if budget > 0 && atomic.LoadInt64(&processMemory) >= budget {
+		return memoryBudgetExhausted(budget)
+	}
+	return nil
+}
+
+func memoryBudgetExhausted(budget int64) Error {
+	return NewError(ResourceExhausted, "server is out of memory budget").
+		WithMeta("memory_budget", strconv.FormatInt(budget, 10))
+}
+
+// WithMemoryAccount 为请求开启内存记账，limit 为单个请求的上限，0 表示不限制
+//
+// 一般在 hook.RequestReceived 阶段调用，请求结束后必须调用 ReleaseMemory 归还预算。
+// 请求体在读取时记账，超过限制时读取返回 ResourceExhausted 错误。
+func WithMemoryAccount(ctx context.Context, limit int64) context.Context {
+	account := &MemoryAccount{limit: limit}
+	if req, ok := HttpRequest(ctx); ok && req.Body != nil && req.Body != http.NoBody {
+		req.Body = &accountedBody{ReadCloser: req.Body, account: account}
+	}
+	return context.WithValue(ctx, MemoryAccountKey, account)
+}
+
+// ChargeMemory 记录请求分配了 n 字节，超过单个请求的上限或者进程预算时返回 ResourceExhausted 错误
+//
+// 没有开启内存记账的请求直接返回 nil。
+func ChargeMemory(ctx context.Context, n int64) error {
+	account, ok := ctx.Value(MemoryAccountKey).(*MemoryAccount)
+	if !ok {
+		return nil
+	}
+	return account.charge(n)
+}
+
+func (a *MemoryAccount) charge(n int64) error {
+	if n <= 0 {
+		return nil
+	}
+
+	used := atomic.AddInt64(&a.used, n)
+	total := atomic.AddInt64(&processMemory, n)
+	if a.limit > 0 && used > a.limit {
+		return NewError(ResourceExhausted, "request uses too much memory").
+			WithMeta("memory_limit", strconv.FormatInt(a.limit, 10))
+	}
+	if budget := atomic.LoadInt64(&processMemoryBudget); budget > 0 && total > budget {
+		return memoryBudgetExhausted(budget)
+	}
+	return nil
+}
+
+// ReleaseMemory 请求结束后归还记账的内存，返回请求记账的总量
+func ReleaseMemory(ctx context.Context) int64 {
+	account, ok := ctx.Value(MemoryAccountKey).(*MemoryAccount)
+	if !ok {
+		return 0
+	}
+	used := atomic.SwapInt64(&account.used, 0)
+	atomic.AddInt64(&processMemory, -used)
+	return used
+}
+
+// MemoryUsage 返回请求当前记账的内存
+func MemoryUsage(ctx context.Context) int64 {
+	account, ok := ctx.Value(MemoryAccountKey).(*MemoryAccount)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(&account.used)
+}
+
+// accountedBody 读取请求体时记账，读取 n 字节按照 n 字节计算
+type accountedBody struct {
+	io.ReadCloser
+	account *MemoryAccount
+	err     error
+}
+
+func (b *accountedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	if cerr := b.account.charge(int64(n)); cerr != nil {
+		b.err = cerr
+		return 0, cerr
+	}
+	return n, err
+}
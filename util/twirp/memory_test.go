@@ -0,0 +1,47 @@
+package twirp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMemoryAccount(t *testing.T) {
+	defer SetMemoryBudget(0)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 100)))
+	ctx := WithMemoryAccount(WithHttpRequest(context.Background(), req), 150)
+
+	if _, err := ioutil.ReadAll(req.Body); err != nil {
+		t.Fatal(err)
+	}
+	if got := MemoryUsage(ctx); got != 100 {
+		t.Errorf("usage after reading body = %d, want 100", got)
+	}
+
+	err := ChargeMemory(ctx, 60)
+	if twerr, ok := err.(Error); !ok || twerr.Code() != ResourceExhausted || twerr.Meta("memory_limit") != "150" {
+		t.Errorf("charge over limit = %v", err)
+	}
+	if got := MemoryInUse(); got != 160 {
+		t.Errorf("in use = %d, want 160", got)
+	}
+
+	SetMemoryBudget(100)
+	if err := CheckMemoryBudget(); err == nil {
+		t.Error("budget should be exhausted")
+	}
+
+	if got := ReleaseMemory(ctx); got != 160 {
+		t.Errorf("released = %d, want 160", got)
+	}
+	if MemoryInUse() != 0 || CheckMemoryBudget() != nil {
+		t.Error("memory should be released")
+	}
+
+	if err := ChargeMemory(context.Background(), 1<<30); err != nil {
+		t.Errorf("charge without account = %v", err)
+	}
+}
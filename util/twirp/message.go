@@ -0,0 +1,28 @@
+package twirp
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestMessage 返回 WithRequest 保存的请求对象，请求还没有解析时返回 nil
+//
+// 请求对象在调用业务方法之前保存，业务方法和 hook 拿到的是同一个对象，
+// hook 只能读取，不要修改其中的字段。开启 message_pool 后请求对象会在
+// 响应输出之后放回对象池复用，不要在 hook 返回之后继续引用，需要保留
+// 时使用 proto.Clone 复制。
+func RequestMessage(ctx context.Context) proto.Message {
+	req, _ := Request(ctx)
+	return req
+}
+
+// ResponseMessage 返回 WithResponse 保存的响应对象，业务方法还没有返回时为 nil
+//
+// 响应对象在 ResponsePrepared 之前保存，hook 修改字段会影响输出的内容，
+// 只有确实需要改写响应时才在 ResponsePrepared 中修改，其他阶段只能读取。
+// 复用规则跟 RequestMessage 相同。
+func ResponseMessage(ctx context.Context) proto.Message {
+	resp, _ := Response(ctx)
+	return resp
+}
@@ -0,0 +1,29 @@
+//go:build go1.18
+
+package twirp
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestAs 返回指定类型的请求对象，请求不存在或者类型不匹配时 ok 为 false
+//
+//	if req, ok := twirp.RequestAs[*user.GetInfoReq](ctx); ok {
+//		log.Println(req.GetUserId())
+//	}
+//
+// 使用规则见 RequestMessage。需要 Go 1.18 及以上版本。
+func RequestAs[T proto.Message](ctx context.Context) (T, bool) {
+	req, ok := ctx.Value(RequestKey).(T)
+	return req, ok
+}
+
+// ResponseAs 返回指定类型的响应对象，响应不存在或者类型不匹配时 ok 为 false
+//
+// 使用规则见 ResponseMessage。需要 Go 1.18 及以上版本。
+func ResponseAs[T proto.Message](ctx context.Context) (T, bool) {
+	resp, ok := ctx.Value(ResponseKey).(T)
+	return resp, ok
+}
@@ -0,0 +1,38 @@
+//go:build go1.18
+
+package twirp
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRequestMessage(t *testing.T) {
+	ctx := context.Background()
+	if RequestMessage(ctx) != nil || ResponseMessage(ctx) != nil {
+		t.Fatal("message should be nil before WithRequest")
+	}
+	if _, ok := RequestAs[*wrapperspb.StringValue](ctx); ok {
+		t.Fatal("RequestAs should fail before WithRequest")
+	}
+
+	req := wrapperspb.String("hello")
+	ctx = WithRequest(ctx, req)
+	ctx = WithResponse(ctx, &emptypb.Empty{})
+
+	if RequestMessage(ctx) != req {
+		t.Error("RequestMessage should return the request")
+	}
+	if got, ok := RequestAs[*wrapperspb.StringValue](ctx); !ok || got.GetValue() != "hello" {
+		t.Errorf("RequestAs = %v %v", got, ok)
+	}
+	if _, ok := RequestAs[*emptypb.Empty](ctx); ok {
+		t.Error("RequestAs should fail with mismatched type")
+	}
+	if _, ok := ResponseAs[*emptypb.Empty](ctx); !ok {
+		t.Error("ResponseAs should return the response")
+	}
+}
@@ -0,0 +1,101 @@
+package twirp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrorDoc 接口声明的错误码及说明，来自接口注释中的 @error 注解
+type ErrorDoc struct {
+	Code    ErrorCode
+	Comment string
+}
+
+// MethodInfo 接口元信息，由 protoc-gen-twirp 生成代码在 init 阶段注册
+type MethodInfo struct {
+	Service string
+	Method  string
+	Path    string
+	Errors  []ErrorDoc
+
+	// Owner、Team、Oncall 来自接口或服务注释中的 @owner、@team、@oncall 注解，
+	// 监控指标按 Team、Oncall 打标签，告警可以直接路由到负责的团队
+	Owner  string
+	Team   string
+	Oncall string
+
+	// Source 定义接口的 proto 文件，Generator 生成代码的 protoc-gen-twirp 版本
+	Source    string
+	Generator string
+}
+
+// Documented 判断错误码是否已在 @error 中声明
+func (m MethodInfo) Documented(code ErrorCode) bool {
+	for _, e := range m.Errors {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	methodsMu sync.RWMutex
+	methods   = map[string]MethodInfo{}
+)
+
+// RegisterMethod 注册接口元信息，路径重复时后注册的覆盖先注册的
+func RegisterMethod(info MethodInfo) {
+	methodsMu.Lock()
+	defer methodsMu.Unlock()
+	methods[info.Path] = info
+}
+
+// LookupMethod 按请求路径查询接口元信息
+func LookupMethod(path string) (MethodInfo, bool) {
+	methodsMu.RLock()
+	defer methodsMu.RUnlock()
+	info, ok := methods[path]
+	return info, ok
+}
+
+// Methods 返回所有已注册的接口，按路径排序
+func Methods() []MethodInfo {
+	methodsMu.RLock()
+	defer methodsMu.RUnlock()
+
+	infos := make([]MethodInfo, 0, len(methods))
+	for _, info := range methods {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Path < infos[j].Path })
+	return infos
+}
+
+// CheckErrorCode 检查业务方法返回的错误码是否已在 @error 中声明
+//
+// 只在使用 sniper_debug 构建标签编译时生效，未声明的错误码会被替换成
+// Internal 错误，方便在测试阶段发现文档与实现不一致。非 twirp.Error
+// 会被当作 Internal 错误处理，所以总是允许。
+func CheckErrorCode(path string, err error) error {
+	if !checkErrorCode || err == nil {
+		return err
+	}
+
+	// 任何接口都可能因为请求取消或者超时返回 Canceled、DeadlineExceeded，不需要声明
+	twerr, ok := err.(Error)
+	if !ok || twerr.Code() == Internal || twerr.Code() == Canceled || twerr.Code() == DeadlineExceeded {
+		return err
+	}
+
+	info, ok := LookupMethod(path)
+	if !ok || info.Documented(twerr.Code()) {
+		return err
+	}
+
+	msg := fmt.Sprintf("%s returned undocumented error code %q", path, twerr.Code())
+	return NewError(Internal, msg).
+		WithMeta("code", string(twerr.Code())).
+		WithMeta("msg", twerr.Msg())
+}
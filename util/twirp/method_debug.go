@@ -0,0 +1,7 @@
+//go:build sniper_debug
+// +build sniper_debug
+
+package twirp
+
+// checkErrorCode 使用 sniper_debug 构建时检查接口返回的错误码
+const checkErrorCode = true
@@ -0,0 +1,6 @@
+//go:build !sniper_debug
+// +build !sniper_debug
+
+package twirp
+
+const checkErrorCode = false
@@ -0,0 +1,82 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+)
+
+// PriorityHeader 传递请求优先级的请求头
+const PriorityHeader = "Sniper-Priority"
+
+// Priority 请求优先级
+//
+// 过载时限流、连接池、消息队列等组件应该先拒绝低优先级的请求，
+// 避免批处理流量挤占在线请求。零值为 PriorityDefault。
+type Priority int
+
+const (
+	// PriorityBatch 批处理、离线任务等可以延后的请求
+	PriorityBatch Priority = iota - 1
+	// PriorityDefault 普通在线请求
+	PriorityDefault
+	// PriorityCritical 核心链路请求，过载时最后被拒绝
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityBatch:
+		return "batch"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "default"
+	}
+}
+
+// ParsePriority 解析 critical、default、batch 三种优先级
+func ParsePriority(s string) (Priority, bool) {
+	switch s {
+	case "batch":
+		return PriorityBatch, true
+	case "default":
+		return PriorityDefault, true
+	case "critical":
+		return PriorityCritical, true
+	}
+	return PriorityDefault, false
+}
+
+// WithPriority 设置请求优先级
+//
+// 优先级会通过 PriorityHeader 传给下游的 twirp 服务，
+// 比如离线任务可以将所有请求标记为 PriorityBatch。
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, PriorityKey, p)
+}
+
+// WithDefaultPriority ctx 没有设置优先级时使用 p，由生成的客户端调用
+func WithDefaultPriority(ctx context.Context, p Priority) context.Context {
+	if _, ok := ctx.Value(PriorityKey).(Priority); ok {
+		return ctx
+	}
+	return WithPriority(ctx, p)
+}
+
+// GetPriority 返回请求优先级，没有设置时返回 PriorityDefault
+func GetPriority(ctx context.Context) Priority {
+	p, _ := ctx.Value(PriorityKey).(Priority)
+	return p
+}
+
+// WithRequestPriority 根据请求头设置服务端的请求优先级，由生成的代码调用
+//
+// max 为接口 @priority 注解声明的优先级。调用方可以降低优先级，但不能高于 max，
+// 否则任何客户端都可以把请求标记为 critical。
+func WithRequestPriority(ctx context.Context, req *http.Request, max Priority) context.Context {
+	p, ok := ParsePriority(req.Header.Get(PriorityHeader))
+	if !ok || p > max {
+		p = max
+	}
+	return WithPriority(ctx, p)
+}
@@ -0,0 +1,75 @@
+package twirp
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType RFC 7807 错误响应的 Content-Type
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypePrefix 错误响应 type 字段的前缀，后面拼接错误码，如 urn:twirp:error:not_found
+var ProblemTypePrefix = "urn:twirp:error:"
+
+// WithProblemDetails 开启 RFC 7807 格式的错误响应，由服务注解 @problem 生成
+//
+// 开启后只有请求头 Accept 包含 application/problem+json 时才使用该格式，
+// 其他请求仍然返回 twirp 的错误格式。
+func WithProblemDetails(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ProblemDetailsKey, true)
+}
+
+// acceptProblem 判断当前请求是否需要返回 RFC 7807 格式的错误
+func acceptProblem(ctx context.Context) bool {
+	if enabled, _ := ctx.Value(ProblemDetailsKey).(bool); !enabled || twitchCompat(ctx) {
+		return false
+	}
+	req, ok := HttpRequest(ctx)
+	if !ok {
+		return false
+	}
+
+	for _, accept := range req.Header["Accept"] {
+		for _, r := range strings.Split(accept, ",") {
+			if t, _, err := mime.ParseMediaType(r); err == nil && t == ProblemContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// marshalErrorToProblem 将 twirp.Error 转换为 RFC 7807 格式
+//
+// 错误码和 meta 作为扩展字段输出，调用方不需要根据 type 反推错误码。
+func marshalErrorToProblem(twerr Error, status int) []byte {
+	msg := twerr.Msg()
+	if len(msg) > 1e6 {
+		msg = msg[:1e6]
+	}
+
+	type problemJSON struct {
+		Type   string            `json:"type"`
+		Title  string            `json:"title"`
+		Status int               `json:"status"`
+		Detail string            `json:"detail,omitempty"`
+		Code   string            `json:"code"`
+		Meta   map[string]string `json:"meta,omitempty"`
+	}
+
+	buf, err := json.Marshal(&problemJSON{
+		Type:   ProblemTypePrefix + string(twerr.Code()),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: msg,
+		Code:   string(twerr.Code()),
+		Meta:   twerr.MetaMap(),
+	})
+	if err != nil {
+		return marshalErrorToJSON(twerr)
+	}
+	return buf
+}
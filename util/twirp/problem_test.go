@@ -0,0 +1,53 @@
+package twirp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	write := func(enabled bool, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/echo.Echo/Hello", nil)
+		req.Header.Set("Accept", accept)
+
+		ctx := WithHttpRequest(context.Background(), req)
+		if enabled {
+			ctx = WithProblemDetails(ctx)
+		}
+
+		resp := httptest.NewRecorder()
+		(&ServerHooks{}).WriteError(ctx, resp, NotFoundError("no such user").WithMeta("uid", "1"))
+		return resp
+	}
+
+	if ct := write(false, ProblemContentType).Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type of disabled service = %q, want application/json", ct)
+	}
+	if ct := write(true, "application/json").Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type without Accept = %q, want application/json", ct)
+	}
+
+	resp := write(true, "application/json, application/problem+json;q=0.9")
+	if ct := resp.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Fatalf("Content-Type = %q, want %q", ct, ProblemContentType)
+	}
+
+	var problem map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"type":   "urn:twirp:error:not_found",
+		"title":  "Not Found",
+		"status": float64(404),
+		"detail": "no such user",
+		"code":   "not_found",
+		"meta":   map[string]interface{}{"uid": "1"},
+	}
+	if !reflect.DeepEqual(problem, want) {
+		t.Errorf("problem = %v, want %v", problem, want)
+	}
+}
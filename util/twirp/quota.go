@@ -0,0 +1,49 @@
+package twirp
+
+import (
+	"context"
+	"strconv"
+)
+
+// QuotaChecker 配额检查接口
+//
+// 接口使用 @quota:name=limit 注解后，生成的代码会在参数校验之后、调用业务方法之前
+// 调用 Take，配额不足时返回 ResourceExhausted 错误。
+type QuotaChecker interface {
+	// Take 为当前请求（用户）消耗一次名为 name 的配额，limit 为周期内的配额总数
+	// 返回剩余配额，配额不足时 ok 为 false
+	Take(ctx context.Context, name string, limit int64) (remaining int64, ok bool, err error)
+}
+
+// WithQuotaChecker 注入配额检查对象，一般在 hook.RequestReceived 阶段调用
+func WithQuotaChecker(ctx context.Context, checker QuotaChecker) context.Context {
+	return context.WithValue(ctx, QuotaCheckerKey, checker)
+}
+
+// CheckQuota 消耗一次配额，配额不足时返回带有 quota、limit、remaining 元信息的
+// ResourceExhausted 错误
+//
+// 如果 ctx 中没有注入 QuotaChecker，则返回 Internal 错误，避免配额因为漏掉注册而不生效。
+// Take 返回的 twirp.Error 原样返回，其他错误包装成 Internal 错误。
+func CheckQuota(ctx context.Context, name string, limit int64) error {
+	checker, ok := ctx.Value(QuotaCheckerKey).(QuotaChecker)
+	if !ok {
+		return InternalError("no QuotaChecker for quota " + name)
+	}
+
+	remaining, ok, err := checker.Take(ctx, name, limit)
+	if err != nil {
+		if twerr, ok := err.(Error); ok {
+			return twerr
+		}
+		return InternalErrorWith(err)
+	}
+	if ok {
+		return nil
+	}
+
+	return NewError(ResourceExhausted, "quota "+name+" exceeded").
+		WithMeta("quota", name).
+		WithMeta("limit", strconv.FormatInt(limit, 10)).
+		WithMeta("remaining", strconv.FormatInt(remaining, 10))
+}
@@ -0,0 +1,34 @@
+package twirp
+
+import (
+	"context"
+	"testing"
+)
+
+type quotaFunc func(ctx context.Context, name string, limit int64) (int64, bool, error)
+
+func (f quotaFunc) Take(ctx context.Context, name string, limit int64) (int64, bool, error) {
+	return f(ctx, name, limit)
+}
+
+func TestCheckQuota(t *testing.T) {
+	if err, ok := CheckQuota(context.Background(), "daily", 10).(Error); !ok || err.Code() != Internal {
+		t.Errorf("CheckQuota without checker = %v, want internal error", err)
+	}
+
+	remaining := int64(1)
+	ctx := WithQuotaChecker(context.Background(), quotaFunc(func(ctx context.Context, name string, limit int64) (int64, bool, error) {
+		if remaining == 0 {
+			return 0, false, nil
+		}
+		remaining--
+		return remaining, true, nil
+	}))
+	if err := CheckQuota(ctx, "daily", 1); err != nil {
+		t.Fatalf("first CheckQuota = %v", err)
+	}
+	err, ok := CheckQuota(ctx, "daily", 1).(Error)
+	if !ok || err.Code() != ResourceExhausted || err.Meta("quota") != "daily" || err.Meta("limit") != "1" || err.Meta("remaining") != "0" {
+		t.Errorf("exhausted CheckQuota = %v", err)
+	}
+}
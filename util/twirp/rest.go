@@ -0,0 +1,257 @@
+package twirp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RESTRoute google.api.http 注解声明的 RESTful 路由，由生成的代码创建
+//
+// 路径模板的语法见 https://github.com/googleapis/googleapis/blob/master/google/api/http.proto，
+// 支持 *、** 通配符，{field}、{field=segments} 变量以及 :verb 后缀。
+type RESTRoute struct {
+	method   string
+	path     string
+	body     string
+	segments []string
+	vars     []restVar
+	verb     string
+}
+
+// restVar 路径变量，对应 segments[start:end]，end 为 -1 表示一直到路径结尾
+type restVar struct {
+	field      string
+	start, end int
+}
+
+// NewRESTRoute 创建 method 请求 pattern 路径时调用 path 接口的路由
+//
+// body 为 "*" 表示请求体为完整的请求消息，为空表示没有请求体，参数从 query 中按照表单格式解析。
+// pattern 格式不正确时 panic，生成代码时已经检查过。
+func NewRESTRoute(method, pattern, path, body string) *RESTRoute {
+	r, err := parseRESTRoute(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("invalid rest pattern %q: %v", pattern, err))
+	}
+	r.method, r.path, r.body = method, path, body
+	return r
+}
+
+func parseRESTRoute(pattern string) (*RESTRoute, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("pattern must start with /")
+	}
+	r := &RESTRoute{}
+	rest := pattern[1:]
+	if i := strings.LastIndexByte(rest, ':'); i != -1 && !strings.ContainsAny(rest[i:], "/}") {
+		rest, r.verb = rest[:i], rest[i+1:]
+	}
+
+	for rest != "" {
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed variable")
+			}
+			field, sub := rest[1:end], "*"
+			if i := strings.IndexByte(field, '='); i != -1 {
+				field, sub = field[:i], field[i+1:]
+			}
+			if field == "" || sub == "" {
+				return nil, fmt.Errorf("empty variable")
+			}
+			v := restVar{field: field, start: len(r.segments)}
+			r.segments = append(r.segments, strings.Split(sub, "/")...)
+			v.end = len(r.segments)
+			if r.segments[v.end-1] == "**" {
+				v.end = -1
+			}
+			r.vars = append(r.vars, v)
+			rest = rest[end+1:]
+		} else {
+			end := strings.IndexByte(rest, '/')
+			if end == -1 {
+				end = len(rest)
+			}
+			r.segments = append(r.segments, rest[:end])
+			rest = rest[end:]
+		}
+		if rest != "" {
+			if rest[0] != '/' {
+				return nil, fmt.Errorf("variable must be a whole segment")
+			}
+			rest = rest[1:]
+		}
+	}
+
+	for i, s := range r.segments {
+		if s == "" || strings.ContainsAny(s, "{}=") {
+			return nil, fmt.Errorf("invalid segment %q", s)
+		}
+		if s == "**" && i != len(r.segments)-1 {
+			return nil, fmt.Errorf("** must be the last segment")
+		}
+	}
+	return r, nil
+}
+
+// match 匹配请求路径，返回路径变量
+func (r *RESTRoute) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if r.verb != "" {
+		if !strings.HasSuffix(path, ":"+r.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+r.verb)
+	}
+
+	parts := strings.Split(path, "/")
+	n := len(r.segments)
+	if n > 0 && r.segments[n-1] == "**" {
+		if len(parts) < n-1 {
+			return nil, false
+		}
+	} else if len(parts) != n {
+		return nil, false
+	}
+	for i, s := range r.segments {
+		if s == "**" {
+			break
+		}
+		if s == "*" && parts[i] == "" || s != "*" && s != parts[i] {
+			return nil, false
+		}
+	}
+
+	params := make(map[string]string, len(r.vars))
+	for _, v := range r.vars {
+		end := v.end
+		if end == -1 {
+			end = len(parts)
+		}
+		value, err := url.PathUnescape(strings.Join(parts[v.start:end], "/"))
+		if err != nil {
+			return nil, false
+		}
+		params[v.field] = value
+	}
+	return params, true
+}
+
+// RouteREST 按照 routes 匹配 RESTful 请求，由生成的代码在分发请求之前调用
+//
+// 匹配成功时把请求改写为对应 twirp 接口的 POST 请求，之后与普通的 twirp 请求完全相同；
+// 路径变量保存在 ctx 中，解析请求之后由 BindPathParams 赋值。没有匹配的请求不做修改。
+func RouteREST(ctx context.Context, req *http.Request, routes []*RESTRoute) context.Context {
+	for _, r := range routes {
+		if r.method != req.Method {
+			continue
+		}
+		params, ok := r.match(req.URL.EscapedPath())
+		if !ok {
+			continue
+		}
+
+		req.Method = http.MethodPost
+		req.URL.Path, req.URL.RawPath = r.path, ""
+		if r.body == "" {
+			// 没有请求体，query 参数按照表单请求解析
+			req.Body, req.ContentLength = http.NoBody, 0
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Del("Content-Encoding")
+		} else if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return context.WithValue(ctx, pathParamsKey, params)
+	}
+	return ctx
+}
+
+// PathParams 返回 RouteREST 匹配的路径变量，key 为字段路径，如 user.user_id
+func PathParams(ctx context.Context) (map[string]string, bool) {
+	params, ok := ctx.Value(pathParamsKey).(map[string]string)
+	return params, ok
+}
+
+// BindPathParams 把路径变量赋值到请求消息的字段中，覆盖请求体或者 query 中的同名参数
+func BindPathParams(ctx context.Context, m proto.Message) error {
+	params, ok := PathParams(ctx)
+	if !ok {
+		return nil
+	}
+	for path, value := range params {
+		msg := m.ProtoReflect()
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			fd := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+			if fd == nil || fd.IsList() || fd.IsMap() {
+				return InternalError("invalid path variable " + path)
+			}
+			if i < len(names)-1 {
+				if fd.Message() == nil {
+					return InternalError("invalid path variable " + path)
+				}
+				msg = msg.Mutable(fd).Message()
+				continue
+			}
+			v, err := parsePathParam(fd, value)
+			if err != nil {
+				return InvalidArgumentError(path, err.Error())
+			}
+			msg.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+func parsePathParam(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		b, err := base64.URLEncoding.DecodeString(s)
+		if err != nil {
+			b, err = base64.StdEncoding.DecodeString(s)
+		}
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field type %s", fd.Kind())
+}
@@ -0,0 +1,101 @@
+package twirp
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRESTRouteMatch(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		path    string
+		params  map[string]string
+	}{
+		{"/v1/users/{user_id}", "/v1/users/1", map[string]string{"user_id": "1"}},
+		{"/v1/users/{user_id}", "/v1/users/1/posts", nil},
+		{"/v1/users/{user_id}", "/v1/users/", nil},
+		{"/v1/users/{user.user_id}/posts/*", "/v1/users/a%2Fb/posts/2", map[string]string{"user.user_id": "a/b"}},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books/2", map[string]string{"name": "shelves/1/books/2"}},
+		{"/v1/{name=shelves/*}", "/v1/books/1", nil},
+		{"/v1/files/{path=**}", "/v1/files/a/b/c", map[string]string{"path": "a/b/c"}},
+		{"/v1/users/{user_id}:cancel", "/v1/users/1:cancel", map[string]string{"user_id": "1"}},
+		{"/v1/users/{user_id}:cancel", "/v1/users/1", nil},
+		{"/v1/users", "/v1/users", map[string]string{}},
+	} {
+		params, ok := NewRESTRoute("GET", c.pattern, "/echo.v1.Echo/Hello", "").match(c.path)
+		if ok != (c.params != nil) || ok && !reflect.DeepEqual(params, c.params) {
+			t.Errorf("%s %s: have %v %v, want %v", c.pattern, c.path, params, ok, c.params)
+		}
+	}
+
+	for _, pattern := range []string{"v1/users", "/v1/{user_id", "/v1/x{user_id}", "/v1/**/users", "/v1//users"} {
+		if _, err := parseRESTRoute(pattern); err == nil {
+			t.Errorf("%s should be invalid", pattern)
+		}
+	}
+}
+
+func TestRouteREST(t *testing.T) {
+	routes := []*RESTRoute{
+		NewRESTRoute("GET", "/v1/fields/{name}", "/echo.v1.Echo/Get", ""),
+		NewRESTRoute("POST", "/v1/fields/{name}", "/echo.v1.Echo/Update", "*"),
+	}
+
+	req := httptest.NewRequest("GET", "/v1/fields/foo?number=3", strings.NewReader("ignored"))
+	ctx := RouteREST(context.Background(), req, routes)
+	if req.Method != "POST" || req.URL.Path != "/echo.v1.Echo/Get" || req.URL.RawQuery != "number=3" ||
+		req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" || req.ContentLength != 0 {
+		t.Errorf("GET request is not rewritten: %s %s %v", req.Method, req.URL, req.Header)
+	}
+
+	m := &descriptorpb.FieldDescriptorProto{Name: proto.String("bar")}
+	if err := BindPathParams(ctx, m); err != nil || m.GetName() != "foo" {
+		t.Errorf("bind name: %v, %v", m, err)
+	}
+
+	req = httptest.NewRequest("POST", "/v1/fields/foo", strings.NewReader("{}"))
+	RouteREST(context.Background(), req, routes)
+	if req.URL.Path != "/echo.v1.Echo/Update" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("POST request is not rewritten: %s %v", req.URL, req.Header)
+	}
+
+	req = httptest.NewRequest("DELETE", "/v1/fields/foo", nil)
+	if ctx := RouteREST(context.Background(), req, routes); req.URL.Path != "/v1/fields/foo" {
+		t.Errorf("unmatched request should not be rewritten: %s", req.URL)
+	} else if _, ok := PathParams(ctx); ok {
+		t.Error("unmatched request should not have path params")
+	}
+}
+
+func TestBindPathParams(t *testing.T) {
+	ctx := context.WithValue(context.Background(), pathParamsKey, map[string]string{
+		"name":               "foo",
+		"number":             "3",
+		"label":              "LABEL_REPEATED",
+		"options.deprecated": "true",
+	})
+	m := &descriptorpb.FieldDescriptorProto{}
+	if err := BindPathParams(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	want := &descriptorpb.FieldDescriptorProto{
+		Name:    proto.String("foo"),
+		Number:  proto.Int32(3),
+		Label:   descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		Options: &descriptorpb.FieldOptions{Deprecated: proto.Bool(true)},
+	}
+	if !proto.Equal(m, want) {
+		t.Errorf("have %v, want %v", m, want)
+	}
+
+	ctx = context.WithValue(context.Background(), pathParamsKey, map[string]string{"number": "x"})
+	if err := BindPathParams(ctx, m); err == nil || err.(Error).Code() != InvalidArgument {
+		t.Errorf("invalid number should be rejected, have %v", err)
+	}
+}
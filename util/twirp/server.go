@@ -1,6 +1,13 @@
 package twirp
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
 
 // Server is the interface generated server structs will support: they're
 // HTTP handlers with additional methods for accessing metadata about the
@@ -21,3 +28,108 @@ type Server interface {
 	// twirp used to generate this file.
 	ProtocGenTwirpVersion() string
 }
+
+// MarshalFunc 响应编码函数，返回编码后的内容和对应的 Content-Type
+type MarshalFunc func(proto.Message) ([]byte, string, error)
+
+// jsonMarshaler 编码 JSON 响应，与 inline_serve 生成的代码使用相同的选项
+var jsonMarshaler = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true, Resolver: AnyResolver}
+
+// MarshalJSON 使用 JSON 编码响应，json 和表单请求共用
+func MarshalJSON(m proto.Message) ([]byte, string, error) {
+	b, err := jsonMarshaler.Marshal(proto.MessageV2(m))
+	if err != nil {
+		return nil, "", wrapErr(err, "failed to marshal json response")
+	}
+	return b, "application/json", nil
+}
+
+// MarshalProtobuf 使用 protobuf 编码响应
+func MarshalProtobuf(m proto.Message) ([]byte, string, error) {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, "", wrapErr(err, "failed to marshal proto response")
+	}
+	return b, "application/protobuf", nil
+}
+
+// WriteNoContent 输出没有响应体的 204 响应并触发相关 hooks
+//
+// 开启 empty_no_content 参数后，返回 google.protobuf.Empty 的接口由生成的代码调用本函数，
+// 不再编码 {} 响应体。
+func WriteNoContent(ctx context.Context, resp http.ResponseWriter, hooks *ServerHooks, respContent proto.Message) {
+	ctx = WithResponse(ctx, respContent)
+
+	ctx = hooks.CallResponsePrepared(ctx)
+
+	MarkServerTiming(ctx, "marshal")
+	ctx = WithStatusCode(ctx, http.StatusNoContent)
+	WriteResponseHeader(ctx, resp)
+	resp.WriteHeader(http.StatusNoContent)
+	hooks.CallResponseSent(ctx)
+}
+
+// WriteResponse 输出业务方法返回的 respContent 并触发相关 hooks
+//
+// 生成的代码默认调用本函数输出响应，不再为每个接口方法重复生成相同的逻辑。
+// 如果 respContent 实现了 GetContentType 和 GetData 方法，则直接输出 GetData 的内容，
+// 否则使用 marshal 编码；经过 WithChunkedResponse 标记的大响应会分块编码和输出。
+func WriteResponse(ctx context.Context, resp http.ResponseWriter, hooks *ServerHooks, respContent proto.Message, marshal MarshalFunc) {
+	ctx = WithResponse(ctx, respContent)
+
+	ctx = hooks.CallResponsePrepared(ctx)
+
+	type httpBody interface {
+		GetContentType() string
+		GetData() []byte
+	}
+
+	var respBytes []byte
+	var respStatus = http.StatusOK
+	if body, ok := respContent.(httpBody); ok {
+		type httpStatus interface{ GetStatus() int32 }
+		if statusBody, ok := respContent.(httpStatus); ok {
+			if status := statusBody.GetStatus(); status > 0 {
+				respStatus = int(status)
+			}
+		}
+		if contentType := body.GetContentType(); contentType != "" {
+			resp.Header().Set("Content-Type", contentType)
+		}
+		respBytes = body.GetData()
+	} else if chunkedResponse(ctx, respContent) {
+		writeChunkedResponse(ctx, resp, hooks, respContent)
+		return
+	} else {
+		b, contentType, err := marshal(respContent)
+		if err != nil {
+			hooks.WriteError(ctx, resp, InternalErrorWith(err))
+			return
+		}
+		respBytes = b
+		resp.Header().Set("Content-Type", contentType)
+	}
+
+	if err := ChargeMemory(ctx, int64(len(respBytes))); err != nil {
+		hooks.WriteError(ctx, resp, err)
+		return
+	}
+
+	respBytes = CompressResponse(ctx, resp, respBytes)
+	respBytes, err := EncryptResponse(ctx, respBytes)
+	if err != nil {
+		hooks.WriteError(ctx, resp, InternalErrorWith(err))
+		return
+	}
+
+	MarkServerTiming(ctx, "marshal")
+	ctx = WithStatusCode(ctx, respStatus)
+	WriteResponseHeader(ctx, resp)
+	resp.WriteHeader(respStatus)
+
+	if n, err := resp.Write(respBytes); err != nil {
+		msg := fmt.Sprintf("failed to write response, %d of %d bytes written: %s", n, len(respBytes), err.Error())
+		hooks.CallError(ctx, NewError(Unknown, msg))
+	}
+	hooks.CallResponseSent(ctx)
+}
@@ -0,0 +1,367 @@
+package twirp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// 服务端流式接口（returns (stream Resp)）的响应格式
+//
+// JSON 请求的响应为 NDJSON，每行一个 JSON 对象，{"result": {...}} 为一条消息，
+// 最后一行为 {"done": true} 或者 {"error": {"code": "...", "msg": "..."}}。
+// 其他编码的响应由若干帧组成，每帧为 1 字节标志、4 字节大端长度和内容，
+// 标志为 StreamFrameMessage 时内容为一条消息，为 StreamFrameEnd 时内容与 NDJSON 的最后一行相同。
+//
+// 没有结束帧说明连接中断，客户端返回错误。开始输出消息之前出错时仍然输出普通的错误响应。
+const (
+	// ContentTypeNDJSON JSON 流式响应的 Content-Type
+	ContentTypeNDJSON = "application/x-ndjson"
+
+	// StreamFrameMessage 消息帧
+	StreamFrameMessage byte = 0
+	// StreamFrameEnd 结束帧
+	StreamFrameEnd byte = 1
+
+	// MaxStreamFrameSize 客户端允许的单帧最大长度
+	MaxStreamFrameSize = 16 << 20
+)
+
+// StreamContentType 返回使用 contentType 编码的流式响应的 Content-Type
+func StreamContentType(contentType string) string {
+	if normalizeContentType(contentType) == "application/json" {
+		return ContentTypeNDJSON
+	}
+	return contentType + "; framing=length-prefixed"
+}
+
+// streamTrailer NDJSON 的最后一行和结束帧的内容
+type streamTrailer struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Done   bool            `json:"done,omitempty"`
+	Error  *struct {
+		Code string            `json:"code"`
+		Msg  string            `json:"msg"`
+		Meta map[string]string `json:"meta,omitempty"`
+	} `json:"error,omitempty"`
+}
+
+// ServerStream 服务端流式接口的输出，生成的代码创建并传给业务方法
+//
+// 第一次调用 Send 时触发 ResponsePrepared 并输出响应头，Finish 时输出结束帧并触发 ResponseSent。
+// 消息通过 StreamWriter 合并输出，最多缓存 100ms。
+type ServerStream struct {
+	ctx         context.Context
+	resp        http.ResponseWriter
+	hooks       *ServerHooks
+	marshal     MarshalFunc
+	contentType string
+	// sse 为 true 时输出 Server-Sent Events，见 NewSSEServerStream
+	sse       bool
+	heartbeat time.Duration
+	stop      chan struct{}
+
+	mu     sync.Mutex
+	w      *StreamWriter
+	closed bool
+}
+
+// NewServerStream 返回使用 marshal 编码消息的 ServerStream
+func NewServerStream(ctx context.Context, resp http.ResponseWriter, hooks *ServerHooks, marshal MarshalFunc) *ServerStream {
+	// MarshalFunc 只在编码时返回 Content-Type，没有消息时也需要知道使用哪种格式
+	_, contentType, _ := marshal(&emptypb.Empty{})
+	return &ServerStream{ctx: ctx, resp: resp, hooks: hooks, marshal: marshal, contentType: contentType}
+}
+
+// Send 输出一条消息，客户端断开之后返回错误，业务方法应该停止输出并返回
+func (s *ServerStream) Send(m proto.Message) error {
+	b, _, err := s.marshal(m)
+	if err != nil {
+		return InternalErrorWith(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	s.start()
+
+	switch {
+	case s.sse:
+		_, err = s.w.Write(appendSSEEvent(nil, SSEEventMessage, b))
+	case s.ndjson():
+		_, err = s.w.Write(append(append([]byte(`{"result":`), b...), '}', '\n'))
+	default:
+		_, err = s.w.Write(appendFrame(nil, StreamFrameMessage, b))
+	}
+	return err
+}
+
+// Finish 结束输出，err 不为空时把错误写入结束帧
+//
+// 还没有输出消息时 err 作为普通的错误响应输出。重复调用时直接返回。
+func (s *ServerStream) Finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	if s.w == nil && err != nil {
+		s.hooks.WriteError(s.ctx, s.resp, err)
+		return
+	}
+	s.start()
+
+	var twerr Error
+	if err != nil {
+		var ok bool
+		if twerr, ok = err.(Error); !ok {
+			twerr = InternalErrorWith(err)
+		}
+		s.ctx = s.hooks.CallError(s.ctx, twerr)
+	}
+	trailer := encodeStreamTrailer(twerr)
+
+	switch {
+	case s.sse:
+		close(s.stop)
+		_, _ = s.w.Write(appendSSEEvent(nil, SSEEventEnd, trailer))
+	case s.ndjson():
+		_, _ = s.w.Write(append(trailer, '\n'))
+	default:
+		_, _ = s.w.Write(appendFrame(nil, StreamFrameEnd, trailer))
+	}
+	// 客户端已经断开时无法输出，与 WriteError 一样忽略写入错误
+	_ = s.w.Close()
+	s.hooks.CallResponseSent(s.ctx)
+}
+
+// start 输出响应头，调用方需要持有锁
+func (s *ServerStream) start() {
+	if s.w != nil {
+		return
+	}
+
+	s.ctx = WithStatusCode(s.ctx, http.StatusOK)
+	s.ctx = s.hooks.CallResponsePrepared(s.ctx)
+	WriteResponseHeader(s.ctx, s.resp)
+	if s.sse {
+		s.resp.Header().Set("Content-Type", ContentTypeEventStream)
+		s.resp.Header().Set("Cache-Control", "no-cache")
+		// 关闭 nginx 的响应缓冲，否则浏览器要等缓冲区满了才能收到事件
+		s.resp.Header().Set("X-Accel-Buffering", "no")
+	} else {
+		s.resp.Header().Set("Content-Type", StreamContentType(s.contentType))
+	}
+	s.resp.WriteHeader(http.StatusOK)
+	s.w = NewStreamWriter(s.ctx, s.resp, StreamOptions{})
+	if s.sse && s.heartbeat > 0 {
+		go s.beat(s.ctx.Done())
+	}
+}
+
+func (s *ServerStream) ndjson() bool {
+	return normalizeContentType(s.contentType) == "application/json"
+}
+
+func appendFrame(b []byte, flag byte, payload []byte) []byte {
+	var header [5]byte
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	return append(append(b, header[:]...), payload...)
+}
+
+// StreamReader 读取服务端流式接口的响应，生成的 {Service}{Method}Stream 使用
+//
+// 读到结束帧或者出错时关闭响应并触发客户端的 ResponseReceived 或者 Error 回调。
+type StreamReader struct {
+	ctx   context.Context
+	call  *clientCall
+	codec Codec
+	body  io.ReadCloser
+	r     *bufio.Reader
+	// sse 为 true 时响应为 Server-Sent Events，消息始终使用 JSON 编码
+	sse bool
+	err error
+}
+
+// DoStreamRequest 使用 c 编码请求，返回读取流式响应的 StreamReader
+func DoStreamRequest(ctx context.Context, client HTTPClient, c Codec, url string, in proto.Message) (_ *StreamReader, err error) {
+	reqBodyBytes, err := c.Marshal(in)
+	if err != nil {
+		return nil, clientError("failed to marshal "+c.Name()+" request", err)
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, clientError("aborted because context was done", err)
+	}
+
+	call := newClientCall(ctx)
+	defer func() {
+		if err != nil {
+			call.done(err)
+		}
+	}()
+
+	req, err := newRequest(ctx, url, bytes.NewReader(reqBodyBytes), c.ContentType())
+	if err != nil {
+		return nil, clientError("could not build request", err)
+	}
+	req.Header.Set("Accept", StreamContentType(c.ContentType()))
+	if req, err = call.prepare(req); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, clientError("failed to do request", &transportError{cause: err})
+	}
+
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		return nil, errorFromResponse(resp)
+	}
+
+	return &StreamReader{
+		ctx:   ctx,
+		call:  call,
+		codec: c,
+		body:  resp.Body,
+		r:     bufio.NewReader(resp.Body),
+		sse:   normalizeContentType(resp.Header.Get("Content-Type")) == ContentTypeEventStream,
+	}, nil
+}
+
+// Recv 读取下一条消息到 out，正常结束时返回 io.EOF，之后一直返回相同的错误
+func (s *StreamReader) Recv(out proto.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	err := s.next(out)
+	if err == nil {
+		return nil
+	}
+	if cerr := s.ctx.Err(); cerr != nil && err != io.EOF {
+		err = clientError("aborted because context was done", cerr)
+	}
+
+	s.err = err
+	_ = s.body.Close()
+	if err == io.EOF {
+		s.call.done(nil)
+	} else {
+		s.call.done(err)
+	}
+	return err
+}
+
+// Close 关闭响应，没有读到结束帧时按调用方取消处理
+func (s *StreamReader) Close() error {
+	if s.err != nil {
+		return nil
+	}
+	s.err = NewError(Canceled, "stream closed by client")
+	s.call.done(s.err)
+	return s.body.Close()
+}
+
+func (s *StreamReader) next(out proto.Message) error {
+	if s.sse {
+		return s.nextEvent(out)
+	}
+	if normalizeContentType(s.codec.ContentType()) == "application/json" {
+		line, err := s.r.ReadBytes('\n')
+		if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+			return clientError("stream ended without trailer", &transportError{cause: io.ErrUnexpectedEOF})
+		}
+		if err != nil && err != io.EOF {
+			return clientError("failed to read stream", &transportError{cause: err})
+		}
+		return decodeStreamFrame(s.codec, line, out)
+	}
+
+	var header [5]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return clientError("stream ended without trailer", &transportError{cause: err})
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > MaxStreamFrameSize {
+		return clientError("failed to read stream", fmt.Errorf("frame size %d exceeds %d", n, MaxStreamFrameSize))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		return clientError("failed to read stream", &transportError{cause: err})
+	}
+
+	switch header[0] {
+	case StreamFrameMessage:
+		if err := s.codec.Unmarshal(payload, out); err != nil {
+			return clientError("failed to unmarshal "+s.codec.Name()+" response", err)
+		}
+		return nil
+	case StreamFrameEnd:
+		return decodeStreamFrame(s.codec, payload, nil)
+	default:
+		return clientError("failed to read stream", fmt.Errorf("unknown frame flag %d", header[0]))
+	}
+}
+
+// encodeStreamTrailer 返回 NDJSON 的最后一行或者结束帧的内容
+func encodeStreamTrailer(twerr Error) []byte {
+	if twerr == nil {
+		return []byte(`{"done":true}`)
+	}
+	return append(append([]byte(`{"error":`), marshalErrorToJSON(twerr)...), '}')
+}
+
+// trailerError 把结束帧或者批量结果中的错误转换为 twirp.Error
+func trailerError(code, msg string, meta map[string]string) Error {
+	if !IsValidErrorCode(ErrorCode(code)) {
+		return InternalError("invalid type returned from server error response: " + code)
+	}
+	twerr := NewError(ErrorCode(code), msg)
+	for k, v := range meta {
+		twerr = twerr.WithMeta(k, v)
+	}
+	return twerr
+}
+
+// decodeStreamFrame 解析 NDJSON 的一行或者结束帧，结束帧返回 io.EOF 或者服务端返回的错误，
+// out 为空时只接受结束帧
+func decodeStreamFrame(c Codec, b []byte, out proto.Message) error {
+	var t streamTrailer
+	if err := json.Unmarshal(b, &t); err != nil {
+		return clientError("failed to unmarshal stream frame", err)
+	}
+
+	switch {
+	case t.Error != nil:
+		return trailerError(t.Error.Code, t.Error.Msg, t.Error.Meta)
+	case t.Done:
+		return io.EOF
+	case t.Result != nil && out != nil:
+		if err := c.Unmarshal(t.Result, out); err != nil {
+			return clientError("failed to unmarshal json response", err)
+		}
+		return nil
+	default:
+		return clientError("failed to unmarshal stream frame", fmt.Errorf("unexpected frame %s", b))
+	}
+}
@@ -0,0 +1,129 @@
+package twirp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// streamServer 按照 Content-Type 选择编码，输出请求中的每个字符作为一条消息，
+// 请求为 fail 时输出两条消息后返回错误，请求为 empty 时直接返回错误
+func streamServer(t *testing.T, sent *[]string) *httptest.Server {
+	hooks := &ServerHooks{
+		ResponseSent: func(ctx context.Context) { *sent = append(*sent, "sent") },
+		Error: func(ctx context.Context, err Error) context.Context {
+			*sent = append(*sent, string(err.Code()))
+			return ctx
+		},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		codec, _ := LookupCodec(r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		in := new(wrapperspb.StringValue)
+		if err := codec.Unmarshal(body, in); err != nil {
+			t.Fatal(err)
+		}
+
+		stream := NewServerStream(r.Context(), w, hooks, CodecMarshal(codec))
+		if in.Value == "empty" {
+			stream.Finish(NotFoundError("empty"))
+			return
+		}
+		for _, c := range in.Value {
+			if err := stream.Send(wrapperspb.String(string(c))); err != nil {
+				t.Fatal(err)
+			}
+			if in.Value == "fail" && c == 'a' {
+				stream.Finish(NewError(Unavailable, "failed"))
+				return
+			}
+		}
+		stream.Finish(nil)
+	}))
+}
+
+func TestServerStream(t *testing.T) {
+	var sent []string
+	ts := streamServer(t, &sent)
+	defer ts.Close()
+
+	for _, codec := range []Codec{JSONCodec, ProtobufCodec} {
+		recv := func(in string) (values []string, err error) {
+			r, err := DoStreamRequest(context.Background(), http.DefaultClient, codec, ts.URL, wrapperspb.String(in))
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			for {
+				out := new(wrapperspb.StringValue)
+				if err := r.Recv(out); err != nil {
+					if err == io.EOF {
+						err = nil
+					}
+					return values, err
+				}
+				values = append(values, out.Value)
+			}
+		}
+
+		sent = nil
+		values, err := recv("abc")
+		if err != nil || strings.Join(values, ",") != "a,b,c" {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		values, err = recv("fail")
+		if twerr, ok := err.(Error); !ok || twerr.Code() != Unavailable || strings.Join(values, ",") != "f,a" {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		values, err = recv("empty")
+		if twerr, ok := err.(Error); !ok || twerr.Code() != NotFound || len(values) != 0 {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		if want := "sent,unavailable,sent,not_found,sent"; strings.Join(sent, ",") != want {
+			t.Errorf("%s: server hooks = %v, want %s", codec.Name(), sent, want)
+		}
+	}
+}
+
+func TestServerStreamTruncated(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeNDJSON)
+		_, _ = w.Write([]byte(`{"result":"a"}` + "\n"))
+	}))
+	defer ts.Close()
+
+	var events []string
+	DefaultClientHooks = &ClientHooks{
+		ResponseReceived: func(ctx context.Context) { events = append(events, "ok") },
+		Error: func(ctx context.Context, err Error) {
+			if IsTransportError(err) {
+				events = append(events, "transport")
+			}
+		},
+	}
+	defer func() { DefaultClientHooks = nil }()
+
+	r, err := DoStreamRequest(context.Background(), http.DefaultClient, JSONCodec, ts.URL, wrapperspb.String("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(wrapperspb.StringValue)
+	if err := r.Recv(out); err != nil || out.Value != "a" {
+		t.Fatalf("Recv = %v, %v", out, err)
+	}
+	if err := r.Recv(out); err == nil || err == io.EOF || !IsTransportError(err) {
+		t.Errorf("truncated stream should return transport error, got %v", err)
+	}
+	_ = r.Close()
+	if strings.Join(events, ",") != "transport" {
+		t.Errorf("client hooks = %v", events)
+	}
+}
@@ -0,0 +1,148 @@
+package twirp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"sniper/util/ctxkit"
+)
+
+// ShadowResult 影子请求的结果
+type ShadowResult struct {
+	// Target 影子接口，格式为 Service/Method
+	Target string
+	// Request 请求的副本
+	Request proto.Message
+
+	// Response 和 Err 为原接口的返回值
+	Response proto.Message
+	Err      error
+
+	// ShadowResponse 和 ShadowErr 为影子接口的返回值
+	ShadowResponse proto.Message
+	ShadowErr      error
+}
+
+// Equal 判断原接口与影子接口的结果是否一致
+//
+// 都返回错误时只比较错误码
+func (r *ShadowResult) Equal() bool {
+	if r.Err != nil || r.ShadowErr != nil {
+		return errorCode(r.Err) == errorCode(r.ShadowErr)
+	}
+	return proto.Equal(r.Response, r.ShadowResponse)
+}
+
+func errorCode(err error) ErrorCode {
+	if err == nil {
+		return NoError
+	}
+	if twerr, ok := err.(Error); ok {
+		return twerr.Code()
+	}
+	return Internal
+}
+
+// Shadower 影子流量处理器
+//
+// 接口使用 @shadow:Service/Method 注解后，生成的代码会在业务方法返回之后，
+// 异步地把请求副本交给 Shadower 调用新的实现，然后通过 Compare 比较两者的结果。
+// 影子请求不会影响原接口的返回。
+type Shadower interface {
+	// Call 调用 target 对应的新实现
+	Call(ctx context.Context, target string, req proto.Message) (proto.Message, error)
+	// Compare 处理比较结果，如记录日志、统计差异率
+	Compare(ctx context.Context, result *ShadowResult)
+}
+
+// WithShadower 注入影子流量处理器，一般在 hook.RequestReceived 阶段调用
+func WithShadower(ctx context.Context, s Shadower) context.Context {
+	return context.WithValue(ctx, ShadowerKey, s)
+}
+
+// Shadow 异步发起影子请求，由生成的代码调用
+//
+// 请求和响应都会先复制一份，所以可以配合 message_pool 使用。
+// 影子请求使用的 ctx 保留原请求的值，但不会随原请求结束而取消。
+func Shadow(ctx context.Context, target string, req, resp proto.Message, err error) {
+	s, ok := ctx.Value(ShadowerKey).(Shadower)
+	if !ok {
+		return
+	}
+
+	result := &ShadowResult{
+		Target:  target,
+		Request: proto.Clone(req),
+		Err:     err,
+	}
+	if err == nil {
+		result.Response = proto.Clone(resp)
+	}
+	shadowReq := proto.Clone(req)
+
+	go func() {
+		ctx := ctxkit.Detach(ctx)
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					result.ShadowErr = InternalError(fmt.Sprintf("shadow %s panic: %v", target, r))
+				}
+			}()
+			result.ShadowResponse, result.ShadowErr = s.Call(ctx, target, shadowReq)
+		}()
+
+		s.Compare(ctx, result)
+	}()
+}
+
+// ShadowMethod 影子接口的实现
+type ShadowMethod func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+// ShadowMux 按 Service/Method 注册影子接口的 Shadower 实现
+type ShadowMux struct {
+	methods   map[string]ShadowMethod
+	onCompare func(ctx context.Context, result *ShadowResult)
+
+	// Timeout 影子请求的超时时间，为零表示不限制
+	Timeout time.Duration
+}
+
+// NewShadowMux 创建 ShadowMux，onCompare 用于处理比较结果
+func NewShadowMux(onCompare func(ctx context.Context, result *ShadowResult)) *ShadowMux {
+	return &ShadowMux{
+		methods:   map[string]ShadowMethod{},
+		onCompare: onCompare,
+	}
+}
+
+// Handle 注册 target 对应的实现，需要在服务启动之前调用
+func (m *ShadowMux) Handle(target string, method ShadowMethod) {
+	m.methods[target] = method
+}
+
+// Call 实现 Shadower 接口
+func (m *ShadowMux) Call(ctx context.Context, target string, req proto.Message) (proto.Message, error) {
+	method, ok := m.methods[target]
+	if !ok {
+		return nil, NewError(Unimplemented, "shadow "+target+" is not registered")
+	}
+
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+
+	return method(ctx, req)
+}
+
+// Compare 实现 Shadower 接口
+func (m *ShadowMux) Compare(ctx context.Context, result *ShadowResult) {
+	if m.onCompare != nil {
+		m.onCompare(ctx, result)
+	}
+}
@@ -0,0 +1,160 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// 服务端流式接口的 Server-Sent Events 输出格式，生成代码时传入 stream_transport=sse 开启
+//
+// 每条消息为一个 message 事件，data 为消息的 JSON；最后一个事件为 end，
+// data 与 NDJSON 的最后一行相同，为 {"done":true} 或者 {"error":{...}}。
+// 浏览器收到 end 事件之后需要关闭 EventSource，否则会自动重连。
+// 没有消息时每隔 SSEHeartbeatInterval 输出一行注释，避免连接被代理当作空闲连接断开。
+const (
+	// ContentTypeEventStream Server-Sent Events 的 Content-Type
+	ContentTypeEventStream = "text/event-stream"
+
+	// SSEEventMessage 消息事件
+	SSEEventMessage = "message"
+	// SSEEventEnd 结束事件
+	SSEEventEnd = "end"
+)
+
+// SSEHeartbeatInterval Server-Sent Events 心跳注释的间隔，为零表示不发送心跳
+var SSEHeartbeatInterval = 15 * time.Second
+
+// sseHeartbeat 心跳注释，EventSource 会忽略以冒号开头的行
+var sseHeartbeat = []byte(": heartbeat\n\n")
+
+// NewSSEServerStream 返回输出 Server-Sent Events 的 ServerStream
+//
+// 消息始终使用 JSON 编码，与请求的编码格式无关。
+func NewSSEServerStream(ctx context.Context, resp http.ResponseWriter, hooks *ServerHooks) *ServerStream {
+	return &ServerStream{
+		ctx:         ctx,
+		resp:        resp,
+		hooks:       hooks,
+		marshal:     MarshalJSON,
+		contentType: "application/json",
+		sse:         true,
+		heartbeat:   SSEHeartbeatInterval,
+		stop:        make(chan struct{}),
+	}
+}
+
+// beat 定时输出心跳注释，直到 Finish 或者客户端断开
+//
+// Finish 会修改 s.ctx，由 start 在持有锁时传入 done。
+func (s *ServerStream) beat(done <-chan struct{}) {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		case <-done:
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		_, err := s.w.Write(sseHeartbeat)
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// appendSSEEvent 追加一个事件，data 中的换行拆成多个 data 字段
+func appendSSEEvent(b []byte, event string, data []byte) []byte {
+	b = append(append(append(b, "event: "...), event...), '\n')
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b = append(append(append(b, "data: "...), data[:i]...), '\n')
+		data = data[i+1:]
+	}
+	return append(append(append(b, "data: "...), data...), '\n', '\n')
+}
+
+// nextEvent 读取下一个 message 或者 end 事件，跳过其他事件
+func (s *StreamReader) nextEvent(out proto.Message) error {
+	for {
+		event, data, err := s.readEvent()
+		if err != nil {
+			return err
+		}
+
+		switch event {
+		case "", SSEEventMessage:
+			if err := JSONCodec.Unmarshal(data, out); err != nil {
+				return clientError("failed to unmarshal json response", err)
+			}
+			return nil
+		case SSEEventEnd:
+			return decodeStreamFrame(JSONCodec, data, nil)
+		}
+	}
+}
+
+// readEvent 读取一个事件，忽略注释以及 id、retry 等字段
+//
+// 事件以空行结束，连接在事件中间断开时丢弃不完整的事件。
+func (s *StreamReader) readEvent() (string, []byte, error) {
+	var event string
+	var data []byte
+	fields := 0
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err == io.EOF {
+			return "", nil, clientError("stream ended without trailer", &transportError{cause: io.ErrUnexpectedEOF})
+		}
+		if err != nil {
+			return "", nil, clientError("failed to read stream", &transportError{cause: err})
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			if fields == 0 {
+				continue
+			}
+			return event, data, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		name, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			name, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+		fields++
+		switch string(name) {
+		case "event":
+			event = string(value)
+		case "data":
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+			if len(data) > MaxStreamFrameSize {
+				return "", nil, clientError("failed to read stream", fmt.Errorf("event size %d exceeds %d", len(data), MaxStreamFrameSize))
+			}
+		}
+	}
+}
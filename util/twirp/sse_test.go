@@ -0,0 +1,135 @@
+package twirp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// sseServer 与 streamServer 相同，但是输出 Server-Sent Events，每条消息之间等待 wait
+func sseServer(t *testing.T, wait time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in := wrapperspb.String(r.URL.Query().Get("value"))
+		if r.Method == http.MethodPost {
+			codec, _ := LookupCodec(r.Header.Get("Content-Type"))
+			body, _ := io.ReadAll(r.Body)
+			if err := codec.Unmarshal(body, in); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stream := NewSSEServerStream(r.Context(), w, &ServerHooks{})
+		if in.Value == "empty" {
+			stream.Finish(NotFoundError("empty"))
+			return
+		}
+		for _, c := range in.Value {
+			if err := stream.Send(wrapperspb.String(string(c))); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(wait)
+			if in.Value == "fail" && c == 'a' {
+				stream.Finish(NewError(Unavailable, "failed"))
+				return
+			}
+		}
+		stream.Finish(nil)
+	}))
+}
+
+func TestSSEServerStream(t *testing.T) {
+	interval := SSEHeartbeatInterval
+	SSEHeartbeatInterval = 20 * time.Millisecond
+	defer func() { SSEHeartbeatInterval = interval }()
+
+	ts := sseServer(t, 50*time.Millisecond)
+	defer ts.Close()
+
+	// EventSource 使用 GET 请求，按行读取原始输出
+	resp, err := http.Get(ts.URL + "?value=ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != ContentTypeEventStream {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, _ := io.ReadAll(bufio.NewReader(resp.Body))
+	for _, s := range []string{
+		"event: message\ndata: \"a\"\n\n",
+		"event: message\ndata: \"b\"\n\n",
+		": heartbeat\n\n",
+	} {
+		if !strings.Contains(string(body), s) {
+			t.Errorf("body should contain %q:\n%s", s, body)
+		}
+	}
+	if !strings.HasSuffix(string(body), "event: end\ndata: {\"done\":true}\n\n") {
+		t.Errorf("body should end with end event:\n%s", body)
+	}
+
+	for _, codec := range []Codec{JSONCodec, ProtobufCodec} {
+		recv := func(in string) (values []string, err error) {
+			r, err := DoStreamRequest(context.Background(), http.DefaultClient, codec, ts.URL, wrapperspb.String(in))
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			for {
+				out := new(wrapperspb.StringValue)
+				if err := r.Recv(out); err != nil {
+					if err == io.EOF {
+						err = nil
+					}
+					return values, err
+				}
+				values = append(values, out.Value)
+			}
+		}
+
+		values, err := recv("ab")
+		if err != nil || strings.Join(values, ",") != "a,b" {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		values, err = recv("fail")
+		if twerr, ok := err.(Error); !ok || twerr.Code() != Unavailable || strings.Join(values, ",") != "f,a" {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		values, err = recv("empty")
+		if twerr, ok := err.(Error); !ok || twerr.Code() != NotFound || len(values) != 0 {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+	}
+}
+
+func TestSSEReadEvent(t *testing.T) {
+	if b := appendSSEEvent(nil, SSEEventMessage, []byte("{\n\"a\":1\n}")); string(b) != "event: message\ndata: {\ndata: \"a\":1\ndata: }\n\n" {
+		t.Errorf("multi-line data = %q", b)
+	}
+
+	body := ": comment\n\nid: 1\nretry: 1000\nevent: other\ndata: x\n\n" +
+		"data:\r\ndata: \"a\"\r\n\r\n" +
+		"event: end\ndata:{\"done\":true}\n\n"
+	r := &StreamReader{r: bufio.NewReader(strings.NewReader(body))}
+	out := new(wrapperspb.StringValue)
+	if err := r.nextEvent(out); err != nil || out.Value != "a" {
+		t.Errorf("nextEvent = %v, %v", out, err)
+	}
+	if err := r.nextEvent(out); err != io.EOF {
+		t.Errorf("end event should return io.EOF, got %v", err)
+	}
+
+	r = &StreamReader{r: bufio.NewReader(strings.NewReader("event: end\ndata: {\"done\":true}\n"))}
+	if err := r.nextEvent(out); err == nil || !IsTransportError(err) {
+		t.Errorf("incomplete event should return transport error, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 流式响应默认参数
+const (
+	defaultStreamMinChunk      = 512
+	defaultStreamMaxChunk      = 64 << 10
+	defaultStreamFlushInterval = 100 * time.Millisecond
+)
+
+// StreamFlush 原因
+const (
+	StreamFlushSize     = "size"
+	StreamFlushDeadline = "deadline"
+	StreamFlushClose    = "close"
+	StreamFlushManual   = "manual"
+)
+
+// ErrStreamClosed 关闭之后继续写入时返回的错误
+var ErrStreamClosed = errors.New("twirp: stream writer is closed")
+
+// StreamOptions 流式响应参数，零值使用默认参数
+type StreamOptions struct {
+	// MinChunk 最小分块大小，默认 512 字节
+	MinChunk int
+	// MaxChunk 最大分块大小，默认 64KB
+	MaxChunk int
+	// FlushInterval 消息最多缓存多久，默认 100ms
+	FlushInterval time.Duration
+}
+
+// StreamFlush 一次输出的统计信息
+type StreamFlush struct {
+	// Reason 输出原因，取值为 StreamFlushSize、StreamFlushDeadline、StreamFlushClose 或者 StreamFlushManual
+	Reason string
+	// Bytes 本次输出的字节数
+	Bytes int
+	// Messages 本次输出的消息数量
+	Messages int
+	// Elapsed 写入和 Flush 的耗时
+	Elapsed time.Duration
+	// Throughput 平滑之后的客户端吞吐量，单位为字节每秒，还没有测量时为 0
+	Throughput float64
+	// ChunkSize 调整之后的分块大小
+	ChunkSize int
+}
+
+// StreamObserver 每次输出分块后调用，用于记录监控指标，由 hook 包设置
+var StreamObserver func(ctx context.Context, f StreamFlush)
+
+// StreamWriter 合并小消息的流式响应输出
+//
+// 缓存的内容达到分块大小或者最早的消息超过 FlushInterval 时输出并 Flush。
+// 每次输出时根据写入耗时估算客户端吞吐量，把分块大小调整为一个 FlushInterval
+// 内可以发送的数据量，慢速网络使用小分块降低延迟，快速网络使用大分块减少系统调用。
+//
+// StreamWriter 可以并发使用，但是接管 ResponseWriter 之后不要再直接写入，
+// 业务方法返回之前必须调用 Close。
+type StreamWriter struct {
+	ctx  context.Context
+	w    http.ResponseWriter
+	opts StreamOptions
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	messages int
+	chunk    int
+	rate     float64
+	timer    *time.Timer
+	closed   bool
+	err      error
+}
+
+// NewStreamWriter 返回输出到 w 的 StreamWriter
+func NewStreamWriter(ctx context.Context, w http.ResponseWriter, opts StreamOptions) *StreamWriter {
+	if opts.MinChunk <= 0 {
+		opts.MinChunk = defaultStreamMinChunk
+	}
+	if opts.MaxChunk < opts.MinChunk {
+		opts.MaxChunk = defaultStreamMaxChunk
+		if opts.MaxChunk < opts.MinChunk {
+			opts.MaxChunk = opts.MinChunk
+		}
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultStreamFlushInterval
+	}
+
+	return &StreamWriter{
+		ctx:   ctx,
+		w:     w,
+		opts:  opts,
+		chunk: opts.MinChunk,
+	}
+}
+
+// Write 写入一条完整的消息，消息的分隔由调用方负责
+func (s *StreamWriter) Write(msg []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrStreamClosed
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.buf.Write(msg)
+	s.messages++
+
+	if s.buf.Len() >= s.chunk {
+		s.flush(StreamFlushSize)
+	} else if s.timer == nil {
+		s.timer = time.AfterFunc(s.opts.FlushInterval, s.deadline)
+	}
+	return len(msg), s.err
+}
+
+// Flush 立即输出缓存的消息
+func (s *StreamWriter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flush(StreamFlushManual)
+	return s.err
+}
+
+// Close 输出剩余的消息，之后不能再写入
+func (s *StreamWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return s.err
+	}
+	s.flush(StreamFlushClose)
+	s.closed = true
+	return s.err
+}
+
+// ChunkSize 返回当前的分块大小
+func (s *StreamWriter) ChunkSize() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.chunk
+}
+
+func (s *StreamWriter) deadline() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.flush(StreamFlushDeadline)
+	}
+}
+
+// flush 输出缓存的消息并调整分块大小，调用方需要持有锁
+func (s *StreamWriter) flush(reason string) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.buf.Len() == 0 || s.err != nil {
+		return
+	}
+
+	n, messages := s.buf.Len(), s.messages
+	start := time.Now()
+	_, err := s.w.Write(s.buf.Bytes())
+	if f, ok := s.w.(http.Flusher); ok && err == nil {
+		f.Flush()
+	}
+	elapsed := time.Since(start)
+
+	s.buf.Reset()
+	s.messages = 0
+	if err != nil {
+		s.err = err
+		return
+	}
+
+	s.adjust(n, elapsed)
+
+	if StreamObserver != nil {
+		StreamObserver(s.ctx, StreamFlush{
+			Reason:     reason,
+			Bytes:      n,
+			Messages:   messages,
+			Elapsed:    elapsed,
+			Throughput: s.rate,
+			ChunkSize:  s.chunk,
+		})
+	}
+}
+
+// adjust 根据本次写入的耗时更新吞吐量，并把分块大小调整为一个 FlushInterval 内可以发送的数据量
+//
+// 写入内核缓冲区几乎不耗时，只有发送缓冲区写满即客户端接收变慢时写入才会阻塞，
+// 耗时太短时无法测量，直接使用最大分块。
+func (s *StreamWriter) adjust(n int, elapsed time.Duration) {
+	if elapsed < time.Millisecond {
+		s.chunk = s.opts.MaxChunk
+		return
+	}
+
+	rate := float64(n) / elapsed.Seconds()
+	if s.rate == 0 {
+		s.rate = rate
+	} else {
+		s.rate = 0.8*s.rate + 0.2*rate
+	}
+
+	chunk := int(s.rate * s.opts.FlushInterval.Seconds())
+	if chunk < s.opts.MinChunk {
+		chunk = s.opts.MinChunk
+	}
+	if chunk > s.opts.MaxChunk {
+		chunk = s.opts.MaxChunk
+	}
+	s.chunk = chunk
+}
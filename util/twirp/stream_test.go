@@ -0,0 +1,86 @@
+package twirp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamWriterCoalesce(t *testing.T) {
+	resp := httptest.NewRecorder()
+	s := NewStreamWriter(context.Background(), resp, StreamOptions{MinChunk: 10, MaxChunk: 100, FlushInterval: time.Hour})
+
+	for i := 0; i < 4; i++ {
+		if _, err := s.Write([]byte("ab")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resp.Body.Len() != 0 || resp.Flushed {
+		t.Fatalf("small messages should be buffered, got %q", resp.Body.String())
+	}
+
+	s.Write([]byte("abc"))
+	if got := resp.Body.String(); got != "abababababc" || !resp.Flushed {
+		t.Fatalf("body = %q, flushed = %v", got, resp.Flushed)
+	}
+	if got := s.ChunkSize(); got != 100 {
+		t.Errorf("chunk size of fast client = %d, want 100", got)
+	}
+
+	s.Write([]byte("tail"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(resp.Body.String(), "tail") {
+		t.Errorf("Close should flush the rest, got %q", resp.Body.String())
+	}
+	if _, err := s.Write([]byte("x")); err != ErrStreamClosed {
+		t.Errorf("write after close = %v, want ErrStreamClosed", err)
+	}
+}
+
+func TestStreamWriterDeadline(t *testing.T) {
+	flushed := make(chan StreamFlush, 1)
+	StreamObserver = func(ctx context.Context, f StreamFlush) { flushed <- f }
+	defer func() { StreamObserver = nil }()
+
+	s := NewStreamWriter(context.Background(), httptest.NewRecorder(), StreamOptions{FlushInterval: 10 * time.Millisecond})
+	s.Write([]byte("hello"))
+	s.Write([]byte("world"))
+
+	select {
+	case f := <-flushed:
+		if f.Reason != StreamFlushDeadline || f.Bytes != 10 || f.Messages != 2 {
+			t.Errorf("flush = %+v", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("messages should be flushed after FlushInterval")
+	}
+	s.Close()
+}
+
+// slowWriter 模拟吞吐量为 rate 字节每秒的客户端
+type slowWriter struct {
+	http.ResponseWriter
+	rate int
+}
+
+func (w slowWriter) Write(b []byte) (int, error) {
+	time.Sleep(time.Duration(len(b)) * time.Second / time.Duration(w.rate))
+	return w.ResponseWriter.Write(b)
+}
+
+func TestStreamWriterSlowClient(t *testing.T) {
+	w := slowWriter{ResponseWriter: httptest.NewRecorder(), rate: 100 << 10}
+	s := NewStreamWriter(context.Background(), w, StreamOptions{MinChunk: 1 << 10, MaxChunk: 64 << 10, FlushInterval: 50 * time.Millisecond})
+
+	s.Write(make([]byte, 2<<10))
+	// 100KB/s 的客户端 50ms 内大约可以接收 5KB
+	if got := s.ChunkSize(); got < 2<<10 || got > 8<<10 {
+		t.Errorf("chunk size of slow client = %d, want about 5KB", got)
+	}
+	s.Close()
+}
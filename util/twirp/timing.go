@@ -0,0 +1,90 @@
+package twirp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerTimingHeader 请求带有此头信息时，生成的代码会通过 Server-Timing 响应头
+// 返回各阶段耗时，方便前端和网关排查耗时问题。
+//
+//	Server-Timing: route;dur=0.052, auth;dur=0.004, decode;dur=0.031, validate;dur=0.002, handler;dur=12.7, marshal;dur=0.06
+//
+// 耗时单位为毫秒。
+const ServerTimingHeader = "X-Server-Timing"
+
+// Stage 请求处理阶段的耗时
+//
+// 生成的代码按照 route、auth、decode、validate、handler、marshal 的顺序标记，
+// 没有执行到的阶段不会记录，如鉴权失败的请求只有 route 和 auth。
+type Stage struct {
+	Name     string
+	Duration time.Duration
+}
+
+type serverTiming struct {
+	mu     sync.Mutex
+	last   time.Time
+	header bool
+	stages []Stage
+}
+
+// WithServerTiming 开始记录各阶段耗时，请求带有 ServerTimingHeader 时还会通过响应头返回
+func WithServerTiming(ctx context.Context, req *http.Request) context.Context {
+	st := &serverTiming{
+		last:   time.Now(),
+		header: req.Header.Get(ServerTimingHeader) != "",
+	}
+	return context.WithValue(ctx, ServerTimingKey, st)
+}
+
+// MarkServerTiming 记录从上次标记到现在的耗时，没有开启记录则直接忽略
+func MarkServerTiming(ctx context.Context, name string) {
+	st, ok := ctx.Value(ServerTimingKey).(*serverTiming)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	st.mu.Lock()
+	st.stages = append(st.stages, Stage{Name: name, Duration: now.Sub(st.last)})
+	st.last = now
+	st.mu.Unlock()
+}
+
+// ServerStages 返回已经记录的各阶段耗时，可以在 ResponseSent、Error 等 hooks 中上报监控
+func ServerStages(ctx context.Context) []Stage {
+	st, ok := ctx.Value(ServerTimingKey).(*serverTiming)
+	if !ok {
+		return nil
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return append([]Stage(nil), st.stages...)
+}
+
+func writeServerTiming(ctx context.Context, resp http.ResponseWriter) {
+	st, ok := ctx.Value(ServerTimingKey).(*serverTiming)
+	if !ok || !st.header {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if len(st.stages) == 0 {
+		return
+	}
+	items := make([]string, len(st.stages))
+	for i, s := range st.stages {
+		items[i] = fmt.Sprintf("%s;dur=%.3f", s.Name, float64(s.Duration)/float64(time.Millisecond))
+	}
+	resp.Header().Set("Server-Timing", strings.Join(items, ", "))
+}
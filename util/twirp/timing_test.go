@@ -0,0 +1,36 @@
+package twirp
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerTiming(t *testing.T) {
+	for _, header := range []bool{false, true} {
+		req := httptest.NewRequest("POST", "/", nil)
+		if header {
+			req.Header.Set(ServerTimingHeader, "1")
+		}
+		ctx := WithServerTiming(context.Background(), req)
+		MarkServerTiming(ctx, "route")
+		MarkServerTiming(ctx, "auth")
+
+		stages := ServerStages(ctx)
+		if len(stages) != 2 || stages[0].Name != "route" || stages[1].Name != "auth" {
+			t.Errorf("header=%v: stages = %v", header, stages)
+		}
+
+		resp := httptest.NewRecorder()
+		writeServerTiming(ctx, resp)
+		got := resp.Header().Get("Server-Timing")
+		if header != strings.HasPrefix(got, "route;dur=") || header != strings.Contains(got, ", auth;dur=") {
+			t.Errorf("header=%v: Server-Timing = %q", header, got)
+		}
+	}
+
+	if stages := ServerStages(context.Background()); stages != nil {
+		t.Errorf("stages without WithServerTiming = %v", stages)
+	}
+}
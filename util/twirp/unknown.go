@@ -0,0 +1,94 @@
+package twirp
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxUnknownFields 最多记录的未知字段数量，避免异常请求产生大量指标
+const maxUnknownFields = 10
+
+// UnknownJSONFields 返回 JSON 请求中 m 没有定义的字段
+//
+// 嵌套字段使用 . 连接，如 user.nick_name。数据不是合法的 JSON 对象时返回空，
+// 由后续的解析逻辑报错。well-known types 的内容不做检查。
+func UnknownJSONFields(data []byte, m proto.Message) []string {
+	var fields []string
+	unknownJSONFields(json.RawMessage(data), m.ProtoReflect().Descriptor(), "", &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func unknownJSONFields(data json.RawMessage, md protoreflect.MessageDescriptor, prefix string, fields *[]string) {
+	if md.FullName().Parent() == "google.protobuf" {
+		return
+	}
+
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil {
+		return
+	}
+
+	for key, value := range obj {
+		if len(*fields) >= maxUnknownFields {
+			return
+		}
+
+		// 扩展字段
+		if strings.HasPrefix(key, "[") {
+			continue
+		}
+
+		fd := md.Fields().ByJSONName(key)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(key))
+		}
+		if fd == nil {
+			*fields = append(*fields, prefix+key)
+			continue
+		}
+
+		name := prefix + string(fd.Name()) + "."
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Kind() != protoreflect.MessageKind {
+				continue
+			}
+			var values map[string]json.RawMessage
+			if json.Unmarshal(value, &values) != nil {
+				continue
+			}
+			for _, v := range values {
+				unknownJSONFields(v, fd.MapValue().Message(), name, fields)
+			}
+		case fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind:
+			continue
+		case fd.IsList():
+			var values []json.RawMessage
+			if json.Unmarshal(value, &values) != nil {
+				continue
+			}
+			for _, v := range values {
+				unknownJSONFields(v, fd.Message(), name, fields)
+			}
+		default:
+			unknownJSONFields(value, fd.Message(), name, fields)
+		}
+	}
+}
+
+// WithUnknownFields 记录请求中的未知字段，由生成的代码调用
+func WithUnknownFields(ctx context.Context, fields []string) context.Context {
+	return context.WithValue(ctx, UnknownFieldsKey, fields)
+}
+
+// UnknownFields 返回请求中的未知字段，需要开启 unknown_fields 生成参数
+func UnknownFields(ctx context.Context) []string {
+	fields, _ := ctx.Value(UnknownFieldsKey).([]string)
+	return fields
+}
@@ -0,0 +1,45 @@
+package twirp
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestUnknownJSONFields(t *testing.T) {
+	data := `{
+		"parameter": "a",
+		"foo": 1,
+		"file_to_generate": ["a.proto"],
+		"compilerVersion": {"major": 3, "bar": [1]},
+		"protoFile": [{"unknown": 1}],
+		"[ext.field]": 1
+	}`
+	// google.protobuf 中的 message 不做检查
+	fields := UnknownJSONFields([]byte(data), &pluginpb.CodeGeneratorRequest{})
+	want := []string{"compiler_version.bar", "foo"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+
+	if fields := UnknownJSONFields([]byte("not json"), &pluginpb.CodeGeneratorRequest{}); len(fields) != 0 {
+		t.Errorf("invalid json should be left to the parser, got %v", fields)
+	}
+
+	// 未知字段数量有上限
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(`"f` + strconv.Itoa(i) + `": 1`)
+	}
+	b.WriteString("}")
+	if fields := UnknownJSONFields([]byte(b.String()), &pluginpb.CodeGeneratorRequest{}); len(fields) != maxUnknownFields {
+		t.Errorf("%d fields are recorded, want %d", len(fields), maxUnknownFields)
+	}
+}
@@ -0,0 +1,35 @@
+package twirp
+
+import "github.com/golang/protobuf/proto"
+
+// vtMessage 由 protoc-gen-go-vtproto 生成的快速编解码方法
+// https://github.com/planetscale/vtprotobuf
+type vtMessage interface {
+	MarshalVT() ([]byte, error)
+	UnmarshalVT([]byte) error
+}
+
+// MarshalVT 优先使用 MarshalVT 编码 protobuf 消息，没有则使用 proto.Marshal
+func MarshalVT(m proto.Message) ([]byte, error) {
+	if vm, ok := m.(vtMessage); ok {
+		return vm.MarshalVT()
+	}
+	return proto.Marshal(m)
+}
+
+// UnmarshalVT 优先使用 UnmarshalVT 解析 protobuf 消息，没有则使用 proto.Unmarshal
+func UnmarshalVT(b []byte, m proto.Message) error {
+	if vm, ok := m.(vtMessage); ok {
+		return vm.UnmarshalVT(b)
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// MarshalVTProtobuf 同 MarshalProtobuf，但优先使用 MarshalVT 编码
+func MarshalVTProtobuf(m proto.Message) ([]byte, string, error) {
+	b, err := MarshalVT(m)
+	if err != nil {
+		return nil, "", wrapErr(err, "failed to marshal proto response")
+	}
+	return b, "application/protobuf", nil
+}
@@ -0,0 +1,206 @@
+package twirp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonpb "github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// 回调请求头，与 Standard Webhooks (https://www.standardwebhooks.com) 相同，
+// 合作方可以直接使用现成的库校验签名
+const (
+	WebhookIDHeader        = "Webhook-Id"
+	WebhookTimestampHeader = "Webhook-Timestamp"
+	WebhookSignatureHeader = "Webhook-Signature"
+)
+
+// WebhookAttempt 一次回调发送的记录
+type WebhookAttempt struct {
+	// ID 回调 ID，重试时保持不变，接收方可以用来去重
+	ID string
+	// URL 回调地址
+	URL string
+	// Body JSON 请求体
+	Body []byte
+	// Attempt 第几次发送，从 1 开始
+	Attempt int
+	// StatusCode 响应状态码，没有收到响应时为 0
+	StatusCode int
+	// Duration 本次发送的耗时
+	Duration time.Duration
+	// Err 本次发送的错误，成功时为 nil
+	Err error
+}
+
+// WebhookConfig 出站回调的签名、重试和死信配置
+type WebhookConfig struct {
+	// Secret 签名密钥，为空时不签名
+	Secret []byte
+	// MaxAttempts 最多发送的次数，包括第一次，默认为 3
+	MaxAttempts int
+	// Backoff 返回第 n 次重试之前的等待时间，默认从 500ms 开始指数退避
+	Backoff func(n int) time.Duration
+	// OnAttempt 每次发送之后调用，用于记录投递日志
+	OnAttempt func(ctx context.Context, a *WebhookAttempt)
+	// DeadLetter 所有重试都失败或者遇到不能重试的错误之后调用，用于保存回调以便人工处理或者稍后重放
+	DeadLetter func(ctx context.Context, a *WebhookAttempt)
+}
+
+func (c *WebhookConfig) maxAttempts() int {
+	if c == nil || c.MaxAttempts <= 0 {
+		return 3
+	}
+	return c.MaxAttempts
+}
+
+func (c *WebhookConfig) backoff(n int) time.Duration {
+	if c != nil && c.Backoff != nil {
+		return c.Backoff(n)
+	}
+	return 500 * time.Millisecond << uint(n-1)
+}
+
+// SignWebhook 计算回调签名，格式为 v1,base64(HMAC-SHA256(secret, id.timestamp.body))
+func SignWebhook(secret []byte, id string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook 校验回调请求头中的签名，供接收回调的服务使用
+//
+// tolerance 为允许的时间偏差，超过时认为是重放的请求。签名头可能包含多个空格分隔的签名，
+// 方便更换密钥，任意一个匹配即可。
+func VerifyWebhook(secret []byte, header http.Header, body []byte, tolerance time.Duration) error {
+	id := header.Get(WebhookIDHeader)
+	ts, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if id == "" || err != nil {
+		return NewError(Unauthenticated, "missing webhook id or timestamp")
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return NewError(Unauthenticated, "webhook timestamp is out of tolerance")
+	}
+
+	want := SignWebhook(secret, id, ts, body)
+	for _, sig := range strings.Fields(header.Get(WebhookSignatureHeader)) {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return nil
+		}
+	}
+	return NewError(Unauthenticated, "invalid webhook signature")
+}
+
+// DoWebhookRequest 以 JSON 格式发送回调，生成的 {Service}WebhookClient 使用
+//
+// 每次发送都重新签名。没有收到响应、响应 429 或者 5xx 时按照 config 重试，重试时回调 ID 不变；
+// ctx 结束之后不再重试。最终失败时调用 config.DeadLetter，并返回最后一次的错误。
+func DoWebhookRequest(ctx context.Context, client HTTPClient, config *WebhookConfig, url string, in, out proto.Message) error {
+	buf := bytes.NewBuffer(nil)
+	marshaler := &jsonpb.Marshaler{OrigName: true, AnyResolver: AnyResolver}
+	if err := marshaler.Marshal(buf, in); err != nil {
+		return clientError("failed to marshal json request", err)
+	}
+
+	a := &WebhookAttempt{ID: newWebhookID(), URL: url, Body: buf.Bytes()}
+	max := config.maxAttempts()
+	for a.Attempt = 1; ; a.Attempt++ {
+		start := time.Now()
+		a.StatusCode, a.Err = sendWebhook(ctx, client, config, a, out)
+		a.Duration = time.Since(start)
+		if config != nil && config.OnAttempt != nil {
+			config.OnAttempt(ctx, a)
+		}
+		if a.Err == nil {
+			return nil
+		}
+		if a.Attempt >= max || !webhookRetryable(a.StatusCode, a.Err) {
+			break
+		}
+
+		timer := time.NewTimer(config.backoff(a.Attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+			continue
+		}
+		break
+	}
+
+	if config != nil && config.DeadLetter != nil {
+		config.DeadLetter(ctx, a)
+	}
+	return a.Err
+}
+
+// sendWebhook 发送一次回调，返回响应状态码
+func sendWebhook(ctx context.Context, client HTTPClient, config *WebhookConfig, a *WebhookAttempt, out proto.Message) (status int, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, clientError("aborted because context was done", err)
+	}
+
+	call := newClientCall(ctx)
+	defer func() { call.done(err) }()
+
+	req, err := newRequest(ctx, a.URL, bytes.NewReader(a.Body), "application/json")
+	if err != nil {
+		return 0, clientError("could not build request", err)
+	}
+	now := time.Now().Unix()
+	req.Header.Set(WebhookIDHeader, a.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(now, 10))
+	if config != nil && len(config.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(config.Secret, a.ID, now, a.Body))
+	}
+	if req, err = call.prepare(req); err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, clientError("failed to do request", &transportError{cause: err})
+	}
+	defer resp.Body.Close()
+
+	// 合作方的回调地址一般不是 twirp 服务，2xx 都认为成功，响应体为空时 out 保持为空
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errorFromResponse(resp)
+	}
+	if resp.ContentLength == 0 || resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, nil
+	}
+	unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true, AnyResolver: AnyResolver}
+	if err = unmarshaler.Unmarshal(resp.Body, out); err != nil && !errors.Is(err, io.EOF) {
+		return resp.StatusCode, clientError("failed to unmarshal json response", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookRetryable 判断失败的回调是否可以重试
+func webhookRetryable(status int, err error) bool {
+	if status == 0 {
+		return IsTransportError(err)
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func newWebhookID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "msg_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "msg_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,127 @@
+package twirp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestWebhook(t *testing.T) {
+	secret := []byte("secret")
+	var calls int32
+	var ids []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifyWebhook(secret, r.Header, body, time.Minute); err != nil {
+			t.Errorf("VerifyWebhook: %v", err)
+		}
+		ids = append(ids, r.Header.Get(WebhookIDHeader))
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`"ok"`))
+	}))
+	defer ts.Close()
+
+	var attempts []int
+	config := &WebhookConfig{
+		Secret:     secret,
+		Backoff:    func(n int) time.Duration { return time.Millisecond },
+		OnAttempt:  func(ctx context.Context, a *WebhookAttempt) { attempts = append(attempts, a.StatusCode) },
+		DeadLetter: func(ctx context.Context, a *WebhookAttempt) { t.Error("DeadLetter should not be called") },
+	}
+	out := new(wrapperspb.StringValue)
+	if err := DoWebhookRequest(context.Background(), http.DefaultClient, config, ts.URL, wrapperspb.String("hi"), out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "ok" {
+		t.Errorf("out = %q", out.Value)
+	}
+	if len(attempts) != 3 || attempts[0] != 503 || attempts[2] != 200 {
+		t.Errorf("attempts = %v", attempts)
+	}
+	if ids[0] == "" || ids[0] != ids[1] || ids[1] != ids[2] {
+		t.Errorf("webhook id should not change across retries: %v", ids)
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	status := http.StatusBadRequest
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	var dead *WebhookAttempt
+	config := &WebhookConfig{
+		MaxAttempts: 2,
+		Backoff:     func(n int) time.Duration { return time.Millisecond },
+		DeadLetter:  func(ctx context.Context, a *WebhookAttempt) { dead = a },
+	}
+	for _, c := range []struct {
+		status, calls int
+	}{
+		{http.StatusBadRequest, 1},
+		{http.StatusTooManyRequests, 2},
+		{http.StatusInternalServerError, 2},
+	} {
+		status, calls, dead = c.status, 0, nil
+		err := DoWebhookRequest(context.Background(), http.DefaultClient, config, ts.URL, wrapperspb.String("hi"), new(wrapperspb.StringValue))
+		if err == nil {
+			t.Fatalf("%d: expected error", c.status)
+		}
+		if int(calls) != c.calls {
+			t.Errorf("%d: calls = %d, want %d", c.status, calls, c.calls)
+		}
+		if dead == nil || dead.StatusCode != c.status || dead.Attempt != c.calls || string(dead.Body) != `"hi"` {
+			t.Errorf("%d: dead letter = %+v", c.status, dead)
+		}
+	}
+
+	// 地址无法连接时重试，ctx 结束之后不再等待
+	ctx, cancel := context.WithCancel(context.Background())
+	config.Backoff = func(n int) time.Duration {
+		cancel()
+		return time.Hour
+	}
+	dead = nil
+	if err := DoWebhookRequest(ctx, http.DefaultClient, config, "http://127.0.0.1:1", wrapperspb.String("hi"), new(wrapperspb.StringValue)); !IsTransportError(err) {
+		t.Errorf("expected transport error, got %v", err)
+	}
+	if dead == nil || dead.Attempt != 1 {
+		t.Errorf("dead letter = %+v", dead)
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{}`)
+	now := time.Now().Unix()
+	header := http.Header{}
+	header.Set(WebhookIDHeader, "msg_1")
+	header.Set(WebhookTimestampHeader, strconv.FormatInt(now, 10))
+	header.Set(WebhookSignatureHeader, "v1,old "+SignWebhook(secret, "msg_1", now, body))
+	if err := VerifyWebhook(secret, header, body, time.Minute); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := VerifyWebhook(secret, header, []byte(`{"a":1}`), time.Minute); err == nil {
+		t.Error("tampered body should fail")
+	}
+
+	old := now - 3600
+	header.Set(WebhookTimestampHeader, strconv.FormatInt(old, 10))
+	header.Set(WebhookSignatureHeader, SignWebhook(secret, "msg_1", old, body))
+	if err := VerifyWebhook(secret, header, body, time.Minute); err == nil {
+		t.Error("stale timestamp should fail")
+	}
+}
@@ -0,0 +1,264 @@
+package twirp
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// WebSocket 协议（RFC 6455）的最小实现，只支持客户端流式和双向流式接口需要的功能：
+// 握手、消息分片、ping/pong 和关闭帧，不支持扩展（如 permessage-deflate）。
+
+// WebSocket 帧类型
+const (
+	wsContinuation byte = 0
+	wsText         byte = 1
+	wsBinary       byte = 2
+	wsClose        byte = 8
+	wsPing         byte = 9
+	wsPong         byte = 10
+)
+
+// WebSocket 关闭码
+const (
+	wsCloseNormal   = 1000
+	wsCloseProtocol = 1002
+)
+
+// wsGUID 计算 Sec-WebSocket-Accept 使用的固定值
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// errWebSocketClosed 对端发送了关闭帧
+var errWebSocketClosed = errors.New("twirp: websocket closed by peer")
+
+// errWebSocketTooLarge 消息超过长度限制
+var errWebSocketTooLarge = errors.New("twirp: websocket message too large")
+
+// IsWebSocketUpgrade 判断请求是否为 WebSocket 握手请求
+func IsWebSocketUpgrade(req *http.Request) bool {
+	return req.Method == http.MethodGet &&
+		headerHasToken(req.Header, "Connection", "upgrade") &&
+		headerHasToken(req.Header, "Upgrade", "websocket")
+}
+
+// headerHasToken 判断逗号分隔的请求头中是否包含 token，忽略大小写
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketAccept 返回握手响应的 Sec-WebSocket-Accept
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	_, _ = h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// newWebSocketKey 返回握手请求的 Sec-WebSocket-Key
+func newWebSocketKey() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b[:]), nil
+}
+
+// wsConn 握手完成之后的 WebSocket 连接
+//
+// 读取只能在一个 goroutine 中进行，写入可以并发。客户端发送的帧需要掩码。
+type wsConn struct {
+	rwc     io.ReadWriteCloser
+	r       *bufio.Reader
+	client  bool
+	maxSize int64
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+func newWSConn(rwc io.ReadWriteCloser, r *bufio.Reader, client bool, maxSize int64) *wsConn {
+	if r == nil {
+		r = bufio.NewReader(rwc)
+	}
+	if maxSize <= 0 {
+		maxSize = MaxStreamFrameSize
+	}
+	return &wsConn{rwc: rwc, r: r, client: client, maxSize: maxSize}
+}
+
+// writeFrame 发送一个完整的帧
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return ErrStreamClosed
+	}
+
+	header := make([]byte, 2, 14+len(payload))
+	header[0] = 0x80 | op
+	n := len(payload)
+	switch {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = append(header, byte(n>>8), byte(n))
+	default:
+		header[1] = 127
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(n))
+		header = append(header, b[:]...)
+	}
+
+	if c.client {
+		header[1] |= 0x80
+		var mask [4]byte
+		if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+			return err
+		}
+		header = append(header, mask[:]...)
+		start := len(header)
+		header = append(header, payload...)
+		for i := range header[start:] {
+			header[start+i] ^= mask[i%4]
+		}
+	} else {
+		header = append(header, payload...)
+	}
+
+	_, err := c.rwc.Write(header)
+	return err
+}
+
+// readMessage 读取一条完整的数据消息，自动回复 ping，收到关闭帧时回复并返回 errWebSocketClosed
+func (c *wsConn) readMessage() (op byte, msg []byte, err error) {
+	for {
+		fin, fop, payload, err := c.readFrame(int64(len(msg)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch fop {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			_ = c.close(wsCloseNormal, "")
+			return 0, nil, errWebSocketClosed
+		case wsText, wsBinary:
+			if op != 0 {
+				return 0, nil, c.fail(wsCloseProtocol, "unexpected data frame")
+			}
+			op = fop
+		case wsContinuation:
+			if op == 0 {
+				return 0, nil, c.fail(wsCloseProtocol, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(wsCloseProtocol, "unknown opcode")
+		}
+
+		msg = append(msg, payload...)
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+// readFrame 读取一个帧，read 为当前消息已经读取的长度
+func (c *wsConn) readFrame(read int64) (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.r, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(wsCloseProtocol, "reserved bits are set")
+	}
+	masked := header[1]&0x80 != 0
+	if masked == c.client {
+		// 客户端发送的帧必须掩码，服务端发送的帧不能掩码
+		return false, 0, nil, c.fail(wsCloseProtocol, "invalid mask")
+	}
+
+	n := int64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.r, b[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.r, b[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint64(b[:]))
+	}
+	if op >= wsClose && (n > 125 || !fin) {
+		return false, 0, nil, c.fail(wsCloseProtocol, "invalid control frame")
+	}
+	if n < 0 || read+n > c.maxSize {
+		// 不读取超长的内容，由调用方发送错误之后关闭连接
+		return false, 0, nil, errWebSocketTooLarge
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// fail 以 code 关闭连接，返回协议错误
+func (c *wsConn) fail(code int, reason string) error {
+	_ = c.close(code, reason)
+	return errors.New("twirp: websocket protocol error: " + reason)
+}
+
+// close 发送关闭帧并关闭连接，重复调用时直接返回
+func (c *wsConn) close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	_ = c.writeFrame(wsClose, payload)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,477 @@
+package twirp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// 客户端流式（rpc Upload(stream Req) returns (Resp)）和双向流式（rpc Chat(stream Req) returns (stream Resp)）
+// 接口使用 WebSocket 传输
+//
+// 客户端使用 GET 请求握手，通过 Sec-WebSocket-Protocol 指定编码，如 twirp.json、twirp.protobuf，
+// 也可以是 twirp.{Codec.Name()}，没有指定时按照 Content-Type 选择，默认为 JSON。
+// 两个方向的消息格式与服务端流式接口相同：JSON 使用文本消息，每条消息为 NDJSON 的一行（不含换行符）；
+// 其他编码使用二进制消息，1 字节标志之后为内容，WebSocket 本身分隔消息，不需要长度。
+// 客户端发送完请求之后发送 {"done": true} 结束帧，服务端返回之后发送结束帧并关闭连接。
+
+// WebSocketProtocolPrefix Sec-WebSocket-Protocol 的前缀，之后为 Codec 的名称
+const WebSocketProtocolPrefix = "twirp."
+
+// webSocketCodec 按照握手请求选择编码，返回选中的子协议
+func webSocketCodec(req *http.Request) (Codec, string) {
+	for _, v := range req.Header[http.CanonicalHeaderKey("Sec-WebSocket-Protocol")] {
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, WebSocketProtocolPrefix) {
+				continue
+			}
+			if c, ok := lookupCodecName(strings.TrimPrefix(p, WebSocketProtocolPrefix)); ok {
+				return c, p
+			}
+		}
+	}
+	if c, ok := LookupCodec(req.Header.Get("Content-Type")); ok {
+		return c, ""
+	}
+	return JSONCodec, ""
+}
+
+// lookupCodecName 按照名称查找注册的 Codec
+func lookupCodecName(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+func isJSONCodec(c Codec) bool {
+	return normalizeContentType(c.ContentType()) == "application/json"
+}
+
+// encodeWebSocketMessage 编码一条消息
+func encodeWebSocketMessage(c Codec, m proto.Message) (byte, []byte, error) {
+	b, err := c.Marshal(m)
+	if err != nil {
+		return 0, nil, err
+	}
+	if isJSONCodec(c) {
+		return wsText, append(append([]byte(`{"result":`), b...), '}'), nil
+	}
+	return wsBinary, append([]byte{StreamFrameMessage}, b...), nil
+}
+
+// encodeWebSocketTrailer 编码结束帧，twerr 为空时表示正常结束
+func encodeWebSocketTrailer(c Codec, twerr Error) (byte, []byte) {
+	trailer := encodeStreamTrailer(twerr)
+	if isJSONCodec(c) {
+		return wsText, trailer
+	}
+	return wsBinary, append([]byte{StreamFrameEnd}, trailer...)
+}
+
+// decodeWebSocketMessage 解析一条消息到 out，结束帧返回 io.EOF 或者对端发送的错误
+func decodeWebSocketMessage(c Codec, op byte, msg []byte, out proto.Message) error {
+	if isJSONCodec(c) {
+		if op != wsText {
+			return clientError("failed to read stream", errors.New("unexpected binary message"))
+		}
+		return decodeStreamFrame(c, msg, out)
+	}
+
+	if op != wsBinary || len(msg) == 0 {
+		return clientError("failed to read stream", errors.New("unexpected text message"))
+	}
+	switch msg[0] {
+	case StreamFrameMessage:
+		if out == nil {
+			return clientError("failed to read stream", errors.New("unexpected message after response"))
+		}
+		if err := c.Unmarshal(msg[1:], out); err != nil {
+			return clientError("failed to unmarshal "+c.Name()+" message", err)
+		}
+		return nil
+	case StreamFrameEnd:
+		return decodeStreamFrame(c, msg[1:], nil)
+	default:
+		return clientError("failed to read stream", errors.New("unknown frame flag "+strconv.Itoa(int(msg[0]))))
+	}
+}
+
+// WebSocketServerStream 服务端的 WebSocket 流，生成的代码创建并传给业务方法
+//
+// Recv 只能在一个 goroutine 中调用，Send 可以与 Recv 并发调用。
+// 客户端断开之后 Context 返回的 ctx 会被取消，但是只有在 Recv 或者 Send 时才能发现断开。
+type WebSocketServerStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	hooks  *ServerHooks
+	codec  Codec
+	conn   *wsConn
+
+	recvErr error
+
+	mu       sync.Mutex
+	finished bool
+}
+
+// AcceptWebSocket 完成 WebSocket 握手，返回的 error 需要作为普通的错误响应输出
+//
+// maxMessageSize 为单条消息的最大字节数，为 0 时使用 MaxStreamFrameSize。
+// 握手成功时触发 ResponsePrepared，ctx 中的状态码为 101。
+func AcceptWebSocket(ctx context.Context, resp http.ResponseWriter, req *http.Request, hooks *ServerHooks, maxMessageSize int64) (*WebSocketServerStream, error) {
+	if !IsWebSocketUpgrade(req) {
+		return nil, NewError(BadRoute, "websocket upgrade is required")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if req.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		return nil, NewError(InvalidArgument, "unsupported websocket version")
+	}
+	hj, ok := resp.(http.Hijacker)
+	if !ok {
+		return nil, InternalError("response writer does not support websocket")
+	}
+	codec, protocol := webSocketCodec(req)
+
+	ctx = WithStatusCode(ctx, http.StatusSwitchingProtocols)
+	ctx = hooks.CallResponsePrepared(ctx)
+	WriteResponseHeader(ctx, resp)
+	h := resp.Header()
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", webSocketAccept(key))
+	if protocol != "" {
+		h.Set("Sec-WebSocket-Protocol", protocol)
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, InternalErrorWith(err)
+	}
+	// 接管之后不再受 http.Server 的读写超时限制，由业务方法控制
+	_ = conn.SetDeadline(time.Time{})
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	_ = h.Write(rw)
+	_, _ = rw.WriteString("\r\n")
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, InternalErrorWith(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &WebSocketServerStream{
+		ctx:    ctx,
+		cancel: cancel,
+		hooks:  hooks,
+		codec:  codec,
+		conn:   newWSConn(conn, rw.Reader, false, maxMessageSize),
+	}, nil
+}
+
+// Context 返回业务方法使用的 ctx，客户端断开或者 Finish 之后取消
+func (s *WebSocketServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// Recv 读取客户端发送的下一条消息到 out，客户端发送完毕时返回 io.EOF，之后一直返回相同的错误
+func (s *WebSocketServerStream) Recv(out proto.Message) error {
+	if s.recvErr != nil {
+		return s.recvErr
+	}
+
+	err := s.recv(out)
+	if err == nil {
+		return nil
+	}
+	if err != io.EOF {
+		s.cancel()
+	}
+	s.recvErr = err
+	return err
+}
+
+func (s *WebSocketServerStream) recv(out proto.Message) error {
+	op, msg, err := s.conn.readMessage()
+	if err == errWebSocketTooLarge {
+		return NewError(ResourceExhausted, "websocket message too large").
+			WithMeta("max_message_size", strconv.FormatInt(s.conn.maxSize, 10))
+	}
+	if err != nil {
+		return NewError(Canceled, "stream closed by client")
+	}
+
+	err = decodeWebSocketMessage(s.codec, op, msg, out)
+	// 客户端发送的内容无法解析，不是服务端的内部错误
+	if twerr, ok := err.(Error); ok && twerr.Code() == Internal {
+		return NewError(InvalidArgument, twerr.Msg())
+	}
+	return err
+}
+
+// Send 发送一条消息，客户端断开之后返回错误，业务方法应该停止发送并返回
+func (s *WebSocketServerStream) Send(m proto.Message) error {
+	s.mu.Lock()
+	finished := s.finished
+	s.mu.Unlock()
+	if finished {
+		return ErrStreamClosed
+	}
+
+	op, b, err := encodeWebSocketMessage(s.codec, m)
+	if err != nil {
+		return InternalErrorWith(err)
+	}
+	if err := s.conn.writeFrame(op, b); err != nil {
+		return NewError(Canceled, "stream closed by client")
+	}
+	return nil
+}
+
+// Finish 发送结束帧并关闭连接，err 不为空时把错误写入结束帧，重复调用时直接返回
+func (s *WebSocketServerStream) Finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.finished {
+		return
+	}
+	s.finished = true
+
+	ctx := s.ctx
+	var twerr Error
+	if err != nil {
+		var ok bool
+		if twerr, ok = err.(Error); !ok {
+			twerr = InternalErrorWith(err)
+		}
+		ctx = s.hooks.CallError(ctx, twerr)
+	}
+
+	// 客户端已经断开时无法发送，与 WriteError 一样忽略写入错误
+	op, trailer := encodeWebSocketTrailer(s.codec, twerr)
+	_ = s.conn.writeFrame(op, trailer)
+	_ = s.conn.close(wsCloseNormal, "")
+	s.cancel()
+	s.hooks.CallResponseSent(ctx)
+}
+
+// WebSocketClientStream 客户端的 WebSocket 流，生成的 {Service}{Method}Stream 使用
+//
+// Send 和 CloseSend 在一个 goroutine 中调用，Recv 可以在另一个 goroutine 中并发调用。
+// 收到结束帧或者出错时关闭连接并触发客户端的 ResponseReceived 或者 Error 回调。
+type WebSocketClientStream struct {
+	ctx   context.Context
+	call  *clientCall
+	codec Codec
+	conn  *wsConn
+	stop  chan struct{}
+
+	mu         sync.Mutex
+	sendClosed bool
+	err        error
+}
+
+// DialWebSocket 使用 c 编码消息，与 url 建立 WebSocket 连接
+//
+// client 需要支持 101 响应，即返回的 Response.Body 实现了 io.ReadWriteCloser，net/http 的 Client 满足要求。
+// 建立连接之后 ctx 取消时关闭连接。
+func DialWebSocket(ctx context.Context, client HTTPClient, c Codec, url string) (_ *WebSocketClientStream, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, clientError("aborted because context was done", err)
+	}
+	key, err := newWebSocketKey()
+	if err != nil {
+		return nil, clientError("could not build request", err)
+	}
+
+	call := newClientCall(ctx)
+	defer func() {
+		if err != nil {
+			call.done(err)
+		}
+	}()
+
+	req, err := newRequest(ctx, url, nil, c.ContentType())
+	if err != nil {
+		return nil, clientError("could not build request", err)
+	}
+	req.Method = http.MethodGet
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Protocol", WebSocketProtocolPrefix+c.Name())
+	if req, err = call.prepare(req); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, clientError("failed to do request", &transportError{cause: err})
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		return nil, errorFromResponse(resp)
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		_ = resp.Body.Close()
+		return nil, clientError("failed to upgrade", errors.New("http client does not support websocket"))
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		_ = rwc.Close()
+		return nil, clientError("failed to upgrade", errors.New("invalid Sec-WebSocket-Accept"))
+	}
+
+	s := &WebSocketClientStream{
+		ctx:   ctx,
+		call:  call,
+		codec: c,
+		conn:  newWSConn(rwc, nil, true, 0),
+		stop:  make(chan struct{}),
+	}
+	go s.watch()
+	return s, nil
+}
+
+// watch ctx 取消时关闭连接，阻塞的 Recv 和 Send 随之返回
+func (s *WebSocketClientStream) watch() {
+	select {
+	case <-s.ctx.Done():
+		_ = s.conn.close(wsCloseNormal, "")
+	case <-s.stop:
+	}
+}
+
+// Send 发送一条消息
+func (s *WebSocketClientStream) Send(m proto.Message) error {
+	s.mu.Lock()
+	err, sendClosed := s.err, s.sendClosed
+	s.mu.Unlock()
+	if err != nil || sendClosed {
+		return ErrStreamClosed
+	}
+
+	op, b, err := encodeWebSocketMessage(s.codec, m)
+	if err != nil {
+		return clientError("failed to marshal "+s.codec.Name()+" request", err)
+	}
+	return s.write(op, b)
+}
+
+// CloseSend 发送结束帧，告诉服务端请求已经发送完毕，之后仍然可以调用 Recv
+func (s *WebSocketClientStream) CloseSend() error {
+	s.mu.Lock()
+	err, sendClosed := s.err, s.sendClosed
+	s.sendClosed = true
+	s.mu.Unlock()
+	if err != nil || sendClosed {
+		return nil
+	}
+
+	op, trailer := encodeWebSocketTrailer(s.codec, nil)
+	return s.write(op, trailer)
+}
+
+func (s *WebSocketClientStream) write(op byte, b []byte) error {
+	if err := s.conn.writeFrame(op, b); err != nil {
+		if cerr := s.ctx.Err(); cerr != nil {
+			return clientError("aborted because context was done", cerr)
+		}
+		return clientError("failed to send message", &transportError{cause: err})
+	}
+	return nil
+}
+
+// Recv 读取服务端发送的下一条消息到 out，正常结束时返回 io.EOF，之后一直返回相同的错误
+func (s *WebSocketClientStream) Recv(out proto.Message) error {
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = s.next(out)
+	if err == nil {
+		return nil
+	}
+	if cerr := s.ctx.Err(); cerr != nil && err != io.EOF {
+		err = clientError("aborted because context was done", cerr)
+	}
+	return s.end(err)
+}
+
+// CloseAndRecv 发送结束帧并读取服务端唯一的响应，客户端流式接口使用
+func (s *WebSocketClientStream) CloseAndRecv(out proto.Message) error {
+	if err := s.CloseSend(); err != nil {
+		_ = s.Close()
+		return err
+	}
+	err := s.Recv(out)
+	if err == io.EOF {
+		return clientError("failed to read stream", errors.New("stream ended without response"))
+	}
+	if err != nil {
+		return err
+	}
+	if err = s.Recv(nil); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// Close 关闭连接，没有读到结束帧时按调用方取消处理
+func (s *WebSocketClientStream) Close() error {
+	s.mu.Lock()
+	done := s.err != nil
+	s.mu.Unlock()
+	if done {
+		return nil
+	}
+	_ = s.end(NewError(Canceled, "stream closed by client"))
+	return nil
+}
+
+// end 记录结束原因、关闭连接并触发回调，返回最先记录的结束原因
+func (s *WebSocketClientStream) end(err error) error {
+	s.mu.Lock()
+	if s.err != nil {
+		err = s.err
+		s.mu.Unlock()
+		return err
+	}
+	s.err = err
+	s.mu.Unlock()
+
+	close(s.stop)
+	_ = s.conn.close(wsCloseNormal, "")
+	if err == io.EOF {
+		s.call.done(nil)
+	} else {
+		s.call.done(err)
+	}
+	return err
+}
+
+func (s *WebSocketClientStream) next(out proto.Message) error {
+	op, msg, err := s.conn.readMessage()
+	if err == errWebSocketClosed || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return clientError("stream ended without trailer", &transportError{cause: io.ErrUnexpectedEOF})
+	}
+	if err != nil {
+		return clientError("failed to read stream", &transportError{cause: err})
+	}
+	return decodeWebSocketMessage(s.codec, op, msg, out)
+}
@@ -0,0 +1,168 @@
+package twirp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// websocketServer 把客户端发送的消息原样返回，收到 fail 时返回错误，
+// 收到 count 时返回之前收到的消息数量。客户端先收到结束帧，服务端之后才触发 ResponseSent，
+// 每次调用之后从 sent 读取，等待回调完成。
+func websocketServer(t *testing.T, events *[]string, sent chan struct{}) *httptest.Server {
+	hooks := &ServerHooks{
+		ResponseSent: func(ctx context.Context) {
+			*events = append(*events, "sent")
+			sent <- struct{}{}
+		},
+		Error: func(ctx context.Context, err Error) context.Context {
+			*events = append(*events, string(err.Code()))
+			return ctx
+		},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := AcceptWebSocket(r.Context(), w, r, hooks, 64)
+		if err != nil {
+			hooks.WriteError(r.Context(), w, err)
+			return
+		}
+
+		n := 0
+		for {
+			in := new(wrapperspb.StringValue)
+			err := stream.Recv(in)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				stream.Finish(err)
+				return
+			}
+			switch in.Value {
+			case "fail":
+				stream.Finish(NewError(Unavailable, "failed"))
+				return
+			case "count":
+				err = stream.Send(wrapperspb.String(strings.Repeat("x", n)))
+			default:
+				err = stream.Send(in)
+			}
+			if err != nil {
+				t.Error(err)
+			}
+			n++
+		}
+		stream.Finish(nil)
+	}))
+}
+
+func TestWebSocketStream(t *testing.T) {
+	var events []string
+	sent := make(chan struct{}, 1)
+	ts := websocketServer(t, &events, sent)
+	defer ts.Close()
+
+	for _, codec := range []Codec{JSONCodec, ProtobufCodec} {
+		chat := func(msgs ...string) (values []string, err error) {
+			s, err := DialWebSocket(context.Background(), http.DefaultClient, codec, ts.URL)
+			if err != nil {
+				return nil, err
+			}
+			defer func() {
+				_ = s.Close()
+				<-sent
+			}()
+			for _, m := range msgs {
+				if err := s.Send(wrapperspb.String(m)); err != nil {
+					return nil, err
+				}
+			}
+			if err := s.CloseSend(); err != nil {
+				return nil, err
+			}
+			for {
+				out := new(wrapperspb.StringValue)
+				if err := s.Recv(out); err != nil {
+					if err == io.EOF {
+						err = nil
+					}
+					return values, err
+				}
+				values = append(values, out.Value)
+			}
+		}
+
+		events = nil
+		values, err := chat("a", "b", "count")
+		if err != nil || strings.Join(values, ",") != "a,b,xx" {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		values, err = chat("a", "fail", "b")
+		if twerr, ok := err.(Error); !ok || twerr.Code() != Unavailable || strings.Join(values, ",") != "a" {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		values, err = chat(strings.Repeat("x", 100))
+		if twerr, ok := err.(Error); !ok || twerr.Code() != ResourceExhausted || len(values) != 0 {
+			t.Errorf("%s: values = %v, err = %v", codec.Name(), values, err)
+		}
+
+		s, err := DialWebSocket(context.Background(), http.DefaultClient, codec, ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Send(wrapperspb.String("count")); err != nil {
+			t.Fatal(err)
+		}
+		out := new(wrapperspb.StringValue)
+		if err := s.CloseAndRecv(out); err != nil || out.Value != "" {
+			t.Errorf("%s: CloseAndRecv = %v, %v", codec.Name(), out, err)
+		}
+		<-sent
+
+		if want := "sent,unavailable,sent,resource_exhausted,sent,sent"; strings.Join(events, ",") != want {
+			t.Errorf("%s: server hooks = %v, want %s", codec.Name(), events, want)
+		}
+	}
+}
+
+func TestWebSocketUpgradeRequired(t *testing.T) {
+	var events []string
+	ts := websocketServer(t, &events, make(chan struct{}, 1))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	twerr := errorFromResponse(resp)
+	_ = resp.Body.Close()
+	if twerr.Code() != BadRoute {
+		t.Errorf("POST should fail with bad_route, got %v", twerr)
+	}
+}
+
+func TestWebSocketContextCanceled(t *testing.T) {
+	var events []string
+	ts := websocketServer(t, &events, make(chan struct{}, 1))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := DialWebSocket(ctx, http.DefaultClient, JSONCodec, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := s.Recv(new(wrapperspb.StringValue)); err == nil || err == io.EOF {
+		t.Errorf("Recv should fail after cancel, got %v", err)
+	}
+	if err := s.Send(wrapperspb.String("a")); err != ErrStreamClosed {
+		t.Errorf("Send after Recv failed = %v, want ErrStreamClosed", err)
+	}
+}
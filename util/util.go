@@ -3,11 +3,14 @@ package util
 import (
 	_ "sniper/util/conf" // init conf
 
+	"sniper/util/db"
 	"sniper/util/log"
+	"sniper/util/worker"
 )
 
 // GatherMetrics 收集一些被动指标
 func GatherMetrics() {
+	db.GatherMetrics()
 }
 
 // Reset all utils
@@ -17,4 +20,5 @@ func Reset() {
 
 // Stop all utils
 func Stop() {
+	worker.Stop()
 }
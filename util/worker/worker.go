@@ -0,0 +1,206 @@
+// Package worker 后台任务池
+//
+// 接口中不需要等待结果的工作（发通知、写日志、刷新缓存等）应该提交到任务池，
+// 不要直接使用 go func()：任务池限制了并发数和排队数量，任务 panic 不会导致进程退出，
+// 服务退出时会等待已提交的任务执行完成，不会在发布时丢失任务。
+//
+//	err := worker.Go(ctx, "send_welcome", func(ctx context.Context) {
+//		// ctx 保留了请求 ctx 中的值，但不会随请求结束而取消
+//	})
+package worker
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/ctxkit"
+	"sniper/util/log"
+	"sniper/util/metrics"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var (
+	// ErrQueueFull 任务队列已满
+	ErrQueueFull = errors.New("worker: queue is full")
+	// ErrStopped 任务池已经停止
+	ErrStopped = errors.New("worker: pool is stopped")
+)
+
+type task struct {
+	ctx  context.Context
+	name string
+	fn   func(context.Context)
+}
+
+// Pool 固定数量 worker 的任务池
+type Pool struct {
+	name  string
+	tasks chan task
+
+	mu      sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   []*Pool
+
+	defaultOnce sync.Once
+	defaultPool *Pool
+)
+
+// NewPool 创建名为 name 的任务池，workers 为并发数，queue 为最多排队的任务数
+//
+// 创建的任务池会在 Stop 时统一停止。
+func NewPool(name string, workers, queue int) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+
+	p := &Pool{name: name, tasks: make(chan task, queue)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	poolsMu.Lock()
+	pools = append(pools, p)
+	poolsMu.Unlock()
+	return p
+}
+
+// Go 提交任务，队列已满时返回 ErrQueueFull，不会阻塞调用方
+//
+// name 用于日志、调用链和监控。fn 收到的 ctx 保留了 ctx 中的值（trace_id 等），
+// 但不继承 deadline 和取消信号。
+func (p *Pool) Go(ctx context.Context, name string, fn func(context.Context)) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		metrics.WorkerTaskTotal.WithLabelValues(p.name, name, "rejected").Inc()
+		return ErrStopped
+	}
+
+	select {
+	case p.tasks <- task{ctx: ctxkit.Detach(ctx), name: name, fn: fn}:
+		return nil
+	default:
+		metrics.WorkerTaskTotal.WithLabelValues(p.name, name, "rejected").Inc()
+		return ErrQueueFull
+	}
+}
+
+// Stop 停止接收新任务，等待已提交的任务执行完成
+//
+// ctx 结束时不再等待，返回 ctx.Err()，未执行的任务会丢失。
+func (p *Pool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for t := range p.tasks {
+		p.run(t)
+	}
+}
+
+func (p *Pool) run(t task) {
+	parent := opentracing.SpanFromContext(t.ctx)
+	var opts []opentracing.StartSpanOption
+	if parent != nil {
+		opts = append(opts, opentracing.FollowsFrom(parent.Context()))
+	}
+	span := opentracing.StartSpan("Worker."+t.name, opts...)
+	ctx := opentracing.ContextWithSpan(t.ctx, span)
+
+	start := time.Now()
+	result := "ok"
+	defer func() {
+		if r := recover(); r != nil {
+			result = "panic"
+			span.SetTag("error", true)
+			log.Get(ctx).Error("[worker] ", p.name, ".", t.name, " panic: ", r, "\n", string(debug.Stack()))
+		}
+		span.Finish()
+		metrics.WorkerTaskTotal.WithLabelValues(p.name, t.name, result).Inc()
+		metrics.WorkerDurationsSeconds.WithLabelValues(p.name, t.name).Observe(time.Since(start).Seconds())
+	}()
+
+	t.fn(ctx)
+}
+
+// Default 返回默认任务池，并发数为 WORKER_SIZE（默认 16），排队数量为 WORKER_QUEUE（默认 1024）
+func Default() *Pool {
+	defaultOnce.Do(func() {
+		size := conf.GetInt("WORKER_SIZE")
+		if size <= 0 {
+			size = 16
+		}
+		queue := conf.GetInt("WORKER_QUEUE")
+		if queue <= 0 {
+			queue = 1024
+		}
+		defaultPool = NewPool("default", size, queue)
+	})
+	return defaultPool
+}
+
+// Go 向默认任务池提交任务
+func Go(ctx context.Context, name string, fn func(context.Context)) error {
+	return Default().Go(ctx, name, fn)
+}
+
+// Stop 停止所有任务池，最多等待 WORKER_DRAIN_TIMEOUT（默认 30s），在服务退出时调用
+func Stop() {
+	timeout := conf.GetDuration("WORKER_DRAIN_TIMEOUT")
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	poolsMu.Lock()
+	all := pools
+	poolsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range all {
+		wg.Add(1)
+		go func(p *Pool) {
+			defer wg.Done()
+			if err := p.Stop(ctx); err != nil {
+				log.Get(ctx).Errorf("[worker] stop pool %s: %v", p.name, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+}
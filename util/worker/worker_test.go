@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sniper/util/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type ctxKey struct{}
+
+func TestQueueFull(t *testing.T) {
+	p := NewPool("test_full", 1, 1)
+	defer p.Stop(context.Background())
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	if err := p.Go(context.Background(), "block", func(ctx context.Context) {
+		close(started)
+		<-block
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// worker 被占用，队列只能再放一个任务
+	if err := p.Go(context.Background(), "queued", func(ctx context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+	rejected := metrics.WorkerTaskTotal.WithLabelValues("test_full", "dropped", "rejected")
+	before := testutil.ToFloat64(rejected)
+	if err := p.Go(context.Background(), "dropped", func(ctx context.Context) {}); err != ErrQueueFull {
+		t.Errorf("err = %v, want ErrQueueFull", err)
+	}
+	if n := testutil.ToFloat64(rejected) - before; n != 1 {
+		t.Errorf("rejected = %v, want 1", n)
+	}
+	close(block)
+}
+
+func TestPanic(t *testing.T) {
+	p := NewPool("test_panic", 1, 2)
+
+	panics := metrics.WorkerTaskTotal.WithLabelValues("test_panic", "panic", "panic")
+	before := testutil.ToFloat64(panics)
+
+	var ran int32
+	p.Go(context.Background(), "panic", func(ctx context.Context) { panic("boom") })
+	p.Go(context.Background(), "after", func(ctx context.Context) { atomic.StoreInt32(&ran, 1) })
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&ran) != 1 {
+		t.Error("worker should keep running after a task panics")
+	}
+	if n := testutil.ToFloat64(panics) - before; n != 1 {
+		t.Errorf("panic = %v, want 1", n)
+	}
+}
+
+func TestStop(t *testing.T) {
+	p := NewPool("test_stop", 2, 10)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "v"))
+	var done int32
+	for i := 0; i < 5; i++ {
+		p.Go(ctx, "task", func(ctx context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			// 请求 ctx 取消后任务仍然可以读取其中的值
+			if ctx.Err() == nil && ctx.Value(ctxKey{}) == "v" {
+				atomic.AddInt32(&done, 1)
+			}
+		})
+	}
+	cancel()
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&done); n != 5 {
+		t.Errorf("done = %d, want 5", n)
+	}
+	if err := p.Go(context.Background(), "task", func(ctx context.Context) {}); err != ErrStopped {
+		t.Errorf("err = %v, want ErrStopped", err)
+	}
+	// 重复 Stop 不会 panic
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	p := NewPool("test_timeout", 1, 1)
+
+	block := make(chan struct{})
+	defer close(block)
+	p.Go(context.Background(), "block", func(ctx context.Context) { <-block })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Stop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+}
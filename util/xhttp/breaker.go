@@ -0,0 +1,87 @@
+package xhttp
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"sniper/util/metrics"
+)
+
+// ErrCircuitOpen 熔断期间直接返回的错误
+var ErrCircuitOpen = errors.New("xhttp: circuit breaker is open")
+
+// breaker 按域名熔断
+//
+// 连续失败 HTTP_BREAKER_FAILURES 次后熔断 HTTP_BREAKER_COOLDOWN，
+// 熔断结束后放行一个请求试探，成功则恢复，失败则继续熔断。
+type breaker struct {
+	mu       sync.Mutex
+	failures int64
+	openedAt time.Time
+	probing  bool
+}
+
+type breakers struct {
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+func (b *breakers) get(host string) *breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.hosts == nil {
+		b.hosts = map[string]*breaker{}
+	}
+	br, ok := b.hosts[host]
+	if !ok {
+		br = &breaker{}
+		b.hosts[host] = br
+	}
+	return br
+}
+
+// allow 判断是否放行请求，opts.breakerFailures 为 0 时不熔断
+func (b *breaker) allow(opts hostOptions, now time.Time) bool {
+	if opts.breakerFailures <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < opts.breakerFailures {
+		return true
+	}
+	if b.probing || now.Sub(b.openedAt) < opts.breakerCooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// done 记录请求结果
+func (b *breaker) done(opts hostOptions, host string, failed bool, now time.Time) {
+	if opts.breakerFailures <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		if b.failures >= opts.breakerFailures {
+			metrics.HTTPBreakerOpen.WithLabelValues(host).Set(0)
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= opts.breakerFailures {
+		b.openedAt = now
+		metrics.HTTPBreakerOpen.WithLabelValues(host).Set(1)
+	}
+}
@@ -1,13 +1,17 @@
-// Package http 提供基础 http 客户端组件
+// Package http 提供基础 http 客户端组件，用于调用第三方接口
 // 内置以下功能：
 // - logging
 // - opentracing
 // - prometheus
+// - 按域名配置超时时间、重试次数和熔断，如 HTTP_TIMEOUT_API_EXAMPLE_COM
 package xhttp
 
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"regexp"
 	"time"
@@ -16,13 +20,15 @@ import (
 	"sniper/util/log"
 	"sniper/util/metrics"
 	"sniper/util/trace"
+	"sniper/util/twirp"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 )
 
 type myClient struct {
-	cli *http.Client
+	cli      *http.Client
+	breakers breakers
 }
 
 // Client http 客户端接口
@@ -40,9 +46,111 @@ func NewClient(timeout time.Duration) Client {
 	}
 }
 
+// TwirpClient 把 Client 转换为生成的 twirp 客户端使用的 twirp.HTTPClient，
+// 调用下游 twirp 服务时同样按域名超时、重试和熔断，重试次数按下游接口记录在
+// sniper_rpc_client_retry_total 指标中
+func TwirpClient(c Client) twirp.HTTPClient {
+	return twirpClient{c}
+}
+
+type twirpClient struct {
+	c Client
+}
+
+func (c twirpClient) Do(req *http.Request) (*http.Response, error) {
+	return c.c.Do(req.Context(), req)
+}
+
 var digitsRE = regexp.MustCompile(`\b\d+\b`)
 
 func (c *myClient) Do(ctx context.Context, req *http.Request) (resp *http.Response, err error) {
+	host := req.URL.Hostname()
+	opts := loadHostOptions(host)
+	br := c.breakers.get(host)
+
+	cli := c.cli
+	if opts.timeout != nil {
+		copied := *c.cli
+		copied.Timeout = *opts.timeout
+		cli = &copied
+	}
+
+	for attempt := int64(0); ; attempt++ {
+		if !br.allow(opts, time.Now()) {
+			return nil, errors.Wrap(ErrCircuitOpen)
+		}
+
+		r := req
+		if attempt > 0 {
+			// 重试时需要重新读取请求体
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				if r.Body, err = req.GetBody(); err != nil {
+					return nil, errors.Wrap(err)
+				}
+			}
+		}
+
+		resp, err = c.do(ctx, cli, r)
+		br.done(opts, host, err != nil || resp.StatusCode >= 500, time.Now())
+
+		if attempt >= opts.retries || !retryable(req, resp, err) || ctx.Err() != nil {
+			return
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		metrics.HTTPRetryTotal.WithLabelValues(host).Inc()
+		if service, method := twirp.ClientMethod(ctx); service != "" {
+			metrics.RPCClientRetryTotal.WithLabelValues(service, method).Inc()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err())
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+// retryable 只重试幂等请求，网络错误、429 以及网关错误可以重试
+//
+// 非幂等请求带有 Idempotency-Key 头时也会重试，由服务端保证不会重复处理。
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		if req.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff 返回第 attempt 次重试前的等待时间，100ms 开始指数增长，最长 2s
+func backoff(attempt int64) time.Duration {
+	d := 100 * time.Millisecond << uint(attempt)
+	if d <= 0 || d > 2*time.Second {
+		d = 2 * time.Second
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// do 发送一次请求，记录日志、调用链和监控
+func (c *myClient) do(ctx context.Context, cli *http.Client, req *http.Request) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "DoHTTP")
 	defer span.Finish()
 
@@ -51,7 +159,7 @@ func (c *myClient) Do(ctx context.Context, req *http.Request) (resp *http.Respon
 	trace.InjectTraceHeader(span.Context(), req)
 
 	start := time.Now()
-	resp, err = c.cli.Do(req)
+	resp, err = cli.Do(req)
 	duration := time.Since(start)
 
 	url := fmt.Sprintf("%s%s", req.URL.Host, req.URL.Path)
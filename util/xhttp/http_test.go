@@ -0,0 +1,134 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sniper/util/conf"
+	"sniper/util/errors"
+)
+
+// setConf 设置测试使用的配置，测试结束后清空
+func setConf(t *testing.T, kvs ...string) {
+	for i := 0; i < len(kvs); i += 2 {
+		key := kvs[i]
+		conf.Set(key, kvs[i+1])
+		t.Cleanup(func() { conf.Set(key, "") })
+	}
+}
+
+// statusServer 依次返回 statuses 中的状态码，之后都返回 200
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *int64) {
+	var calls int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := atomic.AddInt64(&calls, 1); int(n) <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, &calls
+}
+
+func hostKeyOf(t *testing.T, s *httptest.Server) string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hostKey(u.Hostname())
+}
+
+func TestHostOptions(t *testing.T) {
+	setConf(t,
+		"HTTP_RETRIES", "3",
+		"HTTP_TIMEOUT", "1s",
+		"HTTP_RETRIES_API_EXAMPLE_COM", "0",
+		"HTTP_TIMEOUT_API_EXAMPLE_COM", "0",
+	)
+
+	// 域名配置为 0 时同样覆盖默认配置
+	opts := loadHostOptions("api.example.com")
+	if opts.retries != 0 || opts.timeout == nil || *opts.timeout != 0 {
+		t.Errorf("zero overrides should take effect, got retries %d, timeout %v", opts.retries, opts.timeout)
+	}
+
+	opts = loadHostOptions("other.example.com")
+	if opts.retries != 3 || opts.timeout == nil || *opts.timeout != time.Second {
+		t.Errorf("default options should be used, got retries %d, timeout %v", opts.retries, opts.timeout)
+	}
+
+	conf.Set("HTTP_TIMEOUT", "")
+	if opts := loadHostOptions("other.example.com"); opts.timeout != nil {
+		t.Errorf("timeout of NewClient should be used, got %v", *opts.timeout)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	s, calls := statusServer(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	setConf(t, "HTTP_RETRIES_"+hostKeyOf(t, s), "2")
+	c := NewClient(time.Second)
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err := c.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt64(calls) != 3 {
+		t.Errorf("GET should be retried twice, got status %d after %d calls", resp.StatusCode, *calls)
+	}
+
+	// 没有 Idempotency-Key 的 POST 请求不重试
+	s2, calls2 := statusServer(t, http.StatusServiceUnavailable)
+	setConf(t, "HTTP_RETRIES_"+hostKeyOf(t, s2), "2")
+	req, _ = http.NewRequest(http.MethodPost, s2.URL, nil)
+	resp, err = c.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt64(calls2) != 1 {
+		t.Errorf("POST should not be retried, got status %d after %d calls", resp.StatusCode, *calls2)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	s, calls := statusServer(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	key := hostKeyOf(t, s)
+	setConf(t, "HTTP_BREAKER_FAILURES_"+key, "2", "HTTP_BREAKER_COOLDOWN_"+key, "50ms")
+	c := NewClient(time.Second)
+
+	get := func() (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+		resp, err := c.Do(context.Background(), req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 连续失败两次之后熔断，请求不会发出
+	if _, err := get(); errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("circuit should be open, got %v", err)
+	}
+	if n := atomic.LoadInt64(calls); n != 2 {
+		t.Errorf("open circuit should not send requests, got %d calls", n)
+	}
+
+	// 熔断结束后放行试探请求，成功则恢复
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if resp, err := get(); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("circuit should be closed after a successful probe, got %v", err)
+		}
+	}
+}
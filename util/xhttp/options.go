@@ -0,0 +1,61 @@
+package xhttp
+
+import (
+	"strings"
+	"time"
+
+	"sniper/util/conf"
+)
+
+// hostOptions 单个域名的调用配置
+//
+// 每项配置先查询 HTTP_{NAME}_{HOST}，没有配置再查询 HTTP_{NAME}，配置为 0 同样生效，
+// 可以单独关闭某个域名的重试或者超时。HOST 为大写的域名，非字母数字替换成下划线，
+// 如 api.example.com 的超时时间为 HTTP_TIMEOUT_API_EXAMPLE_COM。
+type hostOptions struct {
+	// timeout 单次请求的超时时间，为 nil 时使用 NewClient 传入的超时时间，为 0 表示不超时
+	timeout *time.Duration
+	// retries 失败后的最大重试次数，只重试幂等请求
+	retries int64
+	// breakerFailures 连续失败多少次后熔断，为零表示不熔断
+	breakerFailures int64
+	// breakerCooldown 熔断持续时间
+	breakerCooldown time.Duration
+}
+
+func loadHostOptions(host string) hostOptions {
+	key := hostKey(host)
+	opts := hostOptions{
+		retries:         conf.GetInt64(hostConfKey("RETRIES", key)),
+		breakerFailures: conf.GetInt64(hostConfKey("BREAKER_FAILURES", key)),
+		breakerCooldown: conf.GetDuration(hostConfKey("BREAKER_COOLDOWN", key)),
+	}
+	if k := hostConfKey("TIMEOUT", key); conf.Get(k) != "" {
+		timeout := conf.GetDuration(k)
+		opts.timeout = &timeout
+	}
+	if opts.breakerCooldown <= 0 {
+		opts.breakerCooldown = 10 * time.Second
+	}
+	return opts
+}
+
+func hostKey(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// hostConfKey 返回生效的配置名，域名配置存在时（包括 0）使用域名配置
+func hostConfKey(name, key string) string {
+	if k := "HTTP_" + name + "_" + key; conf.Get(k) != "" {
+		return k
+	}
+	return "HTTP_" + name
+}